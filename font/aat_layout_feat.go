@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package font
+
+import "github.com/boxesandglue/typesetting/font/opentype/tables"
+
+// AATFeatureInfo describes a feature type defined in the AAT 'feat' table,
+// with its settings, in a form suitable to be presented to users.
+type AATFeatureInfo struct {
+	// Name is the human readable name of the feature, as found in
+	// the 'name' table. It may be empty.
+	Name string
+	// Settings are the possible settings (or selectors) of the feature.
+	// For non exclusive features, each setting enables the feature, and
+	// the following odd selector (Selector + 1) disables it.
+	Settings []AATFeatureSetting
+	// Type is the feature type, as used in the 'morx' table.
+	Type uint16
+	// Default is the setting applied when the feature is not explicitly
+	// requested. It is only meaningful for exclusive features.
+	Default uint16
+	// IsExclusive is true if the settings are mutually exclusive.
+	IsExclusive bool
+}
+
+// AATFeatureSetting is one setting of an [AATFeatureInfo].
+type AATFeatureSetting struct {
+	// Name is the human readable name of the setting, as found in
+	// the 'name' table. It may be empty.
+	Name     string
+	Selector uint16
+}
+
+// AATFeatures returns the features defined in the 'feat' table,
+// with their names resolved using the 'name' table, so that
+// applications may list the features, and their settings, in a user interface.
+// It returns nil if the font has no 'feat' table.
+func (f *Font) AATFeatures() []AATFeatureInfo {
+	if len(f.Feat.Names) == 0 {
+		return nil
+	}
+	out := make([]AATFeatureInfo, len(f.Feat.Names))
+	for i, feature := range f.Feat.Names {
+		info := AATFeatureInfo{
+			Name:        f.names.Name(tables.NameID(feature.NameIndex)),
+			Settings:    make([]AATFeatureSetting, len(feature.SettingTable)),
+			Type:        feature.Feature,
+			IsExclusive: feature.IsExclusive(),
+		}
+		info.Default, _ = feature.DefaultSetting()
+		for j, setting := range feature.SettingTable {
+			info.Settings[j] = AATFeatureSetting{
+				Name:     f.names.Name(tables.NameID(setting.NameIndex)),
+				Selector: setting.Setting,
+			}
+		}
+		out[i] = info
+	}
+	return out
+}
@@ -195,13 +195,20 @@ func newKern1(k tables.KernData1) Kern1 {
 		}
 		states[i] = v
 	}
+	// the value offsets are resolved to indices during parsing :
+	// remove them from the flags, where they would be read as the 'kerx' reset flag
+	entries := make([]tables.AATStateEntry, len(k.Entries))
+	for i, entry := range k.Entries {
+		entry.Flags &^= tables.Kern1Offset
+		entries[i] = entry
+	}
 	return Kern1{
 		Values: k.Values,
 		Machine: AATStateTable{
 			nClass:  uint32(k.StateSize),
 			class:   class,
 			states:  states,
-			entries: k.Entries,
+			entries: entries,
 		},
 	}
 }
@@ -301,6 +308,9 @@ func (st *AATStateTable) GetClass(glyph GID) uint16 {
 	if glyph == 0xFFFF { // deleted glyph
 		return 2 // class deleted
 	}
+	if st.class == nil { // invalid table
+		return 1
+	}
 	c, ok := st.class.Class(tables.GlyphID(glyph))
 	if !ok {
 		return 1 // class out of bounds
@@ -314,9 +324,12 @@ func (st *AATStateTable) GetEntry(state, class uint16) tables.AATStateEntry {
 	if uint32(class) >= st.nClass {
 		class = 1 // class out of bounds
 	}
-	if int(state) >= len(st.states) {
+	if int(state) >= len(st.states) || int(class) >= len(st.states[state]) {
+		return tables.AATStateEntry{}
+	}
+	entry := st.states[state][class]
+	if int(entry) >= len(st.entries) { // should be checked when parsing
 		return tables.AATStateEntry{}
 	}
-	entry := st.states[state][class] // access check when parsing
-	return st.entries[entry]         // access check when parsing
+	return st.entries[entry]
 }
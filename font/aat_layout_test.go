@@ -226,3 +226,23 @@ func TestKerx1(t *testing.T) {
 		tu.Assert(t, expectedEntriesLength[i] == len(kern1.Machine.entries))
 	}
 }
+
+func TestAATFeatures(t *testing.T) {
+	ft := loadFont(t, "toys/Feat.ttf")
+	features := ft.AATFeatures()
+	tu.Assert(t, len(features) == 11)
+
+	ligatures := features[0]
+	tu.Assert(t, ligatures.Type == 1 && !ligatures.IsExclusive)
+	tu.Assert(t, len(ligatures.Settings) == 3 && ligatures.Settings[2].Selector == 10)
+
+	letterCase := features[1]
+	tu.Assert(t, letterCase.Type == 3 && letterCase.IsExclusive && letterCase.Default == 0)
+	tu.Assert(t, len(letterCase.Settings) == 3 && letterCase.Settings[2].Selector == 3)
+
+	// this test font has no feature names
+	tu.Assert(t, letterCase.Name == "" && letterCase.Settings[0].Name == "")
+
+	ft = loadFont(t, "common/Raleway-v4020-Regular.otf")
+	tu.Assert(t, ft.AATFeatures() == nil)
+}
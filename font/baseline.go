@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package font
+
+import (
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
+)
+
+// Baseline tags, as registered in the OpenType specification,
+// and used by [Face.Baseline].
+var (
+	// The baseline used by alphabetic scripts such as Latin, Cyrillic and Greek.
+	BaselineRoman = ot.MustNewTag("romn")
+	// The hanging baseline, used by scripts such as Devanagari, Gurmukhi and Bengali.
+	BaselineHanging = ot.MustNewTag("hang")
+	// The bottom edge of the ideographic character face.
+	BaselineIdeoFaceBottom = ot.MustNewTag("icfb")
+	// The top edge of the ideographic character face.
+	BaselineIdeoFaceTop = ot.MustNewTag("icft")
+	// The bottom edge of the ideographic em-box, used by CJK scripts.
+	BaselineIdeoEmBoxBottom = ot.MustNewTag("ideo")
+	// The top edge of the ideographic em-box.
+	BaselineIdeoEmBoxTop = ot.MustNewTag("idtp")
+	// The baseline about which mathematical characters are centered.
+	BaselineMath = ot.MustNewTag("math")
+)
+
+var tagDefaultScript = ot.MustNewTag("DFLT")
+
+// Baseline returns the position of the [baseline] for the OpenType [script] tag,
+// as defined by the 'BASE' table, with the variation deltas applied for the current
+// coordinates of the face.
+// The position is expressed in font units, and is a Y coordinate for horizontal text,
+// or an X coordinate if [isVertical] is true.
+//
+// If [script] is not found in the table, the default script is used. If the
+// font has no value for the baseline, false is returned.
+func (f *Face) Baseline(baseline, script Tag, isVertical bool) (float32, bool) {
+	axis := &f.base.HorizAxis
+	if isVertical {
+		axis = &f.base.VertAxis
+	}
+
+	baseScript := findBaseScript(&axis.BaseScriptList, script)
+	if baseScript == nil {
+		return 0, false
+	}
+	baseValues := &baseScript.BaseValues
+	index := -1
+	for i, tag := range axis.BaseTagList.BaselineTags {
+		if tag == baseline {
+			index = i
+			break
+		}
+	}
+	if index == -1 || index >= len(baseValues.BaseCoords) {
+		return 0, false
+	}
+
+	return f.resolveBaseCoord(baseValues.BaseCoords[index])
+}
+
+// resolveBaseCoord returns the value of [coord], applying the variation
+// deltas, or false for null offsets
+func (f *Face) resolveBaseCoord(coord tables.BaseCoord) (float32, bool) {
+	switch coord := coord.(type) {
+	case tables.BaseCoordFormat1:
+		return float32(coord.Coordinate), true
+	case tables.BaseCoordFormat2:
+		// the adjustment on the contour point is only
+		// meaningful for hinting
+		return float32(coord.Coordinate), true
+	case tables.BaseCoordFormat3:
+		out := float32(coord.Coordinate)
+		if device, ok := coord.Device.(tables.DeviceVariation); ok && len(f.coords) != 0 {
+			out += f.base.ItemVarStore.GetDelta(tables.VariationStoreIndex(device), f.coords)
+		}
+		return out, true
+	default: // null offset
+		return 0, false
+	}
+}
+
+// findBaseScript returns the entry for [script], falling back
+// to the default script, or nil if not found
+func findBaseScript(list *tables.BaseScriptList, script Tag) *tables.BaseScript {
+	index := -1
+	for i, rec := range list.Records {
+		if rec.Tag == script {
+			index = i
+			break
+		} else if rec.Tag == tagDefaultScript {
+			index = i // keep looking for an exact match
+		}
+	}
+	if index == -1 || index >= len(list.BaseScripts) {
+		return nil
+	}
+	return &list.BaseScripts[index]
+}
+
+// LineExtents returns the minimum and maximum extents recommended by the 'BASE' table
+// for the OpenType [script] and [language] tags, with the variation deltas applied for
+// the current coordinates of the face.
+// These extents bound the glyphs of the script in the direction perpendicular to the text,
+// and may be used to compute consistent line heights for paragraphs mixing several scripts.
+// They are expressed in font units, as Y coordinates for horizontal text,
+// or X coordinates if [isVertical] is true.
+//
+// If [script] is not found in the table, the default script is used. The values of the
+// [language] system, if any, take precedence over the default values of the script.
+// The extents specific to a feature are not taken into account (see [tables.MinMax.FeatMinMaxRecords]).
+//
+// If the font does not provide both extents, false is returned.
+func (f *Face) LineExtents(script, language Tag, isVertical bool) (minExtent, maxExtent float32, ok bool) {
+	axis := &f.base.HorizAxis
+	if isVertical {
+		axis = &f.base.VertAxis
+	}
+
+	baseScript := findBaseScript(&axis.BaseScriptList, script)
+	if baseScript == nil {
+		return 0, 0, false
+	}
+	minCoord, maxCoord := baseScript.DefaultMinMax.MinCoord, baseScript.DefaultMinMax.MaxCoord
+	for i, rec := range baseScript.BaseLangSysRecords {
+		if rec.Tag == language && i < len(baseScript.BaseLangSys) {
+			langSys := baseScript.BaseLangSys[i]
+			if langSys.MinCoord != nil {
+				minCoord = langSys.MinCoord
+			}
+			if langSys.MaxCoord != nil {
+				maxCoord = langSys.MaxCoord
+			}
+			break
+		}
+	}
+
+	minExtent, okMin := f.resolveBaseCoord(minCoord)
+	maxExtent, okMax := f.resolveBaseCoord(maxCoord)
+	if !okMin || !okMax {
+		return 0, 0, false
+	}
+	return minExtent, maxExtent, true
+}
+
+// the representative characters used to synthesize
+// the hanging baseline
+var hangingBaselineRunes = map[Tag]rune{
+	ot.MustNewTag("beng"): 0x0995,
+	ot.MustNewTag("bng2"): 0x0995,
+	ot.MustNewTag("deva"): 0x0915,
+	ot.MustNewTag("dev2"): 0x0915,
+	ot.MustNewTag("guru"): 0x0A15,
+	ot.MustNewTag("gur2"): 0x0A15,
+	ot.MustNewTag("tibt"): 0x0F40,
+}
+
+// BaselineWithFallback is the same as [Face.Baseline], but synthesizes
+// the baselines not provided by the font (for instance if it has no 'BASE' table),
+// using its metrics and the extents of representative glyphs, as described in
+// https://www.w3.org/TR/css-inline-3/#baseline-synthesis-fonts.
+//
+// Baselines unknown to this package are reported at 0 when missing.
+func (f *Face) BaselineWithFallback(baseline, script Tag, isVertical bool) float32 {
+	if coord, ok := f.Baseline(baseline, script, isVertical); ok {
+		return coord
+	}
+
+	upem := float32(f.Upem())
+	switch baseline {
+	case BaselineHanging:
+		if !isVertical {
+			if r, ok := hangingBaselineRunes[script]; ok {
+				if gid, ok := f.NominalGlyph(r); ok {
+					if extents, ok := f.GlyphExtents(gid); ok {
+						return extents.YBearing
+					}
+				}
+			}
+		}
+		return upem * 6 / 10
+	case BaselineMath:
+		if !isVertical {
+			gid, ok := f.NominalGlyph(0x2212) // MINUS SIGN
+			if !ok {
+				gid, ok = f.NominalGlyph('-')
+			}
+			if ok {
+				if extents, ok := f.GlyphExtents(gid); ok {
+					return extents.YBearing + extents.Height/2
+				}
+			}
+		}
+		return f.LineMetric(XHeight) / 2
+	case BaselineIdeoFaceTop, BaselineIdeoFaceBottom:
+		top := f.BaselineWithFallback(BaselineIdeoEmBoxTop, script, isVertical)
+		bottom := f.BaselineWithFallback(BaselineIdeoEmBoxBottom, script, isVertical)
+		// the ideographic character face is 90% of the em box
+		if baseline == BaselineIdeoFaceTop {
+			return top - (top-bottom)/10
+		}
+		return bottom + (top-bottom)/10
+	case BaselineIdeoEmBoxTop:
+		return f.BaselineWithFallback(BaselineIdeoEmBoxBottom, script, isVertical) + upem
+	case BaselineIdeoEmBoxBottom:
+		if top, ok := f.Baseline(BaselineIdeoEmBoxTop, script, isVertical); ok {
+			return top - upem
+		}
+		// center the em box between the ascender and the descender
+		ascender, descender := f.ascenderDescender(isVertical)
+		return (ascender + descender - upem) / 2
+	default: // BaselineRoman
+		return 0
+	}
+}
+
+// ascenderDescender returns the ascender and descender of the font,
+// with fallback values if missing
+func (f *Face) ascenderDescender(isVertical bool) (ascender, descender float32) {
+	upem := float32(f.Upem())
+	if isVertical {
+		if extents, ok := f.FontVExtents(); ok {
+			return extents.Ascender, extents.Descender
+		}
+		return upem / 2, -upem / 2
+	}
+	if extents, ok := f.FontHExtents(); ok {
+		return extents.Ascender, extents.Descender
+	}
+	return upem * 0.8, -upem * 0.2
+}
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"math"
 
-	ot "github.com/boxesandglue/typesetting/font/opentype"
 	"github.com/boxesandglue/typesetting/font/opentype/tables"
 )
 
@@ -67,16 +66,9 @@ func strikeSizeMetrics(b tables.Strike, hori *tables.Hhea, avgWidth, upem uint16
 
 // ---------------------------- bitmap ----------------------------
 
-func loadBitmap(ld *ot.Loader, tagLoc, tagData ot.Tag) (bitmap, error) {
-	raw, err := ld.RawTable(tagLoc)
-	if err != nil {
-		return nil, err
-	}
-	loc, _, err := tables.ParseCBLC(raw)
-	if err != nil {
-		return nil, err
-	}
-	imageTable, err := ld.RawTable(tagData)
+// parseBitmap parses the location and data tables of a bitmap format
+func parseBitmap(locRaw, imageTable []byte) (bitmap, error) {
+	loc, _, err := tables.ParseCBLC(locRaw)
 	if err != nil {
 		return nil, err
 	}
@@ -121,6 +113,20 @@ func (t bitmap) availableSizes(avgWidth, upem uint16) []BitmapSize {
 	return out
 }
 
+// hasPNG returns true if one of the strikes uses
+// the PNG image formats, defined in the 'CBDT' table
+func (bt bitmap) hasPNG() bool {
+	for _, strike := range bt {
+		for _, sub := range strike.subTables {
+			switch sub.imageFormat {
+			case 17, 18, 19:
+				return true
+			}
+		}
+	}
+	return false
+}
+
 type bitmapStrike struct {
 	subTables    []bitmapSubtable
 	hori, vert   tables.SbitLineMetrics
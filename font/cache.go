@@ -1,26 +1,44 @@
 package font
 
+import "sync"
+
+// extentsState is the state of a cache entry
+type extentsState uint8
+
+const (
+	extentsUnknown extentsState = iota // not computed yet
+	extentsValid
+	extentsMissing // computed, but the glyph has no extents
+)
+
 type glyphExtents struct {
-	valid   bool
+	state   extentsState
 	extents GlyphExtents
 }
 
+// extentsCache stores the extents of each glyph, for the current
+// settings of a [Face]. It is allocated on first use.
 type extentsCache []glyphExtents
 
-func (ec extentsCache) get(gid GID) (GlyphExtents, bool) {
+// get returns the cached extents of [gid], and false if
+// they are unknown
+func (ec extentsCache) get(gid GID) (glyphExtents, bool) {
 	if int(gid) >= len(ec) {
-		return GlyphExtents{}, false
+		return glyphExtents{}, false
 	}
 	ge := ec[gid]
-	return ge.extents, ge.valid
+	return ge, ge.state != extentsUnknown
 }
 
-func (ec extentsCache) set(gid GID, extents GlyphExtents) {
+func (ec extentsCache) set(gid GID, extents GlyphExtents, ok bool) {
 	if int(gid) >= len(ec) {
 		return
 	}
-	ec[gid].valid = true
-	ec[gid].extents = extents
+	if ok {
+		ec[gid] = glyphExtents{state: extentsValid, extents: extents}
+	} else {
+		ec[gid] = glyphExtents{state: extentsMissing}
+	}
 }
 
 func (ec extentsCache) reset() {
@@ -29,13 +47,78 @@ func (ec extentsCache) reset() {
 	}
 }
 
+// GlyphExtents returns the ink extents of the glyph, in font units,
+// or false if the glyph is invalid or has no extents.
+//
+// The extents are computed from the outlines (with the variations applied for
+// the current coordinates of the face), or from the bitmaps matching the current ppem.
+// They are cached, so that repeated calls (for instance by the shaper) do not
+// parse the outlines again; the cache is cleared when the coordinates or the ppem change.
 func (f *Face) GlyphExtents(glyph GID) (GlyphExtents, bool) {
 	if e, ok := f.extentsCache.get(glyph); ok {
-		return e, ok
+		return e.extents, e.state == extentsValid
 	}
-	e, ok := f.glyphExtentsRaw(glyph)
-	if ok {
-		f.extentsCache.set(glyph, e)
+	if f.extentsCache == nil {
+		f.extentsCache = make(extentsCache, f.nGlyphs)
 	}
+	e, ok := f.glyphExtentsRaw(glyph)
+	f.extentsCache.set(glyph, e, ok)
 	return e, ok
 }
+
+// advanceCacheSize is the number of entries of an [advanceCache],
+// which must be a power of 2
+const advanceCacheSize = 256
+
+// advanceCache is a small direct-mapped cache storing the horizontal
+// advances of a variable [Face], which are expensive to compute, for
+// the current coordinates.
+// As in HarfBuzz, glyphs are mapped to entries using their low bits,
+// so that frequent glyphs are found without hashing.
+type advanceCache [advanceCacheSize]cachedAdvance
+
+type cachedAdvance struct {
+	key     uint32 // the glyph + 1, or 0 for an empty entry
+	advance float32
+}
+
+func (ac *advanceCache) get(gid GID) (float32, bool) {
+	entry := ac[gid&(advanceCacheSize-1)]
+	return entry.advance, entry.key == uint32(gid)+1
+}
+
+func (ac *advanceCache) set(gid GID, advance float32) {
+	ac[gid&(advanceCacheSize-1)] = cachedAdvance{key: uint32(gid) + 1, advance: advance}
+}
+
+func (ac *advanceCache) reset() { *ac = advanceCache{} }
+
+// shaperCache stores data derived from the font tables,
+// which does not depend on the settings of a [Face].
+type shaperCache struct {
+	mu   sync.Mutex
+	data map[any]any
+}
+
+// ShaperData returns the value associated with [key], calling [build]
+// to compute it on first use. It is used by shapers to share data structures
+// which only depend on the font tables (like lookup accelerators)
+// between the objects created for the same face.
+//
+// [key] should be a value of an unexported type, as for [context.Context] values.
+// The returned value is shared, and must not be modified.
+// ShaperData is safe for concurrent use; [build] is called at most once per key.
+func (f *Face) ShaperData(key any, build func() any) any {
+	f.shaperCache.mu.Lock()
+	defer f.shaperCache.mu.Unlock()
+
+	if v, ok := f.shaperCache.data[key]; ok {
+		return v
+	}
+	v := build()
+	if f.shaperCache.data == nil {
+		f.shaperCache.data = make(map[any]any)
+	}
+	f.shaperCache.data[key] = v
+	return v
+}
@@ -0,0 +1,101 @@
+package font
+
+import (
+	"fmt"
+	"sync"
+
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+)
+
+// Collection provides access to the fonts of a collection file (.ttc, .otc),
+// which are only loaded when requested by [Collection.Font].
+//
+// The fonts of a collection usually share some tables,
+// (typically 'glyf', 'CFF ' or 'cmap' for CJK collections),
+// which are then parsed once and shared by the returned [*Font]s,
+// reducing the memory usage.
+//
+// Single font files are also supported, as a collection of one font.
+// Since the fonts are read lazily, the file must remain valid while
+// using the collection.
+//
+// A [Collection] is safe for concurrent use.
+type Collection struct {
+	loaders []*ot.Loader
+
+	mu     sync.Mutex // guards fonts, shared and the reads of the file
+	fonts  []*Font    // lazily loaded
+	shared sharedTables
+}
+
+// NewCollection reads the header of [file], without loading its fonts.
+func NewCollection(file Resource) (*Collection, error) {
+	lds, err := ot.NewLoaders(file)
+	if err != nil {
+		return nil, err
+	}
+	return &Collection{
+		loaders: lds,
+		fonts:   make([]*Font, len(lds)),
+		shared:  make(sharedTables),
+	}, nil
+}
+
+// Len returns the number of fonts in the collection.
+func (c *Collection) Len() int { return len(c.loaders) }
+
+// Describe returns the family and aspect of the [index]-th font,
+// only reading the tables required. See [Describe] for details.
+func (c *Collection) Describe(index int) Description {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	desc, _ := Describe(c.loaders[index], nil)
+	return desc
+}
+
+// Font returns the [index]-th font, loading it if needed.
+// It panics if [index] is out of range.
+func (c *Collection) Font(index int) (*Font, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ft := c.fonts[index]; ft != nil {
+		return ft, nil
+	}
+	ft, err := newFont(c.loaders[index], c.shared)
+	if err != nil {
+		return nil, fmt.Errorf("reading font %d of collection: %s", index, err)
+	}
+	c.fonts[index] = ft
+	return ft, nil
+}
+
+// sharedKey identifies a parsed table by the location
+// of the tables it depends on, and by the other values used to parse it.
+type sharedKey struct {
+	tag   ot.Tag
+	ids   [2]ot.TableID
+	param int
+}
+
+// sharedTables stores the tables already parsed for a collection.
+// A nil map disables the sharing.
+type sharedTables map[sharedKey]interface{}
+
+// load returns the table parsed by [parse], which only depends
+// on the tables [deps] (at most 2, starting by the table itself) and on [param],
+// reusing the value already parsed for another font of the collection if possible.
+func (st sharedTables) load(ld *ot.Loader, deps []ot.Tag, param int, parse func() interface{}) interface{} {
+	if st == nil {
+		return parse()
+	}
+	key := sharedKey{tag: deps[0], param: param}
+	for i, tag := range deps {
+		key.ids[i], _ = ld.TableID(tag) // a missing table has a zero ID
+	}
+	if value, has := st[key]; has {
+		return value
+	}
+	value := parse()
+	st[key] = value
+	return value
+}
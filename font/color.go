@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package font
+
+import "image/color"
+
+// ForegroundColorIndex is the palette index used by the color glyph
+// layers which should be drawn with the text (foreground) color.
+const ForegroundColorIndex = 0xFFFF
+
+// ColorLayer is one layer of a color glyph, as defined in the 'COLR' table.
+type ColorLayer struct {
+	// Glyph is the (outline) glyph to draw for this layer.
+	Glyph GID
+	// PaletteIndex is the index of the color to use in the selected palette
+	// (see [Font.ColorPalettes]), or [ForegroundColorIndex].
+	PaletteIndex uint16
+}
+
+// ColorGlyphLayers returns the layers of [glyph], as defined in the
+// version 0 of the 'COLR' table, in drawing order (bottom layer first).
+// It returns nil if [glyph] is not a color glyph, in which case it
+// should be drawn as usual.
+func (f *Font) ColorGlyphLayers(glyph GID) []ColorLayer {
+	if glyph > 0xFFFF {
+		return nil
+	}
+	layers := f.colrTable().Layers(gID(glyph))
+	if len(layers) == 0 {
+		return nil
+	}
+	out := make([]ColorLayer, len(layers))
+	for i, layer := range layers {
+		out[i] = ColorLayer{Glyph: GID(layer.GlyphID), PaletteIndex: layer.PaletteIndex}
+	}
+	return out
+}
+
+// ColorPaletteFlags indicates the backgrounds a palette is designed for.
+type ColorPaletteFlags uint32
+
+const (
+	// PaletteUsableWithLightBackground is set if the palette
+	// is appropriate to use when displaying the font on a light background.
+	PaletteUsableWithLightBackground ColorPaletteFlags = 1 << iota
+	// PaletteUsableWithDarkBackground is set if the palette
+	// is appropriate to use when displaying the font on a dark background.
+	PaletteUsableWithDarkBackground
+)
+
+// ColorPalette is a palette defined in the 'CPAL' table.
+type ColorPalette struct {
+	// Colors has one entry for each palette index
+	// used by the color glyph layers.
+	Colors []color.NRGBA
+	// Name is the name of the palette, as found in the 'name' table.
+	// It may be empty.
+	Name  string
+	Flags ColorPaletteFlags
+}
+
+// ColorPalettes returns the palettes defined in the 'CPAL' table, or nil
+// if the font has no such table. The first palette is the default one.
+func (f *Font) ColorPalettes() []ColorPalette {
+	cpal := f.cpalTable()
+	if len(cpal.ColorRecordIndices) == 0 {
+		return nil
+	}
+	out := make([]ColorPalette, 0, len(cpal.ColorRecordIndices))
+	for i := range cpal.ColorRecordIndices {
+		records := cpal.Palette(i)
+		if records == nil { // invalid table
+			return nil
+		}
+		palette := ColorPalette{Colors: make([]color.NRGBA, len(records))}
+		for j, c := range records {
+			palette.Colors[j] = color.NRGBA{R: c.Red, G: c.Green, B: c.Blue, A: c.Alpha}
+		}
+		if i < len(cpal.PaletteTypes) {
+			palette.Flags = ColorPaletteFlags(cpal.PaletteTypes[i])
+		}
+		if i < len(cpal.PaletteLabels) && cpal.PaletteLabels[i] != 0xFFFF {
+			palette.Name = f.names.Name(cpal.PaletteLabels[i])
+		}
+		out = append(out, palette)
+	}
+	return out
+}
+
+// HasColorGlyphs returns true if the font provides color glyphs,
+// using one of the 'COLR', 'CBDT' (with PNG images), 'sbix' or 'SVG ' tables.
+func (f *Font) HasColorGlyphs() bool {
+	return len(f.colrTable().BaseGlyphRecords) != 0 || len(f.sbixTable()) != 0 || len(f.svg) != 0 || f.bitmapTable().hasPNG()
+}
@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sync/atomic"
 
 	"github.com/boxesandglue/typesetting/font/cff"
 	ot "github.com/boxesandglue/typesetting/font/opentype"
@@ -59,16 +60,19 @@ func ParseTTF(file Resource) (*Face, error) {
 
 // ParseTTC parse an Opentype font file, with support for collections.
 // Single font files are supported, returning a slice with length 1.
+//
+// The tables shared by the fonts of a collection are only parsed once.
+// See [Collection] to only load some of the fonts.
 func ParseTTC(file Resource) ([]*Face, error) {
-	lds, err := ot.NewLoaders(file)
+	collection, err := NewCollection(file)
 	if err != nil {
 		return nil, err
 	}
-	out := make([]*Face, len(lds))
-	for i, ld := range lds {
-		ft, err := NewFont(ld)
+	out := make([]*Face, collection.Len())
+	for i := range out {
+		ft, err := collection.Font(i)
 		if err != nil {
-			return nil, fmt.Errorf("reading font %d of collection: %s", i, err)
+			return nil, err
 		}
 		out[i] = NewFace(ft)
 	}
@@ -148,28 +152,32 @@ type Font struct {
 	post post         // optional
 	svg  svg          // optional
 
-	glyf   tables.Glyf
-	hmtx   tables.Hmtx
-	vmtx   tables.Vmtx
-	bitmap bitmap
-	sbix   sbix
+	glyf tables.Glyf
+	hmtx tables.Hmtx
+	vmtx tables.Vmtx
+
+	// COLR, CPAL, JSTF and the bitmap tables, parsed on first use
+	lazy *lazyTables
 
 	os2   os2
 	names tables.Name
 	head  tables.Head
+	stat  tables.STAT // optional, see [Font.StyleName]
 
 	// Optional, only present in variable fonts
 
-	fvar fvar         // optional
-	hvar *tables.HVAR // optional
-	vvar *tables.VVAR // optional
-	avar tables.Avar
-	mvar mvar
-	gvar gvar
+	fvar      fvar                    // optional
+	instances []tables.InstanceRecord // optional, from 'fvar'
+	hvar      *tables.HVAR            // optional
+	vvar      *tables.VVAR            // optional
+	avar      tables.Avar
+	mvar      mvar
+	gvar      gvar
 
 	// Advanced layout tables.
 
 	GDEF tables.GDEF // An absent table has a nil GlyphClassDef
+	base tables.BASE // optional, see [Face.Baseline]
 	Trak tables.Trak
 	Ankr tables.Ankr
 	Feat tables.Feat
@@ -187,7 +195,18 @@ type Font struct {
 // NewFont loads all the font tables, sanitizing them.
 // An error is returned only when required tables 'cmap', 'head', 'maxp' are invalid (or missing).
 // More control on errors is available by using package [tables].
-func NewFont(ld *ot.Loader) (*Font, error) {
+func NewFont(ld *ot.Loader) (*Font, error) { return newFont(ld, nil) }
+
+// cmapTables is the result of [ProcessCmap]
+type cmapTables struct {
+	cmap    Cmap
+	cmapVar UnicodeVariations
+	err     error
+}
+
+// newFont implements [NewFont], reusing the tables
+// of [shared] (which may be nil).
+func newFont(ld *ot.Loader, shared sharedTables) (*Font, error) {
 	var (
 		out Font
 		err error
@@ -195,22 +214,28 @@ func NewFont(ld *ot.Loader) (*Font, error) {
 
 	// 'cmap' handling depend on os2
 	raw, _ := ld.RawTable(ot.MustNewTag("OS/2"))
-	os2, _, _ := tables.ParseOs2(raw)
+	os2, _, errOs2 := tables.ParseOs2(raw)
 	fontPage := os2.FontPage()
-	out.os2, _ = newOs2(os2)
+	out.os2, err = newOs2(os2)
+	out.os2.isValid = errOs2 == nil && err == nil
 
-	raw, err = ld.RawTable(ot.MustNewTag("cmap"))
-	if err != nil {
-		return nil, err
-	}
-	tb, _, err := tables.ParseCmap(raw)
-	if err != nil {
-		return nil, err
-	}
-	out.Cmap, out.cmapVar, err = ProcessCmap(tb, fontPage)
-	if err != nil {
-		return nil, err
+	cmaps := shared.load(ld, []ot.Tag{ot.MustNewTag("cmap")}, int(fontPage), func() interface{} {
+		raw, err := ld.RawTable(ot.MustNewTag("cmap"))
+		if err != nil {
+			return cmapTables{err: err}
+		}
+		tb, _, err := tables.ParseCmap(raw)
+		if err != nil {
+			return cmapTables{err: err}
+		}
+		var out cmapTables
+		out.cmap, out.cmapVar, out.err = ProcessCmap(tb, fontPage)
+		return out
+	}).(cmapTables)
+	if cmaps.err != nil {
+		return nil, cmaps.err
 	}
+	out.Cmap, out.cmapVar = cmaps.cmap, cmaps.cmapVar
 
 	out.head, _, err = LoadHeadTable(ld, nil)
 	if err != nil {
@@ -237,27 +262,32 @@ func NewFont(ld *ot.Loader) (*Font, error) {
 	raw, _ = ld.RawTable(ot.MustNewTag("fvar"))
 	fvar, _, _ := tables.ParseFvar(raw)
 	out.fvar = newFvar(fvar)
+	out.instances = fvar.Instances
 
 	raw, _ = ld.RawTable(ot.MustNewTag("avar"))
 	out.avar, _, _ = tables.ParseAvar(raw)
 
 	out.upem = out.head.Upem()
 
-	raw, _ = ld.RawTable(ot.MustNewTag("glyf"))
-	locaRaw, _ := ld.RawTable(ot.MustNewTag("loca"))
-	loca, err := tables.ParseLoca(locaRaw, out.nGlyphs, out.head.IndexToLocFormat == 1)
-	if err == nil { // ParseGlyf panics if len(loca) == 0
-		out.glyf, _ = tables.ParseGlyf(raw, loca)
-	}
-
-	out.bitmap = selectBitmapTable(ld)
-
-	raw, _ = ld.RawTable(ot.MustNewTag("sbix"))
-	sbix, _, _ := tables.ParseSbix(raw, out.nGlyphs)
-	out.sbix = newSbix(sbix)
-
-	out.cff, _ = loadCff(ld, out.nGlyphs)
-	out.cff2, _ = loadCff2(ld, out.nGlyphs, len(out.fvar))
+	out.glyf = shared.load(ld, []ot.Tag{ot.MustNewTag("glyf"), ot.MustNewTag("loca")}, out.nGlyphs<<1|int(out.head.IndexToLocFormat&1), func() interface{} {
+		raw, _ := ld.RawTable(ot.MustNewTag("glyf"))
+		locaRaw, _ := ld.RawTable(ot.MustNewTag("loca"))
+		loca, err := tables.ParseLoca(locaRaw, out.nGlyphs, out.head.IndexToLocFormat == 1)
+		var glyf tables.Glyf
+		if err == nil { // ParseGlyf panics if len(loca) == 0
+			glyf, _ = tables.ParseGlyf(raw, loca)
+		}
+		return glyf
+	}).(tables.Glyf)
+
+	out.cff = shared.load(ld, []ot.Tag{ot.MustNewTag("CFF ")}, out.nGlyphs, func() interface{} {
+		cff, _ := loadCff(ld, out.nGlyphs)
+		return cff
+	}).(*cff.CFF)
+	out.cff2 = shared.load(ld, []ot.Tag{ot.MustNewTag("CFF2")}, out.nGlyphs<<16|len(out.fvar), func() interface{} {
+		cff2, _ := loadCff2(ld, out.nGlyphs, len(out.fvar))
+		return cff2
+	}).(*cff.CFF2)
 
 	raw, _ = ld.RawTable(ot.MustNewTag("post"))
 	post, _, _ := tables.ParsePost(raw)
@@ -267,6 +297,8 @@ func NewFont(ld *ot.Loader) (*Font, error) {
 	svg, _, _ := tables.ParseSVG(raw)
 	out.svg, _ = newSvg(svg)
 
+	out.lazy = newLazyTables(ld, out.nGlyphs)
+
 	out.hhea, out.hmtx, _ = loadHmtx(ld, out.nGlyphs)
 	out.vhea, out.vmtx, _ = loadVmtx(ld, out.nGlyphs)
 
@@ -300,23 +332,39 @@ func NewFont(ld *ot.Loader) (*Font, error) {
 
 	raw, _ = ld.RawTable(ot.MustNewTag("name"))
 	out.names, _, _ = tables.ParseName(raw)
+	raw, _ = ld.RawTable(ot.MustNewTag("STAT"))
+	out.stat, _, _ = tables.ParseSTAT(raw)
 
 	// layout tables
-	out.GDEF, _ = loadGDEF(ld, len(out.fvar))
-
-	raw, _ = ld.RawTable(ot.MustNewTag("GSUB"))
-	layout, _, err := tables.ParseLayout(raw)
-	// harfbuzz relies on GSUB.Loookups being nil when the table is absent
-	if err == nil {
-		out.GSUB, _ = newGSUB(layout)
-	}
-
-	raw, _ = ld.RawTable(ot.MustNewTag("GPOS"))
-	layout, _, err = tables.ParseLayout(raw)
-	// harfbuzz relies on GPOS.Loookups being nil when the table is absent
-	if err == nil {
-		out.GPOS, _ = newGPOS(layout)
-	}
+	out.GDEF = shared.load(ld, []ot.Tag{ot.MustNewTag("GDEF")}, len(out.fvar), func() interface{} {
+		gdef, _ := loadGDEF(ld, len(out.fvar))
+		return gdef
+	}).(tables.GDEF)
+
+	raw, _ = ld.RawTable(ot.MustNewTag("BASE"))
+	out.base, _, _ = tables.ParseBASE(raw)
+
+	out.GSUB = shared.load(ld, []ot.Tag{ot.MustNewTag("GSUB")}, 0, func() interface{} {
+		var gsub GSUB
+		raw, _ := ld.RawTable(ot.MustNewTag("GSUB"))
+		layout, _, err := tables.ParseLayout(raw)
+		// harfbuzz relies on GSUB.Loookups being nil when the table is absent
+		if err == nil {
+			gsub, _ = newGSUB(layout)
+		}
+		return gsub
+	}).(GSUB)
+
+	out.GPOS = shared.load(ld, []ot.Tag{ot.MustNewTag("GPOS")}, 0, func() interface{} {
+		var gpos GPOS
+		raw, _ := ld.RawTable(ot.MustNewTag("GPOS"))
+		layout, _, err := tables.ParseLayout(raw)
+		// harfbuzz relies on GPOS.Loookups being nil when the table is absent
+		if err == nil {
+			gpos, _ = newGPOS(layout)
+		}
+		return gpos
+	}).(GPOS)
 
 	raw, _ = ld.RawTable(ot.MustNewTag("morx"))
 	morx, _, _ := tables.ParseMorx(raw, out.nGlyphs)
@@ -370,25 +418,6 @@ func LoadHeadTable(ld *ot.Loader, buffer []byte) (tables.Head, []byte, error) {
 }
 
 // return nil if no table is valid (or present)
-func selectBitmapTable(ld *ot.Loader) bitmap {
-	color, err := loadBitmap(ld, ot.MustNewTag("CBLC"), ot.MustNewTag("CBDT"))
-	if err == nil {
-		return color
-	}
-
-	gray, err := loadBitmap(ld, ot.MustNewTag("EBLC"), ot.MustNewTag("EBDT"))
-	if err == nil {
-		return gray
-	}
-
-	apple, err := loadBitmap(ld, ot.MustNewTag("bloc"), ot.MustNewTag("bdat"))
-	if err == nil {
-		return apple
-	}
-
-	return nil
-}
-
 // return nil if the table is missing or invalid
 func loadCff(ld *ot.Loader, numGlyphs int) (*cff.CFF, error) {
 	raw, err := ld.RawTable(ot.MustNewTag("CFF "))
@@ -491,15 +520,50 @@ func loadGDEF(ld *ot.Loader, axisCount int) (tables.GDEF, error) {
 type Face struct {
 	*Font
 
-	extentsCache extentsCache
+	extentsCache extentsCache  // lazily allocated by GlyphExtents
+	advanceCache *advanceCache // lazily allocated by HorizontalAdvance, for variable faces
+	shaperCache  shaperCache   // see ShaperData
 
 	coords       []tables.Coord
 	xPpem, yPpem uint16
+
+	generation uint64 // see [Face.Generation]
 }
 
-// NewFace wraps [font] and initializes glyph caches.
+// lastGeneration is shared by all the faces, so that
+// generations are never reused
+var lastGeneration atomic.Uint64
+
+// NewFace wraps [font]. The glyph caches are allocated on first use.
 func NewFace(font *Font) *Face {
-	return &Face{Font: font, extentsCache: make(extentsCache, font.nGlyphs)}
+	return &Face{Font: font, generation: lastGeneration.Add(1)}
+}
+
+// Generation returns a token identifying the current settings of the face
+// (variable coordinates and ppem), and of the shaping settings stored alongside it
+// (see [Face.BumpGeneration]). It changes each time these settings are
+// modified, so that caches depending on them may be invalidated
+// without comparing the settings.
+//
+// Generations are unique among all the faces of a program, and increase
+// over time.
+func (f *Face) Generation() uint64 { return f.generation }
+
+// BumpGeneration changes the generation of the face, without modifying its settings.
+// It is called by the types adding settings on top of a face, so that caches keyed
+// on [Face.Generation] are also invalidated when these settings change.
+// For instance, the harfbuzz.Font setters of the synthetic slant and boldness,
+// tracking, ppem, fractional scale, glyph metrics functions and optical sizing call it;
+// the changes of its exported fields (Ptem, XScale and YScale) are not tracked.
+func (f *Face) BumpGeneration() { f.generation = lastGeneration.Add(1) }
+
+// invalidate resets the internal caches and updates the generation
+func (f *Face) invalidate() {
+	f.extentsCache.reset()
+	if f.advanceCache != nil {
+		f.advanceCache.reset()
+	}
+	f.BumpGeneration()
 }
 
 // Ppem returns the horizontal and vertical pixels-per-em (ppem), used to select bitmap sizes.
@@ -508,8 +572,7 @@ func (f *Face) Ppem() (x, y uint16) { return f.xPpem, f.yPpem }
 // SetPpem applies horizontal and vertical pixels-per-em (ppem).
 func (f *Face) SetPpem(x, y uint16) {
 	f.xPpem, f.yPpem = x, y
-	// invalid the cache
-	f.extentsCache.reset()
+	f.invalidate()
 }
 
 // Coords return a read-only slice of the current variable coordinates, expressed in normalized units.
@@ -520,6 +583,5 @@ func (f *Face) Coords() []tables.Coord { return f.coords }
 // Use [NormalizeVariations] to convert from design (user) space units.
 func (f *Face) SetCoords(coords []tables.Coord) {
 	f.coords = coords
-	// invalid the cache
-	f.extentsCache.reset()
+	f.invalidate()
 }
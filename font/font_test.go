@@ -4,11 +4,17 @@ package font
 
 import (
 	"bytes"
+	"image/color"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	ot "github.com/boxesandglue/typesetting/font/opentype"
 	"github.com/boxesandglue/typesetting/font/opentype/tables"
 	tu "github.com/boxesandglue/typesetting/testutils"
+	hd "github.com/go-text/typesetting-utils/harfbuzz"
 	td "github.com/go-text/typesetting-utils/opentype"
 )
 
@@ -134,3 +140,436 @@ func TestCapHeight(t *testing.T) {
 	tu.Assert(t, face.LineMetric(CapHeight) == 730)
 	tu.Assert(t, face.LineMetric(XHeight) == 520)
 }
+
+func TestMetric(t *testing.T) {
+	font := loadFont(t, "common/SourceSans-VF.ttf")
+	face := NewFace(font)
+
+	// reference values read from the OS/2 and post tables, since the
+	// MVAR deltas are not applied at the default instance
+	for _, test := range []struct {
+		tag      Tag
+		expected float32
+	}{
+		{MetricStrikeoutOffset, 286},
+		{MetricXHeight, 478},
+		{MetricUnderlineOffset, -50},
+		{MetricHorizontalClippingAscent, 984},
+		{MetricHorizontalClippingDescent, 273},
+		{MetricHorizontalCaretRise, 1},
+		{MetricSuperscriptYOffset, 350},
+	} {
+		got, ok := face.Metric(test.tag)
+		tu.Assert(t, ok)
+		tu.AssertC(t, got == test.expected, test.tag.String())
+	}
+	extents, _ := face.FontHExtents()
+	ascender, _ := face.Metric(MetricHorizontalAscender)
+	tu.Assert(t, ascender == extents.Ascender)
+	_, ok := face.Metric(MetricVerticalCaretRise) // no vhea table
+	tu.Assert(t, !ok)
+	_, ok = face.Metric(ot.MustNewTag("xxxx"))
+	tu.Assert(t, !ok)
+
+	// MVAR deltas : the default instance is the minimum (wght=200),
+	// and the MVAR table has one region, peaking at wght=900, with
+	// deltas of 13 for 'stro' and 22 for 'xhgt'
+	face.SetVariations([]Variation{{Tag: font.fvar[0].Tag, Value: font.fvar[0].Minimum}})
+	got, _ := face.Metric(MetricStrikeoutOffset)
+	tu.Assert(t, got == 286)
+	got, _ = face.Metric(MetricXHeight)
+	tu.Assert(t, got == 478)
+	face.SetVariations([]Variation{{Tag: font.fvar[0].Tag, Value: 900}})
+	got, _ = face.Metric(MetricStrikeoutOffset)
+	tu.Assert(t, got == 286+13)
+	got, _ = face.Metric(MetricXHeight)
+	tu.Assert(t, got == 478+22)
+}
+
+func TestScriptMetrics(t *testing.T) {
+	face := NewFace(loadFont(t, "common/DejaVuSans.ttf"))
+	tu.Assert(t, face.SuperscriptMetrics() == ScriptMetrics{XSize: 1331, YSize: 1433, YOffset: 983})
+	tu.Assert(t, face.SubscriptMetrics() == ScriptMetrics{XSize: 1331, YSize: 1433, YOffset: -286})
+
+	face = NewFace(loadFont(t, "common/SourceSans-VF.ttf"))
+	tu.Assert(t, face.SuperscriptMetrics() == ScriptMetrics{XSize: 650, YSize: 600, YOffset: 350})
+	tu.Assert(t, face.SubscriptMetrics() == ScriptMetrics{XSize: 650, YSize: 600, YOffset: -75})
+}
+
+func TestFontHExtentsFor(t *testing.T) {
+	for _, test := range []struct {
+		filename string
+		expected [4]FontExtents // auto, typo, win, hhea
+	}{
+		{"common/Raleway-v4020-Regular.otf", [4]FontExtents{ // USE_TYPO_METRICS is set
+			{940, -234, 0}, {940, -234, 0}, {1154, -234, 0}, {940, -234, 0},
+		}},
+		{"common/DejaVuSans.ttf", [4]FontExtents{
+			{1901, -483, 0}, {1556, -492, 410}, {1901, -483, 0}, {1901, -483, 0},
+		}},
+		{"common/Mada-VF.ttf", [4]FontExtents{ // win line gap
+			{900, -300, 100}, {900, -300, 100}, {796, -316, 188}, {900, -300, 100},
+		}},
+	} {
+		face := NewFace(loadFont(t, test.filename))
+		for i, policy := range []ExtentsPolicy{ExtentsAuto, ExtentsTypo, ExtentsWin, ExtentsHhea} {
+			got, ok := face.FontHExtentsFor(policy)
+			tu.Assert(t, ok)
+			tu.AssertC(t, got == test.expected[i], test.filename+" "+policy.String())
+		}
+	}
+
+	_, ok := NewFace(loadFont(t, "common/DejaVuSans.ttf")).FontHExtentsFor(ExtentsPolicy(10))
+	tu.Assert(t, !ok)
+}
+
+func TestWOFF2Glyphs(t *testing.T) {
+	load := func(filename string) *Face {
+		file, err := os.Open(filename)
+		tu.AssertNoErr(t, err)
+		defer file.Close()
+		face, err := ParseTTF(file)
+		tu.AssertNoErr(t, err)
+		return face
+	}
+	ref, face := load("testdata/FontAwesome.ttf"), load("testdata/FontAwesome.woff2")
+
+	tu.Assert(t, len(ref.glyf) == len(face.glyf))
+	for gid := GID(0); int(gid) < len(ref.glyf); gid++ {
+		tu.Assert(t, reflect.DeepEqual(ref.GlyphData(gid), face.GlyphData(gid)))
+		tu.Assert(t, ref.HorizontalAdvance(gid) == face.HorizontalAdvance(gid))
+		exp, _ := ref.GlyphExtents(gid)
+		got, _ := face.GlyphExtents(gid)
+		tu.Assert(t, exp == got)
+	}
+}
+
+func TestGlyphToRune(t *testing.T) {
+	ld := readFontFile(t, "common/Raleway-v4020-Regular.otf")
+	font, err := NewFont(ld)
+	tu.AssertNoErr(t, err)
+	face := NewFace(font)
+
+	gidA, _ := face.NominalGlyph('a')
+	tu.Assert(t, string(face.GlyphToRune(gidA)) == "a")
+	tu.Assert(t, face.GlyphToRune(0) == nil)
+	tu.Assert(t, face.GlyphToRune(GID(face.nGlyphs)) == nil)
+
+	fromCmap := map[GID]bool{}
+	for iter := face.Cmap.Iter(); iter.Next(); {
+		_, gid := iter.Char()
+		fromCmap[gid] = true
+	}
+
+	// glyphs only reachable through GSUB
+	var hasLigature, hasSubstitute bool
+	for gid := GID(0); int(gid) < face.nGlyphs; gid++ {
+		text := string(face.GlyphToRune(gid))
+		if text == "ffi" {
+			hasLigature = true
+		} else if text == "a" && gid != gidA && !fromCmap[gid] {
+			hasSubstitute = true
+		}
+	}
+	tu.Assert(t, hasLigature)
+	tu.Assert(t, hasSubstitute)
+
+	// the mapping is shared by the faces of the font
+	other := NewFace(font)
+	text := other.GlyphToRune(gidA)
+	tu.Assert(t, &text[0] == &face.GlyphToRune(gidA)[0])
+}
+
+func TestFaceGeneration(t *testing.T) {
+	font := loadFont(t, "common/Commissioner-VF.ttf")
+	face1, face2 := NewFace(font), NewFace(font)
+	tu.Assert(t, face1.Generation() != face2.Generation())
+
+	g := face1.Generation()
+	face1.SetVariations([]Variation{{Tag: ot.MustNewTag("wght"), Value: 700}})
+	tu.Assert(t, face1.Generation() > g)
+	g = face1.Generation()
+	face1.SetPpem(12, 12)
+	tu.Assert(t, face1.Generation() > g)
+	g = face1.Generation()
+	face1.SetCoords(nil)
+	tu.Assert(t, face1.Generation() > g)
+}
+
+func TestFaceShaperData(t *testing.T) {
+	type key struct{}
+	face := NewFace(loadFont(t, "common/Commissioner-VF.ttf"))
+
+	var (
+		calls int32
+		wg    sync.WaitGroup
+		got   [8]any
+	)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i] = face.ShaperData(key{}, func() any {
+				atomic.AddInt32(&calls, 1)
+				return new(int)
+			})
+		}(i)
+	}
+	wg.Wait()
+	tu.Assert(t, calls == 1)
+	for _, v := range got {
+		tu.Assert(t, v == got[0])
+	}
+
+	// the data is kept when the settings change
+	face.SetPpem(12, 12)
+	tu.Assert(t, face.ShaperData(key{}, func() any { return nil }) == got[0])
+}
+
+func TestBaseline(t *testing.T) {
+	ld := readFontFile(t, "common/NotoSansCJKjp-VF.otf")
+	font, err := NewFont(ld)
+	tu.AssertNoErr(t, err)
+	face := NewFace(font)
+
+	latn, hani := ot.MustNewTag("latn"), ot.MustNewTag("hani")
+	for _, test := range []struct {
+		baseline, script Tag
+		isVertical       bool
+		expected         float32
+	}{
+		{BaselineRoman, latn, false, 0},
+		{BaselineIdeoEmBoxBottom, hani, false, -120},
+		{BaselineIdeoFaceBottom, hani, false, -67},
+		{BaselineIdeoFaceTop, hani, false, 827},
+		{BaselineRoman, hani, true, 120},
+		{BaselineIdeoEmBoxBottom, ot.MustNewTag("arab"), false, -120}, // default script
+	} {
+		got, ok := face.Baseline(test.baseline, test.script, test.isVertical)
+		tu.Assert(t, ok)
+		tu.Assert(t, got == test.expected)
+	}
+
+	_, ok := face.Baseline(BaselineHanging, latn, false)
+	tu.Assert(t, !ok)
+
+	// variation deltas
+	face.SetCoords([]VarCoord{1 << 14})
+	got, _ := face.Baseline(BaselineIdeoFaceBottom, hani, false)
+	tu.Assert(t, got == -67-27)
+	got, _ = face.Baseline(BaselineIdeoFaceTop, hani, false)
+	tu.Assert(t, got == 827+27)
+
+	// no BASE table
+	ld = readFontFile(t, "common/Raleway-v4020-Regular.otf")
+	font, err = NewFont(ld)
+	tu.AssertNoErr(t, err)
+	_, ok = NewFace(font).Baseline(BaselineRoman, latn, false)
+	tu.Assert(t, !ok)
+}
+
+func TestLineExtents(t *testing.T) {
+	ld := readFontFile(t, "common/NotoSansCJKjp-VF.otf")
+	font, err := NewFont(ld)
+	tu.AssertNoErr(t, err)
+	face := NewFace(font)
+
+	hani, jan := ot.MustNewTag("hani"), ot.MustNewTag("JAN ")
+	_, _, ok := face.LineExtents(hani, 0, false)
+	tu.Assert(t, !ok) // the font has no MinMax tables
+
+	// use the (variable) ideographic face bottom as minimum extent
+	scripts := &font.base.HorizAxis.BaseScriptList
+	script := findBaseScript(scripts, hani)
+	script.DefaultMinMax = tables.MinMax{
+		MinCoord: script.BaseValues.BaseCoords[0],
+		MaxCoord: tables.BaseCoordFormat1{Coordinate: 880},
+	}
+	script.BaseLangSysRecords = []tables.TagOffsetRecord{{Tag: jan}}
+	script.BaseLangSys = []tables.MinMax{{MaxCoord: tables.BaseCoordFormat1{Coordinate: 900}}}
+
+	minExtent, maxExtent, ok := face.LineExtents(hani, 0, false)
+	tu.Assert(t, ok && minExtent == -67 && maxExtent == 880)
+	minExtent, maxExtent, ok = face.LineExtents(hani, jan, false)
+	tu.Assert(t, ok && minExtent == -67 && maxExtent == 900)
+	_, _, ok = face.LineExtents(hani, 0, true)
+	tu.Assert(t, !ok)
+
+	face.SetCoords([]VarCoord{1 << 14})
+	minExtent, _, _ = face.LineExtents(hani, jan, false)
+	tu.Assert(t, minExtent == -67-27)
+}
+
+func TestBaselineWithFallback(t *testing.T) {
+	latn := ot.MustNewTag("latn")
+
+	// no BASE table
+	ld := readFontFile(t, "common/Raleway-v4020-Regular.otf")
+	font, err := NewFont(ld)
+	tu.AssertNoErr(t, err)
+	face := NewFace(font)
+
+	tu.Assert(t, face.BaselineWithFallback(BaselineRoman, latn, false) == 0)
+	tu.Assert(t, face.BaselineWithFallback(BaselineHanging, latn, false) == 600)
+	// middle of the minus sign
+	minus, _ := face.NominalGlyph(0x2212)
+	extents, _ := face.GlyphExtents(minus)
+	tu.Assert(t, face.BaselineWithFallback(BaselineMath, latn, false) == extents.YBearing+extents.Height/2)
+	tu.Assert(t, face.BaselineWithFallback(BaselineMath, latn, true) == face.LineMetric(XHeight)/2)
+	// the em box is centered between the ascender (940) and the descender (-234)
+	tu.Assert(t, face.BaselineWithFallback(BaselineIdeoEmBoxBottom, latn, false) == -147)
+	tu.Assert(t, face.BaselineWithFallback(BaselineIdeoEmBoxTop, latn, false) == 853)
+	tu.Assert(t, face.BaselineWithFallback(BaselineIdeoFaceBottom, latn, false) == -47)
+	tu.Assert(t, face.BaselineWithFallback(BaselineIdeoFaceTop, latn, false) == 753)
+
+	// BASE table with some baselines missing
+	ld = readFontFile(t, "common/NotoSansCJKjp-VF.otf")
+	font, err = NewFont(ld)
+	tu.AssertNoErr(t, err)
+	face = NewFace(font)
+
+	tu.Assert(t, face.BaselineWithFallback(BaselineIdeoFaceBottom, latn, false) == -67)
+	tu.Assert(t, face.BaselineWithFallback(BaselineIdeoEmBoxTop, latn, false) == -120+1000)
+}
+
+func TestColorGlyphs(t *testing.T) {
+	file, err := hd.Files.ReadFile("harfbuzz_reference/in-house/fonts/53374c7ca3657be37efde7ed02ae34229a56ae1f.ttf")
+	tu.AssertNoErr(t, err)
+	ld, err := ot.NewLoader(bytes.NewReader(file))
+	tu.AssertNoErr(t, err)
+	font, err := NewFont(ld)
+	tu.AssertNoErr(t, err)
+
+	tu.Assert(t, reflect.DeepEqual(font.ColorGlyphLayers(8), []ColorLayer{{9, 0}, {10, 7}, {11, 14}}))
+	tu.Assert(t, font.ColorGlyphLayers(9) == nil)
+
+	palettes := font.ColorPalettes()
+	tu.Assert(t, len(palettes) == 2)
+	for _, palette := range palettes {
+		tu.Assert(t, len(palette.Colors) == 69)
+	}
+	tu.Assert(t, palettes[0].Colors[7] == color.NRGBA{R: 255, A: 255})
+	tu.Assert(t, palettes[1].Colors[7] == color.NRGBA{R: 255, G: 240, A: 255})
+	tu.Assert(t, font.HasColorGlyphs())
+
+	// no color tables
+	font = loadFont(t, "common/Raleway-v4020-Regular.otf")
+	tu.Assert(t, font.ColorGlyphLayers(8) == nil)
+	tu.Assert(t, font.ColorPalettes() == nil)
+	tu.Assert(t, !font.HasColorGlyphs())
+
+	// bitmap fonts
+	tu.Assert(t, loadFont(t, "bitmap/NotoColorEmoji.ttf").HasColorGlyphs())
+	tu.Assert(t, !loadFont(t, "bitmap/IBM3161-bitmap.otb").HasColorGlyphs())
+	tu.Assert(t, loadFont(t, "toys/Sbix1.ttf").HasColorGlyphs())
+}
+
+func TestLazyTables(t *testing.T) {
+	data, err := hd.Files.ReadFile("harfbuzz_reference/in-house/fonts/53374c7ca3657be37efde7ed02ae34229a56ae1f.ttf")
+	tu.AssertNoErr(t, err)
+	filename := t.TempDir() + "/color.ttf"
+	tu.AssertNoErr(t, os.WriteFile(filename, data, 0o644))
+
+	// the lazy tables may be used after the file is closed
+	file, err := os.Open(filename)
+	tu.AssertNoErr(t, err)
+	ld, err := ot.NewLoader(file)
+	tu.AssertNoErr(t, err)
+	font, err := NewFont(ld)
+	tu.AssertNoErr(t, err)
+	tu.AssertNoErr(t, file.Close())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tu.Assert(t, font.HasColorGlyphs())
+			tu.Assert(t, len(font.ColorGlyphLayers(8)) == 3)
+			tu.Assert(t, len(font.ColorPalettes()) == 2)
+		}()
+	}
+	wg.Wait()
+
+	// fonts not created with NewFont
+	var empty Font
+	tu.Assert(t, !empty.HasColorGlyphs())
+	tu.Assert(t, empty.ColorPalettes() == nil && empty.JustificationPriorities(0, 0) == nil)
+}
+
+func TestCollection(t *testing.T) {
+	for _, test := range []struct {
+		file     string
+		families []string
+	}{
+		{"collections/msgothic.ttc", []string{"MS Gothic", "MS PGothic", "MS UI Gothic"}},
+		{"collections/NotoSansCJK-Bold.ttc", []string{"Noto Sans CJK JP", "Noto Sans CJK KR", "Noto Sans CJK SC", "Noto Sans CJK TC", "Noto Sans CJK HK", "Noto Sans Mono CJK JP", "Noto Sans Mono CJK KR", "Noto Sans Mono CJK SC", "Noto Sans Mono CJK TC", "Noto Sans Mono CJK HK"}},
+	} {
+		f, err := td.Files.ReadFile(test.file)
+		tu.AssertNoErr(t, err)
+
+		collection, err := NewCollection(bytes.NewReader(f))
+		tu.AssertNoErr(t, err)
+		tu.Assert(t, collection.Len() == len(test.families))
+		for i, family := range test.families {
+			tu.AssertC(t, collection.Describe(i).Family == family, collection.Describe(i).Family)
+		}
+
+		// concurrent loading returns the same fonts
+		collection, err = NewCollection(bytes.NewReader(f))
+		tu.AssertNoErr(t, err)
+		var wg sync.WaitGroup
+		loaded := make([][]*Font, 4)
+		for j := range loaded {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				for i := 0; i < collection.Len(); i++ {
+					ft, _ := collection.Font(i)
+					collection.Describe(i)
+					loaded[j] = append(loaded[j], ft)
+				}
+			}(j)
+		}
+		wg.Wait()
+		for _, fonts := range loaded[1:] {
+			for i, ft := range fonts {
+				tu.Assert(t, ft != nil && ft == loaded[0][i])
+			}
+		}
+
+		lds, err := ot.NewLoaders(bytes.NewReader(f))
+		tu.AssertNoErr(t, err)
+		first, err := collection.Font(0)
+		tu.AssertNoErr(t, err)
+		for i, ld := range lds {
+			ft, err := collection.Font(i)
+			tu.AssertNoErr(t, err)
+			again, _ := collection.Font(i)
+			tu.Assert(t, again == ft) // loaded once
+
+			// the shared tables are not duplicated
+			if id, _ := ld.TableID(ot.MustNewTag("glyf")); id == mustTableID(lds[0], "glyf") && ft.glyf != nil {
+				tu.Assert(t, &ft.glyf[0] == &first.glyf[0])
+			}
+			if id, _ := ld.TableID(ot.MustNewTag("CFF ")); id == mustTableID(lds[0], "CFF ") && ft.cff != nil {
+				tu.Assert(t, ft.cff == first.cff)
+			}
+
+			// the content is the same as when loading the font alone
+			alone, err := NewFont(ld)
+			tu.AssertNoErr(t, err)
+			tu.Assert(t, reflect.DeepEqual(alone.Cmap, ft.Cmap))
+			tu.Assert(t, reflect.DeepEqual(alone.GSUB, ft.GSUB))
+			tu.Assert(t, reflect.DeepEqual(alone.GPOS, ft.GPOS))
+			for _, gid := range []GID{1, 10, 100} {
+				tu.Assert(t, reflect.DeepEqual(NewFace(alone).GlyphData(gid), NewFace(ft).GlyphData(gid)))
+			}
+		}
+	}
+}
+
+func mustTableID(ld *ot.Loader, tag string) ot.TableID {
+	id, _ := ld.TableID(ot.MustNewTag(tag))
+	return id
+}
@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package font
+
+import (
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
+)
+
+// maxClosurePasses bounds the number of passes over the GSUB lookups,
+// required to follow chains of substitutions.
+const maxClosurePasses = 8
+
+// reverseCmap stores, for each glyph, the runes it represents
+type reverseCmap [][]rune
+
+// GlyphToRune returns the text represented by [gid], which is
+// usually one rune, but may be several runes for ligatures.
+// It returns nil if no text is known for [gid].
+//
+// The mapping is built on the first call, from the 'cmap' table,
+// completed with the glyphs reachable through the GSUB substitutions
+// (single, multiple, alternate and ligature substitutions).
+// It is shared by all the faces of the same [Font].
+// Since a glyph may be produced from different texts, this is an heuristic,
+// intended for instance to build the ToUnicode CMaps of PDF files:
+// the original text of a shaped run, when available, is more accurate.
+//
+// The returned slice must not be modified.
+func (f *Face) GlyphToRune(gid GID) []rune {
+	rc := f.reverseCmapTable()
+	if int(gid) >= len(rc) {
+		return nil
+	}
+	return rc[gid]
+}
+
+func isPrivateUse(r rune) bool {
+	return (0xE000 <= r && r <= 0xF8FF) || 0xF0000 <= r
+}
+
+func newReverseCmap(font *Font) reverseCmap {
+	out := make(reverseCmap, font.nGlyphs)
+
+	// when several runes are mapped to the same glyph,
+	// use the smallest one, avoiding Private Use Areas
+	iter := font.Cmap.Iter()
+	for iter.Next() {
+		r, gid := iter.Char()
+		if int(gid) >= len(out) || gid == 0 {
+			continue
+		}
+		if current := out[gid]; current != nil {
+			if prev := current[0]; isPrivateUse(r) && !isPrivateUse(prev) || r > prev && isPrivateUse(r) == isPrivateUse(prev) {
+				continue
+			}
+		}
+		out[gid] = []rune{r}
+	}
+
+	for pass := 0; pass < maxClosurePasses; pass++ {
+		if !out.closeGSUB(font.GSUB) {
+			break
+		}
+	}
+
+	return out
+}
+
+// set stores [runes] for [gid], if [gid] is valid and has no
+// mapping yet, returning true if the mapping has been updated
+func (rc reverseCmap) set(gid tables.GlyphID, runes []rune) bool {
+	if len(runes) == 0 || int(gid) >= len(rc) || rc[gid] != nil {
+		return false
+	}
+	rc[gid] = runes
+	return true
+}
+
+// closeGSUB performs one pass over the GSUB lookups, returning true
+// if new glyphs have been mapped.
+func (rc reverseCmap) closeGSUB(gsub GSUB) (updated bool) {
+	for _, lookup := range gsub.Lookups {
+		for _, subtable := range lookup.Subtables {
+			switch subtable := subtable.(type) {
+			case tables.SingleSubs:
+				switch data := subtable.Data.(type) {
+				case tables.SingleSubstData1:
+					forEachCovered(data.Coverage, func(gid tables.GlyphID, _ int) {
+						updated = rc.set(tables.GlyphID(int(gid)+int(data.DeltaGlyphID)), rc.get(gid)) || updated
+					})
+				case tables.SingleSubstData2:
+					forEachCovered(data.Coverage, func(gid tables.GlyphID, index int) {
+						if index < len(data.SubstituteGlyphIDs) {
+							updated = rc.set(data.SubstituteGlyphIDs[index], rc.get(gid)) || updated
+						}
+					})
+				}
+			case tables.MultipleSubs:
+				// attribute the text to the first glyph of the sequence
+				forEachCovered(subtable.Coverage, func(gid tables.GlyphID, index int) {
+					if index < len(subtable.Sequences) && len(subtable.Sequences[index].SubstituteGlyphIDs) != 0 {
+						updated = rc.set(subtable.Sequences[index].SubstituteGlyphIDs[0], rc.get(gid)) || updated
+					}
+				})
+			case tables.AlternateSubs:
+				forEachCovered(subtable.Coverage, func(gid tables.GlyphID, index int) {
+					if index >= len(subtable.AlternateSets) {
+						return
+					}
+					runes := rc.get(gid)
+					for _, alternate := range subtable.AlternateSets[index].AlternateGlyphIDs {
+						updated = rc.set(alternate, runes) || updated
+					}
+				})
+			case tables.LigatureSubs:
+				forEachCovered(subtable.Coverage, func(gid tables.GlyphID, index int) {
+					if index >= len(subtable.LigatureSets) {
+						return
+					}
+					for _, lig := range subtable.LigatureSets[index].Ligatures {
+						updated = rc.set(lig.LigatureGlyph, rc.ligatureText(gid, lig.ComponentGlyphIDs)) || updated
+					}
+				})
+			}
+		}
+	}
+	return updated
+}
+
+func (rc reverseCmap) get(gid tables.GlyphID) []rune {
+	if int(gid) >= len(rc) {
+		return nil
+	}
+	return rc[gid]
+}
+
+// ligatureText returns the concatenated text of the components,
+// or nil if one of them is unknown
+func (rc reverseCmap) ligatureText(first tables.GlyphID, components []tables.GlyphID) []rune {
+	out := append([]rune(nil), rc.get(first)...)
+	if len(out) == 0 {
+		return nil
+	}
+	for _, component := range components {
+		runes := rc.get(component)
+		if len(runes) == 0 {
+			return nil
+		}
+		out = append(out, runes...)
+	}
+	return out
+}
+
+// forEachCovered calls [fn] for each glyph in [cov], with its coverage index
+func forEachCovered(cov tables.Coverage, fn func(gid tables.GlyphID, index int)) {
+	for _, r := range cov.RangeRecords() {
+		for gid := int(r.StartGlyphID); gid <= int(r.EndGlyphID); gid++ {
+			fn(tables.GlyphID(gid), int(r.StartCoverageIndex)+gid-int(r.StartGlyphID))
+		}
+	}
+}
@@ -204,6 +204,35 @@ func (f *Face) getGlyfPoints(gid tables.GlyphID, computeExtents bool) (ext Glyph
 	return ext, ph
 }
 
+// TrueTypePoint is a point of a TrueType outline, as returned by [Face.TrueTypePoints].
+type TrueTypePoint struct {
+	X, Y       float32
+	OnCurve    bool
+	EndContour bool // the point is the last of its contour
+}
+
+// TrueTypePoints returns the points of the outline of [gid], as defined in the 'glyf' table,
+// with the variations of the face applied, and the components of composite glyphs resolved.
+// It returns nil if the font has no 'glyf' table or if the glyph has no outline.
+//
+// Contrary to [Face.GlyphData], the points are not converted to segments, so that the
+// outline may be written back, for instance when building a static instance of a variable font.
+func (f *Face) TrueTypePoints(gid GID) []TrueTypePoint {
+	if int(gid) >= len(f.glyf) {
+		return nil
+	}
+	var allPoints []contourPoint
+	f.getPointsForGlyph(gID(gid), 0, &allPoints)
+	if len(allPoints) <= phantomCount {
+		return nil
+	}
+	out := make([]TrueTypePoint, len(allPoints)-phantomCount)
+	for i, p := range allPoints[:len(out)] {
+		out[i] = TrueTypePoint{X: p.X, Y: p.Y, OnCurve: p.isOnCurve, EndContour: p.isEndPoint}
+	}
+	return out
+}
+
 func min16(a, b int16) int16 {
 	if a < b {
 		return a
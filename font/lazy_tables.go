@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package font
+
+import (
+	"sync"
+
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
+)
+
+// bitmapTags are the location and data tables of the bitmap formats,
+// by order of preference.
+var bitmapTags = [...][2]ot.Tag{
+	{ot.MustNewTag("CBLC"), ot.MustNewTag("CBDT")},
+	{ot.MustNewTag("EBLC"), ot.MustNewTag("EBDT")},
+	{ot.MustNewTag("bloc"), ot.MustNewTag("bdat")},
+}
+
+// lazyTables stores the tables rarely needed by text layout (justification,
+// color and bitmap glyphs), which are only parsed on first use, so that loading
+// a font, for instance to index it, is faster.
+//
+// The raw tables are read when loading the font, so that the font file may be
+// closed afterwards, and parsing is guarded by a [sync.Once] per group of tables,
+// so that [Font] is still safe for concurrent use.
+type lazyTables struct {
+	nGlyphs int
+
+	jstfOnce sync.Once
+	jstfRaw  []byte
+	jstf     tables.JSTF
+
+	colorOnce        sync.Once
+	colrRaw, cpalRaw []byte
+	colr             tables.COLR
+	cpal             tables.CPAL
+
+	bitmapOnce sync.Once
+	bitmapRaw  [len(bitmapTags)][2][]byte
+	sbixRaw    []byte
+	bitmap     bitmap
+	sbix       sbix
+
+	reverseCmapOnce sync.Once
+	reverseCmap     reverseCmap // see [Face.GlyphToRune]
+}
+
+// newLazyTables reads the raw content of the lazy tables.
+func newLazyTables(ld *ot.Loader, nGlyphs int) *lazyTables {
+	out := &lazyTables{nGlyphs: nGlyphs}
+	out.jstfRaw, _ = ld.RawTable(ot.MustNewTag("JSTF"))
+	out.colrRaw, _ = ld.RawTable(ot.MustNewTag("COLR"))
+	out.cpalRaw, _ = ld.RawTable(ot.MustNewTag("CPAL"))
+	for i, tags := range bitmapTags {
+		if ld.HasTable(tags[0]) && ld.HasTable(tags[1]) {
+			out.bitmapRaw[i][0], _ = ld.RawTable(tags[0])
+			out.bitmapRaw[i][1], _ = ld.RawTable(tags[1])
+		}
+	}
+	out.sbixRaw, _ = ld.RawTable(ot.MustNewTag("sbix"))
+	return out
+}
+
+func (lt *lazyTables) loadJSTF() {
+	lt.jstf, _, _ = tables.ParseJSTF(lt.jstfRaw)
+	lt.jstfRaw = nil
+}
+
+func (lt *lazyTables) loadColor() {
+	lt.colr, _, _ = tables.ParseCOLR(lt.colrRaw)
+	lt.cpal, _, _ = tables.ParseCPAL(lt.cpalRaw)
+	lt.colrRaw, lt.cpalRaw = nil, nil
+}
+
+func (lt *lazyTables) loadBitmaps() {
+	for _, raws := range lt.bitmapRaw {
+		if raws[0] == nil {
+			continue
+		}
+		if bm, err := parseBitmap(raws[0], raws[1]); err == nil {
+			lt.bitmap = bm
+			break
+		}
+	}
+	sbix, _, _ := tables.ParseSbix(lt.sbixRaw, lt.nGlyphs)
+	lt.sbix = newSbix(sbix)
+	lt.bitmapRaw, lt.sbixRaw = [len(bitmapTags)][2][]byte{}, nil
+}
+
+// JSTF returns the 'JSTF' table, parsed on first use.
+// See also [Font.JustificationPriorities] and [Font.ExtenderGlyphs].
+func (f *Font) JSTF() *tables.JSTF {
+	if f.lazy == nil { // font not created by NewFont
+		return &tables.JSTF{}
+	}
+	f.lazy.jstfOnce.Do(f.lazy.loadJSTF)
+	return &f.lazy.jstf
+}
+
+func (f *Font) colrTable() *tables.COLR {
+	if f.lazy == nil {
+		return &tables.COLR{}
+	}
+	f.lazy.colorOnce.Do(f.lazy.loadColor)
+	return &f.lazy.colr
+}
+
+func (f *Font) cpalTable() *tables.CPAL {
+	if f.lazy == nil {
+		return &tables.CPAL{}
+	}
+	f.lazy.colorOnce.Do(f.lazy.loadColor)
+	return &f.lazy.cpal
+}
+
+func (f *Font) bitmapTable() bitmap {
+	if f.lazy == nil {
+		return nil
+	}
+	f.lazy.bitmapOnce.Do(f.lazy.loadBitmaps)
+	return f.lazy.bitmap
+}
+
+// reverseCmapTable returns the mapping used by [Face.GlyphToRune],
+// built on first use and shared by all the faces of the font.
+func (f *Font) reverseCmapTable() reverseCmap {
+	if f.lazy == nil {
+		return newReverseCmap(f)
+	}
+	f.lazy.reverseCmapOnce.Do(func() { f.lazy.reverseCmap = newReverseCmap(f) })
+	return f.lazy.reverseCmap
+}
+
+func (f *Font) sbixTable() sbix {
+	if f.lazy == nil {
+		return nil
+	}
+	f.lazy.bitmapOnce.Do(f.lazy.loadBitmaps)
+	return f.lazy.sbix
+}
@@ -296,6 +296,7 @@ func stringContainsConst(str string, constants []string) int {
 const (
 	nameFontFamily         tables.NameID = 1
 	nameFontSubfamily      tables.NameID = 2
+	namePostScript         tables.NameID = 6
 	namePreferredFamily    tables.NameID = 16 // or Typographic Family
 	namePreferredSubfamily tables.NameID = 17 // or Typographic Subfamily
 	nameWWSFamily          tables.NameID = 21 //
@@ -428,9 +429,26 @@ func (fd *Font) IsMonospace() bool {
 type Description struct {
 	Family string
 	Aspect Aspect
+
+	// PostScriptName is the PostScript name of the font (name ID 6),
+	// which uniquely identifies a face, or an empty string.
+	PostScriptName string
+	// Version is the font revision stored in the 'head' table,
+	// for instance 2.001, or zero if not available.
+	Version float32
+}
+
+// description returns the metadata of the font.
+func (fd *fontDescriptor) description() Description {
+	return Description{
+		Family:         fd.family(),
+		Aspect:         fd.aspect(),
+		PostScriptName: fd.names.Name(namePostScript),
+		Version:        fd.head.FontRevision(),
+	}
 }
 
-// Describe provides access to family and aspect.
+// Describe provides access to family, aspect, PostScript name and version.
 //
 // 'buffer' may be provided to reduce allocations.
 //
@@ -439,15 +457,15 @@ type Description struct {
 // if you already have loaded the font.
 func Describe(ld *ot.Loader, buffer []byte) (Description, []byte) {
 	desc, buffer := newFontDescriptor(ld, buffer)
-	return Description{desc.family(), desc.aspect()}, buffer
+	return desc.description(), buffer
 }
 
-// Describe provides access to family and aspect.
+// Describe provides access to family, aspect, PostScript name and version.
 //
 // See also the package level function [Describe],
 // which is more efficient if you only need the font
 // metadata.
 func (ft *Font) Describe() Description {
 	desc := fontDescriptor{ft.os2.os2Desc, ft.names, ft.head}
-	return Description{desc.family(), desc.aspect()}
+	return desc.description()
 }
@@ -3,6 +3,7 @@ package font
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"testing"
 
 	ot "github.com/boxesandglue/typesetting/font/opentype"
@@ -12,24 +13,32 @@ import (
 
 func TestMetadata(t *testing.T) {
 	tests := []struct {
-		fontPath string
-		aspect   Aspect
-		family   string
+		fontPath       string
+		aspect         Aspect
+		family         string
+		postScriptName string
+		version        float32
 	}{
 		{
 			"common/Roboto-BoldItalic.ttf",
 			Aspect{StyleItalic, WeightBold, StretchNormal},
 			"Roboto",
+			"Roboto-BoldItalic",
+			2.138,
 		},
 		{
 			"common/NotoSansArabic.ttf",
 			Aspect{StyleNormal, WeightNormal, StretchNormal},
 			"Noto Sans Arabic",
+			"NotoSansArabic-Regular",
+			2.004,
 		},
 		{
 			"common/DejaVuSans.ttf",
 			Aspect{StyleNormal, WeightNormal, StretchNormal},
 			"DejaVu Sans",
+			"DejaVuSans",
+			2.37,
 		},
 	}
 
@@ -43,6 +52,8 @@ func TestMetadata(t *testing.T) {
 		got, _ := Describe(ld, nil)
 		tu.AssertC(t, got.Aspect == test.aspect, fmt.Sprint(got.Aspect))
 		tu.AssertC(t, got.Family == test.family, got.Family)
+		tu.AssertC(t, got.PostScriptName == test.postScriptName, got.PostScriptName)
+		tu.AssertC(t, math.Abs(float64(got.Version-test.version)) < 0.001, fmt.Sprint(got.Version))
 
 		// check the two APIs are consistent
 		ft, err := NewFont(ld)
@@ -3,6 +3,7 @@
 package font
 
 import (
+	"fmt"
 	"math"
 
 	ot "github.com/boxesandglue/typesetting/font/opentype"
@@ -34,20 +35,44 @@ func (f *Font) GlyphName(glyph GID) string {
 // This value is only relevant for scalable fonts.
 func (f *Font) Upem() uint16 { return f.upem }
 
+// Metrics tags, as registered in the 'MVAR' table specification,
+// and used by [Face.Metric].
 var (
-	metricsTagHorizontalAscender  = ot.MustNewTag("hasc")
-	metricsTagHorizontalDescender = ot.MustNewTag("hdsc")
-	metricsTagHorizontalLineGap   = ot.MustNewTag("hlgp")
-	metricsTagVerticalAscender    = ot.MustNewTag("vasc")
-	metricsTagVerticalDescender   = ot.MustNewTag("vdsc")
-	metricsTagVerticalLineGap     = ot.MustNewTag("vlgp")
+	MetricHorizontalAscender        = ot.MustNewTag("hasc")
+	MetricHorizontalDescender       = ot.MustNewTag("hdsc")
+	MetricHorizontalLineGap         = ot.MustNewTag("hlgp")
+	MetricHorizontalClippingAscent  = ot.MustNewTag("hcla")
+	MetricHorizontalClippingDescent = ot.MustNewTag("hcld")
+	MetricVerticalAscender          = ot.MustNewTag("vasc")
+	MetricVerticalDescender         = ot.MustNewTag("vdsc")
+	MetricVerticalLineGap           = ot.MustNewTag("vlgp")
+	MetricHorizontalCaretRise       = ot.MustNewTag("hcrs")
+	MetricHorizontalCaretRun        = ot.MustNewTag("hcrn")
+	MetricHorizontalCaretOffset     = ot.MustNewTag("hcof")
+	MetricVerticalCaretRise         = ot.MustNewTag("vcrs")
+	MetricVerticalCaretRun          = ot.MustNewTag("vcrn")
+	MetricVerticalCaretOffset       = ot.MustNewTag("vcof")
+	MetricXHeight                   = ot.MustNewTag("xhgt")
+	MetricCapHeight                 = ot.MustNewTag("cpht")
+	MetricSubscriptXSize            = ot.MustNewTag("sbxs")
+	MetricSubscriptYSize            = ot.MustNewTag("sbys")
+	MetricSubscriptXOffset          = ot.MustNewTag("sbxo")
+	MetricSubscriptYOffset          = ot.MustNewTag("sbyo")
+	MetricSuperscriptXSize          = ot.MustNewTag("spxs")
+	MetricSuperscriptYSize          = ot.MustNewTag("spys")
+	MetricSuperscriptXOffset        = ot.MustNewTag("spxo")
+	MetricSuperscriptYOffset        = ot.MustNewTag("spyo")
+	MetricStrikeoutSize             = ot.MustNewTag("strs")
+	MetricStrikeoutOffset           = ot.MustNewTag("stro")
+	MetricUnderlineSize             = ot.MustNewTag("unds")
+	MetricUnderlineOffset           = ot.MustNewTag("undo")
 )
 
 func fixAscenderDescender(value float32, metricsTag Tag) float32 {
-	if metricsTag == metricsTagHorizontalAscender || metricsTag == metricsTagVerticalAscender {
+	if metricsTag == MetricHorizontalAscender || metricsTag == MetricVerticalAscender {
 		return float32(math.Abs(float64(value)))
 	}
-	if metricsTag == metricsTagHorizontalDescender || metricsTag == metricsTagVerticalDescender {
+	if metricsTag == MetricHorizontalDescender || metricsTag == MetricVerticalDescender {
 		return float32(-math.Abs(float64(value)))
 	}
 	return value
@@ -56,34 +81,34 @@ func fixAscenderDescender(value float32, metricsTag Tag) float32 {
 func (f *Font) getPositionCommon(metricTag Tag, varCoords []VarCoord) (float32, bool) {
 	deltaVar := f.mvar.getVar(metricTag, varCoords)
 	switch metricTag {
-	case metricsTagHorizontalAscender:
+	case MetricHorizontalAscender:
 		if f.os2.useTypoMetrics {
 			return fixAscenderDescender(float32(f.os2.sTypoAscender)+deltaVar, metricTag), true
 		} else if f.hhea != nil {
 			return fixAscenderDescender(float32(f.hhea.Ascender)+deltaVar, metricTag), true
 		}
 
-	case metricsTagHorizontalDescender:
+	case MetricHorizontalDescender:
 		if f.os2.useTypoMetrics {
 			return fixAscenderDescender(float32(f.os2.sTypoDescender)+deltaVar, metricTag), true
 		} else if f.hhea != nil {
 			return fixAscenderDescender(float32(f.hhea.Descender)+deltaVar, metricTag), true
 		}
-	case metricsTagHorizontalLineGap:
+	case MetricHorizontalLineGap:
 		if f.os2.useTypoMetrics {
 			return fixAscenderDescender(float32(f.os2.sTypoLineGap)+deltaVar, metricTag), true
 		} else if f.hhea != nil {
 			return fixAscenderDescender(float32(f.hhea.LineGap)+deltaVar, metricTag), true
 		}
-	case metricsTagVerticalAscender:
+	case MetricVerticalAscender:
 		if f.vhea != nil {
 			return fixAscenderDescender(float32(f.vhea.Ascender)+deltaVar, metricTag), true
 		}
-	case metricsTagVerticalDescender:
+	case MetricVerticalDescender:
 		if f.vhea != nil {
 			return fixAscenderDescender(float32(f.vhea.Descender)+deltaVar, metricTag), true
 		}
-	case metricsTagVerticalLineGap:
+	case MetricVerticalLineGap:
 		if f.vhea != nil {
 			return fixAscenderDescender(float32(f.vhea.LineGap)+deltaVar, metricTag), true
 		}
@@ -98,9 +123,9 @@ func (f *Face) FontHExtents() (FontExtents, bool) {
 		out           FontExtents
 		ok1, ok2, ok3 bool
 	)
-	out.Ascender, ok1 = f.Font.getPositionCommon(metricsTagHorizontalAscender, f.coords)
-	out.Descender, ok2 = f.Font.getPositionCommon(metricsTagHorizontalDescender, f.coords)
-	out.LineGap, ok3 = f.Font.getPositionCommon(metricsTagHorizontalLineGap, f.coords)
+	out.Ascender, ok1 = f.Font.getPositionCommon(MetricHorizontalAscender, f.coords)
+	out.Descender, ok2 = f.Font.getPositionCommon(MetricHorizontalDescender, f.coords)
+	out.LineGap, ok3 = f.Font.getPositionCommon(MetricHorizontalLineGap, f.coords)
 	return out, ok1 && ok2 && ok3
 }
 
@@ -110,26 +135,142 @@ func (f *Face) FontVExtents() (FontExtents, bool) {
 		out           FontExtents
 		ok1, ok2, ok3 bool
 	)
-	out.Ascender, ok1 = f.Font.getPositionCommon(metricsTagVerticalAscender, f.coords)
-	out.Descender, ok2 = f.Font.getPositionCommon(metricsTagVerticalDescender, f.coords)
-	out.LineGap, ok3 = f.Font.getPositionCommon(metricsTagVerticalLineGap, f.coords)
+	out.Ascender, ok1 = f.Font.getPositionCommon(MetricVerticalAscender, f.coords)
+	out.Descender, ok2 = f.Font.getPositionCommon(MetricVerticalDescender, f.coords)
+	out.LineGap, ok3 = f.Font.getPositionCommon(MetricVerticalLineGap, f.coords)
 	return out, ok1 && ok2 && ok3
 }
 
-var (
-	tagStrikeoutSize      = ot.MustNewTag("strs")
-	tagStrikeoutOffset    = ot.MustNewTag("stro")
-	tagUnderlineSize      = ot.MustNewTag("unds")
-	tagUnderlineOffset    = ot.MustNewTag("undo")
-	tagSuperscriptYSize   = ot.MustNewTag("spys")
-	tagSuperscriptXOffset = ot.MustNewTag("spxo")
-	tagSubscriptYSize     = ot.MustNewTag("sbys")
-	tagSubscriptYOffset   = ot.MustNewTag("sbyo")
-	tagSubscriptXOffset   = ot.MustNewTag("sbxo")
-	tagXHeight            = ot.MustNewTag("xhgt")
-	tagCapHeight          = ot.MustNewTag("cpht")
+// ExtentsPolicy selects the tables used to compute the horizontal font extents,
+// since applications follow different conventions. See [Face.FontHExtentsFor].
+type ExtentsPolicy uint8
+
+const (
+	// ExtentsAuto uses the 'OS/2' typographic metrics if the USE_TYPO_METRICS
+	// flag of fsSelection is set, and the 'hhea' metrics otherwise.
+	// This is the policy used by [Face.FontHExtents] and the shaper.
+	ExtentsAuto ExtentsPolicy = iota
+	// ExtentsTypo uses the sTypoAscender, sTypoDescender and sTypoLineGap
+	// fields of the 'OS/2' table, ignoring the USE_TYPO_METRICS flag.
+	ExtentsTypo
+	// ExtentsWin uses the usWinAscent and usWinDescent fields of the 'OS/2' table,
+	// which usually cover the bounding box of all the glyphs.
+	// As on Windows, the line gap is the part of the 'hhea' line gap
+	// not already included in these extents.
+	ExtentsWin
+	// ExtentsHhea uses the ascender, descender and line gap of the 'hhea' table.
+	ExtentsHhea
 )
 
+func (p ExtentsPolicy) String() string {
+	switch p {
+	case ExtentsAuto:
+		return "auto"
+	case ExtentsTypo:
+		return "typo"
+	case ExtentsWin:
+		return "win"
+	case ExtentsHhea:
+		return "hhea"
+	default:
+		return fmt.Sprintf("<extents policy %d>", uint8(p))
+	}
+}
+
+// FontHExtentsFor is the same as [Face.FontHExtents], but uses the given [policy]
+// to select the metrics. As for [Face.FontHExtents], the descender is negative.
+// False is returned if the tables required by the policy are missing.
+func (f *Face) FontHExtentsFor(policy ExtentsPolicy) (FontExtents, bool) {
+	switch policy {
+	case ExtentsAuto:
+		return f.FontHExtents()
+	case ExtentsTypo:
+		if !f.os2.isValid {
+			return FontExtents{}, false
+		}
+		return FontExtents{
+			Ascender:  fixAscenderDescender(f.os2.sTypoAscender+f.mvar.getVar(MetricHorizontalAscender, f.coords), MetricHorizontalAscender),
+			Descender: fixAscenderDescender(f.os2.sTypoDescender+f.mvar.getVar(MetricHorizontalDescender, f.coords), MetricHorizontalDescender),
+			LineGap:   f.os2.sTypoLineGap + f.mvar.getVar(MetricHorizontalLineGap, f.coords),
+		}, true
+	case ExtentsWin:
+		if !f.os2.isValid {
+			return FontExtents{}, false
+		}
+		out := FontExtents{
+			Ascender:  f.os2.usWinAscent + f.mvar.getVar(MetricHorizontalClippingAscent, f.coords),
+			Descender: -(f.os2.usWinDescent + f.mvar.getVar(MetricHorizontalClippingDescent, f.coords)),
+		}
+		if hhea, ok := f.FontHExtentsFor(ExtentsHhea); ok {
+			// the external leading, as defined by Windows GDI
+			gap := hhea.LineGap - ((out.Ascender - out.Descender) - (hhea.Ascender - hhea.Descender))
+			out.LineGap = float32(math.Max(0, float64(gap)))
+		}
+		return out, true
+	case ExtentsHhea:
+		if f.hhea == nil {
+			return FontExtents{}, false
+		}
+		return FontExtents{
+			Ascender:  fixAscenderDescender(float32(f.hhea.Ascender)+f.mvar.getVar(MetricHorizontalAscender, f.coords), MetricHorizontalAscender),
+			Descender: fixAscenderDescender(float32(f.hhea.Descender)+f.mvar.getVar(MetricHorizontalDescender, f.coords), MetricHorizontalDescender),
+			LineGap:   float32(f.hhea.LineGap) + f.mvar.getVar(MetricHorizontalLineGap, f.coords),
+		}, true
+	default:
+		return FontExtents{}, false
+	}
+}
+
+// ScriptMetrics describes how to synthesize superscripts or subscripts by
+// scaling and moving the regular glyphs, as recommended by the 'OS/2' table.
+// All the values are in font units. See [Face.SuperscriptMetrics] and [Face.SubscriptMetrics].
+type ScriptMetrics struct {
+	// XSize and YSize are the horizontal and vertical em sizes of the scripts.
+	XSize, YSize float32
+	// XOffset is the horizontal offset of the scripts, usually
+	// non zero for italic fonts.
+	XOffset float32
+	// YOffset is the vertical offset of the baseline of the scripts,
+	// positive upward, so that it is negative for subscripts.
+	YOffset float32
+}
+
+// SuperscriptMetrics returns the size and position of the synthetic superscripts,
+// read from the 'OS/2' table and adjusted by the 'MVAR' table.
+// If the font does not provide them, default values
+// (0.65 em wide, 0.6 em high, raised by 0.35 em) are returned.
+func (f *Face) SuperscriptMetrics() ScriptMetrics {
+	return f.scriptMetrics(MetricSuperscriptXSize, MetricSuperscriptYSize,
+		MetricSuperscriptXOffset, MetricSuperscriptYOffset, 0.35)
+}
+
+// SubscriptMetrics returns the size and position of the synthetic subscripts,
+// read from the 'OS/2' table and adjusted by the 'MVAR' table.
+// If the font does not provide them, default values
+// (0.65 em wide, 0.6 em high, lowered by 0.075 em) are returned.
+//
+// Note that the 'OS/2' table stores the subscript offset as a positive
+// value, whereas [ScriptMetrics.YOffset] is negative.
+func (f *Face) SubscriptMetrics() ScriptMetrics {
+	out := f.scriptMetrics(MetricSubscriptXSize, MetricSubscriptYSize,
+		MetricSubscriptXOffset, MetricSubscriptYOffset, 0.075)
+	out.YOffset = -out.YOffset
+	return out
+}
+
+func (f *Face) scriptMetrics(xSize, ySize, xOffset, yOffset Tag, defaultYOffset float32) ScriptMetrics {
+	var out ScriptMetrics
+	out.XSize, _ = f.Metric(xSize)
+	out.YSize, _ = f.Metric(ySize)
+	if out.XSize <= 0 || out.YSize <= 0 { // missing or invalid table: use defaults
+		upem := float32(f.upem)
+		return ScriptMetrics{XSize: 0.65 * upem, YSize: 0.6 * upem, YOffset: defaultYOffset * upem}
+	}
+	out.XOffset, _ = f.Metric(xOffset)
+	out.YOffset, _ = f.Metric(yOffset)
+	return out
+}
+
 // return the height from baseline (in font units)
 func (f *Face) runeHeight(r rune) float32 {
 	gid, ok := f.Font.NominalGlyph(r)
@@ -147,42 +288,127 @@ func (f *Face) runeHeight(r rune) float32 {
 func (f *Face) LineMetric(metric LineMetric) float32 {
 	switch metric {
 	case UnderlinePosition:
-		return f.post.underlinePosition + f.mvar.getVar(tagUnderlineOffset, f.coords)
+		return f.post.underlinePosition + f.mvar.getVar(MetricUnderlineOffset, f.coords)
 	case UnderlineThickness:
-		return f.post.underlineThickness + f.mvar.getVar(tagUnderlineSize, f.coords)
+		return f.post.underlineThickness + f.mvar.getVar(MetricUnderlineSize, f.coords)
 	case StrikethroughPosition:
-		return float32(f.os2.yStrikeoutPosition) + f.mvar.getVar(tagStrikeoutOffset, f.coords)
+		return float32(f.os2.yStrikeoutPosition) + f.mvar.getVar(MetricStrikeoutOffset, f.coords)
 	case StrikethroughThickness:
-		return float32(f.os2.yStrikeoutSize) + f.mvar.getVar(tagStrikeoutSize, f.coords)
+		return float32(f.os2.yStrikeoutSize) + f.mvar.getVar(MetricStrikeoutSize, f.coords)
 	case SuperscriptEmYSize:
-		return float32(f.os2.ySuperscriptYSize) + f.mvar.getVar(tagSuperscriptYSize, f.coords)
+		return float32(f.os2.ySuperscriptYSize) + f.mvar.getVar(MetricSuperscriptYSize, f.coords)
 	case SuperscriptEmXOffset:
-		return float32(f.os2.ySuperscriptXOffset) + f.mvar.getVar(tagSuperscriptXOffset, f.coords)
+		return float32(f.os2.ySuperscriptXOffset) + f.mvar.getVar(MetricSuperscriptXOffset, f.coords)
 	case SubscriptEmYSize:
-		return float32(f.os2.ySubscriptYSize) + f.mvar.getVar(tagSubscriptYSize, f.coords)
+		return float32(f.os2.ySubscriptYSize) + f.mvar.getVar(MetricSubscriptYSize, f.coords)
 	case SubscriptEmYOffset:
-		return float32(f.os2.ySubscriptYOffset) + f.mvar.getVar(tagSubscriptYOffset, f.coords)
+		return float32(f.os2.ySubscriptYOffset) + f.mvar.getVar(MetricSubscriptYOffset, f.coords)
 	case SubscriptEmXOffset:
-		return float32(f.os2.ySubscriptXOffset) + f.mvar.getVar(tagSubscriptXOffset, f.coords)
+		return float32(f.os2.ySubscriptXOffset) + f.mvar.getVar(MetricSubscriptXOffset, f.coords)
 	case CapHeight:
 		if f.os2.version < 2 {
 			// sCapHeight may be set equal to the top of the unscaled and unhinted glyph
 			// bounding box of the glyph encoded at U+0048 (LATIN CAPITAL LETTER H).
 			return f.runeHeight('H')
 		}
-		return float32(f.os2.sCapHeight) + f.mvar.getVar(tagCapHeight, f.coords)
+		return float32(f.os2.sCapHeight) + f.mvar.getVar(MetricCapHeight, f.coords)
 	case XHeight:
 		if f.os2.version < 2 {
 			// sxHeight equal to the top of the unscaled and unhinted glyph bounding box
 			// of the glyph encoded at U+0078 (LATIN SMALL LETTER X).
 			return f.runeHeight('x')
 		}
-		return float32(f.os2.sxHeigh) + f.mvar.getVar(tagXHeight, f.coords)
+		return float32(f.os2.sxHeigh) + f.mvar.getVar(MetricXHeight, f.coords)
 	default:
 		return 0
 	}
 }
 
+// Metric returns the value of the font-wide metric identified by the 'MVAR' [tag]
+// (see for instance [MetricUnderlineOffset] or [MetricHorizontalAscender]), in font units,
+// with the variation deltas applied for the current coordinates of the face.
+//
+// The default values are read from the 'OS/2', 'hhea', 'vhea' and 'post' tables.
+// Ascenders, descenders and line gaps are resolved as in [Face.FontHExtents]; the clipping
+// descent is returned as stored in the 'OS/2' table, that is as a positive value.
+// False is returned if the tag is unknown or if the font does not provide the metric.
+func (f *Face) Metric(tag Tag) (float32, bool) {
+	switch tag {
+	case MetricHorizontalAscender, MetricHorizontalDescender, MetricHorizontalLineGap,
+		MetricVerticalAscender, MetricVerticalDescender, MetricVerticalLineGap:
+		return f.Font.getPositionCommon(tag, f.coords)
+	}
+	value, ok := f.Font.metricDefault(tag)
+	if !ok {
+		return 0, false
+	}
+	return value + f.mvar.getVar(tag, f.coords), true
+}
+
+// metricDefault returns the value of [tag], without variations,
+// for the tags not handled by [getPositionCommon]
+func (f *Font) metricDefault(tag Tag) (float32, bool) {
+	switch tag {
+	case MetricHorizontalCaretRise, MetricHorizontalCaretRun, MetricHorizontalCaretOffset:
+		return caretMetric(f.hhea, tag)
+	case MetricVerticalCaretRise, MetricVerticalCaretRun, MetricVerticalCaretOffset:
+		return caretMetric(f.vhea, tag)
+	case MetricUnderlineOffset:
+		return f.post.underlinePosition, true
+	case MetricUnderlineSize:
+		return f.post.underlineThickness, true
+	}
+
+	if !f.os2.isValid {
+		return 0, false
+	}
+	switch tag {
+	case MetricHorizontalClippingAscent:
+		return f.os2.usWinAscent, true
+	case MetricHorizontalClippingDescent:
+		return f.os2.usWinDescent, true
+	case MetricXHeight:
+		return f.os2.sxHeigh, f.os2.version >= 2
+	case MetricCapHeight:
+		return f.os2.sCapHeight, f.os2.version >= 2
+	case MetricSubscriptXSize:
+		return f.os2.ySubscriptXSize, true
+	case MetricSubscriptYSize:
+		return f.os2.ySubscriptYSize, true
+	case MetricSubscriptXOffset:
+		return f.os2.ySubscriptXOffset, true
+	case MetricSubscriptYOffset:
+		return f.os2.ySubscriptYOffset, true
+	case MetricSuperscriptXSize:
+		return f.os2.ySuperscriptXSize, true
+	case MetricSuperscriptYSize:
+		return f.os2.ySuperscriptYSize, true
+	case MetricSuperscriptXOffset:
+		return f.os2.ySuperscriptXOffset, true
+	case MetricSuperscriptYOffset:
+		return f.os2.ySuperscriptYOffset, true
+	case MetricStrikeoutSize:
+		return f.os2.yStrikeoutSize, true
+	case MetricStrikeoutOffset:
+		return f.os2.yStrikeoutPosition, true
+	}
+	return 0, false
+}
+
+func caretMetric(table *tables.Hhea, tag Tag) (float32, bool) {
+	if table == nil {
+		return 0, false
+	}
+	switch tag {
+	case MetricHorizontalCaretRise, MetricVerticalCaretRise:
+		return float32(table.CaretSlopeRise), true
+	case MetricHorizontalCaretRun, MetricVerticalCaretRun:
+		return float32(table.CaretSlopeRun), true
+	default: // caret offset
+		return float32(table.CaretOffset), true
+	}
+}
+
 // NominalGlyph returns the glyph used to represent the given rune,
 // or false if not found.
 // Note that it only looks into the cmap, without taking account substitutions
@@ -190,7 +416,10 @@ func (f *Face) LineMetric(metric LineMetric) float32 {
 func (f *Font) NominalGlyph(ch rune) (GID, bool) { return f.Cmap.Lookup(ch) }
 
 // VariationGlyph retrieves the glyph ID for a specified Unicode code point
-// followed by a specified Variation Selector code point, or false if not found
+// followed by a specified Variation Selector code point, or false if not found.
+// The Unicode variation sequences are read from the 'cmap' format 14 subtable;
+// when the font declares that the sequence uses the default glyph,
+// the result of [Font.NominalGlyph] is returned.
 func (f *Font) VariationGlyph(ch, varSelector rune) (GID, bool) {
 	gid, kind := f.cmapVar.GetGlyphVariant(ch, varSelector)
 	switch kind {
@@ -250,14 +479,61 @@ func (f *Face) getGlyphAdvanceVar(gid gID, isVertical bool) float32 {
 	return clamp(phantoms[phantomRight].X - phantoms[phantomLeft].X)
 }
 
+// getCff2AdvanceVar approximates the advance of a glyph of a CFF2 font without
+// HVAR (or VVAR) table, since CFF2 charstrings do not store advances :
+// the side bearings of the default instance are preserved, so that the advance
+// follows the variation of the blended outline.
+// Glyphs whose default advance is the em (like CJK ideographs) are designed
+// on a fixed grid, so that their advance is not modified.
+// The charstring is interpreted twice, but the result is cached by the caller.
+func (f *Face) getCff2AdvanceVar(gid gID, advance int16, isVertical bool) float32 {
+	if int(advance) == int(f.upem) {
+		return float32(advance)
+	}
+	_, bounds, err := f.cff2.LoadGlyph(gid, f.coords)
+	if err != nil {
+		return float32(advance)
+	}
+	_, defaultBounds, err := f.cff2.LoadGlyph(gid, nil)
+	if err != nil {
+		return float32(advance)
+	}
+	var delta float64
+	if isVertical {
+		delta = (bounds.Max.Y - bounds.Min.Y) - (defaultBounds.Max.Y - defaultBounds.Min.Y)
+	} else {
+		delta = (bounds.Max.X - bounds.Min.X) - (defaultBounds.Max.X - defaultBounds.Min.X)
+	}
+	return clamp(float32(advance) + float32(delta))
+}
+
+// HorizontalAdvance returns the advance of the glyph for horizontal text,
+// taking into account the variations of the face.
+// For variable faces, the advances are cached, since applying the
+// variations is expensive; the cache is cleared when the coordinates change.
 func (f *Face) HorizontalAdvance(gid GID) float32 {
 	advance := f.getBaseAdvance(gID(gid), f.hmtx, false)
 	if !f.isVar() {
 		return float32(advance)
 	}
+	if f.advanceCache == nil {
+		f.advanceCache = new(advanceCache)
+	} else if cached, ok := f.advanceCache.get(gid); ok {
+		return cached
+	}
+	out := f.horizontalAdvanceVar(gid, advance)
+	f.advanceCache.set(gid, out)
+	return out
+}
+
+// horizontalAdvanceVar applies the variations to the base [advance]
+func (f *Face) horizontalAdvanceVar(gid GID, advance int16) float32 {
 	if f.hvar != nil {
 		return float32(advance) + getAdvanceDeltaUnscaled(f.hvar, gID(gid), f.coords)
 	}
+	if f.cff2 != nil {
+		return f.getCff2AdvanceVar(gID(gid), advance, false)
+	}
 	return f.getGlyphAdvanceVar(gID(gid), false)
 }
 
@@ -267,11 +543,21 @@ func (f *Face) isVar() bool {
 }
 
 // HasVerticalMetrics returns true if a the 'vmtx' table is present.
-// If not, client should avoid calls to [VerticalAdvance], which will returns a
-// defaut value.
+// If not, [Face.VerticalAdvance] returns a synthesized value.
 func (f *Font) HasVerticalMetrics() bool { return !f.vmtx.IsEmpty() }
 
+// VerticalAdvance returns the advance of the glyph for vertical text, which is
+// negative, taking into account the variations (VVAR table) of the face.
+//
+// If the font has no vertical metrics (see [Font.HasVerticalMetrics]), the advance is synthesized
+// from the ascender and descender of the face, which vary with the 'MVAR' table.
 func (f *Face) VerticalAdvance(gid GID) float32 {
+	if f.vmtx.IsEmpty() {
+		if extents, ok := f.FontHExtents(); ok && extents.Ascender > extents.Descender {
+			return -(extents.Ascender - extents.Descender)
+		}
+	}
+
 	// return the opposite of the advance from the font
 	advance := f.getBaseAdvance(gID(gid), f.vmtx, true)
 	if !f.isVar() {
@@ -280,6 +566,12 @@ func (f *Face) VerticalAdvance(gid GID) float32 {
 	if f.vvar != nil {
 		return -float32(advance) - getAdvanceDeltaUnscaled(f.vvar, gID(gid), f.coords)
 	}
+	if f.vmtx.IsEmpty() { // default advance
+		return -float32(advance)
+	}
+	if f.cff2 != nil {
+		return -f.getCff2AdvanceVar(gID(gid), advance, true)
+	}
 	return -f.getGlyphAdvanceVar(gID(gid), true)
 }
 
@@ -301,6 +593,9 @@ func (f *Face) getVerticalSideBearing(glyph gID) int16 {
 	if f.vvar != nil {
 		return sideBearing + int16(getLsbDeltaUnscaled(f.vvar, glyph, f.coords))
 	}
+	if f.cff2 != nil { // see getCff2AdvanceVar
+		return sideBearing
+	}
 	return f.getGlyphSideBearingVar(glyph, true)
 }
 
@@ -317,7 +612,7 @@ func (f *Face) GlyphVOrigin(glyph GID) (x, y int32, found bool) {
 		return x, y, true
 	}
 
-	if extents, ok := f.getExtentsFromGlyf(gID(glyph)); ok {
+	if extents, ok := f.glyphExtentsRaw(glyph); ok {
 		if f.HasVerticalMetrics() {
 			tsb := f.getVerticalSideBearing(gID(glyph))
 			y = int32(extents.YBearing) + int32(tsb)
@@ -349,7 +644,7 @@ func (f *Face) getExtentsFromGlyf(glyph gID) (GlyphExtents, bool) {
 }
 
 func (f *Font) getExtentsFromBitmap(glyph gID, xPpem, yPpem uint16) (GlyphExtents, bool) {
-	strike := f.bitmap.chooseStrike(xPpem, yPpem)
+	strike := f.bitmapTable().chooseStrike(xPpem, yPpem)
 	if strike == nil || strike.ppemX == 0 || strike.ppemY == 0 {
 		return GlyphExtents{}, false
 	}
@@ -379,7 +674,7 @@ func (f *Font) getExtentsFromBitmap(glyph gID, xPpem, yPpem uint16) (GlyphExtent
 }
 
 func (f *Font) getExtentsFromSbix(glyph gID, xPpem, yPpem uint16) (GlyphExtents, bool) {
-	strike := f.sbix.chooseStrike(xPpem, yPpem)
+	strike := f.sbixTable().chooseStrike(xPpem, yPpem)
 	if strike == nil || strike.Ppem == 0 {
 		return GlyphExtents{}, false
 	}
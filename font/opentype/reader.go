@@ -49,9 +49,10 @@ type Resource interface {
 
 // tableSection represents a table within the font file.
 type tableSection struct {
-	offset  uint32 // Offset into the file this table starts.
-	length  uint32 // Length of this table within the file.
-	zLength uint32 // Uncompressed length of this table.
+	offset   uint32 // Offset into the file this table starts.
+	length   uint32 // Length of this table within the file.
+	zLength  uint32 // Uncompressed length of this table.
+	checksum uint32 // Checksum of the (uncompressed) table, as stored in the directory.
 }
 
 // Loader is the low level font reader, providing
@@ -60,6 +61,9 @@ type Loader struct {
 	file   Resource             // source, needed to parse each table
 	tables map[Tag]tableSection // header only, contents is processed on demand
 
+	hasChecksums bool // the table directory provides checksums
+	isSfnt       bool // the file is a single, uncompressed, font
+
 	// Type represents the kind of this font being loaded.
 	// It is one of TrueType, TrueTypeApple, PostScript1, OpenType
 	Type Tag
@@ -96,6 +100,8 @@ func NewLoaders(file Resource) ([]*Loader, error) {
 	switch magic {
 	case signatureWOFF, TrueType, OpenType, PostScript1, AppleTrueType:
 		pr, err = parseOneFont(file, 0, false)
+	case signatureWOFF2: // may be a collection
+		return parseWOFF2(file)
 	case ttcTag:
 		offsets, err = parseTTCHeader(file)
 	case dfontResourceDataOffset:
@@ -170,6 +176,21 @@ func (ld *Loader) Tables() []Tag {
 	return out
 }
 
+// TableID identifies the content of a table in a font file.
+// In collections, tables with the same TableID are shared
+// between several fonts.
+// Note that TableIDs are only meaningful for a given file.
+type TableID struct {
+	offset, length uint32
+}
+
+// TableID returns the location of [table] in the file,
+// or false if [table] is not present.
+func (ld *Loader) TableID(table Tag) (TableID, bool) {
+	s, has := ld.tables[table]
+	return TableID{offset: s.offset, length: s.length}, has
+}
+
 // RawTable returns the binary content of the given table,
 // or an error if not found.
 func (pr *Loader) RawTable(tag Tag) ([]byte, error) {
@@ -203,6 +224,8 @@ func parseOneFont(file Resource, offset uint32, relativeOffset bool) (parser *Lo
 	switch magic {
 	case signatureWOFF:
 		parser, err = parseWOFF(file, offset, relativeOffset)
+	case signatureWOFF2:
+		parser, err = parseOneWOFF2(file, offset)
 	case TrueType, OpenType, PostScript1, AppleTrueType:
 		parser, err = parseOTF(file, offset, relativeOffset)
 	case ttcTag, dfontResourceDataOffset: // no more collections allowed here
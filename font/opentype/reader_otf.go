@@ -68,6 +68,9 @@ func parseOTF(file Resource, offset uint32, relativeOffset bool) (*Loader, error
 		file:   file,
 		tables: make(map[Tag]tableSection, numTables),
 		Type:   flavor,
+
+		hasChecksums: true,
+		isSfnt:       offset == 0 && !relativeOffset,
 	}
 
 	for i := 0; i < int(numTables); i++ {
@@ -82,8 +85,9 @@ func parseOTF(file Resource, offset uint32, relativeOffset bool) (*Loader, error
 		}
 
 		sec := tableSection{
-			offset: entry.Offset,
-			length: entry.Length,
+			offset:   entry.Offset,
+			length:   entry.Length,
+			checksum: entry.CheckSum,
 		}
 		// adapt the relative offsets
 		if relativeOffset {
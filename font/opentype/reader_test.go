@@ -5,6 +5,8 @@ package opentype
 import (
 	"bytes"
 	"math/rand"
+	"os"
+	"reflect"
 	"testing"
 
 	tu "github.com/boxesandglue/typesetting/testutils"
@@ -78,3 +80,104 @@ func TestRawTable(t *testing.T) {
 		tu.AssertC(t, err == nil, filename)
 	}
 }
+
+func TestWOFF2(t *testing.T) {
+	ttf, err := os.ReadFile("../testdata/FontAwesome.ttf")
+	tu.AssertNoErr(t, err)
+	woff2, err := os.ReadFile("../testdata/FontAwesome.woff2")
+	tu.AssertNoErr(t, err)
+
+	ref, err := NewLoader(bytes.NewReader(ttf))
+	tu.AssertNoErr(t, err)
+	font, err := NewLoader(bytes.NewReader(woff2))
+	tu.AssertNoErr(t, err)
+	fonts, err := NewLoaders(bytes.NewReader(woff2))
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, len(fonts) == 1)
+
+	tu.Assert(t, font.Type == TrueType)
+	tu.Assert(t, reflect.DeepEqual(ref.Tables(), font.Tables()))
+	for _, tag := range ref.Tables() {
+		exp, err := ref.RawTable(tag)
+		tu.AssertNoErr(t, err)
+		got, err := font.RawTable(tag)
+		tu.AssertNoErr(t, err)
+		switch tag {
+		case MustNewTag("glyf"), MustNewTag("loca"), MustNewTag("head"):
+			// reconstructed or modified by the encoder
+			tu.Assert(t, len(got) != 0)
+		default:
+			tu.AssertC(t, bytes.Equal(exp, got), tag.String())
+		}
+	}
+
+	// invalid input
+	for _, L := range []int{10, 48, 200, len(woff2) - 100} {
+		_, err = NewLoader(bytes.NewReader(woff2[:L]))
+		tu.Assert(t, err != nil)
+	}
+}
+
+func TestWOFF2Hmtx(t *testing.T) {
+	// 3 glyphs, 2 hMetrics
+	maxp := []byte{0, 0, 0x50, 0, 0, 3}
+	hhea := make([]byte, 36)
+	hhea[35] = 2
+	storage := append(maxp, hhea...)
+	tables := map[Tag]tableSection{
+		MustNewTag("maxp"): {offset: 0, length: 6},
+		MustNewTag("hhea"): {offset: 6, length: 36},
+	}
+	xMins := []int16{10, -20, 30}
+
+	// lsb absent for every glyph
+	transformed := []byte{0x03, 0x01, 0xF4, 0x02, 0x58}
+	hmtx, err := reconstructHmtx(transformed, storage, tables, xMins)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, bytes.Equal(hmtx, []byte{0x01, 0xF4, 0, 10, 0x02, 0x58, 0xFF, 0xEC, 0, 30}))
+
+	// explicit lsb for the monospaced glyphs
+	transformed = []byte{0x01, 0x01, 0xF4, 0x02, 0x58, 0, 5}
+	hmtx, err = reconstructHmtx(transformed, storage, tables, xMins)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, bytes.Equal(hmtx, []byte{0x01, 0xF4, 0, 10, 0x02, 0x58, 0xFF, 0xEC, 0, 5}))
+
+	_, err = reconstructHmtx(transformed[:4], storage, tables, xMins)
+	tu.Assert(t, err != nil)
+}
+
+func TestWOFF2Numbers(t *testing.T) {
+	for _, test := range []struct {
+		data     []byte
+		expected uint32
+	}{
+		{[]byte{0x3F}, 63},
+		{[]byte{0x81, 0x00}, 128},
+		{[]byte{0x8F, 0xFF, 0xFF, 0xFF, 0x7F}, 0xFFFFFFFF},
+	} {
+		r := woff2Reader{data: test.data}
+		got, err := r.base128()
+		tu.AssertNoErr(t, err)
+		tu.Assert(t, got == test.expected)
+	}
+	for _, invalid := range [][]byte{{0x80, 0x01}, {0x90, 0x80, 0x80, 0x80, 0x00}, {0xFF}} {
+		r := woff2Reader{data: invalid}
+		_, err := r.base128()
+		tu.Assert(t, err != nil)
+	}
+
+	for _, test := range []struct {
+		data     []byte
+		expected uint16
+	}{
+		{[]byte{252}, 252},
+		{[]byte{255, 0}, 253},
+		{[]byte{254, 0}, 506},
+		{[]byte{253, 0x01, 0x02}, 258},
+	} {
+		r := woff2Reader{data: test.data}
+		got, err := r.uint255()
+		tu.AssertNoErr(t, err)
+		tu.Assert(t, got == test.expected)
+	}
+}
@@ -64,6 +64,8 @@ func parseWOFF(file Resource, offset uint32, relativeOffset bool) (*Loader, erro
 		file:   file,
 		tables: make(map[Tag]tableSection, numTables),
 		Type:   flavor,
+
+		hasChecksums: true,
 	}
 	for i := 0; i < int(numTables); i++ {
 		entry, err := readWOFFEntry(file)
@@ -77,9 +79,10 @@ func parseWOFF(file Resource, offset uint32, relativeOffset bool) (*Loader, erro
 		}
 
 		sec := tableSection{
-			offset:  entry.Offset,
-			length:  entry.CompLength,
-			zLength: entry.OrigLength,
+			offset:   entry.Offset,
+			length:   entry.CompLength,
+			zLength:  entry.OrigLength,
+			checksum: entry.OrigChecksum,
 		}
 		// adapt the relative offsets
 		if relativeOffset {
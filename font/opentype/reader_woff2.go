@@ -0,0 +1,854 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package opentype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// WOFF2 support, as specified in https://www.w3.org/TR/WOFF2/
+//
+// The compressed tables are decoded once, when loading the file, and
+// the transformed 'glyf', 'loca' and 'hmtx' tables are reconstructed,
+// so that the returned [Loader]s are backed by an uncompressed, in-memory font.
+
+// signatureWOFF2 is the magic number at the start of a WOFF2 file.
+var signatureWOFF2 = MustNewTag("wOF2")
+
+const (
+	woff2HeaderSize = 48
+
+	// security implementation limit for the decompressed size
+	maxWOFF2DecompressedSize = 1 << 28
+)
+
+// woff2KnownTags are the tags referenced by index in the table directory
+var woff2KnownTags = [63]Tag{
+	MustNewTag("cmap"), MustNewTag("head"), MustNewTag("hhea"), MustNewTag("hmtx"),
+	MustNewTag("maxp"), MustNewTag("name"), MustNewTag("OS/2"), MustNewTag("post"),
+	MustNewTag("cvt "), MustNewTag("fpgm"), MustNewTag("glyf"), MustNewTag("loca"),
+	MustNewTag("prep"), MustNewTag("CFF "), MustNewTag("VORG"), MustNewTag("EBDT"),
+	MustNewTag("EBLC"), MustNewTag("gasp"), MustNewTag("hdmx"), MustNewTag("kern"),
+	MustNewTag("LTSH"), MustNewTag("PCLT"), MustNewTag("VDMX"), MustNewTag("vhea"),
+	MustNewTag("vmtx"), MustNewTag("BASE"), MustNewTag("GDEF"), MustNewTag("GPOS"),
+	MustNewTag("GSUB"), MustNewTag("EBSC"), MustNewTag("JSTF"), MustNewTag("MATH"),
+	MustNewTag("CBDT"), MustNewTag("CBLC"), MustNewTag("COLR"), MustNewTag("CPAL"),
+	MustNewTag("SVG "), MustNewTag("sbix"), MustNewTag("acnt"), MustNewTag("avar"),
+	MustNewTag("bdat"), MustNewTag("bloc"), MustNewTag("bsln"), MustNewTag("cvar"),
+	MustNewTag("fdsc"), MustNewTag("feat"), MustNewTag("fmtx"), MustNewTag("fvar"),
+	MustNewTag("gvar"), MustNewTag("hsty"), MustNewTag("just"), MustNewTag("lcar"),
+	MustNewTag("mort"), MustNewTag("morx"), MustNewTag("opbd"), MustNewTag("prop"),
+	MustNewTag("trak"), MustNewTag("Zapf"), MustNewTag("Silf"), MustNewTag("Glat"),
+	MustNewTag("Gloc"), MustNewTag("Feat"), MustNewTag("Sill"),
+}
+
+var (
+	tagGlyf = MustNewTag("glyf")
+	tagLoca = MustNewTag("loca")
+	tagHmtx = MustNewTag("hmtx")
+	tagHhea = MustNewTag("hhea")
+	tagMaxp = MustNewTag("maxp")
+)
+
+type woff2Entry struct {
+	Tag             Tag
+	transformed     bool
+	origLength      uint32
+	transformLength uint32
+
+	offset uint32 // in the decompressed stream
+}
+
+// length returns the length of the table in the decompressed stream
+func (e woff2Entry) length() uint32 {
+	if e.transformed {
+		return e.transformLength
+	}
+	return e.origLength
+}
+
+// woff2Reader is a cursor into a WOFF2 binary stream
+type woff2Reader struct {
+	data []byte
+	pos  int
+}
+
+var errWOFF2EOF = errors.New("invalid WOFF2 file: unexpected end of data")
+
+func (r *woff2Reader) bytes(n int) ([]byte, error) {
+	if n < 0 || len(r.data)-r.pos < n {
+		return nil, errWOFF2EOF
+	}
+	out := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return out, nil
+}
+
+func (r *woff2Reader) u8() (uint8, error) {
+	b, err := r.bytes(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *woff2Reader) u16() (uint16, error) {
+	b, err := r.bytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b), nil
+}
+
+func (r *woff2Reader) u32() (uint32, error) {
+	b, err := r.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+// base128 reads an UIntBase128 value
+func (r *woff2Reader) base128() (uint32, error) {
+	var accum uint32
+	for i := 0; i < 5; i++ {
+		b, err := r.u8()
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 && b == 0x80 { // leading zeros are invalid
+			return 0, errors.New("invalid WOFF2 UIntBase128 value")
+		}
+		if accum&0xFE000000 != 0 { // overflow
+			return 0, errors.New("invalid WOFF2 UIntBase128 value")
+		}
+		accum = accum<<7 | uint32(b&0x7F)
+		if b&0x80 == 0 {
+			return accum, nil
+		}
+	}
+	return 0, errors.New("invalid WOFF2 UIntBase128 value")
+}
+
+// uint255 reads an 255UInt16 value
+func (r *woff2Reader) uint255() (uint16, error) {
+	const (
+		oneMoreByteCode1 = 255
+		oneMoreByteCode2 = 254
+		wordCode         = 253
+		lowestUCode      = 253
+	)
+	code, err := r.u8()
+	if err != nil {
+		return 0, err
+	}
+	switch code {
+	case wordCode:
+		return r.u16()
+	case oneMoreByteCode1:
+		v, err := r.u8()
+		return uint16(v) + lowestUCode, err
+	case oneMoreByteCode2:
+		v, err := r.u8()
+		return uint16(v) + lowestUCode*2, err
+	default:
+		return uint16(code), nil
+	}
+}
+
+// woff2Font is one font of the (possibly collection) file,
+// storing indices into the table directory
+type woff2Font struct {
+	flavor  Tag
+	indices []uint16
+}
+
+// parseWOFF2 decodes a whole WOFF2 file, which may be a collection.
+func parseWOFF2(file Resource) ([]*Loader, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	r := &woff2Reader{data: content}
+
+	header, err := r.bytes(woff2HeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	flavor := Tag(binary.BigEndian.Uint32(header[4:]))
+	numTables := binary.BigEndian.Uint16(header[12:])
+	totalCompressedSize := binary.BigEndian.Uint32(header[20:])
+	if numTables == 0 {
+		return nil, errors.New("invalid WOFF2 file: no tables")
+	}
+
+	entries := make([]woff2Entry, numTables)
+	var decompressedSize uint64
+	for i := range entries {
+		entry, err := r.readEntry()
+		if err != nil {
+			return nil, err
+		}
+		entry.offset = uint32(decompressedSize)
+		decompressedSize += uint64(entry.length())
+		if decompressedSize > maxWOFF2DecompressedSize {
+			return nil, fmt.Errorf("WOFF2 decompressed size exceed implementation limit (%d)", maxWOFF2DecompressedSize)
+		}
+		entries[i] = entry
+	}
+
+	var fonts []woff2Font
+	if flavor == ttcTag {
+		fonts, err = r.readCollectionDirectory(len(entries))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		font := woff2Font{flavor: flavor, indices: make([]uint16, len(entries))}
+		for i := range entries {
+			font.indices[i] = uint16(i)
+		}
+		fonts = []woff2Font{font}
+	}
+
+	compressed, err := r.bytes(int(totalCompressedSize))
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, decompressedSize)
+	if _, err = io.ReadFull(brotli.NewReader(bytes.NewReader(compressed)), data); err != nil {
+		return nil, fmt.Errorf("invalid WOFF2 compressed data: %s", err)
+	}
+
+	return newWOFF2Loaders(entries, fonts, data)
+}
+
+// parseOneWOFF2 is the same as [parseWOFF2], but rejects collections
+func parseOneWOFF2(file Resource, offset uint32) (*Loader, error) {
+	if offset != 0 {
+		return nil, errors.New("WOFF2 fonts are not allowed in collections")
+	}
+	loaders, err := parseWOFF2(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(loaders) != 1 {
+		return nil, errors.New("collections not allowed")
+	}
+	return loaders[0], nil
+}
+
+func (r *woff2Reader) readEntry() (woff2Entry, error) {
+	var entry woff2Entry
+	flags, err := r.u8()
+	if err != nil {
+		return entry, err
+	}
+	if index := flags & 0x3F; index == 0x3F {
+		tag, err := r.u32()
+		if err != nil {
+			return entry, err
+		}
+		entry.Tag = Tag(tag)
+	} else if int(index) < len(woff2KnownTags) {
+		entry.Tag = woff2KnownTags[index]
+	} else {
+		return entry, fmt.Errorf("invalid WOFF2 table index %d", index)
+	}
+
+	entry.origLength, err = r.base128()
+	if err != nil {
+		return entry, err
+	}
+
+	// for glyf and loca, version 0 is the transformed one,
+	// and version 3 is the null transform
+	transformVersion := flags >> 6
+	if entry.Tag == tagGlyf || entry.Tag == tagLoca {
+		entry.transformed = transformVersion == 0
+	} else {
+		entry.transformed = transformVersion != 0
+	}
+
+	if entry.transformed {
+		entry.transformLength, err = r.base128()
+		if err != nil {
+			return entry, err
+		}
+		if entry.Tag == tagLoca && entry.transformLength != 0 {
+			return entry, errors.New("invalid WOFF2 file: transformed loca table must be empty")
+		}
+	}
+	return entry, nil
+}
+
+func (r *woff2Reader) readCollectionDirectory(numTables int) ([]woff2Font, error) {
+	if _, err := r.u32(); err != nil { // version
+		return nil, err
+	}
+	numFonts, err := r.uint255()
+	if err != nil {
+		return nil, err
+	}
+	if numFonts == 0 {
+		return nil, errors.New("empty font collection")
+	}
+	fonts := make([]woff2Font, numFonts)
+	for i := range fonts {
+		count, err := r.uint255()
+		if err != nil {
+			return nil, err
+		}
+		flavor, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		font := woff2Font{flavor: Tag(flavor), indices: make([]uint16, count)}
+		for j := range font.indices {
+			font.indices[j], err = r.uint255()
+			if err != nil {
+				return nil, err
+			}
+			if int(font.indices[j]) >= numTables {
+				return nil, fmt.Errorf("invalid WOFF2 collection table index %d", font.indices[j])
+			}
+		}
+		fonts[i] = font
+	}
+	return fonts, nil
+}
+
+// newWOFF2Loaders builds the loaders from the decompressed [data],
+// reconstructing the transformed tables.
+func newWOFF2Loaders(entries []woff2Entry, fonts []woff2Font, data []byte) ([]*Loader, error) {
+	// the final in-memory font data; reconstructed tables are appended
+	// after the decompressed stream
+	storage := data
+	appendTable := func(table []byte) (tableSection, error) {
+		if len(storage)+len(table) > maxWOFF2DecompressedSize {
+			return tableSection{}, fmt.Errorf("WOFF2 decompressed size exceed implementation limit (%d)", maxWOFF2DecompressedSize)
+		}
+		sec := tableSection{offset: uint32(len(storage)), length: uint32(len(table)), zLength: uint32(len(table))}
+		storage = append(storage, table...)
+		return sec, nil
+	}
+
+	// reconstructed tables, shared between the fonts of a collection,
+	// indexed by the index of the transformed table in the directory
+	type glyfLoca struct {
+		glyf, loca tableSection
+		xMins      []int16
+	}
+	var (
+		glyfCache = map[uint16]glyfLoca{}
+		hmtxCache = map[uint16]tableSection{}
+	)
+
+	out := make([]*Loader, len(fonts))
+	for fi, font := range fonts {
+		tables := make(map[Tag]tableSection, len(font.indices))
+		glyfIndex, locaIndex, hmtxIndex := -1, -1, -1
+		for _, index := range font.indices {
+			entry := entries[index]
+			if _, found := tables[entry.Tag]; found {
+				// ignore duplicate tables – the first one wins
+				continue
+			}
+			switch entry.Tag {
+			case tagGlyf:
+				glyfIndex = int(index)
+			case tagLoca:
+				locaIndex = int(index)
+			case tagHmtx:
+				hmtxIndex = int(index)
+			}
+			tables[entry.Tag] = tableSection{offset: entry.offset, length: entry.length(), zLength: entry.length()}
+		}
+
+		var xMins []int16 // required by the hmtx transform
+		if glyfIndex != -1 && entries[glyfIndex].transformed {
+			if locaIndex == -1 {
+				return nil, errors.New("invalid WOFF2 file: missing loca table")
+			}
+			cached, ok := glyfCache[uint16(glyfIndex)]
+			if !ok {
+				entry := entries[glyfIndex]
+				glyf, loca, mins, err := reconstructGlyfLoca(data[entry.offset : entry.offset+entry.length()])
+				if err != nil {
+					return nil, err
+				}
+				cached.xMins = mins
+				if cached.glyf, err = appendTable(glyf); err != nil {
+					return nil, err
+				}
+				if cached.loca, err = appendTable(loca); err != nil {
+					return nil, err
+				}
+				glyfCache[uint16(glyfIndex)] = cached
+			}
+			tables[tagGlyf], tables[tagLoca] = cached.glyf, cached.loca
+			xMins = cached.xMins
+		}
+
+		if hmtxIndex != -1 && entries[hmtxIndex].transformed {
+			sec, ok := hmtxCache[uint16(hmtxIndex)]
+			if !ok {
+				if xMins == nil {
+					return nil, errors.New("invalid WOFF2 file: transformed hmtx requires a transformed glyf table")
+				}
+				entry := entries[hmtxIndex]
+				hmtx, err := reconstructHmtx(data[entry.offset:entry.offset+entry.length()], storage, tables, xMins)
+				if err != nil {
+					return nil, err
+				}
+				if sec, err = appendTable(hmtx); err != nil {
+					return nil, err
+				}
+				hmtxCache[uint16(hmtxIndex)] = sec
+			}
+			tables[tagHmtx] = sec
+		}
+
+		out[fi] = &Loader{tables: tables, Type: font.flavor}
+	}
+
+	// storage may have been reallocated
+	file := bytes.NewReader(storage)
+	for _, ld := range out {
+		ld.file = file
+	}
+	return out, nil
+}
+
+// numGlyphs and numberOfHMetrics, from the maxp and hhea tables
+func woff2HmtxCounts(storage []byte, tables map[Tag]tableSection) (numGlyphs, numHMetrics int, err error) {
+	maxp, hasMaxp := tables[tagMaxp]
+	hhea, hasHhea := tables[tagHhea]
+	if !hasMaxp || !hasHhea || maxp.length < 6 || hhea.length < 36 {
+		return 0, 0, errors.New("invalid WOFF2 file: missing or invalid maxp or hhea table for hmtx transform")
+	}
+	numGlyphs = int(binary.BigEndian.Uint16(storage[maxp.offset+4:]))
+	numHMetrics = int(binary.BigEndian.Uint16(storage[hhea.offset+34:]))
+	if numHMetrics < 1 || numHMetrics > numGlyphs {
+		return 0, 0, errors.New("invalid WOFF2 file: invalid numberOfHMetrics")
+	}
+	return numGlyphs, numHMetrics, nil
+}
+
+// composite glyph flags
+const (
+	woff2ArgsAreWords     = 0x0001
+	woff2HaveScale        = 0x0008
+	woff2MoreComponents   = 0x0020
+	woff2HaveXYScale      = 0x0040
+	woff2HaveTwoByTwo     = 0x0080
+	woff2HaveInstructions = 0x0100
+)
+
+// simple glyph flags
+const (
+	glyfOnCurve      = 0x01
+	glyfXShort       = 0x02
+	glyfYShort       = 0x04
+	glyfXSameOrPos   = 0x10
+	glyfYSameOrPos   = 0x20
+	glyfOverlapSimpl = 0x40
+)
+
+// reconstructGlyfLoca decodes a transformed glyf table, returning the
+// glyf and loca tables, and the xMin of each glyph.
+func reconstructGlyfLoca(transformed []byte) (glyf, loca []byte, xMins []int16, err error) {
+	r := &woff2Reader{data: transformed}
+	header, err := r.bytes(36)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	optionFlags := binary.BigEndian.Uint16(header[2:])
+	numGlyphs := int(binary.BigEndian.Uint16(header[4:]))
+	indexFormat := binary.BigEndian.Uint16(header[6:])
+
+	// the seven sub-streams
+	var streams [7]*woff2Reader
+	for i := range streams {
+		size := binary.BigEndian.Uint32(header[8+4*i:])
+		b, err := r.bytes(int(size))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		streams[i] = &woff2Reader{data: b}
+	}
+	nContourStream, nPointsStream, flagStream, glyphStream, compositeStream, bboxStream, instructionStream := streams[0], streams[1], streams[2], streams[3], streams[4], streams[5], streams[6]
+
+	bboxBitmap, err := bboxStream.bytes(((numGlyphs + 31) >> 5) * 4)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var overlapBitmap []byte
+	if optionFlags&1 != 0 {
+		overlapBitmap, err = r.bytes((numGlyphs + 7) >> 3)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	offsets := make([]uint32, numGlyphs+1)
+	xMins = make([]int16, numGlyphs)
+	var (
+		points    []woff2Point
+		endPoints []uint16
+	)
+	for gid := 0; gid < numGlyphs; gid++ {
+		hasBbox := bboxBitmap[gid>>3]&(0x80>>(gid&7)) != 0
+		nContours, err := nContourStream.u16()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		var bbox [4]int16
+		if hasBbox {
+			for i := range bbox {
+				v, err := bboxStream.u16()
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				bbox[i] = int16(v)
+			}
+		}
+
+		start := len(glyf)
+		switch int16(nContours) {
+		case 0: // empty glyph
+			if hasBbox {
+				return nil, nil, nil, errors.New("invalid WOFF2 glyf table: empty glyph with bounding box")
+			}
+		case -1: // composite glyph
+			if !hasBbox {
+				return nil, nil, nil, errors.New("invalid WOFF2 glyf table: composite glyph without bounding box")
+			}
+			composite, haveInstructions, err := readWOFF2Composite(compositeStream)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			glyf = appendU16(glyf, nContours)
+			for _, v := range bbox {
+				glyf = appendU16(glyf, uint16(v))
+			}
+			glyf = append(glyf, composite...)
+			if haveInstructions {
+				glyf, err = appendWOFF2Instructions(glyf, glyphStream, instructionStream)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+			}
+		default: // simple glyph
+			endPoints = endPoints[:0]
+			var totalPoints int
+			for i := 0; i < int(nContours); i++ {
+				n, err := nPointsStream.uint255()
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				totalPoints += int(n)
+				if totalPoints > 0xFFFF {
+					return nil, nil, nil, errors.New("invalid WOFF2 glyf table: too many points")
+				}
+				endPoints = append(endPoints, uint16(totalPoints-1))
+			}
+			flags, err := flagStream.bytes(totalPoints)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			points, err = decodeWOFF2Triplets(flags, glyphStream, points[:0])
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if !hasBbox {
+				bbox = woff2PointsBbox(points)
+			}
+			glyf = appendU16(glyf, nContours)
+			for _, v := range bbox {
+				glyf = appendU16(glyf, uint16(v))
+			}
+			for _, e := range endPoints {
+				glyf = appendU16(glyf, e)
+			}
+			glyf, err = appendWOFF2Instructions(glyf, glyphStream, instructionStream)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			hasOverlap := overlapBitmap != nil && overlapBitmap[gid>>3]&(0x80>>(gid&7)) != 0
+			glyf = appendSimplePoints(glyf, points, hasOverlap)
+		}
+		xMins[gid] = bbox[0]
+
+		// pad to 4 bytes
+		for len(glyf)%4 != 0 {
+			glyf = append(glyf, 0)
+		}
+		if len(glyf) > maxWOFF2DecompressedSize {
+			return nil, nil, nil, fmt.Errorf("WOFF2 decompressed size exceed implementation limit (%d)", maxWOFF2DecompressedSize)
+		}
+		offsets[gid] = uint32(start)
+		offsets[gid+1] = uint32(len(glyf))
+	}
+
+	// build the loca table, with the format specified in the transformed header
+	if indexFormat == 0 && len(glyf) > 0xFFFF*2 {
+		return nil, nil, nil, errors.New("invalid WOFF2 glyf table: glyf too large for short loca format")
+	}
+	for _, o := range offsets {
+		if indexFormat == 0 {
+			loca = appendU16(loca, uint16(o/2))
+		} else {
+			loca = binary.BigEndian.AppendUint32(loca, o)
+		}
+	}
+	return glyf, loca, xMins, nil
+}
+
+func appendU16(b []byte, v uint16) []byte { return append(b, byte(v>>8), byte(v)) }
+
+// readWOFF2Composite returns the components data, which are stored
+// as in the original glyf table
+func readWOFF2Composite(stream *woff2Reader) (data []byte, haveInstructions bool, err error) {
+	start := stream.pos
+	for {
+		flags, err := stream.u16()
+		if err != nil {
+			return nil, false, err
+		}
+		haveInstructions = haveInstructions || flags&woff2HaveInstructions != 0
+		argSize := 2 // glyph index
+		if flags&woff2ArgsAreWords != 0 {
+			argSize += 4
+		} else {
+			argSize += 2
+		}
+		switch {
+		case flags&woff2HaveScale != 0:
+			argSize += 2
+		case flags&woff2HaveXYScale != 0:
+			argSize += 4
+		case flags&woff2HaveTwoByTwo != 0:
+			argSize += 8
+		}
+		if _, err := stream.bytes(argSize); err != nil {
+			return nil, false, err
+		}
+		if flags&woff2MoreComponents == 0 {
+			break
+		}
+	}
+	return stream.data[start:stream.pos], haveInstructions, nil
+}
+
+func appendWOFF2Instructions(glyf []byte, glyphStream, instructionStream *woff2Reader) ([]byte, error) {
+	size, err := glyphStream.uint255()
+	if err != nil {
+		return nil, err
+	}
+	instructions, err := instructionStream.bytes(int(size))
+	if err != nil {
+		return nil, err
+	}
+	glyf = appendU16(glyf, size)
+	return append(glyf, instructions...), nil
+}
+
+type woff2Point struct {
+	x, y    int32
+	onCurve bool
+}
+
+// decodeWOFF2Triplets decodes the point coordinates, as described
+// in the section 5.2. "Decoding of variable-length X and Y coordinates"
+func decodeWOFF2Triplets(flags []byte, stream *woff2Reader, points []woff2Point) ([]woff2Point, error) {
+	withSign := func(flag byte, v int32) int32 {
+		if flag&1 != 0 {
+			return v
+		}
+		return -v
+	}
+	var x, y int32
+	for _, flag := range flags {
+		onCurve := flag>>7 == 0
+		flag &= 0x7F
+		var n int
+		switch {
+		case flag < 84:
+			n = 1
+		case flag < 120:
+			n = 2
+		case flag < 124:
+			n = 3
+		default:
+			n = 4
+		}
+		b, err := stream.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+		var dx, dy int32
+		switch {
+		case flag < 10:
+			dy = withSign(flag, int32(flag&14)<<7+int32(b[0]))
+		case flag < 20:
+			dx = withSign(flag, int32((flag-10)&14)<<7+int32(b[0]))
+		case flag < 84:
+			b0, b1 := int32(flag-20), int32(b[0])
+			dx = withSign(flag, 1+(b0&0x30)+(b1>>4))
+			dy = withSign(flag>>1, 1+((b0&0x0C)<<2)+(b1&0x0F))
+		case flag < 120:
+			b0 := int32(flag - 84)
+			dx = withSign(flag, 1+((b0/12)<<8)+int32(b[0]))
+			dy = withSign(flag>>1, 1+(((b0%12)>>2)<<8)+int32(b[1]))
+		case flag < 124:
+			b2 := int32(b[1])
+			dx = withSign(flag, int32(b[0])<<4+(b2>>4))
+			dy = withSign(flag>>1, (b2&0x0F)<<8+int32(b[2]))
+		default:
+			dx = withSign(flag, int32(b[0])<<8+int32(b[1]))
+			dy = withSign(flag>>1, int32(b[2])<<8+int32(b[3]))
+		}
+		x += dx
+		y += dy
+		points = append(points, woff2Point{x: x, y: y, onCurve: onCurve})
+	}
+	return points, nil
+}
+
+func woff2PointsBbox(points []woff2Point) (bbox [4]int16) {
+	if len(points) == 0 {
+		return bbox
+	}
+	xMin, yMin, xMax, yMax := points[0].x, points[0].y, points[0].x, points[0].y
+	for _, p := range points[1:] {
+		if p.x < xMin {
+			xMin = p.x
+		}
+		if p.x > xMax {
+			xMax = p.x
+		}
+		if p.y < yMin {
+			yMin = p.y
+		}
+		if p.y > yMax {
+			yMax = p.y
+		}
+	}
+	return [4]int16{int16(xMin), int16(yMin), int16(xMax), int16(yMax)}
+}
+
+// appendSimplePoints encodes the flags and coordinates of a simple glyph
+func appendSimplePoints(glyf []byte, points []woff2Point, hasOverlap bool) []byte {
+	var (
+		xs, ys       []byte
+		lastX, lastY int32
+	)
+	for i, p := range points {
+		var flag byte
+		if p.onCurve {
+			flag |= glyfOnCurve
+		}
+		if i == 0 && hasOverlap {
+			flag |= glyfOverlapSimpl
+		}
+
+		dx := p.x - lastX
+		switch {
+		case dx == 0:
+			flag |= glyfXSameOrPos
+		case -255 <= dx && dx <= 255:
+			flag |= glyfXShort
+			if dx > 0 {
+				flag |= glyfXSameOrPos
+			} else {
+				dx = -dx
+			}
+			xs = append(xs, byte(dx))
+		default:
+			xs = appendU16(xs, uint16(int16(dx)))
+		}
+
+		dy := p.y - lastY
+		switch {
+		case dy == 0:
+			flag |= glyfYSameOrPos
+		case -255 <= dy && dy <= 255:
+			flag |= glyfYShort
+			if dy > 0 {
+				flag |= glyfYSameOrPos
+			} else {
+				dy = -dy
+			}
+			ys = append(ys, byte(dy))
+		default:
+			ys = appendU16(ys, uint16(int16(dy)))
+		}
+
+		lastX, lastY = p.x, p.y
+		glyf = append(glyf, flag)
+	}
+	glyf = append(glyf, xs...)
+	return append(glyf, ys...)
+}
+
+// reconstructHmtx decodes a transformed hmtx table
+func reconstructHmtx(transformed, storage []byte, tables map[Tag]tableSection, xMins []int16) ([]byte, error) {
+	numGlyphs, numHMetrics, err := woff2HmtxCounts(storage, tables)
+	if err != nil {
+		return nil, err
+	}
+	if len(xMins) < numGlyphs {
+		return nil, errors.New("invalid WOFF2 file: glyf and maxp tables mismatch")
+	}
+
+	r := &woff2Reader{data: transformed}
+	flags, err := r.u8()
+	if err != nil {
+		return nil, err
+	}
+	advances, err := r.bytes(2 * numHMetrics)
+	if err != nil {
+		return nil, err
+	}
+	var lsbs, monoLsbs []byte
+	if flags&1 == 0 {
+		if lsbs, err = r.bytes(2 * numHMetrics); err != nil {
+			return nil, err
+		}
+	}
+	if flags&2 == 0 {
+		if monoLsbs, err = r.bytes(2 * (numGlyphs - numHMetrics)); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]byte, 0, 4*numHMetrics+2*(numGlyphs-numHMetrics))
+	for i := 0; i < numHMetrics; i++ {
+		out = append(out, advances[2*i], advances[2*i+1])
+		if lsbs != nil {
+			out = append(out, lsbs[2*i], lsbs[2*i+1])
+		} else {
+			out = appendU16(out, uint16(xMins[i]))
+		}
+	}
+	for i := numHMetrics; i < numGlyphs; i++ {
+		if monoLsbs != nil {
+			j := i - numHMetrics
+			out = append(out, monoLsbs[2*j], monoLsbs[2*j+1])
+		} else {
+			out = appendU16(out, uint16(xMins[i]))
+		}
+	}
+	return out, nil
+}
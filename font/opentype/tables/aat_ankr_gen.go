@@ -125,20 +125,16 @@ func ParseAATLoopkup10(src []byte) (AATLoopkup10, int, error) {
 	item.version = binary.BigEndian.Uint16(src[0:])
 	item.unitSize = binary.BigEndian.Uint16(src[2:])
 	item.FirstGlyph = binary.BigEndian.Uint16(src[4:])
-	arrayLengthValues := int(binary.BigEndian.Uint16(src[6:]))
+	item.nValues = binary.BigEndian.Uint16(src[6:])
 	n += 8
 
 	{
 
-		if L := len(src); L < 8+arrayLengthValues*2 {
-			return item, 0, fmt.Errorf("reading AATLoopkup10: "+"EOF: expected length: %d, got %d", 8+arrayLengthValues*2, L)
-		}
-
-		item.Values = make([]uint16, arrayLengthValues) // allocation guarded by the previous check
-		for i := range item.Values {
-			item.Values[i] = binary.BigEndian.Uint16(src[8+i*2:])
+		read, err := item.parseValues(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading AATLoopkup10: %s", err)
 		}
-		n += arrayLengthValues * 2
+		n = read
 	}
 	return item, n, nil
 }
@@ -155,17 +151,12 @@ func ParseAATLoopkup2(src []byte) (AATLoopkup2, int, error) {
 	n += 12
 
 	{
-		arrayLength := int(item.nUnits)
-
-		if L := len(src); L < 12+arrayLength*6 {
-			return item, 0, fmt.Errorf("reading AATLoopkup2: "+"EOF: expected length: %d, got %d", 12+arrayLength*6, L)
-		}
 
-		item.Records = make([]LookupRecord2, arrayLength) // allocation guarded by the previous check
-		for i := range item.Records {
-			item.Records[i].mustParse(src[12+i*6:])
+		read, err := item.parseRecords(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading AATLoopkup2: %s", err)
 		}
-		n += arrayLength * 6
+		n = read
 	}
 	return item, n, nil
 }
@@ -182,18 +173,12 @@ func ParseAATLoopkup4(src []byte) (AATLoopkup4, int, error) {
 	n += 12
 
 	{
-		arrayLength := int(item.nUnits - 1)
 
-		offset := 12
-		for i := 0; i < arrayLength; i++ {
-			elem, read, err := ParseAATLookupRecord4(src[offset:], src)
-			if err != nil {
-				return item, 0, fmt.Errorf("reading AATLoopkup4: %s", err)
-			}
-			item.Records = append(item.Records, elem)
-			offset += read
+		read, err := item.parseRecords(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading AATLoopkup4: %s", err)
 		}
-		n = offset
+		n = read
 	}
 	return item, n, nil
 }
@@ -210,17 +195,12 @@ func ParseAATLoopkup6(src []byte) (AATLoopkup6, int, error) {
 	n += 12
 
 	{
-		arrayLength := int(item.nUnits)
 
-		if L := len(src); L < 12+arrayLength*4 {
-			return item, 0, fmt.Errorf("reading AATLoopkup6: "+"EOF: expected length: %d, got %d", 12+arrayLength*4, L)
-		}
-
-		item.Records = make([]loopkupRecord6, arrayLength) // allocation guarded by the previous check
-		for i := range item.Records {
-			item.Records[i].mustParse(src[12+i*4:])
+		read, err := item.parseRecords(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading AATLoopkup6: %s", err)
 		}
-		n += arrayLength * 4
+		n = read
 	}
 	return item, n, nil
 }
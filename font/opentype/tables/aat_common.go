@@ -223,7 +223,7 @@ type AATLoopkup0 struct {
 type AATLoopkup2 struct {
 	version uint16 `unionTag:"2"`
 	binSearchHeader
-	Records []LookupRecord2 `arrayCount:"ComputedField-nUnits"`
+	Records []LookupRecord2 `isOpaque:""`
 }
 
 type LookupRecord2 struct {
@@ -235,8 +235,7 @@ type LookupRecord2 struct {
 type AATLoopkup4 struct {
 	version uint16 `unionTag:"4"`
 	binSearchHeader
-	// Do not include the termination segment
-	Records []AATLookupRecord4 `arrayCount:"ComputedField-nUnits-1"`
+	Records []AATLookupRecord4 `isOpaque:""`
 }
 
 type AATLookupRecord4 struct {
@@ -246,12 +245,17 @@ type AATLookupRecord4 struct {
 	Values []uint16 `offsetSize:"Offset16" offsetRelativeTo:"Parent" arrayCount:"ComputedField-nValues()"`
 }
 
-func (lk AATLookupRecord4) nValues() int { return int(lk.LastGlyph) - int(lk.FirstGlyph) + 1 }
+func (lk AATLookupRecord4) nValues() int {
+	if lk.LastGlyph < lk.FirstGlyph { // invalid range
+		return 0
+	}
+	return int(lk.LastGlyph) - int(lk.FirstGlyph) + 1
+}
 
 type AATLoopkup6 struct {
 	version uint16 `unionTag:"6"`
 	binSearchHeader
-	Records []loopkupRecord6 `arrayCount:"ComputedField-nUnits"`
+	Records []loopkupRecord6 `isOpaque:""`
 }
 
 type loopkupRecord6 struct {
@@ -271,9 +275,10 @@ type AATLoopkup8Data struct {
 
 type AATLoopkup10 struct {
 	version    uint16 `unionTag:"10"`
-	unitSize   uint16
+	unitSize   uint16 // size of the values, in bytes
 	FirstGlyph GlyphID
-	Values     []uint16 `arrayCount:"FirstUint16"`
+	nValues    uint16
+	Values     []uint16 `isOpaque:""`
 }
 
 // extended versions
@@ -304,7 +309,7 @@ type AATLoopkupExt0 struct {
 type AATLoopkupExt2 struct {
 	version uint16 `unionTag:"2"`
 	binSearchHeader
-	Records []lookupRecordExt2 `arrayCount:"ComputedField-nUnits"`
+	Records []lookupRecordExt2 `isOpaque:""`
 }
 
 type lookupRecordExt2 struct {
@@ -317,7 +322,7 @@ type AATLoopkupExt4 struct {
 	version uint16 `unionTag:"4"`
 	binSearchHeader
 	// the values pointed by the record are uint32
-	Records []loopkupRecordExt4 `arrayCount:"ComputedField-nUnits"`
+	Records []loopkupRecordExt4 `isOpaque:""`
 }
 
 type loopkupRecordExt4 struct {
@@ -332,7 +337,7 @@ func (lk loopkupRecordExt4) nValues() int { return int(lk.LastGlyph) - int(lk.Fi
 type AATLoopkupExt6 struct {
 	version uint16 `unionTag:"6"`
 	binSearchHeader
-	Records []loopkupRecordExt6 `arrayCount:"ComputedField-nUnits"`
+	Records []loopkupRecordExt6 `isOpaque:""`
 }
 
 type loopkupRecordExt6 struct {
@@ -344,7 +349,169 @@ type AATLoopkupExt8 AATLoopkup8
 
 type AATLoopkupExt10 struct {
 	version    uint16 `unionTag:"10"`
-	unitSize   uint16
+	unitSize   uint16 // size of the values, in bytes
 	FirstGlyph GlyphID
-	Values     []uint32 `arrayCount:"FirstUint16"`
+	nValues    uint16
+	Values     []uint32 `isOpaque:""`
+}
+
+// binary search tables and trimmed arrays are parsed by hand, since
+// fonts produced by older tools use various unit sizes, and
+// are not consistent regarding the termination unit
+
+// units returns the units of the binary search table starting at src[12:],
+// each one being [bs.unitSize] long.
+// [minUnitSize] is the size of a record, which must fit in a unit, and [keyWords]
+// is the number of leading uint16 set to 0xFFFF in the optional termination unit,
+// which is removed.
+// It also returns the length of the table.
+func (bs binSearchHeader) units(src []byte, minUnitSize, keyWords int) ([][]byte, int, error) {
+	unitSize, nUnits := int(bs.unitSize), int(bs.nUnits)
+	if unitSize < minUnitSize {
+		return nil, 0, fmt.Errorf("invalid AAT lookup unit size: %d", unitSize)
+	}
+	end := 12 + unitSize*nUnits
+	if L := len(src); L < end {
+		return nil, 0, fmt.Errorf("EOF: expected length: %d, got %d", end, L)
+	}
+	src = src[12:end]
+
+	if nUnits != 0 {
+		last, isTermination := src[(nUnits-1)*unitSize:], true
+		for i := 0; i < keyWords; i++ {
+			if binary.BigEndian.Uint16(last[2*i:]) != 0xFFFF {
+				isTermination = false
+			}
+		}
+		if isTermination {
+			nUnits--
+		}
+	}
+
+	out := make([][]byte, nUnits)
+	for i := range out {
+		out[i] = src[i*unitSize : (i+1)*unitSize]
+	}
+	return out, end, nil
+}
+
+func (lk *AATLoopkup2) parseRecords(src []byte) (int, error) {
+	units, n, err := lk.binSearchHeader.units(src, 6, 2)
+	if err != nil {
+		return 0, err
+	}
+	lk.Records = make([]LookupRecord2, len(units))
+	for i, unit := range units {
+		lk.Records[i].mustParse(unit)
+	}
+	return n, nil
+}
+
+func (lk *AATLoopkup4) parseRecords(src []byte) (int, error) {
+	units, n, err := lk.binSearchHeader.units(src, 6, 2)
+	if err != nil {
+		return 0, err
+	}
+	lk.Records = make([]AATLookupRecord4, len(units))
+	for i, unit := range units {
+		if lk.Records[i], _, err = ParseAATLookupRecord4(unit, src); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func (lk *AATLoopkup6) parseRecords(src []byte) (int, error) {
+	units, n, err := lk.binSearchHeader.units(src, 4, 1)
+	if err != nil {
+		return 0, err
+	}
+	lk.Records = make([]loopkupRecord6, len(units))
+	for i, unit := range units {
+		lk.Records[i].mustParse(unit)
+	}
+	return n, nil
+}
+
+func (lk *AATLoopkupExt2) parseRecords(src []byte) (int, error) {
+	units, n, err := lk.binSearchHeader.units(src, 8, 2)
+	if err != nil {
+		return 0, err
+	}
+	lk.Records = make([]lookupRecordExt2, len(units))
+	for i, unit := range units {
+		lk.Records[i].mustParse(unit)
+	}
+	return n, nil
+}
+
+func (lk *AATLoopkupExt4) parseRecords(src []byte) (int, error) {
+	units, n, err := lk.binSearchHeader.units(src, 6, 2)
+	if err != nil {
+		return 0, err
+	}
+	lk.Records = make([]loopkupRecordExt4, len(units))
+	for i, unit := range units {
+		if lk.Records[i], _, err = parseLoopkupRecordExt4(unit, src); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func (lk *AATLoopkupExt6) parseRecords(src []byte) (int, error) {
+	units, n, err := lk.binSearchHeader.units(src, 6, 1)
+	if err != nil {
+		return 0, err
+	}
+	lk.Records = make([]loopkupRecordExt6, len(units))
+	for i, unit := range units {
+		lk.Records[i].mustParse(unit)
+	}
+	return n, nil
+}
+
+// parseAATValues reads [count] values of [size] bytes (1, 2 or 4),
+// starting at src[8:], as used by format 10 lookups.
+func parseAATValues(src []byte, size, count int) ([]uint32, int, error) {
+	if size != 1 && size != 2 && size != 4 {
+		return nil, 0, fmt.Errorf("invalid AAT lookup value size: %d", size)
+	}
+	end := 8 + size*count
+	if L := len(src); L < end {
+		return nil, 0, fmt.Errorf("EOF: expected length: %d, got %d", end, L)
+	}
+	out := make([]uint32, count)
+	for i := range out {
+		switch v := src[8+i*size:]; size {
+		case 1:
+			out[i] = uint32(v[0])
+		case 2:
+			out[i] = uint32(binary.BigEndian.Uint16(v))
+		case 4:
+			out[i] = binary.BigEndian.Uint32(v)
+		}
+	}
+	return out, end, nil
+}
+
+func (lk *AATLoopkup10) parseValues(src []byte) (int, error) {
+	values, n, err := parseAATValues(src, int(lk.unitSize), int(lk.nValues))
+	if err != nil {
+		return 0, err
+	}
+	lk.Values = make([]uint16, len(values))
+	for i, v := range values {
+		lk.Values[i] = uint16(v)
+	}
+	return n, nil
+}
+
+func (lk *AATLoopkupExt10) parseValues(src []byte) (int, error) {
+	var (
+		n   int
+		err error
+	)
+	lk.Values, n, err = parseAATValues(src, int(lk.unitSize), int(lk.nValues))
+	return n, err
 }
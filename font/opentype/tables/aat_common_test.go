@@ -48,6 +48,82 @@ func TestAATLookup4(t *testing.T) {
 	tu.Assert(t, !found)
 }
 
+func TestAATLookupFormats(t *testing.T) {
+	type classes = map[GlyphID]uint16
+	for _, test := range []struct {
+		src      string
+		expected classes // other glyphs are not covered
+	}{
+		// format 2, with termination segment
+		{"0002 0006 0003 000C 0001 0006 " + "0005 0003 0007 " + "000A 0009 0008 " + "FFFF FFFF 0000", classes{3: 7, 4: 7, 5: 7, 9: 8, 10: 8}},
+		// format 2, without termination segment
+		{"0002 0006 0002 000C 0001 0000 " + "0005 0003 0007 " + "000A 0009 0008", classes{3: 7, 4: 7, 5: 7, 9: 8, 10: 8}},
+		// format 2, with padded units
+		{"0002 0008 0002 0010 0001 0000 " + "0005 0003 0007 0000 " + "000A 0009 0008 0000", classes{3: 7, 4: 7, 5: 7, 9: 8, 10: 8}},
+		// format 4, without termination segment, with a null offset
+		{"0004 0006 0002 000C 0001 0000 " + "0002 0001 0018 " + "0005 0004 0000 " + "0007 0008", classes{1: 7, 2: 8}},
+		// format 6, with termination unit
+		{"0006 0004 0003 0008 0001 0004 " + "0002 0005 " + "0010 0006 " + "FFFF 0000", classes{2: 5, 16: 6}},
+		// format 6, without termination unit
+		{"0006 0004 0002 0008 0001 0000 " + "0002 0005 " + "0010 0006", classes{2: 5, 16: 6}},
+		// format 8
+		{"0008 0004 0003 " + "0001 0000 0002", classes{4: 1, 5: 0, 6: 2}},
+		// format 8, reaching the last glyph
+		{"0008 FFFE 0002 " + "0001 0002", classes{0xFFFE: 1, 0xFFFF: 2}},
+		// format 10, with one byte values
+		{"000A 0001 0004 0003 " + "0102 03", classes{4: 1, 5: 2, 6: 3}},
+		// format 10, with two bytes values
+		{"000A 0002 0004 0002 " + "0001 0002", classes{4: 1, 5: 2}},
+		// format 10, with four bytes values
+		{"000A 0004 0004 0002 " + "0000 0001 0000 0002", classes{4: 1, 5: 2}},
+	} {
+		src := deHexStr(test.src)
+		lk, _, err := ParseAATLookup(src, 0)
+		tu.AssertNoErr(t, err)
+		// formats 8 and 10 have the same layout in extended lookups
+		var lkExt AATLookupExt
+		if format := src[1]; format == 8 || format == 10 {
+			lkExt, _, err = ParseAATLookupExt(src, 0)
+			tu.AssertNoErr(t, err)
+		}
+
+		for g := 0; g <= 0xFFFF; g++ {
+			exp, expOk := test.expected[GlyphID(g)]
+			got, ok := lk.Class(GlyphID(g))
+			tu.AssertC(t, ok == expOk && got == exp, test.src)
+			tu.AssertC(t, lk.ClassUint32(GlyphID(g)) == uint32(exp), test.src)
+			if lkExt != nil {
+				got, ok := lkExt.Class(GlyphID(g))
+				tu.AssertC(t, ok == expOk && got == uint32(exp), test.src)
+			}
+		}
+	}
+
+	// the termination segment of extended format 4 lookups is ignored
+	lkExt, _, err := ParseAATLookupExt(deHexStr("0004 0006 0002 000C 0001 0000 "+"0002 0001 0018 "+"FFFF FFFF FFFF "+"0000 0007 0001 0008"), 0)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, len(lkExt.(AATLoopkupExt4).Records) == 1)
+	c, ok := lkExt.Class(2)
+	tu.Assert(t, ok && c == 0x10008)
+
+	// invalid lookups are rejected
+	for _, src := range []string{
+		"0002 0006 0002 000C 0001 0000 " + "0005 0003 0007", // truncated
+		"0002 0004 0001 0004 0000 0000 " + "0005 0003",      // unit too small
+		"0004 0006 0002 000C 0001 0000 " + "0002 0001 0018", // truncated
+		"0004 0006 0001 0006 0000 0000 " + "0002 0001 0012", // values out of bounds
+		"0006 0004 0002 0008 0001 0000 " + "0002 0005",      // truncated
+		"0008 0004 0003 " + "0001 0000",                     // truncated
+		"000A 0003 0004 0001 " + "000001",                   // invalid value size
+		"000A 0002 0004 0002 " + "0001",                     // truncated
+	} {
+		_, _, err := ParseAATLookup(deHexStr(src), 0)
+		tu.AssertC(t, err != nil, src)
+		_, _, err = ParseAATLookupExt(deHexStr(src), 0)
+		tu.AssertC(t, err != nil, src)
+	}
+}
+
 func TestParseTrak(t *testing.T) {
 	fp := readFontFile(t, "toys/Trak.ttf")
 	trak, _, err := ParseTrak(readTable(t, fp, "trak"))
@@ -83,6 +159,19 @@ func TestParseFeat(t *testing.T) {
 		got := name.SettingTable
 		tu.Assert(t, reflect.DeepEqual(exp, got))
 	}
+
+	def, ok := feat.GetFeature(3).DefaultSetting()
+	tu.Assert(t, ok && def == 0)
+	_, ok = feat.GetFeature(1).DefaultSetting() // non exclusive
+	tu.Assert(t, !ok)
+
+	// explicit default index
+	fn := FeatureName{FeatureFlags: 0x8000 | 0x4000 | 2, SettingTable: []FeatureSettingName{{0, 0}, {1, 0}, {3, 0}}}
+	def, ok = fn.DefaultSetting()
+	tu.Assert(t, ok && def == 3)
+	fn.FeatureFlags = 0x8000 | 0x4000 | 5 // invalid index
+	_, ok = fn.DefaultSetting()
+	tu.Assert(t, !ok)
 }
 
 func TestParseAnkr(t *testing.T) {
@@ -104,20 +104,16 @@ func ParseAATLoopkupExt10(src []byte) (AATLoopkupExt10, int, error) {
 	item.version = binary.BigEndian.Uint16(src[0:])
 	item.unitSize = binary.BigEndian.Uint16(src[2:])
 	item.FirstGlyph = binary.BigEndian.Uint16(src[4:])
-	arrayLengthValues := int(binary.BigEndian.Uint16(src[6:]))
+	item.nValues = binary.BigEndian.Uint16(src[6:])
 	n += 8
 
 	{
 
-		if L := len(src); L < 8+arrayLengthValues*4 {
-			return item, 0, fmt.Errorf("reading AATLoopkupExt10: "+"EOF: expected length: %d, got %d", 8+arrayLengthValues*4, L)
-		}
-
-		item.Values = make([]uint32, arrayLengthValues) // allocation guarded by the previous check
-		for i := range item.Values {
-			item.Values[i] = binary.BigEndian.Uint32(src[8+i*4:])
+		read, err := item.parseValues(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading AATLoopkupExt10: %s", err)
 		}
-		n += arrayLengthValues * 4
+		n = read
 	}
 	return item, n, nil
 }
@@ -134,17 +130,12 @@ func ParseAATLoopkupExt2(src []byte) (AATLoopkupExt2, int, error) {
 	n += 12
 
 	{
-		arrayLength := int(item.nUnits)
 
-		if L := len(src); L < 12+arrayLength*8 {
-			return item, 0, fmt.Errorf("reading AATLoopkupExt2: "+"EOF: expected length: %d, got %d", 12+arrayLength*8, L)
-		}
-
-		item.Records = make([]lookupRecordExt2, arrayLength) // allocation guarded by the previous check
-		for i := range item.Records {
-			item.Records[i].mustParse(src[12+i*8:])
+		read, err := item.parseRecords(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading AATLoopkupExt2: %s", err)
 		}
-		n += arrayLength * 8
+		n = read
 	}
 	return item, n, nil
 }
@@ -161,18 +152,12 @@ func ParseAATLoopkupExt4(src []byte) (AATLoopkupExt4, int, error) {
 	n += 12
 
 	{
-		arrayLength := int(item.nUnits)
 
-		offset := 12
-		for i := 0; i < arrayLength; i++ {
-			elem, read, err := parseLoopkupRecordExt4(src[offset:], src)
-			if err != nil {
-				return item, 0, fmt.Errorf("reading AATLoopkupExt4: %s", err)
-			}
-			item.Records = append(item.Records, elem)
-			offset += read
+		read, err := item.parseRecords(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading AATLoopkupExt4: %s", err)
 		}
-		n = offset
+		n = read
 	}
 	return item, n, nil
 }
@@ -189,17 +174,12 @@ func ParseAATLoopkupExt6(src []byte) (AATLoopkupExt6, int, error) {
 	n += 12
 
 	{
-		arrayLength := int(item.nUnits)
 
-		if L := len(src); L < 12+arrayLength*6 {
-			return item, 0, fmt.Errorf("reading AATLoopkupExt6: "+"EOF: expected length: %d, got %d", 12+arrayLength*6, L)
-		}
-
-		item.Records = make([]loopkupRecordExt6, arrayLength) // allocation guarded by the previous check
-		for i := range item.Records {
-			item.Records[i].mustParse(src[12+i*6:])
+		read, err := item.parseRecords(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading AATLoopkupExt6: %s", err)
 		}
-		n += arrayLength * 6
+		n = read
 	}
 	return item, n, nil
 }
@@ -59,7 +59,11 @@ func (lk AATLoopkup4) Class(g GlyphID) (uint16, bool) {
 		} else if entry.LastGlyph < g {
 			i = h + 1
 		} else {
-			return entry.Values[g-entry.FirstGlyph], true
+			// Values may be missing (null offset)
+			if index := int(g - entry.FirstGlyph); index < len(entry.Values) {
+				return entry.Values[index], true
+			}
+			return 0, false
 		}
 	}
 	return 0, false
@@ -82,14 +86,14 @@ func (lk AATLoopkup6) Class(g GlyphID) (uint16, bool) {
 }
 
 func (lk AATLoopkup8Data) Class(g GlyphID) (uint16, bool) {
-	if g < lk.FirstGlyph || g >= lk.FirstGlyph+GlyphID(len(lk.Values)) {
+	if g < lk.FirstGlyph || int(g-lk.FirstGlyph) >= len(lk.Values) {
 		return 0, false
 	}
 	return lk.Values[g-lk.FirstGlyph], true
 }
 
 func (lk AATLoopkup10) Class(g GlyphID) (uint16, bool) {
-	if g < lk.FirstGlyph || g >= lk.FirstGlyph+GlyphID(len(lk.Values)) {
+	if g < lk.FirstGlyph || int(g-lk.FirstGlyph) >= len(lk.Values) {
 		return 0, false
 	}
 	return lk.Values[g-lk.FirstGlyph], true
@@ -145,7 +149,11 @@ func (lk AATLoopkupExt4) Class(g GlyphID) (uint32, bool) {
 		} else if entry.LastGlyph < g {
 			i = h + 1
 		} else {
-			return entry.Values[g-entry.FirstGlyph], true
+			// Values may be missing (null offset)
+			if index := int(g - entry.FirstGlyph); index < len(entry.Values) {
+				return entry.Values[index], true
+			}
+			return 0, false
 		}
 	}
 	return 0, false
@@ -173,7 +181,7 @@ func (lk AATLoopkupExt8) Class(g GlyphID) (uint32, bool) {
 }
 
 func (lk AATLoopkupExt10) Class(g GlyphID) (uint32, bool) {
-	if g < lk.FirstGlyph || g >= lk.FirstGlyph+GlyphID(len(lk.Values)) {
+	if g < lk.FirstGlyph || int(g-lk.FirstGlyph) >= len(lk.Values) {
 		return 0, false
 	}
 	return lk.Values[g-lk.FirstGlyph], true
@@ -266,3 +274,25 @@ func (feature *FeatureName) IsExclusive() bool {
 	const Exclusive = 0x8000
 	return feature.FeatureFlags&Exclusive != 0
 }
+
+// DefaultSetting returns the setting applied by default for an exclusive feature,
+// as indicated by the feature flags.
+// It returns false for non exclusive features (whose settings are toggled independently)
+// or invalid tables.
+func (feature *FeatureName) DefaultSetting() (uint16, bool) {
+	const (
+		notDefault = 0x4000
+		indexMask  = 0x00FF
+	)
+	if !feature.IsExclusive() {
+		return 0, false
+	}
+	index := 0
+	if feature.FeatureFlags&notDefault != 0 {
+		index = int(feature.FeatureFlags & indexMask)
+	}
+	if index >= len(feature.SettingTable) {
+		return 0, false
+	}
+	return feature.SettingTable[index].Setting, true
+}
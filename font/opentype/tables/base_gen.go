@@ -0,0 +1,429 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package tables
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Code generated by binarygen from base_src.go. DO NOT EDIT
+
+func (item *BaseCoordFormat1) mustParse(src []byte) {
+	_ = src[3] // early bound checking
+	item.format = binary.BigEndian.Uint16(src[0:])
+	item.Coordinate = int16(binary.BigEndian.Uint16(src[2:]))
+}
+
+func (item *BaseCoordFormat2) mustParse(src []byte) {
+	_ = src[7] // early bound checking
+	item.format = binary.BigEndian.Uint16(src[0:])
+	item.Coordinate = int16(binary.BigEndian.Uint16(src[2:]))
+	item.ReferenceGlyph = binary.BigEndian.Uint16(src[4:])
+	item.BaseCoordPoint = binary.BigEndian.Uint16(src[6:])
+}
+
+func ParseAxis(src []byte) (Axis, int, error) {
+	var item Axis
+	n := 0
+	if L := len(src); L < 4 {
+		return item, 0, fmt.Errorf("reading Axis: "+"EOF: expected length: 4, got %d", L)
+	}
+	_ = src[3] // early bound checking
+	offsetBaseTagList := int(binary.BigEndian.Uint16(src[0:]))
+	offsetBaseScriptList := int(binary.BigEndian.Uint16(src[2:]))
+	n += 4
+
+	{
+
+		if offsetBaseTagList != 0 { // ignore null offset
+			if L := len(src); L < offsetBaseTagList {
+				return item, 0, fmt.Errorf("reading Axis: "+"EOF: expected length: %d, got %d", offsetBaseTagList, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.BaseTagList, read, err = ParseBaseTagList(src[offsetBaseTagList:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading Axis: %s", err)
+			}
+			offsetBaseTagList += read
+		}
+	}
+	{
+
+		if offsetBaseScriptList != 0 { // ignore null offset
+			if L := len(src); L < offsetBaseScriptList {
+				return item, 0, fmt.Errorf("reading Axis: "+"EOF: expected length: %d, got %d", offsetBaseScriptList, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.BaseScriptList, read, err = ParseBaseScriptList(src[offsetBaseScriptList:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading Axis: %s", err)
+			}
+			offsetBaseScriptList += read
+		}
+	}
+	return item, n, nil
+}
+
+func ParseBASE(src []byte) (BASE, int, error) {
+	var item BASE
+	n := 0
+	if L := len(src); L < 8 {
+		return item, 0, fmt.Errorf("reading BASE: "+"EOF: expected length: 8, got %d", L)
+	}
+	_ = src[7] // early bound checking
+	item.majorVersion = binary.BigEndian.Uint16(src[0:])
+	item.minorVersion = binary.BigEndian.Uint16(src[2:])
+	offsetHorizAxis := int(binary.BigEndian.Uint16(src[4:]))
+	offsetVertAxis := int(binary.BigEndian.Uint16(src[6:]))
+	n += 8
+
+	{
+
+		if offsetHorizAxis != 0 { // ignore null offset
+			if L := len(src); L < offsetHorizAxis {
+				return item, 0, fmt.Errorf("reading BASE: "+"EOF: expected length: %d, got %d", offsetHorizAxis, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.HorizAxis, read, err = ParseAxis(src[offsetHorizAxis:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading BASE: %s", err)
+			}
+			offsetHorizAxis += read
+		}
+	}
+	{
+
+		if offsetVertAxis != 0 { // ignore null offset
+			if L := len(src); L < offsetVertAxis {
+				return item, 0, fmt.Errorf("reading BASE: "+"EOF: expected length: %d, got %d", offsetVertAxis, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.VertAxis, read, err = ParseAxis(src[offsetVertAxis:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading BASE: %s", err)
+			}
+			offsetVertAxis += read
+		}
+	}
+	{
+
+		read, err := item.parseItemVarStore(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading BASE: %s", err)
+		}
+		n = read
+	}
+	return item, n, nil
+}
+
+func ParseBaseCoord(src []byte) (BaseCoord, int, error) {
+	var item BaseCoord
+
+	if L := len(src); L < 2 {
+		return item, 0, fmt.Errorf("reading BaseCoord: "+"EOF: expected length: 2, got %d", L)
+	}
+	format := uint16(binary.BigEndian.Uint16(src[0:]))
+	var (
+		read int
+		err  error
+	)
+	switch format {
+	case 1:
+		item, read, err = ParseBaseCoordFormat1(src[0:])
+	case 2:
+		item, read, err = ParseBaseCoordFormat2(src[0:])
+	case 3:
+		item, read, err = ParseBaseCoordFormat3(src[0:])
+	default:
+		err = fmt.Errorf("unsupported BaseCoord format %d", format)
+	}
+	if err != nil {
+		return item, 0, fmt.Errorf("reading BaseCoord: %s", err)
+	}
+
+	return item, read, nil
+}
+
+func ParseBaseCoordFormat1(src []byte) (BaseCoordFormat1, int, error) {
+	var item BaseCoordFormat1
+	n := 0
+	if L := len(src); L < 4 {
+		return item, 0, fmt.Errorf("reading BaseCoordFormat1: "+"EOF: expected length: 4, got %d", L)
+	}
+	item.mustParse(src)
+	n += 4
+	return item, n, nil
+}
+
+func ParseBaseCoordFormat2(src []byte) (BaseCoordFormat2, int, error) {
+	var item BaseCoordFormat2
+	n := 0
+	if L := len(src); L < 8 {
+		return item, 0, fmt.Errorf("reading BaseCoordFormat2: "+"EOF: expected length: 8, got %d", L)
+	}
+	item.mustParse(src)
+	n += 8
+	return item, n, nil
+}
+
+func ParseBaseCoordFormat3(src []byte) (BaseCoordFormat3, int, error) {
+	var item BaseCoordFormat3
+	n := 0
+	if L := len(src); L < 6 {
+		return item, 0, fmt.Errorf("reading BaseCoordFormat3: "+"EOF: expected length: 6, got %d", L)
+	}
+	_ = src[5] // early bound checking
+	item.format = binary.BigEndian.Uint16(src[0:])
+	item.Coordinate = int16(binary.BigEndian.Uint16(src[2:]))
+	item.deviceOffset = Offset16(binary.BigEndian.Uint16(src[4:]))
+	n += 6
+
+	{
+
+		err := item.parseDevice(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading BaseCoordFormat3: %s", err)
+		}
+	}
+	return item, n, nil
+}
+
+func ParseBaseScript(src []byte) (BaseScript, int, error) {
+	var item BaseScript
+	n := 0
+	if L := len(src); L < 6 {
+		return item, 0, fmt.Errorf("reading BaseScript: "+"EOF: expected length: 6, got %d", L)
+	}
+	_ = src[5] // early bound checking
+	offsetBaseValues := int(binary.BigEndian.Uint16(src[0:]))
+	offsetDefaultMinMax := int(binary.BigEndian.Uint16(src[2:]))
+	arrayLengthBaseLangSysRecords := int(binary.BigEndian.Uint16(src[4:]))
+	n += 6
+
+	{
+
+		if offsetBaseValues != 0 { // ignore null offset
+			if L := len(src); L < offsetBaseValues {
+				return item, 0, fmt.Errorf("reading BaseScript: "+"EOF: expected length: %d, got %d", offsetBaseValues, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.BaseValues, read, err = ParseBaseValues(src[offsetBaseValues:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading BaseScript: %s", err)
+			}
+			offsetBaseValues += read
+		}
+	}
+	{
+
+		if offsetDefaultMinMax != 0 { // ignore null offset
+			if L := len(src); L < offsetDefaultMinMax {
+				return item, 0, fmt.Errorf("reading BaseScript: "+"EOF: expected length: %d, got %d", offsetDefaultMinMax, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.DefaultMinMax, read, err = ParseMinMax(src[offsetDefaultMinMax:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading BaseScript: %s", err)
+			}
+			offsetDefaultMinMax += read
+		}
+	}
+	{
+
+		if L := len(src); L < 6+arrayLengthBaseLangSysRecords*6 {
+			return item, 0, fmt.Errorf("reading BaseScript: "+"EOF: expected length: %d, got %d", 6+arrayLengthBaseLangSysRecords*6, L)
+		}
+
+		item.BaseLangSysRecords = make([]TagOffsetRecord, arrayLengthBaseLangSysRecords) // allocation guarded by the previous check
+		for i := range item.BaseLangSysRecords {
+			item.BaseLangSysRecords[i].mustParse(src[6+i*6:])
+		}
+		n += arrayLengthBaseLangSysRecords * 6
+	}
+	{
+
+		err := item.parseBaseLangSys(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading BaseScript: %s", err)
+		}
+	}
+	return item, n, nil
+}
+
+func ParseBaseScriptList(src []byte) (BaseScriptList, int, error) {
+	var item BaseScriptList
+	n := 0
+	if L := len(src); L < 2 {
+		return item, 0, fmt.Errorf("reading BaseScriptList: "+"EOF: expected length: 2, got %d", L)
+	}
+	arrayLengthRecords := int(binary.BigEndian.Uint16(src[0:]))
+	n += 2
+
+	{
+
+		if L := len(src); L < 2+arrayLengthRecords*6 {
+			return item, 0, fmt.Errorf("reading BaseScriptList: "+"EOF: expected length: %d, got %d", 2+arrayLengthRecords*6, L)
+		}
+
+		item.Records = make([]TagOffsetRecord, arrayLengthRecords) // allocation guarded by the previous check
+		for i := range item.Records {
+			item.Records[i].mustParse(src[2+i*6:])
+		}
+		n += arrayLengthRecords * 6
+	}
+	{
+
+		err := item.parseBaseScripts(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading BaseScriptList: %s", err)
+		}
+	}
+	return item, n, nil
+}
+
+func ParseBaseTagList(src []byte) (BaseTagList, int, error) {
+	var item BaseTagList
+	n := 0
+	if L := len(src); L < 2 {
+		return item, 0, fmt.Errorf("reading BaseTagList: "+"EOF: expected length: 2, got %d", L)
+	}
+	arrayLengthBaselineTags := int(binary.BigEndian.Uint16(src[0:]))
+	n += 2
+
+	{
+
+		if L := len(src); L < 2+arrayLengthBaselineTags*4 {
+			return item, 0, fmt.Errorf("reading BaseTagList: "+"EOF: expected length: %d, got %d", 2+arrayLengthBaselineTags*4, L)
+		}
+
+		item.BaselineTags = make([]Tag, arrayLengthBaselineTags) // allocation guarded by the previous check
+		for i := range item.BaselineTags {
+			item.BaselineTags[i] = Tag(binary.BigEndian.Uint32(src[2+i*4:]))
+		}
+		n += arrayLengthBaselineTags * 4
+	}
+	return item, n, nil
+}
+
+func ParseBaseValues(src []byte) (BaseValues, int, error) {
+	var item BaseValues
+	n := 0
+	if L := len(src); L < 4 {
+		return item, 0, fmt.Errorf("reading BaseValues: "+"EOF: expected length: 4, got %d", L)
+	}
+	_ = src[3] // early bound checking
+	item.DefaultBaselineIndex = binary.BigEndian.Uint16(src[0:])
+	arrayLengthBaseCoords := int(binary.BigEndian.Uint16(src[2:]))
+	n += 4
+
+	{
+
+		if L := len(src); L < 4+arrayLengthBaseCoords*2 {
+			return item, 0, fmt.Errorf("reading BaseValues: "+"EOF: expected length: %d, got %d", 4+arrayLengthBaseCoords*2, L)
+		}
+
+		item.BaseCoords = make([]BaseCoord, arrayLengthBaseCoords) // allocation guarded by the previous check
+		for i := range item.BaseCoords {
+			offset := int(binary.BigEndian.Uint16(src[4+i*2:]))
+			// ignore null offsets
+			if offset == 0 {
+				continue
+			}
+
+			if L := len(src); L < offset {
+				return item, 0, fmt.Errorf("reading BaseValues: "+"EOF: expected length: %d, got %d", offset, L)
+			}
+
+			var err error
+			item.BaseCoords[i], _, err = ParseBaseCoord(src[offset:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading BaseValues: %s", err)
+			}
+		}
+		n += arrayLengthBaseCoords * 2
+	}
+	return item, n, nil
+}
+
+func ParseMinMax(src []byte) (MinMax, int, error) {
+	var item MinMax
+	n := 0
+	if L := len(src); L < 6 {
+		return item, 0, fmt.Errorf("reading MinMax: "+"EOF: expected length: 6, got %d", L)
+	}
+	_ = src[5] // early bound checking
+	offsetMinCoord := int(binary.BigEndian.Uint16(src[0:]))
+	offsetMaxCoord := int(binary.BigEndian.Uint16(src[2:]))
+	n += 6
+
+	{
+
+		if offsetMinCoord != 0 { // ignore null offset
+			if L := len(src); L < offsetMinCoord {
+				return item, 0, fmt.Errorf("reading MinMax: "+"EOF: expected length: %d, got %d", offsetMinCoord, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.MinCoord, read, err = ParseBaseCoord(src[offsetMinCoord:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading MinMax: %s", err)
+			}
+			offsetMinCoord += read
+		}
+	}
+	{
+
+		if offsetMaxCoord != 0 { // ignore null offset
+			if L := len(src); L < offsetMaxCoord {
+				return item, 0, fmt.Errorf("reading MinMax: "+"EOF: expected length: %d, got %d", offsetMaxCoord, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.MaxCoord, read, err = ParseBaseCoord(src[offsetMaxCoord:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading MinMax: %s", err)
+			}
+			offsetMaxCoord += read
+		}
+	}
+	{
+
+		read, err := item.parseFeatMinMaxRecords(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading MinMax: %s", err)
+		}
+		n += read
+	}
+	return item, n, nil
+}
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package tables
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// BASE is the Baseline table
+// See https://learn.microsoft.com/en-us/typography/opentype/spec/base
+type BASE struct {
+	majorVersion uint16       // Major version of the BASE table, = 1
+	minorVersion uint16       // Minor version of the BASE table, = 0 or 1
+	HorizAxis    Axis         `offsetSize:"Offset16"` // Offset to horizontal Axis table, from beginning of BASE table (may be NULL)
+	VertAxis     Axis         `offsetSize:"Offset16"` // Offset to vertical Axis table, from beginning of BASE table (may be NULL)
+	ItemVarStore ItemVarStore `isOpaque:""`           // Offset to Item Variation Store table, from beginning of BASE table (may be null)
+}
+
+func (base *BASE) parseItemVarStore(src []byte) (int, error) {
+	const headerSize = 8
+	if base.minorVersion < 1 {
+		return 0, nil
+	}
+	if L := len(src); L < headerSize+4 {
+		return 0, fmt.Errorf("EOF: expected length: %d, got %d", headerSize+4, L)
+	}
+	offset := binary.BigEndian.Uint32(src[headerSize:])
+	if offset != 0 {
+		if L := len(src); L < int(offset) {
+			return 0, fmt.Errorf("EOF: expected length: %d, got %d", offset, L)
+		}
+		var err error
+		base.ItemVarStore, _, err = ParseItemVarStore(src[offset:])
+		if err != nil {
+			return 0, err
+		}
+	}
+	return headerSize + 4, nil
+}
+
+type Axis struct {
+	BaseTagList    BaseTagList    `offsetSize:"Offset16"` // Offset to BaseTagList table, from beginning of Axis table (may be NULL)
+	BaseScriptList BaseScriptList `offsetSize:"Offset16"` // Offset to BaseScriptList table, from beginning of Axis table
+}
+
+type BaseTagList struct {
+	BaselineTags []Tag `arrayCount:"FirstUint16"` // [baseTagCount] Array of 4-byte baseline identification tags — must be in alphabetical order
+}
+
+type BaseScriptList struct {
+	Records     []TagOffsetRecord `arrayCount:"FirstUint16"` // [baseScriptCount] Array of BaseScriptRecords, in alphabetical order by baseScriptTag
+	BaseScripts []BaseScript      `isOpaque:""`              // same length as Records
+}
+
+func (sl *BaseScriptList) parseBaseScripts(src []byte) error {
+	sl.BaseScripts = make([]BaseScript, len(sl.Records))
+	for i, rec := range sl.Records {
+		var err error
+		if L := len(src); L < int(rec.Offset) {
+			return fmt.Errorf("EOF: expected length: %d, got %d", rec.Offset, L)
+		}
+		sl.BaseScripts[i], _, err = ParseBaseScript(src[rec.Offset:])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type BaseScript struct {
+	BaseValues         BaseValues        `offsetSize:"Offset16"`    // Offset to BaseValues table, from beginning of BaseScript table (may be NULL)
+	DefaultMinMax      MinMax            `offsetSize:"Offset16"`    // Offset to MinMax table, from beginning of BaseScript table (may be NULL)
+	BaseLangSysRecords []TagOffsetRecord `arrayCount:"FirstUint16"` // [baseLangSysCount] Array of BaseLangSysRecords, in alphabetical order by BaseLangSysTag
+	BaseLangSys        []MinMax          `isOpaque:""`              // same length as BaseLangSysRecords
+}
+
+func (bs *BaseScript) parseBaseLangSys(src []byte) error {
+	bs.BaseLangSys = make([]MinMax, len(bs.BaseLangSysRecords))
+	for i, rec := range bs.BaseLangSysRecords {
+		var err error
+		if L := len(src); L < int(rec.Offset) {
+			return fmt.Errorf("EOF: expected length: %d, got %d", rec.Offset, L)
+		}
+		bs.BaseLangSys[i], _, err = ParseMinMax(src[rec.Offset:])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MinMax stores the minimum and maximum extents of a script or language system,
+// that is the extreme positions of the glyphs in the direction perpendicular to the text.
+type MinMax struct {
+	MinCoord          BaseCoord          `offsetSize:"Offset16"`    // Offset to BaseCoord table that defines the minimum extent value, from the beginning of MinMax table (may be NULL)
+	MaxCoord          BaseCoord          `offsetSize:"Offset16"`    // Offset to BaseCoord table that defines maximum extent value, from the beginning of MinMax table (may be NULL)
+	FeatMinMaxRecords []FeatMinMaxRecord `arrayCount:"FirstUint16"` // [featMinMaxCount] Array of FeatMinMaxRecords, in alphabetical order by featureTableTag
+}
+
+// FeatMinMaxRecord stores the extents modified by a feature.
+// Its offsets are from the beginning of the parent [MinMax] table.
+type FeatMinMaxRecord struct {
+	FeatureTableTag Tag       // 4-byte feature identification tag — must match feature tag in FeatureList
+	MinCoord        BaseCoord `isOpaque:""` // Offset to BaseCoord table that defines the minimum extent value, from beginning of MinMax table (may be NULL)
+	MaxCoord        BaseCoord `isOpaque:""` // Offset to BaseCoord table that defines the maximum extent value, from beginning of MinMax table (may be NULL)
+}
+
+func (mm *MinMax) parseFeatMinMaxRecords(src []byte) (int, error) {
+	const headerSize = 6
+	if L := len(src); L < headerSize {
+		return 0, fmt.Errorf("EOF: expected length: %d, got %d", headerSize, L)
+	}
+	count := int(binary.BigEndian.Uint16(src[4:]))
+	if L := len(src); L < headerSize+count*8 {
+		return 0, fmt.Errorf("EOF: expected length: %d, got %d", headerSize+count*8, L)
+	}
+	mm.FeatMinMaxRecords = make([]FeatMinMaxRecord, count)
+	for i := range mm.FeatMinMaxRecords {
+		record := src[headerSize+i*8:]
+		mm.FeatMinMaxRecords[i].FeatureTableTag = Tag(binary.BigEndian.Uint32(record))
+		for j, coord := range [2]*BaseCoord{&mm.FeatMinMaxRecords[i].MinCoord, &mm.FeatMinMaxRecords[i].MaxCoord} {
+			offset := int(binary.BigEndian.Uint16(record[4+2*j:]))
+			if offset == 0 { // ignore null offsets
+				continue
+			}
+			if L := len(src); L < offset {
+				return 0, fmt.Errorf("EOF: expected length: %d, got %d", offset, L)
+			}
+			var err error
+			*coord, _, err = ParseBaseCoord(src[offset:])
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	return count * 8, nil
+}
+
+type BaseValues struct {
+	DefaultBaselineIndex uint16      // Index number of default baseline for this script — equals index position of baseline tag in baselineTags array of the BaseTagList
+	BaseCoords           []BaseCoord `arrayCount:"FirstUint16" offsetsArray:"Offset16"` // [baseCoordCount] Array of offsets to BaseCoord tables, from beginning of BaseValues table — order matches baselineTags array in the BaseTagList
+}
+
+type BaseCoord interface {
+	isBaseCoord()
+}
+
+func (BaseCoordFormat1) isBaseCoord() {}
+func (BaseCoordFormat2) isBaseCoord() {}
+func (BaseCoordFormat3) isBaseCoord() {}
+
+type BaseCoordFormat1 struct {
+	format     uint16 `unionTag:"1"` // Format identifier — format = 1
+	Coordinate int16  // X or Y value, in design units
+}
+
+type BaseCoordFormat2 struct {
+	format         uint16 `unionTag:"2"` // Format identifier — format = 2
+	Coordinate     int16  // X or Y value, in design units
+	ReferenceGlyph uint16 // Glyph ID of control glyph
+	BaseCoordPoint uint16 // Index of contour point on the reference glyph
+}
+
+type BaseCoordFormat3 struct {
+	format       uint16      `unionTag:"3"` // Format identifier — format = 3
+	Coordinate   int16       // X or Y value, in design units
+	deviceOffset Offset16    // Offset to Device table (non-variable font) / Variation Index table (variable font) for X or Y value, from beginning of BaseCoord table (may be NULL).
+	Device       DeviceTable `isOpaque:""`
+}
+
+func (bc *BaseCoordFormat3) parseDevice(src []byte) (err error) {
+	if bc.deviceOffset == 0 {
+		return nil
+	}
+	bc.Device, err = parseDeviceTable(src, uint16(bc.deviceOffset))
+	return err
+}
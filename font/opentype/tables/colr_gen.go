@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package tables
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Code generated by binarygen from colr_src.go. DO NOT EDIT
+
+func (item *BaseGlyphRecord) mustParse(src []byte) {
+	_ = src[5] // early bound checking
+	item.GlyphID = GlyphID(binary.BigEndian.Uint16(src[0:]))
+	item.FirstLayerIndex = binary.BigEndian.Uint16(src[2:])
+	item.NumLayers = binary.BigEndian.Uint16(src[4:])
+}
+
+func ParseCOLR(src []byte) (COLR, int, error) {
+	var item COLR
+	n := 0
+	if L := len(src); L < 14 {
+		return item, 0, fmt.Errorf("reading COLR: "+"EOF: expected length: 14, got %d", L)
+	}
+	_ = src[13] // early bound checking
+	item.Version = binary.BigEndian.Uint16(src[0:])
+	item.numBaseGlyphRecords = binary.BigEndian.Uint16(src[2:])
+	offsetBaseGlyphRecords := int(binary.BigEndian.Uint32(src[4:]))
+	offsetLayerRecords := int(binary.BigEndian.Uint32(src[8:]))
+	item.numLayerRecords = binary.BigEndian.Uint16(src[12:])
+	n += 14
+
+	{
+
+		if offsetBaseGlyphRecords != 0 { // ignore null offset
+			if L := len(src); L < offsetBaseGlyphRecords {
+				return item, 0, fmt.Errorf("reading COLR: "+"EOF: expected length: %d, got %d", offsetBaseGlyphRecords, L)
+			}
+
+			arrayLength := int(item.numBaseGlyphRecords)
+
+			if L := len(src); L < offsetBaseGlyphRecords+arrayLength*6 {
+				return item, 0, fmt.Errorf("reading COLR: "+"EOF: expected length: %d, got %d", offsetBaseGlyphRecords+arrayLength*6, L)
+			}
+
+			item.BaseGlyphRecords = make([]BaseGlyphRecord, arrayLength) // allocation guarded by the previous check
+			for i := range item.BaseGlyphRecords {
+				item.BaseGlyphRecords[i].mustParse(src[offsetBaseGlyphRecords+i*6:])
+			}
+			offsetBaseGlyphRecords += arrayLength * 6
+		}
+	}
+	{
+
+		if offsetLayerRecords != 0 { // ignore null offset
+			if L := len(src); L < offsetLayerRecords {
+				return item, 0, fmt.Errorf("reading COLR: "+"EOF: expected length: %d, got %d", offsetLayerRecords, L)
+			}
+
+			arrayLength := int(item.numLayerRecords)
+
+			if L := len(src); L < offsetLayerRecords+arrayLength*4 {
+				return item, 0, fmt.Errorf("reading COLR: "+"EOF: expected length: %d, got %d", offsetLayerRecords+arrayLength*4, L)
+			}
+
+			item.LayerRecords = make([]LayerRecord, arrayLength) // allocation guarded by the previous check
+			for i := range item.LayerRecords {
+				item.LayerRecords[i].mustParse(src[offsetLayerRecords+i*4:])
+			}
+			offsetLayerRecords += arrayLength * 4
+		}
+	}
+	return item, n, nil
+}
+
+func (item *LayerRecord) mustParse(src []byte) {
+	_ = src[3] // early bound checking
+	item.GlyphID = GlyphID(binary.BigEndian.Uint16(src[0:]))
+	item.PaletteIndex = binary.BigEndian.Uint16(src[2:])
+}
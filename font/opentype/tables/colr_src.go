@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package tables
+
+// COLR is the Color table. Only the version 0 layers are supported.
+// See https://learn.microsoft.com/en-us/typography/opentype/spec/colr
+type COLR struct {
+	Version             uint16            // Table version number
+	numBaseGlyphRecords uint16            // Number of BaseGlyph records.
+	BaseGlyphRecords    []BaseGlyphRecord `offsetSize:"Offset32" arrayCount:"ComputedField-numBaseGlyphRecords"` // Offset to baseGlyphRecords array.
+	LayerRecords        []LayerRecord     `offsetSize:"Offset32" arrayCount:"ComputedField-numLayerRecords"`     // Offset to layerRecords array.
+	numLayerRecords     uint16            // Number of Layer records.
+}
+
+// BaseGlyphRecord associates a glyph with its layers.
+type BaseGlyphRecord struct {
+	GlyphID         GlyphID // Glyph ID of the base glyph.
+	FirstLayerIndex uint16  // Index (base 0) into the layerRecords array.
+	NumLayers       uint16  // Number of color layers associated with this glyph.
+}
+
+// LayerRecord is one layer of a color glyph.
+type LayerRecord struct {
+	GlyphID      GlyphID // Glyph ID of the glyph used for a given layer.
+	PaletteIndex uint16  // Index (base 0) for a palette entry in the CPAL table.
+}
+
+// Layers returns the layers for [glyph], in drawing order
+// (bottom first), or nil if [glyph] is not a color glyph.
+func (colr COLR) Layers(glyph GlyphID) []LayerRecord {
+	for i, j := 0, len(colr.BaseGlyphRecords); i < j; {
+		h := i + (j-i)/2
+		entry := colr.BaseGlyphRecords[h]
+		if glyph < entry.GlyphID {
+			j = h
+		} else if entry.GlyphID < glyph {
+			i = h + 1
+		} else {
+			start, end := int(entry.FirstLayerIndex), int(entry.FirstLayerIndex)+int(entry.NumLayers)
+			if end > len(colr.LayerRecords) { // invalid table
+				return nil
+			}
+			return colr.LayerRecords[start:end]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package tables
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Code generated by binarygen from cpal_src.go. DO NOT EDIT
+
+func ParseCPAL(src []byte) (CPAL, int, error) {
+	var item CPAL
+	n := 0
+	if L := len(src); L < 12 {
+		return item, 0, fmt.Errorf("reading CPAL: "+"EOF: expected length: 12, got %d", L)
+	}
+	_ = src[11] // early bound checking
+	item.Version = binary.BigEndian.Uint16(src[0:])
+	item.NumPaletteEntries = binary.BigEndian.Uint16(src[2:])
+	item.numPalettes = binary.BigEndian.Uint16(src[4:])
+	item.numColorRecords = binary.BigEndian.Uint16(src[6:])
+	offsetColorRecords := int(binary.BigEndian.Uint32(src[8:]))
+	n += 12
+
+	{
+
+		if offsetColorRecords != 0 { // ignore null offset
+			if L := len(src); L < offsetColorRecords {
+				return item, 0, fmt.Errorf("reading CPAL: "+"EOF: expected length: %d, got %d", offsetColorRecords, L)
+			}
+
+			arrayLength := int(item.numColorRecords)
+
+			if L := len(src); L < offsetColorRecords+arrayLength*4 {
+				return item, 0, fmt.Errorf("reading CPAL: "+"EOF: expected length: %d, got %d", offsetColorRecords+arrayLength*4, L)
+			}
+
+			item.ColorRecords = make([]ColorRecord, arrayLength) // allocation guarded by the previous check
+			for i := range item.ColorRecords {
+				item.ColorRecords[i].mustParse(src[offsetColorRecords+i*4:])
+			}
+			offsetColorRecords += arrayLength * 4
+		}
+	}
+	{
+		arrayLength := int(item.numPalettes)
+
+		if L := len(src); L < 12+arrayLength*2 {
+			return item, 0, fmt.Errorf("reading CPAL: "+"EOF: expected length: %d, got %d", 12+arrayLength*2, L)
+		}
+
+		item.ColorRecordIndices = make([]uint16, arrayLength) // allocation guarded by the previous check
+		for i := range item.ColorRecordIndices {
+			item.ColorRecordIndices[i] = binary.BigEndian.Uint16(src[12+i*2:])
+		}
+		n += arrayLength * 2
+	}
+	{
+
+		read, err := item.parsePaletteTypes(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading CPAL: %s", err)
+		}
+		n += read
+	}
+	{
+
+		read, err := item.parsePaletteLabels(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading CPAL: %s", err)
+		}
+		n += read
+	}
+	{
+
+		read, err := item.parsePaletteEntryLabels(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading CPAL: %s", err)
+		}
+		n += read
+	}
+	return item, n, nil
+}
+
+func (item *ColorRecord) mustParse(src []byte) {
+	_ = src[3] // early bound checking
+	item.Blue = src[0]
+	item.Green = src[1]
+	item.Red = src[2]
+	item.Alpha = src[3]
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package tables
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// CPAL is the Color Palette table.
+// See https://learn.microsoft.com/en-us/typography/opentype/spec/cpal
+type CPAL struct {
+	Version            uint16        // Table version number
+	NumPaletteEntries  uint16        // Number of palette entries in each palette.
+	numPalettes        uint16        // Number of palettes in the table.
+	numColorRecords    uint16        // Total number of color records, combined for all palettes.
+	ColorRecords       []ColorRecord `offsetSize:"Offset32" arrayCount:"ComputedField-numColorRecords"` // Offset from the beginning of CPAL table to the first ColorRecord.
+	ColorRecordIndices []uint16      `arrayCount:"ComputedField-numPalettes"`                           // Index of each palette’s first color record in the combined color record array.
+
+	// The following fields are only present in version 1,
+	// and have either zero length or numPalettes (or NumPaletteEntries) length.
+
+	PaletteTypes       []uint32 `isOpaque:""` // [numPalettes] palette flags
+	PaletteLabels      []NameID `isOpaque:""` // [numPalettes] 'name' table IDs, or 0xFFFF if no name is provided
+	PaletteEntryLabels []NameID `isOpaque:""` // [NumPaletteEntries] 'name' table IDs, or 0xFFFF if no name is provided
+}
+
+// ColorRecord is a color in the sRGB color space,
+// with a non premultiplied alpha.
+type ColorRecord struct {
+	Blue, Green, Red, Alpha uint8
+}
+
+// v1Offset returns the offset of the version 1 field at [index],
+// which are stored after the color record indices, or 0 for version 0 tables.
+func (cp *CPAL) v1Offset(src []byte, index int) (int, error) {
+	if cp.Version < 1 {
+		return 0, nil
+	}
+	start := 12 + 2*int(cp.numPalettes) + 4*index
+	if L := len(src); L < start+4 {
+		return 0, fmt.Errorf("EOF: expected length: %d, got %d", start+4, L)
+	}
+	return int(binary.BigEndian.Uint32(src[start:])), nil
+}
+
+func (cp *CPAL) parsePaletteTypes(src []byte) (int, error) {
+	offset, err := cp.v1Offset(src, 0)
+	if err != nil || offset == 0 {
+		return 0, err
+	}
+	count := int(cp.numPalettes)
+	if L := len(src); L < offset+4*count {
+		return 0, fmt.Errorf("EOF: expected length: %d, got %d", offset+4*count, L)
+	}
+	cp.PaletteTypes = make([]uint32, count)
+	for i := range cp.PaletteTypes {
+		cp.PaletteTypes[i] = binary.BigEndian.Uint32(src[offset+4*i:])
+	}
+	return 0, nil
+}
+
+func (cp *CPAL) parsePaletteLabels(src []byte) (int, error) {
+	offset, err := cp.v1Offset(src, 1)
+	if err != nil || offset == 0 {
+		return 0, err
+	}
+	cp.PaletteLabels, err = parseNameIDs(src, offset, int(cp.numPalettes))
+	return 0, err
+}
+
+func (cp *CPAL) parsePaletteEntryLabels(src []byte) (int, error) {
+	offset, err := cp.v1Offset(src, 2)
+	if err != nil || offset == 0 {
+		return 0, err
+	}
+	cp.PaletteEntryLabels, err = parseNameIDs(src, offset, int(cp.NumPaletteEntries))
+	return 0, err
+}
+
+func parseNameIDs(src []byte, offset, count int) ([]NameID, error) {
+	if L := len(src); L < offset+2*count {
+		return nil, fmt.Errorf("EOF: expected length: %d, got %d", offset+2*count, L)
+	}
+	out := make([]NameID, count)
+	for i := range out {
+		out[i] = NameID(binary.BigEndian.Uint16(src[offset+2*i:]))
+	}
+	return out, nil
+}
+
+// Palette returns the colors of the palette at [index],
+// or nil if [index] is out of range or the table is invalid.
+func (cp CPAL) Palette(index int) []ColorRecord {
+	if index < 0 || index >= len(cp.ColorRecordIndices) {
+		return nil
+	}
+	start := int(cp.ColorRecordIndices[index])
+	end := start + int(cp.NumPaletteEntries)
+	if end > len(cp.ColorRecords) {
+		return nil
+	}
+	return cp.ColorRecords[start:end]
+}
@@ -27,6 +27,10 @@ type Head struct {
 	glyphDataFormat    int16
 }
 
+// FontRevision returns the 'fontRevision' field, set by the font manufacturer,
+// converted from its 16.16 fixed point format.
+func (head *Head) FontRevision() float32 { return float32(int32(head.fontRevision)) / (1 << 16) }
+
 // Upem returns a sanitize version of the 'UnitsPerEm' field.
 func (head *Head) Upem() uint16 {
 	if head.UnitsPerEm < 16 || head.UnitsPerEm > 16384 {
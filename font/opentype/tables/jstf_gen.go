@@ -0,0 +1,440 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package tables
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Code generated by binarygen from jstf_src.go. DO NOT EDIT
+
+func ParseExtenderGlyph(src []byte) (ExtenderGlyph, int, error) {
+	var item ExtenderGlyph
+	n := 0
+	if L := len(src); L < 2 {
+		return item, 0, fmt.Errorf("reading ExtenderGlyph: "+"EOF: expected length: 2, got %d", L)
+	}
+	arrayLengthGlyphs := int(binary.BigEndian.Uint16(src[0:]))
+	n += 2
+
+	{
+
+		if L := len(src); L < 2+arrayLengthGlyphs*2 {
+			return item, 0, fmt.Errorf("reading ExtenderGlyph: "+"EOF: expected length: %d, got %d", 2+arrayLengthGlyphs*2, L)
+		}
+
+		item.Glyphs = make([]GlyphID, arrayLengthGlyphs) // allocation guarded by the previous check
+		for i := range item.Glyphs {
+			item.Glyphs[i] = binary.BigEndian.Uint16(src[2+i*2:])
+		}
+		n += arrayLengthGlyphs * 2
+	}
+	return item, n, nil
+}
+
+func ParseJSTF(src []byte) (JSTF, int, error) {
+	var item JSTF
+	n := 0
+	if L := len(src); L < 6 {
+		return item, 0, fmt.Errorf("reading JSTF: "+"EOF: expected length: 6, got %d", L)
+	}
+	_ = src[5] // early bound checking
+	item.majorVersion = binary.BigEndian.Uint16(src[0:])
+	item.minorVersion = binary.BigEndian.Uint16(src[2:])
+	arrayLengthScriptRecords := int(binary.BigEndian.Uint16(src[4:]))
+	n += 6
+
+	{
+
+		if L := len(src); L < 6+arrayLengthScriptRecords*6 {
+			return item, 0, fmt.Errorf("reading JSTF: "+"EOF: expected length: %d, got %d", 6+arrayLengthScriptRecords*6, L)
+		}
+
+		item.ScriptRecords = make([]TagOffsetRecord, arrayLengthScriptRecords) // allocation guarded by the previous check
+		for i := range item.ScriptRecords {
+			item.ScriptRecords[i].mustParse(src[6+i*6:])
+		}
+		n += arrayLengthScriptRecords * 6
+	}
+	{
+
+		err := item.parseScripts(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading JSTF: %s", err)
+		}
+	}
+	return item, n, nil
+}
+
+func ParseJstfLangSys(src []byte) (JstfLangSys, int, error) {
+	var item JstfLangSys
+	n := 0
+	if L := len(src); L < 2 {
+		return item, 0, fmt.Errorf("reading JstfLangSys: "+"EOF: expected length: 2, got %d", L)
+	}
+	arrayLengthPriorities := int(binary.BigEndian.Uint16(src[0:]))
+	n += 2
+
+	{
+
+		if L := len(src); L < 2+arrayLengthPriorities*2 {
+			return item, 0, fmt.Errorf("reading JstfLangSys: "+"EOF: expected length: %d, got %d", 2+arrayLengthPriorities*2, L)
+		}
+
+		item.Priorities = make([]JstfPriority, arrayLengthPriorities) // allocation guarded by the previous check
+		for i := range item.Priorities {
+			offset := int(binary.BigEndian.Uint16(src[2+i*2:]))
+			// ignore null offsets
+			if offset == 0 {
+				continue
+			}
+
+			if L := len(src); L < offset {
+				return item, 0, fmt.Errorf("reading JstfLangSys: "+"EOF: expected length: %d, got %d", offset, L)
+			}
+
+			var err error
+			item.Priorities[i], _, err = ParseJstfPriority(src[offset:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading JstfLangSys: %s", err)
+			}
+		}
+		n += arrayLengthPriorities * 2
+	}
+	return item, n, nil
+}
+
+func ParseJstfMax(src []byte) (JstfMax, int, error) {
+	var item JstfMax
+	n := 0
+	if L := len(src); L < 2 {
+		return item, 0, fmt.Errorf("reading JstfMax: "+"EOF: expected length: 2, got %d", L)
+	}
+	arrayLengthLookups := int(binary.BigEndian.Uint16(src[0:]))
+	n += 2
+
+	{
+
+		if L := len(src); L < 2+arrayLengthLookups*2 {
+			return item, 0, fmt.Errorf("reading JstfMax: "+"EOF: expected length: %d, got %d", 2+arrayLengthLookups*2, L)
+		}
+
+		item.Lookups = make([]Lookup, arrayLengthLookups) // allocation guarded by the previous check
+		for i := range item.Lookups {
+			offset := int(binary.BigEndian.Uint16(src[2+i*2:]))
+			// ignore null offsets
+			if offset == 0 {
+				continue
+			}
+
+			if L := len(src); L < offset {
+				return item, 0, fmt.Errorf("reading JstfMax: "+"EOF: expected length: %d, got %d", offset, L)
+			}
+
+			var err error
+			item.Lookups[i], _, err = ParseLookup(src[offset:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading JstfMax: %s", err)
+			}
+		}
+		n += arrayLengthLookups * 2
+	}
+	return item, n, nil
+}
+
+func ParseJstfModList(src []byte) (JstfModList, int, error) {
+	var item JstfModList
+	n := 0
+	if L := len(src); L < 2 {
+		return item, 0, fmt.Errorf("reading JstfModList: "+"EOF: expected length: 2, got %d", L)
+	}
+	arrayLengthLookupIndices := int(binary.BigEndian.Uint16(src[0:]))
+	n += 2
+
+	{
+
+		if L := len(src); L < 2+arrayLengthLookupIndices*2 {
+			return item, 0, fmt.Errorf("reading JstfModList: "+"EOF: expected length: %d, got %d", 2+arrayLengthLookupIndices*2, L)
+		}
+
+		item.LookupIndices = make([]uint16, arrayLengthLookupIndices) // allocation guarded by the previous check
+		for i := range item.LookupIndices {
+			item.LookupIndices[i] = binary.BigEndian.Uint16(src[2+i*2:])
+		}
+		n += arrayLengthLookupIndices * 2
+	}
+	return item, n, nil
+}
+
+func ParseJstfPriority(src []byte) (JstfPriority, int, error) {
+	var item JstfPriority
+	n := 0
+	if L := len(src); L < 20 {
+		return item, 0, fmt.Errorf("reading JstfPriority: "+"EOF: expected length: 20, got %d", L)
+	}
+	_ = src[19] // early bound checking
+	offsetGSUBShrinkageEnable := int(binary.BigEndian.Uint16(src[0:]))
+	offsetGSUBShrinkageDisable := int(binary.BigEndian.Uint16(src[2:]))
+	offsetGPOSShrinkageEnable := int(binary.BigEndian.Uint16(src[4:]))
+	offsetGPOSShrinkageDisable := int(binary.BigEndian.Uint16(src[6:]))
+	offsetShrinkageJstfMax := int(binary.BigEndian.Uint16(src[8:]))
+	offsetGSUBExtensionEnable := int(binary.BigEndian.Uint16(src[10:]))
+	offsetGSUBExtensionDisable := int(binary.BigEndian.Uint16(src[12:]))
+	offsetGPOSExtensionEnable := int(binary.BigEndian.Uint16(src[14:]))
+	offsetGPOSExtensionDisable := int(binary.BigEndian.Uint16(src[16:]))
+	offsetExtensionJstfMax := int(binary.BigEndian.Uint16(src[18:]))
+	n += 20
+
+	{
+
+		if offsetGSUBShrinkageEnable != 0 { // ignore null offset
+			if L := len(src); L < offsetGSUBShrinkageEnable {
+				return item, 0, fmt.Errorf("reading JstfPriority: "+"EOF: expected length: %d, got %d", offsetGSUBShrinkageEnable, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.GSUBShrinkageEnable, read, err = ParseJstfModList(src[offsetGSUBShrinkageEnable:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading JstfPriority: %s", err)
+			}
+			offsetGSUBShrinkageEnable += read
+		}
+	}
+	{
+
+		if offsetGSUBShrinkageDisable != 0 { // ignore null offset
+			if L := len(src); L < offsetGSUBShrinkageDisable {
+				return item, 0, fmt.Errorf("reading JstfPriority: "+"EOF: expected length: %d, got %d", offsetGSUBShrinkageDisable, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.GSUBShrinkageDisable, read, err = ParseJstfModList(src[offsetGSUBShrinkageDisable:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading JstfPriority: %s", err)
+			}
+			offsetGSUBShrinkageDisable += read
+		}
+	}
+	{
+
+		if offsetGPOSShrinkageEnable != 0 { // ignore null offset
+			if L := len(src); L < offsetGPOSShrinkageEnable {
+				return item, 0, fmt.Errorf("reading JstfPriority: "+"EOF: expected length: %d, got %d", offsetGPOSShrinkageEnable, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.GPOSShrinkageEnable, read, err = ParseJstfModList(src[offsetGPOSShrinkageEnable:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading JstfPriority: %s", err)
+			}
+			offsetGPOSShrinkageEnable += read
+		}
+	}
+	{
+
+		if offsetGPOSShrinkageDisable != 0 { // ignore null offset
+			if L := len(src); L < offsetGPOSShrinkageDisable {
+				return item, 0, fmt.Errorf("reading JstfPriority: "+"EOF: expected length: %d, got %d", offsetGPOSShrinkageDisable, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.GPOSShrinkageDisable, read, err = ParseJstfModList(src[offsetGPOSShrinkageDisable:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading JstfPriority: %s", err)
+			}
+			offsetGPOSShrinkageDisable += read
+		}
+	}
+	{
+
+		if offsetShrinkageJstfMax != 0 { // ignore null offset
+			if L := len(src); L < offsetShrinkageJstfMax {
+				return item, 0, fmt.Errorf("reading JstfPriority: "+"EOF: expected length: %d, got %d", offsetShrinkageJstfMax, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.ShrinkageJstfMax, read, err = ParseJstfMax(src[offsetShrinkageJstfMax:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading JstfPriority: %s", err)
+			}
+			offsetShrinkageJstfMax += read
+		}
+	}
+	{
+
+		if offsetGSUBExtensionEnable != 0 { // ignore null offset
+			if L := len(src); L < offsetGSUBExtensionEnable {
+				return item, 0, fmt.Errorf("reading JstfPriority: "+"EOF: expected length: %d, got %d", offsetGSUBExtensionEnable, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.GSUBExtensionEnable, read, err = ParseJstfModList(src[offsetGSUBExtensionEnable:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading JstfPriority: %s", err)
+			}
+			offsetGSUBExtensionEnable += read
+		}
+	}
+	{
+
+		if offsetGSUBExtensionDisable != 0 { // ignore null offset
+			if L := len(src); L < offsetGSUBExtensionDisable {
+				return item, 0, fmt.Errorf("reading JstfPriority: "+"EOF: expected length: %d, got %d", offsetGSUBExtensionDisable, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.GSUBExtensionDisable, read, err = ParseJstfModList(src[offsetGSUBExtensionDisable:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading JstfPriority: %s", err)
+			}
+			offsetGSUBExtensionDisable += read
+		}
+	}
+	{
+
+		if offsetGPOSExtensionEnable != 0 { // ignore null offset
+			if L := len(src); L < offsetGPOSExtensionEnable {
+				return item, 0, fmt.Errorf("reading JstfPriority: "+"EOF: expected length: %d, got %d", offsetGPOSExtensionEnable, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.GPOSExtensionEnable, read, err = ParseJstfModList(src[offsetGPOSExtensionEnable:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading JstfPriority: %s", err)
+			}
+			offsetGPOSExtensionEnable += read
+		}
+	}
+	{
+
+		if offsetGPOSExtensionDisable != 0 { // ignore null offset
+			if L := len(src); L < offsetGPOSExtensionDisable {
+				return item, 0, fmt.Errorf("reading JstfPriority: "+"EOF: expected length: %d, got %d", offsetGPOSExtensionDisable, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.GPOSExtensionDisable, read, err = ParseJstfModList(src[offsetGPOSExtensionDisable:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading JstfPriority: %s", err)
+			}
+			offsetGPOSExtensionDisable += read
+		}
+	}
+	{
+
+		if offsetExtensionJstfMax != 0 { // ignore null offset
+			if L := len(src); L < offsetExtensionJstfMax {
+				return item, 0, fmt.Errorf("reading JstfPriority: "+"EOF: expected length: %d, got %d", offsetExtensionJstfMax, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.ExtensionJstfMax, read, err = ParseJstfMax(src[offsetExtensionJstfMax:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading JstfPriority: %s", err)
+			}
+			offsetExtensionJstfMax += read
+		}
+	}
+	return item, n, nil
+}
+
+func ParseJstfScript(src []byte) (JstfScript, int, error) {
+	var item JstfScript
+	n := 0
+	if L := len(src); L < 6 {
+		return item, 0, fmt.Errorf("reading JstfScript: "+"EOF: expected length: 6, got %d", L)
+	}
+	_ = src[5] // early bound checking
+	offsetExtenderGlyph := int(binary.BigEndian.Uint16(src[0:]))
+	offsetDefaultLangSys := int(binary.BigEndian.Uint16(src[2:]))
+	arrayLengthLangSysRecords := int(binary.BigEndian.Uint16(src[4:]))
+	n += 6
+
+	{
+
+		if offsetExtenderGlyph != 0 { // ignore null offset
+			if L := len(src); L < offsetExtenderGlyph {
+				return item, 0, fmt.Errorf("reading JstfScript: "+"EOF: expected length: %d, got %d", offsetExtenderGlyph, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.ExtenderGlyph, read, err = ParseExtenderGlyph(src[offsetExtenderGlyph:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading JstfScript: %s", err)
+			}
+			offsetExtenderGlyph += read
+		}
+	}
+	{
+
+		if offsetDefaultLangSys != 0 { // ignore null offset
+			if L := len(src); L < offsetDefaultLangSys {
+				return item, 0, fmt.Errorf("reading JstfScript: "+"EOF: expected length: %d, got %d", offsetDefaultLangSys, L)
+			}
+
+			var (
+				err  error
+				read int
+			)
+			item.DefaultLangSys, read, err = ParseJstfLangSys(src[offsetDefaultLangSys:])
+			if err != nil {
+				return item, 0, fmt.Errorf("reading JstfScript: %s", err)
+			}
+			offsetDefaultLangSys += read
+		}
+	}
+	{
+
+		if L := len(src); L < 6+arrayLengthLangSysRecords*6 {
+			return item, 0, fmt.Errorf("reading JstfScript: "+"EOF: expected length: %d, got %d", 6+arrayLengthLangSysRecords*6, L)
+		}
+
+		item.LangSysRecords = make([]TagOffsetRecord, arrayLengthLangSysRecords) // allocation guarded by the previous check
+		for i := range item.LangSysRecords {
+			item.LangSysRecords[i].mustParse(src[6+i*6:])
+		}
+		n += arrayLengthLangSysRecords * 6
+	}
+	{
+
+		err := item.parseLangSys(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading JstfScript: %s", err)
+		}
+	}
+	return item, n, nil
+}
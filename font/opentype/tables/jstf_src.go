@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package tables
+
+import "fmt"
+
+// JSTF is the Justification table
+// See https://learn.microsoft.com/en-us/typography/opentype/spec/jstf
+type JSTF struct {
+	majorVersion  uint16            // Major version of the JSTF table, = 1
+	minorVersion  uint16            // Minor version of the JSTF table, = 0
+	ScriptRecords []TagOffsetRecord `arrayCount:"FirstUint16"` // [jstfScriptCount] Array of JstfScriptRecords, in alphabetical order by jstfScriptTag
+	Scripts       []JstfScript      `isOpaque:""`              // same length as ScriptRecords
+}
+
+func (jt *JSTF) parseScripts(src []byte) error {
+	jt.Scripts = make([]JstfScript, len(jt.ScriptRecords))
+	for i, rec := range jt.ScriptRecords {
+		var err error
+		if L := len(src); L < int(rec.Offset) {
+			return fmt.Errorf("EOF: expected length: %d, got %d", rec.Offset, L)
+		}
+		jt.Scripts[i], _, err = ParseJstfScript(src[rec.Offset:])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JstfScript stores the justification data of one script.
+type JstfScript struct {
+	ExtenderGlyph  ExtenderGlyph     `offsetSize:"Offset16"`    // Offset to ExtenderGlyph table, from beginning of JstfScript table (may be NULL)
+	DefaultLangSys JstfLangSys       `offsetSize:"Offset16"`    // Offset to default JstfLangSys table, from beginning of JstfScript table (may be NULL)
+	LangSysRecords []TagOffsetRecord `arrayCount:"FirstUint16"` // [jstfLangSysCount] Array of JstfLangSysRecords, in alphabetical order by JstfLangSysTag
+	LangSys        []JstfLangSys     `isOpaque:""`              // same length as LangSysRecords
+}
+
+func (js *JstfScript) parseLangSys(src []byte) error {
+	js.LangSys = make([]JstfLangSys, len(js.LangSysRecords))
+	for i, rec := range js.LangSysRecords {
+		var err error
+		if L := len(src); L < int(rec.Offset) {
+			return fmt.Errorf("EOF: expected length: %d, got %d", rec.Offset, L)
+		}
+		js.LangSys[i], _, err = ParseJstfLangSys(src[rec.Offset:])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExtenderGlyph lists the glyphs, such as kashidas, which may be inserted
+// to extend the text.
+type ExtenderGlyph struct {
+	Glyphs []GlyphID `arrayCount:"FirstUint16"` // [glyphCount] Extender glyph IDs — in increasing numerical order
+}
+
+// JstfLangSys lists the justification suggestions of a language system,
+// by decreasing priority.
+type JstfLangSys struct {
+	Priorities []JstfPriority `arrayCount:"FirstUint16" offsetsArray:"Offset16"` // [jstfPriorityCount] Array of offsets to JstfPriority tables, from beginning of JstfLangSys table, in priority order
+}
+
+// JstfPriority stores the modifications suggested for one justification priority:
+// GSUB and GPOS lookups to enable or disable, and the maximum adjustments, given as GPOS lookups.
+type JstfPriority struct {
+	GSUBShrinkageEnable  JstfModList `offsetSize:"Offset16"` // Offset to shrinkage-enable JstfGSUBModList table, from beginning of JstfPriority table (may be NULL)
+	GSUBShrinkageDisable JstfModList `offsetSize:"Offset16"` // Offset to shrinkage-disable JstfGSUBModList table, from beginning of JstfPriority table (may be NULL)
+	GPOSShrinkageEnable  JstfModList `offsetSize:"Offset16"` // Offset to shrinkage-enable JstfGPOSModList table, from beginning of JstfPriority table (may be NULL)
+	GPOSShrinkageDisable JstfModList `offsetSize:"Offset16"` // Offset to shrinkage-disable JstfGPOSModList table, from beginning of JstfPriority table (may be NULL)
+	ShrinkageJstfMax     JstfMax     `offsetSize:"Offset16"` // Offset to shrinkage JstfMax table, from beginning of JstfPriority table (may be NULL)
+	GSUBExtensionEnable  JstfModList `offsetSize:"Offset16"` // Offset to extension-enable JstfGSUBModList table, from beginnning of JstfPriority table (may be NULL)
+	GSUBExtensionDisable JstfModList `offsetSize:"Offset16"` // Offset to extension-disable JstfGSUBModList table, from beginning of JstfPriority table (may be NULL)
+	GPOSExtensionEnable  JstfModList `offsetSize:"Offset16"` // Offset to extension-enable JstfGPOSModList table, from beginning of JstfPriority table (may be NULL)
+	GPOSExtensionDisable JstfModList `offsetSize:"Offset16"` // Offset to extension-disable JstfGPOSModList table, from beginning of JstfPriority table (may be NULL)
+	ExtensionJstfMax     JstfMax     `offsetSize:"Offset16"` // Offset to extension JstfMax table, from beginning of JstfPriority table (may be NULL)
+}
+
+// JstfModList is a list of indices into the GSUB or GPOS lookup list.
+type JstfModList struct {
+	LookupIndices []uint16 `arrayCount:"FirstUint16"` // [lookupCount] Array of lookup indices into the GSUB or GPOS LookupList, in increasing numerical order
+}
+
+// JstfMax defines, with GPOS lookups, the maximum shrinkage or extension of the text.
+type JstfMax struct {
+	Lookups []Lookup `arrayCount:"FirstUint16" offsetsArray:"Offset16"` // [lookupCount] Array of offsets to GPOS-type lookup tables, from beginning of JstfMax table, in design order
+}
+
+// FindScript looks for [script] and return its index into the [Scripts] slice,
+// or -1 if the tag is not found.
+func (jt *JSTF) FindScript(script Tag) int {
+	// ScriptRecords is sorted: binary search
+	low, high := 0, len(jt.ScriptRecords)
+	for low < high {
+		mid := low + (high-low)/2 // avoid overflow when computing mid
+		p := jt.ScriptRecords[mid].Tag
+		if script < p {
+			high = mid
+		} else if script > p {
+			low = mid + 1
+		} else {
+			return mid
+		}
+	}
+	return -1
+}
+
+// FindLanguage looks for [language] and return its index into the [LangSys] slice,
+// or -1 if the tag is not found.
+func (js *JstfScript) FindLanguage(language Tag) int {
+	// LangSysRecords is sorted: binary search
+	low, high := 0, len(js.LangSysRecords)
+	for low < high {
+		mid := low + (high-low)/2 // avoid overflow when computing mid
+		p := js.LangSysRecords[mid].Tag
+		if language < p {
+			high = mid
+		} else if language > p {
+			low = mid + 1
+		} else {
+			return mid
+		}
+	}
+	return -1
+}
+
+// GetLangSys return the language at [index]. It [index] is out of range (for example with -1),
+// it returns [DefaultLangSys] (which may be empty)
+func (js *JstfScript) GetLangSys(index int) JstfLangSys {
+	if index < 0 || index >= len(js.LangSys) {
+		return js.DefaultLangSys
+	}
+	return js.LangSys[index]
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package tables
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	tu "github.com/boxesandglue/typesetting/testutils"
+)
+
+// jstfTable returns a 'JSTF' table for the 'arab' script, with extender glyphs,
+// a default language system and an 'URD ' one.
+func jstfTable() []byte {
+	u16 := func(vs ...uint16) []byte {
+		out := make([]byte, 2*len(vs))
+		for i, v := range vs {
+			binary.BigEndian.PutUint16(out[2*i:], v)
+		}
+		return out
+	}
+	var table []byte
+	table = append(table, u16(1, 0, 1)...)                       // version, jstfScriptCount
+	table = append(table, 'a', 'r', 'a', 'b', 0, 12)             // JstfScriptRecord
+	table = append(table, u16(12, 20, 1)...)                     // JstfScript at 12 : extender, default, jstfLangSysCount
+	table = append(table, 'U', 'R', 'D', ' ', 0, 48)             // JstfLangSysRecord
+	table = append(table, u16(2, 100, 101, 0)...)                // ExtenderGlyph at 24, padding
+	table = append(table, u16(1, 4)...)                          // default JstfLangSys at 32
+	table = append(table, u16(0, 0, 0, 0, 0, 0, 48, 0, 0, 0)...) // JstfPriority at 36 : GSUBExtensionDisable
+	table = append(table, u16(0, 0)...)                          // padding
+	table = append(table, u16(1, 4)...)                          // 'URD ' JstfLangSys at 60
+	table = append(table, u16(20, 0, 0, 0, 0, 0, 0, 0, 0, 0)...) // JstfPriority at 64 : GSUBShrinkageEnable
+	table = append(table, u16(2, 3, 5)...)                       // JstfModList at 84
+	return table
+}
+
+func TestParseJSTF(t *testing.T) {
+	jstf, _, err := ParseJSTF(jstfTable())
+	tu.AssertNoErr(t, err)
+
+	tu.Assert(t, len(jstf.Scripts) == 1)
+	tu.Assert(t, jstf.FindScript(ot.MustNewTag("arab")) == 0)
+	tu.Assert(t, jstf.FindScript(ot.MustNewTag("latn")) == -1)
+
+	script := jstf.Scripts[0]
+	tu.Assert(t, reflect.DeepEqual(script.ExtenderGlyph.Glyphs, []GlyphID{100, 101}))
+	tu.Assert(t, script.FindLanguage(ot.MustNewTag("URD ")) == 0)
+	tu.Assert(t, script.FindLanguage(ot.MustNewTag("FAR ")) == -1)
+
+	def := script.GetLangSys(-1)
+	tu.Assert(t, len(def.Priorities) == 1)
+	tu.Assert(t, reflect.DeepEqual(def.Priorities[0].GSUBExtensionDisable.LookupIndices, []uint16{3, 5}))
+	tu.Assert(t, len(def.Priorities[0].GSUBShrinkageEnable.LookupIndices) == 0)
+
+	urdu := script.GetLangSys(0)
+	tu.Assert(t, len(urdu.Priorities) == 1)
+	tu.Assert(t, reflect.DeepEqual(urdu.Priorities[0].GSUBShrinkageEnable.LookupIndices, []uint16{3, 5}))
+
+	// invalid offsets are reported
+	_, _, err = ParseJSTF(jstfTable()[:80])
+	tu.Assert(t, err != nil)
+}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package tables
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Code generated by binarygen from meta_src.go. DO NOT EDIT
+
+func ParseMeta(src []byte) (Meta, int, error) {
+	var item Meta
+	n := 0
+	if L := len(src); L < 16 {
+		return item, 0, fmt.Errorf("reading Meta: "+"EOF: expected length: 16, got %d", L)
+	}
+	_ = src[15] // early bound checking
+	item.version = binary.BigEndian.Uint32(src[0:])
+	item.flags = binary.BigEndian.Uint32(src[4:])
+	item.reserved = binary.BigEndian.Uint32(src[8:])
+	item.numDataMaps = binary.BigEndian.Uint32(src[12:])
+	n += 16
+
+	{
+		arrayLength := int(item.numDataMaps)
+
+		if L := len(src); L < 16+arrayLength*12 {
+			return item, 0, fmt.Errorf("reading Meta: "+"EOF: expected length: %d, got %d", 16+arrayLength*12, L)
+		}
+
+		item.dataMaps = make([]dataMap, arrayLength) // allocation guarded by the previous check
+		for i := range item.dataMaps {
+			item.dataMaps[i].mustParse(src[16+i*12:])
+		}
+		n += arrayLength * 12
+	}
+	{
+
+		item.data = src[0:]
+		n = len(src)
+	}
+	return item, n, nil
+}
+
+func (item *dataMap) mustParse(src []byte) {
+	_ = src[11] // early bound checking
+	item.Tag = Tag(binary.BigEndian.Uint32(src[0:]))
+	item.dataOffset = binary.BigEndian.Uint32(src[4:])
+	item.dataLength = binary.BigEndian.Uint32(src[8:])
+}
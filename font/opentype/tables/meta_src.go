@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package tables
+
+import (
+	"strings"
+
+	"github.com/boxesandglue/typesetting/language"
+)
+
+// Meta is the metadata table
+// See https://learn.microsoft.com/en-us/typography/opentype/spec/meta
+type Meta struct {
+	version     uint32    // Version number of the metadata table — set to 1.
+	flags       uint32    // Flags — currently unused; set to 0.
+	reserved    uint32    // Not used; should be set to 0.
+	numDataMaps uint32    // The number of data maps in the table.
+	dataMaps    []dataMap `arrayCount:"ComputedField-numDataMaps"` // Array of data map records.
+	data        []byte    `subsliceStart:"AtStart" arrayCount:"ToEnd"`
+}
+
+type dataMap struct {
+	Tag        Tag    // A tag indicating the type of metadata.
+	dataOffset uint32 // Offset in bytes from the beginning of the metadata table to the data for this tag.
+	dataLength uint32 // Length of the data, in bytes. The data is not required to be padded to any byte boundary.
+}
+
+// Data returns the data associated with [tag], or nil if not found or invalid.
+func (mt Meta) Data(tag Tag) []byte {
+	for _, m := range mt.dataMaps {
+		if m.Tag != tag {
+			continue
+		}
+		end := uint64(m.dataOffset) + uint64(m.dataLength)
+		if end > uint64(len(mt.data)) {
+			return nil
+		}
+		return mt.data[m.dataOffset:end]
+	}
+	return nil
+}
+
+// Languages parses the data associated with [tag], expected to be a comma separated
+// list of ScriptLangTag, as used by the 'dlng' (design languages) and 'slng' (supported languages)
+// entries. The tags are returned canonicalized (see [language.NewLanguage]).
+func (mt Meta) Languages(tag Tag) []language.Language {
+	data := mt.Data(tag)
+	if len(data) == 0 {
+		return nil
+	}
+	var out []language.Language
+	for _, s := range strings.Split(string(data), ",") {
+		if lang := language.NewLanguage(strings.TrimSpace(s)); lang != "" {
+			out = append(out, lang)
+		}
+	}
+	return out
+}
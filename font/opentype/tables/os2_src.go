@@ -2,6 +2,8 @@
 
 package tables
 
+import "encoding/binary"
+
 // OS/2 and Windows Metrics Table
 // See https://learn.microsoft.com/en-us/typography/opentype/spec/os2
 type Os2 struct {
@@ -17,7 +19,7 @@ type Os2 struct {
 	YSuperscriptXSize   int16
 	YSuperscriptYSize   int16
 	YSuperscriptXOffset int16
-	ySuperscriptYOffset int16
+	YSuperscriptYOffset int16
 	YStrikeoutSize      int16
 	YStrikeoutPosition  int16
 	sFamilyClass        int16
@@ -30,8 +32,8 @@ type Os2 struct {
 	STypoAscender       int16
 	STypoDescender      int16
 	STypoLineGap        int16
-	usWinAscent         uint16
-	usWinDescent        uint16
+	USWinAscent         uint16
+	USWinDescent        uint16
 	HigherVersionData   []byte `arrayCount:"ToEnd"`
 }
 
@@ -42,6 +44,18 @@ func (os *Os2) FontPage() FontPage {
 	return FPNone
 }
 
+// CodePageRanges returns the ulCodePageRange1 (low bits) and ulCodePageRange2 (high bits)
+// fields, describing the code pages the font is functional for.
+// It returns false for version 0 tables, which do not define these fields.
+func (os *Os2) CodePageRanges() (uint64, bool) {
+	if os.Version < 1 || len(os.HigherVersionData) < 8 {
+		return 0, false
+	}
+	low := binary.BigEndian.Uint32(os.HigherVersionData)
+	high := binary.BigEndian.Uint32(os.HigherVersionData[4:])
+	return uint64(high)<<32 | uint64(low), true
+}
+
 // See https://docs.microsoft.com/en-us/typography/legacy/legacy_arabic_fonts
 // https://github.com/Microsoft/Font-Validator/blob/520aaae/OTFontFileVal/val_OS2.cs#L644-L681
 type FontPage uint16
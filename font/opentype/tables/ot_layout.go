@@ -29,6 +29,15 @@ type Coverage interface {
 	// It is 0 for empty coverages.
 	// For non empty Coverages, it is also 1 + (maximum index returned)
 	Len() int
+
+	// GlyphList returns the covered glyphs, sorted by coverage index,
+	// that is, the glyph at index i has coverage index i.
+	GlyphList() []GlyphID
+
+	// RangeRecords returns the covered glyphs as sorted, non overlapping ranges.
+	// It is more efficient than [GlyphList] for coverages with large ranges.
+	// The returned slice must not be modified.
+	RangeRecords() []RangeRecord
 }
 
 func (Coverage1) isCov() {}
@@ -60,6 +69,11 @@ type ClassDef interface {
 	// Extent returns the maximum class ID + 1. This is the length
 	// required for an array to be indexed by the class values.
 	Extent() int
+
+	// RangeRecords returns the classified glyphs as sorted, non overlapping ranges
+	// of glyphs sharing the same class.
+	// The returned slice must not be modified.
+	RangeRecords() []ClassRangeRecord
 }
 
 func (ClassDef1) isClassDef() {}
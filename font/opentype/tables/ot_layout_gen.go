@@ -89,6 +89,13 @@ func ParseFeature(src []byte) (Feature, int, error) {
 		}
 		n += arrayLengthLookupListIndices * 2
 	}
+	{
+
+		err := item.parseFeatureParams(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading Feature: %s", err)
+		}
+	}
 	return item, n, nil
 }
 
@@ -123,6 +130,69 @@ func ParseFeatureList(src []byte) (FeatureList, int, error) {
 	return item, n, nil
 }
 
+func ParseFeatureParamsCharacterVariants(src []byte) (FeatureParamsCharacterVariants, int, error) {
+	var item FeatureParamsCharacterVariants
+	n := 0
+	if L := len(src); L < 12 {
+		return item, 0, fmt.Errorf("reading FeatureParamsCharacterVariants: "+"EOF: expected length: 12, got %d", L)
+	}
+	_ = src[11] // early bound checking
+	item.format = binary.BigEndian.Uint16(src[0:])
+	item.FeatUILabelNameID = NameID(binary.BigEndian.Uint16(src[2:]))
+	item.FeatUITooltipTextNameID = NameID(binary.BigEndian.Uint16(src[4:]))
+	item.SampleTextNameID = NameID(binary.BigEndian.Uint16(src[6:]))
+	item.NumNamedParameters = binary.BigEndian.Uint16(src[8:])
+	item.FirstParamUILabelNameID = NameID(binary.BigEndian.Uint16(src[10:]))
+	n += 12
+
+	{
+
+		read, err := item.parseCharacters(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading FeatureParamsCharacterVariants: %s", err)
+		}
+		n = read
+	}
+	return item, n, nil
+}
+
+func (item *FeatureParamsSize) mustParse(src []byte) {
+	_ = src[9] // early bound checking
+	item.DesignSize = binary.BigEndian.Uint16(src[0:])
+	item.SubfamilyID = binary.BigEndian.Uint16(src[2:])
+	item.SubfamilyNameID = NameID(binary.BigEndian.Uint16(src[4:]))
+	item.RangeStart = binary.BigEndian.Uint16(src[6:])
+	item.RangeEnd = binary.BigEndian.Uint16(src[8:])
+}
+
+func ParseFeatureParamsSize(src []byte) (FeatureParamsSize, int, error) {
+	var item FeatureParamsSize
+	n := 0
+	if L := len(src); L < 10 {
+		return item, 0, fmt.Errorf("reading FeatureParamsSize: "+"EOF: expected length: 10, got %d", L)
+	}
+	item.mustParse(src)
+	n += 10
+	return item, n, nil
+}
+
+func (item *FeatureParamsStylisticSet) mustParse(src []byte) {
+	_ = src[3] // early bound checking
+	item.version = binary.BigEndian.Uint16(src[0:])
+	item.UINameID = NameID(binary.BigEndian.Uint16(src[2:]))
+}
+
+func ParseFeatureParamsStylisticSet(src []byte) (FeatureParamsStylisticSet, int, error) {
+	var item FeatureParamsStylisticSet
+	n := 0
+	if L := len(src); L < 4 {
+		return item, 0, fmt.Errorf("reading FeatureParamsStylisticSet: "+"EOF: expected length: 4, got %d", L)
+	}
+	item.mustParse(src)
+	n += 4
+	return item, n, nil
+}
+
 func ParseFeatureTableSubstitution(src []byte) (FeatureTableSubstitution, int, error) {
 	var item FeatureTableSubstitution
 	n := 0
@@ -5,6 +5,8 @@ package tables
 import (
 	"encoding/binary"
 	"fmt"
+
+	"github.com/boxesandglue/typesetting/font/opentype"
 )
 
 // Layout represents the common layout table used by GPOS and GSUB.
@@ -115,6 +117,9 @@ func (fl *FeatureList) parseFeatures(src []byte) error {
 		if err != nil {
 			return err
 		}
+		if rec.Tag == sizeTag {
+			fl.Features[i].fixSizeParams(src)
+		}
 	}
 	return nil
 }
@@ -122,6 +127,102 @@ func (fl *FeatureList) parseFeatures(src []byte) error {
 type Feature struct {
 	featureParamsOffset uint16   // Offset from start of Feature table to FeatureParams table, if defined for the feature and present, else NULL
 	LookupListIndices   []uint16 `arrayCount:"FirstUint16"` // [lookupIndexCount]	Array of indices into the LookupList — zero-based (first lookup is LookupListIndex = 0)
+	// FeatureParams is the raw FeatureParams table, or nil.
+	// Its format depends on the feature tag : see [ParseFeatureParamsSize],
+	// [ParseFeatureParamsStylisticSet] and [ParseFeatureParamsCharacterVariants].
+	FeatureParams []byte `isOpaque:""`
+}
+
+func (ft *Feature) parseFeatureParams(src []byte) error {
+	if ft.featureParamsOffset == 0 {
+		return nil
+	}
+	// invalid offsets are ignored, since some old fonts use an offset
+	// relative to the FeatureList for the 'size' feature
+	if int(ft.featureParamsOffset) < len(src) {
+		ft.FeatureParams = src[ft.featureParamsOffset:]
+	}
+	return nil
+}
+
+var sizeTag = opentype.NewTag('s', 'i', 'z', 'e')
+
+// fixSizeParams handles the fonts built by old versions of the Adobe tools, which
+// used an offset to the 'size' FeatureParams table relative to the FeatureList
+// (given in [featureList]) instead of the Feature table.
+func (ft *Feature) fixSizeParams(featureList []byte) {
+	if ft.featureParamsOffset == 0 {
+		return
+	}
+	if params, _, err := ParseFeatureParamsSize(ft.FeatureParams); err == nil && params.IsValid() {
+		return
+	}
+	if len(featureList) < int(ft.featureParamsOffset) {
+		return
+	}
+	data := featureList[ft.featureParamsOffset:]
+	if params, _, err := ParseFeatureParamsSize(data); err == nil && params.IsValid() {
+		ft.FeatureParams = data
+	}
+}
+
+// FeatureParamsSize is the FeatureParams table of the 'size' feature.
+type FeatureParamsSize struct {
+	DesignSize      uint16 // The design size in 720/inch units (decipoints).
+	SubfamilyID     uint16 // Identifies the font family subfamily, or 0 if the font is the only one of its family.
+	SubfamilyNameID NameID // The 'name' table name ID of the subfamily name (like "Caption"), between 256 and 32767.
+	RangeStart      uint16 // Small end of the recommended usage range (exclusive), in decipoints.
+	RangeEnd        uint16 // Large end of the recommended usage range (inclusive), in decipoints.
+}
+
+// IsValid returns true if the parameters are consistent, as defined
+// by the specification.
+func (fp FeatureParamsSize) IsValid() bool {
+	if fp.DesignSize == 0 {
+		return false
+	}
+	if fp.SubfamilyID == 0 && fp.SubfamilyNameID == 0 && fp.RangeStart == 0 && fp.RangeEnd == 0 {
+		return true
+	}
+	return fp.RangeStart <= fp.DesignSize && fp.DesignSize <= fp.RangeEnd &&
+		256 <= fp.SubfamilyNameID && fp.SubfamilyNameID <= 32767
+}
+
+// FeatureParamsStylisticSet is the FeatureParams table
+// of the 'ss01' to 'ss20' features.
+type FeatureParamsStylisticSet struct {
+	version  uint16 // Set to 0.
+	UINameID NameID // The 'name' table name ID that specifies a string (or strings, for multiple languages) for a user-interface label for this feature.
+}
+
+// FeatureParamsCharacterVariants is the FeatureParams table
+// of the 'cv01' to 'cv99' features.
+type FeatureParamsCharacterVariants struct {
+	format                  uint16 // Format number is set to 0.
+	FeatUILabelNameID       NameID // The 'name' table name ID that specifies a string (or strings, for multiple languages) for a user-interface label for this feature. (May be NULL.)
+	FeatUITooltipTextNameID NameID // The 'name' table name ID that specifies a string (or strings, for multiple languages) that an application can use for tooltip text for this feature. (May be NULL.)
+	SampleTextNameID        NameID // The 'name' table name ID that specifies sample text that illustrates the effect of this feature. (May be NULL.)
+	NumNamedParameters      uint16 // Number of named parameters. (May be zero.)
+	FirstParamUILabelNameID NameID // The first 'name' table name ID used to specify strings for user-interface labels for the feature parameters. (Must be zero if numParameters is zero.)
+	Characters              []rune `isOpaque:""` // The Unicode Scalar Value of the characters for which this feature provides glyph variants.
+}
+
+func (cv *FeatureParamsCharacterVariants) parseCharacters(src []byte) (int, error) {
+	const headerSize = 12
+	if L := len(src); L < headerSize+2 {
+		return 0, fmt.Errorf("EOF: expected length: %d, got %d", headerSize+2, L)
+	}
+	count := int(binary.BigEndian.Uint16(src[headerSize:]))
+	end := headerSize + 2 + 3*count
+	if L := len(src); L < end {
+		return 0, fmt.Errorf("EOF: expected length: %d, got %d", end, L)
+	}
+	cv.Characters = make([]rune, count)
+	for i := range cv.Characters {
+		b := src[headerSize+2+3*i:]
+		cv.Characters[i] = rune(b[0])<<16 | rune(b[1])<<8 | rune(b[2])
+	}
+	return end, nil
 }
 
 type lookupList struct {
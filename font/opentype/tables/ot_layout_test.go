@@ -3,10 +3,12 @@
 package tables
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"testing"
 
+	ot "github.com/boxesandglue/typesetting/font/opentype"
 	tu "github.com/boxesandglue/typesetting/testutils"
 	td "github.com/go-text/typesetting-utils/opentype"
 )
@@ -398,3 +400,147 @@ func TestGPOS2_1(t *testing.T) {
 	tu.Assert(t, reflect.DeepEqual(v1, v1g))
 	tu.Assert(t, reflect.DeepEqual(v2, v2g))
 }
+
+func TestParseBASE(t *testing.T) {
+	fp := readFontFile(t, "common/NotoSansCJKjp-VF.otf")
+	base, _, err := ParseBASE(readTable(t, fp, "BASE"))
+	tu.AssertNoErr(t, err)
+
+	for _, axis := range []Axis{base.HorizAxis, base.VertAxis} {
+		tu.Assert(t, len(axis.BaseTagList.BaselineTags) == 4)
+		tu.Assert(t, len(axis.BaseScriptList.Records) == 7)
+		tu.Assert(t, len(axis.BaseScriptList.BaseScripts) == 7)
+		for _, script := range axis.BaseScriptList.BaseScripts {
+			tu.Assert(t, len(script.BaseValues.BaseCoords) == 4)
+			_, isVar := script.BaseValues.BaseCoords[0].(BaseCoordFormat3).Device.(DeviceVariation)
+			tu.Assert(t, isVar)
+		}
+	}
+	tu.Assert(t, len(base.ItemVarStore.ItemVariationDatas) == 1)
+
+	fp = readFontFile(t, "common/OldaniaADFStd-Bold.otf")
+	base, _, err = ParseBASE(readTable(t, fp, "BASE"))
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, len(base.HorizAxis.BaseTagList.BaselineTags) == 2)
+	tu.Assert(t, base.HorizAxis.BaseScriptList.BaseScripts[1].BaseValues.BaseCoords[0] == BaseCoordFormat1{format: 1, Coordinate: -144})
+	tu.Assert(t, len(base.VertAxis.BaseScriptList.Records) == 0)
+
+	// MinMax tables
+	file, err := td.Files.ReadFile("collections/msgothic.ttc")
+	tu.AssertNoErr(t, err)
+	fonts, err := ot.NewLoaders(bytes.NewReader(file))
+	tu.AssertNoErr(t, err)
+	base, _, err = ParseBASE(readTable(t, fonts[0], "BASE"))
+	tu.AssertNoErr(t, err)
+	for _, script := range base.HorizAxis.BaseScriptList.BaseScripts {
+		tu.Assert(t, script.DefaultMinMax.MinCoord == BaseCoordFormat1{format: 1, Coordinate: -36})
+		tu.Assert(t, script.DefaultMinMax.MaxCoord == BaseCoordFormat1{format: 1, Coordinate: 219})
+		tu.Assert(t, len(script.BaseLangSys) == 0)
+	}
+}
+
+func TestParseMinMax(t *testing.T) {
+	src := deHexStr("000E 0012 0001 766B6E61 0016 0000 0001 FF9C 0001 0320 0001 FFCE")
+	minMax, _, err := ParseMinMax(src)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, minMax.MinCoord == BaseCoordFormat1{format: 1, Coordinate: -100})
+	tu.Assert(t, minMax.MaxCoord == BaseCoordFormat1{format: 1, Coordinate: 800})
+	tu.Assert(t, len(minMax.FeatMinMaxRecords) == 1)
+	rec := minMax.FeatMinMaxRecords[0]
+	tu.Assert(t, rec.FeatureTableTag == ot.MustNewTag("vkna"))
+	tu.Assert(t, rec.MinCoord == BaseCoordFormat1{format: 1, Coordinate: -50})
+	tu.Assert(t, rec.MaxCoord == nil)
+
+	_, _, err = ParseMinMax(src[:20])
+	tu.Assert(t, err != nil)
+}
+
+func TestCoverageIteration(t *testing.T) {
+	cov1 := Coverage1{1, []GlyphID{3, 4, 5, 8, 10, 11}}
+	tu.Assert(t, reflect.DeepEqual(cov1.GlyphList(), []GlyphID{3, 4, 5, 8, 10, 11}))
+	tu.Assert(t, reflect.DeepEqual(cov1.RangeRecords(), []RangeRecord{{3, 5, 0}, {8, 8, 3}, {10, 11, 4}}))
+
+	cov2 := Coverage2{2, []RangeRecord{{3, 5, 0}, {8, 8, 3}, {10, 11, 4}}}
+	tu.Assert(t, reflect.DeepEqual(cov2.GlyphList(), cov1.GlyphList()))
+	tu.Assert(t, reflect.DeepEqual(cov2.RangeRecords(), cov1.RangeRecords()))
+
+	tu.Assert(t, len(Coverage1{}.GlyphList()) == 0 && len(Coverage1{}.RangeRecords()) == 0)
+	tu.Assert(t, len(Coverage2{}.GlyphList()) == 0 && len(Coverage2{}.RangeRecords()) == 0)
+}
+
+func TestClassDefIteration(t *testing.T) {
+	cl1 := ClassDef1{1, 5, []uint16{1, 1, 0, 2, 2, 2, 1}}
+	tu.Assert(t, reflect.DeepEqual(cl1.RangeRecords(), []ClassRangeRecord{{5, 6, 1}, {7, 7, 0}, {8, 10, 2}, {11, 11, 1}}))
+	tu.Assert(t, len(ClassDef1{}.RangeRecords()) == 0)
+
+	cl2 := ClassDef2{2, []ClassRangeRecord{{5, 6, 1}, {8, 10, 2}}}
+	tu.Assert(t, reflect.DeepEqual(cl2.RangeRecords(), cl2.ClassRangeRecords))
+}
+
+// checks that iterating is consistent with Index and Class
+func TestIterationConsistency(t *testing.T) {
+	for _, filename := range td.WithOTLayout {
+		fp := readFontFile(t, filename)
+		gpos, _, err := ParseLayout(readTable(t, fp, "GPOS"))
+		tu.AssertNoErr(t, err)
+
+		for _, lookup := range gpos.LookupList.Lookups {
+			lks, err := lookup.AsGPOSLookups()
+			tu.AssertNoErr(t, err)
+			for _, subtable := range lks {
+				if _, isExtension := subtable.(ExtensionPos); isExtension {
+					continue
+				}
+				cov := subtable.Cov()
+				glyphs := cov.GlyphList()
+				tu.Assert(t, len(glyphs) == cov.Len())
+				for i, gi := range glyphs {
+					index, ok := cov.Index(gi)
+					tu.Assert(t, ok && index == i)
+				}
+
+				pair, ok := subtable.(PairPos)
+				if !ok {
+					continue
+				}
+				data, ok := pair.Data.(PairPosData2)
+				if !ok {
+					continue
+				}
+				for _, classDef := range [2]ClassDef{data.ClassDef1, data.ClassDef2} {
+					for _, r := range classDef.RangeRecords() {
+						for gi := r.StartGlyphID; gi <= r.EndGlyphID; gi++ {
+							class, ok := classDef.Class(gi)
+							tu.Assert(t, ok && class == r.Class)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSizeFeatureParamsOffset(t *testing.T) {
+	params := []byte{0, 100, 0, 1, 1, 0, 0, 50, 0, 200} // 10pt, range ]5, 20]
+	build := func(paramsOffset byte) []byte {
+		list := []byte{0, 1, 's', 'i', 'z', 'e', 0, 8} // one feature record
+		list = append(list, 0, paramsOffset, 0, 0)     // feature without lookups
+		list = append(list, params...)
+		return append(list, bytes.Repeat([]byte{0xFF}, 16)...)
+	}
+	expected := FeatureParamsSize{DesignSize: 100, SubfamilyID: 1, SubfamilyNameID: 256, RangeStart: 50, RangeEnd: 200}
+
+	// offset from the Feature table
+	fl, _, err := ParseFeatureList(build(4))
+	tu.AssertNoErr(t, err)
+	got, _, err := ParseFeatureParamsSize(fl.Features[0].FeatureParams)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, got == expected && got.IsValid())
+
+	// offset from the FeatureList, as used by old fonts
+	fl, _, err = ParseFeatureList(build(12))
+	tu.AssertNoErr(t, err)
+	got, _, err = ParseFeatureParamsSize(fl.Features[0].FeatureParams)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, got == expected)
+}
@@ -17,6 +17,20 @@ func (c Coverage1) Index(gi GlyphID) (int, bool) {
 
 func (cl Coverage1) Len() int { return len(cl.Glyphs) }
 
+func (cl Coverage1) GlyphList() []GlyphID { return cl.Glyphs }
+
+func (cl Coverage1) RangeRecords() []RangeRecord {
+	var out []RangeRecord
+	for i, gi := range cl.Glyphs {
+		if L := len(out); L != 0 && out[L-1].EndGlyphID+1 == gi {
+			out[L-1].EndGlyphID = gi
+			continue
+		}
+		out = append(out, RangeRecord{StartGlyphID: gi, EndGlyphID: gi, StartCoverageIndex: uint16(i)})
+	}
+	return out
+}
+
 func (c Coverage2) Index(gi GlyphID) (int, bool) {
 	num := len(c.Ranges)
 	if num == 0 {
@@ -52,6 +66,20 @@ func (cr Coverage2) Len() int {
 	return size
 }
 
+func (cr Coverage2) GlyphList() []GlyphID {
+	out := make([]GlyphID, cr.Len())
+	for _, r := range cr.Ranges {
+		for gi := int(r.StartGlyphID); gi <= int(r.EndGlyphID); gi++ {
+			if index := int(r.StartCoverageIndex) + gi - int(r.StartGlyphID); index < len(out) {
+				out[index] = GlyphID(gi)
+			}
+		}
+	}
+	return out
+}
+
+func (cr Coverage2) RangeRecords() []RangeRecord { return cr.Ranges }
+
 func (cl ClassDef1) Class(gi GlyphID) (uint16, bool) {
 	if gi < cl.StartGlyphID || gi >= cl.StartGlyphID+GlyphID(len(cl.ClassValueArray)) {
 		return 0, false
@@ -59,6 +87,19 @@ func (cl ClassDef1) Class(gi GlyphID) (uint16, bool) {
 	return cl.ClassValueArray[gi-cl.StartGlyphID], true
 }
 
+func (cl ClassDef1) RangeRecords() []ClassRangeRecord {
+	var out []ClassRangeRecord
+	for i, class := range cl.ClassValueArray {
+		gi := cl.StartGlyphID + GlyphID(i)
+		if L := len(out); L != 0 && out[L-1].Class == class {
+			out[L-1].EndGlyphID = gi
+			continue
+		}
+		out = append(out, ClassRangeRecord{StartGlyphID: gi, EndGlyphID: gi, Class: class})
+	}
+	return out
+}
+
 func (cl ClassDef1) Extent() int {
 	max := uint16(0)
 	for _, cid := range cl.ClassValueArray {
@@ -102,6 +143,8 @@ func (cl ClassDef2) Class(g GlyphID) (uint16, bool) {
 	return 0, false
 }
 
+func (cl ClassDef2) RangeRecords() []ClassRangeRecord { return cl.ClassRangeRecords }
+
 func (cl ClassDef2) Extent() int {
 	max := uint16(0)
 	for _, r := range cl.ClassRangeRecords {
@@ -248,7 +291,8 @@ func (dev DeviceHinting) GetDelta(ppem uint16, scale int32) int32 {
 
 	pixels := dev.Values[ppem-dev.StartSize]
 
-	return int32(pixels) * (scale / int32(ppem))
+	// scale before dividing, to avoid truncating scale / ppem
+	return int32(int64(pixels) * int64(scale) / int64(ppem))
 }
 
 // -------------------------------------- gdef --------------------------------------
@@ -304,12 +348,16 @@ func (store ItemVarStore) GetDelta(index VariationStoreIndex, coords []Coord) fl
 	return delta
 }
 
-// Evaluate returns the scalar factor of the region
+// Evaluate returns the scalar factor of the region.
+// Missing coordinates are treated as zero (the default instance).
 func (vr VariationRegion) Evaluate(coords []Coord) float32 {
 	v := float32(1)
-	for axis, coord := range coords {
-		factor := vr.RegionAxes[axis].evaluate(coord)
-		v *= factor
+	for axis, reg := range vr.RegionAxes {
+		var coord Coord
+		if axis < len(coords) {
+			coord = coords[axis]
+		}
+		v *= reg.evaluate(coord)
 	}
 	return v
 }
@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package tables
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Code generated by binarygen from stat_src.go. DO NOT EDIT
+
+func (item *AxisRecord) mustParse(src []byte) {
+	_ = src[7] // early bound checking
+	item.Tag = Tag(binary.BigEndian.Uint32(src[0:]))
+	item.NameID = NameID(binary.BigEndian.Uint16(src[4:]))
+	item.Ordering = binary.BigEndian.Uint16(src[6:])
+}
+
+func (item *AxisValueEntry) mustParse(src []byte) {
+	_ = src[5] // early bound checking
+	item.AxisIndex = binary.BigEndian.Uint16(src[0:])
+	item.Value = Float1616FromUint(binary.BigEndian.Uint32(src[2:]))
+}
+
+func (item *AxisValueFormat1) mustParse(src []byte) {
+	_ = src[11] // early bound checking
+	item.format = binary.BigEndian.Uint16(src[0:])
+	item.AxisIndex = binary.BigEndian.Uint16(src[2:])
+	item.AxisValueFlags = AxisValueFlags(binary.BigEndian.Uint16(src[4:]))
+	item.ValueNameID = NameID(binary.BigEndian.Uint16(src[6:]))
+	item.Value = Float1616FromUint(binary.BigEndian.Uint32(src[8:]))
+}
+
+func (item *AxisValueFormat2) mustParse(src []byte) {
+	_ = src[19] // early bound checking
+	item.format = binary.BigEndian.Uint16(src[0:])
+	item.AxisIndex = binary.BigEndian.Uint16(src[2:])
+	item.AxisValueFlags = AxisValueFlags(binary.BigEndian.Uint16(src[4:]))
+	item.ValueNameID = NameID(binary.BigEndian.Uint16(src[6:]))
+	item.NominalValue = Float1616FromUint(binary.BigEndian.Uint32(src[8:]))
+	item.RangeMinValue = Float1616FromUint(binary.BigEndian.Uint32(src[12:]))
+	item.RangeMaxValue = Float1616FromUint(binary.BigEndian.Uint32(src[16:]))
+}
+
+func (item *AxisValueFormat3) mustParse(src []byte) {
+	_ = src[15] // early bound checking
+	item.format = binary.BigEndian.Uint16(src[0:])
+	item.AxisIndex = binary.BigEndian.Uint16(src[2:])
+	item.AxisValueFlags = AxisValueFlags(binary.BigEndian.Uint16(src[4:]))
+	item.ValueNameID = NameID(binary.BigEndian.Uint16(src[6:]))
+	item.Value = Float1616FromUint(binary.BigEndian.Uint32(src[8:]))
+	item.LinkedValue = Float1616FromUint(binary.BigEndian.Uint32(src[12:]))
+}
+
+func ParseAxisValue(src []byte) (AxisValue, int, error) {
+	var item AxisValue
+
+	if L := len(src); L < 2 {
+		return item, 0, fmt.Errorf("reading AxisValue: "+"EOF: expected length: 2, got %d", L)
+	}
+	format := uint16(binary.BigEndian.Uint16(src[0:]))
+	var (
+		read int
+		err  error
+	)
+	switch format {
+	case 1:
+		item, read, err = ParseAxisValueFormat1(src[0:])
+	case 2:
+		item, read, err = ParseAxisValueFormat2(src[0:])
+	case 3:
+		item, read, err = ParseAxisValueFormat3(src[0:])
+	case 4:
+		item, read, err = ParseAxisValueFormat4(src[0:])
+	default:
+		err = fmt.Errorf("unsupported AxisValue format %d", format)
+	}
+	if err != nil {
+		return item, 0, fmt.Errorf("reading AxisValue: %s", err)
+	}
+
+	return item, read, nil
+}
+
+func ParseAxisValueFormat1(src []byte) (AxisValueFormat1, int, error) {
+	var item AxisValueFormat1
+	n := 0
+	if L := len(src); L < 12 {
+		return item, 0, fmt.Errorf("reading AxisValueFormat1: "+"EOF: expected length: 12, got %d", L)
+	}
+	item.mustParse(src)
+	n += 12
+	return item, n, nil
+}
+
+func ParseAxisValueFormat2(src []byte) (AxisValueFormat2, int, error) {
+	var item AxisValueFormat2
+	n := 0
+	if L := len(src); L < 20 {
+		return item, 0, fmt.Errorf("reading AxisValueFormat2: "+"EOF: expected length: 20, got %d", L)
+	}
+	item.mustParse(src)
+	n += 20
+	return item, n, nil
+}
+
+func ParseAxisValueFormat3(src []byte) (AxisValueFormat3, int, error) {
+	var item AxisValueFormat3
+	n := 0
+	if L := len(src); L < 16 {
+		return item, 0, fmt.Errorf("reading AxisValueFormat3: "+"EOF: expected length: 16, got %d", L)
+	}
+	item.mustParse(src)
+	n += 16
+	return item, n, nil
+}
+
+func ParseAxisValueFormat4(src []byte) (AxisValueFormat4, int, error) {
+	var item AxisValueFormat4
+	n := 0
+	if L := len(src); L < 8 {
+		return item, 0, fmt.Errorf("reading AxisValueFormat4: "+"EOF: expected length: 8, got %d", L)
+	}
+	_ = src[7] // early bound checking
+	item.format = binary.BigEndian.Uint16(src[0:])
+	item.axisCount = binary.BigEndian.Uint16(src[2:])
+	item.AxisValueFlags = AxisValueFlags(binary.BigEndian.Uint16(src[4:]))
+	item.ValueNameID = NameID(binary.BigEndian.Uint16(src[6:]))
+	n += 8
+
+	{
+		arrayLength := int(item.axisCount)
+
+		if L := len(src); L < 8+arrayLength*6 {
+			return item, 0, fmt.Errorf("reading AxisValueFormat4: "+"EOF: expected length: %d, got %d", 8+arrayLength*6, L)
+		}
+
+		item.AxisValues = make([]AxisValueEntry, arrayLength) // allocation guarded by the previous check
+		for i := range item.AxisValues {
+			item.AxisValues[i].mustParse(src[8+i*6:])
+		}
+		n += arrayLength * 6
+	}
+	return item, n, nil
+}
+
+func ParseSTAT(src []byte) (STAT, int, error) {
+	var item STAT
+	n := 0
+	if L := len(src); L < 18 {
+		return item, 0, fmt.Errorf("reading STAT: "+"EOF: expected length: 18, got %d", L)
+	}
+	_ = src[17] // early bound checking
+	item.majorVersion = binary.BigEndian.Uint16(src[0:])
+	item.minorVersion = binary.BigEndian.Uint16(src[2:])
+	item.designAxisSize = binary.BigEndian.Uint16(src[4:])
+	item.designAxisCount = binary.BigEndian.Uint16(src[6:])
+	item.designAxesOffset = Offset32(binary.BigEndian.Uint32(src[8:]))
+	item.axisValueCount = binary.BigEndian.Uint16(src[12:])
+	item.offsetToAxisValueOffsets = Offset32(binary.BigEndian.Uint32(src[14:]))
+	n += 18
+
+	{
+
+		read, err := item.parseElidedFallbackNameID(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading STAT: %s", err)
+		}
+		n += read
+	}
+	{
+
+		read, err := item.parseDesignAxes(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading STAT: %s", err)
+		}
+		n += read
+	}
+	{
+
+		read, err := item.parseAxisValues(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading STAT: %s", err)
+		}
+		n += read
+	}
+	return item, n, nil
+}
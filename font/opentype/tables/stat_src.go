@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package tables
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// STAT is the Style Attributes table.
+// See https://learn.microsoft.com/en-us/typography/opentype/spec/stat
+type STAT struct {
+	majorVersion             uint16       // Major version number of the style attributes table — set to 1.
+	minorVersion             uint16       // Minor version number of the style attributes table — set to 2.
+	designAxisSize           uint16       // The size in bytes of each axis record.
+	designAxisCount          uint16       // The number of axis records.
+	designAxesOffset         Offset32     // Offset in bytes from the beginning of the STAT table to the start of the design axes array.
+	axisValueCount           uint16       // The number of axis value tables.
+	offsetToAxisValueOffsets Offset32     // Offset in bytes from the beginning of the STAT table to the start of the axis value offsets array.
+	ElidedFallbackNameID     NameID       `isOpaque:""` // Name ID used as fallback when projection of names into a particular font model produces a subfamily name containing only elidable elements (0 for version 1.0 tables).
+	DesignAxes               []AxisRecord `isOpaque:""` // [designAxisCount]
+	AxisValues               []AxisValue  `isOpaque:""` // [axisValueCount]
+}
+
+func (st *STAT) parseElidedFallbackNameID(src []byte) (int, error) {
+	if st.minorVersion < 1 {
+		return 0, nil
+	}
+	if L := len(src); L < 20 {
+		return 0, fmt.Errorf("EOF: expected length: 20, got %d", L)
+	}
+	st.ElidedFallbackNameID = NameID(binary.BigEndian.Uint16(src[18:]))
+	return 2, nil
+}
+
+func (st *STAT) parseDesignAxes(src []byte) (int, error) {
+	if st.designAxisCount == 0 {
+		return 0, nil
+	}
+	size, count, offset := int(st.designAxisSize), int(st.designAxisCount), int(st.designAxesOffset)
+	if size < 8 {
+		return 0, fmt.Errorf("invalid axis record size %d", size)
+	}
+	if L := len(src); L < offset+count*size {
+		return 0, fmt.Errorf("EOF: expected length: %d, got %d", offset+count*size, L)
+	}
+	st.DesignAxes = make([]AxisRecord, count)
+	for i := range st.DesignAxes {
+		st.DesignAxes[i].mustParse(src[offset+i*size:])
+	}
+	return 0, nil
+}
+
+func (st *STAT) parseAxisValues(src []byte) (int, error) {
+	if st.axisValueCount == 0 {
+		return 0, nil
+	}
+	count, offset := int(st.axisValueCount), int(st.offsetToAxisValueOffsets)
+	if L := len(src); L < offset+count*2 {
+		return 0, fmt.Errorf("EOF: expected length: %d, got %d", offset+count*2, L)
+	}
+	st.AxisValues = make([]AxisValue, count)
+	for i := range st.AxisValues {
+		// offsets are from the start of the offsets array
+		valueOffset := offset + int(binary.BigEndian.Uint16(src[offset+2*i:]))
+		if L := len(src); L < valueOffset {
+			return 0, fmt.Errorf("EOF: expected length: %d, got %d", valueOffset, L)
+		}
+		var err error
+		st.AxisValues[i], _, err = ParseAxisValue(src[valueOffset:])
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
+
+// AxisRecord describes a design axis, which may or may
+// not be a variation axis.
+type AxisRecord struct {
+	Tag      Tag    // A tag identifying the axis of design variation.
+	NameID   NameID // The name ID for entries in the 'name' table that provide a display string for this axis.
+	Ordering uint16 // A value that applications can use to determine primary sorting of face names, or for ordering of labels when composing family or face names.
+}
+
+// AxisValueFlags are defined for [AxisValue] tables.
+type AxisValueFlags uint16
+
+const (
+	// If set, this axis value table provides axis value information that is applicable
+	// to other fonts within the same font family.
+	OlderSiblingFontAttribute AxisValueFlags = 0x0001
+	// If set, it indicates that the axis value represents the “normal” value for the axis
+	// and may be omitted when composing name strings.
+	ElidableAxisValueName AxisValueFlags = 0x0002
+)
+
+// AxisValue associates a name to a value (or a range of values)
+// of one or several design axis.
+type AxisValue interface {
+	isAxisValue()
+
+	// Name returns the name ID for entries in the 'name' table that
+	// provide a display string for this value.
+	Name() NameID
+	// Flags returns the flags of the table.
+	Flags() AxisValueFlags
+}
+
+func (AxisValueFormat1) isAxisValue() {}
+func (AxisValueFormat2) isAxisValue() {}
+func (AxisValueFormat3) isAxisValue() {}
+func (AxisValueFormat4) isAxisValue() {}
+
+func (av AxisValueFormat1) Name() NameID { return av.ValueNameID }
+func (av AxisValueFormat2) Name() NameID { return av.ValueNameID }
+func (av AxisValueFormat3) Name() NameID { return av.ValueNameID }
+func (av AxisValueFormat4) Name() NameID { return av.ValueNameID }
+
+func (av AxisValueFormat1) Flags() AxisValueFlags { return av.AxisValueFlags }
+func (av AxisValueFormat2) Flags() AxisValueFlags { return av.AxisValueFlags }
+func (av AxisValueFormat3) Flags() AxisValueFlags { return av.AxisValueFlags }
+func (av AxisValueFormat4) Flags() AxisValueFlags { return av.AxisValueFlags }
+
+// AxisValueFormat1 names a single value of an axis.
+type AxisValueFormat1 struct {
+	format         uint16         `unionTag:"1"`
+	AxisIndex      uint16         // Zero-base index into the axis record array identifying the axis of design variation to which the axis value table applies.
+	AxisValueFlags AxisValueFlags // Flags
+	ValueNameID    NameID         // The name ID for entries in the 'name' table that provide a display string for this attribute value.
+	Value          Float1616      // A numeric value for this attribute value.
+}
+
+// AxisValueFormat2 names a range of values of an axis.
+type AxisValueFormat2 struct {
+	format         uint16         `unionTag:"2"`
+	AxisIndex      uint16         // Zero-base index into the axis record array identifying the axis of design variation to which the axis value table applies.
+	AxisValueFlags AxisValueFlags // Flags
+	ValueNameID    NameID         // The name ID for entries in the 'name' table that provide a display string for this attribute value.
+	NominalValue   Float1616      // A nominal numeric value for this attribute value.
+	RangeMinValue  Float1616      // The minimum value for a range associated with the specified name ID.
+	RangeMaxValue  Float1616      // The maximum value for a range associated with the specified name ID.
+}
+
+// AxisValueFormat3 names a single value of an axis, and
+// links it to a style-linked value (typically Regular to Bold).
+type AxisValueFormat3 struct {
+	format         uint16         `unionTag:"3"`
+	AxisIndex      uint16         // Zero-base index into the axis record array identifying the axis of design variation to which the axis value table applies.
+	AxisValueFlags AxisValueFlags // Flags
+	ValueNameID    NameID         // The name ID for entries in the 'name' table that provide a display string for this attribute value.
+	Value          Float1616      // A numeric value for this attribute value.
+	LinkedValue    Float1616      // The numeric value for a style-linked mapping from this value.
+}
+
+// AxisValueFormat4 names a combination of values
+// of several axes.
+type AxisValueFormat4 struct {
+	format         uint16           `unionTag:"4"`
+	axisCount      uint16           // The total number of axes contributing to this axis-values combination.
+	AxisValueFlags AxisValueFlags   // Flags
+	ValueNameID    NameID           // The name ID for entries in the 'name' table that provide a display string for this combination of axis values.
+	AxisValues     []AxisValueEntry `arrayCount:"ComputedField-axisCount"` // Array of AxisValue records that provide the combination of axis values, one for each contributing axis.
+}
+
+// AxisValueEntry is a value of an axis, used in [AxisValueFormat4].
+type AxisValueEntry struct {
+	AxisIndex uint16    // Zero-base index into the axis record array identifying the axis to which this value applies.
+	Value     Float1616 // A numeric value for this attribute value.
+}
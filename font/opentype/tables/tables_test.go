@@ -4,9 +4,11 @@ package tables
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
 
 	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/language"
 	tu "github.com/boxesandglue/typesetting/testutils"
 	td "github.com/go-text/typesetting-utils/opentype"
 )
@@ -85,3 +87,107 @@ func TestParseCmap(t *testing.T) {
 		tu.Assert(t, len(cmap.Records) > 0)
 	}
 }
+
+func TestParseMeta(t *testing.T) {
+	dlng, slng := []byte("Jpan, zh-Hant"), []byte("Hani,Kana,Hira")
+	data := []byte{
+		0, 0, 0, 1, // version
+		0, 0, 0, 0, // flags
+		0, 0, 0, 0, // reserved
+		0, 0, 0, 2, // numDataMaps
+		'd', 'l', 'n', 'g', 0, 0, 0, 40, 0, 0, 0, byte(len(dlng)),
+		's', 'l', 'n', 'g', 0, 0, 0, 40 + byte(len(dlng)), 0, 0, 0, byte(len(slng)),
+	}
+	data = append(append(data, dlng...), slng...)
+
+	meta, _, err := ParseMeta(data)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, reflect.DeepEqual(meta.Languages(ot.MustNewTag("dlng")), []language.Language{"jpan", "zh-hant"}))
+	tu.Assert(t, reflect.DeepEqual(meta.Languages(ot.MustNewTag("slng")), []language.Language{"hani", "kana", "hira"}))
+	tu.Assert(t, meta.Languages(ot.MustNewTag("appl")) == nil)
+
+	// invalid data range
+	data[23] = 0xFF
+	meta, _, err = ParseMeta(data)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, meta.Data(ot.MustNewTag("dlng")) == nil)
+
+	_, _, err = ParseMeta(data[:20])
+	tu.Assert(t, err != nil)
+}
+
+func TestParseCOLR(t *testing.T) {
+	data := deHexStr("0000 0002 0000000E 0000001A 0003" + // header
+		"0005 0000 0002 0009 0002 0001" + // base glyph records
+		"0006 0000 0007 FFFF 000A 0001") // layer records
+
+	colr, _, err := ParseCOLR(data)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, reflect.DeepEqual(colr.Layers(5), []LayerRecord{{6, 0}, {7, 0xFFFF}}))
+	tu.Assert(t, reflect.DeepEqual(colr.Layers(9), []LayerRecord{{10, 1}}))
+	tu.Assert(t, colr.Layers(6) == nil)
+
+	// invalid layer range
+	data[19] = 4
+	colr, _, err = ParseCOLR(data)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, colr.Layers(5) == nil)
+
+	_, _, err = ParseCOLR(data[:30])
+	tu.Assert(t, err != nil)
+}
+
+func TestParseCPAL(t *testing.T) {
+	data := deHexStr("0001 0002 0002 0003 0000001C 0000 0001" + // header
+		"00000028 00000030 00000000" + // version 1 offsets
+		"0000FFFF 00FF00FF FF000080" + // color records
+		"00000001 00000002" + // palette types
+		"0100 FFFF") // palette labels
+
+	cpal, _, err := ParseCPAL(data)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, reflect.DeepEqual(cpal.Palette(0), []ColorRecord{{0, 0, 0xFF, 0xFF}, {0, 0xFF, 0, 0xFF}}))
+	tu.Assert(t, reflect.DeepEqual(cpal.Palette(1), []ColorRecord{{0, 0xFF, 0, 0xFF}, {0xFF, 0, 0, 0x80}}))
+	tu.Assert(t, cpal.Palette(2) == nil)
+	tu.Assert(t, reflect.DeepEqual(cpal.PaletteTypes, []uint32{1, 2}))
+	tu.Assert(t, reflect.DeepEqual(cpal.PaletteLabels, []NameID{0x100, 0xFFFF}))
+	tu.Assert(t, cpal.PaletteEntryLabels == nil)
+
+	// version 0 tables ignore the extensions
+	data[1] = 0
+	cpal, _, err = ParseCPAL(data)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, cpal.PaletteTypes == nil && cpal.PaletteLabels == nil)
+
+	_, _, err = ParseCPAL(data[:14])
+	tu.Assert(t, err != nil)
+}
+
+func TestParseSTAT(t *testing.T) {
+	fp := readFontFile(t, "common/Commissioner-VF.ttf")
+	stat, _, err := ParseSTAT(readTable(t, fp, "STAT"))
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, stat.ElidedFallbackNameID == 2)
+	tu.Assert(t, reflect.DeepEqual(stat.DesignAxes, []AxisRecord{
+		{ot.MustNewTag("wght"), 256, 0},
+		{ot.MustNewTag("slnt"), 257, 1},
+		{ot.MustNewTag("FLAR"), 258, 2},
+		{ot.MustNewTag("VOLM"), 259, 3},
+	}))
+	tu.Assert(t, len(stat.AxisValues) == 15)
+	tu.Assert(t, stat.AxisValues[3] == AxisValueFormat2{2, 0, ElidableAxisValueName, 263, 400, 350, 450})
+	tu.Assert(t, stat.AxisValues[9] == AxisValueFormat3{3, 0, 0, 263, 400, 700})
+	tu.Assert(t, stat.AxisValues[11] == AxisValueFormat1{1, 1, 0, 315, -12})
+	v4 := stat.AxisValues[13].(AxisValueFormat4)
+	tu.Assert(t, v4.Name() == 316 && reflect.DeepEqual(v4.AxisValues, []AxisValueEntry{{2, 100}, {3, 0}}))
+
+	// version 1.0 tables have no elided fallback name
+	data := deHexStr("0001 0000 0008 0001 00000012 0000 00000000" + // header
+		"77676874 0100 0000") // axis record
+	stat, _, err = ParseSTAT(data)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, stat.ElidedFallbackNameID == 0 && len(stat.DesignAxes) == 1 && stat.AxisValues == nil)
+
+	_, _, err = ParseSTAT(data[:20])
+	tu.Assert(t, err != nil)
+}
@@ -41,6 +41,22 @@ func ParseAvar(src []byte) (Avar, int, error) {
 		}
 		n = offset
 	}
+	{
+
+		read, err := item.parseAxisIndexMap(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading Avar: %s", err)
+		}
+		n += read
+	}
+	{
+
+		read, err := item.parseVarStore(src[:])
+		if err != nil {
+			return item, 0, fmt.Errorf("reading Avar: %s", err)
+		}
+		n += read
+	}
 	return item, n, nil
 }
 
@@ -3,9 +3,11 @@
 package tables
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/maphash"
 )
 
 // ------------------------------------ fvar ------------------------------------
@@ -28,7 +30,7 @@ func (fv *Fvar) parseFvarRecords(src []byte) (err error) {
 	if L := len(src); L < int(fv.axesArrayOffset) {
 		return fmt.Errorf("EOF: expected length: %d, got %d", fv.axesArrayOffset, L)
 	}
-	fv.FvarRecords, _, err = ParseFvarRecords(src[fv.axesArrayOffset:], int(fv.axisCount), int(fv.instanceCount), int(fv.axisCount))
+	fv.FvarRecords, _, err = ParseFvarRecords(src[fv.axesArrayOffset:], int(fv.axisCount), int(fv.instanceCount), int(fv.instanceSize))
 	return
 }
 
@@ -40,13 +42,19 @@ type FvarRecords struct {
 }
 
 func (fvr *FvarRecords) parseInstances(src []byte, axisCount, instanceCount, instanceSize int) error {
+	if instanceCount == 0 {
+		return nil
+	}
+	if instanceSize < 4+4*axisCount {
+		return fmt.Errorf("invalid instance size %d for %d axis", instanceSize, axisCount)
+	}
 	if L := len(src); L < instanceCount*instanceSize {
 		return fmt.Errorf("EOF: expected length: %d, got %d", instanceCount*instanceSize, L)
 	}
 	fvr.Instances = make([]InstanceRecord, instanceCount)
 	for i := range fvr.Instances {
 		var err error
-		fvr.Instances[i], _, err = ParseInstanceRecord(src[instanceSize*i:], axisCount)
+		fvr.Instances[i], _, err = ParseInstanceRecord(src[instanceSize*i:instanceSize*(i+1)], axisCount)
 		if err != nil {
 			return err
 		}
@@ -67,7 +75,7 @@ type InstanceRecord struct {
 	SubfamilyNameID  uint16      // The name ID for entries in the 'name' table that provide subfamily names for this instance.
 	flags            uint16      // Reserved for future use — set to 0.
 	Coordinates      []Float1616 // [axisCount] The coordinates array for this instance.
-	PostScriptNameID uint16      `isOpaque:"" subsliceStart:"AtCurrent"` // Optional. The name ID for entries in the 'name' table that provide PostScript names for this instance.
+	PostScriptNameID uint16      `isOpaque:"" subsliceStart:"AtCurrent"` // Optional. The name ID for entries in the 'name' table that provide PostScript names for this instance, or 0xFFFF.
 }
 
 func (ir *InstanceRecord) parsePostScriptNameID(src []byte, _ int) (int, error) {
@@ -75,6 +83,7 @@ func (ir *InstanceRecord) parsePostScriptNameID(src []byte, _ int) (int, error)
 		ir.PostScriptNameID = binary.BigEndian.Uint16(src)
 		return 2, nil
 	}
+	ir.PostScriptNameID = 0xFFFF // not provided
 	return 0, nil
 }
 
@@ -198,8 +207,17 @@ func (gv *Gvar) parseGlyphVariationDataOffsets(src []byte) error {
 func (gv *Gvar) parseGlyphVariationDatas(src []byte) error {
 	gv.GlyphVariationDatas = make([]GlyphVariationData, gv.glyphCount)
 	startArray := uint32(gv.glyphVariationDataArrayOffset)
+	blockRange := func(i int) (int, int) {
+		return int(startArray + gv.glyphVariationDataOffsets[i]), int(startArray + gv.glyphVariationDataOffsets[i+1])
+	}
+
+	// identical data blocks, which are frequent for composite glyphs,
+	// are only parsed once, and share their storage
+	seed := maphash.MakeSeed()
+	blocks := map[uint64]int{} // block hash -> first glyph with this block
+
 	for i := range gv.GlyphVariationDatas {
-		start, end := int(startArray+gv.glyphVariationDataOffsets[i]), int(startArray+gv.glyphVariationDataOffsets[i+1])
+		start, end := blockRange(i)
 		if start == end {
 			continue
 		}
@@ -212,8 +230,19 @@ func (gv *Gvar) parseGlyphVariationDatas(src []byte) error {
 			return fmt.Errorf("EOF: expected length: %d, got %d", end, L)
 		}
 
+		block := src[start:end]
+		key := maphash.Bytes(seed, block)
+		if j, ok := blocks[key]; ok {
+			if startJ, endJ := blockRange(j); bytes.Equal(block, src[startJ:endJ]) {
+				gv.GlyphVariationDatas[i] = gv.GlyphVariationDatas[j]
+				continue
+			}
+		} else {
+			blocks[key] = i
+		}
+
 		var err error
-		gv.GlyphVariationDatas[i], _, err = ParseGlyphVariationData(src[start:end], int(gv.axisCount))
+		gv.GlyphVariationDatas[i], _, err = ParseGlyphVariationData(block, int(gv.axisCount))
 		if err != nil {
 			return err
 		}
@@ -374,10 +403,54 @@ type VVAR = HVAR
 
 // avar — Axis Variations Table
 type Avar struct {
-	majorVersion    uint16        // Major version number of the axis variations table — set to 1.
+	majorVersion    uint16        // Major version number of the axis variations table — set to 1 or 2.
 	minorVersion    uint16        // Minor version number of the axis variations table — set to 0.
 	reserved        uint16        // Permanently reserved; set to zero.
 	AxisSegmentMaps []SegmentMaps `arrayCount:"FirstUint16"` //[axisCount]	The segment maps array — one segment map for each axis, in the order of axes specified in the 'fvar' table.
+
+	// The following fields are only present in version 2.
+
+	AxisIndexMap DeltaSetMapping `isOpaque:""` // Optional mapping from axis indices to delta-set indices. If empty, axis indices are used as inner indices.
+	VarStore     ItemVarStore    `isOpaque:""` // Variation store used to adjust the normalized coordinates. Empty for version 1 tables.
+}
+
+// v2Offset returns the offset of the version 2 field at [index],
+// which are stored after the segment maps, or 0 for version 1 tables.
+func (av *Avar) v2Offset(src []byte, index int) (int, error) {
+	if av.majorVersion < 2 {
+		return 0, nil
+	}
+	start := 8
+	for _, sm := range av.AxisSegmentMaps {
+		start += 2 + 4*len(sm.AxisValueMaps)
+	}
+	start += 4 * index
+	if L := len(src); L < start+4 {
+		return 0, fmt.Errorf("EOF: expected length: %d, got %d", start+4, L)
+	}
+	offset := int(binary.BigEndian.Uint32(src[start:]))
+	if L := len(src); L < offset {
+		return 0, fmt.Errorf("EOF: expected length: %d, got %d", offset, L)
+	}
+	return offset, nil
+}
+
+func (av *Avar) parseAxisIndexMap(src []byte) (int, error) {
+	offset, err := av.v2Offset(src, 0)
+	if err != nil || offset == 0 {
+		return 0, err
+	}
+	av.AxisIndexMap, _, err = ParseDeltaSetMapping(src[offset:])
+	return 0, err
+}
+
+func (av *Avar) parseVarStore(src []byte) (int, error) {
+	offset, err := av.v2Offset(src, 1)
+	if err != nil || offset == 0 {
+		return 0, err
+	}
+	av.VarStore, _, err = ParseItemVarStore(src[offset:])
+	return 0, err
 }
 
 type SegmentMaps struct {
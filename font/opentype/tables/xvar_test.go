@@ -191,6 +191,37 @@ func TestParseAvar(t *testing.T) {
 	}
 }
 
+// avar2Table is a version 2 'avar' table for two axes : the first axis
+// is moved by 0.25 when the second one is at its maximum.
+const avar2Table = "0002 0000 0000 0002" + // header
+	"0000 0000" + // identity segment maps
+	"00000000 00000014" + // no axis index map, variation store
+	"0001 0000000C 0001 0000001C" + // variation store header
+	"0002 0001 0000 0000 0000 0000 4000 4000" + // region list
+	"0002 0001 0001 0000 1000 0000" // item variation data
+
+func TestParseAvar2(t *testing.T) {
+	data := deHexStr(avar2Table)
+	avar, _, err := ParseAvar(data)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, len(avar.AxisSegmentMaps) == 2)
+	tu.Assert(t, len(avar.AxisIndexMap.Map) == 0)
+	tu.Assert(t, avar.VarStore.AxisCount() == 2)
+	coords := []Coord{0, NewCoord(1)}
+	tu.Assert(t, avar.VarStore.GetDelta(avar.AxisIndexMap.Index(0), coords) == 0x1000)
+	tu.Assert(t, avar.VarStore.GetDelta(avar.AxisIndexMap.Index(1), coords) == 0)
+
+	// version 1 tables ignore the extension
+	data[1] = 1
+	avar, _, err = ParseAvar(data)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, avar.VarStore.AxisCount() == -1)
+
+	data[1] = 2
+	_, _, err = ParseAvar(data[:14])
+	tu.Assert(t, err != nil)
+}
+
 func TestParseMVAR(t *testing.T) {
 	for _, filepath := range td.WithMVAR {
 		fp := readFontFile(t, filepath)
@@ -207,3 +238,23 @@ func TestParseFvar(t *testing.T) {
 		tu.Assert(t, len(fvar.Axis) == item.AxisCount)
 	}
 }
+
+func TestVariationRegionEvaluate(t *testing.T) {
+	region := VariationRegion{RegionAxes: []RegionAxisCoordinates{
+		{StartCoord: 0, PeakCoord: 1 << 14, EndCoord: 1 << 14},
+		{StartCoord: 0, PeakCoord: 1 << 14, EndCoord: 1 << 14},
+	}}
+	// missing coordinates are at the default instance
+	tu.Assert(t, region.Evaluate(nil) == 0)
+	tu.Assert(t, region.Evaluate([]Coord{1 << 14}) == 0)
+	tu.Assert(t, region.Evaluate([]Coord{1 << 14, 1 << 13}) == 0.5)
+
+	store := ItemVarStore{
+		format:              1,
+		VariationRegionList: VariationRegionList{axisCount: 2, VariationRegions: []VariationRegion{region}},
+		ItemVariationDatas:  []ItemVariationData{{RegionIndexes: []uint16{0}, DeltaSets: [][]int16{{100}}}},
+	}
+	// no delta is applied at the default coordinates
+	tu.Assert(t, store.GetDelta(VariationStoreIndex{}, nil) == 0)
+	tu.Assert(t, store.GetDelta(VariationStoreIndex{}, []Coord{1 << 14, 1 << 14}) == 100)
+}
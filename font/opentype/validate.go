@@ -0,0 +1,250 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package opentype
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WarningKind identifies the problems found by [Loader.Validate].
+type WarningKind uint8
+
+const (
+	_ WarningKind = iota
+	// TableOutOfBounds is reported for tables extending past the end of the file.
+	TableOutOfBounds
+	// MissingTable is reported when a required table is missing.
+	MissingTable
+	// InvalidTable is reported for tables too short or with invalid header fields.
+	InvalidTable
+	// InvalidLoca is reported when the 'loca' and 'glyf' tables are not consistent.
+	InvalidLoca
+	// InvalidMetrics is reported when the 'hmtx' table is too short.
+	InvalidMetrics
+	// TableChecksum is reported for tables whose content does not match the
+	// checksum stored in the table directory.
+	TableChecksum
+	// HeadChecksumAdjustment is reported when the checkSumAdjustment
+	// field of the 'head' table does not match the file content.
+	HeadChecksumAdjustment
+)
+
+// Severe returns true for the problems which make the font unusable, and false
+// for checksum mismatches, which are common in real world fonts and harmless
+// for text layout.
+func (k WarningKind) Severe() bool { return k != TableChecksum && k != HeadChecksumAdjustment }
+
+func (k WarningKind) String() string {
+	switch k {
+	case TableOutOfBounds:
+		return "table out of bounds"
+	case MissingTable:
+		return "missing table"
+	case InvalidTable:
+		return "invalid table"
+	case InvalidLoca:
+		return "invalid loca"
+	case InvalidMetrics:
+		return "invalid metrics"
+	case TableChecksum:
+		return "table checksum"
+	case HeadChecksumAdjustment:
+		return "head checksum adjustment"
+	default:
+		return fmt.Sprintf("<warning kind %d>", k)
+	}
+}
+
+// Warning is an integrity problem of a font file, as reported by [Loader.Validate].
+type Warning struct {
+	Kind WarningKind
+	// Table is the table concerned, or zero
+	// for problems with the whole file.
+	Table   Tag
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Table == 0 {
+		return fmt.Sprintf("%s: %s", w.Kind, w.Message)
+	}
+	return fmt.Sprintf("%s (%s): %s", w.Kind, w.Table, w.Message)
+}
+
+// ValidationError is returned by [NewLoaderStrict] for fonts with severe problems.
+type ValidationError struct {
+	Warnings []Warning
+}
+
+func (ve *ValidationError) Error() string {
+	var severe []string
+	for _, w := range ve.Warnings {
+		if w.Kind.Severe() {
+			severe = append(severe, w.String())
+		}
+	}
+	return "invalid font: " + strings.Join(severe, "; ")
+}
+
+// NewLoaderStrict is the same as [NewLoader], but also checks the integrity
+// of the font with [Loader.Validate].
+//
+// If a severe problem is found (see [WarningKind.Severe]), a [*ValidationError] is returned;
+// otherwise, the loader is returned with the (possibly empty) list of warnings.
+// This is useful to reject corrupted fonts early, for instance when processing
+// fonts provided by users.
+func NewLoaderStrict(file Resource) (*Loader, []Warning, error) {
+	ld, err := NewLoader(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	warnings := ld.Validate()
+	for _, w := range warnings {
+		if w.Kind.Severe() {
+			return nil, warnings, &ValidationError{Warnings: warnings}
+		}
+	}
+	return ld, warnings, nil
+}
+
+var tagHead = MustNewTag("head")
+
+// Validate checks the integrity of the font, returning the problems found, or nil.
+// The following checks are performed:
+//   - the tables must be contained in the file
+//   - the 'head', 'maxp', 'hhea' and 'hmtx' tables must be present and long enough
+//   - the 'loca' table must be consistent with the number of glyphs and the 'glyf' table
+//   - the table checksums must match the table directory (when available, that is not for WOFF2 files)
+//   - the checkSumAdjustment field of the 'head' table must match the file (for single, uncompressed fonts)
+//
+// Note that the tables content is not parsed: use the font package for that.
+func (ld *Loader) Validate() []Warning {
+	var out []Warning
+	warn := func(kind WarningKind, table Tag, format string, args ...interface{}) {
+		out = append(out, Warning{Kind: kind, Table: table, Message: fmt.Sprintf(format, args...)})
+	}
+
+	size, err := ld.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		warn(TableOutOfBounds, 0, "file size not available: %s", err)
+		return out
+	}
+	tables := map[Tag][]byte{}
+	for _, tag := range ld.Tables() {
+		sec := ld.tables[tag]
+		if end := int64(sec.offset) + int64(sec.length); end > size {
+			warn(TableOutOfBounds, tag, "table ends at %d, after the end of the file (%d)", end, size)
+			continue
+		}
+		content, err := ld.findTableBuffer(sec, nil)
+		if err != nil {
+			warn(InvalidTable, tag, "%s", err)
+			continue
+		}
+		tables[tag] = content
+
+		if ld.hasChecksums {
+			if tag == tagHead && len(content) >= 12 {
+				// the checksum is computed with a zero checkSumAdjustment
+				content = append([]byte(nil), content...)
+				binary.BigEndian.PutUint32(content[8:], 0)
+			}
+			if cs := checksum(content); cs != sec.checksum {
+				warn(TableChecksum, tag, "expected 0x%08x, got 0x%08x", sec.checksum, cs)
+			}
+		}
+	}
+
+	// required tables
+	head, maxp, hhea, hmtx := tables[tagHead], tables[tagMaxp], tables[tagHhea], tables[tagHmtx]
+	for _, table := range [...]struct {
+		tag     Tag
+		content []byte
+		minSize int
+	}{{tagHead, head, 54}, {tagMaxp, maxp, 6}, {tagHhea, hhea, 36}, {tagHmtx, hmtx, 0}} {
+		if !ld.HasTable(table.tag) {
+			warn(MissingTable, table.tag, "required table is missing")
+		} else if len(table.content) < table.minSize {
+			warn(InvalidTable, table.tag, "table too short (%d bytes)", len(table.content))
+		}
+	}
+	if len(head) < 54 || len(maxp) < 6 {
+		return out
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+
+	if ld.isSfnt && ld.hasChecksums {
+		if err := ld.validateChecksumAdjustment(size, head); err != nil {
+			warn(HeadChecksumAdjustment, tagHead, "%s", err)
+		}
+	}
+
+	if len(hhea) >= 36 && ld.HasTable(tagHmtx) {
+		numberOfHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+		if numberOfHMetrics == 0 || numberOfHMetrics > numGlyphs {
+			warn(InvalidMetrics, tagHhea, "invalid number of metrics %d (for %d glyphs)", numberOfHMetrics, numGlyphs)
+		} else if expected := 4*numberOfHMetrics + 2*(numGlyphs-numberOfHMetrics); len(hmtx) < expected {
+			warn(InvalidMetrics, tagHmtx, "table too short (%d bytes, expected %d)", len(hmtx), expected)
+		}
+	}
+
+	if glyf, hasGlyf := tables[tagGlyf]; hasGlyf {
+		loca, hasLoca := tables[tagLoca]
+		if !hasLoca {
+			warn(MissingTable, tagLoca, "required table is missing (with a 'glyf' table)")
+		} else if err := validateLoca(loca, glyf, numGlyphs, binary.BigEndian.Uint16(head[50:])); err != nil {
+			warn(InvalidLoca, tagLoca, "%s", err)
+		}
+	}
+
+	return out
+}
+
+// validateChecksumAdjustment checks the checkSumAdjustment field of [head]
+func (ld *Loader) validateChecksumAdjustment(size int64, head []byte) error {
+	file := make([]byte, size)
+	if _, err := ld.file.ReadAt(file, 0); err != nil {
+		return err
+	}
+	// the file checksum is computed with a zero checkSumAdjustment
+	binary.BigEndian.PutUint32(file[ld.tables[tagHead].offset+8:], 0)
+	sum := checksum(file)
+	if expected, got := 0xB1B0AFBA-sum, binary.BigEndian.Uint32(head[8:]); expected != got {
+		return fmt.Errorf("expected 0x%08x, got 0x%08x", expected, got)
+	}
+	return nil
+}
+
+// validateLoca checks that the 'loca' table has [numGlyphs]+1 increasing offsets, inside [glyf]
+func validateLoca(loca, glyf []byte, numGlyphs int, indexToLocFormat uint16) error {
+	var offsets []uint32
+	switch indexToLocFormat {
+	case 0:
+		if len(loca) < 2*(numGlyphs+1) {
+			return fmt.Errorf("table too short (%d bytes) for %d glyphs", len(loca), numGlyphs)
+		}
+		offsets = make([]uint32, numGlyphs+1)
+		for i := range offsets {
+			offsets[i] = 2 * uint32(binary.BigEndian.Uint16(loca[2*i:]))
+		}
+	case 1:
+		if len(loca) < 4*(numGlyphs+1) {
+			return fmt.Errorf("table too short (%d bytes) for %d glyphs", len(loca), numGlyphs)
+		}
+		offsets = parseUint32s(loca, numGlyphs+1)
+	default:
+		return fmt.Errorf("invalid indexToLocFormat %d", indexToLocFormat)
+	}
+	for i := 1; i < len(offsets); i++ {
+		if offsets[i] < offsets[i-1] {
+			return fmt.Errorf("decreasing offsets for glyph %d", i-1)
+		}
+	}
+	if last := offsets[len(offsets)-1]; int(last) > len(glyf) {
+		return fmt.Errorf("offset %d after the end of the 'glyf' table (%d bytes)", last, len(glyf))
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package opentype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	tu "github.com/boxesandglue/typesetting/testutils"
+	td "github.com/go-text/typesetting-utils/opentype"
+)
+
+func TestValidate(t *testing.T) {
+	for _, filename := range tu.Filenames(t, "common") {
+		f, err := td.Files.ReadFile(filename)
+		tu.AssertNoErr(t, err)
+
+		ld, warnings, err := NewLoaderStrict(bytes.NewReader(f))
+		tu.AssertNoErr(t, err)
+		tu.Assert(t, ld != nil)
+		tu.AssertC(t, len(warnings) == 0, filename)
+	}
+}
+
+func loadTables(t *testing.T, filename string) []Table {
+	t.Helper()
+	f, err := td.Files.ReadFile(filename)
+	tu.AssertNoErr(t, err)
+	ld, err := NewLoader(bytes.NewReader(f))
+	tu.AssertNoErr(t, err)
+	tags := ld.Tables()
+	tables := make([]Table, len(tags))
+	for i, tag := range tags {
+		tables[i].Tag = tag
+		tables[i].Content, err = ld.RawTable(tag)
+		tu.AssertNoErr(t, err)
+	}
+	return tables
+}
+
+func TestValidateChecksums(t *testing.T) {
+	f, err := td.Files.ReadFile("common/Roboto-BoldItalic.ttf")
+	tu.AssertNoErr(t, err)
+	ld, err := NewLoader(bytes.NewReader(f))
+	tu.AssertNoErr(t, err)
+
+	// modify one byte of the 'name' table
+	name := ld.tables[MustNewTag("name")]
+	corrupted := append([]byte(nil), f...)
+	corrupted[name.offset+name.length-1]++
+
+	ld, warnings, err := NewLoaderStrict(bytes.NewReader(corrupted))
+	tu.AssertNoErr(t, err) // checksums mismatches are not severe
+	tu.Assert(t, ld != nil)
+	tu.Assert(t, len(warnings) == 2)
+	tu.Assert(t, warnings[0].Kind == TableChecksum && warnings[0].Table == MustNewTag("name"))
+	tu.Assert(t, warnings[1].Kind == HeadChecksumAdjustment)
+}
+
+func TestValidateLoca(t *testing.T) {
+	tables := loadTables(t, "common/Roboto-BoldItalic.ttf")
+	for i, table := range tables {
+		if table.Tag == tagLoca {
+			// increase the last offset past the end of 'glyf'
+			loca := append([]byte(nil), table.Content...)
+			last := loca[len(loca)-2:]
+			binary.BigEndian.PutUint16(last, binary.BigEndian.Uint16(last)+100)
+			tables[i].Content = loca
+		}
+	}
+
+	_, warnings, err := NewLoaderStrict(bytes.NewReader(WriteTTF(tables)))
+	var ve *ValidationError
+	tu.Assert(t, errors.As(err, &ve))
+	tu.Assert(t, len(warnings) == 1 && warnings[0].Kind == InvalidLoca)
+	tu.Assert(t, len(ve.Warnings) == 1)
+}
+
+func TestValidateMissingTable(t *testing.T) {
+	tables := loadTables(t, "common/Roboto-BoldItalic.ttf")
+	var filtered []Table
+	for _, table := range tables {
+		if table.Tag != tagHmtx {
+			filtered = append(filtered, table)
+		}
+	}
+
+	_, warnings, err := NewLoaderStrict(bytes.NewReader(WriteTTF(filtered)))
+	tu.Assert(t, err != nil)
+	tu.Assert(t, len(warnings) == 1 && warnings[0].Kind == MissingTable && warnings[0].Table == tagHmtx)
+}
+
+func TestValidateTruncated(t *testing.T) {
+	f, err := td.Files.ReadFile("common/Roboto-BoldItalic.ttf")
+	tu.AssertNoErr(t, err)
+
+	ld, err := NewLoader(bytes.NewReader(f[:len(f)/2]))
+	tu.AssertNoErr(t, err) // the table directory is still valid
+
+	warnings := ld.Validate()
+	tu.Assert(t, len(warnings) != 0)
+	hasOutOfBounds := false
+	for _, w := range warnings {
+		hasOutOfBounds = hasOutOfBounds || w.Kind == TableOutOfBounds
+	}
+	tu.Assert(t, hasOutOfBounds)
+}
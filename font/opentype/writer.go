@@ -12,16 +12,33 @@ type Table struct {
 }
 
 // WriteTTF creates a single Truetype font file (.ttf) from the given [tables] slice,
-// which must be sorted by Tag
+// which must be sorted by Tag. Each table is padded to a 4-byte boundary, and
+// the checkSumAdjustment field of the 'head' table, if any, is updated in the output.
+// The file has the [OpenType] signature if [tables] contains a 'CFF ' or 'CFF2' table,
+// and the [TrueType] signature otherwise.
 func WriteTTF(tables []Table) []byte {
 	introLength := uint32(otfHeaderSize + len(tables)*otfEntrySize)
 	buffer := make([]byte, introLength)
 
-	writeTTFHeader(len(tables), buffer)
+	signature := TrueType
+	for _, table := range tables {
+		if table.Tag == MustNewTag("CFF ") || table.Tag == MustNewTag("CFF2") {
+			signature = OpenType
+		}
+	}
+	writeTTFHeader(signature, len(tables), buffer)
 
 	tableOffset := introLength // the actual content will start after the header + table directory
+	headOffset := -1
 	for i, table := range tables {
 		cs := checksum(table.Content)
+		if table.Tag == MustNewTag("head") && len(table.Content) >= 12 {
+			// the checksum is computed with a zero checkSumAdjustment
+			head := append([]byte(nil), table.Content...)
+			binary.BigEndian.PutUint32(head[8:], 0)
+			cs = checksum(head)
+			headOffset = int(tableOffset)
+		}
 		tableLength := uint32(len(table.Content))
 
 		slice := buffer[otfHeaderSize+i*otfEntrySize:]
@@ -31,7 +48,7 @@ func WriteTTF(tables []Table) []byte {
 		binary.BigEndian.PutUint32(slice[12:], tableLength)
 
 		// update the offset
-		tableOffset = tableOffset + tableLength
+		tableOffset = tableOffset + padded(tableLength)
 	}
 
 	// append the actual table content :
@@ -40,14 +57,22 @@ func WriteTTF(tables []Table) []byte {
 	tableOffset = introLength
 	for _, table := range tables {
 		copy(buffer[tableOffset:], table.Content)
-		tableOffset = tableOffset + uint32(len(table.Content))
+		tableOffset = tableOffset + padded(uint32(len(table.Content)))
+	}
+
+	if headOffset != -1 {
+		binary.BigEndian.PutUint32(buffer[headOffset+8:], 0)
+		binary.BigEndian.PutUint32(buffer[headOffset+8:], 0xB1B0AFBA-checksum(buffer))
 	}
 
 	return buffer
 }
 
+// padded returns the length of a table padded to 4 bytes
+func padded(length uint32) uint32 { return (length + 3) &^ 3 }
+
 // out is assumed to have a length >= ttfHeaderSize
-func writeTTFHeader(nTables int, out []byte) {
+func writeTTFHeader(signature Tag, nTables int, out []byte) {
 	log2 := math.Floor(math.Log2(float64(nTables)))
 	// Maximum power of 2 less than or equal to numTables, times 16 ((2**floor(log2(numTables))) * 16, where “**” is an exponentiation operator).
 	searchRange := math.Pow(2, log2) * 16
@@ -56,7 +81,7 @@ func writeTTFHeader(nTables int, out []byte) {
 	// numTables times 16, minus searchRange ((numTables * 16) - searchRange).
 	rangeShift := nTables*16 - int(searchRange)
 
-	binary.BigEndian.PutUint32(out[:], uint32(TrueType))
+	binary.BigEndian.PutUint32(out[:], uint32(signature))
 	binary.BigEndian.PutUint16(out[4:], uint16(nTables))
 	binary.BigEndian.PutUint16(out[6:], uint16(searchRange))
 	binary.BigEndian.PutUint16(out[8:], uint16(entrySelector))
@@ -68,7 +93,7 @@ func checksum(table []byte) uint32 {
 	// the above algorithm must be modified to treat the data as though
 	// it contains zero padding to a length that is a multiple of four."
 	if r := len(table) % 4; r != 0 {
-		table = append(table, make([]byte, r)...)
+		table = append(table[:len(table):len(table)], make([]byte, 4-r)...)
 	}
 
 	var sum uint32
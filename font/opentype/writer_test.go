@@ -27,12 +27,26 @@ func TestWrite(t *testing.T) {
 		content := WriteTTF(tables)
 		font2, err := NewLoader(bytes.NewReader(content))
 		tu.AssertNoErr(t, err)
+		tu.Assert(t, len(content)%4 == 0)
+		// the whole file sums to the magic number
+		tu.Assert(t, checksum(content) == 0xB1B0AFBA)
 
 		for _, table := range tables {
 			t2, err := font2.RawTable(table.Tag)
 			tu.AssertNoErr(t, err)
 
+			if table.Tag == MustNewTag("head") { // checkSumAdjustment is updated
+				tu.Assert(t, bytes.Equal(table.Content[:8], t2[:8]) && bytes.Equal(table.Content[12:], t2[12:]))
+				continue
+			}
 			tu.Assert(t, bytes.Equal(table.Content, t2))
 		}
 	}
 }
+
+func TestChecksum(t *testing.T) {
+	tu.Assert(t, checksum([]byte{1, 2, 3, 4}) == 0x01020304)
+	// the last bytes are padded with zeros
+	tu.Assert(t, checksum([]byte{1, 2, 3, 4, 5}) == 0x01020304+0x05000000)
+	tu.Assert(t, checksum([]byte{1, 2, 3, 4, 5, 6, 7}) == 0x01020304+0x05060700)
+}
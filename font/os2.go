@@ -16,6 +16,7 @@ type os2 struct {
 	*os2Desc
 
 	useTypoMetrics bool // true if the field sTypoAscender, sTypoDescender and sTypoLineGap are valid.
+	isValid        bool // false if the table is missing or invalid
 
 	ySubscriptXSize     float32
 	ySubscriptYSize     float32
@@ -24,6 +25,7 @@ type os2 struct {
 	ySuperscriptXSize   float32
 	ySuperscriptYSize   float32
 	ySuperscriptXOffset float32
+	ySuperscriptYOffset float32
 	yStrikeoutSize      float32
 	yStrikeoutPosition  float32
 	sTypoAscender       float32
@@ -31,6 +33,8 @@ type os2 struct {
 	sTypoLineGap        float32
 	sxHeigh             float32
 	sCapHeight          float32
+	usWinAscent         float32
+	usWinDescent        float32
 }
 
 func newOs2(os tables.Os2) (os2, error) {
@@ -45,11 +49,14 @@ func newOs2(os tables.Os2) (os2, error) {
 		ySuperscriptXSize:   float32(os.YSuperscriptXSize),
 		ySuperscriptYSize:   float32(os.YSuperscriptYSize),
 		ySuperscriptXOffset: float32(os.YSuperscriptXOffset),
+		ySuperscriptYOffset: float32(os.YSuperscriptYOffset),
 		yStrikeoutSize:      float32(os.YStrikeoutSize),
 		yStrikeoutPosition:  float32(os.YStrikeoutPosition),
 		sTypoAscender:       float32(os.STypoAscender),
 		sTypoDescender:      float32(os.STypoDescender),
 		sTypoLineGap:        float32(os.STypoLineGap),
+		usWinAscent:         float32(os.USWinAscent),
+		usWinDescent:        float32(os.USWinDescent),
 	}
 	// add addition info for version >= 2
 	if os.Version >= 2 {
@@ -2,7 +2,13 @@
 
 package font
 
-import "github.com/boxesandglue/typesetting/font/opentype/tables"
+import (
+	"fmt"
+	"sort"
+
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
+)
 
 // shared between GSUB and GPOS
 type Layout struct {
@@ -253,3 +259,291 @@ func newGPOS(table tables.Layout) (GPOS, error) {
 	}
 	return out, nil
 }
+
+// langSys returns the language system for the OpenType [script] and [language] tags.
+// If [script] is not found, the default script is used; if [language] is not found,
+// the default language system of the script is used.
+func (la *Layout) langSys(script, language Tag) (tables.LangSys, bool) {
+	index := la.FindScript(script)
+	if index == -1 {
+		index = la.FindScript(tagDefaultScript)
+	}
+	if index == -1 {
+		return tables.LangSys{}, false
+	}
+	sc := la.Scripts[index].Script
+	langIndex := sc.FindLanguage(language) // -1 selects the default language system
+	if langIndex == -1 && sc.DefaultLangSys == nil {
+		return tables.LangSys{}, false
+	}
+	return sc.GetLangSys(uint16(langIndex)), true
+}
+
+// LayoutFeature is a feature provided by the GSUB or GPOS tables
+// for a script and language.
+type LayoutFeature struct {
+	Tag Tag
+	// Required is true for the required feature of the language system,
+	// which is always applied by the shapers and can't be disabled.
+	Required bool
+	// InGSUB and InGPOS report the tables defining the feature.
+	InGSUB, InGPOS bool
+}
+
+// FeaturesForScript returns the features defined in the GSUB and GPOS tables
+// for the OpenType [script] and [language] tags (such as 'latn' and 'TRK '), sorted by tag.
+// It may be used to display the features a user may toggle for a run of text.
+//
+// If [script] is not supported by the font, the features of the default script ('DFLT')
+// are returned. If [language] is not found (or is zero), the default language system of
+// the script is used.
+func (f *Font) FeaturesForScript(script, language Tag) []LayoutFeature {
+	var out []LayoutFeature
+	add := func(tag Tag, required, isGSUB bool) {
+		index := -1
+		for i, feat := range out {
+			if feat.Tag == tag {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			out = append(out, LayoutFeature{Tag: tag})
+			index = len(out) - 1
+		}
+		feat := &out[index]
+		feat.Required = feat.Required || required
+		if isGSUB {
+			feat.InGSUB = true
+		} else {
+			feat.InGPOS = true
+		}
+	}
+	for i, la := range [2]*Layout{&f.GSUB.Layout, &f.GPOS.Layout} {
+		langSys, ok := la.langSys(script, language)
+		if !ok {
+			continue
+		}
+		if index := langSys.RequiredFeatureIndex; int(index) < len(la.Features) {
+			add(la.Features[index].Tag, true, i == 0)
+		}
+		for _, index := range langSys.FeatureIndices {
+			if int(index) < len(la.Features) {
+				add(la.Features[index].Tag, false, i == 0)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Tag < out[j].Tag })
+	return out
+}
+
+// FeatureNames are the user interface strings the font provides for a stylistic set
+// ('ss01' to 'ss20') or character variant ('cv01' to 'cv99') feature, so that
+// applications may present them instead of the raw feature tag.
+// Missing strings are empty.
+type FeatureNames struct {
+	// Label is the name of the feature.
+	Label string
+	// Tooltip is a description of the feature (only used by character variants).
+	Tooltip string
+	// SampleText illustrates the effect of the feature (only used by character variants).
+	SampleText string
+	// ParamLabels are the names of the feature values 1, 2, etc...,
+	// for character variants providing several variants (it may be empty).
+	ParamLabels []string
+	// Characters are the characters for which the character variant
+	// provides glyph variants (it may be empty).
+	Characters []rune
+}
+
+func isStylisticSet(tag Tag) bool {
+	if tag>>16 != 's'<<8|'s' {
+		return false
+	}
+	n := featureNumber(tag)
+	return 1 <= n && n <= 20
+}
+
+func isCharacterVariant(tag Tag) bool {
+	if tag>>16 != 'c'<<8|'v' {
+		return false
+	}
+	n := featureNumber(tag)
+	return 1 <= n && n <= 99
+}
+
+// featureNumber returns the number formed by the two last (digit) bytes of [tag], or -1
+func featureNumber(tag Tag) int {
+	d1, d2 := byte(tag>>8), byte(tag)
+	if d1 < '0' || d1 > '9' || d2 < '0' || d2 > '9' {
+		return -1
+	}
+	return int(d1-'0')*10 + int(d2-'0')
+}
+
+// FeatureNames returns the user interface strings defined by the FeatureParams table of
+// the GSUB stylistic set or character variant feature [tag], resolved using the 'name' table.
+// It returns false if [tag] is not a stylistic set or a character variant,
+// or if the font does not provide such strings.
+func (f *Font) FeatureNames(tag Tag) (FeatureNames, bool) {
+	ssFeature, cvFeature := isStylisticSet(tag), isCharacterVariant(tag)
+	if !ssFeature && !cvFeature {
+		return FeatureNames{}, false
+	}
+	name := func(id tables.NameID) string {
+		if id == 0 { // NULL name ID
+			return ""
+		}
+		return f.names.Name(id)
+	}
+	for _, feat := range f.GSUB.Features {
+		if feat.Tag != tag || feat.FeatureParams == nil {
+			continue
+		}
+		if ssFeature {
+			params, _, err := tables.ParseFeatureParamsStylisticSet(feat.FeatureParams)
+			if err != nil {
+				continue
+			}
+			return FeatureNames{Label: name(params.UINameID)}, true
+		}
+		params, _, err := tables.ParseFeatureParamsCharacterVariants(feat.FeatureParams)
+		if err != nil {
+			continue
+		}
+		out := FeatureNames{
+			Label:      name(params.FeatUILabelNameID),
+			Tooltip:    name(params.FeatUITooltipTextNameID),
+			SampleText: name(params.SampleTextNameID),
+			Characters: params.Characters,
+		}
+		if params.NumNamedParameters != 0 {
+			out.ParamLabels = make([]string, params.NumNamedParameters)
+			for i := range out.ParamLabels {
+				out.ParamLabels[i] = name(params.FirstParamUILabelNameID + tables.NameID(i))
+			}
+		}
+		return out, true
+	}
+	return FeatureNames{}, false
+}
+
+// DesignSize is the optical size a font has been designed for,
+// as defined by the GPOS 'size' feature.
+type DesignSize struct {
+	// Size is the design size, in points.
+	Size float32
+	// RangeStart (exclusive) and RangeEnd (inclusive) are the range
+	// of sizes, in points, for which the font is recommended.
+	// They are zero if not provided.
+	RangeStart, RangeEnd float32
+	// SubfamilyID identifies the fonts of a family sharing the same
+	// subfamily, and thus the same size range.
+	// It is zero if the font is the only one of its family.
+	SubfamilyID uint16
+	// SubfamilyName is the name of the subfamily, such as "Caption", or an empty string.
+	SubfamilyName string
+}
+
+// DesignSize returns the parameters of the GPOS 'size' feature,
+// or false if the font has no such (valid) feature.
+func (f *Font) DesignSize() (DesignSize, bool) {
+	sizeTag := ot.MustNewTag("size")
+	for _, feat := range f.GPOS.Features {
+		if feat.Tag != sizeTag || feat.FeatureParams == nil {
+			continue
+		}
+		params, _, err := tables.ParseFeatureParamsSize(feat.FeatureParams)
+		if err != nil || !params.IsValid() {
+			continue
+		}
+		out := DesignSize{
+			Size:        float32(params.DesignSize) / 10,
+			RangeStart:  float32(params.RangeStart) / 10,
+			RangeEnd:    float32(params.RangeEnd) / 10,
+			SubfamilyID: params.SubfamilyID,
+		}
+		if params.SubfamilyNameID != 0 {
+			out.SubfamilyName = f.names.Name(params.SubfamilyNameID)
+		}
+		return out, true
+	}
+	return DesignSize{}, false
+}
+
+// JustificationPriorities returns the justification suggestions of the 'JSTF' table
+// for the OpenType [script] and [language] tags, by decreasing priority.
+// Each priority lists the GSUB and GPOS lookups to enable or disable to
+// shrink or extend the text, which should be tried in turn, until the line is justified.
+//
+// If [language] is not found (or is zero), the default language system of the script
+// is used. It returns nil if the font has no justification data for [script].
+func (f *Font) JustificationPriorities(script, language Tag) []tables.JstfPriority {
+	jstf := f.JSTF()
+	index := jstf.FindScript(script)
+	if index == -1 {
+		return nil
+	}
+	sc := &jstf.Scripts[index]
+	return sc.GetLangSys(sc.FindLanguage(language)).Priorities
+}
+
+// ExtenderGlyphs returns the glyphs (such as kashidas) the 'JSTF' table
+// defines for [script], which may be inserted to extend the text.
+func (f *Font) ExtenderGlyphs(script Tag) []GID {
+	jstf := f.JSTF()
+	index := jstf.FindScript(script)
+	if index == -1 {
+		return nil
+	}
+	glyphs := jstf.Scripts[index].ExtenderGlyph.Glyphs
+	out := make([]GID, len(glyphs))
+	for i, g := range glyphs {
+		out[i] = GID(g)
+	}
+	return out
+}
+
+// MarkGlyphSet returns the marks of the GDEF mark glyph set at [index], sorted by glyph,
+// or false if the font has no such set.
+// The set is used to filter the marks processed by the lookups having the [UseMarkFilteringSet]
+// flag, with [LookupOptions.MarkFilteringSet] as index : the other marks are skipped.
+func (f *Font) MarkGlyphSet(index uint16) ([]GID, bool) {
+	coverages := f.GDEF.MarkGlyphSetsDef.Coverages
+	if int(index) >= len(coverages) {
+		return nil, false
+	}
+	var out []GID
+	for _, rg := range coverages[index].RangeRecords() {
+		for g := int(rg.StartGlyphID); g <= int(rg.EndGlyphID); g++ {
+			out = append(out, GID(g))
+		}
+	}
+	return out, true
+}
+
+// ValidateMarkFilteringSets checks that every GSUB and GPOS lookup using a mark filtering set
+// references a set defined in the GDEF table, returning an error describing the first invalid lookup.
+//
+// Such fonts are still usable: as in HarfBuzz, an invalid set is treated as an empty one, meaning
+// that every mark is skipped by the lookup.
+func (f *Font) ValidateMarkFilteringSets() error {
+	setCount := len(f.GDEF.MarkGlyphSetsDef.Coverages)
+	check := func(table string, index int, lo LookupOptions) error {
+		if lo.Flag&UseMarkFilteringSet != 0 && int(lo.MarkFilteringSet) >= setCount {
+			return fmt.Errorf("%s lookup %d: invalid mark filtering set %d (for %d sets)", table, index, lo.MarkFilteringSet, setCount)
+		}
+		return nil
+	}
+	for i, lk := range f.GSUB.Lookups {
+		if err := check("GSUB", i, lk.LookupOptions); err != nil {
+			return err
+		}
+	}
+	for i, lk := range f.GPOS.Lookups {
+		if err := check("GPOS", i, lk.LookupOptions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,12 +3,15 @@
 package font
 
 import (
+	"bytes"
 	"reflect"
 	"sort"
 	"testing"
 
+	ot "github.com/boxesandglue/typesetting/font/opentype"
 	"github.com/boxesandglue/typesetting/font/opentype/tables"
 	tu "github.com/boxesandglue/typesetting/testutils"
+	hd "github.com/go-text/typesetting-utils/harfbuzz"
 )
 
 func TestGetProps(t *testing.T) {
@@ -74,3 +77,106 @@ func TestOTFeatureVariation(t *testing.T) {
 	tu.Assert(t, gsub.FindVariationIndex([]VarCoord{tables.NewCoord(0.8)}) == 0)
 	tu.Assert(t, gsub.FindVariationIndex([]VarCoord{tables.NewCoord(0.4)}) == -1)
 }
+
+func TestFeaturesForScript(t *testing.T) {
+	ft := loadFont(t, "common/NotoSansArabic.ttf")
+
+	features := ft.FeaturesForScript(ot.MustNewTag("arab"), ot.MustNewTag("URD "))
+	tu.Assert(t, sort.SliceIsSorted(features, func(i, j int) bool { return features[i].Tag < features[j].Tag }))
+	byTag := map[Tag]LayoutFeature{}
+	for _, feat := range features {
+		tu.Assert(t, feat.InGSUB || feat.InGPOS)
+		byTag[feat.Tag] = feat
+	}
+	tu.Assert(t, byTag[ot.MustNewTag("init")].InGSUB && !byTag[ot.MustNewTag("init")].InGPOS)
+	tu.Assert(t, byTag[ot.MustNewTag("mark")].InGPOS)
+
+	// unknown languages use the default language system,
+	// unknown scripts the default script
+	tu.Assert(t, len(ft.FeaturesForScript(ot.MustNewTag("arab"), 0)) != 0)
+	tu.Assert(t, len(ft.FeaturesForScript(ot.MustNewTag("arab"), ot.MustNewTag("XXX "))) != 0)
+	tu.Assert(t, reflect.DeepEqual(ft.FeaturesForScript(ot.MustNewTag("xxxx"), 0), ft.FeaturesForScript(ot.MustNewTag("DFLT"), 0)))
+
+	// required feature
+	ft.GSUB.Scripts = append([]Script(nil), ft.GSUB.Scripts...)
+	index := ft.GSUB.FindScript(ot.MustNewTag("arab"))
+	def := *ft.GSUB.Scripts[index].DefaultLangSys
+	rlig, _ := ft.GSUB.FindFeatureIndex(ot.MustNewTag("rlig"))
+	def.RequiredFeatureIndex = rlig
+	ft.GSUB.Scripts[index].DefaultLangSys = &def
+	for _, feat := range ft.FeaturesForScript(ot.MustNewTag("arab"), 0) {
+		tu.Assert(t, feat.Required == (feat.Tag == ot.MustNewTag("rlig")))
+	}
+}
+
+func TestMarkGlyphSets(t *testing.T) {
+	ft := loadFont(t, "common/NotoSansArabic.ttf")
+
+	for index := range ft.GDEF.MarkGlyphSetsDef.Coverages {
+		glyphs, ok := ft.MarkGlyphSet(uint16(index))
+		tu.Assert(t, ok && len(glyphs) != 0)
+		tu.Assert(t, sort.SliceIsSorted(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] }))
+		for _, g := range glyphs {
+			tu.Assert(t, ft.GDEF.GlyphProps(tables.GlyphID(g)) == tables.GPMark)
+		}
+	}
+	_, ok := ft.MarkGlyphSet(uint16(len(ft.GDEF.MarkGlyphSetsDef.Coverages)))
+	tu.Assert(t, !ok)
+
+	tu.AssertNoErr(t, ft.ValidateMarkFilteringSets())
+	ft.GPOS.Lookups = append([]GPOSLookup(nil), ft.GPOS.Lookups...)
+	ft.GPOS.Lookups[0].Flag |= UseMarkFilteringSet
+	ft.GPOS.Lookups[0].MarkFilteringSet = 10
+	tu.Assert(t, ft.ValidateMarkFilteringSets() != nil)
+}
+
+func TestFeatureNames(t *testing.T) {
+	load := func(filename string) *Font {
+		file, err := hd.Files.ReadFile(filename)
+		tu.AssertNoErr(t, err)
+		ld, err := ot.NewLoader(bytes.NewReader(file))
+		tu.AssertNoErr(t, err)
+		ft, err := NewFont(ld)
+		tu.AssertNoErr(t, err)
+		return ft
+	}
+
+	ft := load("fonts/cv01.otf")
+	names, ok := ft.FeatureNames(ot.MustNewTag("cv01"))
+	tu.Assert(t, ok)
+	tu.Assert(t, reflect.DeepEqual(names, FeatureNames{
+		Label:       "uilabel simple a",
+		Tooltip:     "tool tip simple a",
+		SampleText:  "sample text simple a",
+		ParamLabels: []string{"param1 text simple a", "param2 text simple a"},
+		Characters:  []rune{0x0A, 0x5DDE},
+	}))
+	_, ok = ft.FeatureNames(ot.MustNewTag("cv02"))
+	tu.Assert(t, !ok)
+
+	ft = load("fonts/SourceSansPro-Regular.otf")
+	names, ok = ft.FeatureNames(ot.MustNewTag("ss01"))
+	tu.Assert(t, ok && names.Label == "Straight l" && names.Tooltip == "" && names.Characters == nil)
+	_, ok = ft.FeatureNames(ot.MustNewTag("liga"))
+	tu.Assert(t, !ok)
+
+	// null name IDs are not resolved
+	ft = load("harfbuzz_reference/in-house/fonts/08b4b136f418add748dc641eb4a83033476f1170.ttf")
+	names, ok = ft.FeatureNames(ot.MustNewTag("cv01"))
+	tu.Assert(t, ok && names.Label == "")
+}
+
+func TestDesignSize(t *testing.T) {
+	ft := loadFont(t, "common/Lmmono-italic.otf")
+	ds, ok := ft.DesignSize()
+	tu.Assert(t, ok)
+	tu.Assert(t, ds == DesignSize{Size: 10, RangeStart: 5, RangeEnd: 20, SubfamilyID: 2, SubfamilyName: "Italic"})
+
+	ft = loadFont(t, "toys/CFF2-VF.otf")
+	ds, ok = ft.DesignSize()
+	tu.Assert(t, ok && ds == DesignSize{Size: 10})
+
+	ft = loadFont(t, "common/Raleway-v4020-Regular.otf")
+	_, ok = ft.DesignSize()
+	tu.Assert(t, !ok)
+}
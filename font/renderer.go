@@ -109,7 +109,7 @@ type BitmapSize struct {
 // not found.
 func (f *Face) GlyphData(gid GID) GlyphData {
 	// since outline may be specified for SVG and bitmaps, check it at the end
-	outB, err := f.sbix.glyphData(gID(gid), f.xPpem, f.yPpem)
+	outB, err := f.sbixTable().glyphData(gID(gid), f.xPpem, f.yPpem)
 	if err == nil {
 		outline, ok := f.outlineGlyphData(gID(gid))
 		if ok {
@@ -118,7 +118,7 @@ func (f *Face) GlyphData(gid GID) GlyphData {
 		return outB
 	}
 
-	outB, err = f.bitmap.glyphData(gID(gid), f.xPpem, f.yPpem)
+	outB, err = f.bitmapTable().glyphData(gID(gid), f.xPpem, f.yPpem)
 	if err == nil {
 		outline, ok := f.outlineGlyphData(gID(gid))
 		if ok {
@@ -421,12 +421,12 @@ func (font *Font) BitmapSizes() []BitmapSize {
 	}
 
 	// adapted from freetype tt_face_load_sbit
-	if font.bitmap != nil {
-		return font.bitmap.availableSizes(avgWidth, upem)
+	if bm := font.bitmapTable(); bm != nil {
+		return bm.availableSizes(avgWidth, upem)
 	}
 
 	if hori := font.hhea; hori != nil {
-		return font.sbix.availableSizes(hori, avgWidth, upem)
+		return font.sbixTable().availableSizes(hori, avgWidth, upem)
 	}
 
 	return nil
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package font
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
+)
+
+// StyleAttributes returns the 'STAT' table of the font, which describes
+// its design axes and names their values.
+// An absent table has no design axes.
+func (f *Font) StyleAttributes() tables.STAT { return f.stat }
+
+// StyleName composes the style name (such as "Bold Condensed Italic") of the font
+// at the given design coordinates, using the names provided by the 'STAT' table.
+//
+// [coords] are expressed in design units, one per variation axis (see [Font.NamedInstances]).
+// Nil uses the default coordinates. For design axes which are not variation axes,
+// the first value defined by the 'STAT' table is used.
+//
+// Elidable values (like "Regular") are omitted, unless all the values are elidable, in which
+// case the elided fallback name is returned.
+// An empty string is returned if the font has no 'STAT' table.
+func (f *Font) StyleName(coords []float32) string {
+	axes := f.stat.DesignAxes
+	if len(axes) == 0 {
+		return ""
+	}
+	if coords == nil {
+		coords = f.fvar.getDesignCoordsDefault(nil)
+	}
+	if len(coords) != len(f.fvar) {
+		return ""
+	}
+
+	// resolve the position on each design axis
+	positions := make([]float32, len(axes))
+	isDefined := make([]bool, len(axes))
+	for i, axis := range axes {
+		for j, varAxis := range f.fvar {
+			if varAxis.Tag == axis.Tag {
+				positions[i], isDefined[i] = coords[j], true
+				break
+			}
+		}
+	}
+	for _, value := range f.stat.AxisValues {
+		index, pos, ok := axisValueDefault(value)
+		if ok && int(index) < len(axes) && !isDefined[index] {
+			positions[index], isDefined[index] = pos, true
+		}
+	}
+
+	type namedValue struct {
+		name     tables.NameID
+		ordering uint16
+		elidable bool
+	}
+	var (
+		values  []namedValue
+		covered = make([]bool, len(axes))
+	)
+	// combinations of values take precedence
+	for _, value := range f.stat.AxisValues {
+		v4, ok := value.(tables.AxisValueFormat4)
+		if !ok || !matchesAxisValue4(v4, positions, covered) {
+			continue
+		}
+		ordering := uint16(0xFFFF)
+		for _, entry := range v4.AxisValues {
+			covered[entry.AxisIndex] = true
+			if o := axes[entry.AxisIndex].Ordering; o < ordering {
+				ordering = o
+			}
+		}
+		values = append(values, namedValue{v4.ValueNameID, ordering, v4.AxisValueFlags&tables.ElidableAxisValueName != 0})
+	}
+	for _, value := range f.stat.AxisValues {
+		index, ok := matchesAxisValue(value, positions)
+		if !ok || covered[index] {
+			continue
+		}
+		covered[index] = true // only use the first value
+		values = append(values, namedValue{value.Name(), axes[index].Ordering, value.Flags()&tables.ElidableAxisValueName != 0})
+	}
+
+	sort.SliceStable(values, func(i, j int) bool { return values[i].ordering < values[j].ordering })
+	var names []string
+	for _, value := range values {
+		if value.elidable {
+			continue
+		}
+		if name := f.names.Name(value.name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return f.names.Name(f.stat.ElidedFallbackNameID)
+	}
+	return strings.Join(names, " ")
+}
+
+// axisValueDefault returns the value used for design axis
+// which are not variation axis
+func axisValueDefault(value tables.AxisValue) (uint16, float32, bool) {
+	switch value := value.(type) {
+	case tables.AxisValueFormat1:
+		return value.AxisIndex, value.Value, true
+	case tables.AxisValueFormat2:
+		return value.AxisIndex, value.NominalValue, true
+	case tables.AxisValueFormat3:
+		return value.AxisIndex, value.Value, true
+	}
+	return 0, 0, false
+}
+
+// matchesAxisValue returns the axis index if [value] (with format 1, 2 or 3)
+// matches [positions]
+func matchesAxisValue(value tables.AxisValue, positions []float32) (uint16, bool) {
+	var (
+		index  uint16
+		lo, hi float32
+	)
+	switch value := value.(type) {
+	case tables.AxisValueFormat1:
+		index, lo, hi = value.AxisIndex, value.Value, value.Value
+	case tables.AxisValueFormat2:
+		index, lo, hi = value.AxisIndex, value.RangeMinValue, value.RangeMaxValue
+	case tables.AxisValueFormat3:
+		index, lo, hi = value.AxisIndex, value.Value, value.Value
+	default:
+		return 0, false
+	}
+	if int(index) >= len(positions) {
+		return 0, false
+	}
+	pos := positions[index]
+	return index, lo <= pos && pos <= hi
+}
+
+func matchesAxisValue4(value tables.AxisValueFormat4, positions []float32, covered []bool) bool {
+	if len(value.AxisValues) == 0 {
+		return false
+	}
+	for _, entry := range value.AxisValues {
+		if int(entry.AxisIndex) >= len(positions) || covered[entry.AxisIndex] || positions[entry.AxisIndex] != entry.Value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,477 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package subset
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// DICT operators, with escaped operators stored as 12<<8 | op
+const (
+	opCharset     = 15
+	opEncoding    = 16
+	opCharStrings = 17
+	opPrivate     = 18
+	opSubrs       = 19
+	opROS         = 12<<8 | 30
+	opFDArray     = 12<<8 | 36
+	opFDSelect    = 12<<8 | 37
+)
+
+// cffIndex returns the items of the INDEX starting at [offset], and its total length.
+func cffIndex(data []byte, offset int) ([][]byte, int, error) {
+	if len(data) < offset+2 {
+		return nil, 0, errors.New("invalid CFF INDEX (EOF)")
+	}
+	count := int(binary.BigEndian.Uint16(data[offset:]))
+	if count == 0 {
+		return nil, 2, nil
+	}
+	if len(data) < offset+3 {
+		return nil, 0, errors.New("invalid CFF INDEX (EOF)")
+	}
+	offSize := int(data[offset+2])
+	if offSize < 1 || offSize > 4 {
+		return nil, 0, fmt.Errorf("invalid CFF INDEX offset size %d", offSize)
+	}
+	offsetsStart := offset + 3
+	dataStart := offsetsStart + (count+1)*offSize - 1 // offsets are 1-based
+	if len(data) < dataStart+1 {
+		return nil, 0, errors.New("invalid CFF INDEX (EOF)")
+	}
+	readOffset := func(i int) int {
+		var v int
+		for _, b := range data[offsetsStart+i*offSize : offsetsStart+(i+1)*offSize] {
+			v = v<<8 | int(b)
+		}
+		return v
+	}
+	items := make([][]byte, count)
+	for i := range items {
+		start, end := readOffset(i), readOffset(i+1)
+		if start < 1 || start > end || dataStart+end > len(data) {
+			return nil, 0, errors.New("invalid CFF INDEX offsets")
+		}
+		items[i] = data[dataStart+start : dataStart+end]
+	}
+	return items, dataStart + readOffset(count) - offset, nil
+}
+
+// appendIndex appends the INDEX made of [items]
+func appendIndex(dst []byte, items [][]byte) []byte {
+	dst = append(dst, byte(len(items)>>8), byte(len(items)))
+	if len(items) == 0 {
+		return dst
+	}
+	size := 1
+	for _, item := range items {
+		size += len(item)
+	}
+	offSize := 1
+	for ; size >= 1<<(8*offSize); offSize++ {
+	}
+	dst = append(dst, byte(offSize))
+	offset := 1
+	appendOffset := func() {
+		for i := offSize - 1; i >= 0; i-- {
+			dst = append(dst, byte(offset>>(8*i)))
+		}
+	}
+	appendOffset()
+	for _, item := range items {
+		offset += len(item)
+		appendOffset()
+	}
+	for _, item := range items {
+		dst = append(dst, item...)
+	}
+	return dst
+}
+
+// dictEntry is an operator of a DICT, with its operands
+type dictEntry struct {
+	raw      []byte // the encoded operands and operator
+	operands []int  // real numbers are stored as 0
+	op       int
+}
+
+func parseDict(data []byte) ([]dictEntry, error) {
+	var (
+		out      []dictEntry
+		operands []int
+		start    int
+	)
+	for pos := 0; pos < len(data); {
+		b := data[pos]
+		switch {
+		case b <= 21: // operator
+			op := int(b)
+			pos++
+			if b == 12 {
+				if pos >= len(data) {
+					return nil, errors.New("invalid CFF DICT (EOF)")
+				}
+				op = 12<<8 | int(data[pos])
+				pos++
+			}
+			out = append(out, dictEntry{raw: data[start:pos], operands: operands, op: op})
+			operands, start = nil, pos
+		case b == 28:
+			if len(data) < pos+3 {
+				return nil, errors.New("invalid CFF DICT (EOF)")
+			}
+			operands = append(operands, int(int16(binary.BigEndian.Uint16(data[pos+1:]))))
+			pos += 3
+		case b == 29:
+			if len(data) < pos+5 {
+				return nil, errors.New("invalid CFF DICT (EOF)")
+			}
+			operands = append(operands, int(int32(binary.BigEndian.Uint32(data[pos+1:]))))
+			pos += 5
+		case b == 30: // real number, ended by a 0xf nibble
+			for pos++; pos < len(data) && data[pos]&0xf != 0xf && data[pos]>>4 != 0xf; pos++ {
+			}
+			pos++
+			operands = append(operands, 0)
+		case 32 <= b && b <= 246:
+			operands = append(operands, int(b)-139)
+			pos++
+		case 247 <= b && b <= 254:
+			if len(data) < pos+2 {
+				return nil, errors.New("invalid CFF DICT (EOF)")
+			}
+			if b <= 250 {
+				operands = append(operands, (int(b)-247)*256+int(data[pos+1])+108)
+			} else {
+				operands = append(operands, -(int(b)-251)*256-int(data[pos+1])-108)
+			}
+			pos += 2
+		default:
+			return nil, fmt.Errorf("invalid CFF DICT byte %d", b)
+		}
+	}
+	return out, nil
+}
+
+// appendDictOp appends [op] with its [operands], encoded
+// on 5 bytes so that the size does not depend on their values
+func appendDictOp(dst []byte, op int, operands ...int) []byte {
+	for _, v := range operands {
+		dst = append(dst, 29, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(dst[len(dst)-4:], uint32(int32(v)))
+	}
+	if op > 0xFF {
+		return append(dst, 12, byte(op))
+	}
+	return append(dst, byte(op))
+}
+
+// rewriteDict drops the [dropped] operators, and appends [op] with [operands] if op != -1
+func rewriteDict(entries []dictEntry, dropped []int, op int, operands ...int) []byte {
+	var out []byte
+outer:
+	for _, entry := range entries {
+		for _, d := range dropped {
+			if entry.op == d {
+				continue outer
+			}
+		}
+		out = append(out, entry.raw...)
+	}
+	if op != -1 {
+		out = appendDictOp(out, op, operands...)
+	}
+	return out
+}
+
+func lookupDict(entries []dictEntry, op int) []int {
+	for _, entry := range entries {
+		if entry.op == op {
+			return entry.operands
+		}
+	}
+	return nil
+}
+
+// privateDict returns the Private DICT data referenced by [operands] (size, offset),
+// followed by the local subroutines, and the size of the DICT itself.
+func privateDict(cff []byte, operands []int) ([]byte, int, error) {
+	if len(operands) != 2 {
+		return nil, 0, errors.New("invalid CFF Private operator")
+	}
+	size, offset := operands[0], operands[1]
+	if offset < 0 || size < 0 || len(cff) < offset+size {
+		return nil, 0, errors.New("invalid CFF Private DICT offset")
+	}
+	entries, err := parseDict(cff[offset : offset+size])
+	if err != nil {
+		return nil, 0, err
+	}
+	end := offset + size
+	if subrs := lookupDict(entries, opSubrs); len(subrs) == 1 {
+		_, length, err := cffIndex(cff, offset+subrs[0])
+		if err != nil {
+			return nil, 0, err
+		}
+		if subrsEnd := offset + subrs[0] + length; subrsEnd > end {
+			end = subrsEnd
+		}
+	}
+	return cff[offset:end], size, nil
+}
+
+// cffCharset returns the SIDs (or CIDs) of the glyphs
+func cffCharset(cff []byte, offset, numGlyphs int) ([]uint16, error) {
+	out := make([]uint16, numGlyphs)
+	switch offset {
+	case 0: // ISOAdobe
+		for i := range out {
+			out[i] = uint16(i)
+		}
+		return out, nil
+	case 1, 2:
+		return nil, errors.New("unsupported CFF expert charset")
+	}
+	if len(cff) <= offset {
+		return nil, errors.New("invalid CFF charset offset")
+	}
+	data := cff[offset+1:]
+	switch format := cff[offset]; format {
+	case 0:
+		if len(data) < 2*(numGlyphs-1) {
+			return nil, errors.New("invalid CFF charset (EOF)")
+		}
+		for i := 1; i < numGlyphs; i++ {
+			out[i] = binary.BigEndian.Uint16(data[2*(i-1):])
+		}
+	case 1, 2:
+		rangeSize := 3
+		if format == 2 {
+			rangeSize = 4
+		}
+		for i := 1; i < numGlyphs; {
+			if len(data) < rangeSize {
+				return nil, errors.New("invalid CFF charset (EOF)")
+			}
+			first := binary.BigEndian.Uint16(data)
+			nLeft := int(data[2])
+			if format == 2 {
+				nLeft = int(binary.BigEndian.Uint16(data[2:]))
+			}
+			for j := 0; j <= nLeft && i < numGlyphs; j, i = j+1, i+1 {
+				out[i] = first + uint16(j)
+			}
+			data = data[rangeSize:]
+		}
+	default:
+		return nil, fmt.Errorf("invalid CFF charset format %d", format)
+	}
+	return out, nil
+}
+
+// cffFDSelect returns the font DICT index of the glyphs
+func cffFDSelect(cff []byte, offset, numGlyphs int) ([]byte, error) {
+	if offset <= 0 || len(cff) <= offset {
+		return nil, errors.New("invalid CFF FDSelect offset")
+	}
+	data := cff[offset+1:]
+	switch format := cff[offset]; format {
+	case 0:
+		if len(data) < numGlyphs {
+			return nil, errors.New("invalid CFF FDSelect (EOF)")
+		}
+		return data[:numGlyphs], nil
+	case 3:
+		if len(data) < 2 {
+			return nil, errors.New("invalid CFF FDSelect (EOF)")
+		}
+		nRanges := int(binary.BigEndian.Uint16(data))
+		if len(data) < 2+3*nRanges+2 {
+			return nil, errors.New("invalid CFF FDSelect (EOF)")
+		}
+		out := make([]byte, numGlyphs)
+		for i := 0; i < nRanges; i++ {
+			record := data[2+3*i:]
+			first, end := int(binary.BigEndian.Uint16(record)), int(binary.BigEndian.Uint16(record[3:]))
+			for g := first; g < end && g < numGlyphs; g++ {
+				out[g] = record[2]
+			}
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("invalid CFF FDSelect format %d", format)
+	}
+}
+
+// subsetCFF returns the 'CFF ' table of the subset.
+// The global and local subroutines are kept as such.
+func (s *subsetter) subsetCFF() ([]byte, error) {
+	cff := s.cff
+	if len(cff) < 4 {
+		return nil, errors.New("invalid CFF table (EOF)")
+	}
+	offset := int(cff[2])
+	names, length, err := cffIndex(cff, offset)
+	if err != nil {
+		return nil, err
+	}
+	offset += length
+	topDicts, length, err := cffIndex(cff, offset)
+	if err != nil {
+		return nil, err
+	}
+	if len(topDicts) != 1 {
+		return nil, fmt.Errorf("unsupported number of fonts in CFF table: %d", len(topDicts))
+	}
+	offset += length
+	strings, length, err := cffIndex(cff, offset)
+	if err != nil {
+		return nil, err
+	}
+	offset += length
+	globalSubrs, _, err := cffIndex(cff, offset)
+	if err != nil {
+		return nil, err
+	}
+	topDict, err := parseDict(topDicts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// gather the data referenced by the Top DICT
+	var charStrings [][]byte
+	if operands := lookupDict(topDict, opCharStrings); len(operands) == 1 {
+		charStrings, _, err = cffIndex(cff, operands[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(charStrings) != s.numGlyphs {
+		return nil, fmt.Errorf("invalid number of CFF charstrings: %d (for %d glyphs)", len(charStrings), s.numGlyphs)
+	}
+	var charset []uint16
+	if operands := lookupDict(topDict, opCharset); len(operands) == 1 {
+		charset, err = cffCharset(cff, operands[0], s.numGlyphs)
+	} else {
+		charset, err = cffCharset(cff, 0, s.numGlyphs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	isCID := lookupDict(topDict, opROS) != nil
+	var (
+		private      []byte   // for simple fonts
+		privateSize  int      // for simple fonts
+		fontDicts    [][]byte // for CID fonts
+		privates     [][]byte // for CID fonts
+		privateSizes []int    // for CID fonts
+		fdSelect     []byte   // for CID fonts
+	)
+	if isCID {
+		operands := lookupDict(topDict, opFDArray)
+		if len(operands) != 1 {
+			return nil, errors.New("missing CFF FDArray")
+		}
+		fontDicts, _, err = cffIndex(cff, operands[0])
+		if err != nil {
+			return nil, err
+		}
+		privates = make([][]byte, len(fontDicts))
+		privateSizes = make([]int, len(fontDicts))
+		for i, fd := range fontDicts {
+			entries, err := parseDict(fd)
+			if err != nil {
+				return nil, err
+			}
+			if privates[i], privateSizes[i], err = privateDict(cff, lookupDict(entries, opPrivate)); err != nil {
+				return nil, err
+			}
+			fontDicts[i] = rewriteDict(entries, []int{opPrivate}, -1)
+		}
+		if operands = lookupDict(topDict, opFDSelect); len(operands) != 1 {
+			return nil, errors.New("missing CFF FDSelect")
+		}
+		if fdSelect, err = cffFDSelect(cff, operands[0], s.numGlyphs); err != nil {
+			return nil, err
+		}
+	} else {
+		if private, privateSize, err = privateDict(cff, lookupDict(topDict, opPrivate)); err != nil {
+			return nil, err
+		}
+	}
+
+	// build the subset data, which are laid out in this order:
+	// header, Name INDEX, Top DICT INDEX, String INDEX, Global Subrs INDEX,
+	// charset, CharStrings INDEX, [FDSelect, FDArray INDEX, Privates] or Private
+	newCharset := []byte{0}
+	newCharStrings := make([][]byte, len(s.glyphs))
+	newFDSelect := []byte{0}
+	for i, glyph := range s.glyphs {
+		if i != 0 {
+			newCharset = append(newCharset, byte(charset[glyph]>>8), byte(charset[glyph]))
+		}
+		if s.kept[glyph] {
+			newCharStrings[i] = charStrings[glyph]
+		} else {
+			newCharStrings[i] = []byte{14} // endchar
+		}
+		if isCID {
+			newFDSelect = append(newFDSelect, fdSelect[glyph])
+		}
+	}
+
+	// the size of the Top DICT does not depend on the offsets
+	dropped := []int{opCharset, opEncoding, opCharStrings, opPrivate, opFDArray, opFDSelect}
+	newTopDict := func(charsetOffset, charStringsOffset, privateSize, privateOffset, fdSelectOffset, fdArrayOffset int) []byte {
+		out := rewriteDict(topDict, dropped, opCharset, charsetOffset)
+		out = appendDictOp(out, opCharStrings, charStringsOffset)
+		if isCID {
+			out = appendDictOp(out, opFDSelect, fdSelectOffset)
+			return appendDictOp(out, opFDArray, fdArrayOffset)
+		}
+		return appendDictOp(out, opPrivate, privateSize, privateOffset)
+	}
+
+	out := []byte{1, 0, 4, cff[3]}
+	out = appendIndex(out, names)
+	topDictOffset := len(out)
+	out = appendIndex(out, [][]byte{newTopDict(0, 0, 0, 0, 0, 0)})
+	out = appendIndex(out, strings)
+	out = appendIndex(out, globalSubrs)
+
+	charsetOffset := len(out)
+	out = append(out, newCharset...)
+	charStringsOffset := len(out)
+	out = appendIndex(out, newCharStrings)
+
+	var fdSelectOffset, fdArrayOffset, privateOffset int
+	if isCID {
+		fdSelectOffset = len(out)
+		out = append(out, newFDSelect...)
+		// the Private DICTs follow the FDArray INDEX, whose size
+		// does not depend on the offsets
+		for i := range fontDicts {
+			fontDicts[i] = appendDictOp(fontDicts[i], opPrivate, 0, 0)
+		}
+		fdArrayOffset = len(out)
+		privateOffset = len(appendIndex(out, fontDicts))
+		for i, fd := range fontDicts {
+			binary.BigEndian.PutUint32(fd[len(fd)-10:], uint32(privateSizes[i]))
+			binary.BigEndian.PutUint32(fd[len(fd)-5:], uint32(privateOffset))
+			privateOffset += len(privates[i])
+		}
+		out = appendIndex(out, fontDicts)
+		for _, p := range privates {
+			out = append(out, p...)
+		}
+	} else {
+		privateOffset = len(out)
+		out = append(out, private...)
+	}
+
+	topDictData := newTopDict(charsetOffset, charStringsOffset, privateSize, privateOffset, fdSelectOffset, fdArrayOffset)
+	copy(out[topDictOffset:], appendIndex(nil, [][]byte{topDictData}))
+	return out, nil
+}
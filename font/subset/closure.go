@@ -0,0 +1,220 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package subset
+
+import (
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
+)
+
+// isFeatureRetained returns true if [tag] is selected by [features] (nil meaning all)
+func isFeatureRetained(features []ot.Tag, tag ot.Tag) bool {
+	if features == nil {
+		return true
+	}
+	for _, f := range features {
+		if f == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// retainedLookups returns the lookups used by the features of [layout] selected by [features],
+// including the lookups nested in contextual lookups, given by [nested].
+func retainedLookups(layout font.Layout, features []ot.Tag, nested [][]uint16) []bool {
+	out := make([]bool, len(nested))
+	var stack []uint16
+	for _, feature := range layout.Features {
+		if isFeatureRetained(features, feature.Tag) {
+			stack = append(stack, feature.LookupListIndices...)
+		}
+	}
+	for len(stack) != 0 {
+		index := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if int(index) >= len(out) || out[index] {
+			continue
+		}
+		out[index] = true
+		stack = append(stack, nested[index]...)
+	}
+	return out
+}
+
+func gsubNestedLookups(lookups []font.GSUBLookup) [][]uint16 {
+	out := make([][]uint16, len(lookups))
+	for i, lookup := range lookups {
+		for _, subtable := range lookup.Subtables {
+			switch subtable := subtable.(type) {
+			case tables.ContextualSubs:
+				switch data := subtable.Data.(type) {
+				case tables.ContextualSubs1:
+					out[i] = appendRuleSetsLookups(out[i], data.SeqRuleSet)
+				case tables.ContextualSubs2:
+					out[i] = appendRuleSetsLookups(out[i], data.ClassSeqRuleSet)
+				case tables.ContextualSubs3:
+					out[i] = appendRecordsLookups(out[i], data.SeqLookupRecords)
+				}
+			case tables.ChainedContextualSubs:
+				switch data := subtable.Data.(type) {
+				case tables.ChainedContextualSubs1:
+					out[i] = appendChainedRuleSetsLookups(out[i], data.ChainedSeqRuleSet)
+				case tables.ChainedContextualSubs2:
+					out[i] = appendChainedRuleSetsLookups(out[i], data.ChainedClassSeqRuleSet)
+				case tables.ChainedContextualSubs3:
+					out[i] = appendRecordsLookups(out[i], data.SeqLookupRecords)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func gposNestedLookups(lookups []font.GPOSLookup) [][]uint16 {
+	out := make([][]uint16, len(lookups))
+	for i, lookup := range lookups {
+		for _, subtable := range lookup.Subtables {
+			switch subtable := subtable.(type) {
+			case tables.ContextualPos:
+				switch data := subtable.Data.(type) {
+				case tables.ContextualPos1:
+					out[i] = appendRuleSetsLookups(out[i], data.SeqRuleSet)
+				case tables.ContextualPos2:
+					out[i] = appendRuleSetsLookups(out[i], data.ClassSeqRuleSet)
+				case tables.ContextualPos3:
+					out[i] = appendRecordsLookups(out[i], data.SeqLookupRecords)
+				}
+			case tables.ChainedContextualPos:
+				switch data := subtable.Data.(type) {
+				case tables.ChainedContextualPos1:
+					out[i] = appendChainedRuleSetsLookups(out[i], data.ChainedSeqRuleSet)
+				case tables.ChainedContextualPos2:
+					out[i] = appendChainedRuleSetsLookups(out[i], data.ChainedClassSeqRuleSet)
+				case tables.ChainedContextualPos3:
+					out[i] = appendRecordsLookups(out[i], data.SeqLookupRecords)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func appendRecordsLookups(dst []uint16, records []tables.SequenceLookupRecord) []uint16 {
+	for _, record := range records {
+		dst = append(dst, record.LookupListIndex)
+	}
+	return dst
+}
+
+func appendRuleSetsLookups(dst []uint16, sets []tables.SequenceRuleSet) []uint16 {
+	for _, set := range sets {
+		for _, rule := range set.SeqRule {
+			dst = appendRecordsLookups(dst, rule.SeqLookupRecords)
+		}
+	}
+	return dst
+}
+
+func appendChainedRuleSetsLookups(dst []uint16, sets []tables.ChainedSequenceRuleSet) []uint16 {
+	for _, set := range sets {
+		for _, rule := range set.ChainedSeqRules {
+			dst = appendRecordsLookups(dst, rule.SeqLookupRecords)
+		}
+	}
+	return dst
+}
+
+// addGlyph adds [glyph] to the subset, returning true if
+// it was not already included
+func (s *subsetter) addGlyph(glyph tables.GlyphID) bool {
+	if int(glyph) >= len(s.kept) || s.kept[glyph] {
+		return false
+	}
+	s.kept[glyph] = true
+	return true
+}
+
+// closeOverGSUB adds the glyphs which may be produced by the retained GSUB lookups.
+// The context of contextual substitutions is ignored, so that the
+// closure may include glyphs which are actually never produced.
+func (s *subsetter) closeOverGSUB() {
+	for changed := true; changed; {
+		changed = false
+		for i, lookup := range s.ft.GSUB.Lookups {
+			if !s.gsubLookups[i] {
+				continue
+			}
+			for _, subtable := range lookup.Subtables {
+				if s.closeOverSubtable(subtable) {
+					changed = true
+				}
+			}
+		}
+	}
+}
+
+func (s *subsetter) closeOverSubtable(subtable tables.GSUBLookup) bool {
+	changed := false
+	coverage := subtable.Cov()
+	if coverage == nil {
+		return false
+	}
+	for glyph, kept := range s.kept {
+		if !kept {
+			continue
+		}
+		index, ok := coverage.Index(tables.GlyphID(glyph))
+		if !ok {
+			continue
+		}
+		var substitutes []tables.GlyphID
+		switch subtable := subtable.(type) {
+		case tables.SingleSubs:
+			switch data := subtable.Data.(type) {
+			case tables.SingleSubstData1:
+				substitutes = []tables.GlyphID{tables.GlyphID(uint16(int(glyph) + int(data.DeltaGlyphID)))}
+			case tables.SingleSubstData2:
+				if index < len(data.SubstituteGlyphIDs) {
+					substitutes = data.SubstituteGlyphIDs[index : index+1]
+				}
+			}
+		case tables.MultipleSubs:
+			if index < len(subtable.Sequences) {
+				substitutes = subtable.Sequences[index].SubstituteGlyphIDs
+			}
+		case tables.AlternateSubs:
+			if index < len(subtable.AlternateSets) {
+				substitutes = subtable.AlternateSets[index].AlternateGlyphIDs
+			}
+		case tables.LigatureSubs:
+			if index < len(subtable.LigatureSets) {
+				for _, lig := range subtable.LigatureSets[index].Ligatures {
+					if s.hasGlyphs(lig.ComponentGlyphIDs) {
+						substitutes = append(substitutes, lig.LigatureGlyph)
+					}
+				}
+			}
+		case tables.ReverseChainSingleSubs:
+			if index < len(subtable.SubstituteGlyphIDs) {
+				substitutes = subtable.SubstituteGlyphIDs[index : index+1]
+			}
+		}
+		for _, substitute := range substitutes {
+			if s.addGlyph(substitute) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func (s *subsetter) hasGlyphs(glyphs []tables.GlyphID) bool {
+	for _, g := range glyphs {
+		if int(g) >= len(s.kept) || !s.kept[g] {
+			return false
+		}
+	}
+	return true
+}
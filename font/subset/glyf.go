@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package subset
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+)
+
+// flags of composite glyph components
+const (
+	argsAreWords   = 0x0001
+	haveScale      = 0x0008
+	moreComponents = 0x0020
+	haveXYScale    = 0x0040
+	haveTwoByTwo   = 0x0080
+)
+
+// glyphData returns the raw 'glyf' data of [glyph], which is empty for glyphs without outlines.
+func (s *subsetter) glyphData(glyph font.GID) ([]byte, error) {
+	var start, end int
+	if binary.BigEndian.Uint16(s.headTable[50:]) == 0 { // short offsets
+		if L := len(s.loca); L < 2*int(glyph)+4 {
+			return nil, fmt.Errorf("invalid 'loca' table (EOF at glyph %d)", glyph)
+		}
+		start = 2 * int(binary.BigEndian.Uint16(s.loca[2*glyph:]))
+		end = 2 * int(binary.BigEndian.Uint16(s.loca[2*glyph+2:]))
+	} else {
+		if L := len(s.loca); L < 4*int(glyph)+8 {
+			return nil, fmt.Errorf("invalid 'loca' table (EOF at glyph %d)", glyph)
+		}
+		start = int(binary.BigEndian.Uint32(s.loca[4*glyph:]))
+		end = int(binary.BigEndian.Uint32(s.loca[4*glyph+4:]))
+	}
+	if start > end || end > len(s.glyf) {
+		return nil, fmt.Errorf("invalid 'loca' offsets for glyph %d", glyph)
+	}
+	return s.glyf[start:end], nil
+}
+
+// componentIndices returns the positions, in [data], of the glyph indices of the
+// components of a composite glyph, or nil for simple glyphs.
+func componentIndices(data []byte) ([]int, error) {
+	if len(data) < 10 || int16(binary.BigEndian.Uint16(data)) >= 0 {
+		return nil, nil
+	}
+	var out []int
+	for pos := 10; ; {
+		if len(data) < pos+4 {
+			return nil, fmt.Errorf("invalid composite glyph (EOF at %d)", pos)
+		}
+		flags := binary.BigEndian.Uint16(data[pos:])
+		out = append(out, pos+2)
+		pos += 4
+		if flags&argsAreWords != 0 {
+			pos += 4
+		} else {
+			pos += 2
+		}
+		switch {
+		case flags&haveScale != 0:
+			pos += 2
+		case flags&haveXYScale != 0:
+			pos += 4
+		case flags&haveTwoByTwo != 0:
+			pos += 8
+		}
+		if flags&moreComponents == 0 {
+			return out, nil
+		}
+	}
+}
+
+// closeOverComposites adds the components of the composite glyphs
+func (s *subsetter) closeOverComposites() error {
+	if s.glyf == nil {
+		return nil
+	}
+	var stack []font.GID
+	for glyph, kept := range s.kept {
+		if kept {
+			stack = append(stack, font.GID(glyph))
+		}
+	}
+	for len(stack) != 0 {
+		glyph := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		data, err := s.glyphData(glyph)
+		if err != nil {
+			return err
+		}
+		indices, err := componentIndices(data)
+		if err != nil {
+			return err
+		}
+		for _, pos := range indices {
+			component := binary.BigEndian.Uint16(data[pos:])
+			if int(component) >= len(s.kept) {
+				return fmt.Errorf("invalid component %d in glyph %d", component, glyph)
+			}
+			if !s.kept[component] {
+				s.kept[component] = true
+				stack = append(stack, font.GID(component))
+			}
+		}
+	}
+	return nil
+}
+
+// subsetOutlines returns the 'glyf' and 'loca' tables, or the 'CFF ' table
+func (s *subsetter) subsetOutlines() ([]ot.Table, error) {
+	if s.glyf == nil {
+		cff, err := s.subsetCFF()
+		if err != nil {
+			return nil, err
+		}
+		return []ot.Table{{Tag: tagCFF, Content: cff}}, nil
+	}
+
+	var glyf []byte
+	offsets := make([]int, len(s.glyphs)+1)
+	for i, glyph := range s.glyphs {
+		offsets[i] = len(glyf)
+		if !s.kept[glyph] { // empty glyph
+			continue
+		}
+		data, err := s.glyphData(glyph)
+		if err != nil {
+			return nil, err
+		}
+		indices, _ := componentIndices(data) // checked in closeOverComposites
+		start := len(glyf)
+		glyf = append(glyf, data...)
+		for _, pos := range indices {
+			component := font.GID(binary.BigEndian.Uint16(data[pos:]))
+			binary.BigEndian.PutUint16(glyf[start+pos:], uint16(s.mapping[component]))
+		}
+		if len(glyf)%2 != 0 { // required by short offsets
+			glyf = append(glyf, 0)
+		}
+	}
+	offsets[len(s.glyphs)] = len(glyf)
+
+	var loca []byte
+	loca, s.locaFormat = writeLoca(offsets)
+	return []ot.Table{{Tag: tagGlyf, Content: glyf}, {Tag: tagLoca, Content: loca}}, nil
+}
+
+// writeLoca returns the 'loca' table for the glyph [offsets], which must be even,
+// using short offsets if possible, and its format.
+func writeLoca(offsets []int) ([]byte, uint16) {
+	if offsets[len(offsets)-1]/2 <= 0xFFFF {
+		loca := make([]byte, 2*len(offsets))
+		for i, offset := range offsets {
+			binary.BigEndian.PutUint16(loca[2*i:], uint16(offset/2))
+		}
+		return loca, 0
+	}
+	loca := make([]byte, 4*len(offsets))
+	for i, offset := range offsets {
+		binary.BigEndian.PutUint32(loca[4*i:], uint32(offset))
+	}
+	return loca, 1
+}
@@ -0,0 +1,462 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package subset
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+)
+
+var (
+	tagAvar = ot.MustNewTag("avar")
+	tagCvar = ot.MustNewTag("cvar")
+	tagDSIG = ot.MustNewTag("DSIG")
+	tagFvar = ot.MustNewTag("fvar")
+	tagGvar = ot.MustNewTag("gvar")
+	tagHVAR = ot.MustNewTag("HVAR")
+	tagMVAR = ot.MustNewTag("MVAR")
+	tagVVAR = ot.MustNewTag("VVAR")
+	tagWght = ot.MustNewTag("wght")
+	tagWdth = ot.MustNewTag("wdth")
+)
+
+// Instance builds a static instance of the variable font loaded by [ld], at the
+// design coordinates given by [variations] (the axes not specified use their default value).
+// This is typically required to embed variable fonts in PDF files.
+//
+// The variations are applied to the outlines ('gvar'), to the glyph metrics ('HVAR', 'VVAR'
+// or phantom points) and to the font-wide metrics ('MVAR'), and the feature variations of
+// the 'GSUB' and 'GPOS' tables are resolved. The variation tables are then dropped.
+//
+// Composite glyphs are decomposed and, since the 'cvar' table is not supported,
+// the hinting instructions are dropped. The variation deltas of the 'GPOS'
+// device tables are not applied. Fonts with CFF2 outlines are not supported.
+//
+// The returned font may then be subsetted with [Subset].
+func Instance(ld *ot.Loader, variations []font.Variation) ([]byte, error) {
+	if !ld.HasTable(tagFvar) {
+		return nil, errors.New("font is not variable")
+	}
+	if !ld.HasTable(tagGlyf) {
+		return nil, errors.New("only TrueType outlines are supported")
+	}
+	ft, err := font.NewFont(ld)
+	if err != nil {
+		return nil, err
+	}
+	s := subsetter{ld: ld, ft: ft}
+	if err = s.loadTables(); err != nil {
+		return nil, err
+	}
+	in := instancer{subsetter: &s, face: font.NewFace(ft), defaultFace: font.NewFace(ft)}
+	in.face.SetVariations(variations)
+
+	var tables []ot.Table
+	add := func(tag ot.Tag, content []byte) { tables = append(tables, ot.Table{Tag: tag, Content: content}) }
+
+	glyf, loca := in.outlines()
+	add(tagGlyf, glyf)
+	add(tagLoca, loca)
+	add(tagHead, in.head())
+	add(tagMaxp, in.maxp())
+	hhea, hmtx := in.horizontalMetrics()
+	add(tagHhea, hhea)
+	add(tagHmtx, hmtx)
+	if s.vheaTable != nil && s.vmtxTable != nil {
+		vhea, vmtx := in.verticalMetrics()
+		add(tagVhea, vhea)
+		add(tagVmtx, vmtx)
+	}
+	if os2, err := ld.RawTable(tagOS2); err == nil {
+		add(tagOS2, in.os2(os2, variations))
+	}
+	if post, err := ld.RawTable(tagPost); err == nil {
+		add(tagPost, in.post(post))
+	}
+	for _, layout := range [...]struct {
+		tag    ot.Tag
+		layout font.Layout
+	}{{tagGSUB, ft.GSUB.Layout}, {tagGPOS, ft.GPOS.Layout}} {
+		if table, err := ld.RawTable(layout.tag); err == nil {
+			add(layout.tag, in.layout(table, layout.layout))
+		}
+	}
+
+	// copy the other tables
+	for _, tag := range ld.Tables() {
+		switch tag {
+		case tagGlyf, tagLoca, tagHead, tagMaxp, tagHhea, tagHmtx, tagVhea, tagVmtx, tagOS2, tagPost, tagGSUB, tagGPOS,
+			tagFvar, tagGvar, tagAvar, tagCvar, tagHVAR, tagVVAR, tagMVAR, // variations
+			tagCvt, tagFpgm, tagPrep, // hinting
+			tagDSIG: // invalidated
+			continue
+		}
+		table, err := ld.RawTable(tag)
+		if err != nil {
+			return nil, err
+		}
+		add(tag, table)
+	}
+
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Tag < tables[j].Tag })
+	return ot.WriteTTF(tables), nil
+}
+
+type instancer struct {
+	*subsetter
+
+	face        *font.Face // at the instance coordinates
+	defaultFace *font.Face // at the default coordinates
+
+	// the bounding boxes of the glyphs, as xMin, yMin, xMax, yMax
+	bboxes [][4]int16
+	// the number of points and contours of the largest glyphs
+	maxPoints, maxContours int
+}
+
+// metricDelta returns the variation of the 'MVAR' metric [tag]
+func (in *instancer) metricDelta(tag ot.Tag) int16 {
+	value, ok1 := in.face.Metric(tag)
+	defaultValue, ok2 := in.defaultFace.Metric(tag)
+	if !ok1 || !ok2 {
+		return 0
+	}
+	return int16(math.Round(float64(value - defaultValue)))
+}
+
+// applyMetricDeltas adds the variations of the 'MVAR' metrics stored in [table] at the given offsets
+func (in *instancer) applyMetricDeltas(table []byte, fields map[ot.Tag]int) {
+	for tag, offset := range fields {
+		if len(table) < offset+2 {
+			continue
+		}
+		value := int16(binary.BigEndian.Uint16(table[offset:])) + in.metricDelta(tag)
+		binary.BigEndian.PutUint16(table[offset:], uint16(value))
+	}
+}
+
+// outlines returns the 'glyf' and 'loca' tables
+func (in *instancer) outlines() (glyf, loca []byte) {
+	in.bboxes = make([][4]int16, in.numGlyphs)
+	offsets := make([]int, in.numGlyphs+1)
+	for gid := range in.bboxes {
+		offsets[gid] = len(glyf)
+		points := in.face.TrueTypePoints(font.GID(gid))
+		var contours int
+		glyf, in.bboxes[gid], contours = appendSimpleGlyph(glyf, points)
+		if len(points) > in.maxPoints {
+			in.maxPoints = len(points)
+		}
+		if contours > in.maxContours {
+			in.maxContours = contours
+		}
+		if len(glyf)%2 != 0 { // required by short offsets
+			glyf = append(glyf, 0)
+		}
+	}
+	offsets[in.numGlyphs] = len(glyf)
+	loca, in.locaFormat = writeLoca(offsets)
+	return glyf, loca
+}
+
+// flags of simple glyph points
+const (
+	flagOnCurve       = 0x01
+	flagXShort        = 0x02
+	flagYShort        = 0x04
+	flagXSameOrPos    = 0x10
+	flagYSameOrPos    = 0x20
+	flagOverlapSimple = 0x40
+)
+
+// appendSimpleGlyph appends the 'glyf' data for [points], without instructions,
+// returning the bounding box and the number of contours of the glyph.
+// Nothing is appended for empty glyphs.
+func appendSimpleGlyph(dst []byte, points []font.TrueTypePoint) ([]byte, [4]int16, int) {
+	if len(points) == 0 {
+		return dst, [4]int16{}, 0
+	}
+	xs, ys := make([]int16, len(points)), make([]int16, len(points))
+	var endPoints []int
+	bbox := [4]int16{math.MaxInt16, math.MaxInt16, math.MinInt16, math.MinInt16}
+	for i, p := range points {
+		xs[i], ys[i] = int16(math.Round(float64(p.X))), int16(math.Round(float64(p.Y)))
+		bbox[0], bbox[1] = min16(bbox[0], xs[i]), min16(bbox[1], ys[i])
+		bbox[2], bbox[3] = max16(bbox[2], xs[i]), max16(bbox[3], ys[i])
+		if p.EndContour || i == len(points)-1 {
+			endPoints = append(endPoints, i)
+		}
+	}
+
+	appendUint16 := func(dst []byte, v uint16) []byte { return append(dst, byte(v>>8), byte(v)) }
+	dst = appendUint16(dst, uint16(len(endPoints)))
+	for _, v := range bbox {
+		dst = appendUint16(dst, uint16(v))
+	}
+	for _, end := range endPoints {
+		dst = appendUint16(dst, uint16(end))
+	}
+	dst = appendUint16(dst, 0) // instructionLength
+
+	// flags, then x and y coordinates, as deltas
+	var xData, yData []byte
+	appendCoordinate := func(data []byte, delta int16, shortFlag, samePosFlag byte) ([]byte, byte) {
+		switch {
+		case delta == 0:
+			return data, samePosFlag
+		case -0xFF <= delta && delta <= 0xFF:
+			if delta > 0 {
+				return append(data, byte(delta)), shortFlag | samePosFlag
+			}
+			return append(data, byte(-delta)), shortFlag
+		default:
+			return appendUint16(data, uint16(delta)), 0
+		}
+	}
+	var prevX, prevY int16
+	for i, p := range points {
+		var flag, fx, fy byte
+		if p.OnCurve {
+			flag = flagOnCurve
+		}
+		if i == 0 {
+			flag |= flagOverlapSimple // as for variable fonts, outlines may overlap
+		}
+		xData, fx = appendCoordinate(xData, xs[i]-prevX, flagXShort, flagXSameOrPos)
+		yData, fy = appendCoordinate(yData, ys[i]-prevY, flagYShort, flagYSameOrPos)
+		dst = append(dst, flag|fx|fy)
+		prevX, prevY = xs[i], ys[i]
+	}
+	dst = append(dst, xData...)
+	return append(dst, yData...), bbox, len(endPoints)
+}
+
+func min16(a, b int16) int16 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max16(a, b int16) int16 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (in *instancer) head() []byte {
+	out := in.subsetter.head()
+	bbox := [4]int16{math.MaxInt16, math.MaxInt16, math.MinInt16, math.MinInt16}
+	for _, b := range in.bboxes {
+		if b == ([4]int16{}) { // empty glyph
+			continue
+		}
+		bbox[0], bbox[1] = min16(bbox[0], b[0]), min16(bbox[1], b[1])
+		bbox[2], bbox[3] = max16(bbox[2], b[2]), max16(bbox[3], b[3])
+	}
+	if bbox[0] > bbox[2] { // no outlines
+		bbox = [4]int16{}
+	}
+	for i, v := range bbox {
+		binary.BigEndian.PutUint16(out[36+2*i:], uint16(v))
+	}
+	return out
+}
+
+// maxp updates the maximum profile, since the outlines are
+// decomposed and the instructions dropped
+func (in *instancer) maxp() []byte {
+	out := append([]byte(nil), in.maxpTable...)
+	if len(out) < 32 { // version 0.5
+		return out
+	}
+	binary.BigEndian.PutUint16(out[6:], uint16(in.maxPoints))
+	binary.BigEndian.PutUint16(out[8:], uint16(in.maxContours))
+	for _, offset := range [...]int{10, 12, 26, 28, 30} {
+		// maxCompositePoints, maxCompositeContours, maxSizeOfInstructions,
+		// maxComponentElements, maxComponentDepth
+		binary.BigEndian.PutUint16(out[offset:], 0)
+	}
+	return out
+}
+
+// horizontalMetrics returns the 'hhea' and 'hmtx' tables
+func (in *instancer) horizontalMetrics() (hhea, hmtx []byte) {
+	advances := make([]uint16, in.numGlyphs)
+	sideBearings := make([]uint16, in.numGlyphs)
+	var (
+		maxAdvance, maxExtent           int16 = 0, math.MinInt16
+		minLeftBearing, minRightBearing int16 = math.MaxInt16, math.MaxInt16
+	)
+	for gid, bbox := range in.bboxes {
+		advance := int16(math.Round(float64(in.face.HorizontalAdvance(font.GID(gid)))))
+		advance = max16(advance, 0)
+		advances[gid], sideBearings[gid] = uint16(advance), uint16(bbox[0])
+		maxAdvance = max16(maxAdvance, advance)
+		if bbox == ([4]int16{}) {
+			continue
+		}
+		minLeftBearing = min16(minLeftBearing, bbox[0])
+		minRightBearing = min16(minRightBearing, advance-bbox[2])
+		maxExtent = max16(maxExtent, bbox[2])
+	}
+	hhea, hmtx = writeMetrics(in.hheaTable, advances, sideBearings)
+	if maxExtent != math.MinInt16 {
+		binary.BigEndian.PutUint16(hhea[10:], uint16(maxAdvance))
+		binary.BigEndian.PutUint16(hhea[12:], uint16(minLeftBearing))
+		binary.BigEndian.PutUint16(hhea[14:], uint16(minRightBearing))
+		binary.BigEndian.PutUint16(hhea[16:], uint16(maxExtent))
+	}
+	in.applyMetricDeltas(hhea, map[ot.Tag]int{
+		font.MetricHorizontalCaretRise:   18,
+		font.MetricHorizontalCaretRun:    20,
+		font.MetricHorizontalCaretOffset: 22,
+	})
+	return hhea, hmtx
+}
+
+// verticalMetrics returns the 'vhea' and 'vmtx' tables
+func (in *instancer) verticalMetrics() (vhea, vmtx []byte) {
+	advances := make([]uint16, in.numGlyphs)
+	sideBearings := make([]uint16, in.numGlyphs)
+	var (
+		maxAdvance, maxExtent           int16 = 0, math.MinInt16
+		minTopBearing, minBottomBearing int16 = math.MaxInt16, math.MaxInt16
+	)
+	for gid, bbox := range in.bboxes {
+		advance := int16(math.Round(float64(-in.face.VerticalAdvance(font.GID(gid)))))
+		advance = max16(advance, 0)
+		advances[gid] = uint16(advance)
+		maxAdvance = max16(maxAdvance, advance)
+		if bbox == ([4]int16{}) {
+			continue
+		}
+		_, originY, _ := in.face.GlyphVOrigin(font.GID(gid))
+		topBearing := int16(originY) - bbox[3]
+		sideBearings[gid] = uint16(topBearing)
+		minTopBearing = min16(minTopBearing, topBearing)
+		minBottomBearing = min16(minBottomBearing, advance-topBearing-(bbox[3]-bbox[1]))
+		maxExtent = max16(maxExtent, topBearing+bbox[3]-bbox[1])
+	}
+	vhea, vmtx = writeMetrics(in.vheaTable, advances, sideBearings)
+	if maxExtent != math.MinInt16 {
+		binary.BigEndian.PutUint16(vhea[10:], uint16(maxAdvance))
+		binary.BigEndian.PutUint16(vhea[12:], uint16(minTopBearing))
+		binary.BigEndian.PutUint16(vhea[14:], uint16(minBottomBearing))
+		binary.BigEndian.PutUint16(vhea[16:], uint16(maxExtent))
+	}
+	in.applyMetricDeltas(vhea, map[ot.Tag]int{
+		font.MetricVerticalAscender:    4,
+		font.MetricVerticalDescender:   6,
+		font.MetricVerticalLineGap:     8,
+		font.MetricVerticalCaretRise:   18,
+		font.MetricVerticalCaretRun:    20,
+		font.MetricVerticalCaretOffset: 22,
+	})
+	return vhea, vmtx
+}
+
+// os2 applies the 'MVAR' deltas, and updates the average width,
+// and the weight and width classes from [variations].
+func (in *instancer) os2(raw []byte, variations []font.Variation) []byte {
+	out := append([]byte(nil), raw...)
+	if len(out) < 78 {
+		return out
+	}
+	in.applyMetricDeltas(out, map[ot.Tag]int{
+		font.MetricSubscriptXSize:            10,
+		font.MetricSubscriptYSize:            12,
+		font.MetricSubscriptXOffset:          14,
+		font.MetricSubscriptYOffset:          16,
+		font.MetricSuperscriptXSize:          18,
+		font.MetricSuperscriptYSize:          20,
+		font.MetricSuperscriptXOffset:        22,
+		font.MetricSuperscriptYOffset:        24,
+		font.MetricStrikeoutSize:             26,
+		font.MetricStrikeoutOffset:           28,
+		font.MetricHorizontalAscender:        68,
+		font.MetricHorizontalDescender:       70,
+		font.MetricHorizontalLineGap:         72,
+		font.MetricHorizontalClippingAscent:  74,
+		font.MetricHorizontalClippingDescent: 76,
+		font.MetricXHeight:                   86,
+		font.MetricCapHeight:                 88,
+	})
+
+	// xAvgCharWidth is the average of the non zero advances
+	var sum, count int
+	for gid := 0; gid < in.numGlyphs; gid++ {
+		if advance := math.Round(float64(in.face.HorizontalAdvance(font.GID(gid)))); advance > 0 {
+			sum += int(advance)
+			count++
+		}
+	}
+	if count != 0 {
+		binary.BigEndian.PutUint16(out[2:], uint16((sum+count/2)/count))
+	}
+
+	for _, v := range variations {
+		switch v.Tag {
+		case tagWght:
+			weight := math.Round(float64(v.Value))
+			binary.BigEndian.PutUint16(out[4:], uint16(math.Max(1, math.Min(1000, weight))))
+		case tagWdth:
+			binary.BigEndian.PutUint16(out[6:], widthClass(v.Value))
+		}
+	}
+	return out
+}
+
+// widthClass returns the usWidthClass closest to the 'wdth' axis value [width], in percent
+func widthClass(width float32) uint16 {
+	// the percentages of the normal width of the classes 1 to 9
+	widths := [...]float32{50, 62.5, 75, 87.5, 100, 112.5, 125, 150, 200}
+	best := 0
+	for i, w := range widths {
+		if math.Abs(float64(w-width)) < math.Abs(float64(widths[best]-width)) {
+			best = i
+		}
+	}
+	return uint16(best + 1)
+}
+
+// post applies the 'MVAR' deltas to the underline metrics
+func (in *instancer) post(raw []byte) []byte {
+	out := append([]byte(nil), raw...)
+	in.applyMetricDeltas(out, map[ot.Tag]int{
+		font.MetricUnderlineOffset: 8,
+		font.MetricUnderlineSize:   10,
+	})
+	return out
+}
+
+// layout resolves the feature variations of the GSUB or GPOS [table]
+func (in *instancer) layout(table []byte, layout font.Layout) []byte {
+	if len(layout.FeatureVariations) == 0 {
+		return table
+	}
+	index := layout.FindVariationIndex(in.face.Coords())
+	if index != -1 {
+		layout.Features = append([]font.Feature(nil), layout.Features...)
+		for _, sub := range layout.FeatureVariations[index].Substitutions.Substitutions {
+			if int(sub.FeatureIndex) < len(layout.Features) {
+				layout.Features[sub.FeatureIndex].Feature = sub.AlternateFeature
+			}
+		}
+	}
+	lookupList, err := rawLookupList(table)
+	if err != nil {
+		return table
+	}
+	out, err := pruneLayout(layout, nil, lookupList)
+	if err != nil { // keep the original table, which is still valid
+		return table
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package subset
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	tu "github.com/boxesandglue/typesetting/testutils"
+	td "github.com/go-text/typesetting-utils/opentype"
+)
+
+func assertClose(t *testing.T, got, expected float32) {
+	t.Helper()
+	tu.AssertC(t, math.Abs(float64(got-expected)) <= 1, fmt.Sprint(got, expected))
+}
+
+func TestInstance(t *testing.T) {
+	for _, filename := range []string{
+		"common/SourceSans-VF-HVAR.ttf", // HVAR and MVAR
+		"common/Mada-VF.ttf",            // phantom points and MVAR
+		"common/Commissioner-VF.ttf",    // composite glyphs
+	} {
+		file, err := td.Files.ReadFile(filename)
+		tu.AssertNoErr(t, err)
+		ld, face := loadFont(t, file)
+		variations := []font.Variation{{Tag: ot.MustNewTag("wght"), Value: 700}}
+		face.SetVariations(variations)
+
+		out, err := Instance(ld, variations)
+		tu.AssertNoErr(t, err)
+
+		instanceLd, instance := loadFont(t, out)
+		tu.Assert(t, !instanceLd.HasTable(ot.MustNewTag("fvar")) && !instanceLd.HasTable(ot.MustNewTag("gvar")))
+		tu.Assert(t, numGlyphs(t, instanceLd) == numGlyphs(t, ld))
+
+		for _, r := range "aAgé&" {
+			gid, ok := face.NominalGlyph(r)
+			if !ok {
+				continue
+			}
+			newGID, _ := instance.NominalGlyph(r)
+			tu.Assert(t, newGID == gid)
+			assertClose(t, instance.HorizontalAdvance(gid), face.HorizontalAdvance(gid))
+
+			expected, _ := face.GlyphExtents(gid)
+			got, _ := instance.GlyphExtents(gid)
+			assertClose(t, got.XBearing, expected.XBearing)
+			assertClose(t, got.YBearing, expected.YBearing)
+			assertClose(t, got.Width, expected.Width)
+			assertClose(t, got.Height, expected.Height)
+		}
+		for _, tag := range []font.Tag{font.MetricHorizontalAscender, font.MetricXHeight, font.MetricCapHeight, font.MetricUnderlineOffset} {
+			expected, ok := face.Metric(tag)
+			got, _ := instance.Metric(tag)
+			if ok {
+				assertClose(t, got, expected)
+			}
+		}
+	}
+
+	ld, _ := loadFont(t, mustReadFile(t, "common/Roboto-BoldItalic.ttf"))
+	_, err := Instance(ld, nil)
+	tu.Assert(t, err != nil)
+}
+
+func mustReadFile(t *testing.T, filename string) []byte {
+	t.Helper()
+	file, err := td.Files.ReadFile(filename)
+	tu.AssertNoErr(t, err)
+	return file
+}
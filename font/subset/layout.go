@@ -0,0 +1,1268 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package subset
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"sort"
+
+	"github.com/boxesandglue/typesetting/font"
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
+)
+
+// This file implements the rewriting of the GSUB, GPOS and GDEF tables
+// for renumbered subsets : the glyphs not in the subset are removed from
+// the coverages, class definitions and glyph sequences, and the others are renumbered.
+//
+// Since the new glyph IDs follow the order of the original ones, the sorted glyph arrays
+// stay sorted, and the data indexed by coverage only needs to be filtered.
+// The class values, lookup indices and mark glyph set indices are not changed,
+// so that the lookups may be kept in place.
+// The VariationIndex tables are dropped, since the subset is not variable.
+
+var (
+	errInvalidLayout  = errors.New("invalid layout table (EOF)")
+	errLayoutOverflow = errors.New("layout table overflow")
+)
+
+const useMarkFilteringSet = 0x0010 // lookup flag
+
+// layoutRemapper rewrites layout subtables, with a sticky error
+// for invalid input.
+type layoutRemapper struct {
+	mapping map[font.GID]font.GID
+	err     error
+}
+
+func (lr *layoutRemapper) setErr(err error) {
+	if lr.err == nil {
+		lr.err = err
+	}
+}
+
+// u16 returns the uint16 at [offset] in [data], or 0 if [data] is too short.
+func (lr *layoutRemapper) u16(data []byte, offset int) int {
+	if len(data) < offset+2 {
+		lr.setErr(errInvalidLayout)
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(data[offset:]))
+}
+
+// u32 returns the uint32 at [offset] in [data], or 0 if [data] is too short.
+func (lr *layoutRemapper) u32(data []byte, offset int) int {
+	if len(data) < offset+4 {
+		lr.setErr(errInvalidLayout)
+		return 0
+	}
+	return int(binary.BigEndian.Uint32(data[offset:]))
+}
+
+// bytes returns the [size] bytes at [offset] in [data].
+func (lr *layoutRemapper) bytes(data []byte, offset, size int) []byte {
+	if len(data) < offset+size {
+		lr.setErr(errInvalidLayout)
+		return nil
+	}
+	return data[offset : offset+size]
+}
+
+// sub returns the table at the 16-bit offset read at [offset] in [data],
+// or nil for NULL offsets.
+func (lr *layoutRemapper) sub(data []byte, offset int) []byte {
+	subOffset := lr.u16(data, offset)
+	if subOffset == 0 {
+		return nil
+	}
+	if len(data) < subOffset {
+		lr.setErr(errInvalidLayout)
+		return nil
+	}
+	return data[subOffset:]
+}
+
+func (lr *layoutRemapper) glyph(glyph int) (int, bool) {
+	newGlyph, ok := lr.mapping[font.GID(glyph)]
+	return int(newGlyph), ok
+}
+
+// glyphs returns the new IDs of the [count] glyphs at [offset] in [data],
+// or false if one of them is not in the subset.
+func (lr *layoutRemapper) glyphs(data []byte, offset, count int) ([]int, bool) {
+	out := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		newGlyph, ok := lr.glyph(lr.u16(data, offset+2*i))
+		if !ok {
+			return nil, false
+		}
+		out = append(out, newGlyph)
+	}
+	return out, true
+}
+
+// coveredGlyph is a glyph of a coverage table which is in the subset
+type coveredGlyph struct {
+	glyph, newGlyph int
+	index           int // in the original coverage
+}
+
+// coverage returns the glyphs of the Coverage table [data] which are in the subset.
+func (lr *layoutRemapper) coverage(data []byte) []coveredGlyph {
+	cov, _, err := tables.ParseCoverage(data)
+	if err != nil {
+		lr.setErr(err)
+		return nil
+	}
+	var out []coveredGlyph
+	for _, rng := range cov.RangeRecords() {
+		for glyph := int(rng.StartGlyphID); glyph <= int(rng.EndGlyphID); glyph++ {
+			if newGlyph, ok := lr.glyph(glyph); ok {
+				index := int(rng.StartCoverageIndex) + glyph - int(rng.StartGlyphID)
+				out = append(out, coveredGlyph{glyph, newGlyph, index})
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].newGlyph < out[j].newGlyph })
+	return out
+}
+
+// writeCoverage returns a Coverage table for the [covered] glyphs, sorted by new glyph.
+func writeCoverage(covered []coveredGlyph) []byte {
+	var ranges []int // start, end
+	for _, c := range covered {
+		if L := len(ranges); L != 0 && ranges[L-1] == c.newGlyph-1 {
+			ranges[L-1] = c.newGlyph
+			continue
+		}
+		ranges = append(ranges, c.newGlyph, c.newGlyph)
+	}
+	if 3*len(ranges) < 2*len(covered) { // format 2 is smaller
+		out := appendUint16(nil, 2)
+		out = appendUint16(out, len(ranges)/2)
+		index := 0
+		for i := 0; i < len(ranges); i += 2 {
+			out = appendUint16(out, ranges[i])
+			out = appendUint16(out, ranges[i+1])
+			out = appendUint16(out, index)
+			index += ranges[i+1] - ranges[i] + 1
+		}
+		return out
+	}
+	out := appendUint16(nil, 1)
+	out = appendUint16(out, len(covered))
+	for _, c := range covered {
+		out = appendUint16(out, c.newGlyph)
+	}
+	return out
+}
+
+// classDef returns the ClassDef table [data] restricted to the subset,
+// or nil for a NULL table, and its extent (the maximum class + 1).
+func (lr *layoutRemapper) classDef(data []byte) ([]byte, int) {
+	if data == nil {
+		return nil, 1
+	}
+	cd, _, err := tables.ParseClassDef(data)
+	if err != nil {
+		lr.setErr(err)
+		return nil, 1
+	}
+	type classRange struct{ start, end, class int }
+	var glyphs []classRange
+	for _, rng := range cd.RangeRecords() {
+		for glyph := int(rng.StartGlyphID); glyph <= int(rng.EndGlyphID); glyph++ {
+			if newGlyph, ok := lr.glyph(glyph); ok && rng.Class != 0 {
+				glyphs = append(glyphs, classRange{newGlyph, newGlyph, int(rng.Class)})
+			}
+		}
+	}
+	sort.SliceStable(glyphs, func(i, j int) bool { return glyphs[i].start < glyphs[j].start })
+	var ranges []classRange
+	extent := 1
+	for _, g := range glyphs {
+		if g.class >= extent {
+			extent = g.class + 1
+		}
+		if L := len(ranges); L != 0 && ranges[L-1].end == g.start-1 && ranges[L-1].class == g.class {
+			ranges[L-1].end = g.start
+			continue
+		}
+		ranges = append(ranges, g)
+	}
+
+	if L := len(glyphs); L != 0 && 3*len(ranges) > 1+glyphs[L-1].start-glyphs[0].start { // format 1 is smaller
+		first := glyphs[0].start
+		classes := make([]int, glyphs[L-1].start-first+1)
+		for _, g := range glyphs {
+			classes[g.start-first] = g.class
+		}
+		out := appendUint16(nil, 1)
+		out = appendUint16(out, first)
+		out = appendUint16(out, len(classes))
+		for _, class := range classes {
+			out = appendUint16(out, class)
+		}
+		return out, extent
+	}
+	out := appendUint16(nil, 2)
+	out = appendUint16(out, len(ranges))
+	for _, rng := range ranges {
+		out = appendUint16(out, rng.start)
+		out = appendUint16(out, rng.end)
+		out = appendUint16(out, rng.class)
+	}
+	return out, extent
+}
+
+// device returns the Device table at [offset] in [data], or nil for
+// NULL offsets and VariationIndex tables.
+func (lr *layoutRemapper) device(data []byte, offset int) []byte {
+	if offset == 0 {
+		return nil
+	}
+	start, end, format := lr.u16(data, offset), lr.u16(data, offset+2), lr.u16(data, offset+4)
+	if format < 1 || 3 < format || end < start {
+		return nil
+	}
+	valueBits := 1 << format
+	return lr.bytes(data, offset, 6+2*(((end-start+1)*valueBits+15)/16))
+}
+
+// anchor returns the Anchor table at [offset] in [data], or nil for NULL offsets.
+func (lr *layoutRemapper) anchor(data []byte, offset int) []byte {
+	if offset == 0 {
+		return nil
+	}
+	if len(data) < offset {
+		lr.setErr(errInvalidLayout)
+		return nil
+	}
+	anchor := data[offset:]
+	var t table
+	switch format := lr.u16(anchor, 0); format {
+	case 1:
+		t.header = append(t.header, lr.bytes(anchor, 0, 6)...)
+	case 2:
+		t.header = append(t.header, lr.bytes(anchor, 0, 8)...)
+	case 3:
+		t.header = append(t.header, lr.bytes(anchor, 0, 6)...)
+		t.offset16(lr.device(anchor, lr.u16(anchor, 6)))
+		t.offset16(lr.device(anchor, lr.u16(anchor, 8)))
+	default:
+		lr.setErr(errInvalidLayout)
+	}
+	return lr.pack(&t)
+}
+
+// valueRecord appends to [t] the ValueRecord with [format] at [offset] in [data],
+// which is also the table its Device offsets are relative to.
+// It returns the offset following the record.
+func (lr *layoutRemapper) valueRecord(t *table, format int, data []byte, offset int) int {
+	for field := 0; field < 16; field++ {
+		if format&(1<<field) == 0 {
+			continue
+		}
+		value := lr.u16(data, offset)
+		offset += 2
+		if tables.ValueFormat(1<<field)&tables.Devices != 0 {
+			t.offset16(lr.device(data, value))
+		} else {
+			t.u16(value)
+		}
+	}
+	return offset
+}
+
+func valueRecordSize(format int) int { return 2 * bits.OnesCount16(uint16(format)) }
+
+// table is a table being written : a header, followed by the subtables
+// it references with offsets from its start. Identical subtables are shared.
+type table struct {
+	header    []byte
+	links     []tableLink
+	subtables [][]byte
+	indices   map[string]int // in subtables
+}
+
+type tableLink struct {
+	position, subtable int
+	is32               bool
+}
+
+func (t *table) u16(v int) { t.header = appendUint16(t.header, v) }
+
+// offset16 appends an offset to [subtable], which is NULL if [subtable] is empty.
+func (t *table) offset16(subtable []byte) { t.offset(subtable, false) }
+
+// offset32 appends a 32-bit offset to [subtable], which is NULL if [subtable] is empty.
+func (t *table) offset32(subtable []byte) { t.offset(subtable, true) }
+
+func (t *table) offset(subtable []byte, is32 bool) {
+	position := len(t.header)
+	t.u16(0)
+	if is32 {
+		t.u16(0)
+	}
+	if len(subtable) == 0 {
+		return
+	}
+	if t.indices == nil {
+		t.indices = make(map[string]int)
+	}
+	index, ok := t.indices[string(subtable)]
+	if !ok {
+		index = len(t.subtables)
+		t.subtables = append(t.subtables, subtable)
+		t.indices[string(subtable)] = index
+	}
+	t.links = append(t.links, tableLink{position, index, is32})
+}
+
+// pack returns the content of [t], or nil if an offset overflows.
+func (lr *layoutRemapper) pack(t *table) []byte {
+	out := t.header
+	positions := make([]int, len(t.subtables))
+	for i, subtable := range t.subtables {
+		positions[i] = len(out)
+		out = append(out, subtable...)
+	}
+	for _, link := range t.links {
+		offset := positions[link.subtable]
+		if link.is32 {
+			binary.BigEndian.PutUint32(out[link.position:], uint32(offset))
+		} else if offset <= 0xFFFF {
+			binary.BigEndian.PutUint16(out[link.position:], uint16(offset))
+		} else {
+			lr.setErr(errLayoutOverflow)
+			return nil
+		}
+	}
+	return out
+}
+
+// ------------------------------ lookup list ------------------------------
+
+// remappedLookup is a lookup of the subset, whose subtables are
+// not wrapped in extension subtables.
+type remappedLookup struct {
+	kind, flag, markFilteringSet int
+	subtables                    [][]byte
+}
+
+// remapLookups rewrites the GSUB or GPOS [lookupList] for the subset, emptying
+// the lookups not in [lookups], and returns the new LookupList.
+func (s *subsetter) remapLookups(lookupList []byte, lookups []bool, isGPOS bool) ([]byte, error) {
+	lr := layoutRemapper{mapping: s.mapping}
+	extensionKind := 7
+	if isGPOS {
+		extensionKind = 9
+	}
+	count := lr.u16(lookupList, 0)
+	if count > len(lookups) {
+		return nil, errors.New("invalid layout table lookup list")
+	}
+	out := make([]remappedLookup, count)
+	for i := 0; i < count && lr.err == nil; i++ {
+		lookup := lr.sub(lookupList, 2+2*i)
+		out[i].kind = lr.u16(lookup, 0)
+		if !lookups[i] {
+			continue
+		}
+		out[i].flag = lr.u16(lookup, 2)
+		subtableCount := lr.u16(lookup, 4)
+		if out[i].flag&useMarkFilteringSet != 0 {
+			out[i].markFilteringSet = lr.u16(lookup, 6+2*subtableCount)
+		}
+		lookupKind := out[i].kind
+		for j := 0; j < subtableCount && lr.err == nil; j++ {
+			kind, subtable := lookupKind, lr.sub(lookup, 6+2*j)
+			if kind == extensionKind {
+				kind = lr.u16(subtable, 2)
+				offset := lr.u32(subtable, 4)
+				if len(subtable) < offset {
+					return nil, errInvalidLayout
+				}
+				subtable = subtable[offset:]
+				out[i].kind = kind
+			}
+			var remapped []byte
+			if isGPOS {
+				remapped = lr.gposSubtable(kind, subtable)
+			} else {
+				remapped = lr.gsubSubtable(kind, subtable)
+			}
+			if remapped != nil {
+				out[i].subtables = append(out[i].subtables, remapped)
+			}
+		}
+	}
+	if lr.err != nil {
+		return nil, lr.err
+	}
+	if list, ok := writeLookupList(out, 0); ok {
+		return list, nil
+	}
+	if list, ok := writeLookupList(out, extensionKind); ok {
+		return list, nil
+	}
+	return nil, errLayoutOverflow
+}
+
+// writeLookupList returns a LookupList where the subtables directly follow their lookup,
+// or, if [extensionKind] is not zero, are wrapped in extension subtables and stored after
+// all the lookups, so that they may be referenced with 32-bit offsets.
+// It returns false if a 16-bit offset overflows.
+func writeLookupList(lookups []remappedLookup, extensionKind int) ([]byte, bool) {
+	headerSize := func(lookup remappedLookup) int {
+		if lookup.flag&useMarkFilteringSet != 0 {
+			return 8 + 2*len(lookup.subtables)
+		}
+		return 6 + 2*len(lookup.subtables)
+	}
+	appendHeader := func(dst []byte, lookup remappedLookup, kind int, offsets []int) []byte {
+		dst = appendUint16(dst, kind)
+		dst = appendUint16(dst, lookup.flag)
+		dst = appendUint16(dst, len(offsets))
+		for _, offset := range offsets {
+			dst = appendUint16(dst, offset)
+		}
+		if lookup.flag&useMarkFilteringSet != 0 {
+			dst = appendUint16(dst, lookup.markFilteringSet)
+		}
+		return dst
+	}
+
+	out := appendUint16(nil, len(lookups))
+	out = append(out, make([]byte, 2*len(lookups))...)
+	if extensionKind == 0 {
+		for i, lookup := range lookups {
+			if len(out) > 0xFFFF {
+				return nil, false
+			}
+			binary.BigEndian.PutUint16(out[2+2*i:], uint16(len(out)))
+			offsets := make([]int, len(lookup.subtables))
+			offset := headerSize(lookup)
+			for j, subtable := range lookup.subtables {
+				if offset > 0xFFFF {
+					return nil, false
+				}
+				offsets[j] = offset
+				offset += len(subtable)
+			}
+			out = appendHeader(out, lookup, lookup.kind, offsets)
+			for _, subtable := range lookup.subtables {
+				out = append(out, subtable...)
+			}
+		}
+		// the markFilteringSet field of the last lookup may be read
+		// even when not used : add padding
+		return append(out, 0, 0), true
+	}
+
+	extensionsStart, subtablesCount := len(out), 0
+	for _, lookup := range lookups {
+		extensionsStart += headerSize(lookup)
+		subtablesCount += len(lookup.subtables)
+	}
+	subtableStart := extensionsStart + 8*subtablesCount
+	var extensions, subtables []byte
+	for i, lookup := range lookups {
+		start := len(out)
+		if start > 0xFFFF {
+			return nil, false
+		}
+		binary.BigEndian.PutUint16(out[2+2*i:], uint16(start))
+		offsets := make([]int, len(lookup.subtables))
+		for j, subtable := range lookup.subtables {
+			extension := extensionsStart + len(extensions)
+			if offsets[j] = extension - start; offsets[j] > 0xFFFF {
+				return nil, false
+			}
+			extensions = appendUint16(extensions, 1) // format
+			extensions = appendUint16(extensions, lookup.kind)
+			extensions = binary.BigEndian.AppendUint32(extensions, uint32(subtableStart+len(subtables)-extension))
+			subtables = append(subtables, subtable...)
+		}
+		kind := lookup.kind
+		if len(offsets) != 0 {
+			kind = extensionKind
+		}
+		out = appendHeader(out, lookup, kind, offsets)
+	}
+	out = append(out, extensions...)
+	return append(out, subtables...), true
+}
+
+// ------------------------------ GSUB ------------------------------
+
+// gsubSubtable returns the GSUB subtable [data] of type [kind] restricted
+// to the subset, or nil if it can't apply to the subset.
+func (lr *layoutRemapper) gsubSubtable(kind int, data []byte) []byte {
+	switch kind {
+	case 1:
+		return lr.singleSubst(data)
+	case 2, 3: // MultipleSubst and AlternateSubst have the same layout
+		return lr.sequenceSubst(data, kind == 3)
+	case 4:
+		return lr.ligatureSubst(data)
+	case 5:
+		return lr.context(data)
+	case 6:
+		return lr.chainedContext(data)
+	case 8:
+		return lr.reverseChainSubst(data)
+	default:
+		lr.setErr(errInvalidLayout)
+		return nil
+	}
+}
+
+func (lr *layoutRemapper) singleSubst(data []byte) []byte {
+	format := lr.u16(data, 0)
+	var (
+		covered     []coveredGlyph
+		substitutes []int
+	)
+	for _, c := range lr.coverage(lr.sub(data, 2)) {
+		var substitute int
+		switch format {
+		case 1:
+			substitute = (c.glyph + lr.u16(data, 4)) & 0xFFFF
+		case 2:
+			if c.index >= lr.u16(data, 4) {
+				continue
+			}
+			substitute = lr.u16(data, 6+2*c.index)
+		default:
+			lr.setErr(errInvalidLayout)
+			return nil
+		}
+		if newSubstitute, ok := lr.glyph(substitute); ok {
+			covered = append(covered, c)
+			substitutes = append(substitutes, newSubstitute)
+		}
+	}
+	if len(covered) == 0 {
+		return nil
+	}
+
+	var t table
+	delta := substitutes[0] - covered[0].newGlyph
+	isFormat1 := true
+	for i, c := range covered {
+		isFormat1 = isFormat1 && substitutes[i]-c.newGlyph == delta
+	}
+	if isFormat1 {
+		t.u16(1)
+		t.offset16(writeCoverage(covered))
+		t.u16(delta)
+	} else {
+		t.u16(2)
+		t.offset16(writeCoverage(covered))
+		t.u16(len(substitutes))
+		for _, substitute := range substitutes {
+			t.u16(substitute)
+		}
+	}
+	return lr.pack(&t)
+}
+
+// sequenceSubst handles MultipleSubst, where all the glyphs of a sequence
+// are required, and AlternateSubst, where the alternates may be filtered.
+func (lr *layoutRemapper) sequenceSubst(data []byte, isAlternate bool) []byte {
+	var (
+		covered   []coveredGlyph
+		sequences [][]byte
+	)
+	for _, c := range lr.coverage(lr.sub(data, 2)) {
+		if c.index >= lr.u16(data, 4) {
+			continue
+		}
+		sequence := lr.sub(data, 6+2*c.index)
+		var glyphs []int
+		count := lr.u16(sequence, 0)
+		if isAlternate {
+			for i := 0; i < count; i++ {
+				if newGlyph, ok := lr.glyph(lr.u16(sequence, 2+2*i)); ok {
+					glyphs = append(glyphs, newGlyph)
+				}
+			}
+			if len(glyphs) == 0 {
+				continue
+			}
+		} else { // the sequence may be empty, deleting the glyph
+			var ok bool
+			if glyphs, ok = lr.glyphs(sequence, 2, count); !ok {
+				continue
+			}
+		}
+		newSequence := appendUint16(nil, len(glyphs))
+		for _, glyph := range glyphs {
+			newSequence = appendUint16(newSequence, glyph)
+		}
+		covered = append(covered, c)
+		sequences = append(sequences, newSequence)
+	}
+	return lr.coverageIndexed(covered, sequences)
+}
+
+// coverageIndexed returns a subtable with format 1, followed by a coverage
+// and the array of offsets to [subtables], or nil if [covered] is empty.
+func (lr *layoutRemapper) coverageIndexed(covered []coveredGlyph, subtables [][]byte) []byte {
+	if len(covered) == 0 {
+		return nil
+	}
+	var t table
+	t.u16(1)
+	t.offset16(writeCoverage(covered))
+	t.u16(len(subtables))
+	for _, subtable := range subtables {
+		t.offset16(subtable)
+	}
+	return lr.pack(&t)
+}
+
+func (lr *layoutRemapper) ligatureSubst(data []byte) []byte {
+	var (
+		covered []coveredGlyph
+		sets    [][]byte
+	)
+	for _, c := range lr.coverage(lr.sub(data, 2)) {
+		if c.index >= lr.u16(data, 4) {
+			continue
+		}
+		set := lr.sub(data, 6+2*c.index)
+		var ligatures [][]byte
+		for i := 0; i < lr.u16(set, 0); i++ {
+			ligature := lr.sub(set, 2+2*i)
+			newLigature, ok := lr.glyph(lr.u16(ligature, 0))
+			componentCount := lr.u16(ligature, 2)
+			components, hasComponents := lr.glyphs(ligature, 4, componentCount-1)
+			if !ok || !hasComponents {
+				continue
+			}
+			out := appendUint16(nil, newLigature)
+			out = appendUint16(out, componentCount)
+			for _, component := range components {
+				out = appendUint16(out, component)
+			}
+			ligatures = append(ligatures, out)
+		}
+		if len(ligatures) == 0 {
+			continue
+		}
+		var t table
+		t.u16(len(ligatures))
+		for _, ligature := range ligatures {
+			t.offset16(ligature)
+		}
+		covered = append(covered, c)
+		sets = append(sets, lr.pack(&t))
+	}
+	return lr.coverageIndexed(covered, sets)
+}
+
+func (lr *layoutRemapper) reverseChainSubst(data []byte) []byte {
+	// backtrack and lookahead coverages
+	var contexts [2][][]byte
+	offset := 4
+	for i := range contexts {
+		count := lr.u16(data, offset)
+		for j := 0; j < count; j++ {
+			coverage := lr.coverage(lr.sub(data, offset+2+2*j))
+			if len(coverage) == 0 {
+				return nil
+			}
+			contexts[i] = append(contexts[i], writeCoverage(coverage))
+		}
+		offset += 2 + 2*count
+	}
+	var (
+		covered     []coveredGlyph
+		substitutes []int
+	)
+	for _, c := range lr.coverage(lr.sub(data, 2)) {
+		if c.index >= lr.u16(data, offset) {
+			continue
+		}
+		if substitute, ok := lr.glyph(lr.u16(data, offset+2+2*c.index)); ok {
+			covered = append(covered, c)
+			substitutes = append(substitutes, substitute)
+		}
+	}
+	if len(covered) == 0 {
+		return nil
+	}
+
+	var t table
+	t.u16(1)
+	t.offset16(writeCoverage(covered))
+	for _, coverages := range contexts {
+		t.u16(len(coverages))
+		for _, coverage := range coverages {
+			t.offset16(coverage)
+		}
+	}
+	t.u16(len(substitutes))
+	for _, substitute := range substitutes {
+		t.u16(substitute)
+	}
+	return lr.pack(&t)
+}
+
+// ------------------------------ contexts ------------------------------
+
+// ruleSet returns the SequenceRuleSet (or ChainedSequenceRuleSet, if [isChained])
+// [data], where the input glyphs (or classes) are mapped with [remap].
+// The rules referencing glyphs not in the subset are dropped, and nil is
+// returned if no rule is left.
+func (lr *layoutRemapper) ruleSet(data []byte, isChained bool, remap func(int) (int, bool)) []byte {
+	if data == nil {
+		return nil
+	}
+	var rules [][]byte
+	for i := 0; i < lr.u16(data, 0) && lr.err == nil; i++ {
+		rule := lr.sub(data, 2+2*i)
+		var (
+			out    []byte
+			offset int
+			ok     = true
+		)
+		// the sequences : [backtrack, input, lookahead] for chained rules,
+		// where the input count also includes the first glyph
+		sequences := 1
+		if isChained {
+			sequences = 3
+		}
+		for sequence := 0; sequence < sequences && ok; sequence++ {
+			isInput := sequence == 0 && !isChained || sequence == 1
+			count := lr.u16(rule, offset)
+			out = appendUint16(out, count)
+			offset += 2
+			if !isChained {
+				out = appendUint16(out, lr.u16(rule, offset)) // seqLookupCount
+				offset += 2
+			}
+			if isInput {
+				count--
+			}
+			for j := 0; j < count; j++ {
+				value, kept := remap(lr.u16(rule, offset))
+				ok = ok && kept
+				out = appendUint16(out, value)
+				offset += 2
+			}
+		}
+		if !ok {
+			continue
+		}
+		lookupCount := lr.u16(rule, 2)
+		if isChained {
+			lookupCount = lr.u16(rule, offset)
+			out = appendUint16(out, lookupCount)
+			offset += 2
+		}
+		rules = append(rules, append(out, lr.bytes(rule, offset, 4*lookupCount)...))
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	var t table
+	t.u16(len(rules))
+	for _, rule := range rules {
+		t.offset16(rule)
+	}
+	return lr.pack(&t)
+}
+
+func keepClass(class int) (int, bool) { return class, true }
+
+// context handles the SequenceContext subtables (GSUB type 5 and GPOS type 7).
+func (lr *layoutRemapper) context(data []byte) []byte {
+	switch format := lr.u16(data, 0); format {
+	case 1:
+		return lr.glyphContext(data, false)
+	case 2:
+		return lr.classContext(data, false)
+	case 3:
+		glyphCount, lookupCount := lr.u16(data, 2), lr.u16(data, 4)
+		var t table
+		t.u16(3)
+		t.u16(glyphCount)
+		t.u16(lookupCount)
+		for i := 0; i < glyphCount; i++ {
+			coverage := lr.coverage(lr.sub(data, 6+2*i))
+			if len(coverage) == 0 {
+				return nil
+			}
+			t.offset16(writeCoverage(coverage))
+		}
+		t.header = append(t.header, lr.bytes(data, 6+2*glyphCount, 4*lookupCount)...)
+		return lr.pack(&t)
+	default:
+		lr.setErr(errInvalidLayout)
+		return nil
+	}
+}
+
+// chainedContext handles the ChainedSequenceContext subtables (GSUB type 6 and GPOS type 8).
+func (lr *layoutRemapper) chainedContext(data []byte) []byte {
+	switch format := lr.u16(data, 0); format {
+	case 1:
+		return lr.glyphContext(data, true)
+	case 2:
+		return lr.classContext(data, true)
+	case 3:
+		var t table
+		t.u16(3)
+		offset := 2
+		for range [3]int{} { // backtrack, input and lookahead coverages
+			count := lr.u16(data, offset)
+			t.u16(count)
+			for i := 0; i < count; i++ {
+				coverage := lr.coverage(lr.sub(data, offset+2+2*i))
+				if len(coverage) == 0 {
+					return nil
+				}
+				t.offset16(writeCoverage(coverage))
+			}
+			offset += 2 + 2*count
+		}
+		lookupCount := lr.u16(data, offset)
+		t.u16(lookupCount)
+		t.header = append(t.header, lr.bytes(data, offset+2, 4*lookupCount)...)
+		return lr.pack(&t)
+	default:
+		lr.setErr(errInvalidLayout)
+		return nil
+	}
+}
+
+// glyphContext handles the format 1 of the (chained) context subtables,
+// whose rule sets are indexed by coverage.
+func (lr *layoutRemapper) glyphContext(data []byte, isChained bool) []byte {
+	var (
+		covered []coveredGlyph
+		sets    [][]byte
+	)
+	for _, c := range lr.coverage(lr.sub(data, 2)) {
+		if c.index >= lr.u16(data, 4) {
+			continue
+		}
+		if set := lr.ruleSet(lr.sub(data, 6+2*c.index), isChained, lr.glyph); set != nil {
+			covered = append(covered, c)
+			sets = append(sets, set)
+		}
+	}
+	return lr.coverageIndexed(covered, sets)
+}
+
+// classContext handles the format 2 of the (chained) context subtables,
+// whose rule sets are indexed by class and kept.
+func (lr *layoutRemapper) classContext(data []byte, isChained bool) []byte {
+	covered := lr.coverage(lr.sub(data, 2))
+	if len(covered) == 0 {
+		return nil
+	}
+	classDefs := 1
+	if isChained {
+		classDefs = 3 // backtrack, input and lookahead
+	}
+	var t table
+	t.u16(2)
+	t.offset16(writeCoverage(covered))
+	for i := 0; i < classDefs; i++ {
+		classDef, _ := lr.classDef(lr.sub(data, 4+2*i))
+		t.offset16(classDef)
+	}
+	offset := 4 + 2*classDefs
+	count := lr.u16(data, offset)
+	t.u16(count)
+	for i := 0; i < count && lr.err == nil; i++ {
+		t.offset16(lr.ruleSet(lr.sub(data, offset+2+2*i), isChained, keepClass))
+	}
+	return lr.pack(&t)
+}
+
+// ------------------------------ GPOS ------------------------------
+
+// gposSubtable returns the GPOS subtable [data] of type [kind] restricted
+// to the subset, or nil if it can't apply to the subset.
+func (lr *layoutRemapper) gposSubtable(kind int, data []byte) []byte {
+	switch kind {
+	case 1:
+		return lr.singlePos(data)
+	case 2:
+		return lr.pairPos(data)
+	case 3:
+		return lr.cursivePos(data)
+	case 4, 6: // MarkBasePos and MarkMarkPos have the same layout
+		return lr.markBasePos(data)
+	case 5:
+		return lr.markLigPos(data)
+	case 7:
+		return lr.context(data)
+	case 8:
+		return lr.chainedContext(data)
+	default:
+		lr.setErr(errInvalidLayout)
+		return nil
+	}
+}
+
+func (lr *layoutRemapper) singlePos(data []byte) []byte {
+	format, valueFormat := lr.u16(data, 0), lr.u16(data, 4)
+	covered := lr.coverage(lr.sub(data, 2))
+	var t table
+	switch format {
+	case 1:
+		t.u16(1)
+		t.offset16(writeCoverage(covered))
+		t.u16(valueFormat)
+		lr.valueRecord(&t, valueFormat, data, 6)
+	case 2:
+		valueCount := lr.u16(data, 6)
+		var records []coveredGlyph
+		for _, c := range covered {
+			if c.index < valueCount {
+				records = append(records, c)
+			}
+		}
+		covered = records
+		t.u16(2)
+		t.offset16(writeCoverage(covered))
+		t.u16(valueFormat)
+		t.u16(len(covered))
+		for _, c := range covered {
+			lr.valueRecord(&t, valueFormat, data, 8+c.index*valueRecordSize(valueFormat))
+		}
+	default:
+		lr.setErr(errInvalidLayout)
+		return nil
+	}
+	if len(covered) == 0 {
+		return nil
+	}
+	return lr.pack(&t)
+}
+
+func (lr *layoutRemapper) pairPos(data []byte) []byte {
+	format, valueFormat1, valueFormat2 := lr.u16(data, 0), lr.u16(data, 4), lr.u16(data, 6)
+	covered := lr.coverage(lr.sub(data, 2))
+	switch format {
+	case 1:
+		var (
+			firsts []coveredGlyph
+			sets   [][]byte
+		)
+		recordSize := 2 + valueRecordSize(valueFormat1) + valueRecordSize(valueFormat2)
+		for _, c := range covered {
+			if c.index >= lr.u16(data, 8) {
+				continue
+			}
+			set := lr.sub(data, 10+2*c.index)
+			var (
+				t     table
+				count int
+			)
+			t.u16(0) // count, updated below
+			for i := 0; i < lr.u16(set, 0) && lr.err == nil; i++ {
+				offset := 2 + i*recordSize
+				second, ok := lr.glyph(lr.u16(set, offset))
+				if !ok {
+					continue
+				}
+				t.u16(second)
+				offset = lr.valueRecord(&t, valueFormat1, set, offset+2)
+				lr.valueRecord(&t, valueFormat2, set, offset)
+				count++
+			}
+			if count == 0 {
+				continue
+			}
+			binary.BigEndian.PutUint16(t.header, uint16(count))
+			firsts = append(firsts, c)
+			sets = append(sets, lr.pack(&t))
+		}
+		if len(firsts) == 0 {
+			return nil
+		}
+		var t table
+		t.u16(1)
+		t.offset16(writeCoverage(firsts))
+		t.u16(valueFormat1)
+		t.u16(valueFormat2)
+		t.u16(len(sets))
+		for _, set := range sets {
+			t.offset16(set)
+		}
+		return lr.pack(&t)
+	case 2:
+		if len(covered) == 0 {
+			return nil
+		}
+		// the classes are not renumbered, but the records of the
+		// classes no longer used are removed
+		class2Count := lr.u16(data, 14)
+		classDef1, newClass1Count := lr.classDef(lr.sub(data, 8))
+		classDef2, newClass2Count := lr.classDef(lr.sub(data, 10))
+		var t table
+		t.u16(2)
+		t.offset16(writeCoverage(covered))
+		t.u16(valueFormat1)
+		t.u16(valueFormat2)
+		t.offset16(classDef1)
+		t.offset16(classDef2)
+		t.u16(newClass1Count)
+		t.u16(newClass2Count)
+		recordSize := valueRecordSize(valueFormat1) + valueRecordSize(valueFormat2)
+		for class1 := 0; class1 < newClass1Count && lr.err == nil; class1++ {
+			for class2 := 0; class2 < newClass2Count; class2++ {
+				offset := 16 + (class1*class2Count+class2)*recordSize
+				offset = lr.valueRecord(&t, valueFormat1, data, offset)
+				lr.valueRecord(&t, valueFormat2, data, offset)
+			}
+		}
+		return lr.pack(&t)
+	default:
+		lr.setErr(errInvalidLayout)
+		return nil
+	}
+}
+
+func (lr *layoutRemapper) cursivePos(data []byte) []byte {
+	var covered []coveredGlyph
+	for _, c := range lr.coverage(lr.sub(data, 2)) {
+		if c.index < lr.u16(data, 4) {
+			covered = append(covered, c)
+		}
+	}
+	if len(covered) == 0 {
+		return nil
+	}
+	var t table
+	t.u16(1)
+	t.offset16(writeCoverage(covered))
+	t.u16(len(covered))
+	for _, c := range covered {
+		t.offset16(lr.anchor(data, lr.u16(data, 6+4*c.index)))
+		t.offset16(lr.anchor(data, lr.u16(data, 8+4*c.index)))
+	}
+	return lr.pack(&t)
+}
+
+// markArray returns the MarkArray [data], restricted to the [covered] marks,
+// which are filtered to the records present in [data].
+func (lr *layoutRemapper) markArray(data []byte, covered []coveredGlyph) ([]byte, []coveredGlyph) {
+	var (
+		t     table
+		marks []coveredGlyph
+	)
+	t.u16(0) // count, updated below
+	for _, c := range covered {
+		if c.index >= lr.u16(data, 0) {
+			continue
+		}
+		t.u16(lr.u16(data, 2+4*c.index)) // class
+		t.offset16(lr.anchor(data, lr.u16(data, 4+4*c.index)))
+		marks = append(marks, c)
+	}
+	binary.BigEndian.PutUint16(t.header, uint16(len(marks)))
+	return lr.pack(&t), marks
+}
+
+// anchorMatrix returns the records of [classCount] anchors of the [data] array,
+// for the records given by [indices].
+// It is used for BaseArray, Mark2Array and LigatureAttach tables.
+func (lr *layoutRemapper) anchorMatrix(data []byte, indices []int, classCount int) []byte {
+	var t table
+	t.u16(len(indices))
+	for _, index := range indices {
+		for class := 0; class < classCount; class++ {
+			t.offset16(lr.anchor(data, lr.u16(data, 2+2*(index*classCount+class))))
+		}
+	}
+	return lr.pack(&t)
+}
+
+// recordsIndices returns the indices of the [covered] glyphs
+// which are less than [count], and the corresponding glyphs.
+func recordsIndices(covered []coveredGlyph, count int) ([]coveredGlyph, []int) {
+	var (
+		glyphs  []coveredGlyph
+		indices []int
+	)
+	for _, c := range covered {
+		if c.index < count {
+			glyphs = append(glyphs, c)
+			indices = append(indices, c.index)
+		}
+	}
+	return glyphs, indices
+}
+
+func (lr *layoutRemapper) markBasePos(data []byte) []byte {
+	classCount := lr.u16(data, 6)
+	markArray, marks := lr.markArray(lr.sub(data, 8), lr.coverage(lr.sub(data, 2)))
+	baseArray := lr.sub(data, 10)
+	bases, indices := recordsIndices(lr.coverage(lr.sub(data, 4)), lr.u16(baseArray, 0))
+	if len(marks) == 0 || len(bases) == 0 {
+		return nil
+	}
+	var t table
+	t.u16(1)
+	t.offset16(writeCoverage(marks))
+	t.offset16(writeCoverage(bases))
+	t.u16(classCount)
+	t.offset16(markArray)
+	t.offset16(lr.anchorMatrix(baseArray, indices, classCount))
+	return lr.pack(&t)
+}
+
+func (lr *layoutRemapper) markLigPos(data []byte) []byte {
+	classCount := lr.u16(data, 6)
+	markArray, marks := lr.markArray(lr.sub(data, 8), lr.coverage(lr.sub(data, 2)))
+	ligatureArray := lr.sub(data, 10)
+	ligatures, indices := recordsIndices(lr.coverage(lr.sub(data, 4)), lr.u16(ligatureArray, 0))
+	if len(marks) == 0 || len(ligatures) == 0 {
+		return nil
+	}
+	var array table
+	array.u16(len(indices))
+	for _, index := range indices {
+		attach := lr.sub(ligatureArray, 2+2*index)
+		components := make([]int, lr.u16(attach, 0))
+		for i := range components {
+			components[i] = i
+		}
+		array.offset16(lr.anchorMatrix(attach, components, classCount))
+	}
+
+	var t table
+	t.u16(1)
+	t.offset16(writeCoverage(marks))
+	t.offset16(writeCoverage(ligatures))
+	t.u16(classCount)
+	t.offset16(markArray)
+	t.offset16(lr.pack(&array))
+	return lr.pack(&t)
+}
+
+// ------------------------------ GDEF ------------------------------
+
+// remapGDEF returns the GDEF [gdef] restricted to the subset.
+// The Item Variation Store is dropped.
+func (s *subsetter) remapGDEF(gdef []byte) ([]byte, error) {
+	lr := layoutRemapper{mapping: s.mapping}
+	minorVersion := lr.u16(gdef, 2)
+	var t table
+	t.u16(1)
+	t.u16(0) // minor version, updated below
+	glyphClassDef, _ := lr.classDef(lr.sub(gdef, 4))
+	markAttachClassDef, _ := lr.classDef(lr.sub(gdef, 10))
+	t.offset16(glyphClassDef)
+	t.offset16(lr.attachList(lr.sub(gdef, 6)))
+	t.offset16(lr.ligCaretList(lr.sub(gdef, 8)))
+	t.offset16(markAttachClassDef)
+	if minorVersion >= 2 && lr.u16(gdef, 12) != 0 {
+		sets := lr.sub(gdef, 12)
+		var setsTable table
+		setsTable.u16(1) // format
+		count := lr.u16(sets, 2)
+		setsTable.u16(count)
+		for i := 0; i < count && lr.err == nil; i++ {
+			offset := lr.u32(sets, 4+4*i)
+			if len(sets) < offset {
+				return nil, errInvalidLayout
+			}
+			// keep empty coverages, since the sets are referenced by index
+			setsTable.offset32(writeCoverage(lr.coverage(sets[offset:])))
+		}
+		binary.BigEndian.PutUint16(t.header[2:], 2)
+		t.offset16(lr.pack(&setsTable))
+	}
+	out := lr.pack(&t)
+	if lr.err != nil {
+		return nil, lr.err
+	}
+	return out, nil
+}
+
+func (lr *layoutRemapper) attachList(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+	var (
+		covered []coveredGlyph
+		points  [][]byte
+	)
+	for _, c := range lr.coverage(lr.sub(data, 0)) {
+		if c.index >= lr.u16(data, 2) {
+			continue
+		}
+		attachPoint := lr.sub(data, 4+2*c.index)
+		covered = append(covered, c)
+		points = append(points, lr.bytes(attachPoint, 0, 2+2*lr.u16(attachPoint, 0)))
+	}
+	return lr.coverageArray(covered, points)
+}
+
+func (lr *layoutRemapper) ligCaretList(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+	var (
+		covered   []coveredGlyph
+		ligGlyphs [][]byte
+	)
+	for _, c := range lr.coverage(lr.sub(data, 0)) {
+		if c.index >= lr.u16(data, 2) {
+			continue
+		}
+		ligGlyph := lr.sub(data, 4+2*c.index)
+		var t table
+		count := lr.u16(ligGlyph, 0)
+		t.u16(count)
+		for i := 0; i < count && lr.err == nil; i++ {
+			caret := lr.sub(ligGlyph, 2+2*i)
+			var caretTable table
+			switch format := lr.u16(caret, 0); format {
+			case 1, 2:
+				caretTable.header = append(caretTable.header, lr.bytes(caret, 0, 4)...)
+			case 3:
+				if device := lr.device(caret, lr.u16(caret, 4)); device != nil {
+					caretTable.header = append(caretTable.header, lr.bytes(caret, 0, 4)...)
+					caretTable.offset16(device)
+				} else { // use the format 1, without device
+					caretTable.u16(1)
+					caretTable.u16(lr.u16(caret, 2))
+				}
+			default:
+				lr.setErr(errInvalidLayout)
+			}
+			t.offset16(lr.pack(&caretTable))
+		}
+		covered = append(covered, c)
+		ligGlyphs = append(ligGlyphs, lr.pack(&t))
+	}
+	return lr.coverageArray(covered, ligGlyphs)
+}
+
+// coverageArray returns a table starting with a coverage, followed by
+// the array of offsets to [subtables], as used by the GDEF lists.
+func (lr *layoutRemapper) coverageArray(covered []coveredGlyph, subtables [][]byte) []byte {
+	if len(covered) == 0 {
+		return nil
+	}
+	var t table
+	t.offset16(writeCoverage(covered))
+	t.u16(len(subtables))
+	for _, subtable := range subtables {
+		t.offset16(subtable)
+	}
+	return lr.pack(&t)
+}
+
+func appendUint16(dst []byte, v int) []byte { return append(dst, byte(v>>8), byte(v)) }
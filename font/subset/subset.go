@@ -0,0 +1,298 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+// Package subset builds subsets of OpenType fonts, restricted to the glyphs
+// required to display a given text, as typically done by PDF producers when embedding fonts.
+//
+// The outlines ('glyf' and 'loca', or 'CFF '), metrics, 'cmap' and naming tables
+// are rewritten, the hinting tables are kept, and the other glyph related tables (color, bitmaps,
+// variations, AAT layout) are dropped. The GSUB and GPOS tables are restricted to the
+// selected features and to the lookups they use, and, as the GDEF table, to the glyphs
+// of the subset. Variable fonts are subset at their default instance; use [Instance]
+// to first build a static instance at other coordinates.
+package subset
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+)
+
+// Options selects the content of a subset font.
+type Options struct {
+	// Runes are the characters mapped by the 'cmap' table of the subset.
+	// Their glyphs are included in the subset.
+	Runes []rune
+
+	// Glyphs are additional glyphs included in the subset, typically
+	// the glyphs resulting from shaping.
+	Glyphs []font.GID
+
+	// Features are the OpenType layout features whose substitutions (in the GSUB table)
+	// are followed to complete the glyph set, so that shaping with the subset
+	// gives the same results as with the original font.
+	// They are the only features kept in the GSUB and GPOS tables of the subset.
+	// A nil slice selects all the features of the font, and an empty (non nil)
+	// slice disables the completion.
+	Features []ot.Tag
+
+	// RetainGIDs keeps the glyph IDs of the original font, emptying the outlines
+	// of the glyphs which are not in the subset, instead of renumbering the glyphs.
+	RetainGIDs bool
+
+	// KeepNames keeps all the records of the 'name' table. By default,
+	// only the records with name IDs 0 to 6 (copyright, family and full names, version and
+	// PostScript name) are kept, with the ones used by the parameters of the retained
+	// features ('size', stylistic sets and character variants).
+	KeepNames bool
+}
+
+// Subset builds a subset of the font loaded by [ld], as selected by [opts].
+// It returns the font file, and the mapping from the glyphs of the original font
+// to the glyphs of the subset, which may be used to update shaped text
+// (see for instance [harfbuzz.Buffer.RemapGlyphs]).
+//
+// The .notdef glyph (0) is always included, and is mapped to 0.
+// Fonts with CFF2 outlines are not supported.
+func Subset(ld *ot.Loader, opts Options) ([]byte, map[font.GID]font.GID, error) {
+	ft, err := font.NewFont(ld)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ld.HasTable(tagCFF2) {
+		return nil, nil, errors.New("CFF2 fonts are not supported")
+	}
+	s := subsetter{ld: ld, ft: ft, opts: opts}
+	if err = s.loadTables(); err != nil {
+		return nil, nil, err
+	}
+	if err = s.computeGlyphs(); err != nil {
+		return nil, nil, err
+	}
+
+	var tables []ot.Table
+	add := func(tag ot.Tag, content []byte) { tables = append(tables, ot.Table{Tag: tag, Content: content}) }
+
+	outlines, err := s.subsetOutlines()
+	if err != nil {
+		return nil, nil, err
+	}
+	tables = append(tables, outlines...)
+
+	add(tagHead, s.head())
+	add(tagMaxp, s.maxp())
+	add(tagCmap, s.cmap())
+	add(tagPost, s.post())
+	hhea, hmtx := s.metrics(s.hheaTable, s.hmtxTable)
+	add(tagHhea, hhea)
+	add(tagHmtx, hmtx)
+	if s.vheaTable != nil && s.vmtxTable != nil {
+		vhea, vmtx := s.metrics(s.vheaTable, s.vmtxTable)
+		add(tagVhea, vhea)
+		add(tagVmtx, vmtx)
+	}
+	if name, err := s.name(); err == nil {
+		add(tagName, name)
+	}
+	if os2, err := ld.RawTable(tagOS2); err == nil {
+		add(tagOS2, s.os2(os2))
+	}
+	// hinting tables for TrueType outlines, which are
+	// not glyph dependent
+	if s.glyf != nil {
+		for _, tag := range [...]ot.Tag{tagCvt, tagFpgm, tagPrep, tagGasp} {
+			if table, err := ld.RawTable(tag); err == nil {
+				add(tag, table)
+			}
+		}
+	}
+	if gdef, err := ld.RawTable(tagGDEF); err == nil {
+		if !opts.RetainGIDs {
+			gdef, err = s.remapGDEF(gdef)
+		}
+		if err == nil {
+			add(tagGDEF, gdef)
+		}
+	}
+	for _, layout := range [...]struct {
+		tag     ot.Tag
+		layout  font.Layout
+		lookups []bool
+	}{{tagGSUB, ft.GSUB.Layout, s.gsubLookups}, {tagGPOS, ft.GPOS.Layout, s.gposLookups}} {
+		if table, err := s.layout(layout.tag, layout.layout, layout.lookups); err == nil {
+			add(layout.tag, table)
+		}
+	}
+
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Tag < tables[j].Tag })
+	return ot.WriteTTF(tables), s.mapping, nil
+}
+
+var (
+	tagCFF  = ot.MustNewTag("CFF ")
+	tagCFF2 = ot.MustNewTag("CFF2")
+	tagCmap = ot.MustNewTag("cmap")
+	tagCvt  = ot.MustNewTag("cvt ")
+	tagFpgm = ot.MustNewTag("fpgm")
+	tagGasp = ot.MustNewTag("gasp")
+	tagGDEF = ot.MustNewTag("GDEF")
+	tagGlyf = ot.MustNewTag("glyf")
+	tagGPOS = ot.MustNewTag("GPOS")
+	tagGSUB = ot.MustNewTag("GSUB")
+	tagHead = ot.MustNewTag("head")
+	tagHhea = ot.MustNewTag("hhea")
+	tagHmtx = ot.MustNewTag("hmtx")
+	tagLoca = ot.MustNewTag("loca")
+	tagMaxp = ot.MustNewTag("maxp")
+	tagName = ot.MustNewTag("name")
+	tagOS2  = ot.MustNewTag("OS/2")
+	tagPost = ot.MustNewTag("post")
+	tagPrep = ot.MustNewTag("prep")
+	tagSize = ot.MustNewTag("size")
+	tagVhea = ot.MustNewTag("vhea")
+	tagVmtx = ot.MustNewTag("vmtx")
+)
+
+// layout returns the GSUB or GPOS table [tag], restricted to the retained features
+// and [lookups], whose parsed form is [layout].
+func (s *subsetter) layout(tag ot.Tag, layout font.Layout, lookups []bool) ([]byte, error) {
+	table, err := s.ld.RawTable(tag)
+	if err != nil {
+		return nil, err
+	}
+	lookupList, err := rawLookupList(table)
+	if err != nil {
+		return nil, err
+	}
+	if s.opts.RetainGIDs {
+		lookupList, err = emptyLookups(lookupList, lookups)
+	} else {
+		lookupList, err = s.remapLookups(lookupList, lookups, tag == tagGPOS)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pruneLayout(layout, s.opts.Features, lookupList)
+}
+
+type subsetter struct {
+	ld   *ot.Loader
+	ft   *font.Font
+	opts Options
+
+	// raw tables
+	headTable, maxpTable []byte
+	hheaTable, hmtxTable []byte
+	vheaTable, vmtxTable []byte // optional
+	glyf, loca           []byte // for TrueType outlines
+	cff                  []byte // for CFF outlines
+
+	numGlyphs  int    // in the original font
+	locaFormat uint16 // of the subset
+
+	// glyphs is the list of the glyphs of the original font, in
+	// the order of the subset. With RetainGIDs, it includes the empty glyphs.
+	glyphs []font.GID
+	// kept is true for the glyphs in the subset, indexed by original glyph
+	kept    []bool
+	mapping map[font.GID]font.GID
+
+	runes map[rune]font.GID // original glyphs
+
+	// the lookups used by the retained features, indexed by lookup
+	gsubLookups, gposLookups []bool
+}
+
+func (s *subsetter) loadTables() (err error) {
+	for _, table := range [...]struct {
+		tag ot.Tag
+		dst *[]byte
+	}{
+		{tagHead, &s.headTable},
+		{tagMaxp, &s.maxpTable},
+		{tagHhea, &s.hheaTable},
+		{tagHmtx, &s.hmtxTable},
+	} {
+		*table.dst, err = s.ld.RawTable(table.tag)
+		if err != nil {
+			return err
+		}
+	}
+	if len(s.headTable) < 54 || len(s.maxpTable) < 6 || len(s.hheaTable) < 36 {
+		return errors.New("invalid 'head', 'maxp' or 'hhea' table")
+	}
+	if len(s.hmtxTable) < 4*int(binary.BigEndian.Uint16(s.hheaTable[34:])) {
+		return errors.New("invalid 'hmtx' table, shorter than its number of metrics")
+	}
+	s.numGlyphs = int(binary.BigEndian.Uint16(s.maxpTable[4:]))
+	if s.numGlyphs == 0 {
+		return errors.New("invalid font without glyphs")
+	}
+	s.vheaTable, _ = s.ld.RawTable(tagVhea)
+	s.vmtxTable, _ = s.ld.RawTable(tagVmtx)
+	if len(s.vheaTable) < 36 || len(s.vmtxTable) < 4*int(binary.BigEndian.Uint16(s.vheaTable[34:])) {
+		s.vheaTable = nil
+	}
+
+	if s.ld.HasTable(tagGlyf) {
+		if s.glyf, err = s.ld.RawTable(tagGlyf); err != nil {
+			return err
+		}
+		if s.loca, err = s.ld.RawTable(tagLoca); err != nil {
+			return err
+		}
+	} else if s.ld.HasTable(tagCFF) {
+		if s.cff, err = s.ld.RawTable(tagCFF); err != nil {
+			return err
+		}
+	} else {
+		return errors.New("missing 'glyf' or 'CFF ' outlines")
+	}
+	return nil
+}
+
+// computeGlyphs builds the glyph set and the mapping
+func (s *subsetter) computeGlyphs() error {
+	s.kept = make([]bool, s.numGlyphs)
+	s.kept[0] = true
+	s.runes = make(map[rune]font.GID, len(s.opts.Runes))
+	for _, r := range s.opts.Runes {
+		if gid, ok := s.ft.Cmap.Lookup(r); ok && int(gid) < s.numGlyphs {
+			s.runes[r] = gid
+			s.kept[gid] = true
+		}
+	}
+	for _, gid := range s.opts.Glyphs {
+		if int(gid) >= s.numGlyphs {
+			return fmt.Errorf("invalid glyph %d (for %d glyphs)", gid, s.numGlyphs)
+		}
+		s.kept[gid] = true
+	}
+
+	s.gsubLookups = retainedLookups(s.ft.GSUB.Layout, s.opts.Features, gsubNestedLookups(s.ft.GSUB.Lookups))
+	s.gposLookups = retainedLookups(s.ft.GPOS.Layout, s.opts.Features, gposNestedLookups(s.ft.GPOS.Lookups))
+	s.closeOverGSUB()
+	if err := s.closeOverComposites(); err != nil {
+		return err
+	}
+
+	s.mapping = make(map[font.GID]font.GID)
+	s.glyphs = s.glyphs[:0]
+	for gid, kept := range s.kept {
+		if !kept {
+			continue
+		}
+		if s.opts.RetainGIDs {
+			// include the empty glyphs before gid
+			for g := len(s.glyphs); g < gid; g++ {
+				s.glyphs = append(s.glyphs, font.GID(g))
+			}
+		}
+		s.mapping[font.GID(gid)] = font.GID(len(s.glyphs))
+		s.glyphs = append(s.glyphs, font.GID(gid))
+	}
+	return nil
+}
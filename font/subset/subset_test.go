@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package subset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/harfbuzz"
+	tu "github.com/boxesandglue/typesetting/testutils"
+	hd "github.com/go-text/typesetting-utils/harfbuzz"
+	td "github.com/go-text/typesetting-utils/opentype"
+)
+
+func loadFont(t *testing.T, file []byte) (*ot.Loader, *font.Face) {
+	t.Helper()
+	ld, warnings, err := ot.NewLoaderStrict(bytes.NewReader(file))
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, len(warnings) == 0)
+	face, err := font.ParseTTF(bytes.NewReader(file))
+	tu.AssertNoErr(t, err)
+	return ld, face
+}
+
+func numGlyphs(t *testing.T, ld *ot.Loader) int {
+	t.Helper()
+	maxp, err := ld.RawTable(ot.MustNewTag("maxp"))
+	tu.AssertNoErr(t, err)
+	return int(binary.BigEndian.Uint16(maxp[4:]))
+}
+
+// assertSameGlyphs checks that the glyphs of the subset have the
+// same outlines and advances as in the original font
+func assertSameGlyphs(t *testing.T, face, subset *font.Face, mapping map[font.GID]font.GID) {
+	t.Helper()
+	for gid, newGID := range mapping {
+		tu.Assert(t, reflect.DeepEqual(face.GlyphData(gid), subset.GlyphData(newGID)))
+		tu.Assert(t, face.HorizontalAdvance(gid) == subset.HorizontalAdvance(newGID))
+	}
+}
+
+func TestSubsetGlyf(t *testing.T) {
+	file, err := td.Files.ReadFile("common/Roboto-BoldItalic.ttf")
+	tu.AssertNoErr(t, err)
+	ld, face := loadFont(t, file)
+
+	out, mapping, err := Subset(ld, Options{Runes: []rune("fiaé")})
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, len(out) < len(file)/10)
+
+	subsetLd, subset := loadFont(t, out)
+	tu.Assert(t, int(subset.Upem()) == int(face.Upem()))
+	// .notdef, the runes, the 'fi' ligature and the components of 'é'
+	tu.Assert(t, mapping[0] == 0)
+	tu.Assert(t, numGlyphs(t, subsetLd) == len(mapping))
+	_, hasLigature := mapping[1831]
+	tu.Assert(t, hasLigature)
+	for _, r := range "fiaé" {
+		gid, _ := face.NominalGlyph(r)
+		newGID, ok := subset.NominalGlyph(r)
+		tu.Assert(t, ok && newGID == mapping[gid])
+	}
+	_, ok := subset.NominalGlyph('b')
+	tu.Assert(t, !ok)
+	assertSameGlyphs(t, face, subset, mapping)
+
+	// without features, the ligature is not included
+	_, mapping, err = Subset(ld, Options{Runes: []rune("fia"), Features: []ot.Tag{}})
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, len(mapping) == 4)
+}
+
+func TestSubsetRetainGIDs(t *testing.T) {
+	file, err := td.Files.ReadFile("common/Roboto-BoldItalic.ttf")
+	tu.AssertNoErr(t, err)
+	ld, face := loadFont(t, file)
+
+	out, mapping, err := Subset(ld, Options{Runes: []rune("fia"), RetainGIDs: true, Features: []ot.Tag{ot.MustNewTag("liga")}})
+	tu.AssertNoErr(t, err)
+	lastGID := 0
+	for gid, newGID := range mapping {
+		tu.Assert(t, gid == newGID)
+		if int(gid) > lastGID {
+			lastGID = int(gid)
+		}
+	}
+	subsetLd, subset := loadFont(t, out)
+	tu.Assert(t, numGlyphs(t, subsetLd) == lastGID+1)
+	assertSameGlyphs(t, face, subset, mapping)
+	tu.Assert(t, subset.GlyphData(100).(font.GlyphOutline).Segments == nil)
+
+	// the retained features are still applied
+	buffer := harfbuzz.NewBuffer()
+	buffer.AddRunes([]rune("fia"), 0, -1)
+	buffer.GuessSegmentProperties()
+	buffer.Shape(harfbuzz.NewFont(subset), nil)
+	tu.Assert(t, len(buffer.Info) == 2)
+	tu.Assert(t, buffer.Info[0].Glyph == 1831 && buffer.Info[1].Glyph == 70)
+}
+
+func TestSubsetCFF(t *testing.T) {
+	for _, file := range []string{
+		"common/Raleway-v4020-Regular.otf",
+		"harfbuzz_reference/text-rendering-tests/fonts/FDArrayTest257.otf", // CID keyed
+	} {
+		content, err := td.Files.ReadFile(file)
+		if err != nil {
+			content, err = hd.Files.ReadFile(file)
+		}
+		tu.AssertNoErr(t, err)
+		ld, face := loadFont(t, content)
+
+		for _, retainGIDs := range []bool{false, true} {
+			out, mapping, err := Subset(ld, Options{Runes: []rune("AVx一"), RetainGIDs: retainGIDs})
+			tu.AssertNoErr(t, err)
+			tu.Assert(t, len(mapping) >= 2)
+
+			_, subset := loadFont(t, out)
+			assertSameGlyphs(t, face, subset, mapping)
+		}
+	}
+}
+
+func shape(face *font.Face, text []rune) *harfbuzz.Buffer {
+	buffer := harfbuzz.NewBuffer()
+	buffer.AddRunes(text, 0, -1)
+	buffer.GuessSegmentProperties()
+	buffer.Shape(harfbuzz.NewFont(face), nil)
+	return buffer
+}
+
+func TestSubsetLayout(t *testing.T) {
+	for _, test := range []struct {
+		file string
+		text string
+	}{
+		{"common/Roboto-BoldItalic.ttf", "fiaé AVATAR Wolf Tŷ ffl"},
+		{"common/Raleway-v4020-Regular.otf", "AVATAR office Tëst"},
+		{"common/DejaVuSans.ttf", "AVAWAY ǅ é̃ ö̈ ṩ ḝ̄ Ǭ̆ x̣̂"},
+		{"common/NotoSansArabic.ttf", "بِسْمِ ٱللَّهِ ٱلرَّحْمَٰنِ لا ﷲ ١٢٣"},
+		{"common/Mada-VF.ttf", "بِسْمِ ٱللَّهِ لا"},
+		{"common/FreeSerif.ttf", "क्षत्रिय हिन्दी श्री שָׁלוֹם"},
+	} {
+		file, err := td.Files.ReadFile(test.file)
+		tu.AssertNoErr(t, err)
+		ld, face := loadFont(t, file)
+
+		out, mapping, err := Subset(ld, Options{Runes: []rune(test.text)})
+		tu.AssertNoErr(t, err)
+		subsetLd, subset := loadFont(t, out)
+		for _, tag := range []string{"GSUB", "GPOS", "GDEF"} {
+			tu.AssertC(t, subsetLd.HasTable(ot.MustNewTag(tag)) == ld.HasTable(ot.MustNewTag(tag)), test.file+" "+tag)
+		}
+
+		// shaping with the subset gives the same result, for the
+		// whole text and its parts
+		words := append(bytes.Fields([]byte(test.text)), []byte(test.text))
+		for _, word := range words {
+			expected := shape(face, []rune(string(word)))
+			tu.AssertNoErr(t, expected.RemapGlyphs(mapping))
+			got := shape(subset, []rune(string(word)))
+			tu.AssertC(t, len(got.Info) == len(expected.Info), string(word))
+			for i, info := range got.Info {
+				tu.AssertC(t, info.Glyph == expected.Info[i].Glyph && info.Cluster == expected.Info[i].Cluster, string(word))
+			}
+			tu.AssertC(t, reflect.DeepEqual(got.Pos, expected.Pos), string(word))
+		}
+	}
+}
+
+func TestWriteLookupListExtension(t *testing.T) {
+	lookups := []remappedLookup{
+		{kind: 1, subtables: [][]byte{{0, 1, 0, 6, 0, 3}, {0, 2, 0, 8, 0, 0}}},
+		{kind: 4}, // not retained
+		{kind: 6, flag: useMarkFilteringSet, markFilteringSet: 2, subtables: [][]byte{{0, 3, 0, 0, 0, 0, 0, 0}}},
+	}
+	list, ok := writeLookupList(lookups, 7)
+	tu.Assert(t, ok)
+
+	u16 := func(data []byte, offset int) int { return int(binary.BigEndian.Uint16(data[offset:])) }
+	tu.Assert(t, u16(list, 0) == len(lookups))
+	for i, expected := range lookups {
+		lookup := list[u16(list, 2+2*i):]
+		kind := u16(lookup, 0)
+		if len(expected.subtables) == 0 {
+			tu.Assert(t, kind == expected.kind)
+		} else {
+			tu.Assert(t, kind == 7)
+		}
+		tu.Assert(t, u16(lookup, 2) == expected.flag)
+		tu.Assert(t, u16(lookup, 4) == len(expected.subtables))
+		if expected.flag&useMarkFilteringSet != 0 {
+			tu.Assert(t, u16(lookup, 6+2*len(expected.subtables)) == expected.markFilteringSet)
+		}
+		for j, subtable := range expected.subtables {
+			extension := lookup[u16(lookup, 6+2*j):]
+			tu.Assert(t, u16(extension, 0) == 1 && u16(extension, 2) == expected.kind)
+			offset := int(binary.BigEndian.Uint32(extension[4:]))
+			tu.Assert(t, bytes.Equal(extension[offset:offset+len(subtable)], subtable))
+		}
+	}
+}
+
+func TestSubsetFeatureParams(t *testing.T) {
+	read := func(file string) []byte {
+		content, err := td.Files.ReadFile(file)
+		if err != nil {
+			content, err = hd.Files.ReadFile(file)
+		}
+		tu.AssertNoErr(t, err)
+		return content
+	}
+	subset := func(file []byte, features ...string) *font.Font {
+		ld, err := ot.NewLoader(bytes.NewReader(file))
+		tu.AssertNoErr(t, err)
+		opts := Options{Runes: []rune("abc")}
+		for _, feature := range features {
+			opts.Features = append(opts.Features, ot.MustNewTag(feature))
+		}
+		out, _, err := Subset(ld, opts)
+		tu.AssertNoErr(t, err)
+		ft, err := font.ParseTTF(bytes.NewReader(out))
+		tu.AssertNoErr(t, err)
+		return ft.Font
+	}
+
+	for _, test := range []struct {
+		file    []byte
+		feature string
+	}{
+		{read("fonts/cv01.otf"), "cv01"},
+		{read("fonts/SourceSansPro-Regular.otf"), "ss01"},
+	} {
+		ld, err := ot.NewLoader(bytes.NewReader(test.file))
+		tu.AssertNoErr(t, err)
+		ft, err := font.NewFont(ld)
+		tu.AssertNoErr(t, err)
+		expected, ok := ft.FeatureNames(ot.MustNewTag(test.feature))
+		tu.Assert(t, ok)
+
+		// the parameters and their names are kept with the feature
+		names, ok := subset(test.file, test.feature).FeatureNames(ot.MustNewTag(test.feature))
+		tu.Assert(t, ok && reflect.DeepEqual(names, expected))
+		_, ok = subset(test.file, "liga").FeatureNames(ot.MustNewTag(test.feature))
+		tu.Assert(t, !ok)
+	}
+
+	file := read("common/Lmmono-italic.otf")
+	ds, ok := subset(file).DesignSize()
+	tu.Assert(t, ok)
+	tu.Assert(t, ds == font.DesignSize{Size: 10, RangeStart: 5, RangeEnd: 20, SubfamilyID: 2, SubfamilyName: "Italic"})
+}
+
+func TestSubsetInvalidMetrics(t *testing.T) {
+	file, err := td.Files.ReadFile("common/Roboto-BoldItalic.ttf")
+	tu.AssertNoErr(t, err)
+	ld, _ := loadFont(t, file)
+
+	// 'hmtx' is shorter than announced by 'hhea'
+	var tables []ot.Table
+	for _, tag := range ld.Tables() {
+		table, err := ld.RawTable(tag)
+		tu.AssertNoErr(t, err)
+		if tag == tagHmtx {
+			table = table[:len(table)/2]
+		}
+		tables = append(tables, ot.Table{Tag: tag, Content: table})
+	}
+	ld, err = ot.NewLoader(bytes.NewReader(ot.WriteTTF(tables)))
+	tu.AssertNoErr(t, err)
+	_, _, err = Subset(ld, Options{Runes: []rune("abc")})
+	tu.Assert(t, err != nil)
+}
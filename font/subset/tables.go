@@ -0,0 +1,436 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package subset
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
+)
+
+func (s *subsetter) head() []byte {
+	out := append([]byte(nil), s.headTable...)
+	binary.BigEndian.PutUint32(out[8:], 0) // checkSumAdjustment, set by WriteTTF
+	if s.glyf != nil {
+		binary.BigEndian.PutUint16(out[50:], s.locaFormat)
+	}
+	return out
+}
+
+func (s *subsetter) maxp() []byte {
+	out := append([]byte(nil), s.maxpTable...)
+	binary.BigEndian.PutUint16(out[4:], uint16(len(s.glyphs)))
+	return out
+}
+
+// post returns a version 3.0 'post' table, without glyph names
+func (s *subsetter) post() []byte {
+	out := make([]byte, 32)
+	if raw, err := s.ld.RawTable(tagPost); err == nil && len(raw) >= 32 {
+		copy(out, raw)
+	}
+	binary.BigEndian.PutUint32(out, 0x00030000)
+	return out
+}
+
+// metrics returns the updated 'hhea' and 'hmtx' tables (or 'vhea' and 'vmtx').
+func (s *subsetter) metrics(header, mtx []byte) (newHeader, newMtx []byte) {
+	numberOfMetrics := int(binary.BigEndian.Uint16(header[34:]))
+	metric := func(glyph font.GID) (advance, sideBearing uint16) {
+		index := int(glyph)
+		if index >= numberOfMetrics {
+			index = numberOfMetrics - 1
+		}
+		if index >= 0 && len(mtx) >= 4*index+4 {
+			advance = binary.BigEndian.Uint16(mtx[4*index:])
+		}
+		if int(glyph) < numberOfMetrics {
+			if len(mtx) >= 4*index+4 {
+				sideBearing = binary.BigEndian.Uint16(mtx[4*index+2:])
+			}
+		} else if pos := 4*numberOfMetrics + 2*(int(glyph)-numberOfMetrics); len(mtx) >= pos+2 {
+			sideBearing = binary.BigEndian.Uint16(mtx[pos:])
+		}
+		return advance, sideBearing
+	}
+
+	advances := make([]uint16, len(s.glyphs))
+	sideBearings := make([]uint16, len(s.glyphs))
+	for i, glyph := range s.glyphs {
+		advances[i], sideBearings[i] = metric(glyph)
+	}
+	return writeMetrics(header, advances, sideBearings)
+}
+
+// writeMetrics returns a copy of [header] and the metrics table built from
+// [advances] and [sideBearings], where trailing glyphs with the same advance are compressed.
+func writeMetrics(header []byte, advances, sideBearings []uint16) (newHeader, newMtx []byte) {
+	newNumber := len(advances)
+	for newNumber > 1 && advances[newNumber-2] == advances[newNumber-1] {
+		newNumber--
+	}
+
+	newMtx = make([]byte, 0, 4*newNumber+2*(len(advances)-newNumber))
+	for i := range advances {
+		if i < newNumber {
+			newMtx = append(newMtx, byte(advances[i]>>8), byte(advances[i]))
+		}
+		newMtx = append(newMtx, byte(sideBearings[i]>>8), byte(sideBearings[i]))
+	}
+	newHeader = append([]byte(nil), header...)
+	binary.BigEndian.PutUint16(newHeader[34:], uint16(newNumber))
+	return newHeader, newMtx
+}
+
+// cmapGroup maps the runes [start, end] to consecutive glyphs
+type cmapGroup struct {
+	start, end rune
+	glyph      font.GID
+}
+
+func (s *subsetter) cmapGroups() []cmapGroup {
+	runes := make([]rune, 0, len(s.runes))
+	for r := range s.runes {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	var groups []cmapGroup
+	for _, r := range runes {
+		glyph := s.mapping[s.runes[r]]
+		if L := len(groups); L != 0 && groups[L-1].end == r-1 &&
+			groups[L-1].glyph+font.GID(r-groups[L-1].start) == glyph && (r <= 0xFFFF) == (groups[L-1].start <= 0xFFFF) {
+			groups[L-1].end = r
+			continue
+		}
+		groups = append(groups, cmapGroup{start: r, end: r, glyph: glyph})
+	}
+	return groups
+}
+
+// cmap returns a 'cmap' table with a format 4 subtable for the BMP,
+// and a format 12 subtable if required.
+func (s *subsetter) cmap() []byte {
+	groups := s.cmapGroups()
+
+	// format 4
+	var segments []cmapGroup
+	for _, g := range groups {
+		if g.start <= 0xFFFF && g.end < 0xFFFF {
+			segments = append(segments, g)
+		}
+	}
+	segments = append(segments, cmapGroup{start: 0xFFFF, end: 0xFFFF, glyph: 0})
+	segCount := len(segments)
+	format4 := make([]byte, 16+8*segCount)
+	binary.BigEndian.PutUint16(format4, 4)
+	binary.BigEndian.PutUint16(format4[2:], uint16(len(format4)))
+	binary.BigEndian.PutUint16(format4[6:], uint16(2*segCount))
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= segCount {
+		entrySelector++
+	}
+	binary.BigEndian.PutUint16(format4[8:], uint16(2<<entrySelector))
+	binary.BigEndian.PutUint16(format4[10:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(format4[12:], uint16(2*segCount-2<<entrySelector))
+	for i, seg := range segments {
+		delta := uint16(seg.glyph) - uint16(seg.start)
+		binary.BigEndian.PutUint16(format4[14+2*i:], uint16(seg.end))
+		binary.BigEndian.PutUint16(format4[16+2*segCount+2*i:], uint16(seg.start))
+		binary.BigEndian.PutUint16(format4[16+4*segCount+2*i:], delta)
+		// idRangeOffset is zero
+	}
+
+	// format 12, only used for supplementary planes
+	var format12 []byte
+	if L := len(groups); L != 0 && groups[L-1].end > 0xFFFF {
+		format12 = make([]byte, 16+12*len(groups))
+		binary.BigEndian.PutUint16(format12, 12)
+		binary.BigEndian.PutUint32(format12[4:], uint32(len(format12)))
+		binary.BigEndian.PutUint32(format12[12:], uint32(len(groups)))
+		for i, g := range groups {
+			binary.BigEndian.PutUint32(format12[16+12*i:], uint32(g.start))
+			binary.BigEndian.PutUint32(format12[20+12*i:], uint32(g.end))
+			binary.BigEndian.PutUint32(format12[24+12*i:], uint32(g.glyph))
+		}
+	}
+
+	type record struct {
+		platform, encoding uint16
+		subtable           []byte
+	}
+	records := []record{{0, 3, format4}, {3, 1, format4}}
+	if format12 != nil {
+		records = []record{{0, 3, format4}, {0, 4, format12}, {3, 1, format4}, {3, 10, format12}}
+	}
+	out := make([]byte, 4+8*len(records))
+	binary.BigEndian.PutUint16(out[2:], uint16(len(records)))
+	offset4 := len(out)
+	out = append(out, format4...)
+	offset12 := len(out)
+	out = append(out, format12...)
+	for i, rec := range records {
+		binary.BigEndian.PutUint16(out[4+8*i:], rec.platform)
+		binary.BigEndian.PutUint16(out[6+8*i:], rec.encoding)
+		if len(rec.subtable) != 0 && rec.subtable[1] == 12 {
+			binary.BigEndian.PutUint32(out[8+8*i:], uint32(offset12))
+		} else {
+			binary.BigEndian.PutUint32(out[8+8*i:], uint32(offset4))
+		}
+	}
+	return out
+}
+
+// name returns a format 0 'name' table, with the records
+// selected by [Options.KeepNames] and the ones used by the feature parameters
+func (s *subsetter) name() ([]byte, error) {
+	raw, err := s.ld.RawTable(tagName)
+	if err != nil {
+		return nil, err
+	}
+	// the names used by the parameters of the retained features
+	featureNameIDs := make(map[tables.NameID]bool)
+	for _, layout := range [...]font.Layout{s.ft.GSUB.Layout, s.ft.GPOS.Layout} {
+		for _, feature := range layout.Features {
+			if !isFeatureRetained(s.opts.Features, feature.Tag) {
+				continue
+			}
+			_, nameIDs := featureParams(feature)
+			for _, id := range nameIDs {
+				featureNameIDs[id] = true
+			}
+		}
+	}
+	if len(raw) < 6 {
+		return nil, errors.New("invalid 'name' table (EOF)")
+	}
+	count := int(binary.BigEndian.Uint16(raw[2:]))
+	storage := int(binary.BigEndian.Uint16(raw[4:]))
+	if len(raw) < 6+12*count {
+		return nil, errors.New("invalid 'name' table (EOF)")
+	}
+	var (
+		records []byte
+		strings []byte
+	)
+	for i := 0; i < count; i++ {
+		record := raw[6+12*i : 6+12*i+12]
+		language, nameID := binary.BigEndian.Uint16(record[4:]), binary.BigEndian.Uint16(record[6:])
+		if language >= 0x8000 || (!s.opts.KeepNames && nameID > 6 && !featureNameIDs[tables.NameID(nameID)]) {
+			continue
+		}
+		length, offset := int(binary.BigEndian.Uint16(record[8:])), int(binary.BigEndian.Uint16(record[10:]))
+		if len(raw) < storage+offset+length {
+			return nil, fmt.Errorf("invalid 'name' record %d", i)
+		}
+		newRecord := append([]byte(nil), record...)
+		binary.BigEndian.PutUint16(newRecord[10:], uint16(len(strings)))
+		records = append(records, newRecord...)
+		strings = append(strings, raw[storage+offset:storage+offset+length]...)
+	}
+	out := make([]byte, 6, 6+len(records)+len(strings))
+	binary.BigEndian.PutUint16(out[2:], uint16(len(records)/12))
+	binary.BigEndian.PutUint16(out[4:], uint16(6+len(records)))
+	out = append(out, records...)
+	return append(out, strings...), nil
+}
+
+// os2 updates the first and last character indices of the 'OS/2' table
+func (s *subsetter) os2(raw []byte) []byte {
+	out := append([]byte(nil), raw...)
+	if len(out) < 68 || len(s.runes) == 0 {
+		return out
+	}
+	first, last := rune(0xFFFF), rune(0)
+	for r := range s.runes {
+		if r < first {
+			first = r
+		}
+		if r > last {
+			last = r
+		}
+	}
+	if last > 0xFFFF {
+		last = 0xFFFF
+	}
+	binary.BigEndian.PutUint16(out[64:], uint16(first))
+	binary.BigEndian.PutUint16(out[66:], uint16(last))
+	return out
+}
+
+// rawLookupList returns the LookupList of the GSUB or GPOS [table].
+func rawLookupList(table []byte) ([]byte, error) {
+	if len(table) < 10 {
+		return nil, errors.New("invalid layout table (EOF)")
+	}
+	lookupListOffset := int(binary.BigEndian.Uint16(table[8:]))
+	if lookupListOffset == 0 { // no lookups
+		return []byte{0, 0}, nil
+	}
+	if len(table) < lookupListOffset+2 {
+		return nil, errors.New("invalid layout table lookup list offset")
+	}
+	return table[lookupListOffset:], nil
+}
+
+// emptyLookups returns a copy of [lookupList], where the lookups not in [lookups]
+// are emptied. The glyph IDs are not changed, so that, for subsets,
+// it is only valid with [Options.RetainGIDs].
+func emptyLookups(lookupList []byte, lookups []bool) ([]byte, error) {
+	lookupList = append([]byte(nil), lookupList...)
+	count := int(binary.BigEndian.Uint16(lookupList))
+	if len(lookupList) < 2+2*count || count > len(lookups) {
+		return nil, errors.New("invalid layout table lookup list")
+	}
+	for i := 0; i < count; i++ {
+		if lookups[i] {
+			continue
+		}
+		offset := int(binary.BigEndian.Uint16(lookupList[2+2*i:]))
+		if len(lookupList) < offset+6 {
+			return nil, errors.New("invalid layout table lookup list")
+		}
+		// lookupFlag and subTableCount, so that the lookup is a no-op
+		binary.BigEndian.PutUint32(lookupList[offset+2:], 0)
+	}
+	return lookupList, nil
+}
+
+// featureParams returns the FeatureParams table of [feature], restricted to its
+// actual length, and the name IDs it refers to.
+// It returns nil for the features without parameters, or whose parameters are
+// not defined by the specification, that is other than 'size', 'ssXX' and 'cvXX'.
+func featureParams(feature font.Feature) ([]byte, []tables.NameID) {
+	if feature.FeatureParams == nil {
+		return nil, nil
+	}
+	prefix, d1, d2 := uint16(feature.Tag>>16), byte(feature.Tag>>8), byte(feature.Tag)
+	numbered := '0' <= d1 && d1 <= '9' && '0' <= d2 && d2 <= '9'
+	switch {
+	case feature.Tag == tagSize:
+		params, n, err := tables.ParseFeatureParamsSize(feature.FeatureParams)
+		if err != nil || !params.IsValid() {
+			return nil, nil
+		}
+		return feature.FeatureParams[:n], []tables.NameID{params.SubfamilyNameID}
+	case prefix == 's'<<8|'s' && numbered:
+		params, n, err := tables.ParseFeatureParamsStylisticSet(feature.FeatureParams)
+		if err != nil {
+			return nil, nil
+		}
+		return feature.FeatureParams[:n], []tables.NameID{params.UINameID}
+	case prefix == 'c'<<8|'v' && numbered:
+		params, n, err := tables.ParseFeatureParamsCharacterVariants(feature.FeatureParams)
+		if err != nil {
+			return nil, nil
+		}
+		nameIDs := []tables.NameID{params.FeatUILabelNameID, params.FeatUITooltipTextNameID, params.SampleTextNameID}
+		for i := 0; i < int(params.NumNamedParameters); i++ {
+			nameIDs = append(nameIDs, params.FirstParamUILabelNameID+tables.NameID(i))
+		}
+		return feature.FeatureParams[:n], nameIDs
+	}
+	return nil, nil
+}
+
+// pruneLayout rewrites a GSUB or GPOS table, whose parsed form is [layout],
+// keeping only the features selected by [features] (nil meaning all).
+// [lookupList] is copied as the new LookupList, and must not refer to data before it.
+// The feature variations are dropped.
+func pruneLayout(layout font.Layout, features []ot.Tag, lookupList []byte) ([]byte, error) {
+	// new feature indices
+	featureIndices := make([]int, len(layout.Features))
+	var retained []font.Feature
+	for i, feature := range layout.Features {
+		featureIndices[i] = -1
+		if isFeatureRetained(features, feature.Tag) {
+			featureIndices[i] = len(retained)
+			retained = append(retained, feature)
+		}
+	}
+
+	appendLangSys := func(dst []byte, ls tables.LangSys) []byte {
+		required := 0xFFFF
+		if int(ls.RequiredFeatureIndex) < len(featureIndices) && featureIndices[ls.RequiredFeatureIndex] != -1 {
+			required = featureIndices[ls.RequiredFeatureIndex]
+		}
+		var indices []int
+		for _, index := range ls.FeatureIndices {
+			if int(index) < len(featureIndices) && featureIndices[index] != -1 {
+				indices = append(indices, featureIndices[index])
+			}
+		}
+		dst = appendUint16(dst, 0) // lookupOrderOffset
+		dst = appendUint16(dst, required)
+		dst = appendUint16(dst, len(indices))
+		for _, index := range indices {
+			dst = appendUint16(dst, index)
+		}
+		return dst
+	}
+
+	// ScriptList
+	scriptList := appendUint16(nil, len(layout.Scripts))
+	var scripts []byte
+	scriptsStart := 2 + 6*len(layout.Scripts)
+	for _, script := range layout.Scripts {
+		scriptList = append(scriptList, byte(script.Tag>>24), byte(script.Tag>>16), byte(script.Tag>>8), byte(script.Tag))
+		scriptList = appendUint16(scriptList, scriptsStart+len(scripts))
+
+		headerSize := 4 + 6*len(script.LangSysRecords)
+		var langSys []byte
+		defaultOffset := 0
+		if script.DefaultLangSys != nil {
+			defaultOffset = headerSize
+			langSys = appendLangSys(langSys, *script.DefaultLangSys)
+		}
+		scriptTable := appendUint16(nil, defaultOffset)
+		scriptTable = appendUint16(scriptTable, len(script.LangSysRecords))
+		for i, record := range script.LangSysRecords {
+			scriptTable = append(scriptTable, byte(record.Tag>>24), byte(record.Tag>>16), byte(record.Tag>>8), byte(record.Tag))
+			scriptTable = appendUint16(scriptTable, headerSize+len(langSys))
+			langSys = appendLangSys(langSys, script.LangSys[i])
+		}
+		scripts = append(scripts, scriptTable...)
+		scripts = append(scripts, langSys...)
+	}
+	scriptList = append(scriptList, scripts...)
+
+	// FeatureList, with the feature parameters stored after each feature
+	featureList := appendUint16(nil, len(retained))
+	var featureTables []byte
+	featuresStart := 2 + 6*len(retained)
+	for _, feature := range retained {
+		featureList = append(featureList, byte(feature.Tag>>24), byte(feature.Tag>>16), byte(feature.Tag>>8), byte(feature.Tag))
+		featureList = appendUint16(featureList, featuresStart+len(featureTables))
+		params, _ := featureParams(feature)
+		paramsOffset := 0
+		if params != nil {
+			paramsOffset = 4 + 2*len(feature.LookupListIndices)
+		}
+		featureTables = appendUint16(featureTables, paramsOffset)
+		featureTables = appendUint16(featureTables, len(feature.LookupListIndices))
+		for _, index := range feature.LookupListIndices {
+			featureTables = appendUint16(featureTables, int(index))
+		}
+		featureTables = append(featureTables, params...)
+	}
+	featureList = append(featureList, featureTables...)
+
+	featureListOffset := 10 + len(scriptList)
+	newLookupListOffset := featureListOffset + len(featureList)
+	if newLookupListOffset > 0xFFFF {
+		return nil, errors.New("layout table overflow")
+	}
+	out := []byte{0, 1, 0, 0}
+	out = appendUint16(out, 10)
+	out = appendUint16(out, featureListOffset)
+	out = appendUint16(out, newLookupListOffset)
+	out = append(out, scriptList...)
+	out = append(out, featureList...)
+	return append(out, lookupList...), nil
+}
@@ -7,7 +7,9 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sync/atomic"
 
+	ot "github.com/boxesandglue/typesetting/font/opentype"
 	"github.com/boxesandglue/typesetting/font/opentype/tables"
 )
 
@@ -48,9 +50,21 @@ func (mv mvar) getVar(tag Tag, coords []VarCoord) float32 {
 // ---------------------------------- gvar ----------------------------------
 
 type gvar struct {
-	sharedTuples         [][]VarCoord       // with size tupleCount x axisCount
-	variations           [][]tupleVariation // with length glyphCount
-	sharedTupleActiveIdx []int              // with length tupleCount
+	sharedTuples         [][]VarCoord // with size tupleCount x axisCount
+	sharedTupleActiveIdx []int        // with length tupleCount
+
+	// the variation data of a glyph is only parsed on first access:
+	// [blocks] maps each glyph to an index into [datas] and [variations] (or -1 if the
+	// glyph has no variations), so that glyphs with identical data share the same entries
+	blocks     []int32                            // with length glyphCount
+	datas      []gvarBlock                        // with length blockCount
+	variations []atomic.Pointer[[]tupleVariation] // with length blockCount, the parsed [datas]
+}
+
+// gvarBlock is the (not yet parsed) variation data of one or several glyphs
+type gvarBlock struct {
+	tables.GlyphVariationData
+	pointsNumberCountAll int
 }
 
 func newGvar(table tables.Gvar, glyf tables.Glyf) (gvar, error) {
@@ -60,26 +74,35 @@ func newGvar(table tables.Gvar, glyf tables.Glyf) (gvar, error) {
 
 	out := gvar{
 		sharedTuples:         make([][]VarCoord, len(table.SharedTuples.SharedTuples)),
-		variations:           make([][]tupleVariation, len(table.GlyphVariationDatas)),
 		sharedTupleActiveIdx: make([]int, len(table.SharedTuples.SharedTuples)),
+		blocks:               make([]int32, len(table.GlyphVariationDatas)),
 	}
 	for i, ts := range table.SharedTuples.SharedTuples {
 		out.sharedTuples[i] = ts.Values
 	}
+
+	type blockKey struct {
+		headers              *tables.TupleVariationHeader
+		pointsNumberCountAll int
+	}
+	blocks := map[blockKey]int32{}
 	for i, vs := range table.GlyphVariationDatas {
-		tvs := make([]tupleVariation, len(vs.TupleVariationHeaders))
-		for j, header := range vs.TupleVariationHeaders {
-			tvs[j].TupleVariationHeader = header
+		if len(vs.TupleVariationHeaders) == 0 {
+			out.blocks[i] = -1
+			continue
 		}
-
-		pointsNumberCountAll := pointNumbersCount(glyf[i]) + phantomCount
-		err := parseGlyphVariationSerializedData(vs.SerializedData,
-			vs.HasSharedPointNumbers(), pointsNumberCountAll, false, tvs)
-		if err != nil {
-			return out, err
+		// identical data blocks share their storage (see tables.Gvar),
+		// but the deltas also depend on the number of points of the glyph
+		key := blockKey{&vs.TupleVariationHeaders[0], pointNumbersCount(glyf[i]) + phantomCount}
+		index, ok := blocks[key]
+		if !ok {
+			index = int32(len(out.datas))
+			out.datas = append(out.datas, gvarBlock{vs, key.pointsNumberCountAll})
+			blocks[key] = index
 		}
-		out.variations[i] = tvs
+		out.blocks[i] = index
 	}
+	out.variations = make([]atomic.Pointer[[]tupleVariation], len(out.datas))
 
 	// For shared tuples that only have one axis active, share the index of
 	// that axis as a cache. This will speed up caclulateScalar() a lot
@@ -101,6 +124,37 @@ func newGvar(table tables.Gvar, glyf tables.Glyf) (gvar, error) {
 	return out, nil
 }
 
+// glyphVariations returns the parsed variation data for [glyph],
+// parsing it on first access. Invalid data is reported with an error
+// on first access, and then ignored.
+func (gvar gvar) glyphVariations(glyph gID) ([]tupleVariation, error) {
+	if int(glyph) >= len(gvar.blocks) { // should not happend
+		return nil, nil
+	}
+	index := gvar.blocks[glyph]
+	if index == -1 {
+		return nil, nil
+	}
+	if tvs := gvar.variations[index].Load(); tvs != nil {
+		return *tvs, nil
+	}
+
+	// the parsing is deterministic, so concurrent accesses
+	// may safely store the same result
+	data := gvar.datas[index]
+	tvs := make([]tupleVariation, len(data.TupleVariationHeaders))
+	for j, header := range data.TupleVariationHeaders {
+		tvs[j].TupleVariationHeader = header
+	}
+	err := parseGlyphVariationSerializedData(data.SerializedData,
+		data.HasSharedPointNumbers(), data.pointsNumberCountAll, false, tvs)
+	if err != nil {
+		tvs = []tupleVariation{}
+	}
+	gvar.variations[index].Store(&tvs)
+	return tvs, err
+}
+
 type tupleVariation struct {
 	tables.TupleVariationHeader
 
@@ -337,7 +391,8 @@ func unpackDeltas(data []byte, pointNumbersCount int) ([]int16, error) {
 func (gvar gvar) applyDeltasToPoints(glyph gID, coords []VarCoord, points []contourPoint) {
 	// adapted from harfbuzz/src/hb-ot-var-gvar-table.hh
 
-	if int(glyph) >= len(gvar.variations) { // should not happend
+	varData, _ := gvar.glyphVariations(glyph)
+	if len(varData) == 0 {
 		return
 	}
 
@@ -353,7 +408,6 @@ func (gvar gvar) applyDeltasToPoints(glyph gID, coords []VarCoord, points []cont
 		}
 	}
 
-	varData := gvar.variations[glyph]
 	for _, tuple := range varData {
 		scalar := tuple.calculateScalar(coords, gvar.sharedTuples, gvar.sharedTupleActiveIdx)
 		if scalar == 0 {
@@ -531,6 +585,80 @@ func (face *Face) SetVariations(variations []Variation) {
 	face.SetCoords(face.NormalizeVariations(designCoords))
 }
 
+// SetOpticalSize sets the coordinate of the optical size ('opsz') axis to [size], expressed in points,
+// keeping the other coordinates unchanged. [size] is clamped to the range supported by the font.
+// It returns false, without modifying the face, if the font has no 'opsz' axis.
+//
+// The caches of the face are only invalidated if the coordinates actually change.
+func (face *Face) SetOpticalSize(size float32) bool {
+	fv := face.Font.fvar
+	opszTag := ot.MustNewTag("opsz")
+	hasAxis := false
+	for _, axis := range fv {
+		if axis.Tag == opszTag {
+			hasAxis = true
+			break
+		}
+	}
+	if !hasAxis {
+		return false
+	}
+
+	// the other axes are set to their default value, which only matters
+	// for fonts using 'avar' version 2
+	designCoords := fv.getDesignCoordsDefault([]Variation{{Tag: opszTag, Value: size}})
+	normalized := face.Font.NormalizeVariations(designCoords)
+
+	coords := make([]VarCoord, len(fv))
+	copy(coords, face.coords)
+	changed := len(face.coords) != len(fv)
+	for i, axis := range fv {
+		if axis.Tag == opszTag && coords[i] != normalized[i] {
+			coords[i] = normalized[i]
+			changed = true
+		}
+	}
+	if changed {
+		face.SetCoords(coords)
+	}
+	return true
+}
+
+// NamedInstance is a predefined position in the design space of
+// a variable font, such as "Bold Condensed".
+type NamedInstance struct {
+	SubfamilyNameID  tables.NameID // The name of the instance in the 'name' table
+	PostScriptNameID tables.NameID // The PostScript name of the instance, or 0xFFFF if not provided
+	Coords           []float32     // The design coordinates of the instance, one per axis
+}
+
+// NamedInstances returns the named instances defined in the 'fvar' table,
+// or nil for non variable fonts.
+// The returned slice, including the coordinates, is a copy owned by the caller.
+func (f *Font) NamedInstances() []NamedInstance {
+	if len(f.instances) == 0 || len(f.fvar) == 0 {
+		return nil
+	}
+	out := make([]NamedInstance, len(f.instances))
+	for i, inst := range f.instances {
+		out[i] = NamedInstance{
+			SubfamilyNameID:  tables.NameID(inst.SubfamilyNameID),
+			PostScriptNameID: tables.NameID(inst.PostScriptNameID),
+			Coords:           append([]float32(nil), inst.Coordinates...),
+		}
+	}
+	return out
+}
+
+// SetNamedInstance applies the coordinates of the named instance [index],
+// as returned by [Font.NamedInstances].
+//
+// This method panics if [index] is out of range.
+func (face *Face) SetNamedInstance(index int) {
+	coords := face.Font.instances[index].Coordinates
+	face.SetCoords(face.NormalizeVariations(coords))
+}
+
 // getDesignCoordsDefault returns the design coordinates corresponding to the given pairs of axis/value.
 // The default value of the axis is used when not specified in the variations.
 func (fv fvar) getDesignCoordsDefault(variations []Variation) []float32 {
@@ -588,7 +716,7 @@ func (fv fvar) normalizeCoordinates(coords []float32) []VarCoord {
 // values for the axis are mapped to the interval [-1,1], with the default
 // axis value mapped to 0.
 //
-// Any additional scaling defined in the face's `avar` table is also
+// Any additional scaling defined in the face's `avar` table (version 1 or 2) is also
 // applied, as described at https://docs.microsoft.com/en-us/typography/opentype/spec/avar.
 //
 // This method panics if `coords` has not the correct length, that is the number of axis inf 'fvar'.
@@ -614,5 +742,21 @@ func (f *Font) NormalizeVariations(coords []float32) []VarCoord {
 		}
 	}
 
+	// 'avar' version 2 : the coordinates are then adjusted with the variation store,
+	// evaluated at the coordinates mapped above
+	if store := &f.avar.VarStore; store.AxisCount() == len(normalized) {
+		mapped := append([]VarCoord(nil), normalized...)
+		for i := range normalized {
+			delta := store.GetDelta(f.avar.AxisIndexMap.Index(tables.GlyphID(i)), mapped)
+			v := int32(normalized[i]) + int32(math.Round(float64(delta)))
+			if v < -1<<14 {
+				v = -1 << 14
+			} else if v > 1<<14 {
+				v = 1 << 14
+			}
+			normalized[i] = VarCoord(v)
+		}
+	}
+
 	return normalized
 }
@@ -4,8 +4,10 @@ package font
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 	"math"
+	"math/rand"
 	"os"
 	"reflect"
 	"testing"
@@ -90,6 +92,33 @@ func TestGlyphExtentsVar(t *testing.T) {
 	}
 }
 
+func TestGlyphExtentsCache(t *testing.T) {
+	font := loadFont(t, "common/SourceSans-VF-HVAR.ttf")
+	face := NewFace(font)
+	tu.Assert(t, face.extentsCache == nil) // lazily allocated
+
+	ext, ok := face.GlyphExtents(2)
+	tu.Assert(t, ok && len(face.extentsCache) == int(font.nGlyphs))
+	cached, _ := face.extentsCache.get(2)
+	tu.Assert(t, cached.state == extentsValid && cached.extents == ext)
+
+	// missing extents are also cached
+	face.extentsCache.set(3, GlyphExtents{}, false)
+	_, ok = face.GlyphExtents(3)
+	tu.Assert(t, !ok)
+
+	// changing the coordinates clears the cache
+	face.SetVariations([]Variation{{Tag: font.fvar[0].Tag, Value: 900}})
+	_, known := face.extentsCache.get(2)
+	tu.Assert(t, !known)
+	varExt, _ := face.GlyphExtents(2)
+	raw, _ := face.glyphExtentsRaw(2)
+	tu.Assert(t, varExt == raw && varExt != ext)
+
+	_, ok = face.GlyphExtents(GID(font.nGlyphs))
+	tu.Assert(t, !ok)
+}
+
 func TestGetDefaultCoords(t *testing.T) {
 	tf := fvar{
 		{Tag: ot.MustNewTag("wght"), Minimum: 38, Default: 88, Maximum: 250},
@@ -128,6 +157,105 @@ func TestNormalizeVar(t *testing.T) {
 	tu.Assert(t, reflect.DeepEqual(coords, []VarCoord{tables.NewCoord(1)}))
 }
 
+func TestNormalizeVarAvar2(t *testing.T) {
+	// the weight is increased by 0.25 when the width is at its maximum
+	data, err := hex.DecodeString("0002000000000002" + // header
+		"00000000" + // identity segment maps
+		"0000000000000014" + // no axis index map, variation store
+		"00010000000C00010000001C" + // variation store header
+		"00020001000000000000000040004000" + // region list
+		"000200010001000010000000") // item variation data
+	tu.AssertNoErr(t, err)
+	avar, _, err := tables.ParseAvar(data)
+	tu.AssertNoErr(t, err)
+
+	ft := &Font{
+		fvar: fvar{
+			{Tag: ot.MustNewTag("wght"), Minimum: 100, Default: 400, Maximum: 900},
+			{Tag: ot.MustNewTag("wdth"), Minimum: 50, Default: 100, Maximum: 200},
+		},
+		avar: avar,
+	}
+	tu.Assert(t, reflect.DeepEqual(ft.NormalizeVariations([]float32{400, 100}), []VarCoord{0, 0}))
+	tu.Assert(t, reflect.DeepEqual(ft.NormalizeVariations([]float32{400, 200}), []VarCoord{0x1000, 0x4000}))
+	tu.Assert(t, reflect.DeepEqual(ft.NormalizeVariations([]float32{400, 150}), []VarCoord{0x0800, 0x2000}))
+	tu.Assert(t, reflect.DeepEqual(ft.NormalizeVariations([]float32{650, 150}), []VarCoord{0x2800, 0x2000}))
+	// the result is clamped
+	tu.Assert(t, reflect.DeepEqual(ft.NormalizeVariations([]float32{900, 200}), []VarCoord{0x4000, 0x4000}))
+
+	// invalid stores are ignored
+	ft.fvar = ft.fvar[:1]
+	tu.Assert(t, reflect.DeepEqual(ft.NormalizeVariations([]float32{900}), []VarCoord{0x4000}))
+}
+
+func TestNamedInstances(t *testing.T) {
+	ft := loadFont(t, "common/Commissioner-VF.ttf")
+	instances := ft.NamedInstances()
+	tu.Assert(t, len(instances) == 18)
+	tu.Assert(t, ft.names.Name(instances[0].SubfamilyNameID) == "Thin")
+	tu.Assert(t, ft.names.Name(instances[14].SubfamilyNameID) == "SemiBold Italic")
+	tu.Assert(t, reflect.DeepEqual(instances[14].Coords, []float32{600, -12, 0, 0}))
+	for _, inst := range instances {
+		tu.Assert(t, inst.PostScriptNameID == 0xFFFF)
+	}
+	// the coordinates are copied
+	instances[14].Coords[0] = 100
+	tu.Assert(t, ft.NamedInstances()[14].Coords[0] == 600)
+
+	face := NewFace(ft)
+	face.SetNamedInstance(6) // Bold
+	tu.Assert(t, ft.names.Name(instances[6].SubfamilyNameID) == "Bold")
+	bold := face.Coords()
+	face.SetVariations([]Variation{{Tag: ot.MustNewTag("wght"), Value: 700}})
+	tu.Assert(t, reflect.DeepEqual(bold, face.Coords()))
+
+	ft = loadFont(t, "common/Raleway-v4020-Regular.otf")
+	tu.Assert(t, ft.NamedInstances() == nil)
+}
+
+func TestSetOpticalSize(t *testing.T) {
+	face := NewFace(loadFont(t, "toys/Var1.ttf")) // opsz axis : [10, 14, 72], third axis
+	tu.Assert(t, face.SetOpticalSize(72))
+	coords := face.Coords()
+	tu.Assert(t, len(coords) == 15 && coords[2] == 1<<14)
+	for i, c := range coords {
+		tu.Assert(t, i == 2 || c == 0)
+	}
+	generation := face.generation
+	tu.Assert(t, face.SetOpticalSize(100)) // clamped to the same value
+	tu.Assert(t, face.generation == generation)
+
+	// the other axes are preserved
+	face.SetVariations([]Variation{{Tag: ot.MustNewTag("wght"), Value: 250}})
+	tu.Assert(t, face.SetOpticalSize(10))
+	tu.Assert(t, face.Coords()[0] == 1<<14 && face.Coords()[2] == -1<<14)
+
+	face = NewFace(loadFont(t, "common/Commissioner-VF.ttf"))
+	tu.Assert(t, !face.SetOpticalSize(12))
+	tu.Assert(t, face.Coords() == nil)
+}
+
+func TestStyleName(t *testing.T) {
+	for _, file := range []string{"common/Commissioner-VF.ttf", "common/SourceSans-VF.ttf", "common/NotoSansCJKjp-VF.otf"} {
+		ft := loadFont(t, file)
+		// named instances are expected to agree with the 'STAT' table
+		for _, inst := range ft.NamedInstances() {
+			tu.Assert(t, ft.StyleName(inst.Coords) == ft.names.Name(inst.SubfamilyNameID))
+		}
+	}
+
+	ft := loadFont(t, "common/Commissioner-VF.ttf")
+	tu.Assert(t, len(ft.StyleAttributes().DesignAxes) == 4)
+	tu.Assert(t, ft.StyleName(nil) == "Thin")
+	tu.Assert(t, ft.StyleName([]float32{420, 0, 0, 0}) == "Regular") // elided fallback
+	tu.Assert(t, ft.StyleName([]float32{700, -12, 100, 0}) == "Bold Italic Flair")
+	tu.Assert(t, ft.StyleName([]float32{700, -12, 100, 100}) == "Bold Italic Loud")
+	tu.Assert(t, ft.StyleName([]float32{700}) == "") // invalid coordinates
+
+	ft = loadFont(t, "common/Raleway-v4020-Regular.otf")
+	tu.Assert(t, ft.StyleName(nil) == "")
+}
+
 func TestAdvanceHVar(t *testing.T) {
 	font := loadFont(t, "common/Commissioner-VF.ttf")
 	coords := []VarCoord{-6553, 0, 13108, tables.NewCoord(1)}
@@ -169,6 +297,87 @@ func TestAdvanceNoHVar(t *testing.T) {
 	}
 }
 
+func TestAdvanceCFF2NoHVar(t *testing.T) {
+	font := loadFont(t, "common/NotoSansCJKjp-VF.otf")
+	tu.Assert(t, font.cff2 != nil && font.hvar != nil)
+
+	face := Face{Font: font}
+	face.SetVariations([]Variation{{Tag: ot.MustNewTag("wght"), Value: 900}})
+	for gid, exp := range map[GID]float32{1: 229, 38: 630, 75: 323, 149: 1000, 408: 920, 778: 1000} {
+		tu.Assert(t, face.HorizontalAdvance(gid) == exp)
+	}
+	withHVAR := make([]float32, 1000)
+	for i := range withHVAR {
+		withHVAR[i] = face.HorizontalAdvance(GID(i))
+	}
+
+	// simulate a font without HVAR: the advances must follow the
+	// blended outlines, instead of keeping the default ones
+	font.hvar = nil
+	face.SetVariations([]Variation{{Tag: ot.MustNewTag("wght"), Value: 900}}) // clear the cached advances
+	var sumErr, sumDefaultErr float64
+	for i, exp := range withHVAR {
+		got, defaultAdvance := face.HorizontalAdvance(GID(i)), float32(font.hmtx.Advance(gID(i)))
+		if defaultAdvance == float32(font.upem) { // fixed width glyphs
+			tu.Assert(t, got == defaultAdvance)
+			continue
+		}
+		if i < 40 { // Latin glyphs
+			tu.Assert(t, math.Abs(float64(got-exp)) <= 0.16*float64(exp))
+		}
+		sumErr += math.Abs(float64(got - exp))
+		sumDefaultErr += math.Abs(float64(defaultAdvance - exp))
+	}
+	tu.Assert(t, sumErr < sumDefaultErr)
+	// empty glyph (space)
+	tu.Assert(t, face.HorizontalAdvance(1) == float32(font.hmtx.Advance(1)))
+
+	// the default instance is not modified
+	face.SetVariations(nil)
+	for i := range withHVAR {
+		tu.Assert(t, face.HorizontalAdvance(GID(i)) == float32(font.hmtx.Advance(gID(i))))
+	}
+}
+
+func TestVerticalMetricsVar(t *testing.T) {
+	font := loadFont(t, "common/NotoSansCJKjp-VF.otf")
+	tu.Assert(t, font.HasVerticalMetrics() && font.vvar != nil && font.vorg != nil)
+	face := Face{Font: font}
+	gid, _ := font.NominalGlyph(0x6F22)
+
+	face.SetVariations(nil)
+	defaultAdvance := face.VerticalAdvance(gid)
+	face.SetVariations([]Variation{{Tag: ot.MustNewTag("wght"), Value: 900}})
+	tu.Assert(t, face.VerticalAdvance(gid) == defaultAdvance-getAdvanceDeltaUnscaled(font.vvar, gID(gid), face.coords))
+
+	// without VORG, the origin is derived from the top side bearing and the outline
+	font.vorg = nil
+	extents, _ := face.GlyphExtents(gid)
+	_, y, ok := face.GlyphVOrigin(gid)
+	tu.Assert(t, ok)
+	tu.Assert(t, y == int32(extents.YBearing)+int32(face.getVerticalSideBearing(gID(gid))))
+
+	// without VVAR, the vertical advance is approximated from the outline,
+	// or kept for glyphs designed on the em grid
+	withVVAR := face.VerticalAdvance(gid)
+	font.vvar = nil
+	face.SetVariations([]Variation{{Tag: ot.MustNewTag("wght"), Value: 900}})
+	tu.Assert(t, math.Abs(float64(face.VerticalAdvance(gid)-withVVAR)) <= 0.02*math.Abs(float64(withVVAR)))
+
+	// without vmtx, the vertical advance is synthesized from the font extents
+	font = loadFont(t, "toys/GVAR-no-HVAR.ttf")
+	tu.Assert(t, !font.HasVerticalMetrics())
+	face = Face{Font: font}
+	for _, wght := range []float32{80, 600} {
+		face.SetVariations([]Variation{{Tag: ot.MustNewTag("wght"), Value: wght}})
+		extents, ok := face.FontHExtents()
+		tu.Assert(t, ok)
+		for gid := GID(0); gid < 10; gid++ {
+			tu.Assert(t, face.VerticalAdvance(gid) == -(extents.Ascender-extents.Descender))
+		}
+	}
+}
+
 func TestInvalidGVAR(t *testing.T) {
 	// this file is build by subsetting the 'glyf' table
 	// but keeping the variations tables
@@ -191,10 +400,71 @@ func TestInvalidGVAR(t *testing.T) {
 	tu.AssertNoErr(t, err)
 	gvar, _, err := tables.ParseGvar(raw)
 	tu.AssertNoErr(t, err)
-	// check that newGvar does not crash..
-	_, err = newGvar(gvar, glyf)
+	// the glyph variations are parsed lazily..
+	gv, err := newGvar(gvar, glyf)
+	tu.AssertNoErr(t, err)
+	// .. so check that accessing them does not crash..
+	var nbErrors int
+	for gid := range glyf {
+		if _, err = gv.glyphVariations(gID(gid)); err != nil {
+			nbErrors++
+		}
+		// invalid data is then ignored
+		_, err = gv.glyphVariations(gID(gid))
+		tu.AssertNoErr(t, err)
+	}
 	// ... and reports an error
-	tu.Assert(t, err != nil)
+	tu.Assert(t, nbErrors != 0)
+}
+
+func TestGvarSharedBlocks(t *testing.T) {
+	ft := loadFont(t, "common/Commissioner-VF.ttf")
+
+	withVariations := 0
+	for _, block := range ft.gvar.blocks {
+		if block != -1 {
+			withVariations++
+		}
+	}
+	// identical variation data are only stored once
+	tu.Assert(t, len(ft.gvar.datas) < withVariations)
+
+	// and parsed on demand
+	gid, _ := ft.NominalGlyph('a')
+	index := ft.gvar.blocks[gid]
+	tu.Assert(t, ft.gvar.variations[index].Load() == nil)
+	face := NewFace(ft)
+	face.SetVariations([]Variation{{Tag: ot.MustNewTag("wght"), Value: 900}})
+	_, ok := face.GlyphData(gid).(GlyphOutline)
+	tu.Assert(t, ok)
+	tu.Assert(t, ft.gvar.variations[index].Load() != nil)
+}
+
+func BenchmarkLoadGvar(b *testing.B) {
+	fp := readFontFile(b, "common/Commissioner-VF.ttf")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := NewFont(fp)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGvarGlyphData(b *testing.B) {
+	ft := loadFont(b, "common/Commissioner-VF.ttf")
+	face := NewFace(ft)
+	face.SetVariations([]Variation{{Tag: ot.MustNewTag("wght"), Value: 900}})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for gid := GID(0); gid < 200; gid++ {
+			face.GlyphData(gid)
+		}
+	}
 }
 
 func TestCFF2Var(t *testing.T) {
@@ -731,3 +1001,74 @@ func TestCFF2Var(t *testing.T) {
 		}
 	}
 }
+
+func TestAdvanceCache(t *testing.T) {
+	for _, file := range []string{
+		"common/SourceSans-VF-HVAR.ttf",
+		"common/NotoSansCJKjp-VF.otf",
+	} {
+		ft := loadFont(t, file)
+		face := NewFace(ft)
+
+		check := func() {
+			// twice, so that the cached values are used
+			for range [2]int{} {
+				for gid := GID(0); int(gid) < ft.nGlyphs && gid < 1000; gid++ {
+					expected := face.horizontalAdvanceVar(gid, face.getBaseAdvance(gID(gid), face.hmtx, false))
+					tu.AssertC(t, face.HorizontalAdvance(gid) == expected, fmt.Sprint(file, gid))
+				}
+			}
+		}
+
+		face.SetVariations([]Variation{{Tag: ot.MustNewTag("wght"), Value: 900}})
+		check()
+		tu.Assert(t, face.advanceCache != nil)
+
+		// changing the coordinates clears the cache
+		face.SetVariations([]Variation{{Tag: ot.MustNewTag("wght"), Value: 300}})
+		tu.Assert(t, *face.advanceCache == advanceCache{})
+		check()
+	}
+
+	// the cache is not used for static fonts
+	face := NewFace(loadFont(t, "common/Roboto-BoldItalic.ttf"))
+	face.HorizontalAdvance(1)
+	tu.Assert(t, face.advanceCache == nil)
+}
+
+// BenchmarkHorizontalAdvanceVar simulates the advances queried
+// when shaping a CJK paragraph: a few hundred distinct glyphs,
+// with the most frequent ones often repeated.
+func BenchmarkHorizontalAdvanceVar(b *testing.B) {
+	ft := loadFont(b, "common/NotoSansCJKjp-VF.otf")
+	face := NewFace(ft)
+	face.SetVariations([]Variation{{Tag: ot.MustNewTag("wght"), Value: 900}})
+
+	var text []GID
+	rng := rand.New(rand.NewSource(1))
+	for _, r := range []rune("\u7684\u4E00\u662F\u4E0D\u4E86\u4EBA\u6211\u5728\u6709\u4ED6\u8FD9\u4E3A\u4E4B\u5927\u6765\u4EE5\u4E2A\u4E2D\u4E0A\u4EEC\u5230\u8BF4\u56FD\u548C\u5730\u4E5F\u5B50\u65F6\u9053\u51FA\u800C\u8981\u4E8E\u5C31\u4E0B\u5F97\u53EF\u4F60\u5E74\u751F\u81EA\u4F1A\u90A3\u540E\u80FD\u5BF9\u7740\u4E8B\u5176\u8BED") {
+		gid, _ := ft.Cmap.Lookup(r)
+		for n := rng.Intn(20) + 1; n > 0; n-- {
+			text = append(text, gid)
+		}
+	}
+	for i := 0; i < 500; i++ {
+		text = append(text, GID(rng.Intn(20000)+1000))
+	}
+	rng.Shuffle(len(text), func(i, j int) { text[i], text[j] = text[j], text[i] })
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, gid := range text {
+				face.HorizontalAdvance(gid)
+			}
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, gid := range text {
+				face.horizontalAdvanceVar(gid, face.getBaseAdvance(gID(gid), face.hmtx, false))
+			}
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package fontscan
+
+// Duplicates groups the copies of the same font,
+// as returned by [FindDuplicates].
+type Duplicates struct {
+	// PostScriptName is the name shared by the copies.
+	PostScriptName string
+
+	// Kept is the copy used by [SystemFonts] and [FontMap]:
+	// the one with the highest version, or the first one found if
+	// the versions are equal.
+	Kept Footprint
+
+	// Discarded are the other copies.
+	Discarded []Footprint
+}
+
+// FindDuplicates returns the fonts of [footprints] which are present several times,
+// for instance in both the system and the user font directories.
+// Two footprints are considered as copies of the same font if they have
+// the same (non empty) PostScript name.
+//
+// The groups are returned in the order of their first copy in [footprints].
+// User provided fonts (see [FontMap.AddFont]) are never considered as duplicates.
+func FindDuplicates(footprints []Footprint) []Duplicates {
+	var (
+		out     []Duplicates
+		indices = make(map[string]int) // PostScript name -> index in out
+	)
+	for _, fp := range footprints {
+		if fp.isUserProvided || fp.PostScriptName == "" {
+			continue
+		}
+		index, has := indices[fp.PostScriptName]
+		if !has {
+			indices[fp.PostScriptName] = len(out)
+			out = append(out, Duplicates{PostScriptName: fp.PostScriptName, Kept: fp})
+			continue
+		}
+		group := &out[index]
+		if fp.Version > group.Kept.Version {
+			group.Kept, fp = fp, group.Kept
+		}
+		group.Discarded = append(group.Discarded, fp)
+	}
+
+	// only keep the actual duplicates
+	filtered := out[:0]
+	for _, group := range out {
+		if len(group.Discarded) != 0 {
+			filtered = append(filtered, group)
+		}
+	}
+	return filtered
+}
+
+// removeDuplicates returns [footprints] without the copies
+// discarded by [FindDuplicates], preserving the order.
+func removeDuplicates(footprints []Footprint) []Footprint {
+	duplicates := FindDuplicates(footprints)
+	if len(duplicates) == 0 {
+		return footprints
+	}
+	kept := make(map[string]Location, len(duplicates))
+	for _, group := range duplicates {
+		kept[group.PostScriptName] = group.Kept.Location
+	}
+	out := make([]Footprint, 0, len(footprints))
+	for _, fp := range footprints {
+		if location, isDuplicated := kept[fp.PostScriptName]; isDuplicated && !fp.isUserProvided && fp.Location != location {
+			continue
+		}
+		out = append(out, fp)
+	}
+	return out
+}
@@ -0,0 +1,229 @@
+package fontscan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/boxesandglue/typesetting/font"
+	"github.com/boxesandglue/typesetting/language"
+)
+
+// FallbackConfig restricts and orders the fonts used as fallback by a [FontMap],
+// which is useful to avoid broken, low-quality or license-restricted fonts, and
+// to get a deterministic fallback on servers.
+//
+// The fonts matching exactly one of the families of the query (see [FontMap.SetQuery])
+// are not affected : the configuration only applies to the fallback steps of
+// [FontMap.ResolveFace], including the expansion of generic families.
+//
+// Its fields support JSON encoding, so that it may be loaded from a configuration file,
+// and it may be stored with the system fonts index (see [SaveFallbackConfig]).
+type FallbackConfig struct {
+	// ExcludeFiles are patterns, with the syntax of [filepath.Match], of the font files
+	// never used as fallback. Patterns containing a path separator are matched
+	// against the full path, the others against the base name of the file,
+	// so that "NotoColorEmoji*.ttf" excludes the files with this name in every directory.
+	ExcludeFiles []string `json:"excludeFiles,omitempty"`
+	// ExcludeFamilies are the families never used as fallback.
+	ExcludeFamilies []string `json:"excludeFamilies,omitempty"`
+	// AllowFamilies, if not empty, restricts the system fonts used as fallback
+	// to the given families. The fonts added with [FontMap.AddFont] and [FontMap.AddFace]
+	// are always allowed, unless explicitly excluded.
+	AllowFamilies []string `json:"allowFamilies,omitempty"`
+	// PreferredFamilies maps a script, given by its ISO 15924 code (like "Arab" or "Hani"),
+	// to the families tried first, in order, when falling back for text in this script
+	// (see [FontMap.SetScript]).
+	PreferredFamilies map[string][]string `json:"preferredFamilies,omitempty"`
+}
+
+// fallbackFilter is the compiled form of a [FallbackConfig].
+// The nil value allows every font.
+type fallbackFilter struct {
+	excludeFiles    []string
+	excludeFamilies map[string]bool
+	allowFamilies   map[string]bool // nil to allow every family
+	preferred       map[language.Script][]string
+}
+
+func normalizedFamilies(families []string) map[string]bool {
+	out := make(map[string]bool, len(families))
+	for _, family := range families {
+		out[font.NormalizeFamily(family)] = true
+	}
+	return out
+}
+
+// compile checks the patterns and scripts of the config
+func (fc FallbackConfig) compile() (*fallbackFilter, error) {
+	out := &fallbackFilter{
+		excludeFiles:    fc.ExcludeFiles,
+		excludeFamilies: normalizedFamilies(fc.ExcludeFamilies),
+		preferred:       make(map[language.Script][]string, len(fc.PreferredFamilies)),
+	}
+	for _, pattern := range fc.ExcludeFiles {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid fallback config: file pattern %q: %s", pattern, err)
+		}
+	}
+	if len(fc.AllowFamilies) != 0 {
+		out.allowFamilies = normalizedFamilies(fc.AllowFamilies)
+	}
+	for tag, families := range fc.PreferredFamilies {
+		script, err := language.ParseScript(tag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fallback config: %s", err)
+		}
+		normalized := make([]string, len(families))
+		for i, family := range families {
+			normalized[i] = font.NormalizeFamily(family)
+		}
+		out.preferred[script] = normalized
+	}
+	return out, nil
+}
+
+// allows returns false if [fp] must not be used as fallback
+func (ff *fallbackFilter) allows(fp *Footprint) bool {
+	if ff == nil {
+		return true
+	}
+	if ff.excludeFamilies[fp.Family] {
+		return false
+	}
+	if ff.allowFamilies != nil && !fp.isUserProvided && !ff.allowFamilies[fp.Family] {
+		return false
+	}
+	for _, pattern := range ff.excludeFiles {
+		name := fp.Location.File
+		if !strings.ContainsRune(pattern, filepath.Separator) {
+			name = filepath.Base(name)
+		}
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// filter removes the footprints not allowed from [candidates], in place
+func (ff *fallbackFilter) filter(database fontSet, candidates []int) []int {
+	if ff == nil {
+		return candidates
+	}
+	filtered := candidates[:0]
+	for _, index := range candidates {
+		if ff.allows(&database[index]) {
+			filtered = append(filtered, index)
+		}
+	}
+	return filtered
+}
+
+// prioritize returns a copy of [candidates] where the preferred families
+// for [script] come first, or [candidates] if there is nothing to change.
+func (ff *fallbackFilter) prioritize(database fontSet, candidates []int, script language.Script) []int {
+	if ff == nil || len(ff.preferred[script]) == 0 {
+		return candidates
+	}
+	preferred := ff.preferred[script]
+	rank := func(index int) int {
+		for i, family := range preferred {
+			if database[index].Family == family {
+				return i
+			}
+		}
+		return len(preferred)
+	}
+	out := append([]int(nil), candidates...)
+	sort.SliceStable(out, func(i, j int) bool { return rank(out[i]) < rank(out[j]) })
+	return out
+}
+
+// SetFallbackConfig configures the fonts used as fallback by [FontMap.ResolveFace].
+// See [FallbackConfig] for details. Passing the zero value removes the restrictions.
+//
+// An error is returned if the config is invalid, in which case
+// the current configuration is not modified.
+func (fm *FontMap) SetFallbackConfig(config FallbackConfig) error {
+	filter, err := config.compile()
+	if err != nil {
+		return err
+	}
+	fm.fallback = filter
+	fm.built = false
+	fm.lru.Clear()
+	return nil
+}
+
+const fallbackConfigFile = "font_fallback.json"
+
+// SaveFallbackConfig stores [config] in [cacheDir], next to the system fonts index,
+// so that it is used by the subsequent calls to [FontMap.UseSystemFonts] with the same directory.
+// As for [FontMap.UseSystemFonts], an empty [cacheDir] selects the default cache directory.
+func SaveFallbackConfig(cacheDir string, config FallbackConfig) error {
+	if _, err := config.compile(); err != nil {
+		return err
+	}
+	path, err := fallbackConfigPath(cacheDir)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", " ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("unable to create font cache dir: %w", err)
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// LoadFallbackConfig reads the config stored in [cacheDir] by [SaveFallbackConfig].
+// An error wrapping [fs.ErrNotExist] is returned if no config has been saved.
+func LoadFallbackConfig(cacheDir string) (FallbackConfig, error) {
+	path, err := fallbackConfigPath(cacheDir)
+	if err != nil {
+		return FallbackConfig{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FallbackConfig{}, err
+	}
+	var config FallbackConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return FallbackConfig{}, fmt.Errorf("invalid fallback config %s: %s", path, err)
+	}
+	return config, nil
+}
+
+func fallbackConfigPath(userProvided string) (string, error) {
+	dir, err := cacheDir(userProvided)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fallbackConfigFile), nil
+}
+
+// loadSavedFallbackConfig applies the config saved in [cacheDir], if any
+// and if no config has been set.
+func (fm *FontMap) loadSavedFallbackConfig(cacheDir string) {
+	if fm.fallback != nil {
+		return
+	}
+	config, err := LoadFallbackConfig(cacheDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		err = fm.SetFallbackConfig(config)
+	}
+	if err != nil {
+		fm.logger.Printf("ignoring fallback config: %s", err)
+	}
+}
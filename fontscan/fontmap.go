@@ -7,10 +7,12 @@ import (
 	"path/filepath"
 	"runtime"
 	"sync"
+	"unicode"
 
 	"github.com/boxesandglue/typesetting/font"
 	ot "github.com/boxesandglue/typesetting/font/opentype"
 	"github.com/boxesandglue/typesetting/language"
+	"github.com/boxesandglue/typesetting/unicodedata"
 )
 
 type cacheEntry struct {
@@ -20,6 +22,12 @@ type cacheEntry struct {
 	font.Aspect
 }
 
+// instanceKey identifies an instance of a variable font
+type instanceKey struct {
+	location Location
+	aspect   font.Aspect
+}
+
 // Logger is a type that can log warnings.
 type Logger interface {
 	Printf(format string, args ...interface{})
@@ -31,6 +39,8 @@ type Logger interface {
 // SystemFonts loads the system fonts, using an index stored in [cacheDir].
 // See [FontMap.UseSystemFonts] for more details.
 //
+// The fonts present several times (see [FindDuplicates]) are only returned once.
+//
 // If [logger] is nil, log.Default() is used.
 func SystemFonts(logger Logger, cacheDir string) ([]Footprint, error) {
 	if logger == nil {
@@ -44,7 +54,7 @@ func SystemFonts(logger Logger, cacheDir string) ([]Footprint, error) {
 	}
 
 	// systemFonts is read-only, so may be used concurrently
-	return systemFonts.flatten(), nil
+	return removeDuplicates(systemFonts.flatten()), nil
 }
 
 // FontMap provides a mechanism to select a [font.Face] from a font description.
@@ -62,12 +72,18 @@ type FontMap struct {
 	firstFace *font.Face
 	faceCache map[Location]*font.Face
 	metaCache map[*font.Font]cacheEntry
+	// instances of variable fonts, sharing the [font.Font] of [faceCache]
+	instanceCache map[instanceKey]*font.Face
 
 	// the database to query, either loaded from an index
 	// or populated with the [UseSystemFonts], [AddFont], and/or [AddFace] method.
 	database  fontSet
 	scriptMap map[language.Script][]int
-	lru       runeLRU
+	// maps the PostScript names of the system fonts to their index in database,
+	// used to skip duplicates (see [FindDuplicates])
+	postScriptNames map[string]int
+	discarded       []Footprint // the duplicates not added to database, see [FontMap.Duplicates]
+	lru             runeLRU
 
 	// built holds whether the candidates are populated.
 	built bool
@@ -79,8 +95,14 @@ type FontMap struct {
 	footprintsBuffer scoredFootprints
 	cribleBuffer     familyCrible
 
-	query  Query           // current query
-	script language.Script // current script
+	query  Query             // current query
+	script language.Script   // current script
+	lang   language.Language // current language, optional
+
+	watcher *fontWatcher // optional, see [Watch]
+
+	userSubstitutions []substitution  // optional, see [SetSubstitutions]
+	fallback          *fallbackFilter // optional, see [SetFallbackConfig]
 }
 
 // NewFontMap return a new font map, which should be filled with the `UseSystemFonts`
@@ -91,16 +113,45 @@ func NewFontMap(logger Logger) *FontMap {
 		logger = log.New(log.Writer(), "fontscan", log.Flags())
 	}
 	fm := &FontMap{
-		logger:       logger,
-		faceCache:    make(map[Location]*font.Face),
-		metaCache:    make(map[*font.Font]cacheEntry),
-		cribleBuffer: make(familyCrible, 150),
-		scriptMap:    make(map[language.Script][]int),
+		logger:          logger,
+		faceCache:       make(map[Location]*font.Face),
+		metaCache:       make(map[*font.Font]cacheEntry),
+		instanceCache:   make(map[instanceKey]*font.Face),
+		cribleBuffer:    make(familyCrible, 150),
+		scriptMap:       make(map[language.Script][]int),
+		postScriptNames: make(map[string]int),
 	}
 	fm.lru.maxSize = 4096
 	return fm
 }
 
+// SetSubstitutions configures the family substitutions used to resolve
+// the families of the current query (see [FontMap.SetQuery]), which by default
+// are the built-in rules, derived from the fontconfig configuration.
+// This is useful to define aliases (like "Helvetica" for "Liberation Sans"),
+// or to customize the fonts used for generic families.
+//
+// An error is returned if one of the rules is invalid, in which case
+// the current substitutions are not modified.
+func (fm *FontMap) SetSubstitutions(config SubstitutionConfig) error {
+	rules, err := config.compile()
+	if err != nil {
+		return err
+	}
+	fm.userSubstitutions = rules
+	fm.built = false
+	fm.lru.Clear()
+	return nil
+}
+
+// substitutions returns the family substitutions to use
+func (fm *FontMap) substitutions() []substitution {
+	if fm.userSubstitutions != nil {
+		return fm.userSubstitutions
+	}
+	return familySubstitution
+}
+
 // SetRuneCacheSize configures the size of the cache powering [FontMap.ResolveFace].
 // Applications displaying large quantities of text should tune this value to be greater
 // than the number of unique glyphs they expect to display at one time in order to achieve
@@ -120,8 +171,14 @@ func (fm *FontMap) SetRuneCacheSize(size int) {
 // NOTE: On Android, callers *must* provide a writable path manually, as it cannot
 // be inferred without access to the Java runtime environment of the application.
 //
+// If no fallback configuration has been set with [FontMap.SetFallbackConfig],
+// the one stored in the cache directory by [SaveFallbackConfig], if any, is used.
+//
 // Multiple font maps may call this method concurrently, without duplicating
 // the work of finding the system fonts.
+//
+// When several copies of the same font are found, only the one with the highest version
+// is used, so that the result does not depend on the scan order : see [FontMap.Duplicates].
 func (fm *FontMap) UseSystemFonts(cacheDir string) error {
 	// safe for concurrent use; subsequent calls are no-ops
 	err := initSystemFonts(fm.logger, cacheDir)
@@ -132,24 +189,73 @@ func (fm *FontMap) UseSystemFonts(cacheDir string) error {
 	// systemFonts is read-only, so may be used concurrently
 	fm.appendFootprints(systemFonts.flatten()...)
 
+	fm.loadSavedFallbackConfig(cacheDir)
+
 	fm.built = false
 
 	fm.lru.Clear()
 	return nil
 }
 
+// AddFootprints adds fonts described by their footprints to the font map,
+// typically the batches produced by [StreamSystemFonts].
+// The footprints must refer to font files on the file system, and are
+// considered as system fonts : the copies of a font already added are ignored,
+// unless their version is higher (see [FontMap.Duplicates]).
+func (fm *FontMap) AddFootprints(footprints ...Footprint) {
+	if len(footprints) == 0 {
+		return
+	}
+	fm.appendFootprints(footprints...)
+
+	fm.built = false
+
+	fm.lru.Clear()
+}
+
 // appendFootprints adds the provided footprints to the database and maps their script
 // coverage.
+//
+// The copies of a system font already in the database are skipped, or
+// replace it if their version is higher (see [FindDuplicates]).
 func (fm *FontMap) appendFootprints(footprints ...Footprint) {
-	startIdx := len(fm.database)
-	fm.database = append(fm.database, footprints...)
-	// Insert entries into scriptMap for each footprint's covered scripts.
-	for i, fp := range footprints {
-		dbIdx := startIdx + i
+	replaced := false
+	for _, fp := range footprints {
+		if !fp.isUserProvided && fp.PostScriptName != "" {
+			if dbIdx, isDuplicate := fm.postScriptNames[fp.PostScriptName]; isDuplicate {
+				if fp.Version > fm.database[dbIdx].Version {
+					fm.database[dbIdx], fp = fp, fm.database[dbIdx]
+					replaced = true
+				}
+				fm.discarded = append(fm.discarded, fp)
+				continue
+			}
+			fm.postScriptNames[fp.PostScriptName] = len(fm.database)
+		}
+
+		dbIdx := len(fm.database)
+		fm.database = append(fm.database, fp)
+		// Insert entries into scriptMap for each footprint's covered scripts.
 		for _, script := range fp.Scripts {
 			fm.scriptMap[script] = append(fm.scriptMap[script], dbIdx)
 		}
 	}
+
+	if replaced { // the script coverage of the new versions may be different
+		fm.scriptMap = make(map[language.Script][]int)
+		for dbIdx, fp := range fm.database {
+			for _, script := range fp.Scripts {
+				fm.scriptMap[script] = append(fm.scriptMap[script], dbIdx)
+			}
+		}
+	}
+}
+
+// Duplicates returns the system fonts found several times, with the copies
+// which have been ignored by the font map. See [FindDuplicates] for details.
+func (fm *FontMap) Duplicates() []Duplicates {
+	all := append(append([]Footprint(nil), fm.database...), fm.discarded...)
+	return FindDuplicates(all)
 }
 
 // systemFonts is a global index of the system fonts.
@@ -178,6 +284,18 @@ func cacheDir(userProvided string) (string, error) {
 	return configDir, nil
 }
 
+// indexCachePath returns the path of the index file, stored in
+// the [userProvided] directory or in the default cache directory.
+func indexCachePath(userProvided string) (string, error) {
+	const cacheFilePattern = "font_index_v%d.cache"
+
+	dir, err := cacheDir(userProvided)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fmt.Sprintf(cacheFilePattern, cacheFormatVersion)), nil
+}
+
 // initSystemFonts scan the system fonts and update `SystemFonts`.
 // If the returned error is nil, `SystemFonts` is guaranteed to contain
 // at least one valid font.Face.
@@ -186,17 +304,13 @@ func initSystemFonts(logger Logger, userCacheDir string) error {
 	var err error
 
 	initSystemFontsOnce.Do(func() {
-		const cacheFilePattern = "font_index_v%d.cache"
-
 		// load an existing index
-		var dir string
-		dir, err = cacheDir(userCacheDir)
+		var cachePath string
+		cachePath, err = indexCachePath(userCacheDir)
 		if err != nil {
 			return
 		}
 
-		cachePath := filepath.Join(dir, fmt.Sprintf(cacheFilePattern, cacheFormatVersion))
-
 		systemFonts, err = refreshSystemFontsIndex(logger, cachePath)
 	})
 
@@ -274,7 +388,6 @@ func (fm *FontMap) AddFont(fontFile font.Resource, fileID, familyName string) er
 
 		fp.Location.File = fileID
 		fp.Location.Index = uint16(i)
-		// TODO: for now, we do not handle variable fonts
 
 		if familyName != "" {
 			// give priority to the user provided family
@@ -346,6 +459,7 @@ func (fm *FontMap) FontMetadata(ft *font.Font) (family string, aspect font.Aspec
 //
 // Family names are compared through [font.Normalize].
 func (fm *FontMap) FindSystemFont(family string) (Location, bool) {
+	fm.applyWatchUpdate()
 	family = font.NormalizeFamily(family)
 	for _, footprint := range fm.database {
 		if footprint.isUserProvided {
@@ -360,6 +474,7 @@ func (fm *FontMap) FindSystemFont(family string) (Location, bool) {
 
 // FindSystemFonts is the same as FindSystemFont, but returns all matched fonts.
 func (fm *FontMap) FindSystemFonts(family string) []Location {
+	fm.applyWatchUpdate()
 	var locations []Location
 	family = font.NormalizeFamily(family)
 	for _, footprint := range fm.database {
@@ -391,6 +506,19 @@ func (fm *FontMap) SetScript(s language.Script) {
 	fm.built = false
 }
 
+// SetLanguage set the (BCP 47) language of the (next) runes passed to [ResolveFace].
+// Among the fallback fonts supporting the current script, the fonts declaring
+// this language, either in their 'meta' table or in their OS/2 code pages, are preferred.
+// This is required to select the culturally correct glyphs for unified
+// Han characters, for instance 'ja', 'zh-Hans' or 'zh-TW'.
+//
+// An empty language (the default) disables this preference.
+// Note that fonts added with [AddFace] do not declare any language.
+func (fm *FontMap) SetLanguage(lang language.Language) {
+	fm.lang = language.NewLanguage(string(lang))
+	fm.built = false
+}
+
 // candidates is a cache storing the indices into FontMap.database of footprints matching a Query
 // families
 type candidates struct {
@@ -424,11 +552,19 @@ func (fm *FontMap) buildCandidates() {
 	// first pass for an exact match
 	{
 		for _, family := range fm.query.Families {
-			candidates := fm.database.selectByFamilyExact(family, fm.cribleBuffer, &fm.footprintsBuffer)
+			candidates := fm.database.selectByFamilyExact(family, fm.substitutions(), fm.cribleBuffer, &fm.footprintsBuffer)
 			if len(candidates) == 0 {
 				continue
 			}
 
+			if isGenericFamily(family) {
+				// generic families are resolved with substitutions : apply the fallback restrictions
+				candidates = fm.fallback.filter(fm.database, candidates)
+				if len(candidates) == 0 {
+					continue
+				}
+			}
+
 			// select the correct aspects
 			candidates = fm.database.retainsBestMatches(candidates, fm.query.Aspect)
 
@@ -440,10 +576,12 @@ func (fm *FontMap) buildCandidates() {
 
 	// second pass with substitutions
 	{
-		candidates := fm.database.selectByFamilyWithSubs(fm.query.Families, fm.script, fm.cribleBuffer, &fm.footprintsBuffer)
+		candidates := fm.database.selectByFamilyWithSubs(fm.query.Families, fm.script, fm.lang, fm.substitutions(), fm.cribleBuffer, &fm.footprintsBuffer)
+		candidates = fm.fallback.filter(fm.database, candidates)
 
 		// select the correct aspects
 		candidates = fm.database.retainsBestMatches(candidates, fm.query.Aspect)
+		candidates = fm.fallback.prioritize(fm.database, candidates, fm.script)
 
 		// candidates is owned by fm.footprintsBuffer: copy its content
 		S := fm.candidates.withFallback
@@ -459,6 +597,7 @@ func (fm *FontMap) buildCandidates() {
 	// third pass with user provided fonts
 	{
 		fm.candidates.manual = fm.database.filterUserProvided(fm.candidates.manual)
+		fm.candidates.manual = fm.fallback.filter(fm.database, fm.candidates.manual)
 		fm.candidates.manual = fm.database.retainsBestMatches(fm.candidates.manual, fm.query.Aspect)
 	}
 
@@ -484,6 +623,59 @@ func (fm *FontMap) resolveForRune(candidates []int, r rune) *font.Face {
 	return nil
 }
 
+// resolveColorForRune is the same as [resolveForRune], but only considers
+// the fonts with color glyphs
+func (fm *FontMap) resolveColorForRune(candidates []int, r rune) *font.Face {
+	for _, footprintIndex := range candidates {
+		if fp := fm.database[footprintIndex]; fp.HasColorGlyphs && fp.Runes.Contains(r) {
+			face, err := fm.loadFont(fp)
+			if err != nil { // very unlikely; try another family
+				fm.logger.Printf("failed loading face: %v", err)
+				continue
+			}
+
+			return face
+		}
+	}
+
+	return nil
+}
+
+// resolveEmoji returns the first color font supporting `r`,
+// looking first in the fallback and manual candidates, then in the whole database.
+// It returns nil if no color font supports `r`.
+func (fm *FontMap) resolveEmoji(r rune) *font.Face {
+	if face := fm.resolveColorForRune(fm.candidates.withFallback, r); face != nil {
+		return face
+	}
+	if face := fm.resolveColorForRune(fm.candidates.manual, r); face != nil {
+		return face
+	}
+	for _, fp := range fm.database {
+		if !fp.HasColorGlyphs || !fp.Runes.Contains(r) || !fm.fallback.allows(&fp) {
+			continue
+		}
+		face, err := fm.loadFont(fp)
+		if err != nil { // very unlikely; try another font
+			fm.logger.Printf("failed loading face: %v", err)
+			continue
+		}
+		return face
+	}
+	return nil
+}
+
+// isEmoji returns true for the runes which are usually rendered
+// as emojis, that is, as an approximation of the Emoji_Presentation property,
+// the Extended_Pictographic runes in the supplementary planes, the regional
+// indicators (used for flags) and the keycap combining mark.
+func isEmoji(r rune) bool {
+	if 0x1F1E6 <= r && r <= 0x1F1FF || r == 0x20E3 {
+		return true
+	}
+	return r >= 0x1F000 && unicode.Is(unicodedata.Extended_Pictographic, r)
+}
+
 // returns nil if no candidates support the language `lang`
 func (fm *FontMap) resolveForLang(candidates []int, lang LangID) *font.Face {
 	for _, footprintIndex := range candidates {
@@ -517,11 +709,21 @@ func (fm *FontMap) resolveForLang(candidates []int, lang LangID) *font.Face {
 //	4 - All fonts matching the current script (set by [FontMap.SetScript]) are tried,
 //		ignoring [Query.Aspect]
 //
+// For emojis, color fonts (see [Footprint.HasColorGlyphs]) are preferred
+// over the fallback fonts of steps 2 to 4.
+//
+// The fallback fonts may be restricted and ordered with [FontMap.SetFallbackConfig].
+//
+// Variable fonts are matched through the instance closest to [Query.Aspect]
+// (see [Footprint.Variations]), and the returned face has the corresponding variations.
+//
 // If no fonts match after these steps, an arbitrary face will be returned.
 // This face will be nil only if the underlying font database is empty,
 // or if the file system is broken; otherwise the returned [font.Face] is always valid.
 func (fm *FontMap) ResolveFace(r rune) (face *font.Face) {
-	key := fm.lru.KeyFor(fm.query, fm.script, r)
+	fm.applyWatchUpdate()
+
+	key := fm.lru.KeyFor(fm.query, fm.script, fm.lang, r)
 	face, ok := fm.lru.Get(key, fm.query)
 	if ok {
 		return face
@@ -539,6 +741,13 @@ func (fm *FontMap) ResolveFace(r rune) (face *font.Face) {
 		return face
 	}
 
+	// emojis are preferably rendered with color glyphs
+	if isEmoji(r) {
+		if face := fm.resolveEmoji(r); face != nil {
+			return face
+		}
+	}
+
 	// if no family has matched so far, try again with system fallback,
 	// including fonts with matching script and user provided ones
 	if face := fm.resolveForRune(fm.candidates.withFallback, r); face != nil {
@@ -554,8 +763,7 @@ func (fm *FontMap) ResolveFace(r rune) (face *font.Face) {
 	}
 
 	fm.logger.Printf("No font matched for aspect %v, script %s, and rune %U (%c) -> searching by script coverage only", fm.query.Aspect, fm.script, r, r)
-	scriptCandidates := fm.scriptMap[fm.script]
-	if face := fm.resolveForRune(scriptCandidates, r); face != nil {
+	if face := fm.resolveForRune(fm.scriptCandidates(fm.script), r); face != nil {
 		return face
 	}
 
@@ -563,6 +771,9 @@ func (fm *FontMap) ResolveFace(r rune) (face *font.Face) {
 	// return an arbitrary face
 	if fm.firstFace == nil && len(fm.database) > 0 {
 		for _, fp := range fm.database {
+			if !fm.fallback.allows(&fp) {
+				continue
+			}
 			face, err := fm.loadFont(fp)
 			if err != nil {
 				// very unlikely; warn and keep going
@@ -580,11 +791,24 @@ func (fm *FontMap) ResolveFace(r rune) (face *font.Face) {
 	// and we should never return a nil face.
 }
 
+// scriptCandidates returns the fonts supporting [script],
+// with the restrictions and priorities of the fallback configuration
+func (fm *FontMap) scriptCandidates(script language.Script) []int {
+	candidates := fm.scriptMap[script]
+	if fm.fallback == nil {
+		return candidates
+	}
+	candidates = fm.fallback.filter(fm.database, append([]int(nil), candidates...))
+	return fm.fallback.prioritize(fm.database, candidates, script)
+}
+
 // ResolveForLang returns the first face supporting the given language
 // (for the actual query), or nil if no one is found.
 //
 // The matching logic is similar to the one used by [ResolveFace].
 func (fm *FontMap) ResolveFaceForLang(lang LangID) *font.Face {
+	fm.applyWatchUpdate()
+
 	// no-op if already built
 	fm.buildCandidates()
 
@@ -607,7 +831,120 @@ func (fm *FontMap) ResolveFaceForLang(lang LangID) *font.Face {
 	return nil
 }
 
+// FontsForRune returns the faces supporting the rune [r], in the order
+// used by [ResolveFace] for the current query (see [FontMap.SetQuery],
+// [FontMap.SetScript] and [FontMap.SetLanguage]) : the first face is the one
+// returned by [ResolveFace], followed by the other candidates, and then by the
+// remaining fonts supporting [r].
+// It returns an empty slice if no font supports [r].
+//
+// This is useful to implement custom fallback chains, or to
+// find which fonts can display a given rune. Note that all the
+// returned fonts are loaded (and cached), which may be slow.
+func (fm *FontMap) FontsForRune(r rune) []*font.Face {
+	fm.applyWatchUpdate()
+
+	indices := fm.orderedCandidates(func(fp *Footprint) bool { return fp.Runes.Contains(r) }, isEmoji(r), fm.script)
+	return fm.loadFonts(indices)
+}
+
+// FontsForScript returns the faces supporting the script [s],
+// ordered according to the current query, as for [FontMap.FontsForRune].
+// It returns an empty slice if no font supports [s].
+func (fm *FontMap) FontsForScript(s language.Script) []*font.Face {
+	fm.applyWatchUpdate()
+
+	indices := fm.orderedCandidates(func(fp *Footprint) bool { return fp.Scripts.contains(s) }, false, s)
+	return fm.loadFonts(indices)
+}
+
+// orderedCandidates returns the indices of the footprints accepted by [accept],
+// following the steps of [ResolveFace], with the fonts supporting [script]
+// as last fallback, before the other fonts.
+func (fm *FontMap) orderedCandidates(accept func(fp *Footprint) bool, preferColor bool, script language.Script) []int {
+	// no-op if already built
+	fm.buildCandidates()
+
+	var (
+		seen = make([]bool, len(fm.database))
+		out  []int
+	)
+	isFallback := false // true after the exact matches
+	add := func(index int, colorOnly bool) {
+		fp := &fm.database[index]
+		if seen[index] || (colorOnly && !fp.HasColorGlyphs) || !accept(fp) {
+			return
+		}
+		if isFallback && !fm.fallback.allows(fp) {
+			return
+		}
+		seen[index] = true
+		out = append(out, index)
+	}
+	addAll := func(candidates []int, colorOnly bool) {
+		for _, index := range candidates {
+			add(index, colorOnly)
+		}
+	}
+
+	addAll(fm.candidates.withoutFallback, false)
+	isFallback = true
+	if preferColor {
+		addAll(fm.candidates.withFallback, true)
+		addAll(fm.candidates.manual, true)
+		for index := range fm.database {
+			add(index, true)
+		}
+	}
+	addAll(fm.candidates.withFallback, false)
+	addAll(fm.candidates.manual, false)
+	addAll(fm.scriptCandidates(script), false)
+	for index := range fm.database {
+		add(index, false)
+	}
+	return out
+}
+
+// loadFonts loads the given footprints, skipping the invalid ones
+func (fm *FontMap) loadFonts(indices []int) []*font.Face {
+	out := make([]*font.Face, 0, len(indices))
+	for _, index := range indices {
+		face, err := fm.loadFont(fm.database[index])
+		if err != nil { // very unlikely; try the other fonts
+			fm.logger.Printf("failed loading face: %v", err)
+			continue
+		}
+		out = append(out, face)
+	}
+	return out
+}
+
+// loadFont returns the face for [fp], using the instance closest
+// to the current query for variable fonts.
 func (fm *FontMap) loadFont(fp Footprint) (*font.Face, error) {
+	face, err := fm.loadDefaultFont(fp)
+	if err != nil || fp.Variations.isEmpty() {
+		return face, err
+	}
+
+	query := fm.query.Aspect
+	query.SetDefaults()
+	aspect := fp.closestAspect(query)
+	if aspect == fp.Aspect { // use the default instance
+		return face, nil
+	}
+	key := instanceKey{fp.Location, aspect}
+	if instance, hasCached := fm.instanceCache[key]; hasCached {
+		return instance, nil
+	}
+	instance := font.NewFace(face.Font)
+	instance.SetVariations(fp.Variations.variations(aspect))
+	fm.instanceCache[key] = instance
+	return instance, nil
+}
+
+// loadDefaultFont returns the face for [fp], with default variations.
+func (fm *FontMap) loadDefaultFont(fp Footprint) (*font.Face, error) {
 	if face, hasCached := fm.faceCache[fp.Location]; hasCached {
 		return face, nil
 	}
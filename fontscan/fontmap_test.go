@@ -2,12 +2,15 @@ package fontscan
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -17,6 +20,7 @@ import (
 	"github.com/boxesandglue/typesetting/language"
 	"github.com/boxesandglue/typesetting/shaping"
 	tu "github.com/boxesandglue/typesetting/testutils"
+	td "github.com/go-text/typesetting-utils/opentype"
 )
 
 func ExampleFontMap_UseSystemFonts() {
@@ -150,6 +154,32 @@ func TestResolveFallbackManual(t *testing.T) {
 	tu.Assert(t, face != nil && fm.FontLocation(face.Font).File == "user:Amiri")
 }
 
+func TestResolveEmoji(t *testing.T) {
+	fm := NewFontMap(log.New(io.Discard, "", 0))
+
+	for _, file := range []string{"common/DejaVuSans.ttf", "bitmap/NotoColorEmoji.ttf"} {
+		b, err := td.Files.ReadFile(file)
+		tu.AssertNoErr(t, err)
+		err = fm.AddFont(bytes.NewReader(b), "user:"+file, "")
+		tu.AssertNoErr(t, err)
+	}
+	tu.Assert(t, !fm.database[0].HasColorGlyphs && fm.database[1].HasColorGlyphs)
+
+	fm.SetQuery(Query{}) // no families
+	face := fm.ResolveFace('a')
+	tu.Assert(t, fm.FontLocation(face.Font).File == "user:common/DejaVuSans.ttf")
+
+	// DejaVuSans supports U+1F600, but the color font is preferred
+	tu.Assert(t, fm.database[0].Runes.Contains(0x1F600))
+	face = fm.ResolveFace(0x1F600)
+	tu.Assert(t, fm.FontLocation(face.Font).File == "user:bitmap/NotoColorEmoji.ttf")
+
+	// unless explicitly requested
+	fm.SetQuery(Query{Families: []string{"DejaVu Sans"}})
+	face = fm.ResolveFace(0x1F600)
+	tu.Assert(t, fm.FontLocation(face.Font).File == "user:common/DejaVuSans.ttf")
+}
+
 func TestResolveLang(t *testing.T) {
 	logger := log.New(os.Stdout, "", 0)
 	fm := NewFontMap(logger)
@@ -326,6 +356,35 @@ func TestQueryHelveticaLinux(t *testing.T) {
 	tu.Assert(t, family == font.NormalizeFamily("Nimbus Sans")) // prefered Helvetica replacement
 }
 
+func TestSetSubstitutions(t *testing.T) {
+	file1, err := os.Open("../font/testdata/Amiri-Regular.ttf")
+	tu.AssertNoErr(t, err)
+	defer file1.Close()
+
+	fm := NewFontMap(nil)
+	err = fm.AddFont(file1, "file1", "Nimbus Sans")
+	tu.AssertNoErr(t, err)
+	err = fm.AddFont(file1, "file2", "Liberation Sans")
+	tu.AssertNoErr(t, err)
+
+	fm.SetQuery(Query{Families: []string{"Helvetica"}})
+	fm.SetScript(language.Latin)
+	family, _ := fm.FontMetadata(fm.ResolveFace('x').Font)
+	tu.Assert(t, family == font.NormalizeFamily("Nimbus Sans")) // built-in rules
+
+	err = fm.SetSubstitutions(SubstitutionConfig{Rules: []SubstitutionRule{
+		{Family: "Helvetica", Substitutes: []string{"Liberation Sans"}, Position: PositionPrepend, Binding: BindingStrong},
+	}})
+	tu.AssertNoErr(t, err)
+	family, _ = fm.FontMetadata(fm.ResolveFace('x').Font)
+	tu.Assert(t, family == font.NormalizeFamily("Liberation Sans"))
+
+	err = fm.SetSubstitutions(SubstitutionConfig{Rules: []SubstitutionRule{{Family: ""}}})
+	tu.Assert(t, err != nil)
+	family, _ = fm.FontMetadata(fm.ResolveFace('x').Font)
+	tu.Assert(t, family == font.NormalizeFamily("Liberation Sans")) // not modified
+}
+
 func TestFindSytemFont(t *testing.T) {
 	fm := NewFontMap(log.New(io.Discard, "", 0))
 	_, ok := fm.FindSystemFont("Nimbus")
@@ -462,3 +521,179 @@ func TestResolve_SciptKhmer(t *testing.T) {
 	family, _ := fm.FontMetadata(runs[0].Face.Font)
 	tu.Assert(t, family == "khmeros")
 }
+
+func TestResolveDeclaredLanguage(t *testing.T) {
+	han := newRuneSet('a', '漢', '字')
+	scripts := ScriptSet{language.Han, language.Latin}
+	aspect := font.Aspect{Style: font.StyleNormal, Weight: font.WeightNormal, Stretch: font.StretchNormal}
+	fps := []Footprint{
+		{Family: "han one", Runes: han, Scripts: scripts, Aspect: aspect, Location: Location{File: "sc.otf"}, SupportedLangs: []language.Language{"hans"}},
+		{Family: "han two", Runes: han, Scripts: scripts, Aspect: aspect, Location: Location{File: "tc.otf"}, DesignLangs: []language.Language{"zh-hant"}},
+		{Family: "han three", Runes: han, Scripts: scripts, Aspect: aspect, Location: Location{File: "jp.otf"}, DesignLangs: []language.Language{"jpan"}},
+		{Family: "han four", Runes: han, Scripts: scripts, Aspect: aspect, Location: Location{File: "kr.otf"}, SupportedLangs: []language.Language{"kore"}},
+	}
+	fm := NewFontMap(log.New(io.Discard, "", 0))
+	fm.appendFootprints(fps...)
+	for _, fp := range fps {
+		fm.cache(fp, &font.Face{Font: new(font.Font)})
+	}
+	fm.SetQuery(Query{Families: []string{"serif"}})
+	fm.SetScript(language.Han)
+
+	for _, test := range []struct {
+		lang language.Language
+		file string
+	}{
+		{"", "sc.otf"}, // database order
+		{"ja", "jp.otf"},
+		{"ja-JP", "jp.otf"},
+		{"zh-TW", "tc.otf"},
+		{"zh-Hant", "tc.otf"},
+		{"zh-HK", "tc.otf"},
+		{"zh", "sc.otf"},
+		{"zh-CN", "sc.otf"},
+		{"ko", "kr.otf"},
+		{"fr", "sc.otf"},
+	} {
+		fm.SetLanguage(test.lang)
+		face := fm.ResolveFace('漢')
+		tu.AssertC(t, fm.FontLocation(face.Font).File == test.file, string(test.lang))
+	}
+}
+
+func TestFontsForRuneAndScript(t *testing.T) {
+	fm := NewFontMap(log.New(io.Discard, "", 0))
+
+	for _, file := range []string{"common/DejaVuSans.ttf", "bitmap/NotoColorEmoji.ttf", "common/NotoSansArabic.ttf"} {
+		b, err := td.Files.ReadFile(file)
+		tu.AssertNoErr(t, err)
+		err = fm.AddFont(bytes.NewReader(b), "user:"+file, "")
+		tu.AssertNoErr(t, err)
+	}
+	locations := func(faces []*font.Face) (out []string) {
+		for _, face := range faces {
+			out = append(out, fm.FontLocation(face.Font).File)
+		}
+		return out
+	}
+
+	fm.SetQuery(Query{}) // no families
+	tu.Assert(t, len(fm.FontsForRune(0x10FFFD)) == 0)
+
+	faces := fm.FontsForRune('a')
+	tu.Assert(t, len(faces) >= 1 && faces[0] == fm.ResolveFace('a'))
+	tu.Assert(t, reflect.DeepEqual(locations(faces)[:1], []string{"user:common/DejaVuSans.ttf"}))
+
+	// the color font is preferred for emojis, as in ResolveFace
+	faces = fm.FontsForRune(0x1F600)
+	tu.Assert(t, reflect.DeepEqual(locations(faces), []string{"user:bitmap/NotoColorEmoji.ttf", "user:common/DejaVuSans.ttf"}))
+	tu.Assert(t, faces[0] == fm.ResolveFace(0x1F600))
+
+	// unless explicitly requested
+	fm.SetQuery(Query{Families: []string{"DejaVu Sans"}})
+	faces = fm.FontsForRune(0x1F600)
+	tu.Assert(t, reflect.DeepEqual(locations(faces), []string{"user:common/DejaVuSans.ttf", "user:bitmap/NotoColorEmoji.ttf"}))
+
+	faces = fm.FontsForScript(language.Arabic)
+	tu.Assert(t, len(faces) >= 1)
+	for _, face := range faces {
+		_, ok := face.NominalGlyph(0x0628)
+		tu.Assert(t, ok)
+	}
+	tu.Assert(t, len(fm.FontsForScript(language.Tangut)) == 0)
+}
+
+func TestResolveVariableFont(t *testing.T) {
+	fm := NewFontMap(log.New(io.Discard, "", 0))
+
+	b, err := td.Files.ReadFile("common/Commissioner-VF.ttf")
+	tu.AssertNoErr(t, err)
+	err = fm.AddFont(bytes.NewReader(b), "user:Commissioner", "")
+	tu.AssertNoErr(t, err)
+	fp := fm.database[0]
+	tu.Assert(t, fp.Variations.Weight == [2]font.Weight{100, 900} && fp.Variations.Slant == -12)
+
+	fm.SetQuery(Query{Families: []string{"Commissioner"}, Aspect: font.Aspect{Weight: font.WeightBold, Style: font.StyleItalic}})
+	bold := fm.ResolveFace('a')
+	tu.Assert(t, fm.FontLocation(bold.Font).File == "user:Commissioner")
+	expected := font.NewFace(bold.Font)
+	expected.SetVariations([]font.Variation{{Tag: ot.MustNewTag("wght"), Value: 700}, {Tag: ot.MustNewTag("slnt"), Value: -12}})
+	tu.Assert(t, reflect.DeepEqual(bold.Coords(), expected.Coords()))
+
+	// the default instance is Thin
+	fm.SetQuery(Query{Families: []string{"Commissioner"}, Aspect: font.Aspect{Weight: 100}})
+	thin := fm.ResolveFace('a')
+	tu.Assert(t, thin.Font == bold.Font && thin != bold && len(thin.Coords()) == 0)
+
+	// instances are cached
+	fm.SetQuery(Query{Families: []string{"Commissioner"}, Aspect: font.Aspect{Weight: font.WeightBold, Style: font.StyleItalic}})
+	tu.Assert(t, fm.ResolveFace('b') == bold)
+}
+
+func TestFallbackConfig(t *testing.T) {
+	fm := NewFontMap(log.New(io.Discard, "", 0))
+	for _, file := range []string{"common/DejaVuSans.ttf", "common/NotoSansArabic.ttf"} {
+		b, err := td.Files.ReadFile(file)
+		tu.AssertNoErr(t, err)
+		tu.AssertNoErr(t, fm.AddFont(bytes.NewReader(b), "user:"+file, ""))
+	}
+	amiri, err := os.Open(filepath.Join("..", "font", "testdata", "Amiri-Regular.ttf"))
+	tu.AssertNoErr(t, err)
+	defer amiri.Close()
+	tu.AssertNoErr(t, fm.AddFont(amiri, "user:amiri.ttf", ""))
+
+	resolve := func(r rune) string {
+		return fm.FontLocation(fm.ResolveFace(r).Font).File
+	}
+
+	fm.SetQuery(Query{})
+	fm.SetScript(language.Arabic)
+	for _, test := range []struct {
+		config   FallbackConfig
+		expected string
+	}{
+		{FallbackConfig{PreferredFamilies: map[string][]string{"Arab": {"Amiri", "Noto Sans Arabic"}}}, "user:amiri.ttf"},
+		{FallbackConfig{PreferredFamilies: map[string][]string{"arab": {"Noto Sans Arabic", "Amiri"}}}, "user:common/NotoSansArabic.ttf"},
+		{FallbackConfig{ExcludeFamilies: []string{"Amiri", "Noto Sans Arabic"}}, "user:common/DejaVuSans.ttf"},
+		{FallbackConfig{ExcludeFiles: []string{"user:common/*"}}, "user:amiri.ttf"},
+		{FallbackConfig{ExcludeFiles: []string{"DejaVu*", "amiri.ttf"}}, "user:common/NotoSansArabic.ttf"},
+	} {
+		tu.AssertNoErr(t, fm.SetFallbackConfig(test.config))
+		tu.AssertC(t, resolve(0x0628) == test.expected, fmt.Sprint(test.config))
+		for _, face := range fm.FontsForRune(0x0628) {
+			tu.Assert(t, fm.fallback.allows(&Footprint{
+				Family:         fm.metaCache[face.Font].Family,
+				Location:       fm.FontLocation(face.Font),
+				isUserProvided: true,
+			}))
+		}
+	}
+
+	// the families of the query are not affected
+	tu.AssertNoErr(t, fm.SetFallbackConfig(FallbackConfig{ExcludeFamilies: []string{"Amiri"}}))
+	fm.SetQuery(Query{Families: []string{"Amiri"}})
+	tu.Assert(t, resolve(0x0628) == "user:amiri.ttf")
+
+	// invalid configs are rejected
+	tu.Assert(t, fm.SetFallbackConfig(FallbackConfig{ExcludeFiles: []string{"["}}) != nil)
+	tu.Assert(t, fm.SetFallbackConfig(FallbackConfig{PreferredFamilies: map[string][]string{"A": nil}}) != nil)
+	tu.Assert(t, fm.fallback.excludeFamilies["amiri"]) // not modified
+
+	// persistence
+	dir := t.TempDir()
+	_, err = LoadFallbackConfig(dir)
+	tu.Assert(t, errors.Is(err, fs.ErrNotExist))
+	config := FallbackConfig{
+		ExcludeFiles:      []string{"*.otf"},
+		AllowFamilies:     []string{"DejaVu Sans"},
+		PreferredFamilies: map[string][]string{"Arab": {"Amiri"}},
+	}
+	tu.AssertNoErr(t, SaveFallbackConfig(dir, config))
+	loaded, err := LoadFallbackConfig(dir)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, reflect.DeepEqual(loaded, config))
+
+	fm2 := NewFontMap(log.New(io.Discard, "", 0))
+	fm2.loadSavedFallbackConfig(dir)
+	tu.Assert(t, fm2.fallback != nil && fm2.fallback.allowFamilies["dejavusans"])
+}
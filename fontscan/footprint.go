@@ -9,6 +9,7 @@ import (
 	"github.com/boxesandglue/typesetting/font"
 	ot "github.com/boxesandglue/typesetting/font/opentype"
 	"github.com/boxesandglue/typesetting/font/opentype/tables"
+	"github.com/boxesandglue/typesetting/language"
 )
 
 // Location identifies where a font.Face is stored.
@@ -36,10 +37,35 @@ type Footprint struct {
 	// Langs is the set of languages deduced from [Runes]
 	Langs LangSet
 
+	// DesignLangs are the languages (as BCP 47 ScriptLangTags, like "ja" or "Hant") the
+	// font is designed for, as declared in the 'dlng' entry of its 'meta' table.
+	DesignLangs []language.Language
+
+	// SupportedLangs are the languages (as BCP 47 ScriptLangTags) the font
+	// declares to support, using the 'slng' entry of its 'meta' table,
+	// completed by the CJK code pages of its OS/2 table.
+	SupportedLangs []language.Language
+
 	// Aspect precises the visual characteristics
 	// of the font among a family, like "Bold Italic"
 	Aspect font.Aspect
 
+	// PostScriptName is the PostScript name of the font, used
+	// to detect the copies of a font (see [FindDuplicates]).
+	PostScriptName string
+
+	// Version is the font revision, as stored in the 'head' table.
+	Version float32
+
+	// HasColorGlyphs is true for fonts providing color glyphs,
+	// typically emoji fonts (see [font.Font.HasColorGlyphs]).
+	HasColorGlyphs bool
+
+	// Variations is the range of aspects supported by a variable font,
+	// used to select the instance closest to the query.
+	// It is empty for static fonts.
+	Variations AspectRange
+
 	// isUserProvided is set to true for fonts add manually to
 	// a FontMap
 	// User fonts will always be tried if no other fonts match,
@@ -55,7 +81,10 @@ func newFootprintFromFont(f *font.Font, location Location, md font.Description)
 	out.Langs = newLangsetFromCoverage(out.Runes)
 	out.Family = font.NormalizeFamily(md.Family)
 	out.Aspect = md.Aspect
+	out.PostScriptName = md.PostScriptName
+	out.Version = md.Version
 	out.Location = location
+	out.HasColorGlyphs = f.HasColorGlyphs()
 	out.isUserProvided = true
 	return out
 }
@@ -67,8 +96,11 @@ func newFootprintFromLoader(ld *ot.Loader, isUserProvided bool, buffer scanBuffe
 
 	raw, _ = ld.RawTableTo(ot.MustNewTag("OS/2"), raw)
 	fp := tables.FPNone
+	var codePages uint64
 	if os2, _, err := tables.ParseOs2(raw); err != nil {
 		fp = os2.FontPage()
+	} else {
+		codePages, _ = os2.CodePageRanges()
 	}
 
 	// we can use the buffer since ProcessCmap do not keep any reference on
@@ -93,13 +125,158 @@ func newFootprintFromLoader(ld *ot.Loader, isUserProvided bool, buffer scanBuffe
 	desc, raw := font.Describe(ld, raw)
 	out.Family = font.NormalizeFamily(desc.Family)
 	out.Aspect = desc.Aspect
+	out.PostScriptName = desc.PostScriptName
+	out.Version = desc.Version
 	out.isUserProvided = isUserProvided
+	out.HasColorGlyphs = hasColorTables(ld)
+
+	raw, _ = ld.RawTableTo(ot.MustNewTag("fvar"), raw)
+	if fvar, _, err := tables.ParseFvar(raw); err == nil { // the table is optional
+		out.Variations = newAspectRange(fvar.Axis)
+	}
+
+	raw, _ = ld.RawTableTo(ot.MustNewTag("meta"), raw)
+	meta, _, _ := tables.ParseMeta(raw) // the table is optional
+	out.DesignLangs, out.SupportedLangs = declaredLangs(meta, codePages)
 
 	buffer.tableBuffer = raw
 
 	return out, buffer, nil
 }
 
+// AspectRange stores the range of the aspect properties
+// which may be selected with the axes of a variable font.
+type AspectRange struct {
+	// Weight is the range of the 'wght' axis, or zero.
+	Weight [2]font.Weight
+	// Stretch is the range of the 'wdth' axis, expressed as a ratio, or zero.
+	Stretch [2]font.Stretch
+	// Italic is true if the font has an 'ital' axis.
+	Italic bool
+	// Slant is the value of the 'slnt' axis used for the italic style
+	// (the oblique angle of 14 degrees of CSS, clamped to the axis range),
+	// or zero. It is ignored when Italic is true.
+	Slant float32
+}
+
+// newAspectRange returns the range supported by the standard axes
+func newAspectRange(axes []tables.VariationAxisRecord) (out AspectRange) {
+	for _, axis := range axes {
+		switch axis.Tag {
+		case ot.MustNewTag("wght"):
+			out.Weight = [2]font.Weight{font.Weight(axis.Minimum), font.Weight(axis.Maximum)}
+		case ot.MustNewTag("wdth"):
+			out.Stretch = [2]font.Stretch{font.Stretch(axis.Minimum / 100), font.Stretch(axis.Maximum / 100)}
+		case ot.MustNewTag("ital"):
+			out.Italic = axis.Maximum >= 1
+		case ot.MustNewTag("slnt"):
+			// negative values are clockwise
+			out.Slant = max32(-14, axis.Minimum)
+		}
+	}
+	return out
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// hasItalic returns true if the style may be changed
+func (ar AspectRange) hasItalic() bool { return ar.Italic || ar.Slant < 0 }
+
+// isEmpty returns true for static fonts, or when no standard axis is supported.
+func (ar AspectRange) isEmpty() bool {
+	return ar.Weight == [2]font.Weight{} && ar.Stretch == [2]font.Stretch{} && !ar.hasItalic()
+}
+
+// closestWeight returns the weight closest to [query] supported by the font
+func (fp *Footprint) closestWeight(query font.Weight) font.Weight {
+	if r := fp.Variations.Weight; r != [2]font.Weight{} {
+		return clampWeight(query, r[0], r[1])
+	}
+	return fp.Aspect.Weight
+}
+
+// closestStretch returns the stretch closest to [query] supported by the font
+func (fp *Footprint) closestStretch(query font.Stretch) font.Stretch {
+	if r := fp.Variations.Stretch; r != [2]font.Stretch{} {
+		return clampStretch(query, r[0], r[1])
+	}
+	return fp.Aspect.Stretch
+}
+
+// closestStyle returns the style closest to [query] supported by the font
+func (fp *Footprint) closestStyle(query font.Style) font.Style {
+	if fp.Variations.hasItalic() {
+		return query
+	}
+	return fp.Aspect.Style
+}
+
+// closestAspect returns the aspect of the instance of the font closest to [query],
+// which is [fp.Aspect] for static fonts
+func (fp *Footprint) closestAspect(query font.Aspect) font.Aspect {
+	return font.Aspect{
+		Style:   fp.closestStyle(query.Style),
+		Weight:  fp.closestWeight(query.Weight),
+		Stretch: fp.closestStretch(query.Stretch),
+	}
+}
+
+func clampWeight(v, min, max font.Weight) font.Weight {
+	if v < min {
+		return min
+	} else if v > max {
+		return max
+	}
+	return v
+}
+
+func clampStretch(v, min, max font.Stretch) font.Stretch {
+	if v < min {
+		return min
+	} else if v > max {
+		return max
+	}
+	return v
+}
+
+// variations returns the axes values selecting [aspect]
+func (ar AspectRange) variations(aspect font.Aspect) []font.Variation {
+	var out []font.Variation
+	if ar.Weight != [2]font.Weight{} {
+		out = append(out, font.Variation{Tag: ot.MustNewTag("wght"), Value: float32(aspect.Weight)})
+	}
+	if ar.Stretch != [2]font.Stretch{} {
+		out = append(out, font.Variation{Tag: ot.MustNewTag("wdth"), Value: float32(aspect.Stretch) * 100})
+	}
+	if ar.hasItalic() {
+		var value float32
+		if aspect.Style == font.StyleItalic {
+			value = 1
+			if !ar.Italic {
+				value = ar.Slant
+			}
+		}
+		tag := ot.MustNewTag("ital")
+		if !ar.Italic {
+			tag = ot.MustNewTag("slnt")
+		}
+		out = append(out, font.Variation{Tag: tag, Value: value})
+	}
+	return out
+}
+
+// hasColorTables is a cheap approximation of [font.Font.HasColorGlyphs],
+// which only checks for the presence of the color tables
+func hasColorTables(ld *ot.Loader) bool {
+	return ld.HasTable(ot.MustNewTag("COLR")) || ld.HasTable(ot.MustNewTag("CBDT")) ||
+		ld.HasTable(ot.MustNewTag("sbix")) || ld.HasTable(ot.MustNewTag("SVG "))
+}
+
 // returns true for .ttf and .ttc font files
 func (fp *Footprint) isTruetypeHint() bool {
 	switch strings.ToLower(filepath.Ext(fp.Location.File)) {
@@ -0,0 +1,136 @@
+package fontscan
+
+import (
+	"strings"
+
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
+	"github.com/boxesandglue/typesetting/language"
+)
+
+// this file implements the language preference based on the
+// languages declared by the fonts (as opposed to the languages
+// deduced from the rune coverage), which is required to select the
+// correct font for unified Han characters.
+
+var (
+	tagDlng = ot.MustNewTag("dlng")
+	tagSlng = ot.MustNewTag("slng")
+)
+
+// OS/2 code page bits and the corresponding ScriptLangTags
+var cjkCodePages = [...]struct {
+	bit uint
+	tag language.Language
+}{
+	{17, "jpan"}, // JIS/Japan
+	{18, "hans"}, // Chinese: Simplified chars--PRC and Singapore
+	{19, "kore"}, // Korean Wansung
+	{20, "hant"}, // Chinese: Traditional chars--Taiwan and Hong Kong
+	{21, "kore"}, // Korean Johab
+}
+
+// declaredLangs returns the design and supported languages found in the 'meta' table,
+// completing the supported languages with the [codePages] from the OS/2 table.
+func declaredLangs(meta tables.Meta, codePages uint64) (design, supported []language.Language) {
+	design = meta.Languages(tagDlng)
+	supported = meta.Languages(tagSlng)
+	for _, cp := range cjkCodePages {
+		if codePages&(1<<cp.bit) == 0 {
+			continue
+		}
+		if !containsLang(supported, cp.tag) {
+			supported = append(supported, cp.tag)
+		}
+	}
+	return design, supported
+}
+
+func containsLang(langs []language.Language, lang language.Language) bool {
+	for _, l := range langs {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// langTag is a simplified BCP 47 tag, storing
+// the primary language and the script (as lower case strings)
+type langTag struct {
+	primary string // empty for script only tags, like "jpan"
+	script  string // may be empty
+}
+
+// parseLangTag splits [lang] into its primary language and script subtags.
+// If [inferScript] is true and [lang] has no script subtag, the script is
+// deduced for the CJK languages, so that "zh-TW" is matched by fonts declaring "Hant".
+func parseLangTag(lang language.Language, inferScript bool) langTag {
+	var (
+		out    langTag
+		region string
+	)
+	for i, subtag := range strings.Split(string(lang), "-") {
+		switch {
+		case i == 0 && len(subtag) == 4:
+			out.script = subtag // ScriptLangTag with only a script
+		case i == 0:
+			out.primary = subtag
+		case len(subtag) == 4 && out.script == "":
+			out.script = subtag
+		case len(subtag) == 2 || len(subtag) == 3:
+			if region == "" {
+				region = subtag
+			}
+		}
+	}
+	if !inferScript || out.script != "" {
+		return out
+	}
+	switch out.primary {
+	case "ja":
+		out.script = "jpan"
+	case "ko":
+		out.script = "kore"
+	case "zh":
+		switch region {
+		case "tw", "hk", "mo":
+			out.script = "hant"
+		default:
+			out.script = "hans"
+		}
+	}
+	return out
+}
+
+// matches returns true if the declared tag [decl] is compatible
+// with the requested [lang]
+func (decl langTag) matches(lang langTag) bool {
+	if decl.primary == "" && decl.script == "" {
+		return false
+	}
+	if decl.primary != "" && decl.primary != lang.primary {
+		return false
+	}
+	if decl.script != "" && decl.script != lang.script {
+		return false
+	}
+	return true
+}
+
+// declaresLang returns a preference level for [lang] :
+// 2 if one the design languages matches, 1 if one of the supported languages
+// matches, 0 otherwise.
+func (fp *Footprint) declaresLang(lang langTag) uint8 {
+	for _, l := range fp.DesignLangs {
+		if parseLangTag(l, false).matches(lang) {
+			return 2
+		}
+	}
+	for _, l := range fp.SupportedLangs {
+		if parseLangTag(l, false).matches(lang) {
+			return 1
+		}
+	}
+	return 0
+}
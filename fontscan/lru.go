@@ -18,6 +18,7 @@ type runeLRUEntry struct {
 type runeLRUKey struct {
 	familiesHash uint64
 	s            language.Script
+	lang         language.Language
 	aspect       font.Aspect
 	r            rune
 }
@@ -48,7 +49,7 @@ func (l *runeLRU) init() {
 	}
 }
 
-func (l *runeLRU) KeyFor(q Query, s language.Script, r rune) runeLRUKey {
+func (l *runeLRU) KeyFor(q Query, s language.Script, lang language.Language, r rune) runeLRUKey {
 	l.init()
 	var h maphash.Hash
 	h.SetSeed(l.seed)
@@ -58,6 +59,7 @@ func (l *runeLRU) KeyFor(q Query, s language.Script, r rune) runeLRUKey {
 	return runeLRUKey{
 		familiesHash: h.Sum64(),
 		s:            s,
+		lang:         lang,
 		aspect:       q.Aspect,
 		r:            r,
 	}
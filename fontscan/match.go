@@ -44,15 +44,15 @@ func (fc familyCrible) reset() {
 }
 
 // fillWithSubstitutions starts from `family`
-// and applies all the substitutions coded in the package
-// to add substitutes values
-func (fc familyCrible) fillWithSubstitutions(family string, lang LangID) {
-	fc.fillWithSubstitutionsList([]string{family}, lang)
+// and applies all the substitutions [rules] (typically [familySubstitution],
+// the ones coded in the package) to add substitutes values
+func (fc familyCrible) fillWithSubstitutions(family string, lang LangID, rules []substitution) {
+	fc.fillWithSubstitutionsList([]string{family}, lang, rules)
 }
 
-func (fc familyCrible) fillWithSubstitutionsList(families []string, lang LangID) {
+func (fc familyCrible) fillWithSubstitutionsList(families []string, lang LangID, rules []substitution) {
 	fl := newFamilyList(families)
-	for _, subs := range familySubstitution {
+	for _, subs := range rules {
 		fl.execute(subs, lang)
 	}
 
@@ -63,6 +63,7 @@ func (fc familyCrible) fillWithSubstitutionsList(families []string, lang LangID)
 type scoredFootprints struct {
 	footprints []int         // indices into [database]
 	scores     []scoreStrong // with same length as footprints
+	langLevels []uint8       // with same length as footprints, see [Footprint.declaresLang]
 
 	database fontSet
 	script   language.Script
@@ -72,6 +73,7 @@ type scoredFootprints struct {
 func (sf *scoredFootprints) reset(fs fontSet, script language.Script) {
 	sf.footprints = sf.footprints[:0]
 	sf.scores = sf.scores[:0]
+	sf.langLevels = sf.langLevels[:0]
 
 	sf.database = fs
 	sf.script = script
@@ -106,7 +108,8 @@ func less(scorei, scorej int, fpi, fpj *Footprint) bool {
 // Less compares footprints following these rules :
 //   - 'strong' replacements come before 'weak' ones
 //   - among 'strong' families, only the score matters
-//   - among 'weak' families, the footprints compatible with the given script come first
+//   - among 'weak' families, the footprints compatible with the given script come first,
+//     then the footprints declaring the given language (design languages first)
 //   - if two footprints have the same score (meaning they have the same family),
 //     user provided ones come first, then "regular" over "mono" then TTF before CFF.
 func (sf scoredFootprints) Less(i int, j int) bool {
@@ -129,6 +132,10 @@ func (sf scoredFootprints) Less(i int, j int) bool {
 	} else if !hasScripti && hasScriptj {
 		return false
 	}
+	// ... then by declared language ...
+	if leveli, levelj := sf.langLevels[i], sf.langLevels[j]; leveli != levelj {
+		return leveli > levelj
+	}
 	// ... then by score
 	return less(scorei.score, scorej.score, fpi, fpj)
 }
@@ -137,6 +144,7 @@ func (sf scoredFootprints) Less(i int, j int) bool {
 func (sf scoredFootprints) Swap(i int, j int) {
 	sf.footprints[i], sf.footprints[j] = sf.footprints[j], sf.footprints[i]
 	sf.scores[i], sf.scores[j] = sf.scores[j], sf.scores[i]
+	sf.langLevels[i], sf.langLevels[j] = sf.langLevels[j], sf.langLevels[i]
 }
 
 // Generic families as defined by
@@ -171,7 +179,7 @@ func isGenericFamily(family string) bool {
 // The returned slice may be empty if no font matches the given `family`.
 //
 // The buffers are used to reduce allocations and the returned slice is owned by them.
-func (fm fontSet) selectByFamilyExact(family string, cribleBuffer familyCrible, footprintsBuffer *scoredFootprints,
+func (fm fontSet) selectByFamilyExact(family string, rules []substitution, cribleBuffer familyCrible, footprintsBuffer *scoredFootprints,
 ) []int {
 	if isGenericFamily(family) {
 		// See the CSS spec (https://www.w3.org/TR/css-fonts-4/#font-style-matching) :
@@ -188,9 +196,9 @@ func (fm fontSet) selectByFamilyExact(family string, cribleBuffer familyCrible,
 		//	- restrict the result to the first (best) family
 
 		cribleBuffer.reset()
-		cribleBuffer.fillWithSubstitutions(family, 0)
+		cribleBuffer.fillWithSubstitutions(family, 0, rules)
 
-		footprints := fm.selectByFamiliesAndScript(cribleBuffer, 0, footprintsBuffer)
+		footprints := fm.selectByFamiliesAndScript(cribleBuffer, 0, "", footprintsBuffer)
 
 		// restrict to one 'concrete' family name
 		if len(footprints) == 0 {
@@ -210,30 +218,42 @@ func (fm fontSet) selectByFamilyExact(family string, cribleBuffer familyCrible,
 	// regular family : perform a simple match against the exact family name, without substitutions
 	// nor script matching
 	cribleBuffer = familyCrible{font.NormalizeFamily(family): scoreStrong{0, true}}
-	return fm.selectByFamiliesAndScript(cribleBuffer, 0, footprintsBuffer)
+	return fm.selectByFamiliesAndScript(cribleBuffer, 0, "", footprintsBuffer)
 }
 
 // selectByFamilyExact returns all the fonts in the fontmap matching
 // the given query, with the best matches coming first.
 //
-// `queryFamilies` is expanded with family substitutions
-func (fm fontSet) selectByFamilyWithSubs(queryFamilies []string, queryScript language.Script,
+// `queryFamilies` is expanded with the family substitutions [rules].
+// If not empty, [queryLang] is used to prefer the fonts declaring this language.
+func (fm fontSet) selectByFamilyWithSubs(queryFamilies []string, queryScript language.Script, queryLang language.Language, rules []substitution,
 	cribleBuffer familyCrible, footprintsBuffer *scoredFootprints,
 ) []int {
 	// if not found, the zero value is fine (language based substitutions will be disabled)
-	queryLang := language.ScriptToLang[queryScript]
+	langID := language.ScriptToLang[queryScript]
+	if queryLang != "" {
+		// prefer the requested language, if compatible with the script
+		if id, ok := language.NewLangID(queryLang); ok && id != 0 && id.UseScript(queryScript) {
+			langID = id
+		}
+	}
 
 	// build the crible, handling substitutions
 	cribleBuffer.reset()
-	cribleBuffer.fillWithSubstitutionsList(queryFamilies, queryLang)
-	return fm.selectByFamiliesAndScript(cribleBuffer, queryScript, footprintsBuffer)
+	cribleBuffer.fillWithSubstitutionsList(queryFamilies, langID, rules)
+	return fm.selectByFamiliesAndScript(cribleBuffer, queryScript, queryLang, footprintsBuffer)
 }
 
 // select the fonts in the fontSet matching [crible], returning their (sorted) indices.
 // [footprintsBuffer] is used to reduce allocations.
 // If [script] is 0, no font with matching script is added
-func (fm fontSet) selectByFamiliesAndScript(crible familyCrible, script language.Script, footprintsBuffer *scoredFootprints) []int {
+// If [lang] is empty, the languages declared by the fonts are ignored.
+func (fm fontSet) selectByFamiliesAndScript(crible familyCrible, script language.Script, lang language.Language, footprintsBuffer *scoredFootprints) []int {
 	footprintsBuffer.reset(fm, script)
+	var queryTag langTag
+	if lang != "" {
+		queryTag = parseLangTag(lang, true)
+	}
 
 	// loop through the font set and stores the matching fonts into
 	// the footprintsBuffer, to be sorted.
@@ -246,7 +266,14 @@ func (fm fontSet) selectByFamiliesAndScript(crible familyCrible, script language
 			// match by script: add with a score worse than any family match
 			footprintsBuffer.footprints = append(footprintsBuffer.footprints, index)
 			footprintsBuffer.scores = append(footprintsBuffer.scores, scoreStrong{math.MaxInt, false})
+		} else {
+			continue
+		}
+		var level uint8
+		if lang != "" {
+			level = footprint.declaresLang(queryTag)
 		}
+		footprintsBuffer.langLevels = append(footprintsBuffer.langLevels, level)
 	}
 
 	// sort the matched fonts (see [scoredFootprints.Less])
@@ -258,12 +285,13 @@ func (fm fontSet) selectByFamiliesAndScript(crible familyCrible, script language
 // matchStretch look for the given stretch in the font set,
 // or, if not found, the closest stretch
 // if always return a valid value (contained in `candidates`) if `candidates` is not empty
+// For variable fonts, the query is clamped to the range of the 'wdth' axis.
 func (fs fontSet) matchStretch(candidates []int, query font.Stretch) font.Stretch {
 	// narrower and wider than the query
 	var narrower, wider font.Stretch
 
 	for _, index := range candidates {
-		stretch := fs[index].Aspect.Stretch
+		stretch := fs[index].closestStretch(query)
 		if stretch > query { // wider candidate
 			if wider == 0 || stretch-query < wider-query { // closer
 				wider = stretch
@@ -303,7 +331,7 @@ func (fs fontSet) matchStyle(candidates []int, query font.Style) font.Style {
 	var crible [font.StyleItalic + 1]bool
 
 	for _, index := range candidates {
-		crible[fs[index].Aspect.Style] = true
+		crible[fs[index].closestStyle(query)] = true
 	}
 
 	switch query {
@@ -335,7 +363,7 @@ func (fs fontSet) matchStyle(candidates []int, query font.Style) font.Style {
 func (fs fontSet) matchWeight(candidates []int, query font.Weight) font.Weight {
 	var fatter, thinner font.Weight // approximate match
 	for _, index := range candidates {
-		weight := fs[index].Aspect.Weight
+		weight := fs[index].closestWeight(query)
 		if weight > query { // fatter candidate
 			if fatter == 0 || weight-query < fatter-query { // weight is closer to query
 				fatter = weight
@@ -372,10 +400,10 @@ func (fs fontSet) matchWeight(candidates []int, query font.Weight) font.Weight {
 }
 
 // filter `candidates` in place and returns the updated slice
-func (fs fontSet) filterByStretch(candidates []int, stretch font.Stretch) []int {
+func (fs fontSet) filterByStretch(candidates []int, query, stretch font.Stretch) []int {
 	n := 0
 	for _, index := range candidates {
-		if fs[index].Aspect.Stretch == stretch {
+		if fs[index].closestStretch(query) == stretch {
 			candidates[n] = index
 			n++
 		}
@@ -385,10 +413,10 @@ func (fs fontSet) filterByStretch(candidates []int, stretch font.Stretch) []int
 }
 
 // filter `candidates` in place and returns the updated slice
-func (fs fontSet) filterByStyle(candidates []int, style font.Style) []int {
+func (fs fontSet) filterByStyle(candidates []int, query, style font.Style) []int {
 	n := 0
 	for _, index := range candidates {
-		if fs[index].Aspect.Style == style {
+		if fs[index].closestStyle(query) == style {
 			candidates[n] = index
 			n++
 		}
@@ -398,10 +426,10 @@ func (fs fontSet) filterByStyle(candidates []int, style font.Style) []int {
 }
 
 // filter `candidates` in place and returns the updated slice
-func (fs fontSet) filterByWeight(candidates []int, weight font.Weight) []int {
+func (fs fontSet) filterByWeight(candidates []int, query, weight font.Weight) []int {
 	n := 0
 	for _, index := range candidates {
-		if fs[index].Aspect.Weight == weight {
+		if fs[index].closestWeight(query) == weight {
 			candidates[n] = index
 			n++
 		}
@@ -413,23 +441,26 @@ func (fs fontSet) filterByWeight(candidates []int, weight font.Weight) []int {
 // retainsBestMatches narrows `candidates` to the closest footprints to `query`, according to the CSS font rules
 // `candidates` is a slice of indexes into `fs`, which is mutated and returned
 // if `candidates` is not empty, the returned slice is guaranteed not to be empty
+//
+// Variable fonts are considered through their closest instance,
+// obtained by clamping the query to the ranges of their axes (see [Footprint.Variations]).
 func (fs fontSet) retainsBestMatches(candidates []int, query font.Aspect) []int {
-	// this follows CSS Fonts Level 3 § 5.2 [1].
-	// https://drafts.csswg.org/css-fonts-3/#font-style-matching
+	// this follows CSS Fonts Level 4 § 5.2 [1].
+	// https://drafts.csswg.org/css-fonts-4/#font-style-matching
 
 	query.SetDefaults()
 
 	// First step: font-stretch
 	matchingStretch := fs.matchStretch(candidates, query.Stretch)
-	candidates = fs.filterByStretch(candidates, matchingStretch) // only retain matching stretch
+	candidates = fs.filterByStretch(candidates, query.Stretch, matchingStretch) // only retain matching stretch
 
 	// Second step : font-style
 	matchingStyle := fs.matchStyle(candidates, query.Style)
-	candidates = fs.filterByStyle(candidates, matchingStyle)
+	candidates = fs.filterByStyle(candidates, query.Style, matchingStyle)
 
 	// Third step : font-weight
 	matchingWeight := fs.matchWeight(candidates, query.Weight)
-	candidates = fs.filterByWeight(candidates, matchingWeight)
+	candidates = fs.filterByWeight(candidates, query.Weight, matchingWeight)
 
 	return candidates
 }
@@ -61,7 +61,7 @@ func TestFontSet_selectByFamilyExact(t *testing.T) {
 		},
 	}
 	for _, tt := range tests {
-		if got := tt.fontset.selectByFamilyExact(tt.family, make(familyCrible), &scoredFootprints{}); !reflect.DeepEqual(got, tt.want) {
+		if got := tt.fontset.selectByFamilyExact(tt.family, familySubstitution, make(familyCrible), &scoredFootprints{}); !reflect.DeepEqual(got, tt.want) {
 			t.Errorf("fontSet.selectByFamilyExact(%s) = \n%v, want \n%v", tt.family, got, tt.want)
 		}
 	}
@@ -114,7 +114,7 @@ func TestFontSet_selectByFamilyWithSubs(t *testing.T) {
 		},
 	}
 	for _, tt := range tests {
-		got := tt.fontset.selectByFamilyWithSubs([]string{tt.family}, tt.script, make(familyCrible), &scoredFootprints{})
+		got := tt.fontset.selectByFamilyWithSubs([]string{tt.family}, tt.script, "", familySubstitution, make(familyCrible), &scoredFootprints{})
 		if !reflect.DeepEqual(got, tt.want) {
 			t.Errorf("fontSet.selectByFamilyWithSubs() = \n%v, want \n%v", got, tt.want)
 		}
@@ -248,3 +248,45 @@ func TestFontSet_retainsBestMatches(t *testing.T) {
 		})
 	}
 }
+
+func TestFontSet_retainsBestMatchesVariable(t *testing.T) {
+	regular := Footprint{Aspect: font.Aspect{Style: font.StyleNormal, Weight: font.WeightNormal, Stretch: font.StretchNormal}}
+	variable := Footprint{
+		Aspect:     font.Aspect{Style: font.StyleNormal, Weight: 100, Stretch: font.StretchNormal},
+		Variations: AspectRange{Weight: [2]font.Weight{100, 800}, Slant: -12},
+	}
+	fs := fontSet{regular, variable}
+
+	for _, test := range []struct {
+		query    font.Aspect
+		expected []int
+		aspect   font.Aspect // for the variable font
+	}{
+		{font.Aspect{}, []int{0, 1}, font.Aspect{Style: font.StyleNormal, Weight: 400, Stretch: 1}},
+		{font.Aspect{Weight: 700}, []int{1}, font.Aspect{Style: font.StyleNormal, Weight: 700, Stretch: 1}},
+		{font.Aspect{Weight: 900}, []int{1}, font.Aspect{Style: font.StyleNormal, Weight: 800, Stretch: 1}}, // clamped
+		{font.Aspect{Style: font.StyleItalic}, []int{1}, font.Aspect{Style: font.StyleItalic, Weight: 400, Stretch: 1}},
+		{font.Aspect{Stretch: font.StretchCondensed}, []int{0, 1}, font.Aspect{Style: font.StyleNormal, Weight: 400, Stretch: 1}},
+	} {
+		got := fs.retainsBestMatches(allIndices(fs), test.query)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("for %v, expected %v, got %v", test.query, test.expected, got)
+		}
+		query := test.query
+		query.SetDefaults()
+		if aspect := variable.closestAspect(query); aspect != test.aspect {
+			t.Errorf("for %v, expected %v, got %v", test.query, test.aspect, aspect)
+		}
+	}
+
+	// the 400-500 rule applies to the clamped weight
+	thin := Footprint{Aspect: font.Aspect{Style: font.StyleNormal, Weight: 100, Stretch: font.StretchNormal}, Variations: AspectRange{Weight: [2]font.Weight{100, 420}}}
+	medium := Footprint{Aspect: font.Aspect{Style: font.StyleNormal, Weight: 480, Stretch: font.StretchNormal}}
+	fs = fontSet{thin, medium}
+	if got := fs.retainsBestMatches(allIndices(fs), font.Aspect{Weight: 450}); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("expected the medium font, got %v", got)
+	}
+	if got := fs.retainsBestMatches(allIndices(fs), font.Aspect{Weight: 300}); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("expected the variable font, got %v", got)
+	}
+}
@@ -276,44 +276,104 @@ func (a RuneSet) Len() int {
 	return count
 }
 
-const runePageSize = 2 + 8*4 // uint16 + 8 * uint32
+const (
+	pageSetSize    = 8 * 4 // 8 * uint32
+	runeRecordSize = 2 + 2 // uint16 + uint16
+	runeSetHeader  = 2 + 2 // number of pages + number of records
+)
+
+var fullPage = pageSet{0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF}
 
-// serialize serializes the rune coverage in binary format
+// serialize serializes the rune coverage in binary format.
+//
+// To keep the index small for fonts covering large blocks (typically CJK fonts),
+// the pages are compacted : consecutive full pages are stored as one record
+// (first page, count), whereas the other pages are stored as a record
+// (page, 0) followed by their content.
 func (rs RuneSet) serialize() []byte {
-	buffer := make([]byte, 2+runePageSize*len(rs))
+	buffer := make([]byte, runeSetHeader, runeSetHeader+(runeRecordSize+pageSetSize)*len(rs))
 	binary.BigEndian.PutUint16(buffer, uint16(len(rs)))
-	for i, page := range rs {
-		binary.BigEndian.PutUint16(buffer[2+runePageSize*i:], page.ref)
-		slice := buffer[2+runePageSize*i+2:]
-		for j, k := range page.set {
-			binary.BigEndian.PutUint32(slice[4*j:], k)
+	var (
+		records int
+		tmp     [runeRecordSize + pageSetSize]byte
+	)
+	for i := 0; i < len(rs); {
+		page := rs[i]
+		records++
+		if page.set != fullPage {
+			binary.BigEndian.PutUint16(tmp[:], page.ref)
+			binary.BigEndian.PutUint16(tmp[2:], 0)
+			for j, k := range page.set {
+				binary.BigEndian.PutUint32(tmp[runeRecordSize+4*j:], k)
+			}
+			buffer = append(buffer, tmp[:]...)
+			i++
+			continue
 		}
+		// merge the following full pages
+		j := i + 1
+		for j < len(rs) && rs[j].set == fullPage && rs[j].ref == rs[j-1].ref+1 {
+			j++
+		}
+		binary.BigEndian.PutUint16(tmp[:], page.ref)
+		binary.BigEndian.PutUint16(tmp[2:], uint16(j-i))
+		buffer = append(buffer, tmp[:runeRecordSize]...)
+		i = j
 	}
+	binary.BigEndian.PutUint16(buffer[2:], uint16(records))
 	return buffer
 }
 
 // deserializeFrom reads the binary format produced by serialize.
 // it returns the number of bytes read from `data`
 func (rs *RuneSet) deserializeFrom(data []byte) (int, error) {
-	if len(data) < 2 {
+	if len(data) < runeSetHeader {
 		return 0, errors.New("invalid rune set (EOF)")
 	}
 	L := int(binary.BigEndian.Uint16(data))
-	if len(data) < 2+runePageSize*L {
-		return 0, errors.New("invalid rune set size (EOF)")
+	records := int(binary.BigEndian.Uint16(data[2:]))
+	if records > L {
+		return 0, errors.New("invalid rune set size")
 	}
-	v := make(RuneSet, L)
-	for i := range v {
-		v[i].ref = binary.BigEndian.Uint16(data[2+runePageSize*i:])
-		slice := data[2+runePageSize*i+2:]
-		for j := range v[i].set {
-			v[i].set[j] = binary.BigEndian.Uint32(slice[4*j:])
+	v := make(RuneSet, 0, L)
+	n := runeSetHeader
+	for i := 0; i < records; i++ {
+		if len(data) < n+runeRecordSize {
+			return 0, errors.New("invalid rune set record (EOF)")
 		}
+		ref := binary.BigEndian.Uint16(data[n:])
+		count := int(binary.BigEndian.Uint16(data[n+2:]))
+		n += runeRecordSize
+		// pages must be sorted, without duplicates
+		if len(v) != 0 && ref <= v[len(v)-1].ref {
+			return 0, errors.New("invalid rune set: unsorted pages")
+		}
+		if count == 0 {
+			if len(data) < n+pageSetSize || len(v) == L {
+				return 0, errors.New("invalid rune set page (EOF)")
+			}
+			page := runePage{ref: ref}
+			for j := range page.set {
+				page.set[j] = binary.BigEndian.Uint32(data[n+4*j:])
+			}
+			v = append(v, page)
+			n += pageSetSize
+			continue
+		}
+		if count > L-len(v) || int(ref)+count-1 > 0xFFFF {
+			return 0, errors.New("invalid rune set: too many full pages")
+		}
+		for j := 0; j < count; j++ {
+			v = append(v, runePage{ref: ref + pageRef(j), set: fullPage})
+		}
+	}
+	if len(v) != L {
+		return 0, errors.New("invalid rune set: inconsistent number of pages")
 	}
 
 	*rs = v
 
-	return 2 + runePageSize*L, nil
+	return n, nil
 }
 
 // ScriptSet is a set of scripts, implemented as
@@ -2,6 +2,7 @@ package fontscan
 
 import (
 	"bytes"
+	"encoding/binary"
 	"math/rand"
 	"os"
 	"reflect"
@@ -771,3 +772,40 @@ var testScripts = [...]language.Script{
 	language.Yi,
 	language.Zanabazar_Square,
 }
+
+func TestBinaryFormatCompaction(t *testing.T) {
+	// a CJK like coverage : large blocks of full pages
+	var runes []rune
+	for r := rune(0x4E00); r <= 0x9FFF; r++ {
+		runes = append(runes, r)
+	}
+	for r := rune(0xAC00); r <= 0xD7A3; r++ {
+		runes = append(runes, r)
+	}
+	runes = append(runes, 'a', 'b', 0x1F600)
+	cov := newRuneSet(runes...)
+
+	b := cov.serialize()
+	// 3 partial pages (for 'a' and 'b', the end of Hangul and the emoji) and 2 runs of full pages
+	if exp := runeSetHeader + 3*(runeRecordSize+pageSetSize) + 2*runeRecordSize; len(b) != exp {
+		t.Fatalf("unexpected size %d, expected %d", len(b), exp)
+	}
+
+	var got RuneSet
+	n, err := got.deserializeFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(b) {
+		t.Fatalf("unexpected number of bytes read: %d", n)
+	}
+	if !reflect.DeepEqual(cov, got) {
+		t.Fatalf("expected %v, got %v", cov, got)
+	}
+
+	// invalid number of full pages
+	binary.BigEndian.PutUint16(b[runeSetHeader+2:], 0xFFFF)
+	if _, err := got.deserializeFrom(b); err == nil {
+		t.Fatal("exepcted error on invalid input")
+	}
+}
@@ -1,6 +1,7 @@
 package fontscan
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
 
 	ot "github.com/boxesandglue/typesetting/font/opentype"
 )
@@ -208,8 +210,8 @@ type footprintScanner struct {
 
 	dst systemFontsIndex // accumulated footprints
 
-	// used to reduce allocations
-	scanBuffer
+	// optional, called for each font file added to [dst]
+	onFile func(fileFootprints) error
 }
 
 type scanBuffer struct {
@@ -226,56 +228,155 @@ func newFootprintAccumulator(currentIndex systemFontsIndex) footprintScanner {
 	return out
 }
 
-func (fa *footprintScanner) consume(path string, info os.FileInfo) error {
-	modTime := newTimeStamp(info)
-
-	// try to avoid scanning the file
-	if indexedFile, has := fa.previousIndex[path]; has && indexedFile.modTime == modTime {
-		// we already have an up to date scan of the file:
-		// skip the scan and add the current footprints
-		fa.dst = append(fa.dst, indexedFile)
-		return nil
-	}
-
-	// do the actual scan
+// fontFile is a candidate font file, found when walking the font directories
+type fontFile struct {
+	path    string
+	modTime timeStamp
+}
 
-	file, err := os.Open(path)
+// scanFontFile opens and scans the given file.
+// [buffer] is used to reduce allocations, and returned to be reused
+func scanFontFile(file fontFile, buffer scanBuffer) (fileFootprints, scanBuffer, error) {
+	f, err := os.Open(file.path)
 	if err != nil {
-		return err
+		return fileFootprints{}, buffer, err
 	}
 
 	ff := fileFootprints{
-		path:    path,
-		modTime: modTime,
+		path:    file.path,
+		modTime: file.modTime,
 	}
 
 	// fetch the loaders for the given font file, or nil if is not
 	// an Opentype font.
-	loaders, _ := ot.NewLoaders(file)
+	loaders, _ := ot.NewLoaders(f)
 
 	for i, ld := range loaders {
 		var fp Footprint
-		fp, fa.scanBuffer, err = newFootprintFromLoader(ld, false, fa.scanBuffer)
+		fp, buffer, err = newFootprintFromLoader(ld, false, buffer)
 		// the font won't be usable, just ignore it
 		if err != nil {
 			continue
 		}
 
-		fp.Location.File = path
+		fp.Location.File = file.path
 		fp.Location.Index = uint16(i)
 		// TODO: for now, we do not handle variable fonts
 
 		ff.footprints = append(ff.footprints, fp)
 	}
 
-	// newFootprintFromLoader still uses file, do not close earlier
-	file.Close()
+	// newFootprintFromLoader still uses f, do not close earlier
+	f.Close()
 
 	// if the file is not a valid Opentype file,
 	// we store an empty list of footprints but still adds the entry to the index
 	// so that subsequent calls won't try to open it again
-	fa.dst = append(fa.dst, ff)
+	return ff, buffer, nil
+}
+
+// scanWorkers returns the number of goroutines used to scan
+// [count] files
+func scanWorkers(count int) int {
+	workers := runtime.GOMAXPROCS(0)
+	if count < workers {
+		workers = count
+	}
+	return workers
+}
 
+// scanResult is sent by the workers of [footprintScanner.consumeAll]
+type scanResult struct {
+	index int // in the list of files
+	ff    fileFootprints
+	err   error
+}
+
+// consumeAll adds the footprints of [files] to the index, in order.
+//
+// The files already present (and up to date) in the previous index are not scanned again;
+// the others are parsed concurrently by a bounded pool of goroutines. The results are
+// still added (and passed to [onFile]) in the order of [files], from the calling goroutine,
+// so that the index does not depend on the scheduling.
+func (fa *footprintScanner) consumeAll(ctx context.Context, files []fontFile) error {
+	results := make([]fileFootprints, len(files))
+	ready := make([]bool, len(files))
+
+	var toScan []int
+	for i, file := range files {
+		// try to avoid scanning the file
+		if indexedFile, has := fa.previousIndex[file.path]; has && indexedFile.modTime == file.modTime {
+			// we already have an up to date scan of the file:
+			// skip the scan and reuse the current footprints
+			results[i], ready[i] = indexedFile, true
+		} else {
+			toScan = append(toScan, i)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	// both channels are large enough so that sending never blocks
+	jobs := make(chan int, len(toScan))
+	for _, index := range toScan {
+		jobs <- index
+	}
+	close(jobs)
+	done := make(chan scanResult, len(toScan))
+
+	var wg sync.WaitGroup
+	workers := scanWorkers(len(toScan))
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			var buffer scanBuffer // one buffer per goroutine
+			for index := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				var res scanResult
+				res.index = index
+				res.ff, buffer, res.err = scanFontFile(files[index], buffer)
+				done <- res
+			}
+		}()
+	}
+	// make sure no goroutine outlives the call, stopping
+	// the pending scans before waiting for the workers
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	next := 0 // the next file to add
+	for {
+		for ; next < len(files) && ready[next]; next++ {
+			if err := fa.add(results[next]); err != nil {
+				return err
+			}
+		}
+		if next == len(files) {
+			return nil
+		}
+
+		select {
+		case res := <-done:
+			if res.err != nil {
+				return res.err
+			}
+			results[res.index], ready[res.index] = res.ff, true
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (fa *footprintScanner) add(ff fileFootprints) error {
+	fa.dst = append(fa.dst, ff)
+	if fa.onFile != nil {
+		return fa.onFile(ff)
+	}
 	return nil
 }
 
@@ -286,17 +387,38 @@ func (fa *footprintScanner) consume(path string, info os.FileInfo) error {
 // `currentIndex` may be passed to avoid scanning font files that are
 // already present in `currentIndex` and up to date, and directly duplicating
 // the footprint in `currentIndex`
+//
+// The font files are parsed concurrently, but the returned index
+// only depends on the content of the directories.
 func scanFontFootprints(logger Logger, currentIndex systemFontsIndex, dirs ...string) (systemFontsIndex, error) {
+	return scanFontFootprintsContext(context.Background(), logger, currentIndex, nil, dirs...)
+}
+
+// scanFontFootprintsContext is the same as [scanFontFootprints], but supports cancellation
+// and calls [onFile] (if not nil) as soon as a file has been processed, in index order.
+// An error returned by [onFile] stops the scan.
+func scanFontFootprintsContext(ctx context.Context, logger Logger, currentIndex systemFontsIndex,
+	onFile func(fileFootprints) error, dirs ...string,
+) (systemFontsIndex, error) {
 	// keep track of visited dirs to avoid double inclusions,
 	// for instance with symbolic links
 	visited := make(map[string]bool)
 
-	accu := newFootprintAccumulator(currentIndex)
+	var (
+		files []fontFile
+		err   error
+	)
 	for _, dir := range dirs {
-		err := accu.scanDirectory(logger, dir, visited)
+		files, err = listFontFiles(ctx, logger, dir, visited, files)
 		if err != nil {
 			return nil, err
 		}
 	}
+
+	accu := newFootprintAccumulator(currentIndex)
+	accu.onFile = onFile
+	if err = accu.consumeAll(ctx, files); err != nil {
+		return nil, err
+	}
 	return accu.dst, nil
 }
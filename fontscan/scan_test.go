@@ -1,6 +1,9 @@
 package fontscan
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -150,3 +153,248 @@ func TestScanIncrementalUpdate(t *testing.T) {
 		t.Fatalf("unexpected font set: %v", fontset)
 	}
 }
+
+func TestScanWOFF2(t *testing.T) {
+	dir := t.TempDir()
+	copyFile(t, filepath.Join("..", "font", "testdata", "FontAwesome.woff2"), filepath.Join(dir, "font.woff2"))
+
+	logger := log.New(io.Discard, "", 0)
+	fontset, err := scanFontFootprints(logger, nil, dir)
+	tu.AssertNoErr(t, err)
+	fps := fontset.flatten()
+	tu.Assert(t, len(fps) == 1)
+	tu.Assert(t, fps[0].Family == "fontawesome")
+	tu.Assert(t, fps[0].Runes.Len() != 0)
+
+	face, err := fps[0].loadFromDisk()
+	tu.AssertNoErr(t, err)
+	_, ok := face.NominalGlyph(0xF000)
+	tu.Assert(t, ok)
+}
+
+func TestStreamFontFootprints(t *testing.T) {
+	dir := t.TempDir()
+	copyFile(t, filepath.Join("..", "font", "testdata", "Amiri-Regular.ttf"), filepath.Join(dir, "font1.ttf"))
+	copyFile(t, filepath.Join("..", "font", "testdata", "Roboto-Regular.ttf"), filepath.Join(dir, "font2.ttf"))
+	cachePath := filepath.Join(t.TempDir(), "index.cache")
+
+	logger := log.New(io.Discard, "", 0)
+	stream := func(ctx context.Context) ([]Footprint, error) {
+		out := make(chan IndexBatch)
+		var err error
+		go func() {
+			err = streamFontFootprints(ctx, logger, cachePath, out, dir)
+			close(out)
+		}()
+		var fps []Footprint
+		for batch := range out {
+			fps = append(fps, batch.Footprints...)
+		}
+		return fps, err
+	}
+
+	fps, err := stream(context.Background())
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, len(fps) == 2)
+
+	// the index has been written back
+	index, err := deserializeIndexFile(cachePath)
+	tu.AssertNoErr(t, err)
+	tu.AssertNoErr(t, assertFontsetEquals(fps, index.flatten()))
+
+	fm := NewFontMap(logger)
+	fm.AddFootprints(fps...)
+	fm.SetQuery(Query{Families: []string{"Roboto"}})
+	face := fm.ResolveFace('a')
+	tu.Assert(t, face != nil)
+	family, _ := fm.FontMetadata(face.Font)
+	tu.Assert(t, family == "roboto")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = stream(ctx)
+	tu.Assert(t, err != nil)
+}
+
+func TestSendBatch(t *testing.T) {
+	errScan := errors.New("scan error")
+
+	// the error is not dropped if the receiver is not ready yet
+	out := make(chan IndexBatch)
+	go sendBatch(context.Background(), out, IndexBatch{Err: errScan})
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case batch := <-out:
+		tu.Assert(t, batch.Err == errScan)
+	case <-time.After(time.Second):
+		t.Fatal("error batch dropped")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// the error is sent even if the context is done ...
+	out = make(chan IndexBatch, 1)
+	tu.AssertNoErr(t, sendBatch(ctx, out, IndexBatch{Err: errScan}))
+	tu.Assert(t, (<-out).Err == errScan)
+	// ... but sending does not block when nobody listens
+	out = make(chan IndexBatch)
+	tu.Assert(t, sendBatch(ctx, out, IndexBatch{Err: errScan}) == context.Canceled)
+	tu.Assert(t, sendBatch(ctx, out, IndexBatch{}) == context.Canceled)
+}
+
+func TestWatchDirectories(t *testing.T) {
+	dir := t.TempDir()
+	copyFile(t, filepath.Join("..", "font", "testdata", "Amiri-Regular.ttf"), filepath.Join(dir, "font1.ttf"))
+
+	logger := log.New(io.Discard, "", 0)
+	index, err := scanFontFootprints(logger, nil, dir)
+	tu.AssertNoErr(t, err)
+
+	fm := NewFontMap(logger)
+	fm.AddFootprints(index.flatten()...)
+	fm.SetQuery(Query{Families: []string{"Amiri"}})
+	amiri := fm.ResolveFace('a')
+	tu.Assert(t, amiri != nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fm.watchDirectories(ctx, 5*time.Millisecond, index, []string{dir})
+
+	// wait for the watcher to publish an update
+	waitUpdate := func() {
+		for start := time.Now(); !fm.watcher.hasPending.Load(); {
+			if time.Since(start) > 5*time.Second {
+				t.Fatal("timeout waiting for update")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// install a new font (atomically, to avoid scanning a partial file)
+	tmp := filepath.Join(t.TempDir(), "font2.ttf")
+	copyFile(t, filepath.Join("..", "font", "testdata", "Roboto-Regular.ttf"), tmp)
+	tu.AssertNoErr(t, os.Rename(tmp, filepath.Join(dir, "font2.ttf")))
+	waitUpdate()
+	_, found := fm.FindSystemFont("Roboto")
+	tu.Assert(t, found)
+	tu.Assert(t, fm.ResolveFace('a') == amiri) // cached face is still valid
+
+	// remove a font
+	tu.AssertNoErr(t, os.Remove(filepath.Join(dir, "font1.ttf")))
+	waitUpdate()
+	_, found = fm.FindSystemFont("Amiri")
+	tu.Assert(t, !found)
+	_, isCached := fm.faceCache[Location{File: filepath.Join(dir, "font1.ttf")}]
+	tu.Assert(t, !isCached)
+	face := fm.ResolveFace('a')
+	family, _ := fm.FontMetadata(face.Font)
+	tu.Assert(t, family == "roboto")
+}
+
+func TestScanConcurrentOrder(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"Amiri-Regular.ttf", "Roboto-Regular.ttf", "FontAwesome.woff2", "Amiri-Regular.ttf", "Roboto-Regular.ttf"}
+	for i, name := range names {
+		copyFile(t, filepath.Join("..", "font", "testdata", name), filepath.Join(dir, fmt.Sprintf("font%d%s", i, filepath.Ext(name))))
+	}
+	// not a font file, still indexed
+	err := os.WriteFile(filepath.Join(dir, "invalid.ttf"), []byte("not a font"), os.ModePerm)
+	tu.AssertNoErr(t, err)
+
+	logger := log.New(io.Discard, "", 0)
+	var streamed []string
+	index, err := scanFontFootprintsContext(context.Background(), logger, nil, func(ff fileFootprints) error {
+		streamed = append(streamed, ff.path)
+		return nil
+	}, dir)
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, len(index) == len(names)+1)
+
+	// the index follows the walk order, whatever the scheduling
+	for i, ff := range index {
+		tu.Assert(t, streamed[i] == ff.path)
+		if i > 0 {
+			tu.Assert(t, index[i-1].path < ff.path)
+		}
+		if filepath.Base(ff.path) == "invalid.ttf" {
+			tu.Assert(t, len(ff.footprints) == 0)
+		} else {
+			tu.Assert(t, len(ff.footprints) == 1)
+		}
+	}
+
+	for range [5]int{} {
+		index2, err := scanFontFootprints(logger, nil, dir)
+		tu.AssertNoErr(t, err)
+		tu.AssertNoErr(t, assertFontsetEquals(index.flatten(), index2.flatten()))
+	}
+
+	// errors returned by the callback stop the scan
+	stop := fmt.Errorf("stop")
+	_, err = scanFontFootprintsContext(context.Background(), logger, nil, func(ff fileFootprints) error { return stop }, dir)
+	tu.Assert(t, err == stop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = scanFontFootprintsContext(ctx, logger, nil, nil, dir)
+	tu.Assert(t, err == context.Canceled)
+}
+
+// writeFontRevision copies the font [srcName] to [dstName], changing
+// its 'head' fontRevision to [revision] (in 16.16 format)
+func writeFontRevision(t *testing.T, srcName, dstName string, revision uint32) {
+	t.Helper()
+
+	data, err := os.ReadFile(srcName)
+	tu.AssertNoErr(t, err)
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		record := data[12+16*i:]
+		if string(record[:4]) == "head" {
+			offset := binary.BigEndian.Uint32(record[8:])
+			binary.BigEndian.PutUint32(data[offset+4:], revision)
+		}
+	}
+	tu.AssertNoErr(t, os.WriteFile(dstName, data, 0o644))
+}
+
+func TestDuplicates(t *testing.T) {
+	robotoFile := filepath.Join("..", "font", "testdata", "Roboto-Regular.ttf")
+	system, user := t.TempDir(), t.TempDir()
+	copyFile(t, robotoFile, filepath.Join(system, "roboto.ttf"))
+	copyFile(t, filepath.Join("..", "font", "testdata", "Amiri-Regular.ttf"), filepath.Join(system, "amiri.ttf"))
+	writeFontRevision(t, robotoFile, filepath.Join(user, "roboto.ttf"), 100<<16)
+
+	logger := log.New(io.Discard, "", 0)
+	index, err := scanFontFootprints(logger, nil, system, user)
+	tu.AssertNoErr(t, err)
+	fps := index.flatten()
+	tu.Assert(t, len(fps) == 3)
+
+	duplicates := FindDuplicates(fps)
+	tu.Assert(t, len(duplicates) == 1)
+	group := duplicates[0]
+	tu.Assert(t, group.PostScriptName == "Roboto-Regular")
+	tu.Assert(t, group.Kept.Version == 100 && group.Kept.Location.File == filepath.Join(user, "roboto.ttf"))
+	tu.Assert(t, len(group.Discarded) == 1 && group.Discarded[0].Version < 100)
+
+	deduplicated := removeDuplicates(fps)
+	tu.Assert(t, len(deduplicated) == 2)
+	tu.Assert(t, deduplicated[0].Family == "amiri" && deduplicated[1].Version == 100)
+
+	// the result does not depend on the order of the footprints
+	for _, order := range [][]Footprint{fps, {fps[2], fps[1], fps[0]}} {
+		fm := NewFontMap(logger)
+		for _, fp := range order {
+			fm.AddFootprints(fp)
+		}
+		tu.Assert(t, len(fm.database) == 2)
+		fm.SetQuery(Query{Families: []string{"Roboto"}})
+		face := fm.ResolveFace('a')
+		tu.Assert(t, face != nil)
+		tu.Assert(t, fm.FontLocation(face.Font).File == filepath.Join(user, "roboto.ttf"))
+
+		duplicates = fm.Duplicates()
+		tu.Assert(t, len(duplicates) == 1 && duplicates[0].Kept.Version == 100)
+	}
+}
@@ -1,15 +1,19 @@
 package fontscan
 
 import (
+	"context"
 	"io/fs"
 	"os"
 	"path/filepath"
 )
 
-// recursively walk through the given directory, scanning font files and calling dst.consume
-// for each valid file found.
-func (dst *footprintScanner) scanDirectory(logger Logger, dir string, visited map[string]bool) error {
+// recursively walk through the given directory, appending the font files
+// found to [files], in walk order.
+func listFontFiles(ctx context.Context, logger Logger, dir string, visited map[string]bool, files []fontFile) ([]fontFile, error) {
 	walkFn := func(path string, d fs.DirEntry, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if err != nil {
 			logger.Printf("error walking font directory %q: %v", path, err)
 			return filepath.SkipDir
@@ -35,14 +39,13 @@ func (dst *footprintScanner) scanDirectory(logger Logger, dir string, visited ma
 			return nil
 		}
 
-		err = dst.consume(path, info)
-
-		return err
+		files = append(files, fontFile{path: path, modTime: newTimeStamp(info)})
+		return nil
 	}
 
 	err := filepath.WalkDir(dir, walkFn)
 
-	return err
+	return files, err
 }
 
 type dirEntry = fs.DirEntry
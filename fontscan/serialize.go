@@ -6,12 +6,14 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"math"
 	"os"
 	"path/filepath"
 
 	"github.com/boxesandglue/typesetting/font"
+	"github.com/boxesandglue/typesetting/language"
 )
 
 // defines the routines to serialize a font set to
@@ -50,6 +52,13 @@ func deserializeString(s *string, data []byte) (int, error) {
 	return 2 + L, nil
 }
 
+func serializeBool(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 const aspectSize = 1 + 4 + 4
 
 // serializeTo serialize the Aspect in binary format
@@ -73,6 +82,34 @@ func deserializeAspectFrom(data []byte, as *font.Aspect) (int, error) {
 	return aspectSize, nil
 }
 
+const aspectRangeSize = 4*4 + 1 + 4
+
+func (ar AspectRange) serialize() []byte {
+	var buffer [aspectRangeSize]byte
+	serializeFloat(float32(ar.Weight[0]), buffer[0:])
+	serializeFloat(float32(ar.Weight[1]), buffer[4:])
+	serializeFloat(float32(ar.Stretch[0]), buffer[8:])
+	serializeFloat(float32(ar.Stretch[1]), buffer[12:])
+	buffer[16] = serializeBool(ar.Italic)
+	serializeFloat(ar.Slant, buffer[17:])
+	return buffer[:]
+}
+
+// deserializeFrom reads the binary format produced by serialize
+// it returns the number of bytes read from `data`
+func (ar *AspectRange) deserializeFrom(data []byte) (int, error) {
+	if len(data) < aspectRangeSize {
+		return 0, errors.New("invalid aspect range (EOF)")
+	}
+	ar.Weight[0] = font.Weight(deserializeFloat(data[0:]))
+	ar.Weight[1] = font.Weight(deserializeFloat(data[4:]))
+	ar.Stretch[0] = font.Stretch(deserializeFloat(data[8:]))
+	ar.Stretch[1] = font.Stretch(deserializeFloat(data[12:]))
+	ar.Italic = data[16] != 0
+	ar.Slant = deserializeFloat(data[17:])
+	return aspectRangeSize, nil
+}
+
 // serializeTo serialize the Footprint in binary format,
 // by appending to `dst` and returning the slice
 func (fp Footprint) serializeTo(dst []byte) []byte {
@@ -88,6 +125,14 @@ func (fp Footprint) serializeTo(dst []byte) []byte {
 	dst = append(dst, fp.Scripts.serialize()...)
 	dst = append(dst, fp.Langs.serialize()...)
 	dst = append(dst, serializeAspect(fp.Aspect)...)
+	dst = append(dst, serializeString(fp.PostScriptName)...)
+	var version [4]byte
+	serializeFloat(fp.Version, version[:])
+	dst = append(dst, version[:]...)
+	dst = append(dst, serializeBool(fp.HasColorGlyphs))
+	dst = serializeLangsTo(fp.DesignLangs, dst)
+	dst = serializeLangsTo(fp.SupportedLangs, dst)
+	dst = append(dst, fp.Variations.serialize()...)
 
 	return dst
 }
@@ -131,10 +176,77 @@ func (fp *Footprint) deserializeFrom(data []byte) (int, error) {
 		return 0, err
 	}
 	n += read
+	read, err = deserializeString(&fp.PostScriptName, data[n:])
+	if err != nil {
+		return 0, err
+	}
+	n += read
+	if len(data) < n+4 {
+		return 0, errors.New("invalid version (EOF)")
+	}
+	fp.Version = deserializeFloat(data[n:])
+	n += 4
+	if len(data) < n+1 {
+		return 0, errors.New("invalid color flag (EOF)")
+	}
+	fp.HasColorGlyphs = data[n] != 0
+	n++
+	read, err = deserializeLangsFrom(&fp.DesignLangs, data[n:])
+	if err != nil {
+		return 0, err
+	}
+	n += read
+	read, err = deserializeLangsFrom(&fp.SupportedLangs, data[n:])
+	if err != nil {
+		return 0, err
+	}
+	n += read
+	read, err = fp.Variations.deserializeFrom(data[n:])
+	if err != nil {
+		return 0, err
+	}
+	n += read
 
 	return n, nil
 }
 
+// serializeLangsTo appends the number of languages as uint16,
+// followed by the languages
+func serializeLangsTo(langs []language.Language, dst []byte) []byte {
+	var buffer [2]byte
+	binary.BigEndian.PutUint16(buffer[:], uint16(len(langs)))
+	dst = append(dst, buffer[:]...)
+	for _, lang := range langs {
+		dst = append(dst, serializeString(string(lang))...)
+	}
+	return dst
+}
+
+// deserializeLangsFrom reads the format written by serializeLangsTo,
+// returning the number of bytes read
+func deserializeLangsFrom(langs *[]language.Language, data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, errors.New("invalid languages (EOF)")
+	}
+	L := int(binary.BigEndian.Uint16(data))
+	n := 2
+	if L == 0 {
+		*langs = nil
+		return n, nil
+	}
+	*langs = make([]language.Language, L)
+	for i := range *langs {
+		var s string
+		read, err := deserializeString(&s, data[n:])
+		if err != nil {
+			return 0, err
+		}
+		(*langs)[i] = language.Language(s)
+		n += read
+	}
+	return n, nil
+}
+
 // serialize into binary format, appending to `dst` and returning
 // the updated slice
 func serializeFootprintsTo(footprints []Footprint, dst []byte) []byte {
@@ -185,7 +297,21 @@ func (ff *fileFootprints) deserializeFrom(src []byte) error {
 	return nil
 }
 
-const cacheFormatVersion = 6
+// The index is stored in a versioned container :
+//   - the magic number indexMagic
+//   - the format version, as uint16
+//   - the number of fileFootprints, as uint32
+//   - for each fileFootprints, its encoded size and its CRC-32 (IEEE) checksum,
+//     as uint32, followed by its encoding.
+//
+// cacheFormatVersion must be incremented when the encoding changes,
+// so that the outdated caches are discarded (and rebuilt) instead of being misread.
+const cacheFormatVersion = 11
+
+// indexMagic identifies an index file
+var indexMagic = [4]byte{'f', 's', 'c', 'x'}
+
+const indexHeaderSize = 4 + 2 + 4 // magic + version + count
 
 func max(i, j int) int {
 	if i > j {
@@ -196,21 +322,23 @@ func max(i, j int) int {
 
 // serialize into binary format, compressed with gzip
 func (index systemFontsIndex) serializeTo(w io.Writer) error {
-	// version as uint16 + len as uint32 + somewhat the minimum size for a footprint
-	buffer := make([]byte, 6, max(6, 4+len(index)*(aspectSize+1+2)))
-	binary.BigEndian.PutUint16(buffer[:], cacheFormatVersion)
-	binary.BigEndian.PutUint32(buffer[2:], uint32(len(index)))
+	// header + somewhat the minimum size for a footprint
+	buffer := make([]byte, indexHeaderSize, max(indexHeaderSize, indexHeaderSize+len(index)*(8+aspectSize+1+2)))
+	copy(buffer, indexMagic[:])
+	binary.BigEndian.PutUint16(buffer[4:], cacheFormatVersion)
+	binary.BigEndian.PutUint32(buffer[6:], uint32(len(index)))
 
 	for _, ff := range index {
-		// add buffer to store the length of the encoded fileFootprints,
-		// needed when decoding from a stream
+		// add space to store the length of the encoded fileFootprints,
+		// needed when decoding from a stream, and its checksum
 		n := len(buffer)
-		buffer = append(buffer, make([]byte, 4)...)
+		buffer = append(buffer, make([]byte, 8)...)
 
 		buffer = ff.serializeTo(buffer)
 
-		size := len(buffer) - n - 4
-		binary.BigEndian.PutUint32(buffer[n:], uint32(size))
+		data := buffer[n+8:]
+		binary.BigEndian.PutUint32(buffer[n:], uint32(len(data)))
+		binary.BigEndian.PutUint32(buffer[n+4:], crc32.ChecksumIEEE(data))
 	}
 	wr := gzip.NewWriter(w)
 	_, err := wr.Write(buffer)
@@ -233,32 +361,39 @@ func deserializeIndex(src io.Reader) (systemFontsIndex, error) {
 	defer r.Close()
 
 	var (
-		buf    [6]byte
+		buf    [indexHeaderSize]byte
 		out    systemFontsIndex
 		buffer bytes.Buffer
 	)
 
-	// read the expected length
+	// check the header and read the expected length
 	if _, err := io.ReadFull(r, buf[:]); err != nil {
 		return nil, fmt.Errorf("invalid index format: %s", err)
 	}
-	version := binary.BigEndian.Uint16(buf[:])
+	if !bytes.Equal(buf[:4], indexMagic[:]) {
+		return nil, errors.New("invalid index format: missing magic number")
+	}
+	version := binary.BigEndian.Uint16(buf[4:])
 	if version != cacheFormatVersion {
 		return nil, fmt.Errorf("different index version format: found %d", version)
 	}
-	L := binary.BigEndian.Uint32(buf[2:])
+	L := binary.BigEndian.Uint32(buf[6:])
 	for i := uint32(0); i < L; i++ {
-		// size of the encoded footprint
-		if _, err := io.ReadFull(r, buf[:4]); err != nil {
+		// size and checksum of the encoded footprint
+		if _, err := io.ReadFull(r, buf[:8]); err != nil {
 			return nil, fmt.Errorf("invalid index: %s", err)
 		}
 		size := binary.BigEndian.Uint32(buf[:4])
+		checksum := binary.BigEndian.Uint32(buf[4:8])
 		// buffer the fileFootprints segment
 		buffer.Reset()
 		_, err := io.CopyN(&buffer, r, int64(size))
 		if err != nil {
 			return nil, fmt.Errorf("invalid index: %s", err)
 		}
+		if crc32.ChecksumIEEE(buffer.Bytes()) != checksum {
+			return nil, fmt.Errorf("invalid index: checksum mismatch for entry %d", i)
+		}
 
 		var fp fileFootprints
 		err = fp.deserializeFrom(buffer.Bytes())
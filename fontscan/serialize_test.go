@@ -2,6 +2,7 @@ package fontscan
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -22,6 +23,10 @@ func Test_serializeFootprints(t *testing.T) {
 			Runes:   newRuneSet(1, 0, 2, 0x789, 0xfffee),
 			Scripts: ScriptSet{0, 1, 5, 0xffffff, language.Nabataean, language.Unknown},
 			Aspect:  font.Aspect{Style: 1, Weight: 200, Stretch: 0.45},
+
+			HasColorGlyphs: true,
+			DesignLangs:    []language.Language{"ja", "zh-hant"},
+			SupportedLangs: []language.Language{"jpan", "hani"},
 		},
 		{
 			Runes:   RuneSet{},
@@ -151,3 +156,64 @@ func TestSerializeSystemFonts(t *testing.T) {
 		t.Fatalf("inconsistent serialization %s", err)
 	}
 }
+
+func TestSerializeIndexContainer(t *testing.T) {
+	index := systemFontsIndex{
+		{
+			path:    "/fonts/a.ttf",
+			modTime: 12345,
+			footprints: []Footprint{{
+				Family:  "a",
+				Runes:   newRuneSet(1, 2, 0x4E00, 0x789),
+				Scripts: ScriptSet{language.Latin},
+			}},
+		},
+		{path: "/fonts/b.ttf"},
+	}
+
+	var b bytes.Buffer
+	if err := index.serializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	got, err := deserializeIndex(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = assertFontsetEquals(index.flatten(), got.flatten()); err != nil {
+		t.Fatal(err)
+	}
+
+	// uncompress, modify and compress again
+	r, err := gzip.NewReader(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := func(modify func([]byte)) error {
+		cp := append([]byte(nil), data...)
+		modify(cp)
+		var out bytes.Buffer
+		w := gzip.NewWriter(&out)
+		w.Write(cp)
+		w.Close()
+		_, err := deserializeIndex(&out)
+		return err
+	}
+
+	if err := corrupt(func([]byte) {}); err != nil {
+		t.Fatal(err)
+	}
+	for _, modify := range []func([]byte){
+		func(b []byte) { b[0] = 'x' },                                       // magic number
+		func(b []byte) { binary.BigEndian.PutUint16(b[4:], 1) },             // version
+		func(b []byte) { b[indexHeaderSize+8+len("/fonts/a.ttf")] ^= 0xFF }, // content
+		func(b []byte) { b[indexHeaderSize+4] ^= 0xFF },                     // checksum
+	} {
+		if err := corrupt(modify); err == nil {
+			t.Fatal("expected error on corrupted index")
+		}
+	}
+}
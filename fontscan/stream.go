@@ -0,0 +1,113 @@
+package fontscan
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// IndexBatch is a group of footprints sent by [StreamSystemFonts].
+type IndexBatch struct {
+	// Footprints are the fonts found in one font file.
+	Footprints []Footprint
+
+	// Err is not nil if the scan failed or has been cancelled.
+	// In this case, the batch is the last one sent and [Footprints] is empty.
+	Err error
+}
+
+// StreamSystemFonts is an incremental alternative to [SystemFonts] : it scans the system fonts
+// in a background goroutine and sends the footprints on the returned channel
+// as soon as they are available, one batch per font file.
+//
+// Files already present (and up to date) in the on-disk index stored in [cacheDir]
+// are not scanned again, so that their footprints are sent almost immediately.
+// Once every directory has been processed, the updated index is written back
+// and the channel is closed.
+//
+// Cancelling [ctx] stops the scan : an [IndexBatch] with a non nil Err is
+// then sent (unless the channel is full and no longer consumed) and the index file is not updated.
+//
+// The channel must be drained by the caller. Since [FontMap] is not safe for concurrent use,
+// the batches should be added with [FontMap.AddFootprints] from the goroutine owning the map,
+// for instance :
+//
+//	for batch := range StreamSystemFonts(ctx, nil, "") {
+//		if batch.Err != nil {
+//			// handle the error
+//		}
+//		fontMap.AddFootprints(batch.Footprints...)
+//	}
+//
+// If [logger] is nil, the warnings are written to the output of the standard logger,
+// with a "fontscan" prefix.
+func StreamSystemFonts(ctx context.Context, logger Logger, cacheDir string) <-chan IndexBatch {
+	if logger == nil {
+		logger = log.New(log.Writer(), "fontscan", log.Flags())
+	}
+	out := make(chan IndexBatch, 16)
+	go func() {
+		defer close(out)
+
+		cachePath, err := indexCachePath(cacheDir)
+		if err != nil {
+			sendBatch(ctx, out, IndexBatch{Err: err})
+			return
+		}
+		fontDirectories, err := DefaultFontDirectories(logger)
+		if err != nil {
+			sendBatch(ctx, out, IndexBatch{Err: fmt.Errorf("searching font directories: %s", err)})
+			return
+		}
+		logger.Printf("using system font dirs %q", fontDirectories)
+
+		err = streamFontFootprints(ctx, logger, cachePath, out, fontDirectories...)
+		if err != nil {
+			sendBatch(ctx, out, IndexBatch{Err: err})
+		}
+	}()
+	return out
+}
+
+// sendBatch blocks until [batch] is sent or [ctx] is done.
+// An error batch is still sent after [ctx] is done if the channel has room,
+// so that the caller is notified of the cancellation.
+func sendBatch(ctx context.Context, out chan<- IndexBatch, batch IndexBatch) error {
+	if batch.Err != nil {
+		select {
+		case out <- batch:
+			return nil
+		default:
+		}
+	}
+	select {
+	case out <- batch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// streamFontFootprints scans [dirs], starting from the index stored at [cachePath],
+// sends the non empty footprints lists to [out] and finally updates the index file.
+func streamFontFootprints(ctx context.Context, logger Logger, cachePath string, out chan<- IndexBatch, dirs ...string) error {
+	currentIndex, _ := deserializeIndexFile(cachePath)
+	// if an error occured (the cache file does not exists or is invalid), we start from scratch
+
+	onFile := func(ff fileFootprints) error {
+		if len(ff.footprints) == 0 {
+			return nil
+		}
+		return sendBatch(ctx, out, IndexBatch{Footprints: ff.footprints})
+	}
+	updatedIndex, err := scanFontFootprintsContext(ctx, logger, currentIndex, onFile, dirs...)
+	if err != nil {
+		return fmt.Errorf("scanning system fonts: %s", err)
+	}
+
+	// write back the index in the cache file
+	if err = updatedIndex.serializeToFile(cachePath); err != nil {
+		return fmt.Errorf("updating cache: %s", err)
+	}
+	return nil
+}
@@ -1,9 +1,11 @@
 package fontscan
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/boxesandglue/typesetting/font"
+	"github.com/boxesandglue/typesetting/language"
 )
 
 // this file implements the family substitution feature,
@@ -266,6 +268,182 @@ func (fl *familyList) execute(subs substitution, lang LangID) {
 	}
 }
 
+// ----- user defined rules -----
+
+// SubstitutionPosition specifies where the families added by a [SubstitutionRule] are inserted,
+// with respect to the family triggering the rule.
+// See the 'mode' attribute of the fontconfig <edit> element.
+type SubstitutionPosition uint8
+
+const (
+	// Insert the substitutes just after the matched family (fontconfig 'append').
+	PositionAppend SubstitutionPosition = iota
+	// Insert the substitutes at the end of the family list (fontconfig 'append_last').
+	PositionAppendLast
+	// Insert the substitutes just before the matched family (fontconfig 'prepend').
+	PositionPrepend
+	// Insert the substitutes at the start of the family list (fontconfig 'prepend_first').
+	PositionPrependFirst
+	// Replace the matched family by the substitutes (fontconfig 'assign').
+	PositionReplace
+)
+
+var positionNames = [...]string{"append", "append_last", "prepend", "prepend_first", "replace"}
+
+func (sp SubstitutionPosition) String() string {
+	if int(sp) < len(positionNames) {
+		return positionNames[sp]
+	}
+	return fmt.Sprintf("<unknown substitution position: %d>", sp)
+}
+
+// MarshalText implements encoding.TextMarshaler, using the fontconfig names.
+func (sp SubstitutionPosition) MarshalText() ([]byte, error) {
+	if int(sp) >= len(positionNames) {
+		return nil, fmt.Errorf("invalid substitution position %d", sp)
+	}
+	return []byte(positionNames[sp]), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting the fontconfig names
+// ("assign" is an alias for "replace").
+func (sp *SubstitutionPosition) UnmarshalText(text []byte) error {
+	name := string(text)
+	if name == "assign" {
+		name = "replace"
+	}
+	for i, n := range positionNames {
+		if n == name {
+			*sp = SubstitutionPosition(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid substitution position %q", text)
+}
+
+// Binding specifies how the families added by a [SubstitutionRule] compete with
+// the fonts supporting the queried script.
+// See the 'binding' attribute of the fontconfig <edit> element.
+type Binding uint8
+
+const (
+	// The substitutes have the same binding as the matched family.
+	BindingSame Binding = iota
+	// The substitutes are preferred over the fonts only matching the queried script.
+	BindingStrong
+	// The fonts supporting the queried script are preferred over the substitutes.
+	BindingWeak
+)
+
+var bindingNames = [...]string{"same", "strong", "weak"}
+
+func (b Binding) String() string {
+	if int(b) < len(bindingNames) {
+		return bindingNames[b]
+	}
+	return fmt.Sprintf("<unknown binding: %d>", b)
+}
+
+// MarshalText implements encoding.TextMarshaler, using the fontconfig names.
+func (b Binding) MarshalText() ([]byte, error) {
+	if int(b) >= len(bindingNames) {
+		return nil, fmt.Errorf("invalid binding %d", b)
+	}
+	return []byte(bindingNames[b]), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, using the fontconfig names.
+func (b *Binding) UnmarshalText(text []byte) error {
+	for i, n := range bindingNames {
+		if n == string(text) {
+			*b = Binding(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid binding %q", text)
+}
+
+// SubstitutionRule adds alternative families when a family is found in the
+// list of families being resolved.
+// For instance, the rule
+//
+//	SubstitutionRule{
+//		Family:      "Helvetica",
+//		Substitutes: []string{"Liberation Sans"},
+//		Position:    PositionPrepend,
+//		Binding:     BindingStrong,
+//	}
+//
+// makes "Liberation Sans" the preferred alias for "Helvetica".
+type SubstitutionRule struct {
+	// Family is the family triggering the rule. It is compared
+	// with the family list after normalization (see [font.NormalizeFamily]),
+	// and may be a generic family, like "sans-serif" or "monospace".
+	Family string `json:"family"`
+	// Lang, if not empty, restricts the rule to the queries for this language
+	// (which is deduced from the script of the text).
+	// It must be a language known by this package (see [language.NewLangID]).
+	Lang language.Language `json:"lang,omitempty"`
+	// Substitutes are the families to add, in order of preference.
+	Substitutes []string `json:"substitutes"`
+	// Position is the insertion point of the [Substitutes].
+	Position SubstitutionPosition `json:"position"`
+	// Binding is the importance of the [Substitutes].
+	Binding Binding `json:"binding"`
+}
+
+// SubstitutionConfig groups the family substitution rules of an application.
+// Its fields support JSON encoding, so that it may be loaded from a configuration file.
+type SubstitutionConfig struct {
+	// Rules are applied in order, before the built-in rules,
+	// which may thus expand the families they add.
+	Rules []SubstitutionRule `json:"rules"`
+	// IgnoreDefaults disables the built-in rules, which are
+	// derived from the default fontconfig configuration.
+	IgnoreDefaults bool `json:"ignoreDefaults,omitempty"`
+}
+
+// compile returns the substitutions to apply,
+// including the built-in ones if needed
+func (sc SubstitutionConfig) compile() ([]substitution, error) {
+	// not nil, even if empty, to disable the built-in rules
+	out := make([]substitution, 0, len(sc.Rules))
+	for i, rule := range sc.Rules {
+		if rule.Family == "" {
+			return nil, fmt.Errorf("invalid substitution rule %d: missing family", i)
+		}
+		if int(rule.Position) >= len(positionNames) {
+			return nil, fmt.Errorf("invalid substitution rule %d: invalid position %d", i, rule.Position)
+		}
+		if int(rule.Binding) >= len(bindingNames) {
+			return nil, fmt.Errorf("invalid substitution rule %d: invalid binding %d", i, rule.Binding)
+		}
+
+		var test substitutionTest = familyEquals(rule.Family)
+		if rule.Lang != "" {
+			lang, ok := language.NewLangID(language.NewLanguage(string(rule.Lang)))
+			if !ok {
+				return nil, fmt.Errorf("invalid substitution rule %d: unsupported language %s", i, rule.Lang)
+			}
+			test = langAndFamilyEqual{lang: lang, family: rule.Family}
+		}
+		substitutes := make([]string, len(rule.Substitutes))
+		for j, family := range rule.Substitutes {
+			substitutes[j] = font.NormalizeFamily(family)
+		}
+		out = append(out, substitution{
+			test:               test.normalize(),
+			additionalFamilies: substitutes,
+			op:                 substitutionOp(rule.Position), // same order
+			importance:         "esw"[rule.Binding],
+		})
+	}
+	if !sc.IgnoreDefaults {
+		out = append(out, familySubstitution...)
+	}
+	return out, nil
+}
+
 // ----- list manipulation -----
 
 func insertAt(s []weightedFamily, i int, v []weightedFamily) []weightedFamily {
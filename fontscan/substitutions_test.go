@@ -1,12 +1,14 @@
 package fontscan
 
 import (
+	"encoding/json"
 	"reflect"
 	"sort"
 	"testing"
 
 	"github.com/boxesandglue/typesetting/font"
 	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
 )
 
 func Test_familyList_insertStart(t *testing.T) {
@@ -226,7 +228,7 @@ func Test_newFamilyCrible(t *testing.T) {
 
 	for _, tt := range tests {
 		got := make(familyCrible)
-		got.fillWithSubstitutions(font.NormalizeFamily(tt.family), language.LangEn)
+		got.fillWithSubstitutions(font.NormalizeFamily(tt.family), language.LangEn, familySubstitution)
 		strong, weak := got.families()
 		if !(reflect.DeepEqual(strong, tt.wantStrong) && reflect.DeepEqual(weak, tt.wantWeak)) {
 			t.Errorf("newFamilyCrible() = %v %v, want %v %v", strong, weak, tt.wantStrong, tt.wantWeak)
@@ -329,13 +331,13 @@ func TestReplaceAt(t *testing.T) {
 func BenchmarkNewFamilyCrible(b *testing.B) {
 	c := make(familyCrible)
 	for i := 0; i < b.N; i++ {
-		c.fillWithSubstitutions("Arial", language.LangEn)
+		c.fillWithSubstitutions("Arial", language.LangEn, familySubstitution)
 	}
 }
 
 func TestSubstituteHelveticaOrder(t *testing.T) {
 	c := make(familyCrible)
-	c.fillWithSubstitutionsList([]string{font.NormalizeFamily("BlinkMacSystemFont"), font.NormalizeFamily("Helvetica")}, language.LangEn)
+	c.fillWithSubstitutionsList([]string{font.NormalizeFamily("BlinkMacSystemFont"), font.NormalizeFamily("Helvetica")}, language.LangEn, familySubstitution)
 	// BlinkMacSystemFont is not known by the library, so it is expanded with generic sans-serif,
 	// but with lower priority then Helvetica
 	expected := []string{"blinkmacsystemfont", "helvetica", "nimbussans", "nimbussansl", "texgyreheros", "helveticaltstd"}
@@ -347,18 +349,83 @@ func TestSubstituteHelveticaOrder(t *testing.T) {
 
 func TestLanguageSubstitutions(t *testing.T) {
 	c := make(familyCrible)
-	c.fillWithSubstitutions(font.NormalizeFamily("NimbusSans"), language.LangOr)
+	c.fillWithSubstitutions(font.NormalizeFamily("NimbusSans"), language.LangOr, familySubstitution)
 	if _, has := c["lohitoriya"]; !has {
 		t.Fatal("missing Lohit Oriya")
 	}
 	c.reset()
-	c.fillWithSubstitutions(font.NormalizeFamily("NimbusSans"), language.LangGu)
+	c.fillWithSubstitutions(font.NormalizeFamily("NimbusSans"), language.LangGu, familySubstitution)
 	if _, has := c["lohitgujarati"]; !has {
 		t.Fatal("missing Lohit Gujarati")
 	}
 	c.reset()
-	c.fillWithSubstitutions(font.NormalizeFamily("NimbusSans"), language.LangPa)
+	c.fillWithSubstitutions(font.NormalizeFamily("NimbusSans"), language.LangPa, familySubstitution)
 	if _, has := c["lohitgurmukhi"]; !has {
 		t.Fatal("missing Lohit Gurmukhi")
 	}
 }
+
+func TestSubstitutionConfig(t *testing.T) {
+	const config = `{"rules": [
+		{"family": "Helvetica", "substitutes": ["Liberation Sans"], "position": "prepend", "binding": "strong"},
+		{"family": "sans-serif", "substitutes": ["Inter", "Open Sans"], "position": "prepend_first", "binding": "same"},
+		{"family": "sans-serif", "lang": "ja", "substitutes": ["Noto Sans JP"], "position": "append", "binding": "weak"}
+	]}`
+	var sc SubstitutionConfig
+	tu.AssertNoErr(t, json.Unmarshal([]byte(config), &sc))
+	tu.Assert(t, sc.Rules[0].Position == PositionPrepend && sc.Rules[1].Binding == BindingSame)
+	rules, err := sc.compile()
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, len(rules) == 3+len(familySubstitution))
+
+	c := make(familyCrible)
+	c.fillWithSubstitutions("helvetica", language.LangEn, rules)
+	strong, _ := c.families()
+	tu.Assert(t, reflect.DeepEqual(strong[:2], []string{"liberationsans", "helvetica"}))
+	// the built-in rules are still applied
+	_, has := c["nimbussans"]
+	tu.Assert(t, has)
+
+	c.reset()
+	c.fillWithSubstitutions("sans-serif", language.LangEn, rules)
+	strong, _ = c.families()
+	tu.Assert(t, reflect.DeepEqual(strong[:3], []string{"inter", "opensans", "sans-serif"}))
+	_, has = c["notosansjp"]
+	tu.Assert(t, !has)
+
+	ja, _ := language.NewLangID("ja")
+	c.reset()
+	c.fillWithSubstitutions("sans-serif", ja, rules)
+	score, has := c["notosansjp"]
+	tu.Assert(t, has && !score.strong)
+
+	// without the built-in rules
+	sc.IgnoreDefaults = true
+	rules, err = sc.compile()
+	tu.AssertNoErr(t, err)
+	c.reset()
+	c.fillWithSubstitutions("helvetica", language.LangEn, rules)
+	strong, weak := c.families()
+	tu.Assert(t, reflect.DeepEqual(strong, []string{"liberationsans", "helvetica"}) && len(weak) == 0)
+
+	// invalid configs
+	for _, config := range []string{
+		`{"rules": [{"family": "Arial", "position": "middle"}]}`,
+		`{"rules": [{"family": "Arial", "binding": "medium"}]}`,
+	} {
+		tu.Assert(t, json.Unmarshal([]byte(config), &sc) != nil)
+	}
+	for _, rule := range []SubstitutionRule{
+		{Substitutes: []string{"Arial"}},
+		{Family: "Arial", Lang: "not-a-language"},
+		{Family: "Arial", Position: PositionReplace + 1},
+	} {
+		_, err = SubstitutionConfig{Rules: []SubstitutionRule{rule}}.compile()
+		tu.Assert(t, err != nil)
+	}
+
+	// round trip
+	b, err := json.Marshal(SubstitutionRule{Family: "Arial", Position: PositionReplace, Binding: BindingWeak})
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, string(b) == `{"family":"Arial","substitutes":null,"position":"replace","binding":"weak"}`)
+}
@@ -0,0 +1,203 @@
+package fontscan
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/boxesandglue/typesetting/language"
+)
+
+// DefaultWatchInterval is the delay between two scans of the font directories,
+// used by [FontMap.Watch] when no valid interval is provided.
+const DefaultWatchInterval = 5 * time.Second
+
+// fontWatcher stores the updates found by the watching goroutine,
+// until they are applied by the goroutine owning the [FontMap].
+type fontWatcher struct {
+	hasPending atomic.Bool
+
+	mu      sync.Mutex
+	pending systemFontsIndex
+	changed map[string]bool // files removed or modified since the last applied update
+}
+
+// Watch monitors the system font directories (see [DefaultFontDirectories]) in a background goroutine,
+// until [ctx] is cancelled, so that long-running applications pick up installed or removed
+// fonts without restarting.
+//
+// The directories are polled every [interval] (or [DefaultWatchInterval] if [interval] is not positive) :
+// this is portable and works for network file systems, and, since only the files whose modification
+// time has changed are scanned, is rather cheap.
+//
+// When a change is detected, the system fonts of the font map are replaced by the fonts
+// found in the directories, and the cached faces of the modified or removed files are discarded.
+// Fonts added with [FontMap.AddFont] and [FontMap.AddFace] are not affected.
+// To preserve the single-goroutine usage of [FontMap], the update is applied lazily,
+// on the next call to [FontMap.ResolveFace], [FontMap.ResolveFaceForLang],
+// [FontMap.FindSystemFont] or [FontMap.FindSystemFonts].
+//
+// The font map typically starts with the system fonts, loaded by [FontMap.UseSystemFonts].
+// If it is not the case, the fonts found by the first scan are added to the font map.
+//
+// Watch should only be called once per font map. An error is returned if the font
+// directories can't be found.
+func (fm *FontMap) Watch(ctx context.Context, interval time.Duration) error {
+	dirs, err := DefaultFontDirectories(fm.logger)
+	if err != nil {
+		return fmt.Errorf("searching font directories: %s", err)
+	}
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	// the index the font map is (presumably) built from
+	var reference systemFontsIndex
+	if fm.hasSystemFonts() {
+		reference = systemFonts
+	}
+	fm.watchDirectories(ctx, interval, reference, dirs)
+	return nil
+}
+
+// watchDirectories starts the watching goroutine, using [reference]
+// as the initial state of [dirs].
+func (fm *FontMap) watchDirectories(ctx context.Context, interval time.Duration, reference systemFontsIndex, dirs []string) {
+	watcher := &fontWatcher{}
+	fm.watcher = watcher
+	go watcher.run(ctx, fm.logger, interval, reference, dirs)
+}
+
+// hasSystemFonts returns true if the footprints of the global
+// system index have been added to the font map.
+func (fm *FontMap) hasSystemFonts() bool {
+	for _, fp := range fm.database {
+		if !fp.isUserProvided {
+			return true
+		}
+	}
+	return false
+}
+
+func (fw *fontWatcher) run(ctx context.Context, logger Logger, interval time.Duration,
+	current systemFontsIndex, dirs []string,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for first := true; ; first = false {
+		if !first {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+
+		updated, err := scanFontFootprintsContext(ctx, logger, current, nil, dirs...)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Printf("watching font directories: %s", err)
+			continue
+		}
+
+		if changed, added := current.diffFiles(updated); current == nil || added || len(changed) != 0 {
+			fw.publish(updated, changed)
+		}
+		current = updated
+	}
+}
+
+// diffFiles returns the files of [sfi] which are modified or removed in [updated],
+// and whether [updated] contains new files.
+func (sfi systemFontsIndex) diffFiles(updated systemFontsIndex) (changed map[string]bool, added bool) {
+	previous := make(map[string]timeStamp, len(sfi))
+	for _, file := range sfi {
+		previous[file.path] = file.modTime
+	}
+	modTimes := make(map[string]timeStamp, len(updated))
+	for _, file := range updated {
+		modTimes[file.path] = file.modTime
+		if _, has := previous[file.path]; !has {
+			added = true
+		}
+	}
+	changed = map[string]bool{}
+	for _, file := range sfi {
+		if modTime, has := modTimes[file.path]; !has || modTime != file.modTime {
+			changed[file.path] = true
+		}
+	}
+	return changed, added
+}
+
+func (fw *fontWatcher) publish(index systemFontsIndex, changed map[string]bool) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	fw.pending = index
+	if fw.changed == nil {
+		fw.changed = changed
+	} else {
+		for file := range changed {
+			fw.changed[file] = true
+		}
+	}
+	fw.hasPending.Store(true)
+}
+
+// take returns the pending update, if any.
+func (fw *fontWatcher) take() (systemFontsIndex, map[string]bool, bool) {
+	if fw == nil || !fw.hasPending.Load() {
+		return nil, nil, false
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	index, changed := fw.pending, fw.changed
+	fw.pending, fw.changed = nil, nil
+	fw.hasPending.Store(false)
+	return index, changed, true
+}
+
+// applyWatchUpdate replaces the system fonts with the last index
+// found by the watcher, if any.
+func (fm *FontMap) applyWatchUpdate() {
+	index, changed, ok := fm.watcher.take()
+	if !ok {
+		return
+	}
+
+	// keep the user provided fonts, in the same order
+	var userFonts []Footprint
+	for _, fp := range fm.database {
+		if fp.isUserProvided {
+			userFonts = append(userFonts, fp)
+		}
+	}
+	fm.database = fm.database[:0]
+	fm.scriptMap = make(map[language.Script][]int)
+	fm.postScriptNames = make(map[string]int)
+	fm.discarded = nil
+	fm.appendFootprints(userFonts...)
+	fm.appendFootprints(index.flatten()...)
+
+	// invalidate the faces of modified or removed files
+	for location, face := range fm.faceCache {
+		if !changed[location.File] {
+			continue
+		}
+		delete(fm.faceCache, location)
+		delete(fm.metaCache, face.Font)
+		if fm.firstFace == face {
+			fm.firstFace = nil
+		}
+	}
+
+	fm.built = false
+	fm.lru.Clear()
+}
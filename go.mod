@@ -3,6 +3,7 @@ module github.com/boxesandglue/typesetting
 go 1.19
 
 require (
+	github.com/andybalholm/brotli v1.1.1
 	github.com/go-text/typesetting-utils v0.0.0-20241103174707-87a29e9e6066
 	golang.org/x/image v0.23.0
 	golang.org/x/text v0.21.0
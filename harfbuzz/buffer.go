@@ -1,6 +1,11 @@
 package harfbuzz
 
 import (
+	"fmt"
+	"sort"
+	"unicode/utf16"
+	"unicode/utf8"
+
 	"github.com/boxesandglue/typesetting/font/opentype/tables"
 	"github.com/boxesandglue/typesetting/language"
 )
@@ -90,6 +95,30 @@ type Buffer struct {
 	Flags ShappingOptions
 	// Precise the cluster handling behavior.
 	ClusterLevel ClusterLevel
+	// SoftHyphen controls the rendering of soft hyphens (U+00AD).
+	SoftHyphen SoftHyphenPolicy
+
+	// MarkClassifier, if not nil, replaces the Unicode general category
+	// to classify the glyphs as marks or bases, for fonts without glyph classes (GDEF table).
+	// It is typically used with fonts encoding their marks in the Private Use Area.
+	// It is called before substitution, with the input character and its nominal glyph.
+	MarkClassifier func(r rune, glyph GID) bool
+
+	// Tracer, if not nil, is called at each step of the shaping process.
+	// See [TraceEvent] for the reported steps.
+	Tracer Tracer
+
+	// Justification selects the modifications suggested by the 'JSTF' table
+	// of the font (see [font.Font.JustificationPriorities]) for the script and language of the buffer.
+	// A positive value n applies the extension suggestions of the first n priorities,
+	// and a negative value -n their shrinkage suggestions : the GSUB and GPOS lookups they list
+	// are enabled (for the whole buffer) or disabled.
+	// The JstfMax lookups (the maximum extension or shrinkage of a priority) are not supported.
+	// The default value, zero, ignores the 'JSTF' table.
+	//
+	// Justifying a line thus typically consists in shaping it again, with
+	// increasing levels, until it fits.
+	Justification int
 
 	// some pathological cases can be constructed
 	// (for example with GSUB tables), where the size of the buffer
@@ -106,6 +135,9 @@ type Buffer struct {
 	haveOutput bool
 
 	planCache map[Face][]*shapePlan
+
+	bidiLevels []uint8 // optional, see [Buffer.SetBidiLevels]
+	randomSeed uint32  // see [Buffer.SetRandomSeed]
 }
 
 // NewBuffer allocate a storage with default options.
@@ -118,6 +150,14 @@ func NewBuffer() *Buffer {
 	}
 }
 
+// SetRandomSeed sets the initial state of the random generator used
+// by the 'rand' feature, so that the alternates it selects may be varied or
+// reproduced by applications, for instance with handwriting-style fonts.
+// Shaping the same text with the same seed always gives the same result.
+//
+// A zero seed selects the default state, which is also restored by [Buffer.Clear].
+func (b *Buffer) SetRandomSeed(seed uint32) { b.randomSeed = seed }
+
 // AddRune appends a character with the Unicode value of `codepoint` to `b`, and
 // gives it the initial cluster value of `cluster`. Clusters can be any thing
 // the client wants, they are usually used to refer to the index of the
@@ -176,6 +216,105 @@ func (b *Buffer) AddRunes(text []rune, itemOffset, itemLength int) {
 	b.context[1] = text[itemOffset+itemLength : s]
 }
 
+// AddUTF8 is the same as [Buffer.AddRunes], but for UTF-8 encoded `text`:
+// `itemOffset` and `itemLength` are given in bytes, and the cluster value
+// attributed to each rune is the index of its first byte in `text`.
+// Invalid sequences are replaced by U+FFFD, one for each invalid byte.
+func (b *Buffer) AddUTF8(text []byte, itemOffset, itemLength int) {
+	if len(b.Info) == 0 && itemOffset > 0 {
+		// add pre-context
+		b.clearContext(0)
+		for prev := itemOffset; prev > 0 && len(b.context[0]) < contextLength; {
+			r, size := utf8.DecodeLastRune(text[:prev])
+			b.context[0] = append(b.context[0], r)
+			prev -= size
+		}
+	}
+
+	if itemLength < 0 {
+		itemLength = len(text) - itemOffset
+	}
+	end := itemOffset + itemLength
+	for i := itemOffset; i < end; {
+		r, size := utf8.DecodeRune(text[i:end])
+		b.append(r, i)
+		i += size
+	}
+
+	// add post-context
+	b.context[1] = nil // do not override the slice provided to AddRunes
+	for i := end; i < len(text) && len(b.context[1]) < contextLength; {
+		r, size := utf8.DecodeRune(text[i:])
+		b.context[1] = append(b.context[1], r)
+		i += size
+	}
+}
+
+// AddUTF16 is the same as [Buffer.AddRunes], but for UTF-16 encoded `text`,
+// as used for instance by text editors and Javascript strings:
+// `itemOffset` and `itemLength` are given in code units, and the cluster value
+// attributed to each rune is the index of its first code unit in `text`.
+// Unpaired surrogates are replaced by U+FFFD.
+func (b *Buffer) AddUTF16(text []uint16, itemOffset, itemLength int) {
+	if len(b.Info) == 0 && itemOffset > 0 {
+		// add pre-context
+		b.clearContext(0)
+		for prev := itemOffset; prev > 0 && len(b.context[0]) < contextLength; {
+			r, size := decodeLastUTF16(text[:prev])
+			b.context[0] = append(b.context[0], r)
+			prev -= size
+		}
+	}
+
+	if itemLength < 0 {
+		itemLength = len(text) - itemOffset
+	}
+	end := itemOffset + itemLength
+	for i := itemOffset; i < end; {
+		r, size := decodeUTF16(text[i:end])
+		b.append(r, i)
+		i += size
+	}
+
+	// add post-context
+	b.context[1] = nil // do not override the slice provided to AddRunes
+	for i := end; i < len(text) && len(b.context[1]) < contextLength; {
+		r, size := decodeUTF16(text[i:])
+		b.context[1] = append(b.context[1], r)
+		i += size
+	}
+}
+
+// decodeUTF16 returns the first rune of [text] (which must not be empty),
+// and its length in code units.
+func decodeUTF16(text []uint16) (rune, int) {
+	c := rune(text[0])
+	if !utf16.IsSurrogate(c) {
+		return c, 1
+	}
+	if len(text) >= 2 {
+		if r := utf16.DecodeRune(c, rune(text[1])); r != utf8.RuneError {
+			return r, 2
+		}
+	}
+	return utf8.RuneError, 1
+}
+
+// decodeLastUTF16 returns the last rune of [text] (which must not be empty),
+// and its length in code units.
+func decodeLastUTF16(text []uint16) (rune, int) {
+	c := rune(text[len(text)-1])
+	if !utf16.IsSurrogate(c) {
+		return c, 1
+	}
+	if len(text) >= 2 {
+		if r := utf16.DecodeRune(rune(text[len(text)-2]), c); r != utf8.RuneError {
+			return r, 2
+		}
+	}
+	return utf8.RuneError, 1
+}
+
 // GuessSegmentProperties fills unset buffer segment properties based on buffer Unicode
 // contents and can be used when no other information is available.
 //
@@ -184,13 +323,27 @@ func (b *Buffer) AddRunes(text []rune, itemOffset, itemLength int) {
 // the buffer that has a script other than Common,
 // Inherited, and Unknown.
 //
-// Next, if buffer `Props.Direction` is zero,
+// Next, if buffer `Props.Direction` is zero (or invalid),
 // it will be set to the natural horizontal direction of the
 // buffer script, defaulting to `LeftToRight`.
 //
 // Finally, if buffer Props.Language is empty,
-// it will be set to the process's default language.
+// it will be set to the process's default language
+// (see [language.DefaultLanguage]).
+//
+// Note that [Buffer.Shape] guesses the script and direction
+// (but not the language) if they are not set.
 func (b *Buffer) GuessSegmentProperties() {
+	b.guessScriptAndDirection()
+
+	/* If language is not set, use default language from locale */
+	if b.Props.Language == "" {
+		b.Props.Language = language.DefaultLanguage()
+	}
+}
+
+// guessScriptAndDirection implements the first two steps of [Buffer.GuessSegmentProperties]
+func (b *Buffer) guessScriptAndDirection() {
 	/* If script is not set, guess from buffer contents */
 	if b.Props.Script == 0 {
 		for _, info := range b.Info {
@@ -203,17 +356,12 @@ func (b *Buffer) GuessSegmentProperties() {
 	}
 
 	/* If direction is unset, guess from script */
-	if b.Props.Direction == 0 {
+	if !b.Props.Direction.isValid() {
 		b.Props.Direction = getHorizontalDirection(b.Props.Script)
 		if b.Props.Direction == 0 {
 			b.Props.Direction = LeftToRight
 		}
 	}
-
-	/* If language is not set, use default language from locale */
-	if b.Props.Language == "" {
-		b.Props.Language = language.DefaultLanguage()
-	}
 }
 
 // Clear resets `b` to its initial empty state (including user settings).
@@ -221,6 +369,10 @@ func (b *Buffer) GuessSegmentProperties() {
 func (b *Buffer) Clear() {
 	b.ClusterLevel = 0
 	b.Flags = 0
+	b.SoftHyphen = 0
+	b.MarkClassifier = nil
+	b.Tracer = nil
+	b.Justification = 0
 	b.Invisible = 0
 	b.NotFound = 0
 
@@ -235,10 +387,223 @@ func (b *Buffer) Clear() {
 	b.Pos = b.Pos[:0]
 	b.clearContext(0)
 	b.clearContext(1)
+	b.bidiLevels = nil
+	b.randomSeed = 0
 
 	b.serial = 0
 }
 
+// Positions returns the glyph positions resulting from the last shaping,
+// which are the same as [Buffer.Pos], in visual order.
+// The returned slice may be mutated, for instance to apply tracking or justification,
+// but note that the offsets of glyphs attached to other glyphs (marks and cursive
+// connections) include the offsets of their parent and the advances in-between.
+// Use [Buffer.AdjustPositions] to modify advances while preserving these attachments.
+//
+// Positions returns nil if the buffer has not been shaped.
+func (b *Buffer) Positions() []GlyphPosition {
+	if len(b.Pos) != len(b.Info) {
+		return nil
+	}
+	return b.Pos
+}
+
+// FractionalPosition is a glyph position, expressed with floating point values.
+// See [Buffer.FractionalPositions].
+type FractionalPosition struct {
+	XAdvance, XOffset float32
+	YAdvance, YOffset float32
+}
+
+// FractionalPositions returns the glyph positions resulting from the last shaping
+// with [font], in visual order, scaled to the scale set by [Font.SetFractionalScale].
+// The positions are not rounded, which is useful for sub-pixel layout.
+//
+// FractionalPositions returns nil if the buffer has not been shaped.
+func (b *Buffer) FractionalPositions(font *Font) []FractionalPosition {
+	pos := b.Positions()
+	if pos == nil {
+		return nil
+	}
+	xScale, yScale := font.FractionalScale()
+	// from the font scale to the fractional scale
+	xFactor, yFactor := xScale/float32(font.XScale), yScale/float32(font.YScale)
+	out := make([]FractionalPosition, len(pos))
+	for i, p := range pos {
+		out[i] = FractionalPosition{
+			XAdvance: float32(p.XAdvance) * xFactor,
+			XOffset:  float32(p.XOffset) * xFactor,
+			YAdvance: float32(p.YAdvance) * yFactor,
+			YOffset:  float32(p.YOffset) * yFactor,
+		}
+	}
+	return out
+}
+
+// AdjustPositions calls [adjust] with the glyph positions resulting from the last shaping,
+// after expressing the offsets of attached glyphs (marks and cursive connections)
+// relatively to the glyph they are attached to.
+// Once [adjust] returns, the attachment offsets are propagated again, so that marks
+// follow their base glyph and cursive chains stay connected, whatever the changes
+// applied to the advances and to the offsets of the base glyphs.
+//
+// [adjust] may change the advances and offsets of any glyph, but must not
+// reorder, insert or remove positions.
+func (b *Buffer) AdjustPositions(adjust func(pos []GlyphPosition)) {
+	pos := b.Positions()
+	if b.scratchFlags&bsfHasGPOSAttachment == 0 {
+		adjust(pos)
+		return
+	}
+
+	// after shaping, the glyphs are in visual order
+	direction := LeftToRight
+	if !b.Props.Direction.isHorizontal() {
+		direction = TopToBottom
+	}
+	abs := append([]GlyphPosition(nil), pos...)
+	detachAttachmentOffsets(pos, abs, direction)
+	adjust(pos)
+	propagateAllAttachmentOffsets(pos, direction)
+}
+
+// AttachmentKind describes how a glyph is attached to another glyph,
+// as returned by [Buffer.Attachment].
+type AttachmentKind uint8
+
+const (
+	// AttachmentNone is used for glyphs which are not attached.
+	AttachmentNone AttachmentKind = iota
+	// AttachmentMark is used for marks positioned on a base glyph, a ligature
+	// or another mark (GPOS lookup types 4, 5 and 6, or 'kerx' anchors and control points).
+	AttachmentMark
+	// AttachmentCursive is used for glyphs connected to the previous or next glyph
+	// (GPOS lookup type 3, or 'kerx' and 'kern' cross-stream kerning).
+	AttachmentCursive
+)
+
+// Attachment returns the index of the glyph the glyph [i] is attached to,
+// as resolved by the positioning of the last shaping, and the kind of attachment.
+// The indices refer to [Buffer.Info] and [Buffer.Pos], in visual order.
+//
+// For marks attached to other marks, the base glyph is found by calling Attachment
+// again with the returned index, until a glyph which is not a mark attachment is found.
+//
+// If the glyph is not attached, or if the buffer has not been shaped,
+// (-1, [AttachmentNone]) is returned. Note that fallback mark positioning
+// does not attach glyphs.
+func (b *Buffer) Attachment(i int) (int, AttachmentKind) {
+	pos := b.Positions()
+	if b.scratchFlags&bsfHasGPOSAttachment == 0 || i < 0 || i >= len(pos) {
+		return -1, AttachmentNone
+	}
+	parent, ok := attachmentParent(pos, i)
+	if !ok {
+		return -1, AttachmentNone
+	}
+	if pos[i].attachType&attachTypeCursive != 0 {
+		return parent, AttachmentCursive
+	}
+	return parent, AttachmentMark
+}
+
+// RemapGlyphs replaces the glyphs resulting from shaping using [mapping], which
+// maps the glyph IDs of the font used for shaping to new glyph IDs, typically the ones of a subset of this font,
+// built once the text is shaped. The positions, clusters and flags are not modified,
+// so that the buffer is usable with the new font without shaping it again.
+//
+// If a glyph is missing from [mapping], an error is returned and the buffer is not modified.
+func (b *Buffer) RemapGlyphs(mapping map[GID]GID) error {
+	for _, info := range b.Info {
+		if _, ok := mapping[info.Glyph]; !ok {
+			return fmt.Errorf("glyph %d is not remapped", info.Glyph)
+		}
+	}
+	for i, info := range b.Info {
+		b.Info[i].Glyph = mapping[info.Glyph]
+	}
+	return nil
+}
+
+// PropagateGlyphFlags makes the glyph flags (see [GlyphUnsafeToBreak] and
+// related constants) consistent across each cluster,
+// as done at the end of shaping.
+// It should be called after changing the [GlyphInfo.Mask] or [GlyphInfo.Cluster]
+// fields of the glyphs resulting from shaping.
+func (b *Buffer) PropagateGlyphFlags() {
+	for _, info := range b.Info {
+		if info.Mask&glyphFlagDefined != 0 {
+			b.scratchFlags |= bsfHasGlyphFlags
+			break
+		}
+	}
+	propagateFlags(b)
+}
+
+// SafeBreaks returns the cluster values where the shaped text may be broken
+// (for instance by a line breaker) without shaping again the two parts : they are
+// the start of the clusters whose glyphs are not flagged with [GlyphUnsafeToBreak].
+// The cluster values are expressed in the units used when adding the text (typically
+// rune indices for [Buffer.AddRunes]).
+//
+// The returned slice is sorted in increasing order, whatever the direction, and does not
+// include the start of the text.
+//
+// If [opportunities] is not nil, it is interpreted as the (sorted) cluster values where breaking is
+// allowed, for instance by the Unicode line breaking algorithm (UAX#14), and only the safe
+// positions which are also opportunities are returned.
+// Opportunities inside a cluster are never safe.
+//
+// It should be called after [Buffer.Shape].
+func (b *Buffer) SafeBreaks(opportunities []int) []int {
+	if len(b.Info) == 0 {
+		return nil
+	}
+	start := b.Info[0].Cluster // start of the text
+	var out []int
+	for i, info := range b.Info {
+		if i != 0 && info.Cluster == b.Info[i-1].Cluster {
+			continue // same cluster, which share the same flags
+		}
+		start = min(start, info.Cluster)
+		if info.Mask&GlyphUnsafeToBreak == 0 {
+			out = append(out, info.Cluster)
+		}
+	}
+	sort.Ints(out)
+
+	// remove the start of the text and the duplicates, which may happen
+	// for clusters split by reordering
+	filtered := out[:0]
+	for i, cluster := range out {
+		if cluster == start || (i != 0 && cluster == out[i-1]) {
+			continue
+		}
+		filtered = append(filtered, cluster)
+	}
+	out = filtered
+
+	if opportunities == nil {
+		return out
+	}
+
+	// intersect the two sorted lists
+	filtered = out[:0]
+	for i, j := 0, 0; i < len(out) && j < len(opportunities); {
+		switch {
+		case out[i] < opportunities[j]:
+			i++
+		case out[i] > opportunities[j]:
+			j++
+		default:
+			filtered = append(filtered, out[i])
+			i++
+			j++
+		}
+	}
+	return filtered
+}
+
 // cur returns the glyph at the cursor, optionaly shifted by `i`.
 // Its simply a syntactic sugar for `&b.Info[b.idx+i] `
 func (b *Buffer) cur(i int) *GlyphInfo { return &b.Info[b.idx+i] }
@@ -423,6 +788,10 @@ func (b *Buffer) setMasks(value, mask GlyphMask, clusterStart, clusterEnd int) {
 	}
 }
 
+// MergeClusters merges the clusters of the glyphs Info[start:end], which is
+// required before reordering them, for instance in the hooks of a [ComplexShaper].
+func (b *Buffer) MergeClusters(start, end int) { b.mergeClusters(start, end) }
+
 func (b *Buffer) mergeClusters(start, end int) {
 	if end-start < 2 {
 		return
@@ -502,6 +871,7 @@ done:
 func (b *Buffer) deleteGlyphsInplace(filter func(*GlyphInfo) bool) {
 	// Merge clusters and delete filtered glyphs.
 	// NOTE! We can't use out-buffer as we have positioning data.
+	b.remapAttachments(filter)
 	var (
 		j    int
 		info = b.Info
@@ -548,6 +918,46 @@ func (b *Buffer) deleteGlyphsInplace(filter func(*GlyphInfo) bool) {
 	b.Pos = b.Pos[:j]
 }
 
+// remapAttachments updates the attachment chains before
+// the glyphs matching [filter] are removed.
+// Glyphs attached to a removed glyph are detached.
+func (b *Buffer) remapAttachments(filter func(*GlyphInfo) bool) {
+	if b.scratchFlags&bsfHasGPOSAttachment == 0 {
+		return
+	}
+	newIndex := make([]int, len(b.Info))
+	j := 0
+	for i := range b.Info {
+		if filter(&b.Info[i]) {
+			newIndex[i] = -1
+			continue
+		}
+		newIndex[i] = j
+		j++
+	}
+	pos := b.Pos
+	for i := range pos {
+		parent, ok := attachmentParent(pos, i)
+		if !ok || newIndex[i] == -1 {
+			continue
+		}
+		if newIndex[parent] == -1 {
+			pos[i].attachChain, pos[i].attachType = 0, attachTypeNone
+		} else {
+			pos[i].attachChain = int16(newIndex[parent] - newIndex[i])
+		}
+	}
+}
+
+// clearAttachments removes the attachment chains, which
+// is required when glyphs are inserted after positioning.
+func (b *Buffer) clearAttachments() {
+	for i := range b.Pos {
+		b.Pos[i].attachChain, b.Pos[i].attachType = 0, attachTypeNone
+	}
+	b.scratchFlags &^= bsfHasGPOSAttachment
+}
+
 // unsafeToBreak adds the flag `GlyphFlagUnsafeToBreak`
 // when needed, between `start` and `end`.
 func (b *Buffer) unsafeToBreak(start, end int) {
@@ -639,6 +1049,10 @@ func (b *Buffer) clearPositions() {
 	L := len(b.Info)
 	if cap(b.Pos) >= L {
 		b.Pos = b.Pos[:L]
+		// reset the positions left by a previous shaping
+		for i := range b.Pos {
+			b.Pos[i] = GlyphPosition{}
+		}
 	} else {
 		b.Pos = make([]GlyphPosition, L)
 	}
@@ -666,18 +1080,33 @@ func (b *Buffer) reverseRange(start, end int) {
 		return
 	}
 	info := b.Info[start:end]
-	pos := b.Pos[start:end]
 	L := len(info)
-	_ = pos[L-1] // BCE
 	for i := L/2 - 1; i >= 0; i-- {
 		opp := L - 1 - i
 		info[i], info[opp] = info[opp], info[i]
-		pos[i], pos[opp] = pos[opp], pos[i] // same length
+	}
+	// during substitution, positions are not allocated yet
+	// and may be shorter than Info (after glyph insertions)
+	if end > len(b.Pos) {
+		return
+	}
+	pos := b.Pos[start:end]
+	for i := L/2 - 1; i >= 0; i-- {
+		opp := L - 1 - i
+		pos[i], pos[opp] = pos[opp], pos[i]
 	}
 }
 
 // Reverse reverses buffer contents, that is the `Info` and `Pos` slices.
-func (b *Buffer) Reverse() { b.reverseRange(0, len(b.Info)) }
+func (b *Buffer) Reverse() {
+	b.reverseRange(0, len(b.Info))
+	if b.scratchFlags&bsfHasGPOSAttachment != 0 {
+		// attachment chains are relative to the glyph index
+		for i := range b.Pos {
+			b.Pos[i].attachChain = -b.Pos[i].attachChain
+		}
+	}
+}
 
 func (b *Buffer) reverseClusters() {
 	b.reverseGroups(func(gi1, gi2 *GlyphInfo) bool {
@@ -740,15 +1169,21 @@ func (b *Buffer) shiftForward(count int) {
 	b.idx += count
 }
 
-func (b *Buffer) moveTo(i int) {
+// moveTo moves the output position to [i], returning false
+// (without moving) if [i] is out of range, which may happen with malformed fonts.
+func (b *Buffer) moveTo(i int) bool {
 	if !b.haveOutput {
-		// assert(i <= len)
+		if i < 0 || i > len(b.Info) {
+			return false
+		}
 		b.idx = i
-		return
+		return true
 	}
 
-	// assert(i <= out_len+(len-idx))
 	outL := len(b.outInfo)
+	if i < 0 || i > outL+(len(b.Info)-b.idx) {
+		return false
+	}
 	if outL < i {
 		count := i - outL
 		b.outInfo = append(b.outInfo, b.Info[b.idx:count+b.idx]...)
@@ -767,6 +1202,7 @@ func (b *Buffer) moveTo(i int) {
 		copy(b.Info[b.idx:], b.outInfo[outL-count:outL])
 		b.outInfo = b.outInfo[:outL-count]
 	}
+	return true
 }
 
 // iterator over the grapheme of a buffer
@@ -793,6 +1229,123 @@ func (b *Buffer) graphemesIterator() (*graphemesIterator, int) {
 	return &graphemesIterator{buffer: b}, len(b.Info)
 }
 
+// GraphemeIterator provides a convenient way of iterating over graphemes,
+// using the same segmentation as the shaper (see [MonotoneGraphemes]).
+// This segmentation follows the extended grapheme clusters of UAX #29 for combining marks,
+// emoji sequences and regional indicators, but does not handle, for instance,
+// Hangul syllables (which are handled by the Hangul shaper) or CR LF sequences.
+//
+// Callers computing caret stops or selection boundaries should use it instead of a full
+// UAX #29 implementation (like the segmenter package) so that the graphemes match the
+// clusters of the shaped text.
+type GraphemeIterator struct {
+	info       []GlyphInfo // with continuation flags
+	start, end int         // current grapheme
+	shaped     bool        // never split clusters
+	backward   bool        // glyphs in reverse logical order
+}
+
+// NewGraphemeIterator returns an iterator over the graphemes of [text].
+// The indices returned by [GraphemeIterator.Grapheme] are indices into [text].
+func NewGraphemeIterator(text []rune) *GraphemeIterator {
+	buffer := NewBuffer()
+	buffer.AddRunes(text, 0, -1)
+	buffer.setUnicodeProps()
+	return &GraphemeIterator{info: buffer.Info}
+}
+
+// GraphemeIterator returns an iterator over the graphemes of the shaped buffer.
+// The indices returned by [GraphemeIterator.Grapheme] are indices into [Buffer.Info],
+// so that each grapheme is made of consecutive glyphs, in visual order.
+// A grapheme never splits a cluster, but a cluster may contain several graphemes,
+// for instance for ligatures or with the default [MonotoneGraphemes] cluster level.
+//
+// It should be called after [Buffer.Shape].
+func (b *Buffer) GraphemeIterator() *GraphemeIterator {
+	return &GraphemeIterator{info: b.Info, shaped: true, backward: b.Props.Direction.isBackward()}
+}
+
+// Next returns true if there is still a grapheme to process,
+// and advances the iterator; or return false.
+func (gi *GraphemeIterator) Next() bool {
+	if gi.end >= len(gi.info) {
+		return false
+	}
+	gi.start = gi.end
+	for gi.end = gi.start + 1; gi.end < len(gi.info); gi.end++ {
+		prev, info := &gi.info[gi.end-1], &gi.info[gi.end]
+		if gi.shaped && info.Cluster == prev.Cluster {
+			continue
+		}
+		// in backward buffers, [prev] follows [info] in logical order
+		if gi.backward {
+			info = prev
+		}
+		if !info.isContinuation() {
+			break
+		}
+	}
+	return true
+}
+
+// Grapheme returns the current grapheme, delimited by [start, end).
+func (gi *GraphemeIterator) Grapheme() (start, end int) { return gi.start, gi.end }
+
+// GlyphRange returns the range [start, end) of the glyphs in [Buffer.Info] displaying
+// the text at [textIndex], which must be expressed in the same unit as the clusters
+// (runes for [Buffer.AddRunes], bytes for [Buffer.AddUTF8], etc.).
+// It is the range of the glyphs belonging to the cluster containing [textIndex], that is
+// the glyphs with the largest cluster value less than or equal to [textIndex].
+//
+// The cluster level of the buffer is taken into account: for instance, with [Characters],
+// the index of a mark maps to the glyph of the mark (if any), whereas with [MonotoneGraphemes]
+// it maps to the whole grapheme. Since glyphs are in visual order, clusters may be
+// reordered (in right-to-left text, or with Indic pre-base matras) : the returned range
+// is the smallest range containing all the glyphs of the cluster.
+//
+// An empty range is returned if no glyph is found, that is if [textIndex] is before
+// the first cluster.
+//
+// It should be called after [Buffer.Shape], and runs in linear time.
+func (b *Buffer) GlyphRange(textIndex int) (start, end int) {
+	cluster := -1
+	for _, info := range b.Info {
+		if info.Cluster <= textIndex && info.Cluster > cluster {
+			cluster = info.Cluster
+		}
+	}
+	if cluster == -1 {
+		return 0, 0
+	}
+	start = -1
+	for i, info := range b.Info {
+		if info.Cluster == cluster {
+			if start == -1 {
+				start = i
+			}
+			end = i + 1
+		}
+	}
+	return start, end
+}
+
+// TextRange returns the range [start, end) of the text displayed by the glyph at
+// [glyphIndex] in [Buffer.Info], that is the text of its cluster. The range
+// ends at the next cluster in logical order, or at [textLength] for the last cluster.
+// As for [Buffer.GlyphRange], indices are expressed in the same unit as the clusters,
+// and several glyphs may share the same text range.
+//
+// It should be called after [Buffer.Shape], and runs in linear time.
+func (b *Buffer) TextRange(glyphIndex, textLength int) (start, end int) {
+	start, end = b.Info[glyphIndex].Cluster, textLength
+	for _, info := range b.Info {
+		if start < info.Cluster && info.Cluster < end {
+			end = info.Cluster
+		}
+	}
+	return start, end
+}
+
 // iterator over clusters of a buffer with the loop
 // for start, end := iter.Next(); start < count; start, end = iter.Next() {}
 type clusterIterator struct {
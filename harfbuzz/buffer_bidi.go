@@ -0,0 +1,161 @@
+package harfbuzz
+
+// BidiRun is a run of glyphs shaped with the same bidi embedding level,
+// as returned by [Buffer.ShapeRuns].
+type BidiRun struct {
+	// Start and End (excluded) are the indices of the glyphs of the run
+	// in [Buffer.Info] and [Buffer.Pos].
+	Start, End int
+	// Level is the bidi embedding level of the run :
+	// odd levels are right-to-left, even levels are left-to-right.
+	Level uint8
+}
+
+// SetBidiLevels attaches to the buffer the bidi embedding levels of its text, resolved by the
+// caller (typically with the Unicode Bidirectional Algorithm), to be used by [Buffer.ShapeRuns].
+//
+// [levels] is indexed by cluster values : levels[i] is the level of the character
+// with cluster i, that is, for text added with [Buffer.AddRunes], the level of text[i].
+//
+// Passing nil removes the levels, which are also removed by [Buffer.Clear].
+// Note that [Buffer.Shape] ignores the levels.
+func (b *Buffer) SetBidiLevels(levels []uint8) { b.bidiLevels = levels }
+
+// ShapeRuns is a convenience wrapper around [Buffer.Shape], for text mixing
+// several directions, whose levels have been set with [Buffer.SetBidiLevels].
+//
+// The text of the buffer is split into runs of characters with the same level,
+// which are shaped separately (with the context of the adjacent runs), using the direction
+// given by the parity of their level, the script guessed from their content (see [Buffer.GuessSegmentProperties]),
+// and the language of [Buffer.Props]. Since each run is shaped with one script, text
+// mixing several scripts in the same direction should rather be split by the caller.
+// Then, the runs are reordered for display, following the rule L2 of the Unicode
+// Bidirectional Algorithm.
+//
+// After the call, the buffer contains the glyphs of the whole text, in visual order (from
+// left to right), and the returned runs, also in visual order, give the ranges of each run.
+// The cluster values of the glyphs are the ones of the characters they come from, as usual.
+//
+// If no levels have been set, ShapeRuns is the same as [Buffer.Shape], and
+// returns one run with level 1 for right-to-left text and 0 otherwise.
+func (b *Buffer) ShapeRuns(font *Font, features []Feature) []BidiRun {
+	levels := b.bidiLevels
+	if levels == nil {
+		b.Shape(font, features)
+		var level uint8
+		if b.Props.Direction == RightToLeft {
+			level = 1
+		}
+		return []BidiRun{{Start: 0, End: len(b.Info), Level: level}}
+	}
+
+	// use the (unshaped) content of the buffer as text,
+	// remembering the cluster values
+	text := make([]rune, len(b.Info))
+	clusters := make([]int, len(b.Info))
+	for i, info := range b.Info {
+		text[i], clusters[i] = info.codepoint, info.Cluster
+	}
+
+	// split into logical runs
+	var runs []BidiRun
+	for i := range text {
+		level := levels[clusters[i]]
+		if i == 0 || level != runs[len(runs)-1].Level {
+			runs = append(runs, BidiRun{Start: i, End: i, Level: level})
+		}
+		runs[len(runs)-1].End = i + 1
+	}
+
+	// shape each run, using a scratch buffer sharing the plan cache
+	sub := &Buffer{maxOps: b.maxOps, planCache: b.planCache}
+	var (
+		info []GlyphInfo
+		pos  []GlyphPosition
+		// attachment chains are relative, and stay valid once concatenated
+		attachments bufferScratchFlags
+	)
+	for i, run := range runs {
+		sub.Clear()
+		sub.Invisible, sub.NotFound = b.Invisible, b.NotFound
+		sub.ClusterLevel, sub.SoftHyphen = b.ClusterLevel, b.SoftHyphen
+		sub.MarkClassifier, sub.randomSeed = b.MarkClassifier, b.randomSeed
+		sub.Tracer, sub.Justification = b.Tracer, b.Justification
+
+		// only the first and last runs are at the boundaries of the text
+		sub.Flags = b.Flags &^ (Bot | Eot)
+		if i == 0 {
+			sub.Flags |= b.Flags & Bot
+			sub.context[0] = append(sub.context[0], b.context[0]...)
+		}
+		if i == len(runs)-1 {
+			sub.Flags |= b.Flags & Eot
+		}
+
+		sub.AddRunes(text, run.Start, run.End-run.Start)
+		if i == len(runs)-1 {
+			sub.context[1] = append(sub.context[1][:0:0], b.context[1]...)
+		}
+
+		sub.Props = b.Props
+		sub.Props.Script = 0
+		sub.Props.Direction = LeftToRight
+		if run.Level&1 == 1 {
+			sub.Props.Direction = RightToLeft
+		}
+		sub.GuessSegmentProperties()
+		sub.Shape(font, features)
+
+		runs[i].Start = len(info)
+		for _, glyph := range sub.Info {
+			glyph.Cluster = clusters[glyph.Cluster]
+			info = append(info, glyph)
+		}
+		pos = append(pos, sub.Pos...)
+		attachments |= sub.scratchFlags & bsfHasGPOSAttachment
+		runs[i].End = len(info)
+	}
+
+	// concatenate the runs in visual order
+	b.Info, b.Pos = b.Info[:0], b.Pos[:0]
+	b.scratchFlags = b.scratchFlags&^bsfHasGPOSAttachment | attachments
+	reorderBidiRuns(runs)
+	for i, run := range runs {
+		start := len(b.Info)
+		b.Info = append(b.Info, info[run.Start:run.End]...)
+		b.Pos = append(b.Pos, pos[run.Start:run.End]...)
+		runs[i].Start, runs[i].End = start, len(b.Info)
+	}
+	return runs
+}
+
+// reorderBidiRuns applies the rule L2 of the Unicode Bidirectional Algorithm,
+// reversing, from the highest level to the lowest odd level, any contiguous sequence
+// of runs at that level or higher.
+func reorderBidiRuns(runs []BidiRun) {
+	var maxLevel, minOddLevel uint8 = 0, 0xFF
+	for _, run := range runs {
+		if run.Level > maxLevel {
+			maxLevel = run.Level
+		}
+		if run.Level&1 == 1 && run.Level < minOddLevel {
+			minOddLevel = run.Level
+		}
+	}
+	for level := maxLevel; level >= minOddLevel && level > 0; level-- {
+		for i := 0; i < len(runs); {
+			if runs[i].Level < level {
+				i++
+				continue
+			}
+			j := i + 1
+			for j < len(runs) && runs[j].Level >= level {
+				j++
+			}
+			for k, l := i, j-1; k < l; k, l = k+1, l-1 {
+				runs[k], runs[l] = runs[l], runs[k]
+			}
+			i = j
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package harfbuzz
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/font"
+	tu "github.com/boxesandglue/typesetting/testutils"
+)
+
+func TestShapeRuns(t *testing.T) {
+	hbFont := NewFont(font.NewFace(openFontFileTT(t, "common/NotoSansArabic.ttf")))
+
+	// a right-to-left paragraph, with embedded left-to-right text
+	text := []rune("ab \u0644\u0627 12")
+	levels := []uint8{2, 2, 1, 1, 1, 1, 2, 2}
+
+	buffer := NewBuffer()
+	buffer.AddRunes(text, 0, -1)
+	buffer.GuessSegmentProperties()
+	buffer.SetBidiLevels(levels)
+	runs := buffer.ShapeRuns(hbFont, nil)
+
+	// runs and glyphs are in visual order
+	tu.Assert(t, reflect.DeepEqual(runs, []BidiRun{{0, 2, 2}, {2, 5, 1}, {5, 7, 2}}))
+	var clusters []int
+	for _, info := range buffer.Info {
+		clusters = append(clusters, info.Cluster)
+	}
+	tu.Assert(t, reflect.DeepEqual(clusters, []int{6, 7, 5, 3, 2, 0, 1}))
+	tu.Assert(t, len(buffer.Pos) == len(buffer.Info))
+
+	// the Arabic run is shaped with its ligatures
+	ref := NewBuffer()
+	ref.AddRunes(text, 2, 4)
+	ref.Props.Direction = RightToLeft
+	ref.GuessSegmentProperties()
+	ref.Shape(hbFont, nil)
+	for i, info := range ref.Info {
+		tu.Assert(t, buffer.Info[2+i].Glyph == info.Glyph)
+		tu.Assert(t, buffer.Pos[2+i] == ref.Pos[i])
+	}
+
+	// without levels, ShapeRuns is Shape
+	buffer.Clear()
+	buffer.AddRunes(text, 0, -1)
+	buffer.GuessSegmentProperties()
+	runs = buffer.ShapeRuns(hbFont, nil)
+	tu.Assert(t, reflect.DeepEqual(runs, []BidiRun{{Start: 0, End: len(buffer.Info), Level: 0}}))
+}
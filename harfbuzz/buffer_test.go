@@ -1,8 +1,14 @@
 package harfbuzz
 
 import (
+	"fmt"
+	"math"
+	"reflect"
 	"testing"
+	"unicode/utf16"
+	"unicode/utf8"
 
+	"github.com/boxesandglue/typesetting/font"
 	"github.com/boxesandglue/typesetting/language"
 	tu "github.com/boxesandglue/typesetting/testutils"
 )
@@ -299,3 +305,522 @@ func bufferDiff(buffer, reference *Buffer, dottedcircleGlyph GID, positionFuzz i
 
 	return result
 }
+
+func TestAdjustPositions(t *testing.T) {
+	ft := openFontFileTT(t, "common/NotoSansArabic.ttf")
+	runes := []rune{0x0633, 0x064F, 0x0644, 0x064E, 0x0651, 0x0627, 0x0651, 0x0650, 0x0645, 0x062A, 0x06CC}
+
+	buffer := NewBuffer()
+	tu.Assert(t, buffer.Positions() == nil)
+	buffer.AddRunes(runes, 0, -1)
+	buffer.GuessSegmentProperties()
+	buffer.Shape(NewFont(font.NewFace(ft)), nil)
+
+	pos := buffer.Positions()
+	tu.Assert(t, len(pos) == len(buffer.Info))
+	original := append([]GlyphPosition(nil), pos...)
+
+	// absolute drawing positions, in visual order
+	absolute := func(pos []GlyphPosition) []Position {
+		out := make([]Position, len(pos))
+		var pen Position
+		for i, p := range pos {
+			out[i] = pen + p.XOffset
+			pen += p.XAdvance
+		}
+		return out
+	}
+
+	// no-op
+	buffer.AdjustPositions(func(pos []GlyphPosition) {})
+	tu.Assert(t, reflect.DeepEqual(pos, original))
+
+	// add tracking to every glyph, and raise the base glyphs
+	buffer.AdjustPositions(func(pos []GlyphPosition) {
+		for i := range pos {
+			pos[i].XAdvance += 200
+			if pos[i].attachChain == 0 {
+				pos[i].YOffset += 50
+			}
+		}
+	})
+
+	before, after := absolute(original), absolute(pos)
+	attached := 0
+	for i, p := range pos {
+		j, ok := attachmentParent(pos, i)
+		if !ok || p.attachType&attachTypeMark == 0 {
+			continue
+		}
+		attached++
+		// marks are moved with their base
+		tu.Assert(t, after[i]-after[j] == before[i]-before[j])
+		tu.Assert(t, p.YOffset == original[i].YOffset+50)
+	}
+	tu.Assert(t, attached > 0)
+}
+
+func TestAttachment(t *testing.T) {
+	hbFont := NewFont(font.NewFace(openFontFileTT(t, "common/NotoSansArabic.ttf")))
+	// the damma is stacked on the fathatan
+	runes := []rune{0x0633, 0x064F, 0x0644, 0x064E, 0x0651, 0x0627, 0x0020, 0x0628, 0x064B, 0x064F}
+
+	buffer := NewBuffer()
+	buffer.AddRunes(runes, 0, -1)
+	parent, kind := buffer.Attachment(0)
+	tu.Assert(t, parent == -1 && kind == AttachmentNone)
+
+	checkMarks := func(start, end int) {
+		marks, stacked := 0, 0
+		for i := start; i < end; i++ {
+			parent, kind := buffer.Attachment(i)
+			if !buffer.Info[i].isMark() {
+				tu.Assert(t, kind == AttachmentNone && parent == -1)
+				continue
+			}
+			marks++
+			tu.Assert(t, kind == AttachmentMark)
+			tu.Assert(t, start <= parent && parent < end)
+			if buffer.Info[parent].isMark() {
+				stacked++
+				parent, kind = buffer.Attachment(parent)
+				tu.Assert(t, kind == AttachmentMark && !buffer.Info[parent].isMark())
+			}
+			// marks are attached to a base of their cluster
+			tu.Assert(t, buffer.Info[parent].Cluster == buffer.Info[i].Cluster)
+		}
+		tu.Assert(t, marks == 4 && stacked == 1)
+	}
+
+	buffer.GuessSegmentProperties()
+	buffer.Shape(hbFont, nil)
+	checkMarks(0, len(buffer.Info))
+	tu.Assert(t, buffer.Props.Direction == RightToLeft)
+
+	// out of range
+	parent, kind = buffer.Attachment(len(buffer.Info))
+	tu.Assert(t, parent == -1 && kind == AttachmentNone)
+
+	// the attachments are preserved when shaping several runs
+	text := append([]rune("ab "), runes...)
+	levels := make([]uint8, len(text))
+	for i := 3; i < len(levels); i++ {
+		levels[i] = 1
+	}
+	buffer.Clear()
+	buffer.AddRunes(text, 0, -1)
+	buffer.SetBidiLevels(levels)
+	runs := buffer.ShapeRuns(hbFont, nil)
+	tu.Assert(t, len(runs) == 2)
+	checkMarks(runs[1].Start, runs[1].End)
+}
+
+func TestRemapGlyphs(t *testing.T) {
+	buffer := NewBuffer()
+	buffer.AddRunes([]rune("fia fia"), 0, -1)
+	buffer.GuessSegmentProperties()
+	buffer.Flags = ProduceUnsafeToConcat
+	buffer.Shape(NewFont(font.NewFace(openFontFileTT(t, "common/Roboto-BoldItalic.ttf"))), nil)
+	tu.Assert(t, buffer.Info[0].Glyph == 1831) // ligature
+	info := append([]GlyphInfo(nil), buffer.Info...)
+	pos := append([]GlyphPosition(nil), buffer.Pos...)
+
+	mapping := map[GID]GID{1831: 1, 70: 2}
+	err := buffer.RemapGlyphs(mapping)
+	tu.Assert(t, err != nil) // the space is missing
+	tu.Assert(t, reflect.DeepEqual(buffer.Info, info))
+
+	mapping[buffer.Info[2].Glyph] = 3
+	tu.AssertNoErr(t, buffer.RemapGlyphs(mapping))
+	for i, inf := range buffer.Info {
+		tu.Assert(t, inf.Glyph == mapping[info[i].Glyph])
+		tu.Assert(t, inf.Cluster == info[i].Cluster && inf.Mask == info[i].Mask)
+	}
+	tu.Assert(t, reflect.DeepEqual(buffer.Pos, pos))
+}
+
+func TestSafeBreaks(t *testing.T) {
+	shape := func(filename, text string) *Buffer {
+		buffer := NewBuffer()
+		buffer.AddRunes([]rune(text), 0, -1)
+		buffer.GuessSegmentProperties()
+		buffer.Shape(NewFont(font.NewFace(openFontFileTT(t, filename))), nil)
+		return buffer
+	}
+
+	// joined Arabic letters are never safe to break
+	buffer := shape("common/NotoSansArabic.ttf", "سلام عليكم")
+	tu.Assert(t, buffer.Props.Direction == RightToLeft)
+	tu.Assert(t, reflect.DeepEqual(buffer.SafeBreaks(nil), []int{3, 4, 5}))
+	// restrict to the line breaking opportunities
+	tu.Assert(t, reflect.DeepEqual(buffer.SafeBreaks([]int{5}), []int{5}))
+	tu.Assert(t, len(buffer.SafeBreaks([]int{})) == 0)
+
+	// kerning and ligatures
+	buffer = shape("common/Raleway-v4020-Regular.otf", "AVAT fit")
+	for _, info := range buffer.Info {
+		if info.Cluster == 1 || info.Cluster == 4 {
+			tu.Assert(t, info.Mask&GlyphUnsafeToBreak != 0)
+		}
+	}
+	tu.Assert(t, reflect.DeepEqual(buffer.SafeBreaks(nil), []int{5, 7}))
+	// 6 is inside the 'fi' ligature
+	tu.Assert(t, reflect.DeepEqual(buffer.SafeBreaks([]int{4, 5, 6}), []int{5}))
+
+	tu.Assert(t, NewBuffer().SafeBreaks(nil) == nil)
+}
+
+func TestFractionalPositions(t *testing.T) {
+	face := font.NewFace(openFontFileTT(t, "common/Raleway-v4020-Regular.otf"))
+	hbFont := NewFont(face)
+	upem := float32(face.Upem())
+
+	shape := func() *Buffer {
+		buffer := NewBuffer()
+		buffer.AddRunes([]rune("Hello AVA world"), 0, -1)
+		buffer.GuessSegmentProperties()
+		buffer.Shape(hbFont, nil)
+		return buffer
+	}
+
+	tu.Assert(t, NewBuffer().FractionalPositions(hbFont) == nil)
+
+	// default scale : same as the integer positions
+	buffer := shape()
+	xScale, yScale := hbFont.FractionalScale()
+	tu.Assert(t, xScale == upem && yScale == upem)
+	for i, pos := range buffer.FractionalPositions(hbFont) {
+		tu.Assert(t, pos.XAdvance == float32(buffer.Pos[i].XAdvance) && pos.XOffset == float32(buffer.Pos[i].XOffset))
+	}
+	unscaled := append([]GlyphPosition(nil), buffer.Pos...)
+
+	hbFont.SetFractionalScale(10.5, 10.5)
+	tu.Assert(t, hbFont.XScale == int32(upem) && hbFont.YScale == int32(upem))
+	buffer = shape()
+	var total float32
+	for i, pos := range buffer.FractionalPositions(hbFont) {
+		expected := float32(unscaled[i].XAdvance) * 10.5 / upem
+		tu.Assert(t, math.Abs(float64(pos.XAdvance-expected)) < 1e-4)
+		total += pos.XAdvance
+	}
+
+	// integer positions accumulate rounding errors
+	hbFont.XScale, hbFont.YScale = 10, 10
+	buffer = shape()
+	var totalInt Position
+	for _, pos := range buffer.Pos {
+		totalInt += pos.XAdvance
+	}
+	tu.Assert(t, float32(totalInt) != total)
+}
+
+func TestClusterLevels(t *testing.T) {
+	hbFont := NewFont(font.NewFace(openFontFileTT(t, "common/Raleway-v4020-Regular.otf")))
+
+	shape := func(level ClusterLevel, text string, dir Direction) []int {
+		buffer := NewBuffer()
+		buffer.ClusterLevel = level
+		buffer.AddRunes([]rune(text), 0, -1)
+		buffer.GuessSegmentProperties()
+		buffer.Props.Direction = dir
+		buffer.Shape(hbFont, nil)
+		var clusters []int
+		for _, info := range buffer.Info {
+			clusters = append(clusters, info.Cluster)
+		}
+		return clusters
+	}
+
+	// x and the combining acute accent do not compose
+	text := "x\u0301a"
+	for _, test := range []struct {
+		level    ClusterLevel
+		dir      Direction
+		expected []int
+	}{
+		{MonotoneGraphemes, LeftToRight, []int{0, 0, 2}},
+		{MonotoneCharacters, LeftToRight, []int{0, 1, 2}},
+		{Characters, LeftToRight, []int{0, 1, 2}},
+		// non native direction : the graphemes are reversed
+		{MonotoneGraphemes, RightToLeft, []int{2, 0, 0}},
+		{MonotoneCharacters, RightToLeft, []int{2, 0, 0}},
+		{Characters, RightToLeft, []int{2, 0, 1}},
+	} {
+		got := shape(test.level, text, test.dir)
+		tu.AssertC(t, reflect.DeepEqual(got, test.expected), fmt.Sprintf("%s %d: %v", test.level, test.dir, got))
+	}
+
+	// ligatures
+	tu.Assert(t, reflect.DeepEqual(shape(MonotoneCharacters, "fi", LeftToRight), []int{0}))
+	tu.Assert(t, reflect.DeepEqual(shape(Characters, "fi", LeftToRight), []int{0}))
+}
+
+func TestRandomSeed(t *testing.T) {
+	hbFont := NewFont(font.NewFace(openFontFile(t, "harfbuzz_reference/in-house/fonts/5bb74492f5e0ffa1fbb72e4c881be035120b6513.ttf")))
+	text := []rune("TUVTUVTUVTUV")
+
+	shape := func(buf *Buffer) []GID {
+		buf.AddRunes(text, 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(hbFont, nil)
+		out := make([]GID, len(buf.Info))
+		for i, info := range buf.Info {
+			out[i] = info.Glyph
+		}
+		return out
+	}
+	withSeed := func(seed uint32) []GID {
+		buf := NewBuffer()
+		buf.SetRandomSeed(seed)
+		return shape(buf)
+	}
+
+	// see harfbuzz_reference/in-house/tests/rand.tests
+	expected := []GID{5, 7, 10, 4, 7, 10, 6, 9, 10, 5, 8, 12}
+	tu.Assert(t, reflect.DeepEqual(withSeed(0), expected))
+	tu.Assert(t, reflect.DeepEqual(withSeed(1), expected))
+
+	// the seed changes the alternates, deterministically
+	other := withSeed(2024)
+	tu.Assert(t, !reflect.DeepEqual(other, expected))
+	tu.Assert(t, reflect.DeepEqual(withSeed(2024), other))
+
+	// the seed is reset by Clear
+	buf := NewBuffer()
+	buf.SetRandomSeed(2024)
+	buf.Clear()
+	tu.Assert(t, reflect.DeepEqual(shape(buf), expected))
+}
+
+func TestGuessSegmentProperties(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_CTYPE", "")
+	t.Setenv("LANG", "fa_IR.UTF-8")
+
+	for _, test := range []struct {
+		text     string
+		expected SegmentProperties
+	}{
+		{"سلام", SegmentProperties{Script: language.Arabic, Direction: RightToLeft, Language: "fa-ir"}},
+		{"123 abc", SegmentProperties{Script: language.Latin, Direction: LeftToRight, Language: "fa-ir"}},
+		{"123", SegmentProperties{Direction: LeftToRight, Language: "fa-ir"}},
+		{"ᚠᛇᚻ", SegmentProperties{Script: language.Runic, Direction: LeftToRight, Language: "fa-ir"}},
+	} {
+		buffer := NewBuffer()
+		buffer.AddRunes([]rune(test.text), 0, -1)
+		buffer.GuessSegmentProperties()
+		tu.AssertC(t, buffer.Props == test.expected, test.text)
+	}
+
+	// properties already set are not modified
+	buffer := NewBuffer()
+	buffer.AddRunes([]rune("سلام"), 0, -1)
+	buffer.Props.Direction = TopToBottom
+	buffer.Props.Language = "ar"
+	buffer.GuessSegmentProperties()
+	tu.Assert(t, buffer.Props == SegmentProperties{Script: language.Arabic, Direction: TopToBottom, Language: "ar"})
+}
+
+func TestShapeUnsetProperties(t *testing.T) {
+	ft := NewFont(font.NewFace(openFontFileTT(t, "common/NotoSansArabic.ttf")))
+	runes := []rune("سلام")
+
+	expected := NewBuffer()
+	expected.AddRunes(runes, 0, -1)
+	expected.Props = SegmentProperties{Script: language.Arabic, Direction: RightToLeft}
+	expected.Shape(ft, nil)
+
+	// the script and direction are guessed, but not the language
+	for _, props := range []SegmentProperties{
+		{},
+		{Script: language.Arabic},
+		{Direction: RightToLeft},
+		{Direction: 3}, // invalid
+	} {
+		buffer := NewBuffer()
+		buffer.AddRunes(runes, 0, -1)
+		buffer.Props = props
+		buffer.Shape(ft, nil)
+		tu.Assert(t, buffer.Props == expected.Props)
+		tu.Assert(t, reflect.DeepEqual(buffer.Info, expected.Info))
+		tu.Assert(t, reflect.DeepEqual(buffer.Pos, expected.Pos))
+	}
+}
+
+func TestAddUTF(t *testing.T) {
+	text := "a\u00E9\u4E2D\U0001F600b"
+	runes := []rune(text)
+
+	b := NewBuffer()
+	b.AddUTF8([]byte(text), 1, 9) // skip 'a' and 'b'
+	tu.Assert(t, len(b.Info) == 3)
+	for i, cluster := range []int{1, 3, 6} {
+		tu.Assert(t, b.Info[i].codepoint == runes[i+1] && b.Info[i].Cluster == cluster)
+	}
+	tu.Assert(t, reflect.DeepEqual(b.context[0], []rune{'a'}))
+	tu.Assert(t, reflect.DeepEqual(b.context[1], []rune{'b'}))
+
+	utf16Text := utf16.Encode(runes)
+	b = NewBuffer()
+	b.AddUTF16(utf16Text, 1, 4)
+	tu.Assert(t, len(b.Info) == 3)
+	for i, cluster := range []int{1, 2, 3} {
+		tu.Assert(t, b.Info[i].codepoint == runes[i+1] && b.Info[i].Cluster == cluster)
+	}
+	tu.Assert(t, reflect.DeepEqual(b.context[0], []rune{'a'}))
+	tu.Assert(t, reflect.DeepEqual(b.context[1], []rune{'b'}))
+
+	// the whole text, with context limited to 5 runes
+	b = NewBuffer()
+	b.AddUTF16(utf16Text, 0, -1)
+	tu.Assert(t, len(b.Info) == 5 && b.Info[4].Cluster == 5)
+	b = NewBuffer()
+	long := []byte("0123456789")
+	b.AddUTF8(long, 7, 1)
+	tu.Assert(t, reflect.DeepEqual(b.context[0], []rune("65432")))
+	tu.Assert(t, reflect.DeepEqual(b.context[1], []rune("89")))
+
+	// invalid input
+	b = NewBuffer()
+	b.AddUTF8([]byte{'a', 0xC3, 0xFF, 'b'}, 0, -1)
+	tu.Assert(t, len(b.Info) == 4 && b.Info[1].codepoint == utf8.RuneError && b.Info[2].codepoint == utf8.RuneError)
+	tu.Assert(t, b.Info[3].Cluster == 3)
+	b = NewBuffer()
+	b.AddUTF16([]uint16{'a', 0xDE00, 0xD83D, 'b'}, 0, 3)
+	tu.Assert(t, len(b.Info) == 3 && b.Info[1].codepoint == utf8.RuneError && b.Info[2].codepoint == utf8.RuneError)
+	tu.Assert(t, b.Info[2].Cluster == 2)
+
+	// AddRunes slice is not modified by the post-context
+	input := []rune("abc")
+	b = NewBuffer()
+	b.AddRunes(input, 0, 1)
+	b.AddUTF8([]byte("xyz"), 0, 1)
+	tu.Assert(t, string(input) == "abc" && reflect.DeepEqual(b.context[1], []rune("yz")))
+
+	// shaping gives the same glyphs as AddRunes
+	font := NewFont(font.NewFace(openFontFileTT(t, "common/NotoSansArabic.ttf")))
+	arabic := "\u0633\u064F\u0644\u064E\u0651\u0627"
+	b1, b2 := NewBuffer(), NewBuffer()
+	b1.AddRunes([]rune(arabic), 0, -1)
+	b2.AddUTF8([]byte(arabic), 0, -1)
+	for _, b := range []*Buffer{b1, b2} {
+		b.GuessSegmentProperties()
+		b.Shape(font, nil)
+	}
+	tu.Assert(t, len(b1.Info) == len(b2.Info))
+	for i := range b1.Info {
+		tu.Assert(t, b1.Info[i].Glyph == b2.Info[i].Glyph && b1.Pos[i] == b2.Pos[i])
+		tu.Assert(t, b2.Info[i].Cluster == 2*b1.Info[i].Cluster) // 2 bytes runes
+	}
+}
+
+func TestGraphemeIterator(t *testing.T) {
+	collect := func(iter *GraphemeIterator) (out [][2]int) {
+		for iter.Next() {
+			start, end := iter.Grapheme()
+			out = append(out, [2]int{start, end})
+		}
+		return out
+	}
+
+	// e + acute, family emoji (ZWJ sequence), skin tone modifier, two flags, tag sequence
+	text := []rune("e\u0301a\U0001F468\u200D\U0001F469\u200D\U0001F467\U0001F44D\U0001F3FD\U0001F1EB\U0001F1F7\U0001F1E9\U0001F1EA\U0001F3F4\U000E0067\U000E0062\U000E007F")
+	expected := [][2]int{{0, 2}, {2, 3}, {3, 8}, {8, 10}, {10, 12}, {12, 14}, {14, 18}}
+	tu.Assert(t, reflect.DeepEqual(collect(NewGraphemeIterator(text)), expected))
+	tu.Assert(t, len(collect(NewGraphemeIterator(nil))) == 0)
+
+	// the graphemes of the shaped buffer match the clusters formed by the shaper
+	hbFont := NewFont(font.NewFace(openFontFileTT(t, "common/DejaVuSans.ttf")))
+	buffer := NewBuffer()
+	buffer.AddRunes(text, 0, -1)
+	buffer.GuessSegmentProperties()
+	buffer.Shape(hbFont, nil)
+	var clusters []int
+	for _, gr := range collect(buffer.GraphemeIterator()) {
+		for _, info := range buffer.Info[gr[0]:gr[1]] {
+			tu.Assert(t, info.Cluster == buffer.Info[gr[0]].Cluster)
+		}
+		clusters = append(clusters, buffer.Info[gr[0]].Cluster)
+	}
+	tu.Assert(t, reflect.DeepEqual(clusters, []int{0, 2, 3, 8, 10, 12, 14}))
+
+	// with the characters level, marks are not merged with their base
+	// but still belong to the same grapheme, also for backward text
+	text = []rune("\u0628\u064E\u062A\u0650")
+	hbFont = NewFont(font.NewFace(openFontFileTT(t, "common/NotoSansArabic.ttf")))
+	buffer = NewBuffer()
+	buffer.ClusterLevel = Characters
+	buffer.AddRunes(text, 0, -1)
+	buffer.GuessSegmentProperties()
+	buffer.Shape(hbFont, nil)
+	clusters = clusters[:0]
+	for _, gr := range collect(buffer.GraphemeIterator()) {
+		tu.Assert(t, gr[1]-gr[0] == 2)
+		clusters = append(clusters, buffer.Info[gr[1]-1].Cluster)
+	}
+	tu.Assert(t, reflect.DeepEqual(clusters, []int{2, 0}))
+}
+
+func TestGlyphAndTextRanges(t *testing.T) {
+	shape := func(file string, text string, level ClusterLevel) *Buffer {
+		buffer := NewBuffer()
+		buffer.ClusterLevel = level
+		buffer.AddRunes([]rune(text), 0, -1)
+		buffer.GuessSegmentProperties()
+		buffer.Shape(NewFont(font.NewFace(openFontFileTT(t, file))), nil)
+		return buffer
+	}
+	assertGlyphs := func(b *Buffer, textIndex, expStart, expEnd int) {
+		t.Helper()
+		start, end := b.GlyphRange(textIndex)
+		tu.AssertC(t, start == expStart && end == expEnd, fmt.Sprint(textIndex, start, end))
+	}
+	assertText := func(b *Buffer, glyphIndex, textLength, expStart, expEnd int) {
+		t.Helper()
+		start, end := b.TextRange(glyphIndex, textLength)
+		tu.AssertC(t, start == expStart && end == expEnd, fmt.Sprint(glyphIndex, start, end))
+	}
+
+	// 'fi' ligature and composed mark : o fi e + acute
+	for _, level := range []ClusterLevel{MonotoneGraphemes, Characters} {
+		b := shape("common/DejaVuSans.ttf", "ofie\u0301", level)
+		tu.Assert(t, len(b.Info) == 3)
+		assertGlyphs(b, 0, 0, 1)
+		assertGlyphs(b, 1, 1, 2)
+		assertGlyphs(b, 2, 1, 2)
+		assertGlyphs(b, 4, 2, 3)
+		assertText(b, 1, 5, 1, 3)
+		assertText(b, 2, 5, 3, 5)
+	}
+
+	// right-to-left, with marks kept apart and a lam-alef ligature
+	b := shape("common/NotoSansArabic.ttf", "\u0628\u064E\u062A\u0650 \u0644\u0627", Characters)
+	tu.Assert(t, len(b.Info) == 6)
+	assertGlyphs(b, 0, 5, 6)
+	assertGlyphs(b, 1, 4, 5)
+	assertGlyphs(b, 6, 0, 1)
+	assertText(b, 0, 7, 5, 7)
+	assertText(b, 4, 7, 1, 2)
+	assertText(b, 5, 7, 0, 1)
+
+	// Indic pre-base matra, reordered before its consonant
+	b = shape("common/FreeSerif.ttf", "\u0915\u093F\u0915", Characters)
+	tu.Assert(t, len(b.Info) == 3)
+	assertGlyphs(b, 0, 1, 2)
+	assertGlyphs(b, 1, 0, 1)
+	assertText(b, 0, 3, 1, 2)
+	assertText(b, 1, 3, 0, 1)
+	b = shape("common/FreeSerif.ttf", "\u0915\u093F\u0915", MonotoneGraphemes)
+	assertGlyphs(b, 0, 0, 2)
+	assertGlyphs(b, 1, 0, 2)
+	assertGlyphs(b, 2, 2, 3)
+	assertText(b, 0, 3, 0, 2)
+	assertText(b, 1, 3, 0, 2)
+
+	// text before the first cluster
+	b = NewBuffer()
+	b.AddRunes([]rune("abc"), 1, 2)
+	b.Shape(NewFont(font.NewFace(openFontFileTT(t, "common/DejaVuSans.ttf"))), nil)
+	assertGlyphs(b, 0, 0, 0)
+	assertGlyphs(b, 2, 1, 2)
+}
@@ -0,0 +1,177 @@
+package harfbuzz
+
+import (
+	"sort"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
+)
+
+// CapabilityFlags is a set of shaping related properties of a font.
+type CapabilityFlags uint16
+
+const (
+	// CapGSUB is set if the font has a GSUB table.
+	CapGSUB CapabilityFlags = 1 << iota
+	// CapGPOS is set if the font has a GPOS table.
+	CapGPOS
+	// CapMorx is set if the font has an AAT 'morx' table.
+	CapMorx
+	// CapKerx is set if the font has an AAT 'kerx' table.
+	CapKerx
+	// CapKern is set if the font has a 'kern' table.
+	CapKern
+	// CapTrak is set if the font has a non empty AAT 'trak' table.
+	CapTrak
+	// CapGlyphClasses is set if the font provides glyph classes in its GDEF table.
+	CapGlyphClasses
+	// CapArabicGSUB is set if the GSUB table defines one of the Arabic joining features
+	// ('isol', 'fina', 'medi', 'init' and their Syriac variants).
+	// Without it, the Arabic shaper does not request these features, except when
+	// the fallback shaping of the Arabic script is needed.
+	CapArabicGSUB
+	// CapMarkPositioning is set if the GPOS table has mark or cursive attachment lookups.
+	// Without it (and without AAT attachments), the attachments are not resolved when
+	// positioning.
+	CapMarkPositioning
+	// CapKerning is set if the font has a GPOS 'kern' feature, or a 'kern' or 'kerx' table.
+	// Without it, the 'kern' feature is not requested and the fallback kerning is skipped.
+	CapKerning
+
+	// the 'kern' table has state machine subtables
+	capKernMachine
+	// the 'kern' table has cross-stream subtables
+	capKernCrossStream
+	// the 'kern' table has vertical subtables
+	capKernVertical
+)
+
+// Capabilities summarizes what a font may provide to the shaping process.
+// It is computed once per face (see [Font.Capabilities]), and used when building
+// shaping plans to skip the work which can't possibly have an effect.
+type Capabilities struct {
+	// Scripts are the OpenType script tags supported by the GSUB and GPOS tables,
+	// sorted and without duplicates. For instance, a font with Devanagari
+	// features for the new Indic specification includes the 'dev2' tag.
+	Scripts []tables.Tag
+
+	// the feature tags of the GSUB and GPOS tables,
+	// sorted and without duplicates
+	features [2][]tables.Tag
+
+	Flags CapabilityFlags
+}
+
+// Capabilities returns the shaping capabilities of the font.
+// The returned value is shared by the fonts using the same face, and must not be modified.
+func (f *Font) Capabilities() Capabilities { return f.capabilities }
+
+// capabilitiesKey is the key used to cache the
+// capabilities with [font.Face.ShaperData]
+type capabilitiesKey struct{}
+
+func newCapabilities(ft *font.Font) Capabilities {
+	var out Capabilities
+
+	// an absent table has a nil slice of lookups
+	if ft.GSUB.Lookups != nil {
+		out.Flags |= CapGSUB
+	}
+	if ft.GPOS.Lookups != nil {
+		out.Flags |= CapGPOS
+	}
+	if len(ft.Morx) != 0 {
+		out.Flags |= CapMorx
+	}
+	if ft.Kerx != nil {
+		out.Flags |= CapKerx | CapKerning
+	}
+	if ft.Kern != nil {
+		out.Flags |= CapKern | CapKerning
+		if hasMachineKerning(ft.Kern) {
+			out.Flags |= capKernMachine
+		}
+		if hasCrossKerning(ft.Kern) {
+			out.Flags |= capKernCrossStream
+		}
+		if hasVerticalKerning(ft.Kern) {
+			out.Flags |= capKernVertical
+		}
+	}
+	if !ft.Trak.IsEmpty() {
+		out.Flags |= CapTrak
+	}
+	if ft.GDEF.GlyphClassDef != nil {
+		out.Flags |= CapGlyphClasses
+	}
+
+	layouts := [2]*font.Layout{&ft.GSUB.Layout, &ft.GPOS.Layout}
+	for tableIndex, layout := range layouts {
+		for _, script := range layout.Scripts {
+			out.Scripts = append(out.Scripts, script.Tag)
+		}
+		var features []tables.Tag
+		for _, feature := range layout.Features {
+			features = append(features, feature.Tag)
+		}
+		out.features[tableIndex] = sortedTags(features)
+	}
+	out.Scripts = sortedTags(out.Scripts)
+
+	for _, tag := range arabicFeatures {
+		if out.hasFeature(0, tag) {
+			out.Flags |= CapArabicGSUB
+		}
+	}
+
+	if out.hasFeature(1, ot.NewTag('k', 'e', 'r', 'n')) {
+		out.Flags |= CapKerning
+	}
+	for _, lookup := range ft.GPOS.Lookups {
+		for _, subtable := range lookup.Subtables {
+			switch subtable.(type) {
+			case tables.MarkBasePos, tables.MarkLigPos, tables.MarkMarkPos, tables.CursivePos:
+				out.Flags |= CapMarkPositioning
+			}
+		}
+	}
+
+	return out
+}
+
+// sortedTags sorts [tags] and removes duplicates, in place
+func sortedTags(tags []tables.Tag) []tables.Tag {
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+	j := 0
+	for i, tag := range tags {
+		if i == 0 || tag != tags[j-1] {
+			tags[j] = tag
+			j++
+		}
+	}
+	return tags[:j]
+}
+
+func containsTag(tags []tables.Tag, tag tables.Tag) bool {
+	i := sort.Search(len(tags), func(i int) bool { return tags[i] >= tag })
+	return i < len(tags) && tags[i] == tag
+}
+
+// Has returns true if all the given [flags] are set.
+func (c Capabilities) Has(flags CapabilityFlags) bool { return c.Flags&flags == flags }
+
+// HasScript returns true if the GSUB or GPOS table supports the OpenType script [tag].
+func (c Capabilities) HasScript(tag tables.Tag) bool { return containsTag(c.Scripts, tag) }
+
+// HasFeature returns true if the GSUB or GPOS table defines the feature [tag],
+// for any script.
+func (c Capabilities) HasFeature(tag tables.Tag) bool {
+	return c.hasFeature(0, tag) || c.hasFeature(1, tag)
+}
+
+// hasFeature returns true if the feature is defined in the GSUB (tableIndex = 0)
+// or GPOS (tableIndex = 1) table.
+func (c Capabilities) hasFeature(tableIndex int, tag tables.Tag) bool {
+	return containsTag(c.features[tableIndex], tag)
+}
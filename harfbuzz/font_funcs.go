@@ -0,0 +1,88 @@
+package harfbuzz
+
+// FontFuncs provides the glyph metrics used when shaping with a [Font].
+//
+// The default implementation, [DefaultFontFuncs], uses the tables
+// of the font face. Custom implementations may be installed with [Font.SetFuncs],
+// for instance to use hinted metrics provided by a rasterizer.
+//
+// All the values are expressed in the scale of the font (see [Font.XScale] and [Font.YScale]).
+type FontFuncs interface {
+	// GlyphHAdvance returns the advance of [glyph], for horizontal text.
+	GlyphHAdvance(font *Font, glyph GID) Position
+	// GlyphVAdvance returns the advance of [glyph], for vertical text.
+	// It is typically negative.
+	GlyphVAdvance(font *Font, glyph GID) Position
+	// GlyphHOrigin returns the origin of [glyph] used for horizontal text,
+	// relative to the origin of the glyph outline, or false if not defined.
+	GlyphHOrigin(font *Font, glyph GID) (x, y Position, ok bool)
+	// GlyphVOrigin returns the origin of [glyph] used for vertical text,
+	// relative to the origin of the glyph outline, or false if not defined.
+	GlyphVOrigin(font *Font, glyph GID) (x, y Position, ok bool)
+	// GlyphContourPoint returns the position of the point at [pointIndex]
+	// in the outline of [glyph], or false if not found.
+	GlyphContourPoint(font *Font, glyph GID, pointIndex uint16) (x, y Position, ok bool)
+}
+
+var _ FontFuncs = DefaultFontFuncs{}
+
+// DefaultFontFuncs implements [FontFuncs] using the tables of the font face.
+//
+// It may be embedded in custom implementations which only override some
+// of the methods.
+type DefaultFontFuncs struct{}
+
+// GlyphHAdvance implements [FontFuncs].
+func (DefaultFontFuncs) GlyphHAdvance(f *Font, glyph GID) Position {
+	adv := f.face.HorizontalAdvance(glyph)
+	return f.emScalefX(adv)
+}
+
+// GlyphVAdvance implements [FontFuncs].
+func (DefaultFontFuncs) GlyphVAdvance(f *Font, glyph GID) Position {
+	if f.face.HasVerticalMetrics() {
+		adv := f.face.VerticalAdvance(glyph)
+		return f.emScalefY(adv)
+	}
+	fontExtents := f.fontHExtentsWithFallback()
+	return Position(-(fontExtents.Ascender - fontExtents.Descender))
+}
+
+// GlyphHOrigin implements [FontFuncs].
+func (DefaultFontFuncs) GlyphHOrigin(f *Font, glyph GID) (x, y Position, ok bool) {
+	ux, uy, ok := f.face.GlyphHOrigin(glyph)
+	return f.emScalefX(float32(ux)), f.emScalefY(float32(uy)), ok
+}
+
+// GlyphVOrigin implements [FontFuncs].
+func (DefaultFontFuncs) GlyphVOrigin(f *Font, glyph GID) (x, y Position, ok bool) {
+	ux, uy, ok := f.face.GlyphVOrigin(glyph)
+	return f.emScalefX(float32(ux)), f.emScalefY(float32(uy)), ok
+}
+
+// GlyphContourPoint implements [FontFuncs].
+func (DefaultFontFuncs) GlyphContourPoint(f *Font, glyph GID, pointIndex uint16) (x, y Position, ok bool) {
+	ux, uy, ok := f.face.GetGlyphContourPoint(glyph, pointIndex)
+	if !ok {
+		return 0, 0, false
+	}
+	return f.emScalefX(float32(ux)), f.emScalefY(float32(uy)), true
+}
+
+// SetFuncs installs custom glyph metrics, used instead of the ones
+// provided by the font tables. Passing nil restores [DefaultFontFuncs].
+//
+// Since the shaping results depend on [funcs], a [Font] using custom
+// functions should not be shared with code expecting the default metrics.
+func (f *Font) SetFuncs(funcs FontFuncs) {
+	f.funcs = funcs
+	f.face.BumpGeneration()
+}
+
+// Funcs returns the glyph metrics used by the font.
+func (f *Font) Funcs() FontFuncs {
+	if f.funcs == nil {
+		return DefaultFontFuncs{}
+	}
+	return f.funcs
+}
@@ -2,7 +2,9 @@ package harfbuzz
 
 import (
 	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
 	"github.com/boxesandglue/typesetting/font/opentype/tables"
+	"github.com/boxesandglue/typesetting/language"
 )
 
 // ported from src/hb-font.hh, src/hb-font.cc  Copyright © 2009  Red Hat, Inc., 2012  Google, Inc.  Behdad Esfahbod
@@ -14,17 +16,38 @@ type Face = *font.Face
 // Font are constructed with `NewFont` and adjusted by accessing the fields
 // Ptem, XScale, YScale.
 //
-// Fonts private fields only depend on the provided [*font.Font], so a Font object is suitable for caching.
+// Apart from the tracking settings (see [Font.SetTracking]), the ppem (see [Font.SetPpem]) and
+// the custom glyph metrics (see [Font.SetFuncs]), fonts private fields only depend on the provided [*font.Font],
+// so a Font object is suitable for caching.
 type Font struct {
 	face Face
 
 	gsubAccels, gposAccels []otLayoutLookupAccelerator // accelators for lookup
+	digestStrategy         DigestStrategy              // used to build the accelerators
 	faceUpem               int32                       // cached value of Face.Upem()
+	capabilities           Capabilities                // shared by the fonts of the same face
 
 	// Point size of the font. Set to zero to unset.
-	// This is used in AAT layout, when applying 'trak' table.
+	// This is used in AAT layout, when applying 'trak' table,
+	// and to select the optical size of variable fonts (see [Font.SetOpticalSizing]).
 	Ptem float32
 
+	// pixels per em overriding the face ones, see SetPpem
+	xPpem, yPpem uint16
+
+	// the 'trak' track to apply, see SetTracking
+	track         float32
+	trackDisabled bool
+
+	opticalSizingDisabled bool // see SetOpticalSizing
+
+	funcs FontFuncs // optional, see SetFuncs
+
+	synthetic synthetic // see SetSyntheticSlant and SetSyntheticBold
+
+	// scale of the fractional positions, see SetFractionalScale
+	fracXScale, fracYScale float32
+
 	// Horizontal and vertical scale of the font.
 	//
 	// The font scale is a number related to, but not the same as,
@@ -43,7 +66,7 @@ type Font struct {
 	// you. It might be 20 pixels, or 20 points, or 20 millimeters.
 	// HarfBuzz does not care about that. You can set the point
 	// size of the font using [Ptem], and the pixel
-	// size using [Face.Ppem]
+	// size using [Font.SetPpem]
 	//
 	// The choice of scale is yours but needs to be consistent between
 	// what you set here, and what you expect out of [Position]
@@ -60,6 +83,99 @@ type Font struct {
 	XScale, YScale int32
 }
 
+// SetTracking selects the track of the AAT 'trak' table applied when shaping,
+// and enables tracking if it was disabled by [Font.DisableTracking].
+// By convention, 0 is the normal track (the default), negative values select tighter
+// tracks and positive values looser ones. If [track] is not defined
+// by the font, no tracking is applied.
+//
+// Note that tracking also requires [Font.Ptem] to be set, and may be disabled
+// for some text by the 'trak' feature.
+func (f *Font) SetTracking(track float32) {
+	f.track = track
+	f.trackDisabled = false
+	f.face.BumpGeneration()
+}
+
+// DisableTracking disables the application of the 'trak' table,
+// until the next call to [Font.SetTracking].
+func (f *Font) DisableTracking() {
+	f.trackDisabled = true
+	f.face.BumpGeneration()
+}
+
+// Tracking returns the track selected by [Font.SetTracking], and
+// false if tracking is disabled.
+func (f *Font) Tracking() (float32, bool) { return f.track, !f.trackDisabled }
+
+// SetOpticalSizing enables or disables the automatic optical sizing (enabled by default) :
+// when [Font.Ptem] is set and the font has an 'opsz' variation axis, [Buffer.Shape]
+// sets the coordinate of this axis to [Font.Ptem], keeping the other coordinates unchanged.
+// See [font.Face.SetOpticalSize] for details.
+//
+// Since the coordinates are stored in the face, this
+// overrides any 'opsz' value previously set by the caller. Disable optical sizing to
+// select the optical size explicitly.
+func (f *Font) SetOpticalSizing(enabled bool) {
+	f.opticalSizingDisabled = !enabled
+	f.face.BumpGeneration()
+}
+
+// OpticalSizing returns true if the automatic optical sizing is enabled.
+func (f *Font) OpticalSizing() bool { return !f.opticalSizingDisabled }
+
+// applyOpticalSize sets the 'opsz' axis from [Font.Ptem], if enabled.
+func (f *Font) applyOpticalSize() {
+	if f.Ptem > 0 && !f.opticalSizingDisabled {
+		f.face.SetOpticalSize(f.Ptem)
+	}
+}
+
+// SetPpem selects the horizontal and vertical pixels-per-em (ppem) used when shaping with [f],
+// overriding the ones of its face (see [font.Face.SetPpem]), so that fonts of different
+// sizes may share the same face. Passing 0, 0 restores the ppem of the face.
+//
+// The ppem enables the hinting adjustments of the GPOS and GDEF device tables,
+// and the anchors positioned on contour points, which are designed for screen rendering
+// at these exact sizes : the ppem is typically the pixel size of the font, rounded to the nearest integer,
+// while [Font.XScale] and [Font.YScale] keep the fractional size.
+// The adjustments, expressed in pixels, are then scaled to the font scale.
+func (f *Font) SetPpem(xPpem, yPpem uint16) {
+	f.xPpem, f.yPpem = xPpem, yPpem
+	f.face.BumpGeneration()
+}
+
+// Ppem returns the pixels-per-em used when shaping, as set by [Font.SetPpem],
+// or the ones of the face otherwise.
+func (f *Font) Ppem() (xPpem, yPpem uint16) {
+	if f.xPpem == 0 && f.yPpem == 0 {
+		return f.face.Ppem()
+	}
+	return f.xPpem, f.yPpem
+}
+
+// SetFractionalScale selects the scale of the positions returned by [Buffer.FractionalPositions],
+// typically the font size (in pixels or points) and sets [Font.XScale] and [Font.YScale]
+// to the face Upem, so that shaping is done in font units.
+//
+// This avoids the rounding of the intermediate positions to integers, which is
+// useful for renderers doing sub-pixel layout : the results are only scaled, without rounding,
+// by [Buffer.FractionalPositions].
+func (f *Font) SetFractionalScale(xScale, yScale float32) {
+	f.fracXScale, f.fracYScale = xScale, yScale
+	f.XScale, f.YScale = f.faceUpem, f.faceUpem
+	f.face.BumpGeneration()
+}
+
+// FractionalScale returns the scale set by [Font.SetFractionalScale], or
+// [Font.XScale] and [Font.YScale] if it has not been called.
+func (f *Font) FractionalScale() (xScale, yScale float32) {
+	if f.fracXScale == 0 && f.fracYScale == 0 {
+		return float32(f.XScale), float32(f.YScale)
+	}
+	return f.fracXScale, f.fracYScale
+}
+
 // NewFont constructs a new font object from the specified face.
 //
 // The scale is set to the face Upem, meaning that by default
@@ -74,19 +190,41 @@ func NewFont(face Face) *Font {
 	font.XScale = font.faceUpem
 	font.YScale = font.faceUpem
 
-	// accelerators
-	font.gsubAccels = make([]otLayoutLookupAccelerator, len(face.GSUB.Lookups))
-	for i, l := range face.GSUB.Lookups {
-		font.gsubAccels[i].init(lookupGSUB(l))
-	}
-	font.gposAccels = make([]otLayoutLookupAccelerator, len(face.GPOS.Lookups))
-	for i, l := range face.GPOS.Lookups {
-		font.gposAccels[i].init(lookupGPOS(l))
-	}
+	font.loadAccelerators()
+	font.capabilities = face.ShaperData(capabilitiesKey{}, func() any {
+		return newCapabilities(face.Font)
+	}).(Capabilities)
 
 	return &font
 }
 
+// loadAccelerators builds (or reuses) the lookup accelerators
+// for the digest strategy of the font.
+func (f *Font) loadAccelerators() {
+	strategy := f.digestStrategy
+	// accelerators, shared by the fonts of the same face
+	accels := f.face.ShaperData(layoutAcceleratorsKey{strategy}, func() any {
+		return newLayoutAccelerators(f.face.Font, strategy)
+	}).(layoutAccelerators)
+	f.gsubAccels, f.gposAccels = accels.gsub, accels.gpos
+}
+
+// SetDigestStrategy selects the filters used to skip the lookups which
+// can't apply to the text being shaped. See [DigestStrategy] for the available choices;
+// the default is [DigestAccurate].
+//
+// The lookup accelerators are shared by the fonts using the same face and strategy.
+func (f *Font) SetDigestStrategy(strategy DigestStrategy) {
+	if strategy == f.digestStrategy {
+		return
+	}
+	f.digestStrategy = strategy
+	f.loadAccelerators()
+}
+
+// DigestStrategy returns the strategy selected by [Font.SetDigestStrategy].
+func (f *Font) DigestStrategy() DigestStrategy { return f.digestStrategy }
+
 // SetVarCoordsDesign applies a list of variation coordinates, in design-space units,
 // to the font.
 func (f *Font) SetVarCoordsDesign(coords []float32) {
@@ -142,6 +280,7 @@ func (f *Font) GlyphExtents(glyph GID) (out GlyphExtents, ok bool) {
 	out.Width = f.emScalefX(ext.Width)
 	out.YBearing = f.emScalefY(ext.YBearing)
 	out.Height = f.emScalefY(ext.Height)
+	f.syntheticGlyphExtents(&out)
 	return out, true
 }
 
@@ -160,21 +299,13 @@ func (f *Font) GlyphAdvanceForDirection(glyph GID, dir Direction) (x, y Position
 // GlyphHAdvance fetches the advance for a glyph ID in the font,
 // for horizontal text segments.
 func (f *Font) GlyphHAdvance(glyph GID) Position {
-	adv := f.face.HorizontalAdvance(glyph)
-	return f.emScalefX(adv)
+	return f.emboldenAdvance(f.Funcs().GlyphHAdvance(f, glyph), true)
 }
 
 // Fetches the advance for a glyph ID in the font,
 // for vertical text segments.
 func (f *Font) getGlyphVAdvance(glyph GID) Position {
-	if f.face.HasVerticalMetrics() {
-		adv := f.face.VerticalAdvance(glyph)
-		return f.emScalefY(adv)
-	} else {
-		fontExtents := f.fontHExtentsWithFallback()
-		advance := Position(-(fontExtents.Ascender - fontExtents.Descender))
-		return advance
-	}
+	return f.emboldenAdvance(f.Funcs().GlyphVAdvance(f, glyph), false)
 }
 
 // Subtracts the origin coordinates from an (X,Y) point coordinate,
@@ -203,29 +334,29 @@ func (f *Font) getGlyphOriginForDirection(glyph GID, direction Direction) (x, y
 }
 
 func (f *Font) getGlyphHOriginWithFallback(glyph GID) (Position, Position) {
-	x, y, ok := f.face.GlyphHOrigin(glyph)
+	funcs := f.Funcs()
+	x, y, ok := funcs.GlyphHOrigin(f, glyph)
 	if !ok {
-		x, y, ok = f.face.GlyphVOrigin(glyph)
+		x, y, ok = funcs.GlyphVOrigin(f, glyph)
 		if ok {
-			x, y := f.emScalefX(float32(x)), f.emScalefY(float32(y))
 			dx, dy := f.guessVOriginMinusHOrigin(glyph)
 			return x - dx, y - dy
 		}
 	}
-	return f.emScalefX(float32(x)), f.emScalefY(float32(y))
+	return x, y
 }
 
 func (f *Font) getGlyphVOriginWithFallback(glyph GID) (Position, Position) {
-	x, y, ok := f.face.GlyphVOrigin(glyph)
+	funcs := f.Funcs()
+	x, y, ok := funcs.GlyphVOrigin(f, glyph)
 	if !ok {
-		x, y, ok = f.face.GlyphHOrigin(glyph)
+		x, y, ok = funcs.GlyphHOrigin(f, glyph)
 		if ok {
-			x, y := f.emScalefX(float32(x)), f.emScalefY(float32(y))
 			dx, dy := f.guessVOriginMinusHOrigin(glyph)
 			return x + dx, y + dy
 		}
 	}
-	return f.emScalefX(float32(x)), f.emScalefY(float32(y))
+	return x, y
 }
 
 func (f *Font) guessVOriginMinusHOrigin(glyph GID) (x, y Position) {
@@ -263,7 +394,7 @@ func (f *Font) addGlyphHOrigin(glyph GID, x, y Position) (Position, Position) {
 }
 
 func (f *Font) getGlyphContourPointForOrigin(glyph GID, pointIndex uint16, direction Direction) (x, y Position, ok bool) {
-	x, y, ok = f.face.GetGlyphContourPoint(glyph, pointIndex)
+	x, y, ok = f.Funcs().GlyphContourPoint(f, glyph, pointIndex)
 	if ok {
 		x, y = f.subtractGlyphOriginForDirection(glyph, direction, x, y)
 	}
@@ -315,10 +446,14 @@ func (font *Font) varCoords() []tables.Coord { return font.face.Coords() }
 func (font *Font) getXDelta(varStore tables.ItemVarStore, device tables.DeviceTable) Position {
 	switch device := device.(type) {
 	case tables.DeviceHinting:
-		xPpem, _ := font.face.Ppem()
+		xPpem, _ := font.Ppem()
 		return device.GetDelta(xPpem, font.XScale)
 	case tables.DeviceVariation:
-		return font.emScalefX(varStore.GetDelta(tables.VariationStoreIndex(device), font.varCoords()))
+		coords := font.varCoords()
+		if len(coords) == 0 { // default instance
+			return 0
+		}
+		return font.emScalefX(varStore.GetDelta(tables.VariationStoreIndex(device), coords))
 	default:
 		return 0
 	}
@@ -327,18 +462,28 @@ func (font *Font) getXDelta(varStore tables.ItemVarStore, device tables.DeviceTa
 func (font *Font) getYDelta(varStore tables.ItemVarStore, device tables.DeviceTable) Position {
 	switch device := device.(type) {
 	case tables.DeviceHinting:
-		_, yPpem := font.face.Ppem()
+		_, yPpem := font.Ppem()
 		return device.GetDelta(yPpem, font.YScale)
 	case tables.DeviceVariation:
-		return font.emScalefY(varStore.GetDelta(tables.VariationStoreIndex(device), font.varCoords()))
+		coords := font.varCoords()
+		if len(coords) == 0 { // default instance
+			return 0
+		}
+		return font.emScalefY(varStore.GetDelta(tables.VariationStoreIndex(device), coords))
 	default:
 		return 0
 	}
 }
 
-// GetOTLigatureCarets fetches a list of the caret positions defined for a ligature glyph in the GDEF
-// table of the font (or nil if not found).
-func (f *Font) GetOTLigatureCarets(direction Direction, glyph GID) []Position {
+// LigatureCarets returns the caret positions defined for the ligature [glyph]
+// in the GDEF table of the font, or nil if not found.
+// There is one position for each boundary between the components of the ligature,
+// so that text editors may place the cursor inside ligatures.
+//
+// The positions are expressed in font scaled units, along the axis given by [direction],
+// and take into account the device adjustments for the current ppem
+// and the variation deltas for the current coordinates.
+func (f *Font) LigatureCarets(glyph GID, direction Direction) []Position {
 	varStore := f.face.GDEF.ItemVarStore
 
 	list := f.face.GDEF.LigCaretList
@@ -347,7 +492,7 @@ func (f *Font) GetOTLigatureCarets(direction Direction, glyph GID) []Position {
 	}
 
 	index, ok := list.Coverage.Index(gID(glyph))
-	if !ok {
+	if !ok || index >= len(list.LigGlyphs) {
 		return nil
 	}
 
@@ -359,6 +504,71 @@ func (f *Font) GetOTLigatureCarets(direction Direction, glyph GID) []Position {
 	return out
 }
 
+// GetOTLigatureCarets fetches a list of the caret positions defined for a ligature glyph in the GDEF
+// table of the font (or nil if not found).
+//
+// Deprecated: use [Font.LigatureCarets] instead.
+func (f *Font) GetOTLigatureCarets(direction Direction, glyph GID) []Position {
+	return f.LigatureCarets(glyph, direction)
+}
+
+// Ligature describes a ligature substitution defined in the GSUB table of a font.
+type Ligature struct {
+	// Components is the sequence of glyphs replaced by the ligature,
+	// in logical order (the first element being the starting glyph).
+	Components []GID
+	// Glyph is the ligature glyph.
+	Glyph GID
+	// Feature is the feature triggering the substitution.
+	Feature ot.Tag
+}
+
+// LigaturesStartingWith lists the ligatures beginning with [glyph], defined by the
+// (LigatureSubst) lookups of [features], for the given script and language.
+// The features are used in order, and lookups shared by several features are
+// only reported for the first one. Lookup flags and context are ignored,
+// so that the returned sequences may not always be applied by the shaper.
+func (f *Font) LigaturesStartingWith(glyph GID, script language.Script, lang language.Language, features []ot.Tag) []Ligature {
+	gsub := &f.face.GSUB
+	scriptTags, languageTags := newOTTagsFromScriptAndLanguage(script, lang)
+	scriptIndex, _, _ := selectScript(&gsub.Layout, scriptTags)
+	languageIndex, _ := selectLanguage(&gsub.Layout, scriptIndex, languageTags)
+	variationsIndex := gsub.FindVariationIndex(f.varCoords())
+
+	var (
+		out  []Ligature
+		seen = map[uint16]bool{}
+	)
+	for _, tag := range features {
+		featureIndex := findFeatureForLang(&gsub.Layout, scriptIndex, languageIndex, tag)
+		for _, lookupIndex := range getFeatureLookupsWithVar(&gsub.Layout, featureIndex, variationsIndex) {
+			if seen[lookupIndex] || int(lookupIndex) >= len(gsub.Lookups) {
+				continue
+			}
+			seen[lookupIndex] = true
+			for _, subtable := range gsub.Lookups[lookupIndex].Subtables {
+				subs, ok := subtable.(tables.LigatureSubs)
+				if !ok {
+					continue
+				}
+				index, ok := subs.Coverage.Index(gID(glyph))
+				if !ok || index >= len(subs.LigatureSets) {
+					continue
+				}
+				for _, lig := range subs.LigatureSets[index].Ligatures {
+					components := make([]GID, 1+len(lig.ComponentGlyphIDs))
+					components[0] = glyph
+					for i, c := range lig.ComponentGlyphIDs {
+						components[i+1] = GID(c)
+					}
+					out = append(out, Ligature{Components: components, Glyph: GID(lig.LigatureGlyph), Feature: tag})
+				}
+			}
+		}
+	}
+	return out
+}
+
 // interpreted the CaretValue according to its format
 func (f *Font) getCaretValue(caret tables.CaretValue, direction Direction, glyph GID, varStore tables.ItemVarStore) Position {
 	switch caret := caret.(type) {
@@ -385,3 +595,54 @@ func (f *Font) getCaretValue(caret tables.CaretValue, direction Direction, glyph
 		return 0
 	}
 }
+
+const softHyphen = 0x00AD
+
+// HyphenGlyph returns the glyph used to display a visible soft hyphen (U+00AD),
+// either with [SoftHyphenVisible] or at the end of a line with [SoftHyphenAtLineEnd].
+// It is the glyph mapped to U+00AD, or, as fallbacks, the glyph for
+// U+2010 HYPHEN or U+002D HYPHEN-MINUS.
+func (f *Font) HyphenGlyph() (GID, bool) {
+	for _, r := range [...]rune{softHyphen, 0x2010, '-'} {
+		if g, ok := f.face.NominalGlyph(r); ok {
+			return g, true
+		}
+	}
+	return 0, false
+}
+
+// DigitWidths shapes each of the ASCII digits '0' to '9' with the given [features]
+// (typically 'tnum', or nil for the default figures), and returns the
+// largest of their advances, in font scaled units.
+// [tabular] is true if all the digits are supported by the font and share the same advance,
+// so that numbers may be aligned in columns without further processing.
+//
+// The digits are shaped in isolation, as horizontal Latin text.
+func (f *Font) DigitWidths(features []Feature) (advance Position, tabular bool) {
+	buffer := NewBuffer()
+	tabular = true
+	for digit := '0'; digit <= '9'; digit++ {
+		buffer.Clear()
+		buffer.AddRune(digit, 0)
+		buffer.Props = SegmentProperties{Direction: LeftToRight, Script: language.Latin}
+		buffer.Shape(f, features)
+
+		if len(buffer.Info) != 1 || buffer.Info[0].Glyph == 0 { // missing or decomposed digit
+			tabular = false
+		}
+		var digitAdvance Position
+		for _, pos := range buffer.Pos {
+			digitAdvance += pos.XAdvance
+		}
+
+		if digit == '0' {
+			advance = digitAdvance
+		} else if digitAdvance != advance {
+			tabular = false
+			if digitAdvance > advance {
+				advance = digitAdvance
+			}
+		}
+	}
+	return advance, tabular
+}
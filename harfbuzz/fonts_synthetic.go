@@ -0,0 +1,329 @@
+package harfbuzz
+
+import (
+	"math"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+)
+
+// ported from harfbuzz/src/hb-font.cc, hb-font.hh and hb-outline.cc Copyright © 2023  Behdad Esfahbod
+
+// synthetic holds the settings used to emulate
+// italic and bold styles
+type synthetic struct {
+	slant float32 // see SetSyntheticSlant
+
+	xEmbolden, yEmbolden float32 // see SetSyntheticBold
+	emboldenInPlace      bool
+}
+
+// SetSyntheticSlant sets the "synthetic slant" of the font, used to emulate
+// an italic style for fonts lacking one.
+//
+// [slant] is the ratio of the horizontal shift to the vertical distance, that is the
+// tangent of the slant angle. Positive values slant to the right, and 0.2 is a typical value.
+//
+// Synthetic slant is applied to the offsets of marks, to [Font.GlyphExtents]
+// and [Font.GlyphOutline]; the advances are not modified.
+func (f *Font) SetSyntheticSlant(slant float32) {
+	f.synthetic.slant = slant
+	f.face.BumpGeneration()
+}
+
+// SyntheticSlant returns the value set by [Font.SetSyntheticSlant].
+func (f *Font) SyntheticSlant() float32 { return f.synthetic.slant }
+
+// SetSyntheticBold sets the "synthetic boldness" of the font, used to emulate
+// a bold style for fonts lacking one.
+//
+// [xEmbolden] and [yEmbolden] are the horizontal and vertical strengths, expressed
+// as ratios of the font scale (or the Upem for outlines). Typical values are around 0.02.
+// Zero values disable emboldening.
+//
+// If [inPlace] is false, the horizontal (resp. vertical) advances of the glyphs are increased
+// by the strength; otherwise they are not modified, which is useful when adjusting an
+// existing layout, for instance to emulate grades.
+//
+// Synthetic boldness is applied to the advances, to [Font.GlyphExtents]
+// and [Font.GlyphOutline].
+func (f *Font) SetSyntheticBold(xEmbolden, yEmbolden float32, inPlace bool) {
+	f.synthetic.xEmbolden, f.synthetic.yEmbolden = xEmbolden, yEmbolden
+	f.synthetic.emboldenInPlace = inPlace
+	f.face.BumpGeneration()
+}
+
+// SyntheticBold returns the values set by [Font.SetSyntheticBold].
+func (f *Font) SyntheticBold() (xEmbolden, yEmbolden float32, inPlace bool) {
+	return f.synthetic.xEmbolden, f.synthetic.yEmbolden, f.synthetic.emboldenInPlace
+}
+
+// slantXY returns the slant, adjusted to the font scale
+func (f *Font) slantXY() float32 {
+	if f.YScale == 0 {
+		return 0
+	}
+	return f.synthetic.slant * float32(f.XScale) / float32(f.YScale)
+}
+
+// strengths returns the emboldening strengths, in font scale
+func (f *Font) strengths() (x, y Position) {
+	x = Position(math.Round(math.Abs(float64(f.XScale)) * float64(f.synthetic.xEmbolden)))
+	y = Position(math.Round(math.Abs(float64(f.YScale)) * float64(f.synthetic.yEmbolden)))
+	return x, y
+}
+
+// emboldenAdvance adjusts the (horizontal or vertical) advance [adv] for synthetic boldness
+func (f *Font) emboldenAdvance(adv Position, isHorizontal bool) Position {
+	if f.synthetic.emboldenInPlace || adv == 0 {
+		return adv
+	}
+	xStrength, yStrength := f.strengths()
+	if isHorizontal {
+		return adv + xStrength
+	}
+	// vertical advances are typically negative
+	if adv < 0 {
+		return adv - yStrength
+	}
+	return adv + yStrength
+}
+
+// syntheticGlyphExtents applies the synthetic slant and boldness to [extents]
+func (f *Font) syntheticGlyphExtents(extents *GlyphExtents) {
+	// slant
+	if slant := f.slantXY(); slant != 0 {
+		x1, y1 := extents.XBearing, extents.YBearing
+		x2, y2 := extents.XBearing+extents.Width, extents.YBearing+extents.Height
+
+		s1, s2 := float64(float32(y1)*slant), float64(float32(y2)*slant)
+		x1 += Position(math.Floor(math.Min(s1, s2)))
+		x2 += Position(math.Ceil(math.Max(s1, s2)))
+
+		extents.XBearing = x1
+		extents.Width = x2 - extents.XBearing
+	}
+
+	// embolden
+	if xStrength, yStrength := f.strengths(); xStrength != 0 || yStrength != 0 {
+		yShift := yStrength
+		if f.YScale < 0 {
+			yShift = -yShift
+		}
+		extents.YBearing += yShift
+		extents.Height -= yShift
+
+		xShift := xStrength
+		if f.XScale < 0 {
+			xShift = -xShift
+		}
+		if f.synthetic.emboldenInPlace {
+			extents.XBearing -= xShift / 2
+		}
+		extents.Width += xShift
+	}
+}
+
+// slantOffsets applies the synthetic slant to the offsets
+// of the glyphs (typically marks)
+func (f *Font) slantOffsets(pos []GlyphPosition) {
+	slant := f.slantXY()
+	if slant == 0 {
+		return
+	}
+	for i, p := range pos {
+		pos[i].XOffset += Position(math.Round(float64(slant * float32(p.YOffset))))
+	}
+}
+
+// GlyphOutline returns the outline of [glyph], expressed in font units, with
+// the synthetic slant and boldness applied (see [Font.SetSyntheticSlant] and [Font.SetSyntheticBold]).
+// It returns false if the glyph has no outline.
+func (f *Font) GlyphOutline(glyph GID) (font.GlyphOutline, bool) {
+	var outline font.GlyphOutline
+	switch data := f.face.GlyphData(glyph).(type) {
+	case font.GlyphOutline:
+		outline = data
+	case font.GlyphSVG:
+		outline = data.Outline
+	case font.GlyphBitmap:
+		if data.Outline == nil {
+			return font.GlyphOutline{}, false
+		}
+		outline = *data.Outline
+	default:
+		return font.GlyphOutline{}, false
+	}
+	// do not modify the data of the face
+	outline.Segments = append([]font.Segment(nil), outline.Segments...)
+
+	if slant := f.synthetic.slant; slant != 0 {
+		for i := range outline.Segments {
+			args := outline.Segments[i].ArgsSlice()
+			for j := range args {
+				args[j].X += args[j].Y * slant
+			}
+		}
+	}
+
+	if f.synthetic.xEmbolden != 0 || f.synthetic.yEmbolden != 0 {
+		upem := float32(f.faceUpem)
+		xStrength, yStrength := upem*f.synthetic.xEmbolden, upem*f.synthetic.yEmbolden
+		xShift, yShift := xStrength/2, yStrength/2
+		if f.synthetic.emboldenInPlace {
+			xShift = 0
+		}
+		emboldenOutline(outline.Segments, xStrength, yStrength, xShift, yShift)
+	}
+	return outline, true
+}
+
+// contourPoints returns pointers to the points of each contour of [segments]
+func contourPoints(segments []ot.Segment) [][]*ot.SegmentPoint {
+	var (
+		contours [][]*ot.SegmentPoint
+		current  []*ot.SegmentPoint
+	)
+	for i := range segments {
+		if segments[i].Op == ot.SegmentOpMoveTo && len(current) != 0 {
+			contours = append(contours, current)
+			current = nil
+		}
+		args := segments[i].ArgsSlice()
+		for j := range args {
+			current = append(current, &args[j])
+		}
+	}
+	if len(current) != 0 {
+		contours = append(contours, current)
+	}
+	return contours
+}
+
+// controlArea returns the signed area of the polygon formed by the points
+func controlArea(contours [][]*ot.SegmentPoint) float32 {
+	var a float32
+	for _, points := range contours {
+		for i, pi := range points {
+			pj := points[(i+1)%len(points)]
+			a += pj.X*pi.Y - pi.X*pj.Y
+		}
+	}
+	return a * .5
+}
+
+// normalize returns the length of (x, y) and the normalized vector
+func normalize(x, y float32) (float32, float32, float32) {
+	l := float32(math.Hypot(float64(x), float64(y)))
+	if l == 0 {
+		return 0, x, y
+	}
+	return l, x / l, y / l
+}
+
+// emboldenOutline is a port of FreeType's FT_Outline_EmboldenXY,
+// through hb_outline_t::embolden
+func emboldenOutline(segments []ot.Segment, xStrength, yStrength, xShift, yShift float32) {
+	if xStrength == 0 && yStrength == 0 {
+		return
+	}
+	xStrength /= 2
+	yStrength /= 2
+
+	contours := contourPoints(segments)
+	orientationNegative := controlArea(contours) < 0
+
+	for _, points := range contours {
+		// the new positions, so that the shifts are computed with the original points
+		shifted := make([]ot.SegmentPoint, len(points))
+		for i, p := range points {
+			shifted[i] = *p
+		}
+
+		var (
+			inX, inY, anchorX, anchorY float32
+			lIn, lAnchor               float32
+		)
+		last := len(points) - 1
+		// counter j cycles though the points; counter i advances only
+		// when points are moved; anchor k marks the first moved point.
+		for i, j, k := last, 0, -1; j != i && i != k; {
+			var outX, outY, lOut float32
+			if j != k {
+				lOut, outX, outY = normalize(points[j].X-points[i].X, points[j].Y-points[i].Y)
+				if lOut == 0 {
+					j = nextIndex(j, last)
+					continue
+				}
+			} else {
+				outX, outY, lOut = anchorX, anchorY, lAnchor
+			}
+
+			if lIn != 0 {
+				if k < 0 {
+					k = i
+					anchorX, anchorY, lAnchor = inX, inY, lIn
+				}
+
+				var shiftX, shiftY float32
+				d := inX*outX + inY*outY
+				// shift only if turn is less than ~160 degrees
+				if d > -15./16. {
+					d = d + 1
+
+					// shift components along lateral bisector in proper orientation
+					shiftX, shiftY = inY+outY, inX+outX
+					if orientationNegative {
+						shiftX = -shiftX
+					} else {
+						shiftY = -shiftY
+					}
+
+					// restrict shift magnitude to better handle collapsing segments
+					q := outX*inY - outY*inX
+					if orientationNegative {
+						q = -q
+					}
+
+					l := lIn
+					if lOut < l {
+						l = lOut
+					}
+
+					// non-strict inequalities avoid divide-by-zero when q == l == 0
+					if xStrength*q <= l*d {
+						shiftX = shiftX * xStrength / d
+					} else {
+						shiftX = shiftX * l / q
+					}
+					if yStrength*q <= l*d {
+						shiftY = shiftY * yStrength / d
+					} else {
+						shiftY = shiftY * l / q
+					}
+				}
+
+				for ; i != j; i = nextIndex(i, last) {
+					shifted[i].X = points[i].X + xShift + shiftX
+					shifted[i].Y = points[i].Y + yShift + shiftY
+				}
+			} else {
+				i = j
+			}
+
+			inX, inY, lIn = outX, outY, lOut
+			j = nextIndex(j, last)
+		}
+
+		for i, p := range points {
+			*p = shifted[i]
+		}
+	}
+}
+
+func nextIndex(i, last int) int {
+	if i < last {
+		return i + 1
+	}
+	return 0
+}
@@ -1,13 +1,19 @@
 package harfbuzz
 
 import (
+	"bytes"
 	"fmt"
+	"math"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
 	"github.com/boxesandglue/typesetting/language"
 	tu "github.com/boxesandglue/typesetting/testutils"
+	otTD "github.com/go-text/typesetting-utils/opentype"
 )
 
 // Unit tests for glyph advance Widths and extents of TrueType variable fonts
@@ -208,6 +214,25 @@ func TestLigCarets(t *testing.T) {
 	}
 }
 
+func TestLigatureCaretsVariations(t *testing.T) {
+	ft := openFontFileTT(t, "toys/GDEFCaretList3.ttf")
+	font := NewFont(font.NewFace(ft))
+
+	tu.Assert(t, font.LigatureCarets(379, LeftToRight) == nil)
+
+	// default instance: no variation deltas
+	tu.Assert(t, reflect.DeepEqual(font.LigatureCarets(380, LeftToRight), []Position{620}))
+	tu.Assert(t, reflect.DeepEqual(font.LigatureCarets(383, LeftToRight), []Position{702, 1392}))
+
+	font.SetVarCoordsDesign([]float32{900})
+	tu.Assert(t, reflect.DeepEqual(font.LigatureCarets(380, LeftToRight), []Position{699}))
+	tu.Assert(t, reflect.DeepEqual(font.LigatureCarets(383, LeftToRight), []Position{822, 1587}))
+
+	// scaling is applied
+	font.XScale = 2 * font.XScale
+	tu.Assert(t, reflect.DeepEqual(font.LigatureCarets(380, LeftToRight), []Position{1398}))
+}
+
 func TestColorGlyphExtents(t *testing.T) {
 	// TODO: Support COLR table
 	t.Skip()
@@ -274,3 +299,514 @@ func TestUnifont(t *testing.T) {
 	font := NewFont(font.NewFace(ft))
 	buf.Shape(font, nil) // just check for crashes
 }
+
+func TestDigitWidths(t *testing.T) {
+	tnum := []Feature{{Tag: ot.MustNewTag("tnum"), Value: 1, Start: FeatureGlobalStart, End: FeatureGlobalEnd}}
+
+	hbFont := NewFont(font.NewFace(openFontFileTT(t, "common/DejaVuSans.ttf")))
+	advance, tabular := hbFont.DigitWidths(nil)
+	tu.Assert(t, tabular && advance == 1303)
+
+	// proportional figures
+	hbFont = NewFont(font.NewFace(openFontFileTT(t, "common/Raleway-v4020-Regular.otf")))
+	advance, tabular = hbFont.DigitWidths(nil)
+	tu.Assert(t, !tabular && advance == 612)
+
+	// the 'tnum' feature of this font does not provide exactly tabular figures
+	hbFont = NewFont(font.NewFace(openFontFileTT(t, "common/Roboto-BoldItalic.ttf")))
+	advance, tabular = hbFont.DigitWidths(nil)
+	tu.Assert(t, tabular && advance == 1140)
+	advance, tabular = hbFont.DigitWidths(tnum)
+	tu.Assert(t, !tabular && advance == 1150)
+}
+
+func TestSoftHyphen(t *testing.T) {
+	face := font.NewFace(openFontFileTT(t, "common/DejaVuSans.ttf"))
+	hbFont := NewFont(face)
+	hyphen, ok := hbFont.HyphenGlyph()
+	softHyphenGlyph, _ := face.NominalGlyph(0x00AD)
+	tu.Assert(t, ok && hyphen == softHyphenGlyph)
+
+	for _, policy := range []SoftHyphenPolicy{SoftHyphenInvisible, SoftHyphenAtLineEnd, SoftHyphenVisible} {
+		buf := NewBuffer()
+		buf.AddRunes([]rune("a\u00ADb"), 0, -1)
+		buf.SoftHyphen = policy
+		buf.GuessSegmentProperties()
+		buf.Shape(hbFont, nil)
+		tu.Assert(t, len(buf.Info) == 3)
+		if policy == SoftHyphenVisible {
+			tu.Assert(t, buf.Info[1].Glyph == hyphen && buf.Pos[1].XAdvance > 0)
+		} else {
+			tu.Assert(t, buf.Info[1].Glyph != hyphen && buf.Pos[1].XAdvance == 0)
+		}
+	}
+}
+
+func TestLigaturesStartingWith(t *testing.T) {
+	face := font.NewFace(openFontFileTT(t, "common/Raleway-v4020-Regular.otf"))
+	hbFont := NewFont(face)
+	gf, _ := face.NominalGlyph('f')
+	gi, _ := face.NominalGlyph('i')
+
+	liga := ot.MustNewTag("liga")
+	ligatures := hbFont.LigaturesStartingWith(gf, language.Latin, "en", []ot.Tag{liga})
+	tu.Assert(t, len(ligatures) != 0)
+
+	// check against the shaper
+	buf := NewBuffer()
+	buf.AddRunes([]rune("fi"), 0, -1)
+	buf.GuessSegmentProperties()
+	buf.Shape(hbFont, nil)
+	tu.Assert(t, len(buf.Info) == 1)
+	found := false
+	for _, lig := range ligatures {
+		tu.Assert(t, lig.Components[0] == gf && lig.Feature == liga)
+		if len(lig.Components) == 2 && lig.Components[1] == gi {
+			found = true
+			tu.Assert(t, lig.Glyph == buf.Info[0].Glyph)
+		}
+	}
+	tu.Assert(t, found)
+
+	tu.Assert(t, len(hbFont.LigaturesStartingWith(gf, language.Latin, "en", nil)) == 0)
+	tu.Assert(t, len(hbFont.LigaturesStartingWith(gi, language.Latin, "en", []ot.Tag{liga})) == 0)
+}
+
+func TestCapabilities(t *testing.T) {
+	caps := NewFont(font.NewFace(openFontFileTT(t, "common/NotoSansArabic.ttf"))).Capabilities()
+	tu.Assert(t, caps.Has(CapGSUB|CapGPOS|CapGlyphClasses|CapArabicGSUB|CapMarkPositioning|CapKerning))
+	tu.Assert(t, !caps.Has(CapMorx) && !caps.Has(CapKern))
+	tu.Assert(t, caps.HasScript(ot.NewTag('a', 'r', 'a', 'b')))
+	tu.Assert(t, !caps.HasScript(ot.NewTag('l', 'a', 't', 'n')))
+	tu.Assert(t, caps.HasFeature(ot.NewTag('i', 'n', 'i', 't')))
+	tu.Assert(t, caps.HasFeature(ot.NewTag('m', 'a', 'r', 'k')))
+	tu.Assert(t, !caps.HasFeature(ot.NewTag('s', 'm', 'c', 'p')))
+
+	caps = NewFont(font.NewFace(openFontFileTT(t, "common/Raleway-v4020-Regular.otf"))).Capabilities()
+	tu.Assert(t, caps.Has(CapGSUB|CapGPOS))
+	tu.Assert(t, !caps.Has(CapArabicGSUB))
+	tu.Assert(t, caps.HasFeature(ot.NewTag('l', 'i', 'g', 'a')))
+
+	unifont := font.NewFace(openFontFileTT(t, "bitmap/unifont-15.1.05.otf"))
+	caps = NewFont(unifont).Capabilities()
+	tu.Assert(t, !caps.Has(CapKerning) && !caps.Has(CapMarkPositioning) && !caps.Has(CapArabicGSUB))
+
+	// computed once per face
+	tu.Assert(t, &NewFont(unifont).Capabilities().Scripts[0] == &caps.Scripts[0])
+
+	// the plans skip the work which can't have an effect
+	props := SegmentProperties{Direction: LeftToRight, Script: language.Latin}
+	plan := newShapePlan(NewFont(unifont), props, nil, nil, planOptions{}).shaper.plan
+	tu.Assert(t, plan.kernMask == 0 && !plan.applyFallbackKern && !plan.hasAttachments)
+
+	props = SegmentProperties{Direction: RightToLeft, Script: language.Arabic}
+	plan = newShapePlan(NewFont(font.NewFace(openFontFileTT(t, "common/NotoSansArabic.ttf"))), props, nil, nil, planOptions{}).shaper.plan
+	tu.Assert(t, plan.kernMask != 0 && plan.hasAttachments)
+}
+
+func TestPlanCacheVariations(t *testing.T) {
+	// the 'rvrn' substitution of this font depends on the variable coordinates
+	ft := openFontFile(t, "harfbuzz_reference/in-house/fonts/d23d76ea0909c14972796937ba072b5a40c1e257.ttf")
+	face := font.NewFace(ft)
+	hbFont := NewFont(face)
+
+	buf := NewBuffer()
+	shape := func() GID {
+		buf.Clear()
+		buf.Props.Script = language.Latin
+		buf.Props.Direction = LeftToRight
+		buf.AddRunes([]rune{'r'}, 0, 1)
+		buf.Shape(hbFont, nil)
+		tu.Assert(t, len(buf.Info) == 1)
+		return buf.Info[0].Glyph
+	}
+
+	base := shape()
+	generation := face.Generation()
+
+	// the same buffer is reused : the cached plan must be updated
+	face.SetVariations([]font.Variation{{Tag: ot.MustNewTag("FVTT"), Value: 491}})
+	tu.Assert(t, face.Generation() != generation)
+	subst := shape()
+	tu.Assert(t, subst != base)
+	tu.Assert(t, ft.GlyphName(subst) == "rvrn_subst")
+
+	face.SetVariations(nil)
+	tu.Assert(t, shape() == base)
+	// one plan per variation index, kept when the coordinates change
+	tu.Assert(t, len(buf.planCache[face]) == 2)
+	plan := buf.planCache[face][0]
+
+	face.SetPpem(12, 12)
+	tu.Assert(t, shape() == base)
+	face.SetVariations([]font.Variation{{Tag: ot.MustNewTag("FVTT"), Value: 491}})
+	tu.Assert(t, shape() == subst)
+	face.SetVariations(nil)
+	tu.Assert(t, shape() == base)
+	tu.Assert(t, len(buf.planCache[face]) == 2)
+	tu.Assert(t, buf.planCache[face][0] == plan)
+}
+
+func TestOpticalSizing(t *testing.T) {
+	// 'opsz' axis : [10, 14, 72]
+	ft := openFontFile(t, "harfbuzz_reference/in-house/fonts/ab40c89624a6104e5d0a2308e448a989302f515b.ttf")
+	face := font.NewFace(ft)
+	hbFont := NewFont(face)
+	hasOpticalSize := func(size float32) bool {
+		ref := font.NewFace(ft)
+		ref.SetOpticalSize(size)
+		return reflect.DeepEqual(face.Coords(), ref.Coords())
+	}
+
+	buf := NewBuffer()
+	shape := func() {
+		buf.Clear()
+		buf.AddRunes([]rune("abc"), 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(hbFont, nil)
+	}
+
+	shape()
+	tu.Assert(t, face.Coords() == nil) // Ptem not set
+	tu.Assert(t, hbFont.OpticalSizing())
+
+	hbFont.Ptem = 72
+	shape()
+	tu.Assert(t, hasOpticalSize(72))
+	generation := face.Generation()
+	shape()
+	tu.Assert(t, face.Generation() == generation) // coordinates are not changed
+
+	hbFont.SetOpticalSizing(false)
+	hbFont.Ptem = 10
+	shape()
+	tu.Assert(t, hasOpticalSize(72))
+
+	hbFont.SetOpticalSizing(true)
+	shape()
+	tu.Assert(t, hasOpticalSize(10))
+}
+
+func TestFontSettingsGeneration(t *testing.T) {
+	face := font.NewFace(openFontFileTT(t, "common/Raleway-v4020-Regular.otf"))
+	hbFont := NewFont(face)
+
+	for _, set := range []func(){
+		func() { hbFont.SetSyntheticSlant(0.2) },
+		func() { hbFont.SetSyntheticBold(0.02, 0.02, false) },
+		func() { hbFont.SetTracking(4) },
+		func() { hbFont.DisableTracking() },
+		func() { hbFont.SetPpem(12, 12) },
+		func() { hbFont.SetFractionalScale(1000.5, 1000.5) },
+		func() { hbFont.SetFuncs(DefaultFontFuncs{}) },
+		func() { hbFont.SetOpticalSizing(false) },
+	} {
+		generation := face.Generation()
+		set()
+		tu.Assert(t, face.Generation() > generation)
+	}
+}
+
+func TestPositionSource(t *testing.T) {
+	sources := func(hbFont *Font, text string, flags ShappingOptions) []PositionSource {
+		buf := NewBuffer()
+		buf.Flags = flags
+		buf.AddRunes([]rune(text), 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(hbFont, nil)
+		out := make([]PositionSource, len(buf.Pos))
+		for i, pos := range buf.Pos {
+			out[i] = pos.Source
+		}
+		return out
+	}
+
+	dejaVu := NewFont(font.NewFace(openFontFileTT(t, "common/DejaVuSans.ttf")))
+	tu.Assert(t, reflect.DeepEqual(sources(dejaVu, "AVTo", 0), []PositionSource{PositionGPOSPair, PositionDefault, PositionGPOSPair, PositionDefault}))
+	tu.Assert(t, reflect.DeepEqual(sources(dejaVu, "x̣", 0), []PositionSource{PositionDefault, PositionGPOSMark}))
+
+	raleway := NewFont(font.NewFace(openFontFileTT(t, "common/Raleway-v4020-Regular.otf")))
+	tu.Assert(t, reflect.DeepEqual(sources(raleway, "x̣", FallbackMarkPositioning), []PositionSource{PositionDefault, PositionFallback}))
+
+	trak := NewFont(font.NewFace(openFontFile(t, "fonts/aat-trak.ttf")))
+	trak.Ptem = 1
+	tu.Assert(t, reflect.DeepEqual(sources(trak, "AA", 0), []PositionSource{PositionTrak, PositionTrak}))
+
+	// reusing a buffer does not keep the sources of the previous run
+	shape := func(buf *Buffer, text string) []GlyphPosition {
+		buf.Clear()
+		buf.AddRunes([]rune(text), 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(raleway, nil)
+		return append([]GlyphPosition(nil), buf.Pos...)
+	}
+	text := "ḉǕṻǭȭḉǕṻǭȭ"
+	fresh := shape(NewBuffer(), text)
+	buf := NewBuffer()
+	shape(buf, strings.Repeat("AV", 40))
+	tu.Assert(t, reflect.DeepEqual(shape(buf, text), fresh))
+}
+
+func TestReverseChainSingleSubst(t *testing.T) {
+	ft := openFontFile(t, "harfbuzz_reference/in-house/fonts/1b66a1f4b076b734caa6397b3e57231af1feaafb.ttf")
+	_, isReverse := ft.GSUB.Lookups[1].Subtables[0].(tables.ReverseChainSingleSubs)
+	tu.Assert(t, isReverse)
+
+	glyphNames := func(ft *font.Font, text string) []string {
+		buf := NewBuffer()
+		buf.AddRunes([]rune(text), 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(NewFont(font.NewFace(ft)), nil)
+		out := make([]string, len(buf.Info))
+		for i, info := range buf.Info {
+			out[i] = ft.GlyphName(info.Glyph)
+		}
+		return out
+	}
+
+	// the lookup is applied from the end of the buffer, so that each
+	// numerator is used as context by the preceding digit
+	tu.Assert(t, reflect.DeepEqual(glyphNames(ft, "123⁄4"), []string{"one.numr", "two.numr", "three.numr", "fraction", "four.dnom"}))
+
+	// invalid substitute index : only the digit before the fraction
+	// is substituted, by another lookup
+	lookup := &ft.GSUB.Lookups[1]
+	subst := lookup.Subtables[0].(tables.ReverseChainSingleSubs)
+	subst.SubstituteGlyphIDs = nil
+	lookup.Subtables[0] = subst
+	tu.Assert(t, reflect.DeepEqual(glyphNames(ft, "123⁄4"), []string{"one", "two", "three.numr", "fraction", "four.dnom"}))
+}
+
+// hintedFuncs rounds the advances to multiples of 64,
+// and shifts the horizontal origins
+type hintedFuncs struct {
+	DefaultFontFuncs
+}
+
+func (hintedFuncs) GlyphHAdvance(f *Font, glyph GID) Position {
+	adv := DefaultFontFuncs{}.GlyphHAdvance(f, glyph)
+	return (adv + 32) &^ 63
+}
+
+func (hintedFuncs) GlyphHOrigin(f *Font, glyph GID) (x, y Position, ok bool) {
+	return 10, 0, true
+}
+
+func TestFontFuncs(t *testing.T) {
+	face := font.NewFace(openFontFileTT(t, "common/Raleway-v4020-Regular.otf"))
+	hbFont := NewFont(face)
+	hbFont.XScale, hbFont.YScale = 12*64, 12*64
+	_, isDefault := hbFont.Funcs().(DefaultFontFuncs)
+	tu.Assert(t, isDefault)
+
+	shape := func() []GlyphPosition {
+		buf := NewBuffer()
+		buf.AddRunes([]rune("Hello"), 0, -1)
+		buf.GuessSegmentProperties()
+		// disable kerning to only use the advances
+		buf.Shape(hbFont, []Feature{{Tag: ot.MustNewTag("kern"), Value: 0, Start: 0, End: FeatureGlobalEnd}})
+		return buf.Pos
+	}
+
+	regular := shape()
+	hbFont.SetFuncs(hintedFuncs{})
+	hinted := shape()
+	tu.Assert(t, len(hinted) == len(regular))
+	for i, pos := range hinted {
+		tu.Assert(t, pos.XAdvance%64 == 0)
+		tu.Assert(t, pos.XAdvance-regular[i].XAdvance <= 32 && regular[i].XAdvance-pos.XAdvance < 32)
+		tu.Assert(t, pos.XOffset == regular[i].XOffset-10)
+	}
+
+	hbFont.SetFuncs(nil)
+	tu.Assert(t, reflect.DeepEqual(shape(), regular))
+}
+
+func TestSyntheticSlantBold(t *testing.T) {
+	face := font.NewFace(openFontFileTT(t, "common/Raleway-v4020-Regular.otf"))
+	hbFont := NewFont(face)
+	gid, _ := face.NominalGlyph('H')
+
+	advance := hbFont.GlyphHAdvance(gid)
+	extents, ok := hbFont.GlyphExtents(gid)
+	tu.Assert(t, ok)
+	outline, ok := hbFont.GlyphOutline(gid)
+	tu.Assert(t, ok)
+
+	// bold
+	hbFont.SetSyntheticBold(0.02, 0.02, false)
+	strength := Position(math.Round(float64(hbFont.XScale) * 0.02))
+	tu.Assert(t, hbFont.GlyphHAdvance(gid) == advance+strength)
+	boldExtents, _ := hbFont.GlyphExtents(gid)
+	tu.Assert(t, boldExtents.Width == extents.Width+strength)
+	tu.Assert(t, boldExtents.XBearing == extents.XBearing)
+	boldOutline, _ := hbFont.GlyphOutline(gid)
+	tu.Assert(t, len(boldOutline.Segments) == len(outline.Segments))
+	tu.Assert(t, !reflect.DeepEqual(boldOutline, outline))
+	// the face data is not modified
+	original, _ := face.GlyphData(gid).(font.GlyphOutline)
+	tu.Assert(t, reflect.DeepEqual(original, outline))
+
+	hbFont.SetSyntheticBold(0.02, 0.02, true)
+	tu.Assert(t, hbFont.GlyphHAdvance(gid) == advance)
+	boldExtents, _ = hbFont.GlyphExtents(gid)
+	tu.Assert(t, boldExtents.Width == extents.Width+strength)
+	tu.Assert(t, boldExtents.XBearing == extents.XBearing-strength/2)
+
+	hbFont.SetSyntheticBold(0, 0, false)
+	tu.Assert(t, hbFont.GlyphHAdvance(gid) == advance)
+
+	// slant
+	hbFont.SetSyntheticSlant(0.2)
+	tu.Assert(t, hbFont.GlyphHAdvance(gid) == advance)
+	slantExtents, _ := hbFont.GlyphExtents(gid)
+	tu.Assert(t, slantExtents.Width > extents.Width)
+	slantOutline, _ := hbFont.GlyphOutline(gid)
+	for i, seg := range slantOutline.Segments {
+		for j, pt := range seg.ArgsSlice() {
+			ref := outline.Segments[i].ArgsSlice()[j]
+			tu.Assert(t, pt.Y == ref.Y && pt.X == ref.X+0.2*ref.Y)
+		}
+	}
+
+	hbFont.SetSyntheticSlant(0)
+	resetExtents, _ := hbFont.GlyphExtents(gid)
+	tu.Assert(t, resetExtents == extents)
+}
+
+func TestLayoutAcceleratorsShared(t *testing.T) {
+	face := font.NewFace(openFontFileTT(t, "common/NotoSansArabic.ttf"))
+	f1, f2 := NewFont(face), NewFont(face)
+	tu.Assert(t, len(f1.gsubAccels) != 0 && len(f1.gposAccels) != 0)
+	tu.Assert(t, &f1.gsubAccels[0] == &f2.gsubAccels[0])
+	tu.Assert(t, &f1.gposAccels[0] == &f2.gposAccels[0])
+
+	// fonts of other faces use their own accelerators
+	f3 := NewFont(font.NewFace(face.Font))
+	tu.Assert(t, len(f3.gsubAccels) == len(f1.gsubAccels))
+	tu.Assert(t, &f1.gsubAccels[0] != &f3.gsubAccels[0])
+}
+
+func TestWouldSubstitute(t *testing.T) {
+	face := font.NewFace(openFontFileTT(t, "common/Raleway-v4020-Regular.otf"))
+	hbFont := NewFont(face)
+	glyphs := func(text string) []GID {
+		var out []GID
+		for _, r := range text {
+			g, _ := face.NominalGlyph(r)
+			out = append(out, g)
+		}
+		return out
+	}
+
+	liga, smcp := ot.MustNewTag("liga"), ot.MustNewTag("smcp")
+	tu.Assert(t, hbFont.WouldSubstitute(liga, glyphs("fi")))
+	tu.Assert(t, !hbFont.WouldSubstitute(liga, glyphs("fx")))
+	tu.Assert(t, !hbFont.WouldSubstitute(liga, glyphs("f")))
+	tu.Assert(t, hbFont.WouldSubstitute(smcp, glyphs("a")))
+	tu.Assert(t, !hbFont.WouldSubstitute(smcp, glyphs("")))
+	tu.Assert(t, !hbFont.WouldSubstitute(ot.MustNewTag("xxxx"), glyphs("a")))
+}
+
+func TestPpemDevice(t *testing.T) {
+	src, err := otTD.Files.ReadFile("common/Roboto-BoldItalic.ttf")
+	tu.AssertNoErr(t, err)
+	face, err := font.ParseTTF(bytes.NewReader(src))
+	tu.AssertNoErr(t, err)
+	glyph, _ := face.NominalGlyph('a')
+
+	// a GPOS table with one 'kern' lookup, whose single positioning only
+	// has a hinting device table for the advance of 'a',
+	// with deltas 1, 2, -1 pixels for ppem 10, 11, 12
+	gpos := []byte{
+		0, 1, 0, 0, 0, 10, 0, 30, 0, 44, // header
+		0, 1, 'D', 'F', 'L', 'T', 0, 8, // script list
+		0, 4, 0, 0, // script
+		0, 0, 0xFF, 0xFF, 0, 1, 0, 0, // default language
+		0, 1, 'k', 'e', 'r', 'n', 0, 8, // feature list
+		0, 0, 0, 1, 0, 0, // feature
+		0, 1, 0, 4, // lookup list
+		0, 1, 0, 0, 0, 1, 0, 8, // lookup
+		0, 1, 0, 10, 0, 0x44, 0, 0, 0, 16, // single positioning, format 1
+		0, 1, 0, 1, byte(glyph >> 8), byte(glyph), // coverage
+		0, 10, 0, 12, 0, 3, 1, 2, 0xFF, 0, // device
+	}
+	face, err = font.ParseTTF(bytes.NewReader(withTable(t, src, ot.MustNewTag("GPOS"), gpos)))
+	tu.AssertNoErr(t, err)
+
+	advance := func(hbFont *Font) Position {
+		buf := NewBuffer()
+		buf.AddRunes([]rune("a"), 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(hbFont, nil)
+		return buf.Pos[0].XAdvance
+	}
+
+	hbFont := NewFont(face)
+	hbFont.XScale, hbFont.YScale = 1000, 1000
+	nominal := advance(hbFont)
+	tu.Assert(t, nominal == hbFont.GlyphHAdvance(GID(glyph)))
+
+	for _, test := range []struct {
+		ppem  uint16
+		delta Position
+	}{
+		{9, 0},
+		{10, 100},
+		{11, 181}, // 2 * 1000 / 11
+		{12, -83},
+		{13, 0},
+	} {
+		hbFont.SetPpem(test.ppem, test.ppem)
+		xPpem, yPpem := hbFont.Ppem()
+		tu.Assert(t, xPpem == test.ppem && yPpem == test.ppem)
+		tu.AssertC(t, advance(hbFont) == nominal+test.delta, fmt.Sprint(test.ppem))
+	}
+
+	// the ppem of the face is used by default
+	hbFont.SetPpem(0, 0)
+	face.SetPpem(10, 10)
+	tu.Assert(t, advance(hbFont) == nominal+100)
+	hbFont.SetPpem(11, 11)
+	tu.Assert(t, advance(hbFont) == nominal+181)
+}
+
+func TestVariationSequences(t *testing.T) {
+	face := font.NewFace(openFontFileTT(t, "common/NotoSansCJKjp-VF.otf"))
+	hbFont := NewFont(face)
+
+	shape := func(text string) []GlyphInfo {
+		buf := NewBuffer()
+		buf.AddRunes([]rune(text), 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(hbFont, nil)
+		return buf.Info
+	}
+
+	// ideographic variation sequence, from the 'cmap' format 14 subtable
+	ivs, ok := face.VariationGlyph(0x845B, 0xE0100)
+	tu.Assert(t, ok)
+	nominal, _ := face.NominalGlyph(0x845B)
+	tu.Assert(t, ivs != nominal)
+	glyphs := shape("\u845B\U000E0100")
+	tu.Assert(t, len(glyphs) == 1 && glyphs[0].Glyph == ivs)
+
+	// CJK compatibility ideographs are not decomposed...
+	compat, ok := face.NominalGlyph(0xF907)
+	tu.Assert(t, ok)
+	nominal, _ = face.NominalGlyph(0x9F9C)
+	tu.Assert(t, compat != nominal)
+	glyphs = shape("\uF907")
+	tu.Assert(t, len(glyphs) == 1 && glyphs[0].Glyph == compat)
+
+	// ... and their standardized variation sequence is supported,
+	// even if it is missing from the 'cmap' format 14 subtable
+	_, ok = face.VariationGlyph(0x9F9C, 0xFE00)
+	tu.Assert(t, !ok)
+	glyphs = shape("\u9F9C\uFE00")
+	tu.Assert(t, len(glyphs) == 1 && glyphs[0].Glyph == compat)
+
+	// unsupported sequences fall back to the nominal glyph
+	glyphs = shape("\u9F9C\uFE0F")
+	tu.Assert(t, len(glyphs) == 2 && glyphs[0].Glyph == nominal)
+}
@@ -0,0 +1,34 @@
+//go:build go1.18
+// +build go1.18
+
+package harfbuzz
+
+import (
+	"bytes"
+	"testing"
+
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	tu "github.com/boxesandglue/typesetting/testutils"
+	td "github.com/go-text/typesetting-utils/harfbuzz"
+)
+
+// FuzzAATStateMachines shapes text with fonts whose 'morx' table is
+// replaced by the fuzzed input, looking for panics or infinite loops
+// in the AAT state machines.
+func FuzzAATStateMachines(f *testing.F) {
+	var sources [][]byte
+	for _, filename := range morxTestFonts {
+		src, err := td.Files.ReadFile(filename)
+		tu.AssertNoErr(f, err)
+		ld, err := ot.NewLoader(bytes.NewReader(src))
+		tu.AssertNoErr(f, err)
+		morx, err := ld.RawTable(tagMorx)
+		tu.AssertNoErr(f, err)
+		f.Add(morx, "ABCDEFabcdef", byte(len(sources)))
+		sources = append(sources, src)
+	}
+	f.Fuzz(func(t *testing.T, morx []byte, text string, font byte) {
+		src := sources[int(font)%len(sources)]
+		shapeMorx(withTable(t, src, tagMorx, morx), []rune(text))
+	})
+}
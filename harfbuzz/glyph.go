@@ -32,6 +32,61 @@ type GlyphPosition struct {
 	// negative for going back, positive for forward.
 	attachChain int16
 	attachType  uint8 // attachment type, irrelevant if attachChain is 0
+
+	// Source is the last positioning mechanism which adjusted
+	// this glyph, useful to debug unexpected positions.
+	Source PositionSource
+}
+
+// PositionSource identifies the mechanism (font table or fallback)
+// which adjusted the position of a glyph, beyond its nominal advance.
+type PositionSource uint8
+
+const (
+	// PositionDefault is used for glyphs only positioned with their nominal advance.
+	PositionDefault PositionSource = iota
+	// PositionGPOSSingle is used for GPOS single adjustments (lookup type 1).
+	PositionGPOSSingle
+	// PositionGPOSPair is used for GPOS pair adjustments (lookup type 2), that is kerning.
+	PositionGPOSPair
+	// PositionGPOSCursive is used for GPOS cursive attachments (lookup type 3).
+	PositionGPOSCursive
+	// PositionGPOSMark is used for GPOS mark attachments (lookup types 4, 5 and 6).
+	PositionGPOSMark
+	// PositionKerx is used for the AAT 'kerx' table.
+	PositionKerx
+	// PositionKern is used for the 'kern' table.
+	PositionKern
+	// PositionTrak is used for the AAT 'trak' table.
+	PositionTrak
+	// PositionFallback is used when the font provides no positioning
+	// data, and the shaper synthesizes kerning, mark positions or space widths.
+	PositionFallback
+)
+
+func (ps PositionSource) String() string {
+	switch ps {
+	case PositionDefault:
+		return "default"
+	case PositionGPOSSingle:
+		return "GPOS single"
+	case PositionGPOSPair:
+		return "GPOS pair"
+	case PositionGPOSCursive:
+		return "GPOS cursive"
+	case PositionGPOSMark:
+		return "GPOS mark"
+	case PositionKerx:
+		return "kerx"
+	case PositionKern:
+		return "kern"
+	case PositionTrak:
+		return "trak"
+	case PositionFallback:
+		return "fallback"
+	default:
+		return fmt.Sprintf("<position source %d>", ps)
+	}
 }
 
 // unicodeProp is a two-byte number. The low byte includes:
@@ -194,10 +249,18 @@ func (info GlyphInfo) String() string {
 	return fmt.Sprintf("%d=%d(0x%x)", info.Glyph, info.Cluster, info.Mask&glyphFlagDefined)
 }
 
+// Codepoint returns the character (added to the [Buffer]) the glyph
+// was created from. It is mostly useful before shaping, or in
+// the hooks of a [ComplexShaper].
+func (info GlyphInfo) Codepoint() rune { return info.codepoint }
+
 func (info *GlyphInfo) setUnicodeProps(buffer *Buffer) {
 	u := info.codepoint
 	var flags bufferScratchFlags
 	info.unicode, flags = computeUnicodeProps(u)
+	if u == softHyphen && buffer.SoftHyphen == SoftHyphenVisible {
+		info.unicode &^= upropsMaskIgnorable
+	}
 	buffer.scratchFlags |= flags
 }
 
@@ -24,7 +24,7 @@ func assertEqualInt32(t *testing.T, got, expected int32) {
 }
 
 // opens truetype fonts from opentype testdata.
-func openFontFileTT(t *testing.T, filename string) *font.Font {
+func openFontFileTT(t testing.TB, filename string) *font.Font {
 	t.Helper()
 
 	f, err := otTD.Files.ReadFile(filename)
@@ -192,3 +192,70 @@ func TestExample(t *testing.T) {
 		fmt.Println(pos.XAdvance, pos.XOffset, ext.Width, ext.XBearing)
 	}
 }
+
+func TestClusterFromUTF(t *testing.T) {
+	text := "a\u00E9\u4E2D\U0001F600b" // 1, 2, 3 and 4 bytes runes
+	utf8Text := []byte(text)
+	for offset, expected := range map[int]int{0: 0, 1: 1, 2: 2, 3: 2, 6: 3, 7: 4, 10: 4, 11: 5, 100: 5, FeatureGlobalEnd: FeatureGlobalEnd} {
+		tu.AssertC(t, ClusterFromUTF8(utf8Text, offset) == expected, fmt.Sprint(offset))
+	}
+	tu.Assert(t, ClusterFromUTF8([]byte{'a', 0xFF, 0xFE, 'b'}, 3) == 3) // invalid bytes
+
+	utf16Text := []uint16{'a', 0xE9, 0x4E2D, 0xD83D, 0xDE00, 'b'}
+	for offset, expected := range map[int]int{0: 0, 3: 3, 4: 4, 5: 4, 6: 5, FeatureGlobalEnd: FeatureGlobalEnd} {
+		tu.AssertC(t, ClusterFromUTF16(utf16Text, offset) == expected, fmt.Sprint(offset))
+	}
+	tu.Assert(t, ClusterFromUTF16([]uint16{0xDE00, 0xD83D, 'a'}, 3) == 3) // unpaired surrogates
+}
+
+// shapeClusters returns the glyphs and advances whose cluster is in [from, to)
+func shapeClusters(font *Font, text []rune, features []Feature, level ClusterLevel, from, to int) string {
+	buffer := NewBuffer()
+	buffer.ClusterLevel = level
+	buffer.AddRunes(text, 0, -1)
+	buffer.GuessSegmentProperties()
+	buffer.Shape(font, features)
+	var out []string
+	for i, info := range buffer.Info {
+		if from <= info.Cluster && info.Cluster < to {
+			out = append(out, fmt.Sprintf("%d+%d", info.Glyph, buffer.Pos[i].XAdvance))
+		}
+	}
+	return fmt.Sprint(out)
+}
+
+func TestFeatureRanges(t *testing.T) {
+	for _, test := range []struct {
+		font *font.Font
+		word []rune
+	}{
+		// reordering and conjuncts
+		{openFontFile(t, "harfbuzz_reference/in-house/fonts/8116e5d8fedfbec74e45dc350d2416d810bed8c4.ttf"), []rune{0x091F, 0x094D, 0x092F, 0x093F}},
+		// joining forms and marks
+		{openFontFileTT(t, "common/NotoSansArabic.ttf"), []rune{0x0633, 0x064F, 0x0644, 0x064E, 0x0651, 0x0627}},
+		// decomposition of U+01CE
+		{openFontFileTT(t, "common/Raleway-v4020-Regular.otf"), []rune{'b', 0x01CE, 'b'}},
+	} {
+		font := NewFont(font.NewFace(test.font))
+		n := len(test.word)
+		text := append(append(append([]rune(nil), test.word...), ' '), test.word...)
+		for _, level := range []ClusterLevel{MonotoneGraphemes, MonotoneCharacters, Characters} {
+			for _, feature := range test.font.GSUB.Features {
+				for _, value := range []uint32{0, 1} {
+					global := []Feature{{Tag: feature.Tag, Value: value, Start: FeatureGlobalStart, End: FeatureGlobalEnd}}
+					ranged := []Feature{{Tag: feature.Tag, Value: value, Start: n + 1, End: 2*n + 1}}
+					// the first word is not affected, the second is shaped as if the feature was global
+					tu.AssertC(t, shapeClusters(font, text, ranged, level, 0, n) == shapeClusters(font, test.word, nil, level, 0, n), feature.Tag.String())
+					tu.AssertC(t, shapeClusters(font, text, ranged, level, n+1, 2*n+1) == shapeClusters(font, test.word, global, level, 0, n), feature.Tag.String())
+				}
+			}
+		}
+	}
+
+	// the decomposed characters share the feature of their source
+	font := NewFont(font.NewFace(openFontFileTT(t, "common/Raleway-v4020-Regular.otf")))
+	text := []rune{'b', 0x01CE, 'b'}
+	smcp := []Feature{{Tag: ot.MustNewTag("smcp"), Value: 1, Start: 1, End: 2}}
+	tu.Assert(t, shapeClusters(font, text, nil, MonotoneCharacters, 1, 2) != shapeClusters(font, text, smcp, MonotoneCharacters, 1, 2))
+	tu.Assert(t, shapeClusters(font, text, nil, MonotoneCharacters, 0, 1) == shapeClusters(font, text, smcp, MonotoneCharacters, 0, 1))
+}
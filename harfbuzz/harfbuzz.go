@@ -14,6 +14,7 @@ import (
 	"math"
 	"math/bits"
 	"strconv"
+	"unicode/utf8"
 
 	"github.com/boxesandglue/typesetting/font"
 	ot "github.com/boxesandglue/typesetting/font/opentype"
@@ -24,7 +25,8 @@ import (
 // based on upstream commit 5d543d64222c6ce45332d0c188790f90691ef112
 
 // debugMode is only used in test: if true, it prints detailed information
-// about shaping
+// about shaping.
+// Applications should rather use [Buffer.Tracer].
 const debugMode = false
 
 type (
@@ -69,6 +71,10 @@ func getHorizontalDirection(script language.Script) Direction {
 	return LeftToRight
 }
 
+// Tests whether a text direction is valid, that is
+// one of the four constants.
+func (dir Direction) isValid() bool { return dir & ^Direction(3) == 4 }
+
 // Tests whether a text direction is horizontal. Requires
 // that the direction be valid.
 func (dir Direction) isHorizontal() bool { return dir & ^Direction(1) == 4 }
@@ -158,6 +164,28 @@ const (
 	// glyph-flag should be produced by the shaper. By default
 	// it will not be produced.
 	ProduceSafeToInsertTatweel
+
+	// Flag indicating that the marks not positioned by the font
+	// (that is, not attached to a base by GPOS or 'kerx') should be positioned
+	// by the fallback mark positioning, using their combining class and
+	// their bounding box relative to the one of their base.
+	// By default, this fallback is only applied when the font has
+	// no positioning table at all, and for some scripts.
+	// This is useful for instance for subsetted fonts missing some anchors.
+	FallbackMarkPositioning
+
+	// Flag indicating that the 'halt' and 'palt' features (and their vertical
+	// counterparts 'vhal' and 'vpal'), when requested but not supported by the font,
+	// should be synthesized from the glyph extents, following the character classes of
+	// JLREQ (see [LookupCJKSpacingClass], [Font.CJKHalfWidth] and [Font.CJKProportionalWidth]).
+	// This provides a uniform way to implement Japanese punctuation compression.
+	SyntheticCJKSpacing
+
+	// Flag indicating that, in vertical text, the 'vert' feature should be used
+	// even if the font provides the 'vrt2' feature, which is preferred by default.
+	// This restores the behavior of the previous versions (and of the C library),
+	// since 'vrt2' also rotates the proportional glyphs, like Latin letters.
+	VerticalAlternatesVertOnly
 )
 
 // ClusterLevel allows selecting more fine-grained Cluster handling.
@@ -165,14 +193,34 @@ const (
 type ClusterLevel uint8
 
 const (
-	// Return cluster values grouped by graphemes into monotone order.
+	// Return cluster values grouped by graphemes into monotone order :
+	// the characters of a grapheme always share the same cluster.
 	MonotoneGraphemes ClusterLevel = iota
-	//  Return cluster values grouped into monotone order.
+	// Return cluster values grouped into monotone order : marks and other
+	// grapheme extenders keep their own cluster, unless they are merged by a substitution.
 	MonotoneCharacters
-	// Don't group cluster values.
+	// Don't group cluster values : every character keeps its own cluster, even
+	// when glyphs are reordered or ligated, so that the clusters may not be monotone.
+	// This is the level to use for precise caret positioning.
 	Characters
 )
 
+// SoftHyphenPolicy controls how soft hyphens (U+00AD) are rendered.
+// It defaults to [SoftHyphenInvisible].
+type SoftHyphenPolicy uint8
+
+const (
+	// Soft hyphens are treated as the other default ignorable characters :
+	// they are hidden (or removed, see [RemoveDefaultIgnorables]).
+	SoftHyphenInvisible SoftHyphenPolicy = iota
+	// Soft hyphens are hidden by the shaper, like with [SoftHyphenInvisible],
+	// and are only displayed when a line is broken after them.
+	// This choice is left to the line breaking step : see [Font.HyphenGlyph].
+	SoftHyphenAtLineEnd
+	// Soft hyphens are always displayed, using the glyph returned by [Font.HyphenGlyph].
+	SoftHyphenVisible
+)
+
 func (cl ClusterLevel) String() string {
 	switch cl {
 	case MonotoneCharacters:
@@ -191,6 +239,17 @@ func (cl ClusterLevel) String() string {
 // glyphs which are in clusters between `start` (inclusive) and `end` (exclusive).
 // Setting start to `FeatureGlobalStart` and end to `FeatureGlobalEnd`
 // specifies that the feature always applies to the entire buffer.
+//
+// The ranges are resolved after normalization, on the cluster values of the glyphs,
+// so that they apply to the decomposed characters and follow the glyphs
+// reordered by the complex shapers (like the Indic pre-base matras).
+// With the default [MonotoneGraphemes] cluster level, the characters of a grapheme
+// share the cluster of its first character, so that a range applies to whole graphemes only.
+//
+// When using [Buffer.AddRunes], the cluster values are rune indices : see
+// [ClusterFromUTF8] and [ClusterFromUTF16] to convert offsets from other encodings.
+// When using [Buffer.AddUTF8] or [Buffer.AddUTF16], they are byte or code unit indices,
+// so that no conversion is needed.
 type Feature struct {
 	Tag ot.Tag
 	// Value of the feature: 0 disables the feature, non-zero (usually
@@ -212,6 +271,40 @@ const (
 	FeatureGlobalEnd = maxInt
 )
 
+// ClusterFromUTF8 converts the byte [offset] into the UTF-8 [text] to the cluster
+// value used by [Buffer.AddRunes] with the runes of [text] (as returned by []rune(string(text))),
+// that is the number of runes starting before [offset].
+// It is typically used to compute the [Feature.Start] and [Feature.End] fields.
+//
+// An offset in the middle of a rune is rounded up to the next rune; invalid bytes
+// count as one rune each, as in the conversion to []rune. [FeatureGlobalEnd] is preserved.
+func ClusterFromUTF8(text []byte, offset int) int {
+	if offset == FeatureGlobalEnd {
+		return FeatureGlobalEnd
+	}
+	cluster := 0
+	for i := 0; i < offset && i < len(text); cluster++ {
+		_, size := utf8.DecodeRune(text[i:])
+		i += size
+	}
+	return cluster
+}
+
+// ClusterFromUTF16 is the same as [ClusterFromUTF8], for an [offset] into the UTF-16 [text],
+// given in code units. Surrogate pairs are one rune, and unpaired surrogates
+// count as one rune each, as in [utf16.Decode].
+func ClusterFromUTF16(text []uint16, offset int) int {
+	if offset == FeatureGlobalEnd {
+		return FeatureGlobalEnd
+	}
+	cluster := 0
+	for i := 0; i < offset && i < len(text); cluster++ {
+		_, size := decodeUTF16(text[i:])
+		i += size
+	}
+	return cluster
+}
+
 // ParseVariation parse the string representation of a variation
 // of the form tag=value
 func ParseVariation(s string) (font.Variation, error) {
@@ -2,6 +2,7 @@ package harfbuzz
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/boxesandglue/typesetting/font"
 	ot "github.com/boxesandglue/typesetting/font/opentype"
@@ -455,8 +456,11 @@ type aatApplyContext struct {
 	gdefTable *tables.GDEF
 	ankrTable tables.Ankr
 
-	rangeFlags    []rangeFlags
+	rangeFlags    []rangeFlags // sorted and covering all the clusters
+	allFlags      GlyphMask    // union of the rangeFlags flags
 	subtableFlags GlyphMask
+
+	positionSource PositionSource // kerx or kern, used by applyKernx
 }
 
 func newAatApplyContext(plan *otShapePlan, font *Font, buffer *Buffer) *aatApplyContext {
@@ -469,13 +473,29 @@ func newAatApplyContext(plan *otShapePlan, font *Font, buffer *Buffer) *aatApply
 	return &out
 }
 
-func (c *aatApplyContext) hasAnyFlags(flag GlyphMask) bool {
-	for _, fl := range c.rangeFlags {
-		if fl.flags&flag != 0 {
-			return true
-		}
+func (c *aatApplyContext) setRangeFlags(ranges []rangeFlags) {
+	c.rangeFlags = ranges
+	c.allFlags = 0
+	for _, fl := range ranges {
+		c.allFlags |= fl.flags
 	}
-	return false
+}
+
+func (c *aatApplyContext) hasAnyFlags(flag GlyphMask) bool { return c.allFlags&flag != 0 }
+
+// findRange returns the index of the range containing [cluster].
+// Since clusters are mostly monotone, the range of the previous
+// glyph, [last], and the following one are tried first,
+// before resorting to a binary search.
+func (c *aatApplyContext) findRange(cluster, last int) int {
+	ranges := c.rangeFlags
+	if r := ranges[last]; r.clusterFirst <= cluster && cluster <= r.clusterLast {
+		return last
+	}
+	if next := last + 1; next < len(ranges) && ranges[next].clusterFirst <= cluster && cluster <= ranges[next].clusterLast {
+		return next
+	}
+	return sort.Search(len(ranges)-1, func(i int) bool { return cluster <= ranges[i].clusterLast })
 }
 
 func (c *aatApplyContext) applyMorx(chain font.MorxChain) {
@@ -533,6 +553,7 @@ func (c *aatApplyContext) applyMorx(chain font.MorxChain) {
 		if debugMode {
 			fmt.Printf("MORX - start chainsubtable %d\n", i)
 		}
+		c.buffer.trace(TraceEvent{Kind: TraceSubtableStart, Table: tagMorx, Index: i})
 
 		if reverse {
 			c.buffer.Reverse()
@@ -544,6 +565,7 @@ func (c *aatApplyContext) applyMorx(chain font.MorxChain) {
 			c.buffer.Reverse()
 		}
 
+		c.buffer.trace(TraceEvent{Kind: TraceSubtableEnd, Table: tagMorx, Index: i})
 		if debugMode {
 			fmt.Printf("MORX - end chainsubtable %d\n", i)
 			fmt.Println(c.buffer.Info)
@@ -627,21 +649,11 @@ func (s stateTableDriver) drive(c driverContext, ac *aatApplyContext) {
 		lastRange = 0
 	}
 	for s.buffer.idx = 0; ; {
-		// This block is copied in NoncontextualSubtable::apply. Keep in sync.
 		if lastRange != -1 {
-			range_ := lastRange
 			if s.buffer.idx < len(s.buffer.Info) {
-				cluster := s.buffer.cur(0).Cluster
-				for cluster < ac.rangeFlags[range_].clusterFirst {
-					range_--
-				}
-				for cluster > ac.rangeFlags[range_].clusterLast {
-					range_++
-				}
-
-				lastRange = range_
+				lastRange = ac.findRange(s.buffer.cur(0).Cluster, lastRange)
 			}
-			if !(ac.rangeFlags[range_].flags&ac.subtableFlags != 0) {
+			if ac.rangeFlags[lastRange].flags&ac.subtableFlags == 0 {
 				if s.buffer.idx == len(s.buffer.Info) {
 					break
 				}
@@ -730,6 +742,8 @@ func (s stateTableDriver) drive(c driverContext, ac *aatApplyContext) {
 		if entry.Flags&DontAdvance == 0 {
 			s.buffer.nextGlyph()
 		} else {
+			// malformed fonts may loop forever without advancing:
+			// the operation budget of the buffer guarantees termination
 			if s.buffer.maxOps <= 0 {
 				s.buffer.maxOps--
 				s.buffer.nextGlyph()
@@ -876,6 +890,19 @@ func (dc driverContextContextual) isActionable(driver stateTableDriver, entry ta
 	return markIndex != 0xFFFF || currentIndex != 0xFFFF
 }
 
+// substitute applies the lookup at [index], which may be
+// invalid or empty for malformed fonts
+func (dc *driverContextContextual) substitute(index uint16, glyph GID) (uint16, bool) {
+	if index == 0xFFFF || int(index) >= len(dc.table.Substitutions) {
+		return 0, false
+	}
+	lookup := dc.table.Substitutions[index]
+	if lookup == nil {
+		return 0, false
+	}
+	return lookup.Class(gID(glyph))
+}
+
 func (dc *driverContextContextual) transition(driver stateTableDriver, entry tables.AATStateEntry) {
 	buffer := driver.buffer
 
@@ -890,9 +917,8 @@ func (dc *driverContextContextual) transition(driver stateTableDriver, entry tab
 		hasRep                  bool
 		markIndex, currentIndex = entry.AsMorxContextual()
 	)
-	if markIndex != 0xFFFF {
-		lookup := dc.table.Substitutions[markIndex]
-		replacement, hasRep = lookup.Class(gID(buffer.Info[dc.mark].Glyph))
+	if dc.mark < len(buffer.Info) {
+		replacement, hasRep = dc.substitute(markIndex, buffer.Info[dc.mark].Glyph)
 	}
 	if hasRep {
 		buffer.unsafeToBreak(dc.mark, min(buffer.idx+1, len(buffer.Info)))
@@ -905,10 +931,7 @@ func (dc *driverContextContextual) transition(driver stateTableDriver, entry tab
 
 	hasRep = false
 	idx := min(buffer.idx, len(buffer.Info)-1)
-	if currentIndex != 0xFFFF {
-		lookup := dc.table.Substitutions[currentIndex]
-		replacement, hasRep = lookup.Class(gID(buffer.Info[idx].Glyph))
-	}
+	replacement, hasRep = dc.substitute(currentIndex, buffer.Info[idx].Glyph)
 
 	if hasRep {
 		buffer.Info[idx].Glyph = GID(replacement)
@@ -933,7 +956,7 @@ type driverContextLigature struct {
 func (driverContextLigature) inPlace() bool { return false }
 
 func (driverContextLigature) isActionable(_ stateTableDriver, entry tables.AATStateEntry) bool {
-	return entry.Flags&tables.MLOffset != 0
+	return entry.Flags&tables.MLPerformAction != 0
 }
 
 func (dc *driverContextLigature) transition(driver stateTableDriver, entry tables.AATStateEntry) {
@@ -975,7 +998,10 @@ func (dc *driverContextLigature) transition(driver stateTableDriver, entry table
 		}
 		cursor := dc.matchLength
 
-		actionIdx := entry.AsMorxLigature()
+		actionIdx := int(entry.AsMorxLigature())
+		if actionIdx >= len(dc.table.LigatureAction) {
+			return
+		}
 		actionData := dc.table.LigatureAction[actionIdx:]
 
 		ligatureIdx := 0
@@ -995,7 +1021,9 @@ func (dc *driverContextLigature) transition(driver stateTableDriver, entry table
 			}
 
 			cursor--
-			buffer.moveTo(dc.matchPositions[cursor%len(dc.matchPositions)])
+			if !buffer.moveTo(dc.matchPositions[cursor%len(dc.matchPositions)]) {
+				break
+			}
 
 			if len(actionData) == 0 {
 				break
@@ -1007,8 +1035,11 @@ func (dc *driverContextLigature) transition(driver stateTableDriver, entry table
 				uoffset |= 0xC0000000 /* Sign-extend. */
 			}
 			offset := int32(uoffset)
-			componentIdx := int32(buffer.cur(0).Glyph) + offset
-			if int(componentIdx) >= len(dc.table.Components) {
+			if buffer.idx >= len(buffer.Info) {
+				break
+			}
+			componentIdx := int(buffer.cur(0).Glyph) + int(offset)
+			if componentIdx < 0 || componentIdx >= len(dc.table.Components) {
 				break
 			}
 			componentData := dc.table.Components[componentIdx]
@@ -1039,11 +1070,15 @@ func (dc *driverContextLigature) transition(driver stateTableDriver, entry table
 					}
 
 					dc.matchLength--
-					buffer.moveTo(dc.matchPositions[dc.matchLength%len(dc.matchPositions)])
+					if !buffer.moveTo(dc.matchPositions[dc.matchLength%len(dc.matchPositions)]) {
+						break
+					}
 					buffer.replaceGlyphIndex(0xFFFF)
 				}
 
-				buffer.moveTo(ligEnd)
+				if !buffer.moveTo(ligEnd) {
+					break
+				}
 				buffer.mergeOutClusters(dc.matchPositions[cursor%len(dc.matchPositions)], len(buffer.outInfo))
 			}
 
@@ -1133,13 +1168,18 @@ func (dc *driverContextInsertion) transition(driver stateTableDriver, entry tabl
 		if buffer.maxOps <= 0 {
 			return
 		}
-		start := markedInsertIndex
+		start := int(markedInsertIndex)
+		if start+count > len(dc.insertionAction) {
+			return // checked when parsing
+		}
 		glyphs := dc.insertionAction[start:]
 
 		before := flags&miMarkedInsertBefore != 0
 
 		end := len(buffer.outInfo)
-		buffer.moveTo(dc.mark)
+		if !buffer.moveTo(dc.mark) {
+			return
+		}
 
 		if buffer.idx < len(buffer.Info) && !before {
 			buffer.copyGlyph()
@@ -1167,7 +1207,10 @@ func (dc *driverContextInsertion) transition(driver stateTableDriver, entry tabl
 			return
 		}
 		buffer.maxOps -= count
-		start := currentInsertIndex
+		start := int(currentInsertIndex)
+		if start+count > len(dc.insertionAction) {
+			return // checked when parsing
+		}
 		glyphs := dc.insertionAction[start:]
 
 		before := flags&miCurrentInsertBefore != 0
@@ -1219,19 +1262,9 @@ func (c *aatApplyContext) applyNonContextualSubtable(data font.MorxNonContextual
 		lastRange = 0
 	}
 	for i := range info {
-		// This block is copied in NoncontextualSubtable::apply. Keep in sync.
 		if lastRange != -1 {
-			range_ := lastRange
-			cluster := info[i].Cluster
-			for cluster < c.rangeFlags[range_].clusterFirst {
-				range_--
-			}
-			for cluster > c.rangeFlags[range_].clusterLast {
-				range_++
-			}
-
-			lastRange = range_
-			if c.rangeFlags[range_].flags&c.subtableFlags == 0 {
+			lastRange = c.findRange(info[i].Cluster, lastRange)
+			if c.rangeFlags[lastRange].flags&c.subtableFlags == 0 {
 				continue
 			}
 		}
@@ -1286,10 +1319,12 @@ func (sp *otShapePlan) aatLayoutSubstitute(font *Font, buffer *Buffer, features
 
 	c := newAatApplyContext(sp, font, buffer)
 	c.buffer.unsafeToConcat(0, maxInt)
+	buffer.trace(TraceEvent{Kind: TraceTableStart, Table: tagMorx})
 	for i, chain := range morx {
-		c.rangeFlags = map_.chainFlags[i]
+		c.setRangeFlags(map_.chainFlags[i])
 		c.applyMorx(chain)
 	}
+	buffer.trace(TraceEvent{Kind: TraceTableEnd, Table: tagMorx})
 	// NOTE: we dont support obsolete 'mort' table
 }
 
@@ -1311,12 +1346,20 @@ func (sp *otShapePlan) aatLayoutPosition(font *Font, buffer *Buffer) {
 
 	c := newAatApplyContext(sp, font, buffer)
 	c.ankrTable = font.face.Ankr
+	c.positionSource = PositionKerx
 	c.applyKernx(kerx)
 }
 
 func (c *aatApplyContext) applyKernx(kerx font.Kernx) {
 	var ret, seenCrossStream bool
 
+	table := tagKerx
+	if c.positionSource == PositionKern {
+		table = tagKern
+	}
+	c.buffer.trace(TraceEvent{Kind: TraceTableStart, Table: table})
+	defer c.buffer.trace(TraceEvent{Kind: TraceTableEnd, Table: table})
+
 	c.buffer.unsafeToConcat(0, maxInt)
 	for i, st := range kerx {
 		var reverse bool
@@ -1333,6 +1376,7 @@ func (c *aatApplyContext) applyKernx(kerx font.Kernx) {
 		if debugMode {
 			fmt.Printf("AAT kerx : start subtable %d\n", i)
 		}
+		c.buffer.trace(TraceEvent{Kind: TraceSubtableStart, Table: table, Index: i})
 
 		if !seenCrossStream && st.IsCrossStream() {
 			/* Attach all glyphs into a chain. */
@@ -1362,6 +1406,7 @@ func (c *aatApplyContext) applyKernx(kerx font.Kernx) {
 			c.buffer.Reverse()
 		}
 
+		c.buffer.trace(TraceEvent{Kind: TraceSubtableEnd, Table: table, Index: i})
 		if debugMode {
 			fmt.Printf("AAT kerx : end subtable %d\n", i)
 			fmt.Println(c.buffer.Pos)
@@ -1382,7 +1427,7 @@ func (c *aatApplyContext) applyKerxSubtable(st font.KernSubtable) bool {
 		if st.IsBackwards() {
 			return false
 		}
-		kern(data, st.IsCrossStream(), c.font, c.buffer, c.plan.kernMask, true)
+		kern(data, st.IsCrossStream(), c.font, c.buffer, c.plan.kernMask, true, c.positionSource)
 	case font.Kern1:
 		crossStream := st.IsCrossStream()
 		if !c.plan.requestedKerning && !crossStream {
@@ -1398,7 +1443,7 @@ func (c *aatApplyContext) applyKerxSubtable(st font.KernSubtable) bool {
 		if st.IsBackwards() {
 			return false
 		}
-		kern(data, st.IsCrossStream(), c.font, c.buffer, c.plan.kernMask, true)
+		kern(data, st.IsCrossStream(), c.font, c.buffer, c.plan.kernMask, true, c.positionSource)
 	case font.Kern3:
 		if !c.plan.requestedKerning {
 			return false
@@ -1406,7 +1451,7 @@ func (c *aatApplyContext) applyKerxSubtable(st font.KernSubtable) bool {
 		if st.IsBackwards() {
 			return false
 		}
-		kern(data, st.IsCrossStream(), c.font, c.buffer, c.plan.kernMask, true)
+		kern(data, st.IsCrossStream(), c.font, c.buffer, c.plan.kernMask, true, c.positionSource)
 	case font.Kern4:
 		crossStream := st.IsCrossStream()
 		if !c.plan.requestedKerning && !crossStream {
@@ -1422,7 +1467,7 @@ func (c *aatApplyContext) applyKerxSubtable(st font.KernSubtable) bool {
 		if st.IsBackwards() {
 			return false
 		}
-		kern(data, st.IsCrossStream(), c.font, c.buffer, c.plan.kernMask, true)
+		kern(data, st.IsCrossStream(), c.font, c.buffer, c.plan.kernMask, true, c.positionSource)
 	}
 	return true
 }
@@ -1469,7 +1514,11 @@ func (dc *driverContextKerx1) transition(driver stateTableDriver, entry tables.A
 	if dc.isActionable(driver, entry) && dc.depth != 0 {
 		tupleCount := 1 // we do not support tupleCount > 0
 
-		kernIdx := entry.AsKernxIndex()
+		kernIdx := int(entry.AsKernxIndex())
+		if kernIdx > len(dc.table.Values) {
+			dc.depth = 0
+			return
+		}
 
 		actions := dc.table.Values[kernIdx:]
 		if len(actions) < tupleCount*dc.depth {
@@ -1507,11 +1556,13 @@ func (dc *driverContextKerx1) transition(driver stateTableDriver, entry tables.A
 						o.YOffset = 0
 					} else if o.attachType != 0 {
 						o.YOffset += dc.c.font.emScaleY(v)
+						o.Source = dc.c.positionSource
 						buffer.scratchFlags |= bsfHasGPOSAttachment
 					}
 				} else if buffer.Info[idx].Mask&kernMask != 0 {
 					o.XAdvance += dc.c.font.emScaleX(v)
 					o.XOffset += dc.c.font.emScaleX(v)
+					o.Source = dc.c.positionSource
 				}
 			} else {
 				if dc.crossStream {
@@ -1522,11 +1573,13 @@ func (dc *driverContextKerx1) transition(driver stateTableDriver, entry tables.A
 						o.XOffset = 0
 					} else if o.attachType != 0 {
 						o.XOffset += dc.c.font.emScaleX(v)
+						o.Source = dc.c.positionSource
 						buffer.scratchFlags |= bsfHasGPOSAttachment
 					}
 				} else if buffer.Info[idx].Mask&kernMask != 0 {
 					o.YAdvance += dc.c.font.emScaleY(v)
 					o.YOffset += dc.c.font.emScaleY(v)
+					o.Source = dc.c.positionSource
 				}
 			}
 		}
@@ -1551,12 +1604,16 @@ func (dc *driverContextKerx4) transition(driver stateTableDriver, entry tables.A
 	buffer := driver.buffer
 
 	ankrActionIndex := entry.AsKernxIndex()
-	if dc.markSet && ankrActionIndex != 0xFFFF && buffer.idx < len(buffer.Pos) {
+	if dc.markSet && ankrActionIndex != 0xFFFF && buffer.idx < len(buffer.Pos) && dc.mark < len(buffer.Info) {
 		o := buffer.curPos(0)
 		switch dc.actionType {
 		case 0: /* Control Point Actions.*/
 			/* Indexed into glyph outline. */
-			action := dc.table.Anchors.(tables.KerxAnchorControls).Anchors[ankrActionIndex]
+			anchors, _ := dc.table.Anchors.(tables.KerxAnchorControls)
+			if int(ankrActionIndex) >= len(anchors.Anchors) {
+				return
+			}
+			action := anchors.Anchors[ankrActionIndex]
 
 			markX, markY, okMark := dc.c.font.getGlyphContourPointForOrigin(dc.c.buffer.Info[dc.mark].Glyph,
 				action.Mark, LeftToRight)
@@ -1571,7 +1628,11 @@ func (dc *driverContextKerx4) transition(driver stateTableDriver, entry tables.A
 
 		case 1: /* Anchor Point Actions. */
 			/* Indexed into 'ankr' table. */
-			action := dc.table.Anchors.(tables.KerxAnchorAnchors).Anchors[ankrActionIndex]
+			anchors, _ := dc.table.Anchors.(tables.KerxAnchorAnchors)
+			if int(ankrActionIndex) >= len(anchors.Anchors) {
+				return
+			}
+			action := anchors.Anchors[ankrActionIndex]
 
 			markAnchor := dc.c.ankrTable.GetAnchor(gID(dc.c.buffer.Info[dc.mark].Glyph), int(action.Mark))
 			currAnchor := dc.c.ankrTable.GetAnchor(gID(dc.c.buffer.cur(0).Glyph), int(action.Current))
@@ -1580,12 +1641,17 @@ func (dc *driverContextKerx4) transition(driver stateTableDriver, entry tables.A
 			o.YOffset = dc.c.font.emScaleY(markAnchor.Y) - dc.c.font.emScaleY(currAnchor.Y)
 
 		case 2: /* Control Point Coordinate Actions. */
-			action := dc.table.Anchors.(tables.KerxAnchorCoordinates).Anchors[ankrActionIndex]
+			anchors, _ := dc.table.Anchors.(tables.KerxAnchorCoordinates)
+			if int(ankrActionIndex) >= len(anchors.Anchors) {
+				return
+			}
+			action := anchors.Anchors[ankrActionIndex]
 			o.XOffset = dc.c.font.emScaleX(action.MarkX) - dc.c.font.emScaleX(action.CurrentX)
 			o.YOffset = dc.c.font.emScaleY(action.MarkY) - dc.c.font.emScaleY(action.CurrentY)
 		}
 		o.attachType = attachTypeMark
 		o.attachChain = int16(dc.mark - buffer.idx)
+		o.Source = dc.c.positionSource
 		buffer.scratchFlags |= bsfHasGPOSAttachment
 	}
 
@@ -1607,14 +1673,14 @@ func (c *aatApplyContext) applyTrak(trak tables.Trak) {
 	trakMask := c.plan.trakMask
 
 	ptem := c.font.Ptem
-	if ptem <= 0. {
+	if ptem <= 0. || c.font.trackDisabled {
 		return
 	}
 
 	buffer := c.buffer
 	if buffer.Props.Direction.isHorizontal() {
 		trackData := trak.Horiz
-		tracking := int(getTracking(trackData, ptem, 0))
+		tracking := int(getTracking(trackData, ptem, c.font.track))
 		advanceToAdd := c.font.emScalefX(float32(tracking))
 		offsetToAdd := c.font.emScalefX(float32(tracking / 2))
 
@@ -1625,11 +1691,12 @@ func (c *aatApplyContext) applyTrak(trak tables.Trak) {
 			}
 			buffer.Pos[start].XAdvance += advanceToAdd
 			buffer.Pos[start].XOffset += offsetToAdd
+			buffer.Pos[start].Source = PositionTrak
 		}
 
 	} else {
 		trackData := trak.Vert
-		tracking := int(getTracking(trackData, ptem, 0))
+		tracking := int(getTracking(trackData, ptem, c.font.track))
 		advanceToAdd := c.font.emScalefY(float32(tracking))
 		offsetToAdd := c.font.emScalefY(float32(tracking / 2))
 		iter, count := buffer.graphemesIterator()
@@ -1639,6 +1706,7 @@ func (c *aatApplyContext) applyTrak(trak tables.Trak) {
 			}
 			buffer.Pos[start].YAdvance += advanceToAdd
 			buffer.Pos[start].YOffset += offsetToAdd
+			buffer.Pos[start].Source = PositionTrak
 		}
 
 	}
@@ -1,11 +1,20 @@
 package harfbuzz
 
 import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
 	"github.com/boxesandglue/typesetting/font/opentype/tables"
+	"github.com/boxesandglue/typesetting/language"
 	tu "github.com/boxesandglue/typesetting/testutils"
+	td "github.com/go-text/typesetting-utils/harfbuzz"
 )
 
 // ported from harfbuzz/test/api/test-aat-layout.c Copyright © 2018  Ebrahim Byagowi
@@ -58,3 +67,245 @@ func TestAatHas(t *testing.T) {
 	trak := openFontFile(t, "fonts/aat-trak.ttf")
 	tu.Assert(t, !trak.Trak.IsEmpty())
 }
+
+func TestSetTracking(t *testing.T) {
+	hbFont := NewFont(font.NewFace(openFontFile(t, "fonts/aat-trak.ttf")))
+	hbFont.Ptem = 1 // the 'normal' track adds 200 units
+
+	advances := func() []Position {
+		buf := NewBuffer()
+		buf.AddRunes([]rune("AA"), 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(hbFont, nil)
+		out := make([]Position, len(buf.Pos))
+		for i, pos := range buf.Pos {
+			out[i] = pos.XAdvance
+		}
+		return out
+	}
+
+	tracked := advances()
+	track, enabled := hbFont.Tracking()
+	tu.Assert(t, track == 0 && enabled)
+
+	hbFont.DisableTracking()
+	_, enabled = hbFont.Tracking()
+	tu.Assert(t, !enabled)
+	untracked := advances()
+	for i := range tracked {
+		tu.Assert(t, tracked[i] == untracked[i]+200)
+	}
+
+	// the font has no loose track
+	hbFont.SetTracking(1)
+	tu.Assert(t, reflect.DeepEqual(advances(), untracked))
+
+	hbFont.SetTracking(0)
+	tu.Assert(t, reflect.DeepEqual(advances(), tracked))
+}
+
+// ligaFont returns a font with one morx chain, whose
+// 'liga' feature enables the flag 2, in addition to the default flag 1
+func ligaFont(t testing.TB) *font.Font {
+	ft := openFontFile(t, "harfbuzz_reference/text-rendering-tests/fonts/TestMORXTwo.ttf")
+	tu.Assert(t, len(ft.Morx) == 1)
+	ft.Feat = tables.Feat{Names: []tables.FeatureName{{
+		Feature:      aatLayoutFeatureTypeLigatures,
+		SettingTable: []tables.FeatureSettingName{{Setting: aatLayoutFeatureSelectorCommonLigaturesOn}},
+	}}}
+	ft.Morx[0].DefaultFlags = 1
+	ft.Morx[0].Features = []tables.AATFeature{{
+		FeatureType:    aatLayoutFeatureTypeLigatures,
+		FeatureSetting: aatLayoutFeatureSelectorCommonLigaturesOn,
+		EnableFlags:    2,
+		DisableFlags:   ^uint32(2),
+	}}
+	return ft
+}
+
+func TestAATMapRanges(t *testing.T) {
+	ft := ligaFont(t)
+	liga := func(start, end int) Feature {
+		return Feature{Tag: ot.NewTag('l', 'i', 'g', 'a'), Value: 1, Start: start, End: end}
+	}
+	for _, test := range []struct {
+		features []Feature
+		expected []rangeFlags
+	}{
+		{nil, []rangeFlags{{1, 0, FeatureGlobalEnd}}},
+		{[]Feature{liga(0, 10), liga(20, 30)}, []rangeFlags{{3, 0, 9}, {1, 10, 19}, {3, 20, 29}, {1, 30, FeatureGlobalEnd}}},
+		// adjacent and overlapping ranges are merged
+		{[]Feature{liga(0, 10), liga(10, 20), liga(15, 30)}, []rangeFlags{{3, 0, 29}, {1, 30, FeatureGlobalEnd}}},
+		{[]Feature{liga(5, 100)}, []rangeFlags{{1, 0, 4}, {3, 5, 99}, {1, 100, FeatureGlobalEnd}}},
+	} {
+		builder := newAatMapBuilder(ft, SegmentProperties{})
+		for _, feature := range test.features {
+			builder.addFeature(feature)
+		}
+		var map_ aatMap
+		builder.compile(&map_)
+		tu.AssertC(t, reflect.DeepEqual(map_.chainFlags[0], test.expected), fmt.Sprint(map_.chainFlags[0]))
+	}
+}
+
+func TestAATLanguageTag(t *testing.T) {
+	ft := ligaFont(t)
+	// a 'ltag' table with one tag : "tr"
+	ltag := []byte{
+		0, 0, 0, 1, // version
+		0, 0, 0, 0, // flags
+		0, 0, 0, 1, // numTags
+		0, 16, 0, 2, // range
+		't', 'r',
+	}
+	var err error
+	ft.Ltag, _, err = tables.ParseLtag(ltag)
+	tu.AssertNoErr(t, err)
+	ft.Morx[0].Features = []tables.AATFeature{
+		{FeatureType: aatLayoutFeatureTypeLanguageTagType, FeatureSetting: 1, EnableFlags: 4, DisableFlags: ^uint32(4)},
+		// invalid index in the 'ltag' table
+		{FeatureType: aatLayoutFeatureTypeLanguageTagType, FeatureSetting: 2, EnableFlags: 8, DisableFlags: ^uint32(8)},
+	}
+
+	for _, test := range []struct {
+		lang     string
+		expected GlyphMask
+	}{
+		{"", 1},
+		{"tr", 5},
+		{"tr-TR", 5},
+		{"trk", 1},
+		{"pl", 1},
+	} {
+		builder := newAatMapBuilder(ft, SegmentProperties{Language: language.NewLanguage(test.lang)})
+		var map_ aatMap
+		builder.compile(&map_)
+		tu.AssertC(t, map_.chainFlags[0][0].flags == test.expected, test.lang)
+	}
+}
+
+func TestAATFindRange(t *testing.T) {
+	var ranges []rangeFlags
+	for i := 0; i < 100; i++ {
+		ranges = append(ranges, rangeFlags{GlyphMask(i), 5 * i, 5*i + 4})
+	}
+	ranges[len(ranges)-1].clusterLast = FeatureGlobalEnd
+
+	var c aatApplyContext
+	c.setRangeFlags(ranges)
+	tu.Assert(t, c.hasAnyFlags(64) && !c.hasAnyFlags(128))
+
+	for _, last := range []int{0, 10, 50, 99} {
+		for cluster := 0; cluster < 600; cluster++ {
+			expected := cluster / 5
+			if expected >= len(ranges) {
+				expected = len(ranges) - 1
+			}
+			tu.Assert(t, c.findRange(cluster, last) == expected)
+		}
+	}
+}
+
+func BenchmarkAATFeatureRanges(b *testing.B) {
+	hbFont := NewFont(font.NewFace(ligaFont(b)))
+	text := []rune(strings.Repeat("abc ", 500))
+	var features []Feature
+	for i := 0; i < len(text); i += 8 { // 250 spans
+		features = append(features, Feature{Tag: ot.NewTag('l', 'i', 'g', 'a'), Value: 1, Start: i, End: i + 4})
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := NewBuffer()
+		buf.AddRunes(text, 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(hbFont, features)
+	}
+}
+
+// withTable returns the font file [src], with the table [tag] replaced by [content].
+func withTable(t testing.TB, src []byte, tag ot.Tag, content []byte) []byte {
+	ld, err := ot.NewLoader(bytes.NewReader(src))
+	tu.AssertNoErr(t, err)
+	var out []ot.Table
+	for _, tg := range ld.Tables() {
+		table, err := ld.RawTable(tg)
+		tu.AssertNoErr(t, err)
+		if tg == tag {
+			table = content
+		}
+		out = append(out, ot.Table{Tag: tg, Content: table})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Tag < out[j].Tag })
+	return ot.WriteTTF(out)
+}
+
+var morxTestFonts = []string{
+	"harfbuzz_reference/text-rendering-tests/fonts/TestMORXOne.ttf",         // rearrangement
+	"harfbuzz_reference/text-rendering-tests/fonts/TestMORXTwentyone.ttf",   // contextual
+	"harfbuzz_reference/text-rendering-tests/fonts/TestMORXTwentysix.ttf",   // ligature
+	"harfbuzz_reference/text-rendering-tests/fonts/TestMORXThirtyone.ttf",   // ligature
+	"harfbuzz_reference/text-rendering-tests/fonts/TestMORXTwentythree.ttf", // insertion
+	"harfbuzz_reference/text-rendering-tests/fonts/TestMORXForty.ttf",       // insertion
+}
+
+// shapeMorx loads the font file [src] and shapes [text] with it,
+// returning false if the font can't be loaded
+func shapeMorx(src []byte, text []rune) bool {
+	ld, err := ot.NewLoader(bytes.NewReader(src))
+	if err != nil {
+		return false
+	}
+	ft, err := font.NewFont(ld)
+	if err != nil {
+		return false
+	}
+	buf := NewBuffer()
+	buf.AddRunes(text, 0, -1)
+	buf.GuessSegmentProperties()
+	buf.Shape(NewFont(font.NewFace(ft)), nil)
+	return true
+}
+
+// cmapRunes returns (at most [n]) runes supported by the font
+func cmapRunes(ft *font.Font, n int) []rune {
+	var out []rune
+	iter := ft.Cmap.Iter()
+	for iter.Next() && len(out) < n {
+		r, _ := iter.Char()
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// TestAATMalformed shapes with randomly corrupted 'morx' tables:
+// the state machines must neither panic nor loop forever.
+func TestAATMalformed(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, filename := range morxTestFonts {
+		src, err := td.Files.ReadFile(filename)
+		tu.AssertNoErr(t, err)
+		ld, err := ot.NewLoader(bytes.NewReader(src))
+		tu.AssertNoErr(t, err)
+		morx, err := ld.RawTable(tagMorx)
+		tu.AssertNoErr(t, err)
+		ft, err := font.NewFont(ld)
+		tu.AssertNoErr(t, err)
+		tu.Assert(t, len(ft.Morx) != 0)
+
+		runes := cmapRunes(ft, 20)
+		for i := 0; i < 200; i++ {
+			corrupted := append([]byte(nil), morx...)
+			for j := rng.Intn(8); j >= 0; j-- {
+				corrupted[rng.Intn(len(corrupted))] = byte(rng.Intn(256))
+			}
+			text := make([]rune, 1+rng.Intn(12))
+			for k := range text {
+				text[k] = runes[rng.Intn(len(runes))]
+			}
+			shapeMorx(withTable(t, src, tagMorx, corrupted), text)
+		}
+	}
+}
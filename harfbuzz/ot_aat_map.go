@@ -115,8 +115,15 @@ func (mb *aatMapBuilder) compileMap(map_ *aatMap) {
 	morx := mb.tables.Morx
 	map_.resizeChainFlags(len(morx))
 	for i, chain := range morx {
+		flags := mb.compileMorxFlag(chain)
+		// merge with the previous range if possible, to keep
+		// the number of ranges low when many features are used
+		if ranges := map_.chainFlags[i]; len(ranges) != 0 && ranges[len(ranges)-1].flags == flags {
+			ranges[len(ranges)-1].clusterLast = mb.rangeLast
+			continue
+		}
 		map_.chainFlags[i] = append(map_.chainFlags[i], rangeFlags{
-			flags:        mb.compileMorxFlag(chain),
+			flags:        flags,
 			clusterFirst: mb.rangeFirst,
 			clusterLast:  mb.rangeLast,
 		})
@@ -148,7 +155,7 @@ func (mb *aatMapBuilder) compileMorxFlag(chain font.MorxChain) GlyphMask {
 			type_ = aatLayoutFeatureTypeLowerCase
 			setting = aatLayoutFeatureSelectorLowerCaseSmallCaps
 			goto retry
-		} else if type_ == aatLayoutFeatureTypeLanguageTagType && setting != 0 && langMatches(string(mb.tables.Ltag.Language(setting-1)), string(mb.props.Language)) {
+		} else if type_ == aatLayoutFeatureTypeLanguageTagType && setting != 0 && mb.matchesLtag(setting-1) {
 			flags &= feature.DisableFlags
 			flags |= feature.EnableFlags
 		}
@@ -156,6 +163,14 @@ func (mb *aatMapBuilder) compileMorxFlag(chain font.MorxChain) GlyphMask {
 	return flags
 }
 
+// matchesLtag returns true if the language of the buffer is the same as,
+// or more specific than, the language tag at [index] in the 'ltag' table,
+// so that "tr-TR" text selects the settings registered for "tr".
+func (mb *aatMapBuilder) matchesLtag(index uint16) bool {
+	tag := mb.tables.Ltag.Language(index)
+	return tag != "" && langMatches(string(mb.props.Language), string(tag))
+}
+
 func (mb *aatMapBuilder) addFeature(feature Feature) {
 	feat := mb.tables.Feat
 	if len(feat.Names) == 0 {
@@ -204,6 +219,10 @@ func (mb *aatMapBuilder) addFeature(feature Feature) {
 	info.type_ = mapping.aatFeatureType
 	if feature.Value != 0 {
 		info.setting = mapping.selectorToEnable
+	} else if def, ok := featureName.DefaultSetting(); ok && def != mapping.selectorToEnable {
+		// for exclusive features, restore the default setting
+		// declared by the font, which may differ from the usual one
+		info.setting = def
 	} else {
 		info.setting = mapping.selectorToDisable
 	}
@@ -3,11 +3,13 @@ package harfbuzz
 import (
 	"fmt"
 	"sort"
+	"sync"
 
 	"github.com/boxesandglue/typesetting/font"
 	ot "github.com/boxesandglue/typesetting/font/opentype"
 	"github.com/boxesandglue/typesetting/font/opentype/tables"
 	"github.com/boxesandglue/typesetting/language"
+	ucd "github.com/boxesandglue/typesetting/unicodedata"
 )
 
 // ported from harfbuzz/src/hb-ot-shape-complex-arabic.cc, hb-ot-shape-complex-arabic-fallback.hh Copyright © 2010,2012  Google, Inc. Behdad Esfahbod
@@ -101,6 +103,19 @@ func getJoiningType(u rune, genCat generalCategory) uint8 {
 	return joiningTypeU
 }
 
+// ArabicJoiningType returns the joining type of [r], as used by the Arabic shaper.
+// Runes absent from the Unicode joining data are either transparent
+// (marks and format characters) or non joining.
+func ArabicJoiningType(r rune) ucd.ArabicJoining {
+	if jType, ok := arabicJoinings[r]; ok {
+		return ucd.ArabicJoining(jType)
+	}
+	if getJoiningType(r, uni.generalCategory(r)) == joiningTypeT {
+		return ucd.T
+	}
+	return ucd.U
+}
+
 func featureIsSyriac(tag ot.Tag) bool {
 	return '2' <= byte(tag) && byte(tag) <= '3'
 }
@@ -207,6 +222,9 @@ func (cs *complexShaperArabic) collectFeatures(plan *otShapePlanner) {
 
 	for _, arabFeat := range arabicFeatures {
 		hasFallback := plan.props.Script == language.Arabic && !featureIsSyriac(arabFeat)
+		if !hasFallback && !plan.capabilities.Has(CapArabicGSUB) {
+			continue // the feature can't be found in the font
+		}
 		fl := ffNone
 		if hasFallback {
 			fl = ffHasFallback
@@ -246,8 +264,15 @@ func (cs *complexShaperArabic) collectFeatures(plan *otShapePlanner) {
 	map_.enableFeatureExt(ot.NewTag('m', 's', 'e', 't'), ffManualZWJ, 1)
 }
 
+// arabicFallbackCache lazily builds the fallback plan,
+// so that concurrent shaping with the same plan is safe
+type arabicFallbackCache struct {
+	once sync.Once
+	plan *arabicFallbackPlan
+}
+
 type arabicShapePlan struct {
-	fallbackPlan *arabicFallbackPlan
+	fallback *arabicFallbackCache // non nil if doFallback is true
 	/* The "+ 1" in the next array is to accommodate for the "NONE" command,
 	* which is not an OpenType feature, but this simplifies the code by not
 	* having to do a "if (... < NONE) ..." and just rely on the fact that
@@ -267,6 +292,9 @@ func newArabicPlan(plan *otShapePlan) arabicShapePlan {
 		arabicPlan.doFallback = arabicPlan.doFallback &&
 			(featureIsSyriac(arabFeat) || plan.map_.needsFallback(arabFeat))
 	}
+	if arabicPlan.doFallback {
+		arabicPlan.fallback = new(arabicFallbackCache)
+	}
 	return arabicPlan
 }
 
@@ -368,19 +396,19 @@ func (cs *complexShaperArabic) setupMasks(plan *otShapePlan, buffer *Buffer, _ *
 }
 
 func arabicFallbackShape(plan *otShapePlan, font *Font, buffer *Buffer) bool {
-	arabicPlan := plan.shaper.(*complexShaperArabic).plan
+	arabicPlan := &plan.shaper.(*complexShaperArabic).plan
 
 	if !arabicPlan.doFallback {
 		return false
 	}
 
-	fallbackPlan := arabicPlan.fallbackPlan
-	if fallbackPlan == nil {
-		// this sucks. We need a font to build the fallback plan...
-		fallbackPlan = newArabicFallbackPlan(plan, font)
-	}
+	// this sucks. We need a font to build the fallback plan...
+	// Since shape plans are cached per face, and the fallback plan only
+	// depends on the cmap, it is built once and stored with the shape plan.
+	fallback := arabicPlan.fallback
+	fallback.once.Do(func() { fallback.plan = newArabicFallbackPlan(plan, font) })
 
-	fallbackPlan.shape(font, buffer)
+	fallback.plan.shape(font, buffer)
 	return true
 }
 
@@ -553,6 +581,8 @@ func (cs *complexShaperArabic) postprocessGlyphs(plan *otShapePlan, buffer *Buff
 		}
 
 		if step == MEASURE { // enlarge
+			// the attachment chains are invalidated by the new glyphs
+			buffer.clearAttachments()
 			buffer.Info = append(buffer.Info, make([]GlyphInfo, extraGlyphsNeeded)...)
 			buffer.Pos = append(buffer.Pos, make([]GlyphPosition, extraGlyphsNeeded)...)
 		}
@@ -841,7 +871,7 @@ func (fbPlan *arabicFallbackPlan) initWin1256(plan *otShapePlan, font *Font) boo
 		fbPlan.maskArray[j] = plan.map_.getMask1(man.tag)
 		if fbPlan.maskArray[j] != 0 {
 			if man.lookup != nil {
-				fbPlan.accelArray[j].init(*man.lookup)
+				fbPlan.accelArray[j].init(*man.lookup, DigestAccurate)
 				j++
 			}
 		}
@@ -859,7 +889,7 @@ func (fbPlan *arabicFallbackPlan) initUnicode(plan *otShapePlan, font *Font) boo
 		if fbPlan.maskArray[j] != 0 {
 			lk := arabicFallbackSynthesizeLookup(font, i)
 			if lk != nil {
-				fbPlan.accelArray[j].init(*lk)
+				fbPlan.accelArray[j].init(*lk, DigestAccurate)
 				j++
 			}
 		}
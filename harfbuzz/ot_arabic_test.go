@@ -3,7 +3,9 @@ package harfbuzz
 import (
 	"testing"
 
+	"github.com/boxesandglue/typesetting/font"
 	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
 )
 
 func TestNumArabicLookup(t *testing.T) {
@@ -20,3 +22,66 @@ func TestHasArabicJoining(t *testing.T) {
 		t.Fatal()
 	}
 }
+
+func TestArabicFallbackPlanCache(t *testing.T) {
+	// this font has no GSUB table, but maps the presentation forms
+	face := font.NewFace(openFontFile(t, "harfbuzz_reference/in-house/fonts/df768b9c257e0c9c35786c47cae15c46571d56be.ttf"))
+	hbFont := NewFont(face)
+	tu.Assert(t, len(face.GSUB.Lookups) == 0)
+
+	buf := NewBuffer()
+	shape := func() []GID {
+		buf.Clear()
+		buf.AddRunes([]rune("\u0633\u0644\u0627\u0645"), 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(hbFont, nil)
+		var out []GID
+		for _, info := range buf.Info {
+			out = append(out, info.Glyph)
+		}
+		return out
+	}
+
+	glyphs := shape()
+	plans := buf.planCache[face]
+	tu.Assert(t, len(plans) == 1)
+	fallbackPlan := plans[0].shaper.plan.shaper.(*complexShaperArabic).plan.fallback.plan
+	tu.Assert(t, fallbackPlan != nil && fallbackPlan.numLookups != 0)
+
+	// initial seen and final lam-alef ligature use the presentation forms,
+	// meem is isolated (and its presentation form is not mapped by the font)
+	seen, _ := face.NominalGlyph(0xFEB3)
+	lamAlef, _ := face.NominalGlyph(0xFEFC)
+	meem, _ := face.NominalGlyph(0x0645)
+	tu.Assert(t, seen != 0 && lamAlef != 0 && meem != 0)
+	tu.Assert(t, len(glyphs) == 3 && glyphs[0] == meem && glyphs[1] == lamAlef && glyphs[2] == seen)
+
+	// the fallback plan is reused
+	tu.Assert(t, len(shape()) == 3)
+	tu.Assert(t, plans[0].shaper.plan.shaper.(*complexShaperArabic).plan.fallback.plan == fallbackPlan)
+}
+
+func TestInvalidMarkFilteringSet(t *testing.T) {
+	ft := openFontFileTT(t, "common/NotoSansArabic.ttf")
+	ft.GSUB.Lookups = append([]font.GSUBLookup(nil), ft.GSUB.Lookups...)
+	ft.GPOS.Lookups = append([]font.GPOSLookup(nil), ft.GPOS.Lookups...)
+	n := 0
+	for i := range ft.GSUB.Lookups {
+		if lk := &ft.GSUB.Lookups[i]; lk.Flag&font.UseMarkFilteringSet != 0 {
+			lk.MarkFilteringSet, n = 100, n+1
+		}
+	}
+	for i := range ft.GPOS.Lookups {
+		if lk := &ft.GPOS.Lookups[i]; lk.Flag&font.UseMarkFilteringSet != 0 {
+			lk.MarkFilteringSet, n = 100, n+1
+		}
+	}
+	tu.Assert(t, n != 0 && ft.ValidateMarkFilteringSets() != nil)
+
+	// the invalid sets are treated as empty
+	buf := NewBuffer()
+	buf.AddRunes([]rune("\u0628\u064E\u0651\u062A\u0650\u0652"), 0, -1)
+	buf.GuessSegmentProperties()
+	buf.Shape(NewFont(font.NewFace(ft)), nil)
+	tu.Assert(t, len(buf.Info) != 0)
+}
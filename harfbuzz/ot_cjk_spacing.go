@@ -0,0 +1,194 @@
+package harfbuzz
+
+import (
+	"fmt"
+
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+)
+
+// CJKSpacingClass classifies the CJK punctuation according to the side of
+// the (full width) glyph box occupied by its ink, following the character classes
+// of the "Requirements for Japanese Text Layout" (JLREQ).
+// It is used to synthesize the 'halt' and 'vhal' features (see [SyntheticCJKSpacing]).
+type CJKSpacingClass uint8
+
+const (
+	// CJKSpacingNone is used for the characters which are not compressed.
+	CJKSpacingNone CJKSpacingClass = iota
+	// CJKOpening is used for the opening brackets (JLREQ cl-01),
+	// whose ink is on the trailing half of the glyph box.
+	CJKOpening
+	// CJKClosing is used for the closing brackets (cl-02), the full stops (cl-06)
+	// and the commas (cl-07), whose ink is on the leading half of the glyph box.
+	CJKClosing
+	// CJKMiddle is used for the middle dots (cl-05), whose ink is centered.
+	CJKMiddle
+)
+
+func (cl CJKSpacingClass) String() string {
+	switch cl {
+	case CJKSpacingNone:
+		return "none"
+	case CJKOpening:
+		return "opening"
+	case CJKClosing:
+		return "closing"
+	case CJKMiddle:
+		return "middle"
+	default:
+		return fmt.Sprintf("<CJK spacing class %d>", uint8(cl))
+	}
+}
+
+// LookupCJKSpacingClass returns the JLREQ class of the full width punctuation [r],
+// or [CJKSpacingNone]. The vertical presentation forms (U+FE10 to U+FE19 and U+FE30 to U+FE4F)
+// are also supported.
+func LookupCJKSpacingClass(r rune) CJKSpacingClass {
+	switch r {
+	case 0x2018, 0x201C, 0x3008, 0x300A, 0x300C, 0x300E, 0x3010, 0x3014, 0x3016, 0x3018, 0x301A, 0x301D,
+		0xFF08, 0xFF3B, 0xFF5B, 0xFF5F,
+		0xFE17, 0xFE35, 0xFE37, 0xFE39, 0xFE3B, 0xFE3D, 0xFE3F, 0xFE41, 0xFE43, 0xFE47:
+		return CJKOpening
+	case 0x2019, 0x201D, 0x3009, 0x300B, 0x300D, 0x300F, 0x3011, 0x3015, 0x3017, 0x3019, 0x301B, 0x301E, 0x301F,
+		0xFF09, 0xFF3D, 0xFF5D, 0xFF60,
+		0xFE18, 0xFE36, 0xFE38, 0xFE3A, 0xFE3C, 0xFE3E, 0xFE40, 0xFE42, 0xFE44, 0xFE48,
+		0x3001, 0x3002, 0xFF0C, 0xFF0E, // commas and full stops
+		0xFE10, 0xFE11, 0xFE12:
+		return CJKClosing
+	case 0x30FB, 0xFF1A, 0xFF1B,
+		0xFE13, 0xFE14:
+		return CJKMiddle
+	default:
+		return CJKSpacingNone
+	}
+}
+
+// isCJKProportional returns true for the characters
+// whose width is adjusted by the synthetic 'palt' and 'vpal' features
+func isCJKProportional(r rune) bool {
+	return LookupCJKSpacingClass(r) != CJKSpacingNone ||
+		0x3041 <= r && r <= 0x30FF || // Hiragana and Katakana
+		0xFF01 <= r && r <= 0xFF5E // Fullwidth ASCII variants
+}
+
+func isCJKSpacingFeature(tag ot.Tag) bool {
+	switch tag {
+	case ot.NewTag('h', 'a', 'l', 't'), ot.NewTag('v', 'h', 'a', 'l'),
+		ot.NewTag('p', 'a', 'l', 't'), ot.NewTag('v', 'p', 'a', 'l'):
+		return true
+	}
+	return false
+}
+
+// cjkInkBounds returns the (full width) advance of [glyph] in the inline direction,
+// and the start and end of its ink, measured from the start of the glyph box.
+// It returns false for empty glyphs and glyphs which are not full width.
+func (f *Font) cjkInkBounds(glyph GID, vertical bool) (advance, start, end Position, ok bool) {
+	ext, ok := f.GlyphExtents(glyph)
+	if !ok || ext.Width == 0 || ext.Height == 0 {
+		return 0, 0, 0, false
+	}
+	var em Position
+	if vertical {
+		advance, em = -f.getGlyphVAdvance(glyph), f.YScale
+		_, top := f.getGlyphVOriginWithFallback(glyph)
+		start, end = top-ext.YBearing, top-(ext.YBearing+ext.Height)
+	} else {
+		advance, em = f.GlyphHAdvance(glyph), f.XScale
+		start, end = ext.XBearing, ext.XBearing+ext.Width
+	}
+	if em < 0 {
+		em = -em
+	}
+	if d := advance - em; d > em/16 || d < -em/16 {
+		return 0, 0, 0, false
+	}
+	return advance, start, end, true
+}
+
+// CJKHalfWidth returns the metrics of the half width alternate of the full width
+// punctuation [glyph], whose class is given by [LookupCJKSpacingClass].
+// This is what the 'halt' (or 'vhal' if [vertical] is true) feature usually provides.
+//
+// [advance] is the new advance in the inline direction, and [shift] is the offset to apply
+// to the glyph, in the inline direction (that is, negative values move the glyph to the left,
+// or to the top in vertical text). The opening brackets lose their leading half, the closing
+// brackets their trailing half, and the middle dots a quarter on each side.
+//
+// It returns false if [glyph] is not full width, or if its ink does not fit in the half width box.
+func (f *Font) CJKHalfWidth(glyph GID, class CJKSpacingClass, vertical bool) (advance, shift Position, ok bool) {
+	full, start, end, ok := f.cjkInkBounds(glyph, vertical)
+	if !ok {
+		return 0, 0, false
+	}
+	half, quarter, tolerance := full/2, full/4, full/16
+	switch class {
+	case CJKOpening: // keep the trailing half
+		ok, shift = start >= half-tolerance, -half
+	case CJKClosing: // keep the leading half
+		ok = end <= half+tolerance
+	case CJKMiddle: // keep the center
+		ok, shift = start >= quarter-tolerance && end <= full-quarter+tolerance, -quarter
+	default:
+		ok = false
+	}
+	if !ok {
+		return 0, 0, false
+	}
+	return full - half, shift, true
+}
+
+// CJKProportionalWidth returns the metrics of the proportional alternate of the
+// full width [glyph], fitting its ink with a margin of 1/20 em on each side.
+// This is what the 'palt' (or 'vpal' if [vertical] is true) feature usually provides.
+// See [Font.CJKHalfWidth] for the meaning of the returned values.
+//
+// It returns false if [glyph] is not full width, or if its ink is too wide to be narrowed.
+func (f *Font) CJKProportionalWidth(glyph GID, vertical bool) (advance, shift Position, ok bool) {
+	full, start, end, ok := f.cjkInkBounds(glyph, vertical)
+	if !ok {
+		return 0, 0, false
+	}
+	margin := full / 20
+	advance = end - start + 2*margin
+	if advance >= full {
+		return 0, 0, false
+	}
+	return advance, margin - start, true
+}
+
+// fallbackCJKSpacing synthesizes the 'halt' and 'palt' features (or 'vhal' and 'vpal'),
+// when they are requested but not provided by the font.
+func (sp *otShapePlan) fallbackCJKSpacing(font *Font, buffer *Buffer) {
+	if debugMode {
+		fmt.Println("POSITION - applying fallback CJK spacing")
+	}
+	vertical := buffer.Props.Direction.isVertical()
+	pos := buffer.Pos
+	for i, inf := range buffer.Info {
+		if inf.isMark() || inf.ligated() {
+			continue
+		}
+		var (
+			advance, shift Position
+			ok             bool
+		)
+		if sp.applyFallbackPalt && inf.Mask&sp.paltMask != 0 && isCJKProportional(inf.codepoint) {
+			advance, shift, ok = font.CJKProportionalWidth(inf.Glyph, vertical)
+		} else if sp.applyFallbackHalt && inf.Mask&sp.haltMask != 0 {
+			advance, shift, ok = font.CJKHalfWidth(inf.Glyph, LookupCJKSpacingClass(inf.codepoint), vertical)
+		}
+		if !ok {
+			continue
+		}
+
+		pos[i].Source = PositionFallback
+		if vertical {
+			pos[i].YAdvance -= advance + font.getGlyphVAdvance(inf.Glyph)
+			pos[i].YOffset -= shift
+		} else {
+			pos[i].XAdvance += advance - font.GlyphHAdvance(inf.Glyph)
+			pos[i].XOffset += shift
+		}
+	}
+}
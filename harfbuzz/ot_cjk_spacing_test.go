@@ -0,0 +1,200 @@
+package harfbuzz
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
+	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
+)
+
+func TestLookupCJKSpacingClass(t *testing.T) {
+	for _, test := range []struct {
+		r        rune
+		expected CJKSpacingClass
+	}{
+		{'「', CJKOpening},
+		{'（', CJKOpening},
+		{'︵', CJKOpening}, // vertical left parenthesis
+		{'」', CJKClosing},
+		{'、', CJKClosing},
+		{'。', CJKClosing},
+		{'︒', CJKClosing}, // vertical ideographic full stop
+		{'・', CJKMiddle},
+		{'：', CJKMiddle},
+		{'あ', CJKSpacingNone},
+		{'a', CJKSpacingNone},
+	} {
+		tu.Assert(t, LookupCJKSpacingClass(test.r) == test.expected)
+	}
+}
+
+func shapeCJK(ft *font.Font, text string, dir Direction, feature string, flags ShappingOptions) []GlyphPosition {
+	buf := NewBuffer()
+	buf.AddRunes([]rune(text), 0, -1)
+	buf.Props = SegmentProperties{Direction: dir, Script: language.Han, Language: "ja"}
+	buf.Flags = flags
+	buf.Shape(NewFont(&font.Face{Font: ft}), []Feature{{Tag: ot.MustNewTag(feature), Value: 1, Start: FeatureGlobalStart, End: FeatureGlobalEnd}})
+	return buf.Pos
+}
+
+func TestSyntheticCJKSpacing(t *testing.T) {
+	// this font has no 'halt' nor 'palt' features
+	ft := openFontFileTT(t, "common/mplus-1p-regular.ttf")
+	const text = "「あ」、・"
+
+	for _, test := range []struct {
+		dir      Direction
+		feature  string
+		expected []GlyphPosition // with SyntheticCJKSpacing
+	}{
+		{LeftToRight, "halt", []GlyphPosition{
+			{XAdvance: 500, XOffset: -500}, {XAdvance: 1000}, {XAdvance: 500}, {XAdvance: 500}, {XAdvance: 500, XOffset: -250},
+		}},
+		{LeftToRight, "palt", []GlyphPosition{
+			{XAdvance: 483, XOffset: -500}, {XAdvance: 906, XOffset: -47}, {XAdvance: 483, XOffset: -17}, {XAdvance: 361, XOffset: -8}, {XAdvance: 206, XOffset: -397},
+		}},
+		{TopToBottom, "vhal", []GlyphPosition{
+			{YAdvance: -500, XOffset: -500, YOffset: -360}, {YAdvance: -1000, XOffset: -500, YOffset: -860},
+			{YAdvance: -500, XOffset: -500, YOffset: -860}, {YAdvance: -500, XOffset: -500, YOffset: -860}, {YAdvance: -500, XOffset: -500, YOffset: -610},
+		}},
+	} {
+		// without the flag, the feature is ignored
+		for _, pos := range shapeCJK(ft, text, test.dir, test.feature, 0) {
+			tu.Assert(t, pos.XAdvance == 1000 || pos.YAdvance == -1000)
+		}
+
+		got := shapeCJK(ft, text, test.dir, test.feature, SyntheticCJKSpacing)
+		tu.Assert(t, len(got) == len(test.expected))
+		for i, pos := range got {
+			exp := test.expected[i]
+			tu.AssertC(t, pos.XAdvance == exp.XAdvance && pos.YAdvance == exp.YAdvance &&
+				pos.XOffset == exp.XOffset && pos.YOffset == exp.YOffset, fmt.Sprint(test.feature, i, pos))
+		}
+
+		// the horizontal features are not applied in vertical text
+		if test.dir == LeftToRight {
+			for _, pos := range shapeCJK(ft, text, TopToBottom, test.feature, SyntheticCJKSpacing) {
+				tu.Assert(t, pos.YAdvance == -1000)
+			}
+		}
+	}
+
+	// fonts supporting the features are not modified
+	ft = openFontFileTT(t, "common/NotoSansCJKjp-VF.otf")
+	for _, feature := range []string{"halt", "palt"} {
+		exp := shapeCJK(ft, text, LeftToRight, feature, 0)
+		got := shapeCJK(ft, text, LeftToRight, feature, SyntheticCJKSpacing)
+		tu.Assert(t, reflect.DeepEqual(exp, got))
+		tu.Assert(t, exp[0].XAdvance == 500 && exp[0].XOffset != 0)
+	}
+}
+
+func TestVerticalAlternates(t *testing.T) {
+	ft := openFontFileTT(t, "common/NotoSansCJKjp-VF.otf") // with both 'vert' and 'vrt2'
+	font := NewFont(font.NewFace(ft))
+	text := []rune("\u6F22\uFF1A\u300C\u304B\u300D\u3002") // 漢：「か」。
+
+	shape := func(dir Direction, flags ShappingOptions, features ...Feature) ([]GID, []GlyphPosition) {
+		buffer := NewBuffer()
+		buffer.Flags = flags
+		buffer.AddRunes(text, 0, -1)
+		buffer.Props = SegmentProperties{Direction: dir, Script: language.Han, Language: "ja"}
+		buffer.Shape(font, features)
+		var glyphs []GID
+		for _, info := range buffer.Info {
+			glyphs = append(glyphs, info.Glyph)
+		}
+		return glyphs, buffer.Pos
+	}
+
+	horizontal, _ := shape(LeftToRight, 0)
+	vrt2, _ := shape(TopToBottom, 0)
+	vert, _ := shape(TopToBottom, VerticalAlternatesVertOnly)
+	tu.Assert(t, len(vrt2) == len(text) && len(vert) == len(text))
+	for i := range text {
+		switch i {
+		case 0: // no vertical form
+			tu.Assert(t, vrt2[i] == horizontal[i] && vert[i] == horizontal[i])
+		case 1: // the colon is only replaced by 'vert'
+			tu.Assert(t, vrt2[i] == horizontal[i] && vert[i] != horizontal[i])
+		default: // kana, brackets and full stop
+			tu.Assert(t, vrt2[i] != horizontal[i] && vrt2[i] == vert[i])
+		}
+	}
+
+	// only one of the features is applied : the glyphs are the ones given by
+	// the single substitutions of the font, with no substitution chained,
+	// as in HarfBuzz, which applies 'vert' only
+	for i, g := range horizontal {
+		expected, ok := singleSubstitution(ft, tagVrt2, g)
+		if !ok {
+			expected = g
+		}
+		tu.Assert(t, vrt2[i] == expected)
+		expected, ok = singleSubstitution(ft, tagVert, g)
+		if !ok {
+			expected = g
+		}
+		tu.Assert(t, vert[i] == expected)
+	}
+
+	// disabling 'vert' also disables 'vrt2', so that the kana is not replaced
+	// (the punctuation is still replaced by the Unicode vertical forms)
+	noVert, _ := shape(TopToBottom, 0, Feature{Tag: ot.MustNewTag("vert"), Value: 0, Start: FeatureGlobalStart, End: FeatureGlobalEnd})
+	tu.Assert(t, noVert[3] == horizontal[3])
+	// which may also be disabled alone
+	noVrt2, _ := shape(TopToBottom, 0, Feature{Tag: ot.MustNewTag("vrt2"), Value: 0, Start: FeatureGlobalStart, End: FeatureGlobalEnd})
+	tu.Assert(t, reflect.DeepEqual(noVrt2, noVert))
+
+	// 'vhal' applies to the vertical alternates
+	for _, flags := range []ShappingOptions{0, VerticalAlternatesVertOnly} {
+		_, positions := shape(TopToBottom, flags, Feature{Tag: ot.MustNewTag("vhal"), Value: 1, Start: FeatureGlobalStart, End: FeatureGlobalEnd})
+		tu.Assert(t, positions[0].YAdvance == -1000 && positions[3].YAdvance == -1000)
+		tu.Assert(t, positions[2].YAdvance == -500 && positions[4].YAdvance == -500 && positions[5].YAdvance == -500)
+	}
+
+	// 'vpal' has priority over 'vhal', which has priority over 'vchw'
+	global := func(tag string) Feature {
+		return Feature{Tag: ot.MustNewTag(tag), Value: 1, Start: FeatureGlobalStart, End: FeatureGlobalEnd}
+	}
+	_, vpal := shape(TopToBottom, 0, global("vpal"))
+	_, vhal := shape(TopToBottom, 0, global("vhal"))
+	_, both := shape(TopToBottom, 0, global("vhal"), global("vpal"))
+	tu.Assert(t, !reflect.DeepEqual(vpal, vhal) && reflect.DeepEqual(both, vpal))
+	_, both = shape(TopToBottom, 0, global("vchw"), global("vhal"))
+	tu.Assert(t, reflect.DeepEqual(both, vhal))
+}
+
+// singleSubstitution returns the substitute of [glyph] given by
+// the single substitution lookups of the GSUB [feature] of [ft].
+func singleSubstitution(ft *font.Font, feature ot.Tag, glyph GID) (GID, bool) {
+	for _, f := range ft.GSUB.Features {
+		if f.Tag != feature {
+			continue
+		}
+		for _, index := range f.LookupListIndices {
+			for _, subtable := range ft.GSUB.Lookups[index].Subtables {
+				single, ok := subtable.(tables.SingleSubs)
+				if !ok {
+					continue
+				}
+				coverageIndex, ok := single.Data.Cov().Index(gID(glyph))
+				if !ok {
+					continue
+				}
+				switch data := single.Data.(type) {
+				case tables.SingleSubstData1:
+					return GID(int(glyph) + int(data.DeltaGlyphID)), true
+				case tables.SingleSubstData2:
+					return GID(data.SubstituteGlyphIDs[coverageIndex]), true
+				}
+			}
+		}
+	}
+	return 0, false
+}
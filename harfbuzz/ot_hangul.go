@@ -53,6 +53,9 @@ func (complexShaperHangul) overrideFeatures(plan *otShapePlanner) {
 
 type hangulShapePlan struct {
 	maskArray [hangulFeatureCount]GlyphMask
+	// true if the font has at least one of the jamo features :
+	// if not, decomposing syllables into conjoining jamos is pointless
+	hasJamoFeatures bool
 }
 
 func (cs *complexShaperHangul) dataCreate(plan *otShapePlan) {
@@ -60,6 +63,7 @@ func (cs *complexShaperHangul) dataCreate(plan *otShapePlan) {
 
 	for i := range hangulPlan.maskArray {
 		hangulPlan.maskArray[i] = plan.map_.getMask1(hangulFeatures[i])
+		hangulPlan.hasJamoFeatures = hangulPlan.hasJamoFeatures || hangulPlan.maskArray[i] != 0
 	}
 
 	cs.plan = hangulPlan
@@ -111,6 +115,9 @@ func (cs *complexShaperHangul) preprocessText(_ *otShapePlan, buffer *Buffer, fo
 	*
 	*   - If the whole syllable can be precomposed, do that,
 	*   - Otherwise, fully decompose and apply ljmo/vjmo/tjmo features.
+	*   - However, if the font has no jamo features, conjoining jamos can't be
+	*     rendered properly : in this case, use the precomposed <LV> of a
+	*     <L,V,T> or <LV,T> syllable with a non-combining T, and leave the T alone.
 	*   - If a valid syllable is followed by a Hangul tone mark, reorder the tone
 	*     mark to precede the whole syllable - unless it is a zero-width glyph, in
 	*     which case we leave it untouched, assuming it's designed to overstrike.
@@ -216,6 +223,20 @@ func (cs *complexShaperHangul) preprocessText(_ *otShapePlan, buffer *Buffer, fo
 						continue
 					}
 				}
+				if !cs.plan.hasJamoFeatures && t != 0 &&
+					(ucd.HangulLBase <= l && l <= ucd.HangulLBase+ucd.HangulLCount-1) && (ucd.HangulVBase <= v && v <= ucd.HangulVBase+ucd.HangulVCount-1) {
+					/* Old Hangul <L,V,T> : fallback to <LV,T> */
+					s := ucd.HangulSBase + (l-ucd.HangulLBase)*ucd.HangulNCount + (v-ucd.HangulVBase)*ucd.HangulTCount
+					if font.hasGlyph(s) {
+						buffer.replaceGlyphs(2, []rune{s}, nil)
+						buffer.nextGlyph() // the trailing jamo
+						if buffer.ClusterLevel == MonotoneGraphemes {
+							buffer.mergeOutClusters(start, start+2)
+						}
+						end = start + 2
+						continue
+					}
+				}
 
 				/* We didn't compose, either because it's an Old Hangul syllable without a
 				 * precomposed character in Unicode, or because the font didn't support the
@@ -263,7 +284,19 @@ func (cs *complexShaperHangul) preprocessText(_ *otShapePlan, buffer *Buffer, fo
 			/* Otherwise, decompose if font doesn't support <LV> or <LVT>,
 			* or if having non-combining <LV,T>.  Note that we already handled
 			* combining <LV,T> above. */
-			if !HasGlyph || (tindex == 0 && buffer.idx+1 < count && isT(buffer.cur(+1).codepoint)) {
+			hasNonCombiningT := tindex == 0 && buffer.idx+1 < count && isT(buffer.cur(+1).codepoint)
+			if hasNonCombiningT && HasGlyph && !cs.plan.hasJamoFeatures {
+				/* Keep <LV> and the T as they are. */
+				buffer.unsafeToBreak(buffer.idx, buffer.idx+2)
+				buffer.nextGlyph()
+				buffer.nextGlyph()
+				if buffer.ClusterLevel == MonotoneGraphemes {
+					buffer.mergeOutClusters(start, start+2)
+				}
+				end = start + 2
+				continue
+			}
+			if !HasGlyph || hasNonCombiningT {
 				decomposed := [3]rune{
 					ucd.HangulLBase + lindex,
 					ucd.HangulVBase + vindex,
@@ -305,7 +338,7 @@ func (cs *complexShaperHangul) preprocessText(_ *otShapePlan, buffer *Buffer, fo
 						buffer.mergeOutClusters(start, end)
 					}
 					continue
-				} else if tindex == 0 && buffer.idx+1 < count && isT(buffer.cur(+1).codepoint) {
+				} else if hasNonCombiningT {
 					buffer.unsafeToBreak(buffer.idx, buffer.idx+2) /* Mark unsafe between LV and T. */
 				}
 			}
@@ -0,0 +1,60 @@
+package harfbuzz
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	tu "github.com/boxesandglue/typesetting/testutils"
+)
+
+func TestHangulJamos(t *testing.T) {
+	ft := openFontFileTT(t, "common/NotoSansCJKjp-VF.otf")
+	hbFont := NewFont(font.NewFace(ft))
+
+	noJamoFeatures := []Feature{
+		{Tag: ot.NewTag('l', 'j', 'm', 'o'), Value: 0, Start: FeatureGlobalStart, End: FeatureGlobalEnd},
+		{Tag: ot.NewTag('v', 'j', 'm', 'o'), Value: 0, Start: FeatureGlobalStart, End: FeatureGlobalEnd},
+		{Tag: ot.NewTag('t', 'j', 'm', 'o'), Value: 0, Start: FeatureGlobalStart, End: FeatureGlobalEnd},
+	}
+
+	// shape returns the characters of the glyphs, and the clusters
+	shape := func(text []rune, features []Feature) (runes []rune, clusters []int) {
+		buf := NewBuffer()
+		buf.AddRunes(text, 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(hbFont, features)
+		for _, info := range buf.Info {
+			runes = append(runes, info.codepoint)
+			clusters = append(clusters, info.Cluster)
+		}
+		return runes, clusters
+	}
+
+	for _, test := range []struct {
+		text     []rune
+		features []Feature
+		expected []rune
+		clusters []int
+	}{
+		// composable syllables
+		{[]rune{0x1100, 0x1161, 0x11A8}, nil, []rune{0xAC01}, []int{0}},
+		{[]rune{0xAC00, 0x11A8}, nil, []rune{0xAC01}, []int{0}},
+		{[]rune{0x1100, 0x1161, 0x11A8}, noJamoFeatures, []rune{0xAC01}, []int{0}},
+		// Old Hangul, with a non-combining trailing jamo :
+		// decomposed if the font has jamo features,
+		{[]rune{0x1100, 0x1161, 0x11C3}, nil, []rune{0x1100, 0x1161, 0x11C3}, []int{0, 0, 0}},
+		{[]rune{0xAC00, 0x11C3}, nil, []rune{0x1100, 0x1161, 0x11C3}, []int{0, 0, 0}},
+		// and using the precomposed <LV> otherwise
+		{[]rune{0x1100, 0x1161, 0x11C3}, noJamoFeatures, []rune{0xAC00, 0x11C3}, []int{0, 0}},
+		{[]rune{0xAC00, 0x11C3}, noJamoFeatures, []rune{0xAC00, 0x11C3}, []int{0, 0}},
+		// tone marks are moved before the syllable
+		{[]rune{0xAC00, 0x11C3, 0x302E}, noJamoFeatures, []rune{0x302E, 0xAC00, 0x11C3}, []int{0, 0, 0}},
+	} {
+		runes, clusters := shape(test.text, test.features)
+		tu.AssertC(t, reflect.DeepEqual(runes, test.expected), fmt.Sprintf("%U: %U", test.text, runes))
+		tu.AssertC(t, reflect.DeepEqual(clusters, test.clusters), fmt.Sprintf("%U: %v", test.text, clusters))
+	}
+}
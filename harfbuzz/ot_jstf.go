@@ -0,0 +1,156 @@
+package harfbuzz
+
+import (
+	"sort"
+
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
+)
+
+// tagJSTF is used as feature tag for the lookups
+// enabled by the 'JSTF' table
+var tagJSTF = ot.NewTag('J', 'S', 'T', 'F')
+
+// jstfLookups stores the GSUB and GPOS lookups enabled and
+// disabled by the 'JSTF' table, for a given justification level.
+type jstfLookups struct {
+	enabled, disabled [2][]uint16 // GSUB/GPOS
+}
+
+// justificationLookups resolves the 'JSTF' suggestions for the script and language of the plan,
+// applying the modifications of the first |level| priorities, in order :
+// extension suggestions for positive levels, shrinkage suggestions for negative ones.
+//
+// The JstfMax lookups, which are defined in the 'JSTF' table itself, are not supported.
+func (mb *otMapBuilder) justificationLookups(level int) (out jstfLookups) {
+	if level == 0 {
+		return out
+	}
+	scriptTags, languageTags := newOTTagsFromScriptAndLanguage(mb.props.Script, mb.props.Language)
+	var priorities []tables.JstfPriority
+	jstf := mb.tables.JSTF()
+	for _, script := range scriptTags {
+		if index := jstf.FindScript(script); index != -1 {
+			// use the first language found, or the default one
+			sc := &jstf.Scripts[index]
+			langIndex := -1
+			for _, language := range languageTags {
+				if langIndex = sc.FindLanguage(language); langIndex != -1 {
+					break
+				}
+			}
+			priorities = sc.GetLangSys(langIndex).Priorities
+			break
+		}
+	}
+
+	shrink := level < 0
+	if shrink {
+		level = -level
+	}
+	if level > len(priorities) {
+		level = len(priorities)
+	}
+	for _, priority := range priorities[:level] {
+		mods := [2][2]tables.JstfModList{
+			{priority.GSUBExtensionEnable, priority.GSUBExtensionDisable},
+			{priority.GPOSExtensionEnable, priority.GPOSExtensionDisable},
+		}
+		if shrink {
+			mods = [2][2]tables.JstfModList{
+				{priority.GSUBShrinkageEnable, priority.GSUBShrinkageDisable},
+				{priority.GPOSShrinkageEnable, priority.GPOSShrinkageDisable},
+			}
+		}
+		for tableIndex, mod := range mods {
+			// a lower priority may enable a lookup disabled by a higher one, and conversely
+			for _, index := range mod[0].LookupIndices {
+				out.disabled[tableIndex] = removeLookupIndex(out.disabled[tableIndex], index)
+				out.enabled[tableIndex] = append(removeLookupIndex(out.enabled[tableIndex], index), index)
+			}
+			for _, index := range mod[1].LookupIndices {
+				out.enabled[tableIndex] = removeLookupIndex(out.enabled[tableIndex], index)
+				out.disabled[tableIndex] = append(removeLookupIndex(out.disabled[tableIndex], index), index)
+			}
+		}
+	}
+	return out
+}
+
+func removeLookupIndex(indices []uint16, index uint16) []uint16 {
+	for i, v := range indices {
+		if v == index {
+			return append(indices[:i], indices[i+1:]...)
+		}
+	}
+	return indices
+}
+
+// applyJustification removes the disabled lookups from the map, and adds
+// the enabled ones (for all the glyphs), in the last stage if they are not already present.
+// As in [otMapBuilder.compile], the lookups of the last stage are kept sorted by index.
+// [lookupCounts] is the number of lookups of the GSUB and GPOS tables.
+func (m *otMap) applyJustification(jstf jstfLookups, lookupCounts [2]int, globalMask GlyphMask) {
+	for tableIndex := range m.lookups {
+		lookups, stages := m.lookups[tableIndex], m.stages[tableIndex]
+		disabled := jstf.disabled[tableIndex]
+
+		// remove the disabled lookups, updating the stage boundaries
+		j, stage := 0, 0
+		for i, lookup := range lookups {
+			for ; stage < len(stages) && stages[stage].lastLookup <= i; stage++ {
+				stages[stage].lastLookup = j
+			}
+			if isLookupIn(disabled, lookup.index) {
+				continue
+			}
+			lookups[j] = lookup
+			j++
+		}
+		for ; stage < len(stages); stage++ {
+			stages[stage].lastLookup = j
+		}
+		lookups = lookups[:j]
+
+		added := false
+	enabled:
+		for _, index := range jstf.enabled[tableIndex] {
+			if int(index) >= lookupCounts[tableIndex] {
+				continue
+			}
+			for k := range lookups {
+				if lookups[k].index == index {
+					lookups[k].mask |= globalMask
+					continue enabled
+				}
+			}
+			lookups = append(lookups, lookupMap{
+				index:      index,
+				mask:       globalMask,
+				autoZWNJ:   true,
+				autoZWJ:    true,
+				featureTag: tagJSTF,
+			})
+			added = true
+		}
+		if added && len(stages) != 0 {
+			start := 0
+			if len(stages) >= 2 {
+				start = stages[len(stages)-2].lastLookup
+			}
+			view := lookups[start:]
+			sort.Slice(view, func(i, j int) bool { return view[i].index < view[j].index })
+			stages[len(stages)-1].lastLookup = len(lookups)
+		}
+		m.lookups[tableIndex] = lookups
+	}
+}
+
+func isLookupIn(indices []uint16, index uint16) bool {
+	for _, v := range indices {
+		if v == index {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package harfbuzz
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
+	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
+)
+
+func TestJustification(t *testing.T) {
+	ft := openFontFileTT(t, "common/Roboto-BoldItalic.ttf")
+	// in this font, the 'liga' lookups for 'latn' are 16 and 17, and the 'smcp' one is 1
+	modList := func(indices ...uint16) tables.JstfModList { return tables.JstfModList{LookupIndices: indices} }
+	*ft.JSTF() = tables.JSTF{
+		ScriptRecords: []tables.TagOffsetRecord{{Tag: ot.MustNewTag("latn")}},
+		Scripts: []tables.JstfScript{{
+			ExtenderGlyph: tables.ExtenderGlyph{Glyphs: []tables.GlyphID{5}},
+			DefaultLangSys: tables.JstfLangSys{Priorities: []tables.JstfPriority{
+				{GSUBExtensionDisable: modList(16, 17), GSUBShrinkageEnable: modList(1)},
+				{GSUBExtensionEnable: modList(1, 1000)}, // invalid indices are ignored
+			}},
+		}},
+	}
+	tu.Assert(t, reflect.DeepEqual(ft.ExtenderGlyphs(ot.MustNewTag("latn")), []GID{5}))
+	tu.Assert(t, len(ft.JustificationPriorities(ot.MustNewTag("latn"), ot.MustNewTag("FRA "))) == 2)
+	tu.Assert(t, len(ft.JustificationPriorities(ot.MustNewTag("arab"), 0)) == 0)
+
+	hbFont := NewFont(font.NewFace(ft))
+	shape := func(level int) []GID {
+		buf := NewBuffer()
+		buf.AddRunes([]rune("fia"), 0, -1)
+		buf.Props.Language = language.NewLanguage("fr")
+		buf.GuessSegmentProperties()
+		buf.Justification = level
+		buf.Shape(hbFont, nil)
+		out := make([]GID, len(buf.Info))
+		for i, info := range buf.Info {
+			out[i] = info.Glyph
+		}
+		return out
+	}
+
+	regular := shape(0)
+	tu.Assert(t, len(regular) == 2) // 'fi' ligature
+
+	noLiga := shape(1)
+	tu.Assert(t, len(noLiga) == 3 && noLiga[2] == regular[1])
+
+	// the modifications are cumulative
+	smcp := shape(2)
+	tu.Assert(t, len(smcp) == 3 && smcp[0] != noLiga[0] && smcp[2] != noLiga[2])
+	// levels higher than the number of priorities are clamped
+	tu.Assert(t, len(shape(10)) == 3)
+
+	// the 'smcp' lookup is applied in lookup order, before the 'liga' ones
+	shrunk := shape(-1)
+	tu.Assert(t, reflect.DeepEqual(shrunk, smcp))
+
+	// the plans are cached per level
+	tu.Assert(t, len(shape(0)) == 2)
+
+	// the enabled lookups are merged in the last stage, in sorted order
+	ft.JSTF().Scripts[0].DefaultLangSys.Priorities = []tables.JstfPriority{
+		{GSUBExtensionEnable: modList(20, 3, 1)},
+	}
+	props := SegmentProperties{Direction: LeftToRight, Script: language.Latin, Language: language.NewLanguage("fr")}
+	m := newShapePlan(hbFont, props, nil, nil, planOptions{jstfLevel: 1}).shaper.plan.map_
+	stages, lookups := m.stages[0], m.lookups[0]
+	tu.Assert(t, len(stages) >= 2)
+	last := lookups[stages[len(stages)-2].lastLookup:stages[len(stages)-1].lastLookup]
+	var found []uint16
+	for i, lookup := range last {
+		tu.Assert(t, i == 0 || last[i-1].index < lookup.index)
+		if lookup.featureTag == tagJSTF {
+			found = append(found, lookup.index)
+		}
+	}
+	tu.Assert(t, reflect.DeepEqual(found, []uint16{1, 20})) // 3 is already used by 'ccmp'
+}
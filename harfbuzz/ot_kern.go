@@ -11,7 +11,7 @@ func simpleKern(kernTable fontP.Kernx) fontP.SimpleKerns {
 	return nil
 }
 
-func kern(driver fontP.SimpleKerns, crossStream bool, font *Font, buffer *Buffer, kernMask GlyphMask, scale bool) {
+func kern(driver fontP.SimpleKerns, crossStream bool, font *Font, buffer *Buffer, kernMask GlyphMask, scale bool, source PositionSource) {
 	buffer.unsafeToConcat(0, maxInt)
 
 	var c otApplyContext
@@ -75,6 +75,7 @@ func kern(driver fontP.SimpleKerns, crossStream bool, font *Font, buffer *Buffer
 			}
 		}
 
+		pos[i].Source, pos[j].Source = source, source
 		buffer.unsafeToBreak(i, j+1)
 
 	skip:
@@ -90,7 +91,7 @@ func (sp *otShapePlan) otApplyFallbackKern(font *Font, buffer *Buffer) {
 	}
 
 	if driver := simpleKern(font.face.Kern); driver != nil {
-		kern(driver, false, font, buffer, sp.kernMask, false)
+		kern(driver, false, font, buffer, sp.kernMask, false, PositionFallback)
 	}
 
 	if reverse {
@@ -0,0 +1,260 @@
+package harfbuzz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	tu "github.com/boxesandglue/typesetting/testutils"
+	otTD "github.com/go-text/typesetting-utils/opentype"
+)
+
+// noKern disables the horizontal and vertical kerning
+var noKern = []Feature{
+	{Tag: ot.NewTag('k', 'e', 'r', 'n'), Value: 0, Start: FeatureGlobalStart, End: FeatureGlobalEnd},
+	{Tag: ot.NewTag('v', 'k', 'r', 'n'), Value: 0, Start: FeatureGlobalStart, End: FeatureGlobalEnd},
+}
+
+func shapeKern(hbFont *Font, text []rune, dir Direction, features []Feature) (*Buffer, Position) {
+	buf := NewBuffer()
+	buf.AddRunes(text, 0, -1)
+	buf.Props.Direction = dir
+	buf.GuessSegmentProperties()
+	buf.Shape(hbFont, features)
+	var advance Position
+	for _, pos := range buf.Pos {
+		advance += pos.XAdvance + pos.YAdvance
+	}
+	return buf, advance
+}
+
+// these fonts only have a 'kern' table (no GPOS),
+// with format 0 and 2 subtables
+func TestKernTable(t *testing.T) {
+	for _, test := range []struct {
+		ft       *font.Font
+		text     string
+		expected []Position // advance deltas for [text]
+	}{
+		{openFontFileTT(t, "toys/Kern2.ttf"), "TAVAT", []Position{-50, -90, -80, -90, -50}},
+		{openFontFile(t, "harfbuzz_reference/in-house/fonts/e39391c77a6321c2ac7a2d644de0396470cd4bfe.ttf"), "AVAT", []Position{-40, -80, -90, -50}},
+		{openFontFile(t, "harfbuzz_reference/text-rendering-tests/fonts/TestKERNOne.otf"), "Tuıı", []Position{-100, -100, 250, 250}},
+	} {
+		ft := test.ft
+		tu.Assert(t, len(ft.Kern) != 0)
+		hbFont := NewFont(font.NewFace(ft))
+		tu.Assert(t, hbFont.capabilities.Has(CapKern) && !hbFont.capabilities.Has(CapGPOS))
+
+		kerned, _ := shapeKern(hbFont, []rune(test.text), LeftToRight, nil)
+		unkerned, _ := shapeKern(hbFont, []rune(test.text), LeftToRight, noKern)
+		var deltas []Position
+		for i, pos := range kerned.Pos {
+			deltas = append(deltas, pos.XAdvance-unkerned.Pos[i].XAdvance)
+		}
+		tu.AssertC(t, reflect.DeepEqual(deltas, test.expected), test.text)
+
+		// the glyphs mapped by the cmap, sorted to get a deterministic subset of pairs
+		type glyph struct {
+			g GID
+			r rune
+		}
+		var glyphs []glyph
+		iter := ft.Cmap.Iter()
+		for iter.Next() {
+			r, g := iter.Char()
+			glyphs = append(glyphs, glyph{g, r})
+		}
+		sort.Slice(glyphs, func(i, j int) bool { return glyphs[i].r < glyphs[j].r })
+
+		for _, st := range ft.Kern {
+			simple, ok := st.Data.(font.SimpleKerns)
+			tu.Assert(t, ok && st.IsHorizontal() && !st.IsCrossStream())
+			tested := 0
+			for _, g1 := range glyphs {
+				for _, g2 := range glyphs {
+					if tested == 50 || simple.KernPair(g1.g, g2.g) == 0 {
+						continue
+					}
+					tested++
+
+					// all the subtables are applied
+					var expected Position
+					for _, st := range ft.Kern {
+						expected += Position(st.Data.(font.SimpleKerns).KernPair(g1.g, g2.g))
+					}
+
+					text := []rune{g1.r, g2.r}
+					context := fmt.Sprintf("%U", text)
+					buf, kerned := shapeKern(hbFont, text, LeftToRight, nil)
+					_, unkerned := shapeKern(hbFont, text, LeftToRight, noKern)
+					tu.AssertC(t, kerned-unkerned == expected, context)
+					if expected != 0 {
+						tu.AssertC(t, buf.Pos[0].Source == PositionKern, context)
+					}
+
+					// horizontal subtables are not applied to vertical text
+					_, vKerned := shapeKern(hbFont, text, TopToBottom, nil)
+					_, vUnkerned := shapeKern(hbFont, text, TopToBottom, noKern)
+					tu.AssertC(t, vKerned == vUnkerned, context)
+				}
+			}
+			tu.Assert(t, tested != 0)
+		}
+	}
+}
+
+// withKernCoverage returns a copy of the AAT 'kern' table [kern],
+// with the coverage of all its subtables set to [coverage]
+func withKernCoverage(kern []byte, coverage byte) []byte {
+	out := append([]byte(nil), kern...)
+	offset := 8
+	for i := uint32(0); i < binary.BigEndian.Uint32(out[4:]); i++ {
+		out[offset+4] = coverage
+		offset += int(binary.BigEndian.Uint32(out[offset:]))
+	}
+	return out
+}
+
+// kern1Table builds an AAT 'kern' table with one format 1 subtable,
+// which kerns [right] by [rightValue] and [left] by [leftValue]
+// when [left] is followed by [right] (with left < right).
+// The value table is stored after 0x2000 bytes, so that its offset
+// includes the bit used as reset flag in 'kerx' tables.
+func kern1Table(left, right GID, leftValue, rightValue int16) []byte {
+	const (
+		nClasses       = 6 // the 4 predefined classes, [left] and [right]
+		classTable     = 10
+		stateStart     = 0
+		stateAfterLeft = 2
+		valueTable     = 0x2000
+	)
+	nGlyphs := int(right-left) + 1
+	stateArray := classTable + 4 + nGlyphs + nGlyphs%2
+	entryTable := stateArray + 3*nClasses
+	stateOffset := func(state int) uint16 { return uint16(stateArray + state*nClasses) }
+
+	var st []byte
+	st = binary.BigEndian.AppendUint16(st, nClasses)
+	st = binary.BigEndian.AppendUint16(st, classTable)
+	st = binary.BigEndian.AppendUint16(st, uint16(stateArray))
+	st = binary.BigEndian.AppendUint16(st, uint16(entryTable))
+	st = binary.BigEndian.AppendUint16(st, valueTable)
+
+	st = binary.BigEndian.AppendUint16(st, uint16(left))
+	st = binary.BigEndian.AppendUint16(st, uint16(nGlyphs))
+	classes := bytes.Repeat([]byte{1}, nGlyphs+nGlyphs%2)
+	classes[0], classes[nGlyphs-1] = 4, 5
+	st = append(st, classes...)
+
+	// entries : 0 go to the start, 1 push, 2 push and kern
+	st = append(st,
+		0, 0, 0, 0, 1, 0, // start of text
+		0, 0, 0, 0, 1, 0, // start of line
+		0, 0, 0, 0, 1, 2, // after [left]
+	)
+	for _, entry := range [3][2]uint16{
+		{stateOffset(stateStart), 0},
+		{stateOffset(stateAfterLeft), 0x8000},
+		{stateOffset(stateStart), 0x8000 | valueTable},
+	} {
+		st = binary.BigEndian.AppendUint16(st, entry[0])
+		st = binary.BigEndian.AppendUint16(st, entry[1])
+	}
+	st = append(st, make([]byte, valueTable-len(st))...)
+	// the right glyph is on top of the stack; an odd value ends the list
+	st = binary.BigEndian.AppendUint16(st, uint16(rightValue&^1))
+	st = binary.BigEndian.AppendUint16(st, uint16(leftValue|1))
+
+	var out []byte
+	out = binary.BigEndian.AppendUint32(out, 0x00010000)
+	out = binary.BigEndian.AppendUint32(out, 1)
+	out = binary.BigEndian.AppendUint32(out, uint32(8+len(st)))
+	out = append(out, 0, 1) // coverage, format
+	out = binary.BigEndian.AppendUint16(out, 0)
+	return append(out, st...)
+}
+
+// the formats and coverages missing from the test fonts
+// are checked by replacing the 'kern' table of Kern2.ttf
+func TestKernTableFormats(t *testing.T) {
+	src, err := otTD.Files.ReadFile("toys/Kern2.ttf")
+	tu.AssertNoErr(t, err)
+	ld, err := ot.NewLoader(bytes.NewReader(src))
+	tu.AssertNoErr(t, err)
+	kern, err := ld.RawTable(ot.MustNewTag("kern"))
+	tu.AssertNoErr(t, err)
+	kern3, err := otTD.Files.ReadFile("toys/tables/kern3.bin")
+	tu.AssertNoErr(t, err)
+
+	base, err := font.ParseTTF(bytes.NewReader(src))
+	tu.AssertNoErr(t, err)
+	gA, _ := base.NominalGlyph('A')
+	gV, _ := base.NominalGlyph('V')
+
+	shape := func(kern []byte, dir Direction, text string) *Buffer {
+		face, err := font.ParseTTF(bytes.NewReader(withTable(t, src, ot.MustNewTag("kern"), kern)))
+		tu.AssertNoErr(t, err)
+		buf, _ := shapeKern(NewFont(face), []rune(text), dir, nil)
+		return buf
+	}
+	noSubtables := []byte{0, 1, 0, 0, 0, 0, 0, 0}
+
+	for _, test := range []struct {
+		kern     []byte
+		dir      Direction
+		text     string
+		expected []Position // {advance, offset, cross-stream offset} deltas for each glyph
+	}{
+		{kern1Table(gA, gV, -42, -100), LeftToRight, "AVo", []Position{-42, -42, 0, -100, -100, 0, 0, 0, 0}},
+		{kern3, LeftToRight, "IJN", []Position{-60, 0, 0, -48, -60, 0, 13, 13, 0}},
+		{withKernCoverage(kern, 0x80), TopToBottom, "TAVAT", []Position{-50, 0, 0, -90, -50, 0, -80, -40, 0, -90, -40, 0, -50, -50, 0}},
+		{withKernCoverage(kern, 0x40), LeftToRight, "TAVAT", []Position{0, 0, 0, 0, 0, -100, 0, 0, -180, 0, 0, -260, 0, 0, -360}},
+		{withKernCoverage(kern, 0xC0), TopToBottom, "TAVAT", []Position{0, 0, 0, 0, 0, -56, 0, 0, -135, 0, 0, -216, 0, 0, -360}},
+		{withKernCoverage(kern1Table(gA, gV, -42, -100), 0x40), LeftToRight, "AVo", []Position{0, 0, -42, 0, 0, -142, 0, 0, -142}},
+	} {
+		kerned := shape(test.kern, test.dir, test.text)
+		unkerned := shape(noSubtables, test.dir, test.text)
+		var got []Position
+		for i, pos := range kerned.Pos {
+			ref := unkerned.Pos[i]
+			if test.dir.isHorizontal() {
+				got = append(got, pos.XAdvance-ref.XAdvance, pos.XOffset-ref.XOffset, pos.YOffset-ref.YOffset)
+			} else {
+				got = append(got, pos.YAdvance-ref.YAdvance, pos.YOffset-ref.YOffset, pos.XOffset-ref.XOffset)
+			}
+		}
+		tu.AssertC(t, reflect.DeepEqual(got, test.expected), fmt.Sprintf("%s %v", test.text, test.dir))
+	}
+}
+
+func TestVerticalKernGPOS(t *testing.T) {
+	src, err := otTD.Files.ReadFile("toys/Kern2.ttf")
+	tu.AssertNoErr(t, err)
+	ld, err := ot.NewLoader(bytes.NewReader(src))
+	tu.AssertNoErr(t, err)
+	kern, err := ld.RawTable(ot.MustNewTag("kern"))
+	tu.AssertNoErr(t, err)
+	verticalKern, err := font.ParseTTF(bytes.NewReader(withTable(t, src, ot.MustNewTag("kern"), withKernCoverage(kern, 0x80))))
+	tu.AssertNoErr(t, err)
+
+	// this font has a GPOS 'vkrn' feature, which HarfBuzz does not enable by default
+	text := []rune("ッ。")
+	ft := openFontFileTT(t, "common/NotoSansCJKjp-VF.otf")
+	gpos, _ := shapeKern(NewFont(font.NewFace(ft)), text, TopToBottom, nil)
+	vkrn, _ := shapeKern(NewFont(font.NewFace(ft)), text, TopToBottom, []Feature{
+		{Tag: ot.NewTag('v', 'k', 'r', 'n'), Value: 1, Start: FeatureGlobalStart, End: FeatureGlobalEnd},
+	})
+	tu.Assert(t, !reflect.DeepEqual(gpos.Pos, vkrn.Pos))
+
+	// adding vertical 'kern' subtables does not change the GPOS output
+	ft.Kern = verticalKern.Kern
+	hbFont := NewFont(font.NewFace(ft))
+	tu.Assert(t, hbFont.Capabilities().Has(capKernVertical))
+	withKern, _ := shapeKern(hbFont, text, TopToBottom, nil)
+	tu.Assert(t, reflect.DeepEqual(withKern.Pos, gpos.Pos))
+}
@@ -0,0 +1,93 @@
+package harfbuzz
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/font"
+	tu "github.com/boxesandglue/typesetting/testutils"
+)
+
+// the expected glyphs are the output of HarfBuzz with Noto Sans Khmer 2.000
+var khmerSyllables = []struct {
+	text     []rune
+	expected []string
+}{
+	// split vowels
+	{[]rune{0x179F, 0x17C5}, []string{"uni17C1", "uni179F17C5"}},
+	{[]rune{0x1780, 0x17C0, 0x17D2, 0x179A}, []string{"uni17D2179A", "uni17C1", "uni1780", "uni17C0.right1"}},
+	{[]rune{0x1780, 0x17C4, 0x17D2, 0x179A}, []string{"uni17D2179A", "uni17C1", "uni178017B6"}},
+	{[]rune{0x1780, 0x17D2, 0x179F, 0x17BF, 0x1793, 0x17D2, 0x178F}, []string{"uni17C1", "uni1780", "uni17D2179F", "uni17BF.right2", "uni1793", "uni17D2178F"}},
+	{[]rune{0x1792, 0x17D2, 0x179B, 0x17C4, 0x1780, 0x17CB}, []string{"uni17C1", "uni179217B6", "uni17D2179B", "uni1780", "uni17CB"}},
+	// coeng + ro
+	{[]rune{0x1784, 0x17D2, 0x1782, 0x17D2, 0x179A}, []string{"uni17D2179A.low", "uni1784", "uni17D21782"}},
+	{[]rune{0x1784, 0x17D2, 0x179A, 0x17D2, 0x1782}, []string{"uni17D2179A.low", "uni1784", "uni17D21782"}},
+	{[]rune{0x178F, 0x17D2, 0x179A, 0x17D2, 0x179F, 0x17C0}, []string{"uni17C1", "uni17D2179A", "uni178F", "uni17D2179F", "uni17C0.right2"}},
+	// registration shifters and robat
+	{[]rune{0x1798, 0x17C9, 0x17D2, 0x179B, 0x17C1, 0x17C7}, []string{"uni17C1", "uni1798", "uni17C9", "uni17D2179B", "uni17C7"}},
+	{[]rune{0x1798, 0x200C, 0x17C9, 0x17D2, 0x179B, 0x17C1, 0x17C7}, []string{"uni17C1", "uni1798", "space", "uni17C9", "uni17D2179B", "uni17C7"}},
+	{[]rune{0x1794, 0x17CA, 0x17D0}, []string{"uni1794", "uni17CA", "uni17D0"}},
+	{[]rune{0x1798, 0x17D2, 0x178F, 0x17D2, 0x179B, 0x17C9, 0x17B6}, []string{"uni179817B6", "uni17D2178F", "uni17D2179B", "uni17C9"}},
+	{[]rune{0x179A, 0x17CD}, []string{"uni179A", "uni17CD.r"}},
+}
+
+func TestKhmerSyllables(t *testing.T) {
+	// Noto Sans Khmer 2.000
+	ft := openFontFile(t, "harfbuzz_reference/in-house/fonts/3998336402905b8be8301ef7f47cf7e050cbb1bd.ttf")
+	face := font.NewFace(ft)
+	hbFont := NewFont(face)
+
+	for _, test := range khmerSyllables {
+		buf := NewBuffer()
+		buf.AddRunes(test.text, 0, -1)
+
+		// these syllables are well formed
+		for i := range buf.Info {
+			setKhmerProperties(&buf.Info[i])
+		}
+		findSyllablesKhmer(buf)
+		for _, info := range buf.Info {
+			tu.AssertC(t, info.syllable&0x0F == khmerConsonantSyllable, fmt.Sprintf("%U", test.text))
+		}
+
+		buf.GuessSegmentProperties()
+		buf.Shape(hbFont, nil)
+
+		var got []string
+		for _, info := range buf.Info {
+			got = append(got, ft.GlyphName(info.Glyph))
+		}
+		tu.AssertC(t, reflect.DeepEqual(got, test.expected), fmt.Sprintf("%U: %v", test.text, got))
+	}
+}
+
+// broken syllables, for which HarfBuzz inserts dotted circles : Khmer OS is not
+// available in the test data, so that the expected glyphs are the output of HarfBuzz with Noto Sans Khmer
+var khmerDottedCircles = []struct {
+	font     string
+	text     []rune
+	expected []string
+}{
+	// registration shifter followed by two above vowels
+	{"b6031119874ae9ff1dd65383a335e361c0962220", []rune{0x179F, 0x17C9, 0x17BE, 0x17BB, 0x1794}, []string{"uni17C1", "uni179F", "uni17C9", "uni17B8", "uni25CC", "uni17BB", "uni1794"}},
+	{"b6031119874ae9ff1dd65383a335e361c0962220", []rune{0x179F, 0x17CA, 0x17B8, 0x17BE, 0x1794}, []string{"uni179F", "uni17BB", "uni17B8", "uni17C1", "uni25CC", "uni17B8", "uni1794"}},
+	// repeated coeng
+	{"ad01ab2ea1cb1a4d3a2783e2675112ef11ae6404", []rune{0x17D2, 0x17D2}, []string{"uni25CC", "uni17D2", "uni25CC", "uni17D2"}},
+}
+
+func TestKhmerDottedCircles(t *testing.T) {
+	for _, test := range khmerDottedCircles {
+		ft := openFontFile(t, "harfbuzz_reference/in-house/fonts/"+test.font+".ttf")
+		buf := NewBuffer()
+		buf.AddRunes(test.text, 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(NewFont(font.NewFace(ft)), nil)
+
+		var got []string
+		for _, info := range buf.Info {
+			got = append(got, ft.GlyphName(info.Glyph))
+		}
+		tu.AssertC(t, reflect.DeepEqual(got, test.expected), fmt.Sprintf("%U: %v", test.text, got))
+	}
+}
@@ -138,9 +138,22 @@ func hasCrossKerning(kern font.Kernx) bool {
 	return false
 }
 
+// tests whether a face includes vertical kerning in the 'kern' table.
+//
+// Does NOT examine the GPOS table.
+func hasVerticalKerning(kern font.Kernx) bool {
+	for _, subtable := range kern {
+		if !subtable.IsHorizontal() {
+			return true
+		}
+	}
+	return false
+}
+
 func (sp *otShapePlan) otLayoutKern(font *Font, buffer *Buffer) {
 	kern := font.face.Kern
 	c := newAatApplyContext(sp, font, buffer)
+	c.positionSource = PositionKern
 	c.applyKernx(kern)
 }
 
@@ -269,6 +282,77 @@ func getFeatureLookupsWithVar(table *font.Layout, featureIndex uint16, variation
 	return nil
 }
 
+// FeatureDigest is an approximate set of the glyphs a feature may act upon,
+// built from the coverage digests of its lookups. See [Font.FeatureDigest].
+type FeatureDigest struct {
+	digest setDigest
+	empty  bool
+}
+
+// FeatureDigest returns the digest of the glyphs covered by the GSUB and GPOS
+// lookups of the feature [tag], for every script and language system, and
+// every feature variation.
+//
+// It may be used to cheaply check that a feature can't affect some text,
+// without shaping it : see [FeatureDigest.MayHave] and [Buffer.MayApplyFeature].
+func (f *Font) FeatureDigest(tag tables.Tag) FeatureDigest {
+	out := FeatureDigest{empty: true}
+	addLookups := func(accels []otLayoutLookupAccelerator, indices []uint16) {
+		for _, index := range indices {
+			if int(index) < len(accels) {
+				out.digest.union(&accels[index].digest)
+				out.empty = false
+			}
+		}
+	}
+	for i, table := range [2]*font.Layout{&f.face.GSUB.Layout, &f.face.GPOS.Layout} {
+		accels := f.gsubAccels
+		if i == 1 {
+			accels = f.gposAccels
+		}
+		for featureIndex, feature := range table.Features {
+			if feature.Tag != tag {
+				continue
+			}
+			addLookups(accels, feature.LookupListIndices)
+			for _, variation := range table.FeatureVariations {
+				for _, sub := range variation.Substitutions.Substitutions {
+					if int(sub.FeatureIndex) == featureIndex {
+						addLookups(accels, sub.AlternateFeature.LookupListIndices)
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+// MayHave performs an approximate member query : if it returns false, [glyph] is
+// certainly not covered by the lookups of the feature.
+// A true result may be a false positive.
+func (fd *FeatureDigest) MayHave(glyph GID) bool {
+	return !fd.empty && fd.digest.mayHave(gID(glyph))
+}
+
+// MayApplyFeature returns false if the feature [tag] of [font] certainly has no effect
+// on the text of the buffer, so that callers may skip shaping the text with and without it
+// (for instance to preview the small capitals of a font).
+// It must be called before shaping, and only checks the nominal glyphs of the runes : the glyphs
+// produced by the substitutions of other features, which are rarely the input of user
+// features, are not taken into account.
+func (b *Buffer) MayApplyFeature(font *Font, tag tables.Tag) bool {
+	digest := font.FeatureDigest(tag)
+	if digest.empty {
+		return false
+	}
+	for _, info := range b.Info {
+		if glyph, ok := font.face.NominalGlyph(info.codepoint); ok && digest.MayHave(glyph) {
+			return true
+		}
+	}
+	return false
+}
+
 // tests whether a specified lookup index in the specified face would
 // trigger a substitution on the given glyph sequence.
 // zeroContext indicating whether substitutions should be context-free.
@@ -283,6 +367,36 @@ func otLayoutLookupWouldSubstitute(font *Font, lookupIndex uint16, glyphs []GID,
 	return l.wouldApply(&c, &font.gsubAccels[lookupIndex])
 }
 
+// WouldSubstitute returns true if one of the GSUB lookups of the feature [featureTag]
+// would apply to [glyphs], that is, would substitute a single glyph (len(glyphs) == 1),
+// or form a ligature from exactly these glyphs.
+//
+// The lookups of all the features of the font with this tag are tried, regardless
+// of the script and language, with the variations for the current coordinates applied.
+// Contextual lookups are matched against [glyphs] only, ignoring their backtrack and
+// lookahead sequences.
+//
+// This is much cheaper than a full shaping, but less accurate : only the result
+// of [Buffer.Shape] is authoritative.
+func (f *Font) WouldSubstitute(featureTag tables.Tag, glyphs []GID) bool {
+	if len(glyphs) == 0 {
+		return false
+	}
+	gsub := &f.face.GSUB.Layout
+	variationsIndex := gsub.FindVariationIndex(f.varCoords())
+	for i, feature := range gsub.Features {
+		if feature.Tag != featureTag {
+			continue
+		}
+		for _, lookupIndex := range getFeatureLookupsWithVar(gsub, uint16(i), variationsIndex) {
+			if otLayoutLookupWouldSubstitute(f, lookupIndex, glyphs, false) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Called before substitution lookups are performed, to ensure that glyph
 // class and other properties are set on the glyphs in the buffer.
 func layoutSubstituteStart(font *Font, buffer *Buffer) {
@@ -299,6 +413,7 @@ func layoutSubstituteStart(font *Font, buffer *Buffer) {
 
 func otLayoutDeleteGlyphsInplace(buffer *Buffer, filter func(*GlyphInfo) bool) {
 	// Merge clusters and delete filtered glyphs.
+	buffer.remapAttachments(filter)
 	var (
 		j    int
 		info = buffer.Info
@@ -368,6 +483,10 @@ func clearSubstitutionFlags(_ *otShapePlan, _ *Font, buffer *Buffer) bool {
 	return false
 }
 
+// reverseGraphemes reverses the buffer, keeping the order of the characters
+// of each grapheme.
+// With MonotoneGraphemes, the clusters of the graphemes are already merged (see [Buffer.formClusters]) :
+// only MonotoneCharacters requires a merge to keep the clusters monotone.
 func reverseGraphemes(b *Buffer) {
-	b.reverseGroups(func(_, gi2 *GlyphInfo) bool { return gi2.isContinuation() }, b.ClusterLevel == MonotoneGraphemes)
+	b.reverseGroups(func(_, gi2 *GlyphInfo) bool { return gi2.isContinuation() }, b.ClusterLevel == MonotoneCharacters)
 }
@@ -46,6 +46,11 @@ const (
 	/* Each attachment should be either a mark or a cursive; can't be both. */
 	attachTypeMark    = 0x01
 	attachTypeCursive = 0x02
+
+	// set while propagating offsets, so that each glyph is only
+	// visited once; the attachment chains are kept after positioning,
+	// so that offsets may be propagated again (see [Buffer.AdjustPositions])
+	attachTypeResolved = 0x80
 )
 
 func positionStartGPOS(buffer *Buffer) {
@@ -55,19 +60,32 @@ func positionStartGPOS(buffer *Buffer) {
 	}
 }
 
+// attachmentParent returns the index of the glyph [i] is attached to,
+// or false if the glyph is not attached (or the chain is invalid)
+func attachmentParent(pos []GlyphPosition, i int) (int, bool) {
+	chain := pos[i].attachChain
+	if chain == 0 {
+		return 0, false
+	}
+	j := i + int(chain)
+	if j < 0 || j >= len(pos) {
+		return 0, false
+	}
+	return j, true
+}
+
 func propagateAttachmentOffsets(pos []GlyphPosition, i int, direction Direction) {
 	/* Adjusts offsets of attached glyphs (both cursive and mark) to accumulate
 	 * offset of glyph they are attached to. */
-	chain, type_ := pos[i].attachChain, pos[i].attachType
-	if chain == 0 {
+	type_ := pos[i].attachType
+	if pos[i].attachChain == 0 || type_&attachTypeResolved != 0 {
 		return
 	}
 
-	pos[i].attachChain = 0
-
-	j := i + int(chain)
+	pos[i].attachType |= attachTypeResolved
 
-	if j >= len(pos) {
+	j, ok := attachmentParent(pos, i)
+	if !ok {
 		return
 	}
 
@@ -85,21 +103,79 @@ func propagateAttachmentOffsets(pos []GlyphPosition, i int, direction Direction)
 		pos[i].XOffset += pos[j].XOffset
 		pos[i].YOffset += pos[j].YOffset
 
-		// assert (j < i);
-		if direction.isForward() {
+		dx, dy := attachmentAdvances(pos, i, j, direction)
+		pos[i].XOffset += dx
+		pos[i].YOffset += dy
+	}
+}
+
+// attachmentAdvances returns the advances to add to the offsets
+// of the mark [i] to move it to the origin of the glyph [j].
+// GPOS only produces j < i; j > i may be found in visual order,
+// once backward text has been reversed.
+func attachmentAdvances(pos []GlyphPosition, i, j int, direction Direction) (dx, dy Position) {
+	if direction.isForward() {
+		if j < i {
 			for _, p := range pos[j:i] {
-				pos[i].XOffset -= p.XAdvance
-				pos[i].YOffset -= p.YAdvance
+				dx -= p.XAdvance
+				dy -= p.YAdvance
 			}
 		} else {
+			for _, p := range pos[i:j] {
+				dx += p.XAdvance
+				dy += p.YAdvance
+			}
+		}
+	} else {
+		if j < i {
 			for _, p := range pos[j+1 : i+1] {
-				pos[i].XOffset += p.XAdvance
-				pos[i].YOffset += p.YAdvance
+				dx += p.XAdvance
+				dy += p.YAdvance
 			}
+		} else {
+			for _, p := range pos[i+1 : j+1] {
+				dx -= p.XAdvance
+				dy -= p.YAdvance
+			}
+		}
+	}
+	return dx, dy
+}
+
+// detachAttachmentOffsets is the inverse of [propagateAttachmentOffsets] :
+// it expresses the offsets of attached glyphs relatively to the glyph they
+// are attached to. [abs] is a copy of [pos], with the propagated offsets.
+func detachAttachmentOffsets(pos, abs []GlyphPosition, direction Direction) {
+	for i := range pos {
+		j, ok := attachmentParent(pos, i)
+		if !ok {
+			continue
+		}
+		if pos[i].attachType&attachTypeCursive != 0 {
+			if direction.isHorizontal() {
+				pos[i].YOffset -= abs[j].YOffset
+			} else {
+				pos[i].XOffset -= abs[j].XOffset
+			}
+		} else {
+			dx, dy := attachmentAdvances(abs, i, j, direction)
+			pos[i].XOffset -= abs[j].XOffset + dx
+			pos[i].YOffset -= abs[j].YOffset + dy
 		}
 	}
 }
 
+// propagateAllAttachmentOffsets propagates the offsets of every attached glyph,
+// keeping the attachment chains.
+func propagateAllAttachmentOffsets(pos []GlyphPosition, direction Direction) {
+	for i := range pos {
+		propagateAttachmentOffsets(pos, i, direction)
+	}
+	for i := range pos {
+		pos[i].attachType &^= attachTypeResolved
+	}
+}
+
 func positionFinishOffsetsGPOS(buffer *Buffer) {
 	pos := buffer.Pos
 	direction := buffer.Props.Direction
@@ -111,9 +187,7 @@ func positionFinishOffsetsGPOS(buffer *Buffer) {
 			fmt.Println("POSITION - handling attachments")
 		}
 
-		for i := range pos {
-			propagateAttachmentOffsets(pos, i, direction)
-		}
+		propagateAllAttachmentOffsets(pos, direction)
 	}
 }
 
@@ -141,9 +215,13 @@ func (c *otApplyContext) applyGPOS(table tables.GPOSLookup) bool {
 	case tables.SinglePos:
 		switch inner := data.Data.(type) {
 		case tables.SinglePosData1:
-			c.applyGPOSValueRecord(inner.ValueFormat, inner.ValueRecord, glyphPos)
+			if c.applyGPOSValueRecord(inner.ValueFormat, inner.ValueRecord, glyphPos) {
+				glyphPos.Source = PositionGPOSSingle
+			}
 		case tables.SinglePosData2:
-			c.applyGPOSValueRecord(inner.ValueFormat, inner.ValueRecords[index], glyphPos)
+			if c.applyGPOSValueRecord(inner.ValueFormat, inner.ValueRecords[index], glyphPos) {
+				glyphPos.Source = PositionGPOSSingle
+			}
 		}
 		buffer.idx++
 	case tables.PairPos:
@@ -227,7 +305,7 @@ func (c *otApplyContext) applyGPOSValueRecord(format tables.ValueFormat, v table
 		return ret
 	}
 
-	xp, yp := font.face.Ppem()
+	xp, yp := font.Ppem()
 	useXDevice := xp != 0 || len(font.varCoords()) != 0
 	useYDevice := yp != 0 || len(font.varCoords()) != 0
 
@@ -281,6 +359,16 @@ func reverseCursiveMinorOffset(pos []GlyphPosition, i int, direction Direction,
 	pos[j].attachType = type_
 }
 
+// setPairSource records the pair adjustment on the glyphs actually modified
+func setPairSource(pos1, pos2 *GlyphPosition, ap1, ap2 bool) {
+	if ap1 {
+		pos1.Source = PositionGPOSPair
+	}
+	if ap2 {
+		pos2.Source = PositionGPOSPair
+	}
+}
+
 func (c *otApplyContext) applyGPOSPair1(inner tables.PairPosData1, index int) bool {
 	buffer := c.buffer
 	skippyIter := &c.iterInput
@@ -294,6 +382,7 @@ func (c *otApplyContext) applyGPOSPair1(inner tables.PairPosData1, index int) bo
 
 	ap1 := c.applyGPOSValueRecord(inner.ValueFormat1, record.ValueRecord1, buffer.curPos(0))
 	ap2 := c.applyGPOSValueRecord(inner.ValueFormat2, record.ValueRecord2, &buffer.Pos[pos])
+	setPairSource(buffer.curPos(0), &buffer.Pos[pos], ap1, ap2)
 
 	if ap1 || ap2 {
 		buffer.unsafeToBreak(buffer.idx, pos+1)
@@ -325,6 +414,7 @@ func (c *otApplyContext) applyGPOSPair2(inner tables.PairPosData2) bool {
 
 	ap1 := c.applyGPOSValueRecord(inner.ValueFormat1, vals.ValueRecord1, buffer.curPos(0))
 	ap2 := c.applyGPOSValueRecord(inner.ValueFormat2, vals.ValueRecord2, &buffer.Pos[skippyIter.idx])
+	setPairSource(buffer.curPos(0), &buffer.Pos[skippyIter.idx], ap1, ap2)
 
 	if ap1 || ap2 {
 		buffer.unsafeToBreak(buffer.idx, skippyIter.idx+1)
@@ -405,6 +495,7 @@ func (c *otApplyContext) applyGPOSCursive(data tables.CursivePos, covIndex int)
 
 		pos[j].YAdvance = roundf(entryY)
 	}
+	pos[i].Source, pos[j].Source = PositionGPOSCursive, PositionGPOSCursive
 
 	/* Cross-direction adjustment */
 
@@ -464,7 +555,7 @@ func (c *otApplyContext) getAnchor(anchor tables.Anchor, glyph GID) (x, y float3
 	case tables.AnchorFormat1:
 		return font.emFscaleX(anchor.XCoordinate), font.emFscaleY(anchor.YCoordinate)
 	case tables.AnchorFormat2:
-		xPpem, yPpem := font.face.Ppem()
+		xPpem, yPpem := font.Ppem()
 		var cx, cy Position
 		ret := xPpem != 0 || yPpem != 0
 		if ret {
@@ -482,7 +573,7 @@ func (c *otApplyContext) getAnchor(anchor tables.Anchor, glyph GID) (x, y float3
 		}
 		return x, y
 	case tables.AnchorFormat3:
-		xPpem, yPpem := font.face.Ppem()
+		xPpem, yPpem := font.Ppem()
 		x, y = font.emFscaleX(anchor.XCoordinate), font.emFscaleY(anchor.YCoordinate)
 		if xPpem != 0 || len(font.varCoords()) != 0 {
 			x += float32(font.getXDelta(c.varStore, anchor.XDevice))
@@ -517,6 +608,7 @@ func (c *otApplyContext) applyGPOSMarks(marks tables.MarkArray, markIndex, glyph
 	o.YOffset = roundf(baseY - markY)
 	o.attachType = attachTypeMark
 	o.attachChain = int16(glyphPos - buffer.idx)
+	o.Source = PositionGPOSMark
 	buffer.scratchFlags |= bsfHasGPOSAttachment
 
 	buffer.idx++
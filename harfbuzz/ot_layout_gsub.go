@@ -54,6 +54,7 @@ func (l lookupGSUB) wouldApply(ctx *wouldApplyContext, accel *otLayoutLookupAcce
 	return false
 }
 
+// isReverse returns true for reverse chaining contextual single substitutions (lookup type 8)
 func (l lookupGSUB) isReverse() bool {
 	if len(l.Subtables) == 0 {
 		return false
@@ -190,15 +191,18 @@ func (c *otApplyContext) applyGSUB(table tables.GSUBLookup) bool {
 		if c.nestingLevelLeft != maxNestingLevel {
 			return false // no chaining to this type
 		}
+		if index >= len(data.SubstituteGlyphIDs) { // index is not sanitized in tt.Parse
+			return false
+		}
 		lB, lL := len(data.BacktrackCoverages), len(data.LookaheadCoverages)
 
+		var endIndex int
 		hasMatch, startIndex := c.matchBacktrack(get1N(&c.indices, 0, lB), matchCoverage(data.BacktrackCoverages))
-		if !hasMatch {
-			return false
+		if hasMatch {
+			hasMatch, endIndex = c.matchLookahead(get1N(&c.indices, 0, lL), matchCoverage(data.LookaheadCoverages), c.buffer.idx+1)
 		}
-
-		hasMatch, endIndex := c.matchLookahead(get1N(&c.indices, 0, lL), matchCoverage(data.LookaheadCoverages), c.buffer.idx+1)
 		if !hasMatch {
+			c.buffer.unsafeToConcatFromOutbuffer(startIndex, endIndex)
 			return false
 		}
 
@@ -37,18 +37,47 @@ type otLayoutLookupAccelerator struct {
 	digest    setDigest
 }
 
-func (ac *otLayoutLookupAccelerator) init(lookup layoutLookup) {
+func (ac *otLayoutLookupAccelerator) init(lookup layoutLookup, strategy DigestStrategy) {
+	patternsOnly := strategy == DigestPatterns
 	ac.lookup = lookup
-	ac.digest = setDigest{}
+	ac.digest = setDigest{patternsOnly: patternsOnly}
 	lookup.collectCoverage(&ac.digest)
 	ac.subtables = nil
 	lookup.dispatchSubtables(&ac.subtables)
+	for i := range ac.subtables {
+		ac.subtables[i].digest.patternsOnly = patternsOnly
+	}
+}
+
+// layoutAcceleratorsKey is the key used to cache the
+// accelerators with [font.Face.ShaperData]
+type layoutAcceleratorsKey struct {
+	strategy DigestStrategy
+}
+
+// layoutAccelerators are the accelerators for the GSUB and GPOS lookups of a font.
+// They only depend on the font tables, and are read-only once built.
+type layoutAccelerators struct {
+	gsub, gpos []otLayoutLookupAccelerator
+}
+
+func newLayoutAccelerators(ft *font.Font, strategy DigestStrategy) layoutAccelerators {
+	var out layoutAccelerators
+	out.gsub = make([]otLayoutLookupAccelerator, len(ft.GSUB.Lookups))
+	for i, l := range ft.GSUB.Lookups {
+		out.gsub[i].init(lookupGSUB(l), strategy)
+	}
+	out.gpos = make([]otLayoutLookupAccelerator, len(ft.GPOS.Lookups))
+	for i, l := range ft.GPOS.Lookups {
+		out.gpos[i].init(lookupGPOS(l), strategy)
+	}
+	return out
 }
 
 // apply the subtables and stops at the first success.
 func (ac *otLayoutLookupAccelerator) apply(c *otApplyContext) bool {
-	for _, table := range ac.subtables {
-		if table.apply(c) {
+	for i := range ac.subtables {
+		if ac.subtables[i].apply(c) {
 			return true
 		}
 	}
@@ -74,7 +103,7 @@ func newGPOSApplicable(table tables.GPOSLookup) applicable {
 	return ap
 }
 
-func (ap applicable) apply(c *otApplyContext) bool {
+func (ap *applicable) apply(c *otApplyContext) bool {
 	return ap.digest.mayHave(gID(c.buffer.cur(0).Glyph)) && ap.objApply(c)
 }
 
@@ -366,7 +395,10 @@ func (c *otApplyContext) reset(tableIndex uint8, font *Font, buffer *Buffer) {
 	c.tableIndex = tableIndex
 	c.lookupMask = 1
 	c.lookupProps = 0
-	c.randomState = 1
+	c.randomState = buffer.randomSeed
+	if c.randomState == 0 {
+		c.randomState = 1
+	}
 	c.lookupIndex = 0
 	c.direction = buffer.Props.Direction
 
@@ -438,7 +470,11 @@ func (c *otApplyContext) matchPropertiesMark(glyph GID, glyphProps uint16, match
 	/* If using mark filtering sets, the high uint16 of
 	 * matchProps has the set index. */
 	if uint16(matchProps)&font.UseMarkFilteringSet != 0 {
-		_, has := c.gdef.MarkGlyphSetsDef.Coverages[matchProps>>16].Index(gID(glyph))
+		sets := c.gdef.MarkGlyphSetsDef.Coverages
+		if int(matchProps>>16) >= len(sets) { // invalid set, treated as empty
+			return false
+		}
+		_, has := sets[matchProps>>16].Index(gID(glyph))
 		return has
 	}
 
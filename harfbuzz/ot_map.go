@@ -5,6 +5,7 @@ import (
 	"math"
 	"math/bits"
 	"sort"
+	"sync"
 
 	"github.com/boxesandglue/typesetting/font"
 	ot "github.com/boxesandglue/typesetting/font/opentype"
@@ -56,6 +57,7 @@ type stageInfo struct {
 
 type otMapBuilder struct {
 	tables        *font.Font
+	capabilities  Capabilities
 	props         SegmentProperties
 	stages        [2][]stageInfo
 	featureInfos  []featureInfo
@@ -64,12 +66,14 @@ type otMapBuilder struct {
 	currentStage  [2]int
 	chosenScript  [2]tables.Tag
 	foundScript   [2]bool
+	justification int // see [Buffer.Justification]
 }
 
-func newOtMapBuilder(tables *font.Font, props SegmentProperties) otMapBuilder {
+func newOtMapBuilder(tables *font.Font, capabilities Capabilities, props SegmentProperties) otMapBuilder {
 	var out otMapBuilder
 
 	out.tables = tables
+	out.capabilities = capabilities
 	out.props = props
 
 	/* Fetch script/language indices for GSUB/GPOS.  We need these later to skip
@@ -206,12 +210,19 @@ func (mb *otMapBuilder) compile(m *otMap, key otShapePlanKey) {
 			if requiredFeatureTag[tableIndex] == info.Tag {
 				requiredFeatureStage[tableIndex] = info.stage[tableIndex]
 			}
-			featureIndex[tableIndex] = findFeatureForLang(table, mb.scriptIndex[tableIndex], mb.languageIndex[tableIndex], info.Tag)
+			featureIndex[tableIndex] = NoFeatureIndex
+			// skip the (linear) search if the feature is not defined at all
+			if mb.capabilities.hasFeature(tableIndex, info.Tag) {
+				featureIndex[tableIndex] = findFeatureForLang(table, mb.scriptIndex[tableIndex], mb.languageIndex[tableIndex], info.Tag)
+			}
 			found = found || featureIndex[tableIndex] != NoFeatureIndex
 		}
 		if !found && (info.flags&ffGlobalSearch) != 0 {
 			for tableIndex, table := range tables {
-				featureIndex[tableIndex] = findFeature(table, info.Tag)
+				featureIndex[tableIndex] = NoFeatureIndex
+				if mb.capabilities.hasFeature(tableIndex, info.Tag) {
+					featureIndex[tableIndex] = findFeature(table, info.Tag)
+				}
 				found = found || featureIndex[tableIndex] != NoFeatureIndex
 			}
 		}
@@ -310,6 +321,28 @@ func (mb *otMapBuilder) compile(m *otMap, key otShapePlanKey) {
 			}
 		}
 	}
+
+	if mb.justification != 0 {
+		jstf := mb.justificationLookups(mb.justification)
+		m.applyJustification(jstf, [2]int{len(gsub.Lookups), len(gpos.Lookups)}, globalBitMask)
+	}
+}
+
+// restorePauses sets the pause functions of the stages of [m], a map
+// previously compiled from the same features, whose functions
+// have been lost (see [Buffer.UnmarshalPlans]).
+func (mb *otMapBuilder) restorePauses(m *otMap) error {
+	for tableIndex, stages := range mb.stages {
+		// compile adds a last stage, without pause
+		if len(m.stages[tableIndex]) != len(stages)+1 {
+			return fmt.Errorf("invalid number of stages (%d, expected %d)", len(m.stages[tableIndex]), len(stages)+1)
+		}
+		for i, stage := range stages {
+			m.stages[tableIndex][i].pauseFunc = stage.pauseFunc
+		}
+		m.stages[tableIndex][len(stages)].pauseFunc = nil
+	}
+	return nil
 }
 
 func (mb *otMapBuilder) hasFeature(tag ot.Tag) bool {
@@ -383,10 +416,13 @@ type otMap struct {
 	chosenScript [2]tables.Tag
 	globalMask   GlyphMask
 	foundScript  [2]bool
-
-	applyContext otApplyContext // buffer
 }
 
+// applyContextPool stores the contexts used by [otMap.apply], so that
+// shaping does not modify the (cached) maps and plans, which may thus
+// be used by several goroutines.
+var applyContextPool = sync.Pool{New: func() any { return new(otApplyContext) }}
+
 func (m *otMap) needsFallback(featureTag tables.Tag) bool {
 	if ma := bsearchFeature(m.features, featureTag); ma != nil {
 		return ma.needsFallback
@@ -459,10 +495,12 @@ func (m *otMap) substitute(plan *otShapePlan, font *Font, buffer *Buffer) {
 	if debugMode {
 		fmt.Println("SUBSTITUTE - start table GSUB")
 	}
+	buffer.trace(TraceEvent{Kind: TraceTableStart, Table: tagGSUB})
 
 	proxy := otProxy{otProxyMeta: proxyGSUB, accels: font.gsubAccels}
 	m.apply(proxy, plan, font, buffer)
 
+	buffer.trace(TraceEvent{Kind: TraceTableEnd, Table: tagGSUB})
 	if debugMode {
 		fmt.Println("SUBSTITUTE - end table GSUB")
 	}
@@ -473,10 +511,12 @@ func (m *otMap) position(plan *otShapePlan, font *Font, buffer *Buffer) {
 	if debugMode {
 		fmt.Println("POSITION - start table GPOS")
 	}
+	buffer.trace(TraceEvent{Kind: TraceTableStart, Table: tagGPOS})
 
 	proxy := otProxy{otProxyMeta: proxyGPOS, accels: font.gposAccels}
 	m.apply(proxy, plan, font, buffer)
 
+	buffer.trace(TraceEvent{Kind: TraceTableEnd, Table: tagGPOS})
 	if debugMode {
 		fmt.Println("POSITION - end table GPOS")
 	}
@@ -484,8 +524,17 @@ func (m *otMap) position(plan *otShapePlan, font *Font, buffer *Buffer) {
 
 func (m *otMap) apply(proxy otProxy, plan *otShapePlan, font *Font, buffer *Buffer) {
 	tableIndex := proxy.tableIndex
+	table := tagGSUB
+	if tableIndex == 1 {
+		table = tagGPOS
+	}
 	i := 0
-	c := &m.applyContext
+	c := applyContextPool.Get().(*otApplyContext)
+	defer func() {
+		// do not retain the buffer and font
+		c.font, c.buffer = nil, nil
+		applyContextPool.Put(c)
+	}()
 
 	c.reset(tableIndex, font, buffer)
 	c.recurseFunc = proxy.recurseFunc
@@ -503,13 +552,16 @@ func (m *otMap) apply(proxy otProxy, plan *otShapePlan, font *Font, buffer *Buff
 			if debugMode {
 				fmt.Printf("\t\tLookup %d start\n", lookupIndex)
 			}
+			if buffer.Tracer != nil {
+				buffer.trace(TraceEvent{Kind: TraceLookupStart, Table: table, Index: int(lookupIndex), Feature: lookup.featureTag})
+			}
 
 			// c.digest is a digest of all the current glyphs in the buffer
 			// (plus some past glyphs).
 			//
 			// Only try applying the lookup if there is any overlap. */
 			accel := &proxy.accels[lookupIndex]
-			if accel.digest.mayHaveDigest(c.digest) {
+			if accel.digest.mayHaveDigest(&c.digest) {
 
 				c.lookupIndex = lookupIndex
 				c.lookupMask = lookup.mask
@@ -525,6 +577,10 @@ func (m *otMap) apply(proxy otProxy, plan *otShapePlan, font *Font, buffer *Buff
 				c.applyString(proxy.otProxyMeta, accel)
 			}
 
+			if buffer.Tracer != nil {
+				buffer.trace(TraceEvent{Kind: TraceLookupEnd, Table: table, Index: int(lookupIndex), Feature: lookup.featureTag})
+			}
+
 			if debugMode {
 				fmt.Print("\t\tLookup end : ")
 				if proxy.tableIndex == 0 {
@@ -540,6 +596,7 @@ func (m *otMap) apply(proxy otProxy, plan *otShapePlan, font *Font, buffer *Buff
 			if debugMode {
 				fmt.Println("\t\tExecuting pause function")
 			}
+			buffer.trace(TraceEvent{Kind: TracePause, Table: table, Index: stageI})
 
 			if stage.pauseFunc(plan, font, buffer) {
 				// Refresh working buffer digest since buffer changed.
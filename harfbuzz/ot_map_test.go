@@ -1,9 +1,13 @@
 package harfbuzz
 
 import (
+	"reflect"
+	"sync"
 	"testing"
 
+	"github.com/boxesandglue/typesetting/font"
 	ot "github.com/boxesandglue/typesetting/font/opentype"
+	tu "github.com/boxesandglue/typesetting/testutils"
 )
 
 func TestOTFeature(t *testing.T) {
@@ -16,3 +20,47 @@ func TestOTFeature(t *testing.T) {
 		t.Fatal("failed to find feature index")
 	}
 }
+
+func TestShapePlanConcurrent(t *testing.T) {
+	ft := openFontFileTT(t, "common/NotoSansArabic.ttf")
+	text := []rune("\u0633\u0644\u0627\u0645 \u0639\u0644\u064a\u0643\u0645")
+
+	shape := func(plan *shapePlan, face *font.Face) []GlyphInfo {
+		buf := NewBuffer()
+		buf.AddRunes(text, 0, -1)
+		buf.GuessSegmentProperties()
+		hbFont := NewFont(face)
+		if plan == nil {
+			buf.Shape(hbFont, nil)
+		} else {
+			plan.execute(hbFont, buf, nil)
+		}
+		return buf.Info
+	}
+
+	// build the plan
+	face := font.NewFace(ft)
+	buf := NewBuffer()
+	buf.AddRunes(text, 0, -1)
+	buf.GuessSegmentProperties()
+	buf.Shape(NewFont(face), nil)
+	plans := buf.planCache[face]
+	tu.Assert(t, len(plans) == 1)
+	expected := buf.Info
+
+	// share the plan between goroutines, each one using its own face and buffer
+	var wg sync.WaitGroup
+	results := make([][]GlyphInfo, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = shape(plans[0], font.NewFace(ft))
+		}(i)
+	}
+	wg.Wait()
+	for _, res := range results {
+		tu.Assert(t, reflect.DeepEqual(res, expected))
+	}
+	tu.Assert(t, reflect.DeepEqual(shape(nil, font.NewFace(ft)), expected))
+}
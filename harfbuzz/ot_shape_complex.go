@@ -61,6 +61,10 @@ type otComplexShaper interface {
 var scriptMyanmarZawgyi = language.Script(ot.NewTag('Q', 'a', 'a', 'g'))
 
 func (planner *otShapePlanner) categorizeComplex() otComplexShaper {
+	if shaper := lookupCustomShaper(planner.props.Script); shaper != nil {
+		return shaper
+	}
+
 	switch planner.props.Script {
 	case language.Arabic, language.Syriac:
 		/* For Arabic script, use the Arabic shaper even if no OT script tag was found.
@@ -133,7 +137,9 @@ func (planner *otShapePlanner) categorizeComplex() otComplexShaper {
 		language.Hanifi_Rohingya, language.Makasar, language.Medefaidrin, language.Old_Sogdian,
 		language.Sogdian, language.Elymaic, language.Nandinagari, language.Nyiakeng_Puachue_Hmong,
 		language.Wancho,
-		language.Chorasmian, language.Dives_Akuru, language.Khitan_Small_Script, language.Yezidi:
+		language.Chorasmian, language.Dives_Akuru, language.Khitan_Small_Script, language.Yezidi,
+		language.Cypro_Minoan, language.Old_Uyghur, language.Tangsa, language.Toto, language.Vithkuqi,
+		language.Kawi, language.Nag_Mundari:
 
 		/* If the designer designed the font for the 'DFLT' script,
 		 * (or we ended up arbitrarily pick 'latn'), use the default shaper.
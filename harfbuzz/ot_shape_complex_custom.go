@@ -0,0 +1,164 @@
+package harfbuzz
+
+import (
+	"sync"
+
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/language"
+)
+
+// ComplexShaper implements the script specific logic (like reordering or contextual forms)
+// required to shape a script not supported by this package, typically
+// a constructed script encoded in the Private Use Area.
+//
+// It is a restricted version of the interface implemented by the builtin shapers,
+// which may be registered with [RegisterComplexShaper].
+//
+// The methods are called during [Buffer.Shape], with the following order :
+//   - PreprocessText
+//   - (normalization and glyph mapping)
+//   - SetupMasks
+//   - the features returned by Features, with their pauses (and the common features)
+//   - (positioning)
+//   - PostprocessGlyphs
+type ComplexShaper interface {
+	// Features returns the features applied by the shaper, in order.
+	// It is called once for each shaping plan, that is for each combination
+	// of font, segment properties and user features.
+	Features(props SegmentProperties) []ComplexShaperFeature
+
+	// PreprocessText is called before normalization, and may modify
+	// the text of [buffer], for instance by inserting characters.
+	PreprocessText(buffer *Buffer, font *Font)
+
+	// SetupMasks is called before applying GSUB, and should use [ComplexShaperPlan.FeatureMask]
+	// to enable the non global features on the relevant glyphs.
+	// It must not modify the characters of [buffer].
+	SetupMasks(plan ComplexShaperPlan, buffer *Buffer, font *Font)
+
+	// PostprocessGlyphs is called at the end of shaping, after positioning.
+	PostprocessGlyphs(buffer *Buffer, font *Font)
+}
+
+// ComplexShaperFeature is a feature applied by a [ComplexShaper].
+type ComplexShaperFeature struct {
+	Tag ot.Tag
+
+	// Global features are applied to all the glyphs; the other ones are only
+	// applied to the glyphs selected in [ComplexShaper.SetupMasks].
+	Global bool
+
+	// ManualJoiners disables the automatic skipping of ZWJ and ZWNJ
+	// when applying the lookups of the feature.
+	ManualJoiners bool
+
+	// Pause, if not nil, is called after applying the feature (and the features before it),
+	// and before applying the next ones, for instance to reorder the glyphs.
+	// The features which are not separated by a pause are applied together,
+	// in lookup order.
+	Pause func(plan ComplexShaperPlan, buffer *Buffer, font *Font)
+}
+
+// ComplexShaperPlan gives access to the shaping plan from
+// the methods of a [ComplexShaper].
+type ComplexShaperPlan struct {
+	plan *otShapePlan
+}
+
+// Props returns the segment properties of the plan.
+func (p ComplexShaperPlan) Props() SegmentProperties { return p.plan.props }
+
+// FeatureMask returns the mask enabling the given feature, or 0
+// if the feature is not applied by the plan.
+func (p ComplexShaperPlan) FeatureMask(tag ot.Tag) GlyphMask { return p.plan.map_.getMask1(tag) }
+
+var (
+	customShapersLock sync.RWMutex
+	customShapers     map[language.Script]ComplexShaper
+)
+
+// RegisterComplexShaper registers a shaper used for the text of the given [script],
+// which replaces the builtin shaper, if any.
+// Passing a nil [shaper] removes the registration.
+//
+// Since shaping plans are cached by [Buffer], it should be called before shaping,
+// typically in an init function.
+//
+// Constructed scripts may use the private use script codes of ISO 15924 (Qaaa to Qabx),
+// for instance :
+//
+//	RegisterComplexShaper(language.Script(ot.NewTag('Q', 'a', 'a', 'b')), myShaper)
+//
+// Note that the script of such texts is not detected by [Buffer.GuessSegmentProperties]
+// and must be set by the caller.
+func RegisterComplexShaper(script language.Script, shaper ComplexShaper) {
+	customShapersLock.Lock()
+	defer customShapersLock.Unlock()
+
+	if shaper == nil {
+		delete(customShapers, script)
+		return
+	}
+	if customShapers == nil {
+		customShapers = make(map[language.Script]ComplexShaper)
+	}
+	customShapers[script] = shaper
+}
+
+// lookupCustomShaper returns the shaper registered for [script], or nil.
+func lookupCustomShaper(script language.Script) otComplexShaper {
+	customShapersLock.RLock()
+	defer customShapersLock.RUnlock()
+
+	if shaper := customShapers[script]; shaper != nil {
+		return complexShaperCustom{shaper: shaper}
+	}
+	return nil
+}
+
+// complexShaperCustom adapts a [ComplexShaper] to the internal interface
+type complexShaperCustom struct {
+	complexShaperNil
+
+	shaper ComplexShaper
+}
+
+func (complexShaperCustom) marksBehavior() (zeroWidthMarks, bool) {
+	return zeroWidthMarksByGdefLate, true
+}
+
+func (complexShaperCustom) normalizationPreference() normalizationMode {
+	return nmDefault
+}
+
+func (cs complexShaperCustom) collectFeatures(plan *otShapePlanner) {
+	map_ := &plan.map_
+	for _, feature := range cs.shaper.Features(plan.props) {
+		flags := ffNone
+		if feature.Global {
+			flags |= ffGLOBAL
+		}
+		if feature.ManualJoiners {
+			flags |= ffManualJoiners
+		}
+		map_.addFeatureExt(feature.Tag, flags, 1)
+		if pause := feature.Pause; pause != nil {
+			map_.addGSUBPause(func(plan *otShapePlan, font *Font, buffer *Buffer) bool {
+				pause(ComplexShaperPlan{plan}, buffer, font)
+				return true
+			})
+		}
+	}
+}
+
+func (cs complexShaperCustom) preprocessText(_ *otShapePlan, buffer *Buffer, font *Font) {
+	cs.shaper.PreprocessText(buffer, font)
+}
+
+func (cs complexShaperCustom) setupMasks(plan *otShapePlan, buffer *Buffer, font *Font) {
+	cs.shaper.SetupMasks(ComplexShaperPlan{plan}, buffer, font)
+}
+
+func (cs complexShaperCustom) postprocessGlyphs(_ *otShapePlan, buffer *Buffer, font *Font) {
+	cs.shaper.PostprocessGlyphs(buffer, font)
+}
@@ -0,0 +1,88 @@
+package harfbuzz
+
+import (
+	"testing"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
+)
+
+// testCustomShaper applies small capitals to the 'a' letters,
+// and swaps the first two glyphs
+type testCustomShaper struct {
+	calls []string
+}
+
+var smcpTag = ot.MustNewTag("smcp")
+
+func (cs *testCustomShaper) Features(props SegmentProperties) []ComplexShaperFeature {
+	cs.calls = append(cs.calls, "features")
+	return []ComplexShaperFeature{
+		{Tag: smcpTag, Pause: func(plan ComplexShaperPlan, buffer *Buffer, font *Font) {
+			cs.calls = append(cs.calls, "pause")
+			if len(buffer.Info) >= 2 {
+				buffer.MergeClusters(0, 2)
+				buffer.Info[0], buffer.Info[1] = buffer.Info[1], buffer.Info[0]
+			}
+		}},
+	}
+}
+
+func (cs *testCustomShaper) PreprocessText(buffer *Buffer, font *Font) {
+	cs.calls = append(cs.calls, "preprocess")
+}
+
+func (cs *testCustomShaper) SetupMasks(plan ComplexShaperPlan, buffer *Buffer, font *Font) {
+	cs.calls = append(cs.calls, "masks")
+	mask := plan.FeatureMask(smcpTag)
+	for i, info := range buffer.Info {
+		if info.Codepoint() == 'a' {
+			buffer.Info[i].Mask |= mask
+		}
+	}
+}
+
+func (cs *testCustomShaper) PostprocessGlyphs(buffer *Buffer, font *Font) {
+	cs.calls = append(cs.calls, "postprocess")
+}
+
+func TestCustomShaper(t *testing.T) {
+	face := font.NewFace(openFontFileTT(t, "common/Raleway-v4020-Regular.otf"))
+	hbFont := NewFont(face)
+	script := language.Script(ot.NewTag('Q', 'a', 'a', 'b'))
+
+	shape := func(text string, script language.Script, features []Feature) []GlyphInfo {
+		buf := NewBuffer()
+		buf.AddRunes([]rune(text), 0, -1)
+		buf.Props = SegmentProperties{Direction: LeftToRight, Script: script, Language: "en"}
+		buf.Shape(hbFont, features)
+		return buf.Info
+	}
+	smallCaps := shape("abab", language.Latin, []Feature{{Tag: smcpTag, Value: 1, Start: 0, End: FeatureGlobalEnd}})
+	regular := shape("abab", language.Latin, nil)
+	tu.Assert(t, smallCaps[0].Glyph != regular[0].Glyph && smallCaps[1].Glyph != regular[1].Glyph)
+
+	shaper := &testCustomShaper{}
+	RegisterComplexShaper(script, shaper)
+	defer RegisterComplexShaper(script, nil)
+
+	got := shape("abab", script, nil)
+	tu.Assert(t, len(shaper.calls) == 5)
+	for i, call := range []string{"features", "preprocess", "masks", "pause", "postprocess"} {
+		tu.Assert(t, shaper.calls[i] == call)
+	}
+	tu.Assert(t, len(got) == 4)
+	// swapped, with merged clusters
+	tu.Assert(t, got[0].Glyph == regular[1].Glyph && got[1].Glyph == smallCaps[0].Glyph)
+	tu.Assert(t, got[0].Cluster == 0 && got[1].Cluster == 0)
+	tu.Assert(t, got[2].Glyph == smallCaps[2].Glyph && got[3].Glyph == regular[3].Glyph)
+
+	// unregistered
+	RegisterComplexShaper(script, nil)
+	got = shape("abab", script, nil)
+	for i := range got {
+		tu.Assert(t, got[i].Glyph == regular[i].Glyph)
+	}
+}
@@ -162,6 +162,7 @@ func positionMark(font *Font, buffer *Buffer, baseExtents *GlyphExtents,
 	pos := &buffer.Pos[i]
 	pos.XOffset = 0
 	pos.YOffset = 0
+	pos.Source = PositionFallback
 
 	// we don't position LEFT and RIGHT marks.
 
@@ -226,7 +227,7 @@ func positionMark(font *Font, buffer *Buffer, baseExtents *GlyphExtents,
 }
 
 func positionAroundBase(plan *otShapePlan, font *Font, buffer *Buffer,
-	base, end int, adjustOffsetsWhenZeroing bool,
+	base, end int, adjustOffsetsWhenZeroing bool, attached []bool,
 ) {
 	buffer.unsafeToBreak(base, end)
 
@@ -261,6 +262,11 @@ func positionAroundBase(plan *otShapePlan, font *Font, buffer *Buffer,
 	for i := base + 1; i < end; i++ {
 		thisCombiningClass := info[i].getModifiedCombiningClass()
 
+		if attached != nil && attached[i] {
+			// already positioned by the font
+			continue
+		}
+
 		if thisCombiningClass != 0 {
 			if numLigComponents > 1 {
 				thisLigID := info[i].getLigID()
@@ -314,7 +320,7 @@ func positionAroundBase(plan *otShapePlan, font *Font, buffer *Buffer,
 }
 
 func positionCluster(plan *otShapePlan, font *Font, buffer *Buffer,
-	start, end int, adjustOffsetsWhenZeroing bool,
+	start, end int, adjustOffsetsWhenZeroing bool, attached []bool,
 ) {
 	if end-start < 2 {
 		return
@@ -332,25 +338,38 @@ func positionCluster(plan *otShapePlan, font *Font, buffer *Buffer,
 				}
 			}
 
-			positionAroundBase(plan, font, buffer, i, j, adjustOffsetsWhenZeroing)
+			positionAroundBase(plan, font, buffer, i, j, adjustOffsetsWhenZeroing, attached)
 
 			i = j - 1
 		}
 	}
 }
 
+// fallbackMarkPosition positions the marks around their base.
+// If [attached] is not nil, the glyphs for which it is true are left untouched.
 func fallbackMarkPosition(plan *otShapePlan, font *Font, buffer *Buffer,
-	adjustOffsetsWhenZeroing bool,
+	adjustOffsetsWhenZeroing bool, attached []bool,
 ) {
 	var start int
 	info := buffer.Info
 	for i := 1; i < len(info); i++ {
 		if !info[i].isUnicodeMark() {
-			positionCluster(plan, font, buffer, start, i, adjustOffsetsWhenZeroing)
+			positionCluster(plan, font, buffer, start, i, adjustOffsetsWhenZeroing, attached)
 			start = i
 		}
 	}
-	positionCluster(plan, font, buffer, start, len(info), adjustOffsetsWhenZeroing)
+	positionCluster(plan, font, buffer, start, len(info), adjustOffsetsWhenZeroing, attached)
+}
+
+// attachedGlyphs returns, for each glyph, true if it is attached
+// to another glyph (by GPOS or 'kerx'). It must be called before
+// the attachments are resolved.
+func attachedGlyphs(buffer *Buffer) []bool {
+	out := make([]bool, len(buffer.Pos))
+	for i, pos := range buffer.Pos {
+		out[i] = pos.attachChain != 0
+	}
+	return out
 }
 
 // adjusts width of various spaces.
@@ -365,6 +384,7 @@ func fallbackSpaces(font *Font, buffer *Buffer) {
 		if !inf.isUnicodeSpace() || inf.ligated() {
 			continue
 		}
+		pos[i].Source = PositionFallback
 
 		// If font had no ASCII space and we used the invisible glyph, give it a 1/4 EM default advance.
 		if buffer.Invisible != 0 && info[i].Glyph == buffer.Invisible {
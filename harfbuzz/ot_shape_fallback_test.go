@@ -1,6 +1,13 @@
 package harfbuzz
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/font"
+	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
+)
 
 func TestRecategorize(t *testing.T) {
 	runes := []rune{1615, 1617, 1614, 1616}
@@ -14,3 +21,71 @@ func TestRecategorize(t *testing.T) {
 		}
 	}
 }
+
+func shapeMarks(font *Font, text string, flags ShappingOptions) []GlyphPosition {
+	buf := NewBuffer()
+	buf.Flags = flags
+	buf.AddRunes([]rune(text), 0, -1)
+	buf.Props.Direction = LeftToRight
+	buf.Props.Script = language.Latin
+	buf.Shape(font, nil)
+	return buf.Pos
+}
+
+func TestForceFallbackMarkPositioning(t *testing.T) {
+	// Raleway has no anchor for the dot below on 'x',
+	// Oldania has a GPOS table without mark positioning
+	for _, file := range []string{"common/Raleway-v4020-Regular.otf", "common/OldaniaADFStd-Bold.otf"} {
+		hbFont := NewFont(font.NewFace(openFontFileTT(t, file)))
+
+		pos := shapeMarks(hbFont, "x̣", 0)
+		tu.Assert(t, len(pos) == 2)
+		tu.Assert(t, pos[1].XOffset == 0)
+
+		pos = shapeMarks(hbFont, "x̣", FallbackMarkPositioning)
+		tu.Assert(t, len(pos) == 2)
+		tu.Assert(t, pos[1].XAdvance == 0)
+		tu.Assert(t, pos[1].XOffset < 0) // moved below the base
+	}
+
+	// marks attached by GPOS are not modified
+	hbFont := NewFont(font.NewFace(openFontFileTT(t, "common/DejaVuSans.ttf")))
+	for _, text := range []string{"x̣", "q̇", "x̆́"} {
+		tu.Assert(t, reflect.DeepEqual(shapeMarks(hbFont, text, 0), shapeMarks(hbFont, text, FallbackMarkPositioning)))
+	}
+}
+
+func TestMarkClassifier(t *testing.T) {
+	// this font has no GDEF table
+	ft := openFontFileTT(t, "toys/Kern2.ttf")
+	tu.Assert(t, ft.GDEF.GlyphClassDef == nil)
+	hbFont := NewFont(font.NewFace(ft))
+
+	shape := func(isMark func(rune, GID) bool) *Buffer {
+		buf := NewBuffer()
+		buf.MarkClassifier = isMark
+		buf.AddRunes([]rune("ab"), 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(hbFont, nil)
+		return buf
+	}
+
+	buf := shape(nil)
+	tu.Assert(t, !buf.Info[1].isMark() && buf.Pos[1].XAdvance != 0)
+
+	// 'b' is considered as a mark, and has its advance zeroed
+	var calls []rune
+	buf = shape(func(r rune, glyph GID) bool {
+		gid, _ := ft.Cmap.Lookup(r)
+		tu.Assert(t, glyph == gid)
+		calls = append(calls, r)
+		return r == 'b'
+	})
+	tu.Assert(t, reflect.DeepEqual(calls, []rune("ab")))
+	tu.Assert(t, !buf.Info[0].isMark() && buf.Pos[0].XAdvance != 0)
+	tu.Assert(t, buf.Info[1].isMark() && buf.Pos[1].XAdvance == 0)
+
+	// the classifier is reset by Clear
+	buf.Clear()
+	tu.Assert(t, buf.MarkClassifier == nil)
+}
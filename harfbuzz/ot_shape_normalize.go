@@ -2,6 +2,8 @@ package harfbuzz
 
 import (
 	"fmt"
+
+	ucd "github.com/boxesandglue/typesetting/unicodedata"
 )
 
 // ported from harfbuzz/src/hb-ot-shape-normalize.cc Copyright © 2011,2012  Google, Inc. Behdad Esfahbod
@@ -140,6 +142,18 @@ func (c *otNormalizeContext) decomposeCurrentCharacter(shortest bool) {
 		return
 	}
 
+	// CJK compatibility ideographs would lose their distinctive glyph when decomposed:
+	// prefer the glyph from the font, or from the equivalent variation sequence.
+	if base, selector, isCompat := ucd.LookupCompatibilityIdeographVariant(u); isCompat {
+		if !ok {
+			glyph, ok = c.font.face.VariationGlyph(base, selector)
+		}
+		if ok {
+			nextChar(buffer, glyph)
+			return
+		}
+	}
+
 	if decompose(c, shortest, u) != 0 {
 		buffer.skipGlyph()
 		return
@@ -172,6 +186,14 @@ func (c *otNormalizeContext) decomposeCurrentCharacter(shortest bool) {
 		}
 	}
 
+	if u == softHyphen && buffer.SoftHyphen == SoftHyphenVisible {
+		// fonts are not required to map U+00AD
+		if otherGlyph, ok := c.font.HyphenGlyph(); ok {
+			nextChar(buffer, otherGlyph)
+			return
+		}
+	}
+
 	nextChar(buffer, glyph)
 }
 
@@ -185,7 +207,7 @@ func (c *otNormalizeContext) handleVariationSelectorCluster(end int) {
 	for buffer.idx < end-1 {
 		if uni.isVariationSelector(buffer.cur(+1).codepoint) {
 			var ok bool
-			buffer.cur(0).Glyph, ok = font.face.VariationGlyph(buffer.cur(0).codepoint, buffer.cur(+1).codepoint)
+			buffer.cur(0).Glyph, ok = font.variationGlyph(buffer.cur(0).codepoint, buffer.cur(+1).codepoint)
 			if ok {
 				r := buffer.cur(0).codepoint
 				buffer.replaceGlyphs(2, []rune{r}, nil)
@@ -212,6 +234,20 @@ func (c *otNormalizeContext) handleVariationSelectorCluster(end int) {
 	}
 }
 
+// variationGlyph returns the glyph for [u] followed by the variation [selector],
+// as given by the 'cmap' format 14 subtable.
+// For the standardized variation sequences of CJK compatibility ideographs
+// missing in this subtable, the glyph of the compatibility ideograph is used instead.
+func (f *Font) variationGlyph(u, selector rune) (GID, bool) {
+	if glyph, ok := f.face.VariationGlyph(u, selector); ok {
+		return glyph, true
+	}
+	if compat, ok := ucd.LookupCompatibilityIdeograph(u, selector); ok {
+		return f.face.NominalGlyph(compat)
+	}
+	return 0, false
+}
+
 func (c *otNormalizeContext) decomposeMultiCharCluster(end int, shortCircuit bool) {
 	buffer := c.buffer
 	if debugMode {
@@ -30,20 +30,24 @@ type otShapePlanner struct {
 	shaper                        otComplexShaper
 	props                         SegmentProperties
 	tables                        *font.Font // also used by the map builders
+	capabilities                  Capabilities
 	map_                          otMapBuilder
 	applyMorx                     bool
 	scriptZeroMarks               bool
 	scriptFallbackMarkPositioning bool
+	syntheticCJKSpacing           bool // see [SyntheticCJKSpacing]
+	vertOnly                      bool // see [VerticalAlternatesVertOnly]
 }
 
-func newOtShapePlanner(tables *font.Font, props SegmentProperties) *otShapePlanner {
+func newOtShapePlanner(tables *font.Font, capabilities Capabilities, props SegmentProperties) *otShapePlanner {
 	var out otShapePlanner
 	out.props = props
 	out.tables = tables
-	out.map_ = newOtMapBuilder(tables, props)
+	out.capabilities = capabilities
+	out.map_ = newOtMapBuilder(tables, capabilities, props)
 
 	/* https://github.com/harfbuzz/harfbuzz/issues/2124 */
-	out.applyMorx = len(tables.Morx) != 0 && (props.Direction.isHorizontal() || len(tables.GSUB.Lookups) == 0)
+	out.applyMorx = capabilities.Has(CapMorx) && (props.Direction.isHorizontal() || len(tables.GSUB.Lookups) == 0)
 
 	out.shaper = out.categorizeComplex()
 
@@ -58,10 +62,19 @@ func newOtShapePlanner(tables *font.Font, props SegmentProperties) *otShapePlann
 	return &out
 }
 
-func (planner *otShapePlanner) compile(plan *otShapePlan, key otShapePlanKey) {
+// compile builds the plan. If [precompiled] is not nil, it is used instead of
+// compiling the map (see [Buffer.UnmarshalPlans]).
+func (planner *otShapePlanner) compile(plan *otShapePlan, key otShapePlanKey, precompiled *otMap) error {
 	plan.props = planner.props
 	plan.shaper = planner.shaper
-	planner.map_.compile(&plan.map_, key)
+	if precompiled != nil {
+		plan.map_ = *precompiled
+		if err := planner.map_.restorePauses(&plan.map_); err != nil {
+			return err
+		}
+	} else {
+		planner.map_.compile(&plan.map_, key)
+	}
 
 	plan.fracMask = plan.map_.getMask1(ot.NewTag('f', 'r', 'a', 'c'))
 	plan.numrMask = plan.map_.getMask1(ot.NewTag('n', 'u', 'm', 'r'))
@@ -69,11 +82,11 @@ func (planner *otShapePlanner) compile(plan *otShapePlan, key otShapePlanKey) {
 	plan.hasFrac = plan.fracMask != 0 || (plan.numrMask != 0 && plan.dnomMask != 0)
 
 	plan.rtlmMask = plan.map_.getMask1(ot.NewTag('r', 't', 'l', 'm'))
-	plan.hasVert = plan.map_.getMask1(ot.NewTag('v', 'e', 'r', 't')) != 0
+	plan.hasVert = plan.map_.getMask1(planner.verticalAlternatesTag()) != 0
 
-	kernTag := ot.NewTag('v', 'k', 'r', 'n')
+	kernTag := tagVkrn
 	if planner.props.Direction.isHorizontal() {
-		kernTag = ot.NewTag('k', 'e', 'r', 'n')
+		kernTag = tagKern
 	}
 
 	plan.kernMask, _ = plan.map_.getMask(kernTag)
@@ -85,7 +98,7 @@ func (planner *otShapePlanner) compile(plan *otShapePlan, key otShapePlanKey) {
 	disableGpos := plan.shaper.gposTag() != 0 && plan.shaper.gposTag() != plan.map_.chosenScript[1]
 
 	// Decide who provides glyph classes. GDEF or Unicode.
-	if planner.tables.GDEF.GlyphClassDef == nil {
+	if !planner.capabilities.Has(CapGlyphClasses) {
 		plan.fallbackGlyphClasses = true
 	}
 
@@ -93,9 +106,9 @@ func (planner *otShapePlanner) compile(plan *otShapePlan, key otShapePlanKey) {
 	plan.applyMorx = planner.applyMorx
 
 	//  Decide who does positioning. GPOS, kerx, kern, or fallback.
-	hasKerx := planner.tables.Kerx != nil
-	hasGSUB := !plan.applyMorx && planner.tables.GSUB.Lookups != nil
-	hasGPOS := !disableGpos && planner.tables.GPOS.Lookups != nil
+	hasKerx := planner.capabilities.Has(CapKerx)
+	hasGSUB := !plan.applyMorx && planner.capabilities.Has(CapGSUB)
+	hasGPOS := !disableGpos && planner.capabilities.Has(CapGPOS)
 
 	if hasKerx && !(hasGSUB && hasGPOS) {
 		plan.applyKerx = true
@@ -107,22 +120,28 @@ func (planner *otShapePlanner) compile(plan *otShapePlan, key otShapePlanKey) {
 		// apparently Apple applies kerx if GPOS kern was not applied.
 		if hasKerx {
 			plan.applyKerx = true
-		} else if planner.tables.Kern != nil {
+		} else if planner.capabilities.Has(CapKern) {
 			plan.applyKern = true
 		}
 	}
 
-	plan.applyFallbackKern = !(plan.applyGpos || plan.applyKerx || plan.applyKern)
+	// the fallback kerning only uses the 'kern' table
+	plan.applyFallbackKern = !(plan.applyGpos || plan.applyKerx || plan.applyKern) && planner.capabilities.Has(CapKern)
 
 	plan.zeroMarks = planner.scriptZeroMarks && !plan.applyKerx &&
-		(!plan.applyKern || !hasMachineKerning(planner.tables.Kern))
+		(!plan.applyKern || !planner.capabilities.Has(capKernMachine))
 	plan.hasGposMark = plan.map_.getMask1(ot.NewTag('m', 'a', 'r', 'k')) != 0
 
 	plan.adjustMarkPositioningWhenZeroing = !plan.applyGpos && !plan.applyKerx &&
-		(!plan.applyKern || !hasCrossKerning(planner.tables.Kern))
+		(!plan.applyKern || !planner.capabilities.Has(capKernCrossStream))
 
 	plan.fallbackMarkPositioning = plan.adjustMarkPositioningWhenZeroing && planner.scriptFallbackMarkPositioning
 
+	// only mark and cursive lookups, 'kerx' and 'kern' state machines
+	// and cross-stream kerning (which chains all the glyphs) attach glyphs
+	plan.hasAttachments = (plan.applyGpos && planner.capabilities.Has(CapMarkPositioning)) || plan.applyKerx ||
+		(plan.applyKern && (planner.capabilities.Has(capKernMachine) || planner.capabilities.Has(capKernCrossStream)))
+
 	// If we're using morx shaping, we cancel mark position adjustment because
 	// Apple Color Emoji assumes this will NOT be done when forming emoji sequences;
 	// https://github.com/harfbuzz/harfbuzz/issues/2967.
@@ -131,7 +150,19 @@ func (planner *otShapePlanner) compile(plan *otShapePlan, key otShapePlanKey) {
 	}
 
 	// currently we always apply trak.
-	plan.applyTrak = plan.requestedTracking && !planner.tables.Trak.IsEmpty()
+	plan.applyTrak = plan.requestedTracking && planner.capabilities.Has(CapTrak)
+
+	if planner.syntheticCJKSpacing {
+		haltTag, paltTag := ot.NewTag('v', 'h', 'a', 'l'), ot.NewTag('v', 'p', 'a', 'l')
+		if planner.props.Direction.isHorizontal() {
+			haltTag, paltTag = ot.NewTag('h', 'a', 'l', 't'), ot.NewTag('p', 'a', 'l', 't')
+		}
+		plan.haltMask, _ = plan.map_.getMask(haltTag)
+		plan.paltMask, _ = plan.map_.getMask(paltTag)
+		plan.applyFallbackHalt = plan.haltMask != 0 && plan.map_.needsFallback(haltTag)
+		plan.applyFallbackPalt = plan.paltMask != 0 && plan.map_.needsFallback(paltTag)
+	}
+	return nil
 }
 
 type otShapePlan struct {
@@ -146,6 +177,8 @@ type otShapePlan struct {
 	rtlmMask GlyphMask
 	kernMask GlyphMask
 	trakMask GlyphMask
+	haltMask GlyphMask // 'halt' or 'vhal'
+	paltMask GlyphMask // 'palt' or 'vpal'
 
 	hasFrac                          bool
 	requestedTracking                bool
@@ -156,6 +189,7 @@ type otShapePlan struct {
 	fallbackGlyphClasses             bool
 	fallbackMarkPositioning          bool
 	adjustMarkPositioningWhenZeroing bool
+	hasAttachments                   bool // false if glyphs can't be attached to each other
 
 	applyGpos         bool
 	applyFallbackKern bool
@@ -163,16 +197,28 @@ type otShapePlan struct {
 	applyKerx         bool
 	applyMorx         bool
 	applyTrak         bool
+	applyFallbackHalt bool
+	applyFallbackPalt bool
 }
 
-func (sp *otShapePlan) init0(tables *font.Font, props SegmentProperties, userFeatures []Feature, otKey otShapePlanKey) {
-	planner := newOtShapePlanner(tables, props)
+// init0 compiles the plan, using [precompiled] as map if it is not nil.
+// An error is only returned for invalid precompiled maps.
+func (sp *otShapePlan) init0(tables *font.Font, capabilities Capabilities, props SegmentProperties, userFeatures []Feature,
+	otKey otShapePlanKey, options planOptions, precompiled *otMap,
+) error {
+	planner := newOtShapePlanner(tables, capabilities, props)
+	planner.map_.justification = options.jstfLevel
+	planner.syntheticCJKSpacing = options.syntheticCJKSpacing
+	planner.vertOnly = options.vertOnly
 
 	planner.collectFeatures(userFeatures)
 
-	planner.compile(sp, otKey)
+	if err := planner.compile(sp, otKey, precompiled); err != nil {
+		return err
+	}
 
 	sp.shaper.dataCreate(sp)
+	return nil
 }
 
 func (sp *otShapePlan) substitute(font *Font, buffer *Buffer) {
@@ -192,6 +238,10 @@ func (sp *otShapePlan) position(font *Font, buffer *Buffer) {
 		sp.otApplyFallbackKern(font, buffer)
 	}
 
+	if sp.applyFallbackHalt || sp.applyFallbackPalt {
+		sp.fallbackCJKSpacing(font, buffer)
+	}
+
 	if sp.applyTrak {
 		sp.aatLayoutTrack(font, buffer)
 	}
@@ -213,12 +263,60 @@ var (
 		{ot.NewTag('c', 'l', 'i', 'g'), ffGLOBAL},
 		{ot.NewTag('c', 'u', 'r', 's'), ffGLOBAL},
 		{ot.NewTag('d', 'i', 's', 't'), ffGLOBAL},
-		{ot.NewTag('k', 'e', 'r', 'n'), ffGlobalHasFallback},
+		{tagKern, ffGlobalHasFallback},
 		{ot.NewTag('l', 'i', 'g', 'a'), ffGLOBAL},
 		{ot.NewTag('r', 'c', 'l', 't'), ffGLOBAL},
 	}
 )
 
+var (
+	tagVkrn = ot.NewTag('v', 'k', 'r', 'n')
+	tagVert = ot.NewTag('v', 'e', 'r', 't')
+	tagVrt2 = ot.NewTag('v', 'r', 't', '2')
+)
+
+// verticalAlternatesTag returns the feature providing the vertical alternates :
+// 'vrt2' if the font has it, since it supersedes 'vert' (see the OpenType
+// feature registry), or 'vert'. Only one of them is applied, since 'vert'
+// would be applied to glyphs already substituted by 'vrt2'.
+// AAT fonts always use 'vert', which is mapped to the vertical substitution
+// feature of the 'morx' table.
+func (planner *otShapePlanner) verticalAlternatesTag() ot.Tag {
+	if planner.vertOnly || planner.applyMorx {
+		return tagVert
+	}
+	if _, hasVrt2 := planner.tables.GSUB.FindFeatureIndex(tagVrt2); hasVrt2 {
+		return tagVrt2
+	}
+	return tagVert
+}
+
+// verticalSpacingFeatures are the features adjusting the vertical advances of
+// CJK glyphs, by order of priority. They are mutually exclusive, so that,
+// in vertical text, when several of them are requested for the whole text,
+// only the one with the highest priority is applied.
+var verticalSpacingFeatures = [...]ot.Tag{
+	ot.NewTag('v', 'p', 'a', 'l'), // proportional metrics
+	ot.NewTag('v', 'h', 'a', 'l'), // half-width metrics
+	ot.NewTag('v', 'c', 'h', 'w'), // contextual half-width spacing
+}
+
+// isVerticalSpacingDisabled returns true if [tag] is a vertical spacing feature
+// superseded by a feature with higher priority requested in [userFeatures].
+func isVerticalSpacingDisabled(tag ot.Tag, userFeatures []Feature) bool {
+	for _, higher := range verticalSpacingFeatures {
+		if higher == tag {
+			return false
+		}
+		for _, f := range userFeatures {
+			if f.Tag == higher && f.Value != 0 && f.Start == FeatureGlobalStart && f.End == FeatureGlobalEnd {
+				return true
+			}
+		}
+	}
+	return false // not a vertical spacing feature
+}
+
 func (planner *otShapePlanner) collectFeatures(userFeatures []Feature) {
 	map_ := &planner.map_
 
@@ -261,6 +359,9 @@ func (planner *otShapePlanner) collectFeatures(userFeatures []Feature) {
 
 	if planner.props.Direction.isHorizontal() {
 		for _, feat := range horizontalFeatures {
+			if feat.tag == tagKern && !planner.capabilities.Has(CapKerning) {
+				continue // no kerning data : the feature can't have an effect
+			}
 			map_.addFeatureExt(feat.tag, feat.flags, 1)
 		}
 	} else {
@@ -268,15 +369,32 @@ func (planner *otShapePlanner) collectFeatures(userFeatures []Feature) {
 		 * matter which script/langsys it is listed (or not) under.
 		 * See various bugs referenced from:
 		 * https://github.com/harfbuzz/harfbuzz/issues/63 */
-		map_.enableFeatureExt(ot.NewTag('v', 'e', 'r', 't'), ffGlobalSearch, 1)
+		map_.enableFeatureExt(planner.verticalAlternatesTag(), ffGlobalSearch, 1)
+		// as in HarfBuzz, 'vkrn' is not enabled by default, except for the
+		// fonts with vertical 'kern' subtables, which are only applied with it.
+		// Fonts with a GPOS 'vkrn' feature don't use the 'kern' table, and
+		// its lookups must not be applied by default.
+		if planner.capabilities.Has(capKernVertical) && !planner.capabilities.HasFeature(tagVkrn) {
+			map_.enableFeatureExt(tagVkrn, ffGlobalHasFallback, 1)
+		}
 	}
 
 	for _, f := range userFeatures {
+		if f.Value != 0 && planner.props.Direction.isVertical() && isVerticalSpacingDisabled(f.Tag, userFeatures) {
+			continue
+		}
 		ftag := ffNone
 		if f.Start == FeatureGlobalStart && f.End == FeatureGlobalEnd {
 			ftag = ffGLOBAL
 		}
+		if planner.syntheticCJKSpacing && isCJKSpacingFeature(f.Tag) {
+			ftag |= ffHasFallback
+		}
 		map_.addFeatureExt(f.Tag, ftag, f.Value)
+		// the settings of 'vert' also apply to its replacement
+		if vertTag := planner.verticalAlternatesTag(); f.Tag == tagVert && vertTag != tagVert && planner.props.Direction.isVertical() {
+			map_.addFeatureExt(vertTag, ftag, f.Value)
+		}
 	}
 
 	planner.shaper.overrideFeatures(planner)
@@ -521,6 +639,17 @@ func hideDefaultIgnorables(buffer *Buffer, font *Font) {
 // use unicodeProp to assign a class
 func synthesizeGlyphClasses(buffer *Buffer) {
 	info := buffer.Info
+	if isMark := buffer.MarkClassifier; isMark != nil {
+		for i := range info {
+			class := tables.GPBaseGlyph
+			if isMark(info[i].codepoint, info[i].Glyph) {
+				class = tables.GPMark
+			}
+			info[i].glyphProps = class
+		}
+		return
+	}
+
 	for i := range info {
 		/* Never mark default-ignorables as marks.
 		 * They won't get in the way of lookups anyway,
@@ -550,7 +679,7 @@ func (c *otContext) substituteBeforePosition() {
 	c.setupMasks()
 
 	// this is unfortunate to go here, but necessary...
-	if c.plan.fallbackMarkPositioning {
+	if c.plan.fallbackMarkPositioning || buffer.Flags&FallbackMarkPositioning != 0 {
 		fallbackMarkPositionRecategorizeMarks(buffer)
 	}
 
@@ -656,7 +785,9 @@ func (c *otContext) positionComplex() {
 		pos[i].XOffset, pos[i].YOffset = c.font.addGlyphHOrigin(inf.Glyph, pos[i].XOffset, pos[i].YOffset)
 	}
 
-	otLayoutPositionStart(c.font, c.buffer)
+	if c.plan.hasAttachments {
+		otLayoutPositionStart(c.font, c.buffer)
+	}
 	markBehavior, _ := c.plan.shaper.marksBehavior()
 
 	if c.plan.zeroMarks {
@@ -678,15 +809,29 @@ func (c *otContext) positionComplex() {
 	if c.plan.applyMorx {
 		aatLayoutZeroWidthDeletedGlyphs(c.buffer)
 	}
-	otLayoutPositionFinishOffsets(c.font, c.buffer)
+	// the attachments are resolved by otLayoutPositionFinishOffsets:
+	// record them to only position the other marks
+	forceFallbackMarks := !c.plan.fallbackMarkPositioning && c.buffer.Flags&FallbackMarkPositioning != 0
+	var attached []bool
+	if forceFallbackMarks && c.plan.hasAttachments {
+		attached = attachedGlyphs(c.buffer)
+	}
+
+	if c.plan.hasAttachments {
+		otLayoutPositionFinishOffsets(c.font, c.buffer)
+	}
 
 	for i, inf := range info {
 		pos[i].XOffset, pos[i].YOffset = c.font.subtractGlyphHOrigin(inf.Glyph, pos[i].XOffset, pos[i].YOffset)
 	}
 
 	if c.plan.fallbackMarkPositioning {
-		fallbackMarkPosition(c.plan, c.font, c.buffer, adjustOffsetsWhenZeroing)
+		fallbackMarkPosition(c.plan, c.font, c.buffer, adjustOffsetsWhenZeroing, nil)
+	} else if forceFallbackMarks {
+		fallbackMarkPosition(c.plan, c.font, c.buffer, adjustOffsetsWhenZeroing, attached)
 	}
+
+	c.font.slantOffsets(pos)
 }
 
 func (c *otContext) position() {
@@ -756,24 +901,33 @@ func propagateFlags(buffer *Buffer) {
 // shaperOpentype is the main shaper of this library.
 // It handles complex language and Opentype layout features found in fonts.
 type shaperOpentype struct {
-	tables *font.Font
-	plan   otShapePlan
-	key    otShapePlanKey
+	tables       *font.Font
+	capabilities Capabilities
+	plan         otShapePlan
+	key          otShapePlanKey
+	options      planOptions
 }
 
 type otShapePlanKey = [2]int // -1 for not found
 
-func (sp *shaperOpentype) init(tables *font.Font, coords []tables.Coord) {
+func (sp *shaperOpentype) init(tables *font.Font, capabilities Capabilities, coords []tables.Coord, options planOptions) {
 	sp.plan = otShapePlan{}
+	sp.options = options
 	sp.key = otShapePlanKey{
 		0: tables.GSUB.FindVariationIndex(coords),
 		1: tables.GPOS.FindVariationIndex(coords),
 	}
 	sp.tables = tables
+	sp.capabilities = capabilities
 }
 
 func (sp *shaperOpentype) compile(props SegmentProperties, userFeatures []Feature) {
-	sp.plan.init0(sp.tables, props, userFeatures, sp.key)
+	sp.plan.init0(sp.tables, sp.capabilities, props, userFeatures, sp.key, sp.options, nil)
+}
+
+// compileFrom is the same as compile, but uses a precompiled map.
+func (sp *shaperOpentype) compileFrom(props SegmentProperties, userFeatures []Feature, precompiled *otMap) error {
+	return sp.plan.init0(sp.tables, sp.capabilities, props, userFeatures, sp.key, sp.options, precompiled)
 }
 
 // pull it all together!
@@ -183,6 +183,18 @@ func parsePrivateUseSubtag(privateUseSubtag string, prefix string, normalize fun
 	return out, true
 }
 
+// OTTagsFromScriptAndLanguage converts a `Script` and a `Language`
+// to the OpenType script and language system tags, in order of preference,
+// as used to select the features of a font (see hb_ot_tags_from_script_and_language).
+//
+// The language is interpreted as a BCP 47 tag : when it has no direct match,
+// its canonical form is used (see [language.Language.Canonicalize]).
+// Several language tags may be returned, like [ZHH, ZHT] for "zh-HK"; an empty
+// list means that the default language system should be used.
+func OTTagsFromScriptAndLanguage(script language.Script, lang language.Language) (scriptTags, languageTags []tables.Tag) {
+	return newOTTagsFromScriptAndLanguage(script, lang)
+}
+
 // newOTTagsFromScriptAndLanguage converts a `Script` and a `Language`
 // to script and language tags.
 func newOTTagsFromScriptAndLanguage(script language.Script, language language.Language) (scriptTags, languageTags []tables.Tag) {
@@ -201,7 +213,12 @@ func newOTTagsFromScriptAndLanguage(script language.Script, language language.La
 			if prefix == "" { // if the language is 'fully private'
 				prefix = language
 			}
-			languageTags = otTagsFromLanguage(string(prefix)) // TODO:
+			languageTags = otTagsFromLanguage(string(prefix))
+			// try again with the canonical form, to support
+			// grandfathered and deprecated tags, like "i-klingon"
+			if canonical := prefix.Canonicalize(); len(languageTags) == 0 && canonical != prefix {
+				languageTags = otTagsFromLanguage(string(canonical))
+			}
 		}
 	}
 
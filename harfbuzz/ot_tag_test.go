@@ -424,6 +424,22 @@ func TestOtTagFull(t *testing.T) {
 	testTags(t, 0, "xyz", 0, 1, "XYZ ")
 }
 
+func TestOtTagCanonical(t *testing.T) {
+	// no direct match, use the canonical form
+	testTagFromLanguage(t, "TLH ", "i-klingon")
+	testTagFromLanguage(t, "TAO ", "i-tao")
+	testTagFromLanguage(t, "TSU ", "i-tsu")
+	testTagFromLanguage(t, "dflt", "i-default")
+
+	// the direct matches are preferred
+	testTags(t, 0, "mo", 0, 2, "MOL ", "ROM ")
+	testTags(t, 0, "iw-u-nu-hebr", 0, 1, "IWR ")
+
+	scriptTags, languageTags := OTTagsFromScriptAndLanguage(language.Latin, "no-nyn")
+	assertEqualTag(t, scriptTags[0], ot.MustNewTag("latn"))
+	assertEqualTag(t, languageTags[0], ot.MustNewTag("NYN "))
+}
+
 func TestOtTagFromLanguage(t *testing.T) {
 	scs, _ := newOTTagsFromScriptAndLanguage(language.Tai_Tham, "")
 	if len(scs) != 1 && scs[0] != 1818324577 {
@@ -1,9 +1,74 @@
 package harfbuzz
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
+)
 
 func TestUSE(t *testing.T) {
 	if !(joiningFormInit < 4 && joiningFormIsol < 4 && joiningFormMedi < 4 && joiningFormFina < 4) {
 		t.Error()
 	}
 }
+
+func TestUSEScripts(t *testing.T) {
+	for _, script := range []language.Script{
+		language.Brahmi, language.Yezidi,
+		// Unicode 14
+		language.Cypro_Minoan, language.Old_Uyghur, language.Tangsa, language.Toto, language.Vithkuqi,
+		// Unicode 15
+		language.Kawi, language.Nag_Mundari,
+	} {
+		var planner otShapePlanner
+		planner.props.Script = script
+		planner.map_.chosenScript[0] = ot.Tag(script)
+		_, isUSE := planner.categorizeComplex().(*complexShaperUSE)
+		tu.AssertC(t, isUSE, script.String())
+	}
+}
+
+// the expected categories are derived from the Indic_Syllabic_Category and
+// Indic_Positional_Category properties of the Unicode 15 data
+func TestUSECategories(t *testing.T) {
+	for _, test := range []struct {
+		r        rune
+		expected uint8
+	}{
+		{0x11F00, useSM_ex_VMAbv}, // Kawi sign candrabindu : Bindu, Top
+		{0x11F02, useSM_ex_R},     // Kawi sign repha : Consonant_Preceding_Repha
+		{0x11F03, useSM_ex_VMPst}, // Kawi sign visarga : Visarga, Right
+		{0x11F12, useSM_ex_B},     // Kawi letter ka : Consonant
+		{0x11F34, useSM_ex_VPst},  // Kawi vowel sign aa : Vowel_Dependent, Right
+		{0x11F3E, useSM_ex_VPre},  // Kawi vowel sign e : Vowel_Dependent, Left
+		{0x11F42, useSM_ex_IS},    // Kawi conjoiner : Invisible_Stacker
+		{0x11F50, useSM_ex_B},     // Kawi digit zero : Number
+		{0x1E4D0, useSM_ex_B},     // Nag Mundari letter o
+		{0x1E4F0, useSM_ex_B},     // Nag Mundari digit zero
+	} {
+		tu.AssertC(t, getUSECategory(test.r) == test.expected, fmt.Sprintf("%U: %d", test.r, getUSECategory(test.r)))
+	}
+}
+
+func TestUSESyllables(t *testing.T) {
+	for _, text := range [][]rune{
+		{0x11013, 0x11046, 0x11013, 0x1103E}, // Brahmi : B H B VPst
+		{0x11F12, 0x11F42, 0x11F13, 0x11F34}, // Kawi : B IS B VPst
+		{0x11F02, 0x11F12, 0x11F3E},          // Kawi : R B VPre
+		{0x1E4D0, 0x1E4EC},                   // Nag Mundari : B VAbv
+	} {
+		buf := NewBuffer()
+		buf.AddRunes(text, 0, -1)
+		for i := range buf.Info {
+			buf.Info[i].complexCategory = getUSECategory(buf.Info[i].codepoint)
+		}
+		findSyllablesUse(buf)
+		for _, info := range buf.Info {
+			tu.AssertC(t, info.syllable == buf.Info[0].syllable, fmt.Sprintf("%U", text))
+			tu.AssertC(t, info.syllable&0x0F == useStandardCluster, fmt.Sprintf("%U", text))
+		}
+	}
+}
@@ -6,11 +6,11 @@ import (
 
 // ported from src/hb-set-digest.hh Copyright © 2012  Google, Inc. Behdad Esfahbod
 
-const maskBits = 4 * 8 // 4 = size(setDigestLowestBits)
+const maskBits = 8 * 8 // 8 = size(setBits)
 
 type setType = gID
 
-type setBits uint32
+type setBits uint64
 
 func maskFor(g setType, shift uint) setBits {
 	return 1 << ((g >> shift) & (maskBits - 1))
@@ -46,13 +46,70 @@ func (sd setBits) mayHaveSet(g setBits) bool {
 	return sd&g != 0
 }
 
+// DigestStrategy selects the filters used to quickly skip the lookups
+// which can't apply to the glyphs of the buffer (see [Font.SetDigestStrategy]).
+// The strategy only impacts performance, not the shaping results.
+type DigestStrategy uint8
+
+const (
+	// DigestAccurate completes the three bit patterns used by HarfBuzz with a coarser
+	// pattern and a filter indexed by a hash of the glyphs.
+	// It is the default, and is well suited to fonts with many lookups, such
+	// as Arabic, Indic or CJK fonts.
+	DigestAccurate DigestStrategy = iota
+	// DigestPatterns only uses the three bit patterns of HarfBuzz,
+	// which are smaller and quicker to build, and may be enough
+	// for fonts with few lookups.
+	DigestPatterns
+)
+
+// hashSize is the number of bits of [hashBits]
+const hashSize = 4 * 64
+
+// hashBits is a filter indexed by a multiplicative hash of the glyph.
+// Contrary to the [setBits] patterns, adjacent glyphs are spread
+// over the whole filter, so that it stays accurate for sets made of
+// small ranges scattered over many pages.
+type hashBits [hashSize / 64]uint64
+
+func hashOf(g setType) uint32 {
+	return (uint32(g) * 2654435761) >> (32 - 8) // 8 = log2(hashSize)
+}
+
+func (sd *hashBits) add(g setType) {
+	h := hashOf(g)
+	sd[h/64] |= 1 << (h % 64)
+}
+
+func (sd *hashBits) addRange(a, b setType) {
+	if b-a >= hashSize-1 {
+		*sd = hashBits{^uint64(0), ^uint64(0), ^uint64(0), ^uint64(0)}
+		return
+	}
+	for g := uint32(a); g <= uint32(b); g++ {
+		sd.add(setType(g))
+	}
+}
+
+func (sd *hashBits) mayHave(g setType) bool {
+	h := hashOf(g)
+	return sd[h/64]&(1<<(h%64)) != 0
+}
+
+func (sd *hashBits) mayHaveSet(o *hashBits) bool {
+	return sd[0]&o[0] != 0 || sd[1]&o[1] != 0 || sd[2]&o[2] != 0 || sd[3]&o[3] != 0
+}
+
 /* This is a combination of digests that performs "best".
  * There is not much science to this: it's a result of intuition
- * and testing. */
+ * and testing.
+ * The coarser pattern (pages of 1024 glyphs) helps for large fonts
+ * (such as CJK fonts), whose lookups cover many adjacent ranges. */
 const (
 	shift0 = 4
 	shift1 = 0
-	shift2 = 9
+	shift2 = 7
+	shift3 = 10
 )
 
 // setDigest implement various "filters" that support
@@ -68,41 +125,77 @@ const (
 // The frozen-set can be used instead of a digest, to trade more
 // memory for 100% accuracy, but in practice, that doesn't look like
 // an attractive trade-off.
-type setDigest [3]setBits
+//
+// Compared to HarfBuzz, a fourth bit pattern and a filter indexed by a hash
+// of the glyphs are added, which noticeably reduce the false positives for fonts
+// with many lookups, such as Arabic, Indic or CJK fonts (see BenchmarkDigestFalsePositives).
+// They are not used with the [DigestPatterns] strategy.
+type setDigest struct {
+	bits [4]setBits
+	hash hashBits
+
+	patternsOnly bool // only use bits[0:3], see [DigestPatterns]
+}
 
 // add adds the given rune to the set.
 func (sd *setDigest) add(g setType) {
-	sd[0].add(g, shift0)
-	sd[1].add(g, shift1)
-	sd[2].add(g, shift2)
+	sd.bits[0].add(g, shift0)
+	sd.bits[1].add(g, shift1)
+	sd.bits[2].add(g, shift2)
+	if sd.patternsOnly {
+		return
+	}
+	sd.bits[3].add(g, shift3)
+	sd.hash.add(g)
 }
 
 // addRange adds the given, inclusive range to the set,
 // in an efficient manner.
 func (sd *setDigest) addRange(a, b setType) {
-	sd[0].addRange(a, b, shift0)
-	sd[1].addRange(a, b, shift1)
-	sd[2].addRange(a, b, shift2)
+	sd.bits[0].addRange(a, b, shift0)
+	sd.bits[1].addRange(a, b, shift1)
+	sd.bits[2].addRange(a, b, shift2)
+	if sd.patternsOnly {
+		return
+	}
+	sd.bits[3].addRange(a, b, shift3)
+	sd.hash.addRange(a, b)
 }
 
 // addArray is a convenience method to add
 // many runes.
 func (sd *setDigest) addArray(arr []setType) {
-	sd[0].addArray(arr, shift0)
-	sd[1].addArray(arr, shift1)
-	sd[2].addArray(arr, shift2)
+	sd.bits[0].addArray(arr, shift0)
+	sd.bits[1].addArray(arr, shift1)
+	sd.bits[2].addArray(arr, shift2)
+	if sd.patternsOnly {
+		return
+	}
+	sd.bits[3].addArray(arr, shift3)
+	for _, g := range arr {
+		sd.hash.add(g)
+	}
 }
 
 // mayHave performs an "approximate member query": if the return value
 // is `false`, then it is certain that `g` is not in the set.
 // Otherwise, we don't kwow, it might be a false positive.
 // Note that runes in the set are certain to return `true`.
-func (sd setDigest) mayHave(g setType) bool {
-	return sd[0].mayHave(g, shift0) && sd[1].mayHave(g, shift1) && sd[2].mayHave(g, shift2)
+func (sd *setDigest) mayHave(g setType) bool {
+	if !(sd.bits[0].mayHave(g, shift0) && sd.bits[1].mayHave(g, shift1) && sd.bits[2].mayHave(g, shift2)) {
+		return false
+	}
+	return sd.patternsOnly || (sd.bits[3].mayHave(g, shift3) && sd.hash.mayHave(g))
 }
 
-func (sd setDigest) mayHaveDigest(o setDigest) bool {
-	return sd[0].mayHaveSet(o[0]) && sd[1].mayHaveSet(o[1]) && sd[2].mayHaveSet(o[2])
+// mayHaveDigest returns false if the sets certainly have no common element.
+// Only the filters used by [sd] are checked, so that [o] must be a full digest,
+// like the one of the buffer.
+func (sd *setDigest) mayHaveDigest(o *setDigest) bool {
+	if !(sd.bits[0].mayHaveSet(o.bits[0]) && sd.bits[1].mayHaveSet(o.bits[1]) && sd.bits[2].mayHaveSet(o.bits[2])) {
+		return false
+	}
+	return sd.patternsOnly || (sd.bits[3].mayHaveSet(o.bits[3]) && sd.hash.mayHaveSet(&o.hash))
 }
 
 func (sd *setDigest) collectCoverage(cov tables.Coverage) {
@@ -115,3 +208,15 @@ func (sd *setDigest) collectCoverage(cov tables.Coverage) {
 		}
 	}
 }
+
+// union adds the elements of [o] to the set.
+func (sd *setDigest) union(o *setDigest) {
+	// the filters not used by [o] are empty
+	sd.patternsOnly = sd.patternsOnly || o.patternsOnly
+	for i := range sd.bits {
+		sd.bits[i] |= o.bits[i]
+	}
+	for i := range sd.hash {
+		sd.hash[i] |= o.hash[i]
+	}
+}
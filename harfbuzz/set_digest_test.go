@@ -1,11 +1,19 @@
 package harfbuzz
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
+	tu "github.com/boxesandglue/typesetting/testutils"
+)
 
 func TestDigest(t *testing.T) {
 	const (
 		setTypeSize = 2
-		numBits     = 3 + 1 + 1
+		numBits     = 3 + 1 + 1 + 1 // log2(maskBits)
 	)
 	if shift0 >= setTypeSize*8 {
 		t.Error()
@@ -25,6 +33,12 @@ func TestDigest(t *testing.T) {
 	if shift2+numBits > setTypeSize*8 {
 		t.Error()
 	}
+	if shift3 >= setTypeSize*8 {
+		t.Error()
+	}
+	if shift3+numBits > setTypeSize*8 {
+		t.Error()
+	}
 }
 
 func TestDigestHas(t *testing.T) {
@@ -46,3 +60,222 @@ func TestDigestHas(t *testing.T) {
 		}
 	}
 }
+
+func TestDigestRange(t *testing.T) {
+	for _, r := range [][2]setType{{0, 0}, {10, 20}, {100, 400}, {1000, 1300}, {0xFF00, 0xFFFF}} {
+		var d setDigest
+		d.addRange(r[0], r[1])
+		for g := int(r[0]); g <= int(r[1]); g++ {
+			if !d.mayHave(setType(g)) {
+				t.Errorf("expected <may have> for %d in range %v", g, r)
+			}
+		}
+		if r[1]-r[0] < 100 && d.mayHave(r[1]+1000) {
+			t.Errorf("unexpected <may have> for %d", r[1]+1000)
+		}
+
+		var single setDigest
+		single.add(r[1])
+		if !d.mayHaveDigest(&single) || !single.mayHaveDigest(&d) {
+			t.Errorf("expected <may have> for digests with glyph %d", r[1])
+		}
+	}
+
+	var d1, d2 setDigest
+	d1.addArray([]setType{1, 2, 3})
+	d2.addArray([]setType{1000, 2000})
+	if d1.mayHaveDigest(&d2) {
+		t.Error("unexpected <may have> for disjoint digests")
+	}
+}
+
+func TestDigestStrategy(t *testing.T) {
+	// two digests only distinguished by the coarser pattern
+	full := ^setBits(0)
+	allHash := hashBits{^uint64(0), ^uint64(0), ^uint64(0), ^uint64(0)}
+	d1 := setDigest{bits: [4]setBits{full, full, full, 1}, hash: allHash}
+	d2 := setDigest{bits: [4]setBits{full, full, full, 2}, hash: allHash}
+	tu.Assert(t, !d1.mayHaveDigest(&d2))
+	d1.patternsOnly = true
+	tu.Assert(t, d1.mayHaveDigest(&d2))
+
+	// a digest using only the patterns is a superset of the full one
+	var accurate, patterns setDigest
+	patterns.patternsOnly = true
+	for _, d := range []*setDigest{&accurate, &patterns} {
+		d.addArray([]setType{3, 700, 1500})
+		d.addRange(4000, 4010)
+	}
+	for g := setType(0); g < 0xFFFF; g++ {
+		tu.Assert(t, !accurate.mayHave(g) || patterns.mayHave(g))
+	}
+	var union setDigest
+	union.union(&patterns)
+	tu.Assert(t, union.mayHave(1500) && union.mayHave(4005))
+
+	// the strategy does not change the shaping results
+	ft := openFontFileTT(t, "common/NotoSansArabic.ttf")
+	shape := func(strategy DigestStrategy) *Buffer {
+		hbFont := NewFont(font.NewFace(ft))
+		hbFont.SetDigestStrategy(strategy)
+		tu.Assert(t, hbFont.DigestStrategy() == strategy)
+		buf := NewBuffer()
+		buf.AddRunes([]rune("الله أكبر، بِسْمِ ٱللَّٰهِ"), 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(hbFont, nil)
+		return buf
+	}
+	b1, b2 := shape(DigestAccurate), shape(DigestPatterns)
+	tu.Assert(t, reflect.DeepEqual(b1.Info, b2.Info) && reflect.DeepEqual(b1.Pos, b2.Pos))
+}
+
+func TestFeatureDigest(t *testing.T) {
+	ft := openFontFileTT(t, "common/Raleway-v4020-Regular.otf")
+	hbFont := NewFont(font.NewFace(ft))
+
+	smcp, c2sc := ot.MustNewTag("smcp"), ot.MustNewTag("c2sc")
+	digest := hbFont.FeatureDigest(smcp)
+	a, _ := ft.NominalGlyph('a')
+	tu.Assert(t, digest.MayHave(a))
+	digest = hbFont.FeatureDigest(ot.MustNewTag("zzzz"))
+	tu.Assert(t, !digest.MayHave(a))
+
+	shape := func(text string, feature ot.Tag, value uint32) *Buffer {
+		buf := NewBuffer()
+		buf.AddRunes([]rune(text), 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(hbFont, []Feature{{Tag: feature, Value: value, Start: 0, End: FeatureGlobalEnd}})
+		return buf
+	}
+	for _, text := range []string{"abc", "ABC", "123", " .,;", "fi ff"} {
+		for _, feature := range []ot.Tag{smcp, c2sc, ot.MustNewTag("onum"), ot.MustNewTag("dlig")} {
+			buf := NewBuffer()
+			buf.AddRunes([]rune(text), 0, -1)
+			if buf.MayApplyFeature(hbFont, feature) {
+				continue
+			}
+			// no false negatives
+			with, without := shape(text, feature, 1), shape(text, feature, 0)
+			tu.Assert(t, reflect.DeepEqual(with.Info, without.Info) && reflect.DeepEqual(with.Pos, without.Pos))
+		}
+	}
+
+	buf := NewBuffer()
+	buf.AddRunes([]rune("abc"), 0, -1)
+	tu.Assert(t, buf.MayApplyFeature(hbFont, smcp))
+	tu.Assert(t, !buf.MayApplyFeature(hbFont, c2sc))
+	buf.Clear()
+	buf.AddRunes([]rune("ABC"), 0, -1)
+	tu.Assert(t, !buf.MayApplyFeature(hbFont, smcp))
+	tu.Assert(t, buf.MayApplyFeature(hbFont, c2sc))
+}
+
+// referenceDigest is the digest used by HarfBuzz, with 32-bit masks,
+// used for comparison.
+type referenceDigest [3]uint32
+
+var referenceShifts = [3]uint{4, 0, 9}
+
+func (rd *referenceDigest) collectCoverage(cov tables.Coverage) {
+	for _, r := range cov.RangeRecords() {
+		for i, shift := range referenceShifts {
+			if (r.EndGlyphID>>shift)-(r.StartGlyphID>>shift) >= 31 {
+				rd[i] = ^uint32(0)
+				continue
+			}
+			for page := r.StartGlyphID >> shift; page <= r.EndGlyphID>>shift; page++ {
+				rd[i] |= 1 << (page & 31)
+			}
+		}
+	}
+}
+
+func (rd *referenceDigest) mayHave(g setType) bool {
+	for i, shift := range referenceShifts {
+		if rd[i]&(1<<((g>>shift)&31)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// BenchmarkDigestFalsePositives reports the false positive rate of the lookup digests,
+// computed on the glyphs not covered by the lookup (up to the last glyph covered by the font lookups),
+// compared to the HarfBuzz digest.
+func BenchmarkDigestFalsePositives(b *testing.B) {
+	for _, file := range []string{
+		"perf_reference/fonts/Amiri-Regular.ttf",
+		"perf_reference/fonts/NotoNastaliqUrdu-Regular.ttf",
+		"perf_reference/fonts/NotoSansDevanagari-Regular.ttf",
+		"perf_reference/fonts/Roboto-Regular.ttf",
+		"common/NotoSansCJKjp-VF.otf",
+	} {
+		var ft *font.Font
+		if file == "common/NotoSansCJKjp-VF.otf" {
+			ft = openFontFileTT(b, file)
+		} else {
+			ft = openFontFile(b, file)
+		}
+		var lookups [][]tables.Coverage
+		for _, lookup := range ft.GSUB.Lookups {
+			var covs []tables.Coverage
+			for _, subtable := range lookup.Subtables {
+				covs = append(covs, subtable.Cov())
+			}
+			lookups = append(lookups, covs)
+		}
+		for _, lookup := range ft.GPOS.Lookups {
+			var covs []tables.Coverage
+			for _, subtable := range lookup.Subtables {
+				covs = append(covs, subtable.Cov())
+			}
+			lookups = append(lookups, covs)
+		}
+
+		numGlyphs := 0
+		for _, covs := range lookups {
+			for _, cov := range covs {
+				if rs := cov.RangeRecords(); len(rs) != 0 && int(rs[len(rs)-1].EndGlyphID) >= numGlyphs {
+					numGlyphs = int(rs[len(rs)-1].EndGlyphID) + 1
+				}
+			}
+		}
+
+		b.Run(file, func(b *testing.B) {
+			var negatives, positives, refPositives int
+			for i := 0; i < b.N; i++ {
+				negatives, positives, refPositives = 0, 0, 0
+				for _, covs := range lookups {
+					var (
+						d   setDigest
+						ref referenceDigest
+					)
+					for _, cov := range covs {
+						d.collectCoverage(cov)
+						ref.collectCoverage(cov)
+					}
+					for g := 0; g < numGlyphs; g++ {
+						covered := false
+						for _, cov := range covs {
+							if _, covered = cov.Index(gID(g)); covered {
+								break
+							}
+						}
+						if covered {
+							continue
+						}
+						negatives++
+						if d.mayHave(setType(g)) {
+							positives++
+						}
+						if ref.mayHave(setType(g)) {
+							refPositives++
+						}
+					}
+				}
+			}
+			b.ReportMetric(100*float64(positives)/float64(negatives), "%false-positives")
+			b.ReportMetric(100*float64(refPositives)/float64(negatives), "%false-positives(harfbuzz)")
+		})
+	}
+}
@@ -19,15 +19,30 @@ import (
 // positioned glyphs. If `features` is not empty, it will be used to control the
 // features applied during shaping. If two features have the same tag but
 // overlapping ranges the value of the feature with the higher index takes
-// precedence.
+// precedence. In vertical text, the mutually exclusive 'vpal', 'vhal' and 'vchw'
+// features are applied by order of priority : when several of them are enabled
+// for the whole text, only the first one (in this order) is applied.
 //
 // The shapping plan depends on the font capabilities. See `NewFont` and `Face` and
 // its extension interfaces for more details.
 //
 // It also depends on the properties of the segment of text : the `Props`
-// field of the buffer must be set before calling `Shape`.
+// field of the buffer should be set before calling `Shape`. If the script or the
+// direction are not set, they are guessed from the buffer content, as
+// done by [Buffer.GuessSegmentProperties], and `Props` is updated.
+//
+// For variable fonts with an 'opsz' axis, the optical size is first selected
+// from [Font.Ptem], unless disabled by [Font.SetOpticalSizing].
+//
+// A [Buffer] (and its [Font], whose [Face] stores caches) must not be used by several
+// goroutines at the same time. Distinct buffers and fonts may be shaped concurrently,
+// even if their faces share the same parsed font.
 func (b *Buffer) Shape(font *Font, features []Feature) {
-	shapePlan := b.newShapePlanCached(font, b.Props, features, font.varCoords())
+	if b.Props.Script == 0 || !b.Props.Direction.isValid() {
+		b.guessScriptAndDirection()
+	}
+	font.applyOpticalSize()
+	shapePlan := b.newShapePlanCached(font, b.Props, features, font.varCoords(), b.planOptions())
 	shapePlan.execute(font, b, features)
 }
 
@@ -42,16 +57,38 @@ func (b *Buffer) Shape(font *Font, features []Feature) {
 // etc.).
 //
 // Most client programs will not need to deal with shape plans directly.
+//
+// Once compiled, a plan is not modified by [shapePlan.execute] (the state
+// required when applying the lookups is stored in a per call [otApplyContext]), so
+// that the same plan may be executed concurrently, with distinct buffers and fonts.
 type shapePlan struct {
 	shaper       shaperOpentype
 	props        SegmentProperties
 	userFeatures []Feature
+	options      planOptions
+}
+
+// planOptions are the settings of the buffer, other than
+// its properties, which are resolved when compiling the plan.
+type planOptions struct {
+	jstfLevel           int  // see [Buffer.Justification]
+	syntheticCJKSpacing bool // see [SyntheticCJKSpacing]
+	vertOnly            bool // see [VerticalAlternatesVertOnly]
+}
+
+func (b *Buffer) planOptions() planOptions {
+	return planOptions{
+		jstfLevel:           b.Justification,
+		syntheticCJKSpacing: b.Flags&SyntheticCJKSpacing != 0,
+		vertOnly:            b.Flags&VerticalAlternatesVertOnly != 0,
+	}
 }
 
 func (plan *shapePlan) init(copy bool, font *Font, props SegmentProperties,
-	userFeatures []Feature, coords []tables.Coord,
+	userFeatures []Feature, coords []tables.Coord, options planOptions,
 ) {
 	plan.props = props
+	plan.options = options
 	if !copy {
 		plan.userFeatures = userFeatures
 	} else {
@@ -68,7 +105,7 @@ func (plan *shapePlan) init(copy bool, font *Font, props SegmentProperties,
 	}
 
 	// init shaper
-	plan.shaper.init(font.face.Font, coords)
+	plan.shaper.init(font.face.Font, font.capabilities, coords, options)
 }
 
 func (plan shapePlan) userFeaturesMatch(other shapePlan) bool {
@@ -86,14 +123,15 @@ func (plan shapePlan) userFeaturesMatch(other shapePlan) bool {
 }
 
 func (plan shapePlan) equal(other shapePlan) bool {
-	return plan.props == other.props && plan.userFeaturesMatch(other)
+	return plan.props == other.props && plan.options == other.options &&
+		plan.shaper.key == other.shaper.key && plan.userFeaturesMatch(other)
 }
 
 // Constructs a shaping plan for a combination of @face, @userFeatures, @props,
-// plus the variation-space coordinates @coords.
+// plus the variation-space coordinates @coords and the buffer @options.
 // See newShapePlanCached for caching support.
 func newShapePlan(font *Font, props SegmentProperties,
-	userFeatures []Feature, coords []tables.Coord,
+	userFeatures []Feature, coords []tables.Coord, options planOptions,
 ) *shapePlan {
 	if debugMode {
 		fmt.Printf("NEW SHAPE PLAN: face:%p features:%v coords:%v\n", &font.face, userFeatures, coords)
@@ -101,7 +139,7 @@ func newShapePlan(font *Font, props SegmentProperties,
 
 	var sp shapePlan
 
-	sp.init(true, font, props, userFeatures, coords)
+	sp.init(true, font, props, userFeatures, coords, options)
 
 	if debugMode {
 		fmt.Println("NEW SHAPE PLAN - compiling shaper plan")
@@ -126,15 +164,17 @@ func (sp *shapePlan) execute(font *Font, buffer *Buffer, features []Feature) {
  */
 
 // creates (or returns) a cached shaping plan suitable for reuse, for a combination
-// of `face`, `userFeatures`, `props`, plus the variation-space coordinates `coords`.
+// of `face`, `userFeatures`, `props`, plus the variation-space coordinates `coords`
+// and the buffer options.
 func (b *Buffer) newShapePlanCached(font *Font, props SegmentProperties,
-	userFeatures []Feature, coords []tables.Coord,
+	userFeatures []Feature, coords []tables.Coord, options planOptions,
 ) *shapePlan {
 	var key shapePlan
-	key.init(false, font, props, userFeatures, coords)
+	key.init(false, font, props, userFeatures, coords, options)
 
+	// the plans built for other variable coordinates are kept, since they
+	// only differ by their key : they are reused if the coordinates are restored
 	plans := b.planCache[font.face]
-
 	for _, plan := range plans {
 		if plan.equal(key) {
 			if debugMode {
@@ -143,7 +183,7 @@ func (b *Buffer) newShapePlanCached(font *Font, props SegmentProperties,
 			return plan
 		}
 	}
-	plan := newShapePlan(font, props, userFeatures, coords)
+	plan := newShapePlan(font, props, userFeatures, coords, options)
 
 	plans = append(plans, plan)
 	b.planCache[font.face] = plans
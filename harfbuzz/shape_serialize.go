@@ -0,0 +1,361 @@
+package harfbuzz
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/boxesandglue/typesetting/font"
+	"github.com/boxesandglue/typesetting/font/opentype/tables"
+	"github.com/boxesandglue/typesetting/language"
+)
+
+// The plans are serialized in a versioned container :
+//   - the magic number plansMagic
+//   - the format version, as uint16
+//   - the layout checksum of the font (see layoutChecksum), as uint64
+//   - the number of plans, as uint16, followed by the plans
+//
+// plansFormatVersion must be incremented when the encoding (or the content of
+// the compiled maps) changes, so that outdated data is rejected instead of being misread.
+const plansFormatVersion = 1
+
+// plansMagic identifies serialized plans
+var plansMagic = [4]byte{'h', 'b', 'p', 'l'}
+
+const plansHeaderSize = 4 + 2 + 8 + 2 // magic + version + checksum + count
+
+// errInvalidPlans is returned for truncated or corrupted data
+var errInvalidPlans = errors.New("invalid serialized plans")
+
+// layoutChecksumKey is the key used to cache
+// the layout checksum with [font.Face.ShaperData]
+type layoutChecksumKey struct{}
+
+// cachedLayoutChecksum returns the layout checksum of the face of [font],
+// computed once per face.
+func cachedLayoutChecksum(font *Font) uint64 {
+	return font.face.ShaperData(layoutChecksumKey{}, func() any {
+		return layoutChecksum(font.face.Font)
+	}).(uint64)
+}
+
+// layoutChecksum returns a hash of the parts of the font used when compiling
+// the maps of the shaping plans, that is the GSUB, GPOS and JSTF layouts.
+// It is used to detect serialized plans loaded for the wrong font.
+func layoutChecksum(ft *font.Font) uint64 {
+	h := fnv.New64a()
+	var buf [4]byte
+	write := func(v uint32) {
+		binary.BigEndian.PutUint32(buf[:], v)
+		h.Write(buf[:])
+	}
+	writeLangSys := func(ls *tables.LangSys) {
+		write(uint32(ls.RequiredFeatureIndex))
+		write(uint32(len(ls.FeatureIndices)))
+		for _, index := range ls.FeatureIndices {
+			write(uint32(index))
+		}
+	}
+	writeLayout := func(la *font.Layout) {
+		write(uint32(len(la.Scripts)))
+		for _, script := range la.Scripts {
+			write(uint32(script.Tag))
+			if script.DefaultLangSys != nil {
+				writeLangSys(script.DefaultLangSys)
+			}
+			write(uint32(len(script.LangSys)))
+			for i, ls := range script.LangSys {
+				write(uint32(script.LangSysRecords[i].Tag))
+				writeLangSys(&ls)
+			}
+		}
+		write(uint32(len(la.Features)))
+		for _, feature := range la.Features {
+			write(uint32(feature.Tag))
+			write(uint32(len(feature.LookupListIndices)))
+			for _, index := range feature.LookupListIndices {
+				write(uint32(index))
+			}
+		}
+		write(uint32(len(la.FeatureVariations)))
+	}
+	writeLookup := func(lo font.LookupOptions, subtables int) {
+		write(lo.Props())
+		write(uint32(subtables))
+	}
+
+	writeLayout(&ft.GSUB.Layout)
+	write(uint32(len(ft.GSUB.Lookups)))
+	for _, lookup := range ft.GSUB.Lookups {
+		writeLookup(lookup.LookupOptions, len(lookup.Subtables))
+	}
+	writeLayout(&ft.GPOS.Layout)
+	write(uint32(len(ft.GPOS.Lookups)))
+	for _, lookup := range ft.GPOS.Lookups {
+		writeLookup(lookup.LookupOptions, len(lookup.Subtables))
+	}
+	write(uint32(len(ft.JSTF().ScriptRecords)))
+	for _, record := range ft.JSTF().ScriptRecords {
+		write(uint32(record.Tag))
+	}
+	return h.Sum64()
+}
+
+// MarshalPlans serializes the shaping plans compiled by the buffer for [font], so
+// that they may be loaded later with [Buffer.UnmarshalPlans], skipping their compilation.
+// This is useful for services shaping text with a small, fixed set of fonts, which
+// may store the plans and load them at startup.
+//
+// A plan is compiled (and cached in the buffer) for each combination of segment properties,
+// features (only the tags, values and whether they are global matter), buffer options
+// and variation coordinates used with [Buffer.Shape]. Typically, some representative text
+// is shaped before calling MarshalPlans.
+//
+// The plans are only valid for the same font (they are keyed by a checksum of its layout tables)
+// and the same version of this package.
+func (b *Buffer) MarshalPlans(font *Font) ([]byte, error) {
+	plans := b.planCache[font.face]
+	if len(plans) > 0xFFFF {
+		return nil, fmt.Errorf("too many plans (%d)", len(plans))
+	}
+	out := make([]byte, plansHeaderSize, plansHeaderSize+len(plans)*256)
+	copy(out, plansMagic[:])
+	binary.BigEndian.PutUint16(out[4:], plansFormatVersion)
+	binary.BigEndian.PutUint64(out[6:], cachedLayoutChecksum(font))
+	binary.BigEndian.PutUint16(out[14:], uint16(len(plans)))
+	for _, plan := range plans {
+		out = plan.appendTo(out)
+	}
+	return out, nil
+}
+
+// UnmarshalPlans loads the plans serialized by [Buffer.MarshalPlans] into the cache
+// of the buffer, so that the subsequent calls to [Buffer.Shape] with [font] use them.
+//
+// An error is returned if [data] is invalid, or if it has been serialized for a different font
+// (or a previous version of this package). Plans compiled for other variation
+// coordinates than the current ones of [font] are ignored.
+func (b *Buffer) UnmarshalPlans(font *Font, data []byte) error {
+	if len(data) < plansHeaderSize || string(data[0:4]) != string(plansMagic[:]) {
+		return errInvalidPlans
+	}
+	if version := binary.BigEndian.Uint16(data[4:]); version != plansFormatVersion {
+		return fmt.Errorf("unsupported serialized plans version %d", version)
+	}
+	if binary.BigEndian.Uint64(data[6:]) != cachedLayoutChecksum(font) {
+		return errors.New("serialized plans do not match the font")
+	}
+	count := int(binary.BigEndian.Uint16(data[14:]))
+
+	coords := font.varCoords()
+	plans := b.planCache[font.face]
+	lookupCounts := [2]int{len(font.face.GSUB.Lookups), len(font.face.GPOS.Lookups)}
+	r := planReader{data: data[plansHeaderSize:]}
+	for i := 0; i < count; i++ {
+		var sp shapePlan
+		props, features, options, key, m := r.readPlan(lookupCounts)
+		if r.err != nil {
+			return r.err
+		}
+		sp.init(true, font, props, features, coords, options)
+		if sp.shaper.key != key { // other coordinates
+			continue
+		}
+		if err := sp.shaper.compileFrom(props, features, &m); err != nil {
+			return fmt.Errorf("invalid serialized plan: %s", err)
+		}
+		if !sp.isCachedIn(plans) {
+			plans = append(plans, &sp)
+		}
+	}
+	b.planCache[font.face] = plans
+	return nil
+}
+
+func (plan *shapePlan) isCachedIn(plans []*shapePlan) bool {
+	for _, other := range plans {
+		if other.equal(*plan) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUint16(dst []byte, v uint16) []byte { return binary.BigEndian.AppendUint16(dst, v) }
+func appendUint32(dst []byte, v uint32) []byte { return binary.BigEndian.AppendUint32(dst, v) }
+
+func appendBools(dst []byte, bs ...bool) []byte {
+	var flags byte
+	for i, b := range bs {
+		if b {
+			flags |= 1 << i
+		}
+	}
+	return append(dst, flags)
+}
+
+func (plan *shapePlan) appendTo(dst []byte) []byte {
+	// properties
+	dst = append(dst, byte(plan.props.Direction))
+	dst = appendUint32(dst, uint32(plan.props.Script))
+	dst = appendUint16(dst, uint16(len(plan.props.Language)))
+	dst = append(dst, plan.props.Language...)
+
+	// options
+	dst = appendUint32(dst, uint32(int32(plan.options.jstfLevel)))
+	dst = appendBools(dst, plan.options.syntheticCJKSpacing, plan.options.vertOnly)
+
+	// user features
+	dst = appendUint16(dst, uint16(len(plan.userFeatures)))
+	for _, feat := range plan.userFeatures {
+		dst = appendUint32(dst, uint32(feat.Tag))
+		dst = appendUint32(dst, feat.Value)
+		dst = appendBools(dst, feat.Start == FeatureGlobalStart && feat.End == FeatureGlobalEnd)
+	}
+
+	// variations
+	key := plan.shaper.key
+	dst = appendUint32(dst, uint32(int32(key[0])))
+	dst = appendUint32(dst, uint32(int32(key[1])))
+
+	return plan.shaper.plan.map_.appendTo(dst)
+}
+
+func (m *otMap) appendTo(dst []byte) []byte {
+	dst = appendUint32(dst, uint32(m.globalMask))
+	dst = appendUint32(dst, uint32(m.chosenScript[0]))
+	dst = appendUint32(dst, uint32(m.chosenScript[1]))
+	dst = appendBools(dst, m.foundScript[0], m.foundScript[1])
+
+	dst = appendUint16(dst, uint16(len(m.features)))
+	for _, feat := range m.features {
+		dst = appendUint32(dst, uint32(feat.tag))
+		dst = appendUint16(dst, feat.index[0])
+		dst = appendUint16(dst, feat.index[1])
+		dst = appendUint16(dst, uint16(feat.stage[0]))
+		dst = appendUint16(dst, uint16(feat.stage[1]))
+		dst = append(dst, byte(feat.shift))
+		dst = appendUint32(dst, uint32(feat.mask))
+		dst = appendUint32(dst, uint32(feat.mask1))
+		dst = appendBools(dst, feat.needsFallback, feat.autoZWNJ, feat.autoZWJ, feat.random, feat.perSyllable)
+	}
+
+	for tableIndex := range m.lookups {
+		dst = appendUint16(dst, uint16(len(m.lookups[tableIndex])))
+		for _, lookup := range m.lookups[tableIndex] {
+			dst = appendUint16(dst, lookup.index)
+			dst = appendUint32(dst, uint32(lookup.featureTag))
+			dst = appendUint32(dst, uint32(lookup.mask))
+			dst = appendBools(dst, lookup.autoZWNJ, lookup.autoZWJ, lookup.random, lookup.perSyllable)
+		}
+		// pause functions are restored when loading the plan
+		dst = appendUint16(dst, uint16(len(m.stages[tableIndex])))
+		for _, stage := range m.stages[tableIndex] {
+			dst = appendUint16(dst, uint16(stage.lastLookup))
+		}
+	}
+	return dst
+}
+
+// planReader decodes the plans, recording
+// the first error encountered
+type planReader struct {
+	data []byte
+	err  error
+}
+
+func (r *planReader) read(n int) []byte {
+	if r.err != nil || len(r.data) < n {
+		r.err = errInvalidPlans
+		return make([]byte, n)
+	}
+	out := r.data[:n]
+	r.data = r.data[n:]
+	return out
+}
+
+func (r *planReader) byte() byte     { return r.read(1)[0] }
+func (r *planReader) uint16() uint16 { return binary.BigEndian.Uint16(r.read(2)) }
+func (r *planReader) uint32() uint32 { return binary.BigEndian.Uint32(r.read(4)) }
+
+func (r *planReader) bools(bs ...*bool) {
+	flags := r.byte()
+	for i, b := range bs {
+		*b = flags&(1<<i) != 0
+	}
+}
+
+// readPlan decodes a plan, checking the lookup indices against [lookupCounts]
+func (r *planReader) readPlan(lookupCounts [2]int) (props SegmentProperties, features []Feature, options planOptions, key otShapePlanKey, m otMap) {
+	props.Direction = Direction(r.byte())
+	props.Script = language.Script(r.uint32())
+	props.Language = language.Language(r.read(int(r.uint16())))
+
+	options.jstfLevel = int(int32(r.uint32()))
+	r.bools(&options.syntheticCJKSpacing, &options.vertOnly)
+
+	features = make([]Feature, r.uint16())
+	for i := range features {
+		features[i].Tag = tables.Tag(r.uint32())
+		features[i].Value = r.uint32()
+		var global bool
+		r.bools(&global)
+		features[i].Start, features[i].End = FeatureGlobalStart, FeatureGlobalEnd
+		if !global {
+			features[i].Start, features[i].End = 1, 2
+		}
+	}
+
+	key[0] = int(int32(r.uint32()))
+	key[1] = int(int32(r.uint32()))
+
+	m.globalMask = GlyphMask(r.uint32())
+	m.chosenScript[0] = tables.Tag(r.uint32())
+	m.chosenScript[1] = tables.Tag(r.uint32())
+	r.bools(&m.foundScript[0], &m.foundScript[1])
+
+	if n := r.uint16(); n != 0 { // keep nil slices, as the compiled maps
+		m.features = make([]featureMap, n)
+	}
+	for i := range m.features {
+		feat := &m.features[i]
+		feat.tag = tables.Tag(r.uint32())
+		feat.index[0] = r.uint16()
+		feat.index[1] = r.uint16()
+		feat.stage[0] = int(r.uint16())
+		feat.stage[1] = int(r.uint16())
+		feat.shift = int(r.byte())
+		feat.mask = GlyphMask(r.uint32())
+		feat.mask1 = GlyphMask(r.uint32())
+		r.bools(&feat.needsFallback, &feat.autoZWNJ, &feat.autoZWJ, &feat.random, &feat.perSyllable)
+	}
+
+	for tableIndex := range m.lookups {
+		var lookups []lookupMap
+		if n := r.uint16(); n != 0 {
+			lookups = make([]lookupMap, n)
+		}
+		for i := range lookups {
+			lookup := &lookups[i]
+			lookup.index = r.uint16()
+			if int(lookup.index) >= lookupCounts[tableIndex] {
+				r.err = errInvalidPlans
+			}
+			lookup.featureTag = tables.Tag(r.uint32())
+			lookup.mask = GlyphMask(r.uint32())
+			r.bools(&lookup.autoZWNJ, &lookup.autoZWJ, &lookup.random, &lookup.perSyllable)
+		}
+		m.lookups[tableIndex] = lookups
+		stages := make([]stageMap, r.uint16())
+		for i := range stages {
+			stages[i].lastLookup = int(r.uint16())
+			if stages[i].lastLookup > len(lookups) {
+				r.err = errInvalidPlans
+			}
+		}
+		m.stages[tableIndex] = stages
+	}
+	return
+}
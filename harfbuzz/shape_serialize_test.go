@@ -0,0 +1,151 @@
+package harfbuzz
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
+)
+
+func TestPlansSerialization(t *testing.T) {
+	type run struct {
+		text     string
+		dir      Direction
+		lang     language.Language
+		features []Feature
+		flags    ShappingOptions
+	}
+	for _, test := range []struct {
+		font *font.Font
+		runs []run
+	}{
+		{openFontFileTT(t, "common/NotoSansArabic.ttf"), []run{
+			{text: "\u0628\u064E\u062A\u0650 \u0644\u0627", dir: RightToLeft},
+			{text: "\u0628\u064E\u062A\u0650 \u0644\u0627", dir: RightToLeft, lang: "ur"},
+		}},
+		{openFontFile(t, "perf_reference/fonts/NotoSansDevanagari-Regular.ttf"), []run{
+			{text: "\u0915\u093F\u0915\u094D\u0937", dir: LeftToRight},
+		}},
+		{openFontFileTT(t, "common/Raleway-v4020-Regular.otf"), []run{
+			{text: "office 1/2", dir: LeftToRight},
+			{text: "office 1/2", dir: LeftToRight, features: []Feature{{Tag: ot.NewTag('s', 'm', 'c', 'p'), Value: 1, Start: FeatureGlobalStart, End: FeatureGlobalEnd}}},
+			{text: "office 1/2", dir: LeftToRight, features: []Feature{{Tag: ot.NewTag('l', 'i', 'g', 'a'), Value: 0, Start: 1, End: 3}}},
+		}},
+		{openFontFileTT(t, "common/NotoSansCJKjp-VF.otf"), []run{
+			{text: "\u6F22\uFF1A\u300C\u304B\u300D\u3002", dir: TopToBottom, flags: SyntheticCJKSpacing},
+			{text: "\u6F22\uFF1A\u300C\u304B\u300D\u3002", dir: LeftToRight},
+		}},
+	} {
+		hbFont := NewFont(font.NewFace(test.font))
+
+		shape := func(b *Buffer, r run) ([]GlyphInfo, []GlyphPosition) {
+			b.Clear()
+			b.Flags = r.flags
+			b.AddRunes([]rune(r.text), 0, -1)
+			b.Props.Direction = r.dir
+			b.Props.Language = r.lang
+			b.GuessSegmentProperties()
+			b.Shape(hbFont, r.features)
+			return append([]GlyphInfo(nil), b.Info...), append([]GlyphPosition(nil), b.Pos...)
+		}
+
+		compiled := NewBuffer()
+		var expected [][]GlyphInfo
+		for _, r := range test.runs {
+			infos, _ := shape(compiled, r)
+			expected = append(expected, infos)
+		}
+		tu.Assert(t, len(compiled.planCache[hbFont.face]) == len(test.runs))
+		data, err := compiled.MarshalPlans(hbFont)
+		tu.AssertNoErr(t, err)
+
+		loaded := NewBuffer()
+		err = loaded.UnmarshalPlans(hbFont, data)
+		tu.AssertNoErr(t, err)
+		tu.Assert(t, len(loaded.planCache[hbFont.face]) == len(test.runs))
+		// the loaded plans are identical
+		data2, err := loaded.MarshalPlans(hbFont)
+		tu.AssertNoErr(t, err)
+		tu.Assert(t, bytes.Equal(data, data2))
+		for i, plan := range loaded.planCache[hbFont.face] {
+			exp := compiled.planCache[hbFont.face][i].shaper.plan
+			got := plan.shaper.plan
+			tu.Assert(t, reflect.DeepEqual(exp.map_.features, got.map_.features))
+			tu.Assert(t, reflect.DeepEqual(exp.map_.lookups, got.map_.lookups))
+			for tableIndex := range exp.map_.stages {
+				tu.Assert(t, len(exp.map_.stages[tableIndex]) == len(got.map_.stages[tableIndex]))
+				for j, stage := range exp.map_.stages[tableIndex] {
+					gotStage := got.map_.stages[tableIndex][j]
+					tu.Assert(t, stage.lastLookup == gotStage.lastLookup)
+					tu.Assert(t, (stage.pauseFunc == nil) == (gotStage.pauseFunc == nil))
+				}
+			}
+			// the complex shapers are rebuilt from the map
+			exp.map_, got.map_ = otMap{}, otMap{}
+			exp.shaper, got.shaper = nil, nil
+			tu.Assert(t, reflect.DeepEqual(exp, got))
+		}
+
+		// and used when shaping
+		for i, r := range test.runs {
+			infos, _ := shape(loaded, r)
+			tu.Assert(t, reflect.DeepEqual(infos, expected[i]))
+		}
+		tu.Assert(t, len(loaded.planCache[hbFont.face]) == len(test.runs))
+	}
+}
+
+func TestPlansSerializationErrors(t *testing.T) {
+	arabic := NewFont(font.NewFace(openFontFileTT(t, "common/NotoSansArabic.ttf")))
+	latin := NewFont(font.NewFace(openFontFileTT(t, "common/Raleway-v4020-Regular.otf")))
+
+	b := NewBuffer()
+	b.AddRunes([]rune("\u0628\u064E\u062A\u0650"), 0, -1)
+	b.GuessSegmentProperties()
+	b.Shape(arabic, nil)
+	data, err := b.MarshalPlans(arabic)
+	tu.AssertNoErr(t, err)
+
+	loaded := NewBuffer()
+	tu.Assert(t, loaded.UnmarshalPlans(latin, data) != nil) // wrong font
+	for i := 0; i < len(data); i++ {
+		tu.Assert(t, loaded.UnmarshalPlans(arabic, data[:i]) != nil) // truncated
+	}
+	wrongVersion := append([]byte(nil), data...)
+	wrongVersion[5]++
+	tu.Assert(t, loaded.UnmarshalPlans(arabic, wrongVersion) != nil)
+	tu.Assert(t, len(loaded.planCache[arabic.face]) == 0)
+
+	// no plans
+	data, err = NewBuffer().MarshalPlans(latin)
+	tu.AssertNoErr(t, err)
+	tu.AssertNoErr(t, loaded.UnmarshalPlans(latin, data))
+}
+
+func BenchmarkPlansSerialization(b *testing.B) {
+	hbFont := NewFont(font.NewFace(openFontFile(b, "perf_reference/fonts/NotoNastaliqUrdu-Regular.ttf")))
+	props := SegmentProperties{Direction: RightToLeft, Script: language.Arabic, Language: "ur"}
+
+	b.Run("compile", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			newShapePlan(hbFont, props, nil, nil, planOptions{})
+		}
+	})
+
+	buf := NewBuffer()
+	buf.newShapePlanCached(hbFont, props, nil, nil, planOptions{})
+	data, err := buf.MarshalPlans(hbFont)
+	tu.AssertNoErr(b, err)
+	b.Run("load", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			buf := NewBuffer()
+			if err := buf.UnmarshalPlans(hbFont, data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
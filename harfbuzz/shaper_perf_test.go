@@ -1,16 +1,23 @@
 package harfbuzz
 
 import (
-	"os"
+	"strings"
 	"testing"
 
 	"github.com/boxesandglue/typesetting/font"
 	"github.com/boxesandglue/typesetting/language"
 	tu "github.com/boxesandglue/typesetting/testutils"
+	td "github.com/go-text/typesetting-utils/harfbuzz"
 )
 
 // ported from harfbuzz/perf
 
+// the opening of "I Am a Cat", by Natsume Soseki
+const jaText = "\u543E\u8F29\u306F\u732B\u3067\u3042\u308B\u3002\u540D\u524D\u306F\u307E\u3060\u7121\u3044\u3002" +
+	"\u3069\u3053\u3067\u751F\u308C\u305F\u304B\u3068\u3093\u3068\u898B\u5F53\u304C\u3064\u304B\u306C\u3002" +
+	"\u4F55\u3067\u3082\u8584\u6697\u3044\u3058\u3081\u3058\u3081\u3057\u305F\u6240\u3067" +
+	"\u30CB\u30E3\u30FC\u30CB\u30E3\u30FC\u6CE3\u3044\u3066\u3044\u305F\u4E8B\u3060\u3051\u306F\u8A18\u61B6\u3057\u3066\u3044\u308B\u3002"
+
 func BenchmarkShaping(b *testing.B) {
 	runs := []struct {
 		name      string
@@ -21,7 +28,7 @@ func BenchmarkShaping(b *testing.B) {
 	}{
 		{
 			"fa-thelittleprince.txt - Amiri",
-			"erf_reference/texts/fa-thelittleprince.txt",
+			"perf_reference/texts/fa-thelittleprince.txt",
 			"perf_reference/fonts/Amiri-Regular.ttf",
 			language.Arabic,
 			RightToLeft,
@@ -68,21 +75,26 @@ func BenchmarkShaping(b *testing.B) {
 
 	for _, run := range runs {
 		b.Run(run.name, func(b *testing.B) {
-			shapeOne(b, run.textFile, run.fontFile, run.direction, run.script)
+			textB, err := td.Files.ReadFile(run.textFile)
+			tu.AssertNoErr(b, err)
+			shapeOne(b, []rune(string(textB)), openFontFile(b, run.fontFile), run.direction, run.script)
 		})
 	}
-}
 
-func shapeOne(b *testing.B, textFile, fontFile string, direction Direction, script language.Script) {
-	ft := openFontFile(b, fontFile)
+	// large CJK font, with lookups covering many glyphs
+	ft := openFontFileTT(b, "common/NotoSansCJKjp-VF.otf")
+	text := []rune(strings.Repeat(jaText, 50))
+	b.Run("ja - NotoSansCJKjp", func(b *testing.B) {
+		shapeOne(b, text, ft, LeftToRight, language.Han)
+	})
+	b.Run("ja vertical - NotoSansCJKjp", func(b *testing.B) {
+		shapeOne(b, text, ft, TopToBottom, language.Han)
+	})
+}
 
+func shapeOne(b *testing.B, text []rune, ft *font.Font, direction Direction, script language.Script) {
 	font := NewFont(font.NewFace(ft))
 
-	textB, err := os.ReadFile(textFile)
-	tu.AssertNoErr(b, err)
-
-	text := []rune(string(textB))
-
 	buf := NewBuffer()
 
 	b.ResetTimer()
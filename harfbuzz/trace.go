@@ -0,0 +1,88 @@
+package harfbuzz
+
+import ot "github.com/boxesandglue/typesetting/font/opentype"
+
+// TraceEventKind identifies the steps of the shaping process
+// reported to [Buffer.Tracer].
+type TraceEventKind uint8
+
+const (
+	// TraceTableStart is emitted before applying a layout table,
+	// given by [TraceEvent.Table]: 'GSUB', 'GPOS', 'morx', 'kerx' or 'kern'.
+	TraceTableStart TraceEventKind = iota
+	// TraceTableEnd is emitted after applying a layout table.
+	TraceTableEnd
+	// TraceLookupStart is emitted before applying a GSUB or GPOS lookup.
+	// The lookup is skipped when the buffer has none of its glyphs.
+	TraceLookupStart
+	// TraceLookupEnd is emitted after applying a GSUB or GPOS lookup.
+	TraceLookupEnd
+	// TraceSubtableStart is emitted before applying a subtable
+	// of a 'morx' chain, or of a 'kerx' or 'kern' table.
+	TraceSubtableStart
+	// TraceSubtableEnd is emitted after applying a 'morx', 'kerx' or 'kern' subtable.
+	TraceSubtableEnd
+	// TracePause is emitted before calling the function
+	// registered by a complex shaper between two GSUB or GPOS stages,
+	// which may reorder or modify the glyphs (for instance for Indic scripts).
+	TracePause
+)
+
+// String returns a human readable name of the event kind.
+func (k TraceEventKind) String() string {
+	switch k {
+	case TraceTableStart:
+		return "start table"
+	case TraceTableEnd:
+		return "end table"
+	case TraceLookupStart:
+		return "start lookup"
+	case TraceLookupEnd:
+		return "end lookup"
+	case TraceSubtableStart:
+		return "start subtable"
+	case TraceSubtableEnd:
+		return "end subtable"
+	case TracePause:
+		return "pause"
+	default:
+		return "<invalid trace event>"
+	}
+}
+
+// TraceEvent describes one step of the shaping process.
+type TraceEvent struct {
+	Kind TraceEventKind
+	// Table is the tag of the table being applied.
+	Table ot.Tag
+	// Index is the index of the lookup in the 'GSUB' or 'GPOS' lookup list,
+	// or the index of the subtable in its 'morx' chain or 'kerx'/'kern' table,
+	// or the stage index for [TracePause].
+	// It is zero for table events.
+	Index int
+	// Feature is the feature enabling the lookup, for lookup events.
+	Feature ot.Tag
+}
+
+// Tracer is a function receiving the steps of the shaping process,
+// with the [Buffer] being shaped, in its intermediate state.
+// It is intended for debugging tools, which may for instance copy
+// the glyphs (or their positions, for 'GPOS', 'kerx' and 'kern') after each lookup.
+//
+// The buffer must not be modified.
+type Tracer = func(event TraceEvent, buffer *Buffer)
+
+var (
+	tagGSUB = ot.NewTag('G', 'S', 'U', 'B')
+	tagGPOS = ot.NewTag('G', 'P', 'O', 'S')
+	tagMorx = ot.NewTag('m', 'o', 'r', 'x')
+	tagKerx = ot.NewTag('k', 'e', 'r', 'x')
+	tagKern = ot.NewTag('k', 'e', 'r', 'n')
+)
+
+// trace calls the tracer, if any
+func (b *Buffer) trace(event TraceEvent) {
+	if b.Tracer != nil {
+		b.Tracer(event, b)
+	}
+}
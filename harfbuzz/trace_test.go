@@ -0,0 +1,96 @@
+package harfbuzz
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	tu "github.com/boxesandglue/typesetting/testutils"
+)
+
+func traceShape(t *testing.T, ft *font.Font, text string) ([]TraceEvent, *Buffer, []GID) {
+	var (
+		events []TraceEvent
+		glyphs []GID // after GSUB or morx
+	)
+	buf := NewBuffer()
+	buf.Tracer = func(event TraceEvent, b *Buffer) {
+		tu.Assert(t, b == buf)
+		events = append(events, event)
+		if event.Kind == TraceTableEnd && (event.Table == tagGSUB || event.Table == tagMorx) {
+			glyphs = glyphs[:0]
+			for _, info := range b.Info {
+				glyphs = append(glyphs, info.Glyph)
+			}
+		}
+	}
+	buf.AddRunes([]rune(text), 0, -1)
+	buf.GuessSegmentProperties()
+	buf.Shape(NewFont(font.NewFace(ft)), nil)
+	return events, buf, glyphs
+}
+
+// checkNesting checks that start and end events are balanced
+func checkNesting(t *testing.T, events []TraceEvent) {
+	var stack []TraceEvent
+	for _, event := range events {
+		switch event.Kind {
+		case TraceTableStart, TraceLookupStart, TraceSubtableStart:
+			stack = append(stack, event)
+		case TraceTableEnd, TraceLookupEnd, TraceSubtableEnd:
+			tu.Assert(t, len(stack) != 0)
+			start := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			tu.Assert(t, start.Kind+1 == event.Kind)
+			tu.Assert(t, start.Table == event.Table && start.Index == event.Index && start.Feature == event.Feature)
+		case TracePause:
+			tu.Assert(t, len(stack) == 1 && stack[0].Kind == TraceTableStart)
+		}
+	}
+	tu.Assert(t, len(stack) == 0)
+}
+
+func TestTracerOpenType(t *testing.T) {
+	events, buf, glyphs := traceShape(t, openFontFileTT(t, "common/DejaVuSans.ttf"), "fif\u0301")
+	checkNesting(t, events)
+
+	tu.Assert(t, events[0] == TraceEvent{Kind: TraceTableStart, Table: tagGSUB})
+	tu.Assert(t, events[len(events)-1] == TraceEvent{Kind: TraceTableEnd, Table: tagGPOS})
+	features := map[ot.Tag]bool{}
+	for _, event := range events {
+		if event.Kind == TraceLookupStart {
+			features[event.Feature] = true
+		}
+	}
+	tu.Assert(t, features[ot.NewTag('l', 'i', 'g', 'a')] && features[ot.NewTag('m', 'a', 'r', 'k')])
+
+	// the snapshot after GSUB has the final glyphs
+	tu.Assert(t, len(buf.Info) == 3 && len(glyphs) == 3)
+	for i, info := range buf.Info {
+		tu.Assert(t, info.Glyph == glyphs[i])
+	}
+}
+
+func TestTracerAAT(t *testing.T) {
+	events, _, _ := traceShape(t, openFontFile(t, "harfbuzz_reference/text-rendering-tests/fonts/TestMORXTwo.ttf"), "ABC")
+	checkNesting(t, events)
+	tu.AssertC(t, reflect.DeepEqual(events, []TraceEvent{
+		{Kind: TraceTableStart, Table: tagMorx},
+		{Kind: TraceSubtableStart, Table: tagMorx},
+		{Kind: TraceSubtableEnd, Table: tagMorx},
+		{Kind: TraceTableEnd, Table: tagMorx},
+		// the GSUB table is ignored but still reported
+		{Kind: TraceTableStart, Table: tagGSUB},
+		{Kind: TraceTableEnd, Table: tagGSUB},
+	}), fmt.Sprint(events))
+
+	// kern is used without GPOS
+	events, _, _ = traceShape(t, openFontFileTT(t, "toys/Kern2.ttf"), "ab")
+	checkNesting(t, events)
+	tu.Assert(t, len(events) >= 4)
+	tu.Assert(t, events[0] == TraceEvent{Kind: TraceTableStart, Table: tagGSUB})
+	tu.Assert(t, events[2] == TraceEvent{Kind: TraceTableStart, Table: tagKern})
+	tu.Assert(t, events[len(events)-1] == TraceEvent{Kind: TraceTableEnd, Table: tagKern})
+}
@@ -0,0 +1,261 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package language
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Subtags is the result of parsing a [Language] as a BCP 47 tag,
+// as defined by RFC 5646. All the fields are lowercase.
+type Subtags struct {
+	// Language is the primary language subtag, like "en" or "yue",
+	// or "und" if not specified (for private-use tags).
+	Language string
+	// Extlang is the optional extended language subtag, like "yue" in "zh-yue".
+	Extlang string
+	// Script is the optional script subtag, like "latn" in "sr-latn".
+	Script string
+	// Region is the optional region subtag, like "us" or "419".
+	Region string
+	// Variants are the variant subtags, like "1901" in "de-1901".
+	Variants []string
+	// Extensions are the extension sequences, like "u-co-phonebk".
+	Extensions []Extension
+	// PrivateUse is the content of the private-use sequence, without the "x-" prefix.
+	PrivateUse string
+}
+
+// Extension is an extension sequence of a BCP 47 tag,
+// like "u-co-phonebk" for Unicode locale extensions.
+type Extension struct {
+	Singleton byte     // like 'u' or 't'
+	Subtags   []string // like ["co", "phonebk"]
+}
+
+func isAlphaString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigitString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse splits [l] into its subtags, following the syntax of RFC 5646.
+// The grandfathered tags (like "i-klingon") and the legacy tags with a preferred
+// value are first replaced by their canonical form (see [Language.Canonicalize]).
+//
+// An error is returned if [l] is not a well-formed tag.
+func (l Language) Parse() (Subtags, error) {
+	if preferred, ok := grandfathered[l]; ok {
+		l = preferred
+	}
+	if l == "" {
+		return Subtags{}, errors.New("empty language tag")
+	}
+	parts := strings.Split(string(l), "-")
+
+	var out Subtags
+	i := 0
+	if parts[0] == "x" { // fully private tag
+		out.Language = "und"
+	} else {
+		if len(parts[0]) < 2 || len(parts[0]) > 8 || !isAlphaString(parts[0]) {
+			return Subtags{}, fmt.Errorf("invalid language subtag in %s", l)
+		}
+		out.Language, i = parts[0], 1
+		if len(out.Language) <= 3 && i < len(parts) && len(parts[i]) == 3 && isAlphaString(parts[i]) {
+			out.Extlang, i = parts[i], i+1
+		}
+		if i < len(parts) && len(parts[i]) == 4 && isAlphaString(parts[i]) {
+			out.Script, i = parts[i], i+1
+		}
+		if i < len(parts) && (len(parts[i]) == 2 && isAlphaString(parts[i]) || len(parts[i]) == 3 && isDigitString(parts[i])) {
+			out.Region, i = parts[i], i+1
+		}
+		for ; i < len(parts); i++ {
+			part := parts[i]
+			isVariant := 5 <= len(part) && len(part) <= 8 || len(part) == 4 && part[0] >= '0' && part[0] <= '9'
+			if !isVariant {
+				break
+			}
+			out.Variants = append(out.Variants, part)
+		}
+	}
+
+	for i < len(parts) {
+		part := parts[i]
+		if len(part) != 1 {
+			return Subtags{}, fmt.Errorf("invalid subtag %s in %s", part, l)
+		}
+		i++
+		start := i
+		if part == "x" { // private use : until the end
+			for ; i < len(parts); i++ {
+				if len(parts[i]) < 1 || len(parts[i]) > 8 {
+					return Subtags{}, fmt.Errorf("invalid private-use subtag %s in %s", parts[i], l)
+				}
+			}
+			if start == i {
+				return Subtags{}, fmt.Errorf("empty private-use sequence in %s", l)
+			}
+			out.PrivateUse = strings.Join(parts[start:], "-")
+			break
+		}
+		for ; i < len(parts) && len(parts[i]) >= 2; i++ {
+			if len(parts[i]) > 8 {
+				return Subtags{}, fmt.Errorf("invalid extension subtag %s in %s", parts[i], l)
+			}
+		}
+		if start == i {
+			return Subtags{}, fmt.Errorf("empty extension %s in %s", part, l)
+		}
+		out.Extensions = append(out.Extensions, Extension{Singleton: part[0], Subtags: parts[start:i]})
+	}
+	return out, nil
+}
+
+// Extension returns the subtags of the extension identified by [singleton],
+// or nil if it is not present.
+func (s Subtags) Extension(singleton byte) []string {
+	for _, ext := range s.Extensions {
+		if ext.Singleton == singleton {
+			return ext.Subtags
+		}
+	}
+	return nil
+}
+
+// UnicodeKeyword returns the value of the Unicode locale extension keyword [key] (see UTS #35),
+// like "phonebk" for the key "co" of "de-u-co-phonebk", or an empty string.
+// The value "true" is returned for keywords without value.
+func (s Subtags) UnicodeKeyword(key string) string {
+	subtags := s.Extension('u')
+	for i, subtag := range subtags {
+		if subtag != key {
+			continue
+		}
+		var values []string
+		for _, value := range subtags[i+1:] {
+			if len(value) == 2 { // next key
+				break
+			}
+			values = append(values, value)
+		}
+		if len(values) == 0 {
+			return "true"
+		}
+		return strings.Join(values, "-")
+	}
+	return ""
+}
+
+// Tag returns the tag made of the subtags, which is the inverse of [Language.Parse].
+func (s Subtags) Tag() Language {
+	parts := []string{s.Language}
+	if s.Language == "und" && s.Extlang == "" && s.Script == "" && s.Region == "" &&
+		len(s.Variants) == 0 && len(s.Extensions) == 0 && s.PrivateUse != "" {
+		parts = nil // fully private tag
+	}
+	for _, part := range [...]string{s.Extlang, s.Script, s.Region} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	parts = append(parts, s.Variants...)
+	for _, ext := range s.Extensions {
+		parts = append(parts, string(ext.Singleton))
+		parts = append(parts, ext.Subtags...)
+	}
+	if s.PrivateUse != "" {
+		parts = append(parts, "x", s.PrivateUse)
+	}
+	return Language(strings.Join(parts, "-"))
+}
+
+// Canonicalize returns the canonical form of [l], as defined by RFC 5646 (section 4.5) :
+//   - grandfathered and redundant tags are replaced by their preferred value, like "i-klingon" by "tlh"
+//   - deprecated language and region subtags are replaced, like "iw" by "he", "in" by "id" or "bu" by "mm"
+//   - the extended language form is replaced by the extended language, like "zh-yue" by "yue"
+//   - the extensions are sorted by singleton
+//
+// If [l] is not well-formed, it is returned unchanged.
+func (l Language) Canonicalize() Language {
+	s, err := l.Parse()
+	if err != nil {
+		return l
+	}
+	if s.Extlang != "" {
+		s.Language, s.Extlang = s.Extlang, ""
+	}
+	if preferred, ok := deprecatedLanguages[s.Language]; ok {
+		s.Language = preferred
+	}
+	if preferred, ok := deprecatedRegions[s.Region]; ok {
+		s.Region = preferred
+	}
+	sort.SliceStable(s.Extensions, func(i, j int) bool { return s.Extensions[i].Singleton < s.Extensions[j].Singleton })
+	return s.Tag()
+}
+
+// grandfathered lists the grandfathered and redundant tags of the IANA
+// Language Subtag Registry which have a preferred value.
+var grandfathered = map[Language]Language{
+	"art-lojban":  "jbo",
+	"en-gb-oed":   "en-gb-oxendict",
+	"i-ami":       "ami",
+	"i-bnn":       "bnn",
+	"i-hak":       "hak",
+	"i-klingon":   "tlh",
+	"i-lux":       "lb",
+	"i-navajo":    "nv",
+	"i-pwn":       "pwn",
+	"i-tao":       "tao",
+	"i-tay":       "tay",
+	"i-tsu":       "tsu",
+	"no-bok":      "nb",
+	"no-nyn":      "nn",
+	"sgn-be-fr":   "sfb",
+	"sgn-be-nl":   "vgt",
+	"sgn-ch-de":   "sgg",
+	"zh-guoyu":    "cmn",
+	"zh-hakka":    "hak",
+	"zh-min-nan":  "nan",
+	"zh-xiang":    "hsn",
+	"zh-cmn-hans": "cmn-hans",
+	"zh-cmn-hant": "cmn-hant",
+}
+
+// deprecatedLanguages maps the deprecated language subtags
+// to their preferred value.
+var deprecatedLanguages = map[string]string{
+	"in": "id",
+	"iw": "he",
+	"ji": "yi",
+	"jw": "jv",
+	"mo": "ro",
+}
+
+// deprecatedRegions maps the deprecated region subtags
+// to their preferred value.
+var deprecatedRegions = map[string]string{
+	"bu": "mm",
+	"dd": "de",
+	"fx": "fr",
+	"tp": "tl",
+	"yd": "ye",
+	"zr": "cd",
+}
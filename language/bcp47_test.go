@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package language
+
+import (
+	"reflect"
+	"testing"
+
+	tu "github.com/boxesandglue/typesetting/testutils"
+)
+
+func TestLanguage_Parse(t *testing.T) {
+	for _, test := range []struct {
+		lang     Language
+		expected Subtags
+	}{
+		{"fr", Subtags{Language: "fr"}},
+		{"zh-yue-hk", Subtags{Language: "zh", Extlang: "yue", Region: "hk"}},
+		{"sr-latn-rs", Subtags{Language: "sr", Script: "latn", Region: "rs"}},
+		{"es-419", Subtags{Language: "es", Region: "419"}},
+		{"de-ch-1901-1996", Subtags{Language: "de", Region: "ch", Variants: []string{"1901", "1996"}}},
+		{"sl-rozaj-biske", Subtags{Language: "sl", Variants: []string{"rozaj", "biske"}}},
+		{"de-u-co-phonebk-x-hbotdeu", Subtags{
+			Language:   "de",
+			Extensions: []Extension{{'u', []string{"co", "phonebk"}}},
+			PrivateUse: "hbotdeu",
+		}},
+		{"en-t-fr-u-nu-latn", Subtags{
+			Language:   "en",
+			Extensions: []Extension{{'t', []string{"fr"}}, {'u', []string{"nu", "latn"}}},
+		}},
+		{"x-hbot1234", Subtags{Language: "und", PrivateUse: "hbot1234"}},
+		{"i-klingon", Subtags{Language: "tlh"}},
+	} {
+		got, err := test.lang.Parse()
+		tu.AssertNoErr(t, err)
+		tu.AssertC(t, reflect.DeepEqual(got, test.expected), string(test.lang))
+	}
+
+	for _, invalid := range []Language{"", "a", "1234", "en-", "en-a", "en-u", "en-x", "fr-verylongsubtag", "en-us-x-toolongsubtag"} {
+		_, err := invalid.Parse()
+		tu.AssertC(t, err != nil, string(invalid))
+	}
+}
+
+func TestLanguage_Canonicalize(t *testing.T) {
+	for _, test := range [][2]Language{
+		{"fr-fr", "fr-fr"},
+		{"iw", "he"},
+		{"in-id", "id-id"},
+		{"ji", "yi"},
+		{"jw", "jv"},
+		{"mo", "ro"},
+		{"i-klingon", "tlh"},
+		{"art-lojban", "jbo"},
+		{"zh-min-nan", "nan"},
+		{"zh-yue", "yue"},
+		{"zh-yue-hk", "yue-hk"},
+		{"my-bu", "my-mm"},
+		{"en-u-nu-latn-t-fr", "en-t-fr-u-nu-latn"},
+		{"iw-x-private", "he-x-private"},
+		{"x-private", "x-private"},
+		{"not a tag", "not a tag"},
+	} {
+		tu.AssertC(t, test[0].Canonicalize() == test[1], string(test[0].Canonicalize()))
+	}
+}
+
+func TestSubtags_UnicodeKeyword(t *testing.T) {
+	s, err := Language("th-u-nu-thai-ca-buddhist-kr").Parse()
+	tu.AssertNoErr(t, err)
+	tu.Assert(t, s.UnicodeKeyword("nu") == "thai")
+	tu.Assert(t, s.UnicodeKeyword("ca") == "buddhist")
+	tu.Assert(t, s.UnicodeKeyword("kr") == "true")
+	tu.Assert(t, s.UnicodeKeyword("co") == "")
+	tu.Assert(t, s.Extension('t') == nil)
+}
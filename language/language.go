@@ -131,30 +131,27 @@ func (l Language) Compare(other Language) LanguageComparison {
 }
 
 func languageFromLocale(locale string) Language {
-	if i := strings.IndexByte(locale, '.'); i >= 0 {
+	// strip the codeset and the modifier, as in sr_RS.UTF-8@latin
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
 		locale = locale[:i]
 	}
+	// the portable locale does not define a language
+	if locale == "C" || locale == "POSIX" {
+		return ""
+	}
 	return NewLanguage(locale)
 }
 
 // DefaultLanguage returns the language found in environment variables LC_ALL, LC_CTYPE or
 // LANG (in that order), or the zero value if not found.
+// As for POSIX locales, empty variables are ignored, and the "C" and "POSIX" locales
+// have no language.
 func DefaultLanguage() Language {
-	p, ok := os.LookupEnv("LC_ALL")
-	if ok {
-		return languageFromLocale(p)
-	}
-
-	p, ok = os.LookupEnv("LC_CTYPE")
-	if ok {
-		return languageFromLocale(p)
-	}
-
-	p, ok = os.LookupEnv("LANG")
-	if ok {
-		return languageFromLocale(p)
+	for _, name := range [...]string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := os.Getenv(name); locale != "" {
+			return languageFromLocale(locale)
+		}
 	}
-
 	return ""
 }
 
@@ -9,8 +9,24 @@ import (
 	tu "github.com/boxesandglue/typesetting/testutils"
 )
 
-func TestLanguage(t *testing.T) {
-	fmt.Println(DefaultLanguage())
+func TestDefaultLanguage(t *testing.T) {
+	for _, test := range []struct {
+		lcAll, lcCType, lang string
+		expected             Language
+	}{
+		{"", "", "", ""},
+		{"", "", "fr_FR.UTF-8", "fr-fr"},
+		{"", "de_DE@euro", "fr_FR.UTF-8", "de-de"},
+		{"sr_RS.UTF-8@latin", "de_DE", "fr_FR", "sr-rs"},
+		{"C", "", "fr_FR", ""},
+		{"", "POSIX", "fr_FR", ""},
+		{"C.UTF-8", "", "", ""},
+	} {
+		t.Setenv("LC_ALL", test.lcAll)
+		t.Setenv("LC_CTYPE", test.lcCType)
+		t.Setenv("LANG", test.lang)
+		tu.AssertC(t, DefaultLanguage() == test.expected, fmt.Sprint(test, DefaultLanguage()))
+	}
 }
 
 func TestNonASCIILanguage(t *testing.T) {
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+// interlinear annotation characters
+const (
+	annotationAnchor     = '\uFFF9'
+	annotationSeparator  = '\uFFFA'
+	annotationTerminator = '\uFFFB'
+)
+
+func isAnnotationControl(r rune) bool {
+	return annotationAnchor <= r && r <= annotationTerminator
+}
+
+func hasAnnotationControls(text []rune) bool {
+	for _, r := range text {
+		if isAnnotationControl(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Annotation is an interlinear annotation (like ruby) encoded in plain text
+// with the characters U+FFF9 (anchor), U+FFFA (separator) and U+FFFB (terminator) :
+//
+//	U+FFF9 base text U+FFFA annotating text U+FFFB
+//
+// The ranges are rune indices into the text passed to [ParseAnnotations],
+// and never include the control characters.
+type Annotation struct {
+	// Base is the annotated text.
+	Base Range
+	// Annotating are the annotating texts : several annotations
+	// may be attached to the same base, each one introduced by U+FFFA.
+	Annotating []Range
+}
+
+// lastRange returns the range being parsed
+func (an *Annotation) lastRange() *Range {
+	if len(an.Annotating) == 0 {
+		return &an.Base
+	}
+	return &an.Annotating[len(an.Annotating)-1]
+}
+
+// ParseAnnotations returns the interlinear annotations found in [text], in logical order.
+//
+// Malformed sequences are handled leniently : an annotation which is not terminated
+// ends with the text, and separators or terminators found outside of an annotation are ignored.
+// Annotations can't be nested : an anchor found inside an annotation starts a new one.
+//
+// Note that [Segmenter.Split] splits the runs around the annotation characters,
+// so that base and annotating texts may be positioned independently,
+// and that [HarfbuzzShaper] renders these characters with invisible glyphs.
+// See also [StripAnnotations].
+func ParseAnnotations(text []rune) []Annotation {
+	var out []Annotation
+	inAnnotation := false
+	// closes the range being parsed, ending (excluded) at [end]
+	closeRange := func(end int) {
+		rg := out[len(out)-1].lastRange()
+		rg.Count = end - rg.Offset
+	}
+	for i, r := range text {
+		switch r {
+		case annotationAnchor:
+			if inAnnotation {
+				closeRange(i)
+			}
+			out = append(out, Annotation{Base: Range{Offset: i + 1}})
+			inAnnotation = true
+		case annotationSeparator:
+			if inAnnotation {
+				closeRange(i)
+				an := &out[len(out)-1]
+				an.Annotating = append(an.Annotating, Range{Offset: i + 1})
+			}
+		case annotationTerminator:
+			if inAnnotation {
+				closeRange(i)
+				inAnnotation = false
+			}
+		}
+	}
+	if inAnnotation {
+		closeRange(len(text))
+	}
+	return out
+}
+
+// StripAnnotations returns a copy of [text] where the interlinear annotations
+// are replaced by their base text, which is the recommended fallback for
+// processes not supporting annotations.
+// See [ParseAnnotations] for the handling of malformed sequences.
+func StripAnnotations(text []rune) []rune {
+	out := make([]rune, 0, len(text))
+	var (
+		inAnnotation bool // true between an anchor and a terminator
+		inAnnotating bool // true in the annotating text
+	)
+	for _, r := range text {
+		switch r {
+		case annotationAnchor:
+			inAnnotation, inAnnotating = true, false
+		case annotationSeparator:
+			inAnnotating = inAnnotation
+		case annotationTerminator:
+			inAnnotation, inAnnotating = false, false
+		default:
+			if !inAnnotating {
+				out = append(out, r)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/di"
+	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestParseAnnotations(t *testing.T) {
+	for _, test := range []struct {
+		text     string
+		expected []Annotation
+		stripped string
+	}{
+		{"no annotation", nil, "no annotation"},
+		{
+			"a\uFFF9漢字\uFFFAかんじ\uFFFBb", []Annotation{
+				{Base: Range{2, 2}, Annotating: []Range{{5, 3}}},
+			}, "a漢字b",
+		},
+		{
+			"\uFFF9東\uFFFAとう\uFFFAdong\uFFFB\uFFF9京\uFFFAきょう\uFFFB", []Annotation{
+				{Base: Range{1, 1}, Annotating: []Range{{3, 2}, {6, 4}}},
+				{Base: Range{12, 1}, Annotating: []Range{{14, 3}}},
+			}, "東京",
+		},
+		// malformed sequences
+		{
+			"x\uFFFAy\uFFFBz\uFFF9base\uFFFAnot terminated", []Annotation{
+				{Base: Range{6, 4}, Annotating: []Range{{11, 14}}},
+			}, "xyzbase",
+		},
+		{
+			"\uFFF9a\uFFF9b\uFFFAc\uFFFB", []Annotation{
+				{Base: Range{1, 1}},
+				{Base: Range{3, 1}, Annotating: []Range{{5, 1}}},
+			}, "ab",
+		},
+	} {
+		text := []rune(test.text)
+		got := ParseAnnotations(text)
+		tu.AssertC(t, reflect.DeepEqual(got, test.expected), test.text)
+		tu.AssertC(t, string(StripAnnotations(text)) == test.stripped, test.text)
+	}
+}
+
+func TestAnnotationRuns(t *testing.T) {
+	latinFont := loadOpentypeFont(t, "../font/testdata/UbuntuMono-R.ttf")
+	text := []rune("The \uFFF9base\uFFFAannotation\uFFFB text")
+
+	var seg Segmenter
+	inputs := seg.Split(Input{
+		Text:      text,
+		RunEnd:    len(text),
+		Direction: di.DirectionLTR,
+		Size:      fixed.I(10),
+		Language:  language.NewLanguage("en"),
+	}, fixedFontmap{latinFont})
+	var runs []string
+	for _, input := range inputs {
+		runs = append(runs, string(text[input.RunStart:input.RunEnd]))
+	}
+	tu.Assert(t, reflect.DeepEqual(runs, []string{"The ", "\uFFF9", "base", "\uFFFA", "annotation", "\uFFFB", " text"}))
+
+	// the annotation characters are not rendered with .notdef
+	var shaper HarfbuzzShaper
+	for _, input := range inputs {
+		out := shaper.Shape(input)
+		if !isAnnotationControl(text[input.RunStart]) {
+			continue
+		}
+		tu.Assert(t, len(out.Glyphs) == 1 && out.Advance == 0)
+		g := out.Glyphs[0]
+		tu.Assert(t, g.GlyphID != 0 && g.Width == 0 && g.Height == 0)
+		tu.Assert(t, g.ClusterIndex == input.RunStart && g.RuneCount == 1)
+	}
+
+	// also when shaped in the same run
+	out := shaper.Shape(Input{
+		Text:      text,
+		RunEnd:    len(text),
+		Direction: di.DirectionLTR,
+		Face:      latinFont,
+		Size:      fixed.I(10),
+	})
+	stripped := shaper.Shape(Input{
+		Text:      []rune("The baseannotation text"),
+		RunEnd:    len(text) - 3,
+		Direction: di.DirectionLTR,
+		Face:      latinFont,
+		Size:      fixed.I(10),
+	})
+	tu.Assert(t, out.Advance == stripped.Advance)
+}
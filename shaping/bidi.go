@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	"sort"
+
+	"github.com/boxesandglue/typesetting/di"
+	"golang.org/x/image/math/fixed"
+)
+
+// BidiLine is a single line of text, possibly mixing left-to-right and
+// right-to-left runs (like an Arabic sentence quoting a Latin brand name and numbers),
+// shaped and ordered for display. See [ShapeBidiLine].
+type BidiLine struct {
+	// Runs are the shaped runs, in logical order, with
+	// their [Output.VisualIndex] resolved.
+	Runs Line
+	// Visual stores the indices into [Runs], in visual order,
+	// that is from left to right.
+	Visual []int
+	// Direction is the base direction of the line.
+	Direction di.Direction
+	// Advance is the total advance of the line.
+	Advance fixed.Int26_6
+}
+
+// ShapeBidiLine resolves the common case of a single line of horizontal text
+// mixing several directions, end to end :
+//   - the text is split according to bidi levels, script and faces (see [Segmenter.Split])
+//   - each run is shaped with [shaper]
+//   - the runs are reordered for display
+//
+// [text.Direction] is the base direction of the paragraph, [text.Face] is ignored
+// and [faces] is used to select the font of each run.
+//
+// Use [BidiLine.Visual] to draw the runs from left to right, and [BidiLine.RuneAt]
+// and [BidiLine.RuneOffset] to map positions back to the logical text, for instance for
+// hit testing and caret placement.
+func ShapeBidiLine(shaper Shaper, text Input, faces Fontmap) BidiLine {
+	var seg Segmenter
+	inputs := seg.Split(text, faces)
+
+	line := BidiLine{Direction: text.Direction, Runs: make(Line, 0, len(inputs))}
+	for _, input := range inputs {
+		if input.RunStart >= input.RunEnd {
+			continue
+		}
+		run := shaper.Shape(input)
+		line.Runs = append(line.Runs, run)
+		line.Advance += run.Advance
+	}
+	computeBidiOrdering(text.Direction, line.Runs)
+
+	line.Visual = make([]int, len(line.Runs))
+	for i := range line.Visual {
+		line.Visual[i] = i
+	}
+	sort.Slice(line.Visual, func(i, j int) bool {
+		return line.Runs[line.Visual[i]].VisualIndex < line.Runs[line.Visual[j]].VisualIndex
+	})
+	return line
+}
+
+// visualGlyphs calls [fn] for each glyph of the line, from left to right,
+// with its start position, stopping if [fn] returns false
+func (l BidiLine) visualGlyphs(fn func(run *Output, glyph *Glyph, x fixed.Int26_6) bool) {
+	var x fixed.Int26_6
+	for _, runIndex := range l.Visual {
+		run := &l.Runs[runIndex]
+		for i := range run.Glyphs {
+			g := &run.Glyphs[i]
+			if !fn(run, g, x) {
+				return
+			}
+			x += g.XAdvance
+		}
+	}
+}
+
+// RuneAt returns the index (in the input text) of the first rune of the cluster
+// displayed at the horizontal position [x], measured from the left of the line.
+// Positions outside the line are clamped. -1 is returned for empty lines.
+func (l BidiLine) RuneAt(x fixed.Int26_6) int {
+	out := -1
+	l.visualGlyphs(func(_ *Output, glyph *Glyph, start fixed.Int26_6) bool {
+		if out == -1 || start <= x {
+			out = glyph.ClusterIndex
+		}
+		return start+glyph.XAdvance <= x
+	})
+	return out
+}
+
+// RuneOffset returns the horizontal position, measured from the left of the line,
+// of the leading edge of the cluster containing the rune [runeIndex] (an index into the input text),
+// that is its left edge for left-to-right runs and its right edge for right-to-left runs.
+// It returns false if the rune is not in the line.
+func (l BidiLine) RuneOffset(runeIndex int) (fixed.Int26_6, bool) {
+	var (
+		found    bool
+		left     fixed.Int26_6
+		right    fixed.Int26_6
+		isRTLRun bool
+	)
+	l.visualGlyphs(func(run *Output, glyph *Glyph, start fixed.Int26_6) bool {
+		if runeIndex < glyph.ClusterIndex || runeIndex >= glyph.ClusterIndex+glyph.RuneCount {
+			return !found // the glyphs of a cluster are contiguous
+		}
+		if !found {
+			found, left, isRTLRun = true, start, run.Direction.Progression() == di.TowardTopLeft
+		}
+		right = start + glyph.XAdvance
+		return true
+	})
+	if isRTLRun {
+		return right, found
+	}
+	return left, found
+}
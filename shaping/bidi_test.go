@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	"testing"
+
+	"github.com/boxesandglue/typesetting/di"
+	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestShapeBidiLine(t *testing.T) {
+	latinFont := loadOpentypeFont(t, "../font/testdata/Roboto-Regular.ttf")
+	arabicFont := loadOpentypeFont(t, "../font/testdata/Amiri-Regular.ttf")
+	fm := fixedFontmap{latinFont, arabicFont}
+	var shaper HarfbuzzShaper
+
+	type run struct {
+		text string
+		dir  di.Direction
+	}
+	for _, test := range []struct {
+		text   string
+		dir    di.Direction
+		visual []run // from left to right
+	}{
+		{
+			"اشتريت iPhone 15 بسعر 999 دولار",
+			di.DirectionRTL,
+			[]run{
+				{" دولار", di.DirectionRTL},
+				{"999", di.DirectionLTR},
+				{" بسعر ", di.DirectionRTL},
+				{"iPhone 15", di.DirectionLTR},
+				{"اشتريت ", di.DirectionRTL},
+			},
+		},
+		{
+			"I paid 999 دولار in 2024",
+			di.DirectionLTR,
+			[]run{
+				{"I paid 999 ", di.DirectionLTR},
+				{"دولار", di.DirectionRTL},
+				{" in 2024", di.DirectionLTR},
+			},
+		},
+	} {
+		text := []rune(test.text)
+		line := ShapeBidiLine(&shaper, Input{
+			Text:      text,
+			RunStart:  0,
+			RunEnd:    len(text),
+			Direction: test.dir,
+			Size:      fixed.I(10),
+			Language:  language.NewLanguage("ar"),
+		}, fm)
+
+		tu.Assert(t, line.Direction == test.dir)
+		tu.Assert(t, len(line.Visual) == len(test.visual))
+		var advance fixed.Int26_6
+		for i, exp := range test.visual {
+			r := line.Runs[line.Visual[i]]
+			tu.Assert(t, int(r.VisualIndex) == i)
+			tu.AssertC(t, string(text[r.Runes.Offset:r.Runes.Offset+r.Runes.Count]) == exp.text, exp.text)
+			tu.Assert(t, r.Direction == exp.dir)
+			advance += r.Advance
+		}
+		tu.Assert(t, advance == line.Advance)
+
+		// every rune is displayed, and hit testing at its leading edge
+		// returns its cluster
+		for i := range text {
+			x, ok := line.RuneOffset(i)
+			tu.Assert(t, ok)
+			tu.Assert(t, 0 <= x && x <= line.Advance)
+		}
+		_, ok := line.RuneOffset(len(text))
+		tu.Assert(t, !ok)
+
+		// clamping
+		first, last := line.Runs[line.Visual[0]], line.Runs[line.Visual[len(line.Visual)-1]]
+		tu.Assert(t, line.RuneAt(-fixed.I(10)) == first.Glyphs[0].ClusterIndex)
+		tu.Assert(t, line.RuneAt(line.Advance+fixed.I(10)) == last.Glyphs[len(last.Glyphs)-1].ClusterIndex)
+	}
+
+	// the logical start of the text is at the right of RTL lines
+	text := []rune("اشتريت iPhone 15")
+	line := ShapeBidiLine(&shaper, Input{Text: text, RunEnd: len(text), Direction: di.DirectionRTL, Size: fixed.I(10)}, fm)
+	x, _ := line.RuneOffset(0)
+	tu.Assert(t, x == line.Advance)
+	tu.Assert(t, line.RuneAt(line.Advance-fixed.I(1)) == 0)
+	// and the brand name is read from left to right
+	x, _ = line.RuneOffset(7) // i
+	tu.Assert(t, x == 0)
+	tu.Assert(t, line.RuneAt(fixed.I(1)) == 7)
+
+	line = ShapeBidiLine(&shaper, Input{Direction: di.DirectionLTR, Size: fixed.I(10)}, fm)
+	tu.Assert(t, line.RuneAt(0) == -1)
+}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	"github.com/boxesandglue/typesetting/di"
+	"github.com/boxesandglue/typesetting/harfbuzz"
+)
+
+// CharacterMap maps the runes of a shaped run to the glyphs rendering them.
+//
+// When the shaper reorders characters, as for the pre-base vowels of Indic and Khmer
+// scripts, or the left parts of split vowels, it merges the clusters of the reordered
+// glyphs, so that [Glyph.ClusterIndex] only describes the whole syllable.
+// A CharacterMap keeps track of each rune instead, so that editors may implement caret
+// navigation and selection highlighting inside such clusters.
+type CharacterMap struct {
+	// Offset is the index of the first rune of the run in [Input.Text].
+	Offset int
+
+	// for each rune of the run, the sorted indices in Output.Glyphs
+	glyphs [][]int
+	// for each rune of the run, see IsReordered
+	reordered []bool
+}
+
+// ShapeWithCharacterMap is the same as [HarfbuzzShaper.Shape], but also returns the
+// mapping between the runes of the run and the glyphs of the returned [Output],
+// as computed by the shaper.
+//
+// The input is shaped twice, so this method should only be used when the mapping
+// is actually needed.
+func (t *HarfbuzzShaper) ShapeWithCharacterMap(input Input) (Output, CharacterMap) {
+	out := t.Shape(input)
+
+	start, end := input.runeRange()
+	cm := CharacterMap{
+		Offset:    start,
+		glyphs:    make([][]int, end-start),
+		reordered: make([]bool, end-start),
+	}
+
+	// reshape, keeping one cluster per character: the glyphs are the same,
+	// only the cluster merging differs
+	t.shapeBuffer(input, harfbuzz.Characters, 0)
+	if len(t.buf.Info) == len(out.Glyphs) {
+		for i, info := range t.buf.Info {
+			cm.addGlyph(info.Cluster-start, i)
+		}
+	} else { // should not happen: fall back to the merged clusters
+		for i, g := range out.Glyphs {
+			for r := g.ClusterIndex; r < g.ClusterIndex+g.RuneCount; r++ {
+				cm.addGlyph(r-start, i)
+			}
+		}
+	}
+
+	// runes merged by a ligature, or deleted, have no glyphs:
+	// use the glyphs of the previous rune
+	for r := range cm.glyphs {
+		if len(cm.glyphs[r]) == 0 && r > 0 {
+			cm.glyphs[r] = cm.glyphs[r-1]
+		}
+	}
+	for r := len(cm.glyphs) - 2; r >= 0; r-- {
+		if len(cm.glyphs[r]) == 0 {
+			cm.glyphs[r] = cm.glyphs[r+1]
+		}
+	}
+
+	cm.computeReordered(out.Direction.Progression())
+	return out, cm
+}
+
+func (cm *CharacterMap) addGlyph(r, glyphIndex int) {
+	if r < 0 || r >= len(cm.glyphs) {
+		return
+	}
+	cm.glyphs[r] = append(cm.glyphs[r], glyphIndex)
+}
+
+// computeReordered compares the glyphs of each rune with the glyphs
+// of the runes preceding it in logical order.
+func (cm *CharacterMap) computeReordered(progression di.Progression) {
+	sign := 1
+	if progression == di.TowardTopLeft {
+		// glyphs are in visual order, reversed with respect to the reading order
+		sign = -1
+	}
+	// the position of the last glyph, in reading order, of the previous runes
+	last, hasLast := 0, false
+	for r, glyphs := range cm.glyphs {
+		if len(glyphs) == 0 {
+			continue
+		}
+		first, end := sign*glyphs[0], sign*glyphs[len(glyphs)-1]
+		if first > end {
+			first, end = end, first
+		}
+		if hasLast && first < last {
+			cm.reordered[r] = true
+		}
+		if !hasLast || end > last {
+			last, hasLast = end, true
+		}
+	}
+}
+
+// Glyphs returns the indices in [Output.Glyphs] of the glyphs rendering the
+// rune at [runeIndex], which is an index into [Input.Text], or nil if [runeIndex]
+// is not in the run.
+//
+// The indices are sorted, but are not always contiguous, for instance for split vowels.
+// Runes merged into a ligature share the glyphs of the first rune of the ligature.
+//
+// The returned slice must not be modified.
+func (cm CharacterMap) Glyphs(runeIndex int) []int {
+	runeIndex -= cm.Offset
+	if runeIndex < 0 || runeIndex >= len(cm.glyphs) {
+		return nil
+	}
+	return cm.glyphs[runeIndex]
+}
+
+// IsReordered returns true if the rune at [runeIndex] (an index into [Input.Text])
+// has been moved by the shaper, that is if one of its glyphs is displayed
+// before a glyph of a rune preceding it in logical order.
+func (cm CharacterMap) IsReordered(runeIndex int) bool {
+	runeIndex -= cm.Offset
+	if runeIndex < 0 || runeIndex >= len(cm.reordered) {
+		return false
+	}
+	return cm.reordered[runeIndex]
+}
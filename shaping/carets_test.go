@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/di"
+	"github.com/boxesandglue/typesetting/font"
+	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
+	hd "github.com/go-text/typesetting-utils/harfbuzz"
+)
+
+func TestCharacterMapKhmer(t *testing.T) {
+	b, err := hd.Files.ReadFile("harfbuzz_reference/in-house/fonts/3998336402905b8be8301ef7f47cf7e050cbb1bd.ttf")
+	tu.AssertNoErr(t, err)
+	face, err := font.ParseTTF(bytes.NewReader(b))
+	tu.AssertNoErr(t, err)
+
+	// a space, then KHA, COENG, MO, and the split vowel OE,
+	// whose left part is displayed before the base
+	text := []rune{' ', 0x1781, 0x17D2, 0x1798, 0x17C2}
+	input := Input{
+		Text:      text,
+		RunStart:  1,
+		RunEnd:    len(text),
+		Direction: di.DirectionLTR,
+		Face:      face,
+		Size:      16 * 72,
+		Script:    language.Khmer,
+		Language:  language.NewLanguage("km"),
+	}
+	var shaper HarfbuzzShaper
+	out, cm := shaper.ShapeWithCharacterMap(input)
+	tu.Assert(t, reflect.DeepEqual(out, shaper.Shape(input)))
+
+	// [uni17C2 | uni1781 | uni17D21798]
+	tu.Assert(t, len(out.Glyphs) == 3)
+	for _, g := range out.Glyphs { // one merged cluster
+		tu.Assert(t, g.ClusterIndex == 1)
+	}
+
+	tu.Assert(t, cm.Offset == 1)
+	tu.Assert(t, reflect.DeepEqual(cm.Glyphs(1), []int{1}))
+	tu.Assert(t, reflect.DeepEqual(cm.Glyphs(2), []int{2}))
+	tu.Assert(t, reflect.DeepEqual(cm.Glyphs(3), []int{2})) // ligature
+	tu.Assert(t, reflect.DeepEqual(cm.Glyphs(4), []int{0}))
+	tu.Assert(t, cm.Glyphs(0) == nil && cm.Glyphs(5) == nil)
+
+	for r, expected := range []bool{false, false, false, false, true} {
+		tu.Assert(t, cm.IsReordered(r) == expected)
+	}
+}
+
+func TestCharacterMapRTL(t *testing.T) {
+	text := []rune("abc")
+	input := Input{
+		Text:      text,
+		RunStart:  0,
+		RunEnd:    len(text),
+		Direction: di.DirectionRTL,
+		Face:      benchEnFace,
+		Size:      16 * 72,
+		Script:    language.Latin,
+		Language:  language.NewLanguage("en"),
+	}
+	var shaper HarfbuzzShaper
+	_, cm := shaper.ShapeWithCharacterMap(input)
+	for r := range text {
+		// glyphs are in visual order
+		tu.Assert(t, reflect.DeepEqual(cm.Glyphs(r), []int{2 - r}))
+		tu.Assert(t, !cm.IsReordered(r))
+	}
+}
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	"unicode"
+
+	"github.com/boxesandglue/typesetting/unicodedata"
+)
+
+const (
+	emojiZWJ          = '\u200D'
+	emojiVS16         = '\uFE0F' // emoji presentation selector
+	emojiVS15         = '\uFE0E' // text presentation selector
+	emojiKeycap       = '\u20E3'
+	emojiCancelTag    = '\U000E007F'
+	emojiModifierLow  = '\U0001F3FB'
+	emojiModifierHigh = '\U0001F3FF'
+)
+
+func isRegionalIndicator(r rune) bool { return 0x1F1E6 <= r && r <= 0x1F1FF }
+
+func isEmojiModifier(r rune) bool { return emojiModifierLow <= r && r <= emojiModifierHigh }
+
+func isEmojiTag(r rune) bool { return 0xE0020 <= r && r <= 0xE007E }
+
+func isKeycapBase(r rune) bool { return '0' <= r && r <= '9' || r == '#' || r == '*' }
+
+func isPictographic(r rune) bool { return unicode.Is(unicodedata.Extended_Pictographic, r) }
+
+// emojiSequence returns the end (exclusive) of the emoji sequence
+// starting at text[start], or start if there is none.
+// Only text[:end] is considered.
+//
+// The sequences recognized are (see https://unicode.org/reports/tr51/#Definitions) :
+//   - flags : a pair of regional indicators
+//   - keycaps : [0-9#*] FE0F? 20E3
+//   - ZWJ sequences of elements, each one being an Extended_Pictographic rune,
+//     optionally followed by a presentation selector or a skin tone modifier,
+//     and by a tag sequence (used for sub-region flags)
+func emojiSequence(text []rune, start, end int) int {
+	r := text[start]
+	if isRegionalIndicator(r) {
+		if start+1 < end && isRegionalIndicator(text[start+1]) {
+			return start + 2
+		}
+		return start + 1
+	}
+
+	if isKeycapBase(r) {
+		i := start + 1
+		if i < end && text[i] == emojiVS16 {
+			i++
+		}
+		if i < end && text[i] == emojiKeycap {
+			return i + 1
+		}
+		return start
+	}
+
+	if !isPictographic(r) {
+		return start
+	}
+
+	i := emojiElement(text, start, end)
+	for i+1 < end && text[i] == emojiZWJ && isPictographic(text[i+1]) {
+		i = emojiElement(text, i+1, end)
+	}
+	return i
+}
+
+// emojiElement returns the end of the element starting at text[start],
+// which must be an Extended_Pictographic rune.
+func emojiElement(text []rune, start, end int) int {
+	i := start + 1
+	if i < end && (text[i] == emojiVS16 || text[i] == emojiVS15 || isEmojiModifier(text[i])) {
+		i++
+	}
+	// tag sequence
+	j := i
+	for j < end && isEmojiTag(text[j]) {
+		j++
+	}
+	if j > i && j < end && text[j] == emojiCancelTag {
+		i = j + 1
+	}
+	return i
+}
+
+// emojiSequenceKey returns the rune of [sequence]
+// which should be used to select a font for the whole sequence.
+func emojiSequenceKey(sequence []rune) rune {
+	if sequence[len(sequence)-1] == emojiKeycap {
+		return emojiKeycap
+	}
+	return sequence[0]
+}
+
+// EmojiSequences returns the emoji sequences (like flags, keycaps, skin tone
+// modifiers or ZWJ sequences) found in [text], which should be rendered with one font,
+// and which are shaped as one cluster by [HarfbuzzShaper].
+//
+// Only sequences of several runes are returned.
+//
+// [Segmenter.Split] uses the same logic to select the face of
+// the sequences, so that they are never split across fallback fonts.
+func EmojiSequences(text []rune) []Range {
+	var out []Range
+	for i := 0; i < len(text); {
+		end := emojiSequence(text, i, len(text))
+		if end > i+1 {
+			out = append(out, Range{Offset: i, Count: end - i})
+		}
+		if end > i {
+			i = end
+		} else {
+			i++
+		}
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/font"
+	tu "github.com/boxesandglue/typesetting/testutils"
+)
+
+func TestEmojiSequences(t *testing.T) {
+	for _, test := range []struct {
+		text     string
+		expected []Range
+	}{
+		{"abc", nil},
+		{"\U0001F600", nil}, // single rune
+		{"a\U0001F1EB\U0001F1F7b", []Range{{1, 2}}},                                                 // flag
+		{"\U0001F1EB\U0001F1F7\U0001F1E9", []Range{{0, 2}}},                                         // flag and isolated indicator
+		{"1\uFE0F\u20E3 #\u20E3 1", []Range{{0, 3}, {4, 2}}},                                        // keycaps
+		{"\U0001F44D\U0001F3FD!", []Range{{0, 2}}},                                                  // skin tone
+		{"\u2764\uFE0F", []Range{{0, 2}}},                                                           // presentation selector
+		{"\U0001F469\u200D\u2695\uFE0F", []Range{{0, 4}}},                                           // ZWJ sequence
+		{"\U0001F468\U0001F3FB\u200D\U0001F9B0 ", []Range{{0, 4}}},                                  // ZWJ sequence with modifier
+		{"\U0001F469\u200D", nil},                                                                   // dangling ZWJ
+		{"\U0001F3F4\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F", []Range{{0, 7}}}, // tag sequence
+	} {
+		got := EmojiSequences([]rune(test.text))
+		if len(got) == 0 && len(test.expected) == 0 {
+			continue
+		}
+		tu.AssertC(t, reflect.DeepEqual(got, test.expected), test.text)
+	}
+}
+
+// emojiCmap only supports the supplementary planes and the keycap mark
+type emojiCmap struct{ font.Cmap }
+
+func (emojiCmap) Lookup(r rune) (font.GID, bool) { return 0, r >= 0x1F000 || r == 0x20E3 }
+
+func TestSplitEmojiSequences(t *testing.T) {
+	emojiFont := &font.Face{Font: &font.Font{Cmap: emojiCmap{}}}
+	textFont := &font.Face{Font: &font.Font{Cmap: universalCmap{}}}
+	fm := fixedFontmap{emojiFont, textFont}
+
+	// U+2695 and the digit are supported by the text font only,
+	// but must not be split from their sequence
+	text := []rune("a\U0001F469\u200D\u2695\uFE0Fb1\uFE0F\u20E3")
+	runs := SplitByFace(Input{Text: text, RunEnd: len(text)}, fm)
+	tu.Assert(t, len(runs) == 4)
+	for i, exp := range []struct {
+		start, end int
+		face       *font.Face
+	}{
+		{0, 1, textFont},
+		{1, 5, emojiFont},
+		{5, 6, textFont},
+		{6, 9, emojiFont},
+	} {
+		tu.AssertC(t, runs[i].RunStart == exp.start && runs[i].RunEnd == exp.end, fmt.Sprint(i))
+		tu.AssertC(t, runs[i].Face == exp.face, fmt.Sprint(i))
+	}
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import "sort"
+
+// ShapeWithFallback shapes [input] with [Input.Face] as primary face, and then shapes again
+// the parts of the text this face does not support (that is, the clusters containing
+// a .notdef glyph), using the faces selected by [fallback], which is typically
+// a *fontscan.FontMap. If [fallback] implements [FontmapScript], [Input.Script] is provided.
+//
+// The returned outputs, one for each run of text sharing the same face, are in logical order
+// and cover the runes [Input.RunStart, Input.RunEnd), as done by [Segmenter.Split].
+// Each run is shaped with the whole [Input.Text] as context, so that the cluster indices
+// refer to [Input.Text], as for [HarfbuzzShaper.Shape].
+//
+// A single output is returned if the primary face supports the whole run, or if
+// [fallback] does not provide other faces for the missing text.
+func (t *HarfbuzzShaper) ShapeWithFallback(input Input, fallback Fontmap) []Output {
+	out := t.Shape(input)
+	missing := missingRanges(out.Glyphs)
+	if len(missing) == 0 {
+		return []Output{out}
+	}
+
+	if withScript, ok := fallback.(FontmapScript); ok {
+		withScript.SetScript(input.Script)
+	}
+	var runs []Input
+	addPrimary := func(start, end int) {
+		if start < end {
+			run := input
+			run.RunStart, run.RunEnd = start, end
+			runs = append(runs, run)
+		}
+	}
+	start := input.RunStart
+	for _, rg := range missing {
+		addPrimary(start, rg.Offset)
+		run := input
+		run.RunStart, run.RunEnd = rg.Offset, rg.Offset+rg.Count
+		run.Face = nil
+		runs = splitByFace(run, fallback, runs)
+		start = rg.Offset + rg.Count
+	}
+	addPrimary(start, input.RunEnd)
+
+	// the fallback may return the primary face, or the same face for
+	// two consecutive missing ranges
+	merged := runs[:1]
+	for _, run := range runs[1:] {
+		if last := &merged[len(merged)-1]; last.Face == run.Face && last.RunEnd == run.RunStart {
+			last.RunEnd = run.RunEnd
+		} else {
+			merged = append(merged, run)
+		}
+	}
+	if len(merged) == 1 && merged[0].Face == input.Face {
+		return []Output{out}
+	}
+
+	outputs := make([]Output, len(merged))
+	for i, run := range merged {
+		outputs[i] = t.Shape(run)
+	}
+	return outputs
+}
+
+// missingRanges returns the sorted, non overlapping rune ranges
+// of the clusters containing a .notdef glyph.
+func missingRanges(glyphs []Glyph) []Range {
+	var out []Range
+	for _, g := range glyphs {
+		if g.GlyphID == 0 {
+			out = append(out, Range{Offset: g.ClusterIndex, Count: g.RuneCount})
+		}
+	}
+	// glyphs are in visual order
+	sort.Slice(out, func(i, j int) bool { return out[i].Offset < out[j].Offset })
+
+	merged := out[:0]
+	for _, rg := range out {
+		if L := len(merged); L != 0 && merged[L-1].Offset+merged[L-1].Count >= rg.Offset {
+			last := &merged[L-1]
+			if end := rg.Offset + rg.Count; end > last.Offset+last.Count {
+				last.Count = end - last.Offset
+			}
+			continue
+		}
+		merged = append(merged, rg)
+	}
+	return merged
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/di"
+	"github.com/boxesandglue/typesetting/font"
+	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestShapeWithFallback(t *testing.T) {
+	latinFace := loadOpentypeFont(t, "../font/testdata/Roboto-Regular.ttf")
+	arabicFace := loadOpentypeFont(t, "../font/testdata/Amiri-Regular.ttf")
+	fallback := fixedFontmap{arabicFace, latinFace}
+
+	var shaper HarfbuzzShaper
+	text := []rune("\u0633\u0644\u0627\u0645 123 \u0628\u0628")
+	input := Input{
+		Text:      text,
+		RunStart:  0,
+		RunEnd:    len(text),
+		Direction: di.DirectionRTL,
+		Face:      latinFace,
+		Size:      fixed.I(16),
+		Script:    language.Arabic,
+		Language:  language.NewLanguage("ar"),
+	}
+
+	outputs := shaper.ShapeWithFallback(input, fallback)
+	tu.Assert(t, len(outputs) == 3)
+	expected := []struct {
+		face       *font.Face
+		start, end int
+	}{
+		{arabicFace, 0, 4},
+		{latinFace, 4, 9},
+		{arabicFace, 9, 11},
+	}
+	for i, out := range outputs {
+		exp := expected[i]
+		tu.Assert(t, out.Face == exp.face)
+		tu.Assert(t, out.Runes.Offset == exp.start && out.Runes.Count == exp.end-exp.start)
+		for _, g := range out.Glyphs {
+			tu.Assert(t, g.GlyphID != 0)
+			tu.Assert(t, exp.start <= g.ClusterIndex && g.ClusterIndex < exp.end)
+		}
+		// runs are shaped with context
+		run := input
+		run.Face, run.RunStart, run.RunEnd = exp.face, exp.start, exp.end
+		tu.Assert(t, reflect.DeepEqual(out, shaper.Shape(run)))
+	}
+
+	// the primary face supports the text
+	input.Face = arabicFace
+	outputs = shaper.ShapeWithFallback(input, fallback)
+	tu.Assert(t, len(outputs) == 1 && reflect.DeepEqual(outputs[0], shaper.Shape(input)))
+
+	// the fallback does not support the text
+	input.Face = latinFace
+	outputs = shaper.ShapeWithFallback(input, fixedFontmap{latinFace})
+	tu.Assert(t, len(outputs) == 1 && reflect.DeepEqual(outputs[0], shaper.Shape(input)))
+}
+
+func TestMissingRanges(t *testing.T) {
+	glyphs := []Glyph{
+		{GlyphID: 0, ClusterIndex: 6, RuneCount: 2},
+		{GlyphID: 3, ClusterIndex: 5, RuneCount: 1},
+		{GlyphID: 0, ClusterIndex: 3, RuneCount: 2},
+		{GlyphID: 0, ClusterIndex: 3, RuneCount: 2},
+		{GlyphID: 0, ClusterIndex: 2, RuneCount: 1},
+		{GlyphID: 4, ClusterIndex: 0, RuneCount: 2},
+	}
+	tu.Assert(t, reflect.DeepEqual(missingRanges(glyphs), []Range{{2, 3}, {6, 2}}))
+	tu.Assert(t, missingRanges(glyphs[5:]) == nil)
+}
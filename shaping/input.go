@@ -32,6 +32,12 @@ type Input struct {
 	// The settings are applied to the whole [Text].
 	FontFeatures []FontFeature
 
+	// RangedFeatures activates or deactivates font features on a sub-range
+	// of [Text] only : the Start and End fields are rune indices into [Text] (End excluded).
+	// They are applied after, and thus take precedence over, [FontFeatures].
+	// See [OrdinalFeatures] for an example of ranges computed from the text content.
+	RangedFeatures []harfbuzz.Feature
+
 	// Size is the requested size of the font.
 	// More generally, it is a scale factor applied to the resulting metrics.
 	// For instance, given a device resolution (in dpi) and a point size (like 14), the `Size` to
@@ -43,6 +49,12 @@ type Input struct {
 
 	// Language is an identifier for the language of the text.
 	Language language.Language
+
+	// SoftHyphen controls the rendering of soft hyphens (U+00AD).
+	// With [harfbuzz.SoftHyphenAtLineEnd], soft hyphens are invisible in the
+	// shaped text, but [Output.Hyphen] is set so that [LineWrapper] may display
+	// them at the end of the lines.
+	SoftHyphen harfbuzz.SoftHyphenPolicy
 }
 
 // FontFeature sets one font feature.
@@ -144,6 +156,7 @@ type delimEntry struct {
 
 // Split segments the given pre-configured input according to:
 //   - text direction
+//   - interlinear annotations (see [ParseAnnotations])
 //   - script
 //   - language
 //   - (vertical text only) glyph orientation
@@ -173,7 +186,41 @@ func (seg *Segmenter) Split(text Input, faces Fontmap) []Input {
 	seg.reset()
 	seg.splitByBidi(text) // fills output
 
-	seg.input, seg.output = seg.output, seg.input // output is empty
+	return seg.splitAfterBidi(text, faces)
+}
+
+// SplitWithLevels is the same as [Segmenter.Split], but uses the bidi embedding
+// [levels] provided by the caller, instead of resolving them from [text].
+// This is useful when the Unicode Bidirectional Algorithm has already been run
+// on the whole paragraph, of which [text] is only a part, like a line.
+//
+// [levels] must have the same length as [text.Text] : odd levels are right-to-left,
+// even levels are left-to-right, and runs are split whenever the level changes.
+// It panics otherwise.
+//
+// The returned sliced is owned by the [Segmenter] and is only valid until
+// the next call to [Split] or [SplitWithLevels].
+func (seg *Segmenter) SplitWithLevels(text Input, levels []uint8, faces Fontmap) []Input {
+	if len(levels) != len(text.Text) {
+		panic("shaping: invalid bidi levels length")
+	}
+	seg.reset()
+	seg.splitByLevels(text, levels) // fills output
+
+	return seg.splitAfterBidi(text, faces)
+}
+
+// splitAfterBidi applies the segmentation steps following the bidi one,
+// which is assumed to have filled [seg.output]
+func (seg *Segmenter) splitAfterBidi(text Input, faces Fontmap) []Input {
+	if hasAnnotationControls(text.runes()) {
+		seg.input, seg.output = seg.output, seg.input
+		seg.output = seg.output[:0]
+		seg.splitByAnnotations()
+	}
+
+	seg.input, seg.output = seg.output, seg.input
+	seg.output = seg.output[:0]
 	seg.splitByScript()
 
 	seg.enforceLanguages()
@@ -197,10 +244,12 @@ func (seg *Segmenter) reset() {
 	for i := range seg.input {
 		seg.input[i].Text = nil
 		seg.input[i].FontFeatures = nil
+		seg.input[i].RangedFeatures = nil
 	}
 	for i := range seg.output {
 		seg.output[i].Text = nil
 		seg.output[i].FontFeatures = nil
+		seg.output[i].RangedFeatures = nil
 	}
 	seg.input = seg.input[:0]
 	seg.output = seg.output[:0]
@@ -248,6 +297,53 @@ func (seg *Segmenter) splitByBidi(text Input) {
 	}
 }
 
+// splitByLevels splits [text] at each change of bidi level
+func (seg *Segmenter) splitByLevels(text Input, levels []uint8) {
+	if text.RunStart >= text.RunEnd {
+		seg.output = append(seg.output, text)
+		return
+	}
+	currentInput := text
+	for i := text.RunStart; i < text.RunEnd; i++ {
+		if i != text.RunStart && levels[i] != levels[i-1] {
+			// close the current input and start a new one
+			currentInput.RunEnd = i
+			seg.output = append(seg.output, currentInput)
+			currentInput = text
+			currentInput.RunStart = i
+		}
+		if levels[i]&1 == 1 {
+			currentInput.Direction.SetProgression(di.TowardTopLeft)
+		} else {
+			currentInput.Direction.SetProgression(di.FromTopLeft)
+		}
+	}
+	// close and add the last input
+	currentInput.RunEnd = text.RunEnd
+	seg.output = append(seg.output, currentInput)
+}
+
+// splitByAnnotations isolates the interlinear annotation characters,
+// so that base and annotating texts are never shaped in the same run
+func (seg *Segmenter) splitByAnnotations() {
+	for _, input := range seg.input {
+		currentInput := input
+		for i := input.RunStart + 1; i < input.RunEnd; i++ {
+			if isAnnotationControl(input.Text[i]) == isAnnotationControl(input.Text[i-1]) {
+				continue
+			}
+			// close the current input and start a new one
+			currentInput.RunEnd = i
+			seg.output = append(seg.output, currentInput)
+			currentInput = input
+			currentInput.RunStart = i
+		}
+		// close and add the last input
+		currentInput.RunEnd = input.RunEnd
+		seg.output = append(seg.output, currentInput)
+	}
+}
+
 // lookupDelimIndex binary searches in the list of the paired delimiters,
 // and returns -1 if `ch` is not found
 func lookupDelimIndex(ch rune) int {
@@ -409,6 +505,15 @@ func splitByFace(input Input, availableFaces Fontmap, buffer []Input) []Input {
 	currentInput := input
 	for i := input.RunStart; i < input.RunEnd; i++ {
 		r := input.Text[i]
+
+		// emoji sequences are resolved as a whole, so that
+		// they are never split across several faces
+		start := i
+		if end := emojiSequence(input.Text, i, input.RunEnd); end > i+1 {
+			r = emojiSequenceKey(input.Text[i:end])
+			i = end - 1
+		}
+
 		// We can safely ignore characters if we have a face or if there is more text,
 		// but we must force the choice of a face if we still don't have one and we reach
 		// the final rune. Otherwise strings like all-whitespace are never assigned a face.
@@ -433,16 +538,16 @@ func splitByFace(input Input, availableFaces Fontmap, buffer []Input) []Input {
 
 		// new face needed
 
-		if i != input.RunStart {
+		if start != input.RunStart {
 			// close the current input ...
-			currentInput.RunEnd = i
+			currentInput.RunEnd = start
 			// ... add it to the output ...
 			buffer = append(buffer, currentInput)
 		}
 
 		// ... and create a new one
 		currentInput = input
-		currentInput.RunStart = i
+		currentInput.RunStart = start
 		currentInput.Face = selectedFace
 	}
 
@@ -464,7 +569,8 @@ func splitByFace(input Input, availableFaces Fontmap, buffer []Input) []Input {
 // We don't want to change fonts for line or paragraph separators.
 //
 // Finaly, we also don't change fonts for what Harfbuzz consider
-// as ignorable (however, some Control Format runes like 06DD are not ignored).
+// as ignorable (however, some Control Format runes like 06DD are not ignored),
+// nor for interlinear annotation characters.
 //
 // The rationale is taken from pango : see bugs
 // https://bugzilla.gnome.org/show_bug.cgi?id=355987
@@ -477,7 +583,8 @@ func ignoreFaceChange(r rune) bool {
 		unicode.Is(unicode.Zl, r) || // line separator
 		unicode.Is(unicode.Zp, r) || // paragraph separator
 		(unicode.Is(unicode.Zs, r) && r != '\u1680') || // space separator != OGHAM SPACE MARK
-		harfbuzz.IsDefaultIgnorable(r)
+		harfbuzz.IsDefaultIgnorable(r) ||
+		isAnnotationControl(r) // hidden by the shaper
 }
 
 // enforceLang makes sure the returned language is compatible with
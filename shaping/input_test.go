@@ -648,6 +648,53 @@ func TestSplit(t *testing.T) {
 	tu.Assert(t, inputs[0].Language == "ar")
 }
 
+func TestSplitWithLevels(t *testing.T) {
+	latinFont := loadOpentypeFont(t, "../font/testdata/Roboto-Regular.ttf")
+	arabicFont := loadOpentypeFont(t, "../font/testdata/Amiri-Regular.ttf")
+	fm := fixedFontmap{latinFont, arabicFont}
+
+	var seg Segmenter
+
+	// the first line of a RTL paragraph, as resolved by the caller
+	text := []rune("DUMMY" + "abc \u0633\u0645\u0627\u0621 123" + "DUMMY")
+	levels := make([]uint8, len(text))
+	for i := range levels {
+		levels[i] = 1
+	}
+	for _, i := range []int{5, 6, 7, 8, 14, 15, 16} {
+		levels[i] = 2
+	}
+
+	type run struct {
+		start, end int
+		dir        di.Direction
+		script     language.Script
+		face       *font.Face
+	}
+	inputs := seg.SplitWithLevels(Input{
+		Text:      text,
+		RunStart:  5,
+		RunEnd:    len(text) - 5,
+		Direction: di.DirectionRTL,
+		Size:      10,
+	}, levels, fm)
+	expected := []run{
+		{5, 9, di.DirectionLTR, language.Latin, latinFont},
+		{9, 14, di.DirectionRTL, language.Arabic, arabicFont},
+		{14, 17, di.DirectionLTR, language.Common, latinFont},
+	}
+	tu.Assert(t, len(inputs) == len(expected))
+	for i, run := range expected {
+		got := inputs[i]
+		tu.Assert(t, got.RunStart == run.start)
+		tu.Assert(t, got.RunEnd == run.end)
+		tu.Assert(t, got.Direction == run.dir)
+		tu.Assert(t, got.Script == run.script)
+		tu.Assert(t, got.Face == run.face)
+		tu.Assert(t, got.Size == 10)
+	}
+}
+
 func TestIssue127(t *testing.T) {
 	// regression test for https://github.com/boxesandglue/typesetting/issues/127
 	str := []rune("لمّا")
@@ -0,0 +1,396 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	"sort"
+	"unicode"
+
+	"github.com/boxesandglue/typesetting/di"
+	"github.com/boxesandglue/typesetting/harfbuzz"
+	"github.com/boxesandglue/typesetting/language"
+	ucd "github.com/boxesandglue/typesetting/unicodedata"
+	"golang.org/x/image/math/fixed"
+)
+
+const tatweel = 0x0640
+
+// KashidaPriority ranks the sites where an Arabic word may be elongated
+// with kashidas (tatweels), following the usual typographic rules.
+// Lower values are preferred.
+type KashidaPriority uint8
+
+const (
+	// KashidaAfterTatweel is after a tatweel already present in the text.
+	KashidaAfterTatweel KashidaPriority = iota
+	// KashidaAfterSeen is after the initial or medial form of Seen, Sheen, Sad or Dad.
+	KashidaAfterSeen
+	// KashidaBeforeFinalHeh is before the final form of Heh, Teh Marbuta or Dal.
+	KashidaBeforeFinalHeh
+	// KashidaBeforeFinalAlef is before the final form of Alef, Tah, Lam, Kaf or Gaf.
+	KashidaBeforeFinalAlef
+	// KashidaBeforeMedialBeh is before the medial form of Beh (and similar letters),
+	// when followed by the final form of Reh, Yeh or Alef Maksura.
+	KashidaBeforeMedialBeh
+	// KashidaBeforeFinalWaw is before the final form of Waw, Ain, Qaf or Feh.
+	KashidaBeforeFinalWaw
+	// KashidaBeforeFinal is before the final form of any other letter.
+	KashidaBeforeFinal
+)
+
+// maximum number of tatweels recommended for each priority :
+// the preferred sites accept the longest elongations
+var kashidaMaxTatweels = [...]int{
+	KashidaAfterTatweel:    4,
+	KashidaAfterSeen:       4,
+	KashidaBeforeFinalHeh:  3,
+	KashidaBeforeFinalAlef: 2,
+	KashidaBeforeMedialBeh: 2,
+	KashidaBeforeFinalWaw:  2,
+	KashidaBeforeFinal:     1,
+}
+
+// KashidaCandidate is a site where a word may be elongated.
+type KashidaCandidate struct {
+	// RuneIndex is the index (in the input text) of the rune
+	// after which the elongation should be inserted.
+	RuneIndex int
+	// GlyphIndex is the index in [Output.Glyphs] of the glyph
+	// rendering [RuneIndex].
+	GlyphIndex int
+	// MaxWidth is the maximum recommended elongation at this site,
+	// computed from the advance of the tatweel glyph of the font.
+	MaxWidth fixed.Int26_6
+	Priority KashidaPriority
+}
+
+// KashidaCandidates returns the preferred elongation site of each Arabic word of [run],
+// for Arabic justification, ignoring the sites whose priority is greater than [maxPriority].
+// [text] is the input slice used to create the run.
+//
+// Following the usual typographic rules, only one site is returned per word : the one with
+// the best priority, or the last one in logical order if several sites have the same priority.
+// The returned slice is sorted by priority, then by logical order.
+//
+// The maximum widths are computed from the advance of the tatweel glyph, which is shaped
+// with the font of [run] (fonts often only provide the actual tatweel glyph with
+// a substitution).
+//
+// Sites inside a ligature are never returned. Nil is returned for vertical runs,
+// or if the font has no tatweel glyph.
+func (t *HarfbuzzShaper) KashidaCandidates(run Output, text []rune, maxPriority KashidaPriority) []KashidaCandidate {
+	shapedTatweel, ok := t.shapeTatweel(run)
+	if !ok {
+		return nil
+	}
+	return run.kashidaCandidates(text, maxPriority, shapedTatweel.Advance)
+}
+
+// shapeTatweel shapes a tatweel with the font of [run], returning false
+// for vertical runs, or if the font has no tatweel glyph.
+func (t *HarfbuzzShaper) shapeTatweel(run Output) (Output, bool) {
+	if run.Direction.IsVertical() || run.Face == nil {
+		return Output{}, false
+	}
+	if _, ok := run.Face.NominalGlyph(tatweel); !ok {
+		return Output{}, false
+	}
+	tatweelText := []rune{tatweel}
+	shapedTatweel := t.Shape(Input{
+		Text:      tatweelText,
+		RunStart:  0,
+		RunEnd:    1,
+		Direction: di.DirectionRTL,
+		Face:      run.Face,
+		Size:      run.Size,
+		Script:    language.Arabic,
+	})
+	return shapedTatweel, shapedTatweel.Advance > 0
+}
+
+// JustifyWithKashidas shapes [input], elongating its Arabic words with kashidas (tatweels),
+// so that its advance grows by at most [extra] : this is the usual way of justifying Arabic text.
+//
+// The elongation sites are the ones returned by [HarfbuzzShaper.KashidaCandidates] (for [maxPriority]),
+// restricted to the sites where inserting a tatweel does not modify the shaping of the surrounding
+// letters (see [harfbuzz.GlyphSafeToInsertTatweel]). The tatweels are distributed one at a time over
+// these sites, by priority, without exceeding their maximum recommended width.
+//
+// Since the sites are safe, the shaped tatweel glyph is directly inserted in the returned run, after
+// the glyphs of the letter preceding the site, and in the same cluster, so that the run still maps to the
+// runes of [input].
+//
+// The width actually added is returned : it is a multiple of the advance of the tatweel,
+// and the remaining space should be distributed by other means, such as inter-word spacing.
+// If the run can't be elongated (vertical text, font without tatweel, no suitable site),
+// it is returned as shaped by [HarfbuzzShaper.Shape], with a zero width.
+func (t *HarfbuzzShaper) JustifyWithKashidas(input Input, extra fixed.Int26_6, maxPriority KashidaPriority) (Output, fixed.Int26_6) {
+	run := t.shape(input, harfbuzz.ProduceSafeToInsertTatweel)
+	if extra <= 0 {
+		return run, 0
+	}
+	shapedTatweel, ok := t.shapeTatweel(run)
+	if !ok {
+		return run, 0
+	}
+	advance := shapedTatweel.Advance
+
+	// only keep the sites which are safe to elongate
+	candidates := run.kashidaCandidates(input.Text, maxPriority, advance)
+	sites := candidates[:0]
+	for _, c := range candidates {
+		if run.Glyphs[c.GlyphIndex].Mask&harfbuzz.GlyphSafeToInsertTatweel != 0 {
+			sites = append(sites, c)
+		}
+	}
+
+	// distribute the tatweels
+	counts := make([]int, len(sites))
+	remaining, total := int(extra/advance), 0
+	for remaining > 0 {
+		added := false
+		for i, c := range sites {
+			if remaining == 0 {
+				break
+			}
+			if fixed.Int26_6(counts[i]+1)*advance > c.MaxWidth {
+				continue
+			}
+			counts[i]++
+			remaining--
+			total++
+			added = true
+		}
+		if !added { // all the sites are full
+			break
+		}
+	}
+	if total == 0 {
+		return run, 0
+	}
+
+	// glyph index -> number of tatweels to insert before it
+	insertions := map[int]int{}
+	towardLeft := run.Direction.Progression() == di.TowardTopLeft
+	for i, c := range sites {
+		if counts[i] == 0 {
+			continue
+		}
+		// find the glyphs of the cluster : the tatweels are inserted after them,
+		// in logical order
+		cluster := run.Glyphs[c.GlyphIndex].ClusterIndex
+		start, end := c.GlyphIndex, c.GlyphIndex+1
+		for start > 0 && run.Glyphs[start-1].ClusterIndex == cluster {
+			start--
+		}
+		for end < len(run.Glyphs) && run.Glyphs[end].ClusterIndex == cluster {
+			end++
+		}
+		if towardLeft {
+			insertions[start] = counts[i]
+		} else {
+			insertions[end] = counts[i]
+		}
+	}
+
+	glyphs := make([]Glyph, 0, len(run.Glyphs)+total*len(shapedTatweel.Glyphs))
+	for i := 0; i <= len(run.Glyphs); i++ {
+		if n := insertions[i]; n != 0 {
+			// the cluster is the one of the glyph before the insertion, in logical order
+			cluster := run.Glyphs[i-1].ClusterIndex
+			if towardLeft {
+				cluster = run.Glyphs[i].ClusterIndex
+			}
+			for ; n > 0; n-- {
+				for _, g := range shapedTatweel.Glyphs {
+					g.ClusterIndex = cluster
+					glyphs = append(glyphs, g)
+				}
+			}
+		}
+		if i < len(run.Glyphs) {
+			glyphs = append(glyphs, run.Glyphs[i])
+		}
+	}
+	countClusters(glyphs, run.Runes.Offset+run.Runes.Count, run.Direction.Progression())
+
+	run.Glyphs = glyphs
+	run.RecalculateAll()
+	return run, fixed.Int26_6(total) * advance
+}
+
+func (o *Output) kashidaCandidates(text []rune, maxPriority KashidaPriority, tatweelAdvance fixed.Int26_6) []KashidaCandidate {
+	start, end := o.Runes.Offset, o.Runes.Offset+o.Runes.Count
+	if end > len(text) {
+		return nil
+	}
+
+	// rune index -> glyph index of its cluster
+	glyphs := make([]int, o.Runes.Count)
+	for i := range glyphs {
+		glyphs[i] = -1
+	}
+	for i, g := range o.Glyphs {
+		for r := g.ClusterIndex; r < g.ClusterIndex+g.RuneCount; r++ {
+			// prefer the base glyph to the marks of the cluster
+			if r-start >= 0 && r-start < len(glyphs) && (glyphs[r-start] == -1 || g.XAdvance != 0) {
+				glyphs[r-start] = i
+			}
+		}
+	}
+
+	var (
+		out  []KashidaCandidate
+		best = -1 // index into out of the current word candidate, or -1
+	)
+	addSite := func(runeIndex int, priority KashidaPriority) {
+		if priority > maxPriority {
+			return
+		}
+		candidate := KashidaCandidate{
+			RuneIndex:  runeIndex,
+			GlyphIndex: glyphs[runeIndex-start],
+			MaxWidth:   tatweelAdvance * fixed.Int26_6(kashidaMaxTatweels[priority]),
+			Priority:   priority,
+		}
+		if best == -1 {
+			best = len(out)
+			out = append(out, candidate)
+		} else if priority <= out[best].Priority {
+			out[best] = candidate
+		}
+	}
+
+	// returns false if the runes are rendered by the same glyphs,
+	// for instance in ligatures
+	areSeparated := func(r1, r2 int) bool {
+		g1, g2 := glyphs[r1-start], glyphs[r2-start]
+		return g1 != -1 && g2 != -1 && o.Glyphs[g1].ClusterIndex != o.Glyphs[g2].ClusterIndex
+	}
+
+	// iterate over the pairs of joined letters (skipping the transparent runes)
+	prev := -1 // the previous non transparent rune, or -1
+	for i := start; i < end; i++ {
+		r := text[i]
+		jt := harfbuzz.ArabicJoiningType(r)
+		if jt == ucd.T {
+			continue
+		}
+		if !isArabicWordRune(r) {
+			best = -1 // new word
+		}
+		if prev != -1 && joinsLeft(harfbuzz.ArabicJoiningType(text[prev])) && joinsRight(jt) && areSeparated(prev, i) {
+			if priority, ok := kashidaPriority(text, prev, i, end); ok {
+				site := prev
+				if priority == KashidaBeforeMedialBeh {
+					// the elongation is inserted before the Beh
+					if site = previousJoined(text, prev, start); site != -1 && !areSeparated(site, prev) {
+						site = -1
+					}
+				}
+				if site != -1 {
+					addSite(site, priority)
+				}
+			}
+		}
+		prev = i
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Priority < out[j].Priority })
+	return out
+}
+
+// kashidaPriority returns the priority of the site between the joined
+// letters text[prev] and text[next]
+func kashidaPriority(text []rune, prev, next, end int) (KashidaPriority, bool) {
+	r, n := text[prev], text[next]
+	if r == tatweel {
+		return KashidaAfterTatweel, true
+	}
+	if isSeenLike(r) {
+		return KashidaAfterSeen, true
+	}
+	if n == tatweel {
+		return 0, false
+	}
+	// the following sites are only used before a final form
+	if following := nextNonTransparent(text, next, end); following != -1 &&
+		joinsLeft(harfbuzz.ArabicJoiningType(n)) && joinsRight(harfbuzz.ArabicJoiningType(text[following])) {
+		return 0, false
+	}
+	switch n {
+	case 0x0647, 0x0629, 0x062F, 0x0630, 0x06C1, 0x06D5: // Heh, Teh Marbuta, Dal, Thal
+		return KashidaBeforeFinalHeh, true
+	case 0x0627, 0x0622, 0x0623, 0x0625, 0x0671, // Alef
+		0x0637, 0x0638, // Tah, Zah
+		0x0644,                 // Lam
+		0x0643, 0x06A9, 0x06AF: // Kaf, Keheh, Gaf
+		return KashidaBeforeFinalAlef, true
+	case 0x0631, 0x0632, 0x0698, // Reh, Zain, Jeh
+		0x064A, 0x06CC, 0x0649: // Yeh, Farsi Yeh, Alef Maksura
+		if isBehLike(r) {
+			return KashidaBeforeMedialBeh, true
+		}
+		return KashidaBeforeFinal, true
+	case 0x0648, 0x0624, // Waw
+		0x0639, 0x063A, // Ain, Ghain
+		0x0642, 0x0641, 0x06A4: // Qaf, Feh, Veh
+		return KashidaBeforeFinalWaw, true
+	default:
+		return KashidaBeforeFinal, true
+	}
+}
+
+// Seen, Sheen, Sad, Dad and their variants
+func isSeenLike(r rune) bool {
+	switch r {
+	case 0x0633, 0x0634, 0x0635, 0x0636, 0x069A, 0x069B, 0x069C, 0x069D, 0x069E, 0x06FA, 0x06FB:
+		return true
+	}
+	return false
+}
+
+// Beh, Teh, Theh, Noon, Yeh, Peh and their variants (medial forms look alike)
+func isBehLike(r rune) bool {
+	switch r {
+	case 0x0628, 0x062A, 0x062B, 0x0646, 0x064A, 0x06CC, 0x067E, 0x0679, 0x06BA:
+		return true
+	}
+	return false
+}
+
+// returns true for letters connecting to the following letter
+func joinsLeft(jt ucd.ArabicJoining) bool { return jt == ucd.D || jt == ucd.L || jt == ucd.C }
+
+// returns true for letters connecting to the preceding letter
+func joinsRight(jt ucd.ArabicJoining) bool {
+	return jt == ucd.D || jt == ucd.R || jt == ucd.C || jt == ucd.Alaph || jt == ucd.DalathRish
+}
+
+func isArabicWordRune(r rune) bool { return unicode.IsLetter(r) || unicode.IsMark(r) || r == tatweel }
+
+// nextNonTransparent returns the index of the first non transparent rune after [i], or -1
+func nextNonTransparent(text []rune, i, end int) int {
+	for i++; i < end; i++ {
+		if harfbuzz.ArabicJoiningType(text[i]) != ucd.T {
+			return i
+		}
+	}
+	return -1
+}
+
+// previousJoined returns the index of the letter joined before
+// text[i], or -1
+func previousJoined(text []rune, i, start int) int {
+	for j := i - 1; j >= start; j-- {
+		jt := harfbuzz.ArabicJoiningType(text[j])
+		if jt == ucd.T {
+			continue
+		}
+		if joinsLeft(jt) {
+			return j
+		}
+		return -1
+	}
+	return -1
+}
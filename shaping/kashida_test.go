@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	"testing"
+
+	"github.com/boxesandglue/typesetting/di"
+	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestKashidaCandidates(t *testing.T) {
+	face := loadOpentypeFont(t, "../font/testdata/Amiri-Regular.ttf")
+	var shaper HarfbuzzShaper
+
+	shape := func(text []rune, dir di.Direction) Output {
+		return shaper.Shape(Input{
+			Text:      text,
+			RunStart:  0,
+			RunEnd:    len(text),
+			Direction: dir,
+			Face:      face,
+			Size:      fixed.I(20),
+			Script:    language.Arabic,
+			Language:  language.NewLanguage("ar"),
+		})
+	}
+
+	// Amiri only provides the actual tatweel glyph with a substitution
+	tatweelAdvance := shape([]rune{tatweel}, di.DirectionRTL).Advance
+	tu.Assert(t, tatweelAdvance > 0)
+
+	text := []rune("سلام كتاب بيت كبير مدرسة كـتب")
+	out := shape(text, di.DirectionRTL)
+
+	got := shaper.KashidaCandidates(out, text, KashidaBeforeFinal)
+	expected := []struct {
+		runeIndex int
+		priority  KashidaPriority
+	}{
+		{26, KashidaAfterTatweel},
+		{0, KashidaAfterSeen},
+		{22, KashidaAfterSeen},
+		{6, KashidaBeforeFinalAlef},
+		{15, KashidaBeforeMedialBeh},
+		{11, KashidaBeforeFinal},
+	}
+	tu.Assert(t, len(got) == len(expected))
+	for i, exp := range expected {
+		c := got[i]
+		tu.AssertC(t, c.RuneIndex == exp.runeIndex && c.Priority == exp.priority, string(text[c.RuneIndex]))
+		tu.Assert(t, out.Glyphs[c.GlyphIndex].ClusterIndex == c.RuneIndex)
+		tu.Assert(t, c.MaxWidth == tatweelAdvance*fixed.Int26_6(kashidaMaxTatweels[c.Priority]))
+	}
+
+	// lower quality levels only use the preferred sites
+	got = shaper.KashidaCandidates(out, text, KashidaAfterSeen)
+	tu.Assert(t, len(got) == 3)
+	for _, c := range got {
+		tu.Assert(t, c.Priority <= KashidaAfterSeen)
+	}
+
+	// the input run is not modified by the measure of the tatweel
+	tu.Assert(t, out.Glyphs[0].ClusterIndex == len(text)-1)
+
+	// vertical text is not supported
+	vert := shape(text, di.DirectionTTB)
+	tu.Assert(t, shaper.KashidaCandidates(vert, text, KashidaBeforeFinal) == nil)
+}
+
+func TestJustifyWithKashidas(t *testing.T) {
+	face := loadOpentypeFont(t, "../font/testdata/Amiri-Regular.ttf")
+	var shaper HarfbuzzShaper
+
+	text := []rune("سلام كتاب بيت كبير مدرسة كـتب")
+	input := Input{
+		Text:      text,
+		RunStart:  0,
+		RunEnd:    len(text),
+		Direction: di.DirectionRTL,
+		Face:      face,
+		Size:      fixed.I(20),
+		Script:    language.Arabic,
+		Language:  language.NewLanguage("ar"),
+	}
+	out := shaper.Shape(input)
+	tatweel, ok := shaper.shapeTatweel(out)
+	tu.Assert(t, ok)
+	advance := tatweel.Advance
+
+	// nothing to do
+	got, added := shaper.JustifyWithKashidas(input, 0, KashidaBeforeFinal)
+	tu.Assert(t, added == 0 && got.Advance == out.Advance)
+
+	for _, extra := range []fixed.Int26_6{advance / 2, advance * 3, advance*5 + advance/2, fixed.I(1000)} {
+		got, added := shaper.JustifyWithKashidas(input, extra, KashidaBeforeFinal)
+		tu.Assert(t, added <= extra && added%advance == 0)
+		tu.Assert(t, got.Advance == out.Advance+added)
+		tu.Assert(t, got.Runes == out.Runes)
+		tu.Assert(t, len(got.Glyphs) == len(out.Glyphs)+int(added/advance)*len(tatweel.Glyphs))
+
+		// glyphs are still in visual order, and the clusters cover the whole text
+		for i := 1; i < len(got.Glyphs); i++ {
+			tu.Assert(t, got.Glyphs[i].ClusterIndex <= got.Glyphs[i-1].ClusterIndex)
+		}
+		runes := 0
+		for i := 0; i < len(got.Glyphs); i += got.Glyphs[i].GlyphCount {
+			runes += got.Glyphs[i].RuneCount
+		}
+		tu.Assert(t, runes == len(text))
+	}
+
+	// one tatweel is used on the preferred site, after the existing tatweel
+	countCluster := func(run Output, cluster int) (n int) {
+		for _, g := range run.Glyphs {
+			if g.ClusterIndex == cluster {
+				n++
+			}
+		}
+		return n
+	}
+	got, added = shaper.JustifyWithKashidas(input, advance, KashidaBeforeFinal)
+	tu.Assert(t, added == advance)
+	tu.Assert(t, countCluster(got, 26) == countCluster(out, 26)+len(tatweel.Glyphs))
+
+	// the sites are limited : the width added is bounded
+	_, added = shaper.JustifyWithKashidas(input, fixed.I(1000), KashidaBeforeFinal)
+	var max fixed.Int26_6
+	for _, c := range shaper.KashidaCandidates(out, text, KashidaBeforeFinal) {
+		max += c.MaxWidth
+	}
+	tu.Assert(t, added > 0 && added <= max)
+
+	// vertical text is not supported
+	input.Direction = di.DirectionTTB
+	_, added = shaper.JustifyWithKashidas(input, fixed.I(100), KashidaBeforeFinal)
+	tu.Assert(t, added == 0)
+}
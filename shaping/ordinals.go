@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	"unicode"
+
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/harfbuzz"
+	"github.com/boxesandglue/typesetting/language"
+)
+
+// ordinalSuffixes lists, for each primary language, the (lower case)
+// suffixes which, following a number, form an ordinal.
+// Suffixes are tried in order, so that longer ones must come first.
+var ordinalSuffixes = map[language.Language][]string{
+	"en": {"st", "nd", "rd", "th"},
+	"fr": {"ères", "èmes", "ère", "ème", "ers", "res", "nds", "ndes", "nde", "er", "re", "es", "nd", "d", "e"},
+	"nl": {"ste", "de", "e"},
+	"es": {"os", "as", "o", "a"},
+	"pt": {"os", "as", "o", "a"},
+	"it": {"os", "as", "o", "a"},
+	"gl": {"os", "as", "o", "a"},
+	"ca": {"rs", "ns", "ts", "es", "r", "n", "t", "a", "e"},
+}
+
+// languages where a dot may separate the number from its suffix, like in "1.º"
+var ordinalDotLanguages = map[language.Language]bool{
+	"es": true, "pt": true, "it": true, "gl": true,
+}
+
+// ordinal indicators always accepted as suffix, whatever the language
+const (
+	feminineOrdinalIndicator  = 'ª'
+	masculineOrdinalIndicator = 'º'
+	degreeSign                = '°'
+)
+
+// OrdinalFeatures scans [text] for ordinal patterns, such as "1st" (English),
+// "2e" (French), "3º" (Spanish) or the numero abbreviation "N° 5", and returns
+// one 'ordn' feature for each suffix found, restricted to the runes of the suffix.
+//
+// Only the primary part of [lang] is used to select the suffixes; an empty language
+// is treated as English and languages without known ordinal suffixes only
+// detect the numero abbreviation and the ordinal indicators (º and ª).
+//
+// The returned ranges are rune indices into [text], so they may be used directly
+// as [Input.RangedFeatures] when [text] is the [Input.Text] being shaped.
+// Fonts without 'ordn' support may use the 'sups' feature instead, by
+// updating the Tag of the returned features.
+func OrdinalFeatures(text []rune, lang language.Language) []harfbuzz.Feature {
+	primary := lang.Primary()
+	if primary == "" {
+		primary = "en"
+	}
+	suffixes := ordinalSuffixes[primary]
+	allowDot := ordinalDotLanguages[primary]
+
+	var out []harfbuzz.Feature
+	for i := 0; i < len(text); {
+		if start, end, ok := matchNumero(text, i); ok {
+			out = append(out, ordinalFeature(start, end))
+			i = end
+			continue
+		}
+
+		if !unicode.IsDigit(text[i]) || (i > 0 && isWordRune(text[i-1])) {
+			i++
+			continue
+		}
+		// consume the number
+		j := i
+		for j < len(text) && unicode.IsDigit(text[j]) {
+			j++
+		}
+		start := j
+		if allowDot && start+1 < len(text) && text[start] == '.' {
+			start++
+		}
+		if end, ok := matchOrdinalSuffix(text, start, suffixes); ok {
+			out = append(out, ordinalFeature(start, end))
+			j = end
+		}
+		i = j
+	}
+	return out
+}
+
+func ordinalFeature(start, end int) harfbuzz.Feature {
+	return harfbuzz.Feature{Tag: ot.MustNewTag("ordn"), Value: 1, Start: start, End: end}
+}
+
+func isWordRune(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+// matchOrdinalSuffix returns the end of the suffix starting at [start],
+// which must be followed by a word boundary
+func matchOrdinalSuffix(text []rune, start int, suffixes []string) (int, bool) {
+	if start >= len(text) {
+		return 0, false
+	}
+	if r := text[start]; r == feminineOrdinalIndicator || r == masculineOrdinalIndicator {
+		return start + 1, true
+	}
+	for _, suffix := range suffixes {
+		end := start
+		matched := true
+		for _, r := range suffix {
+			if end >= len(text) || text[end] != r {
+				matched = false
+				break
+			}
+			end++
+		}
+		if matched && (end == len(text) || !isWordRune(text[end])) {
+			return end, true
+		}
+	}
+	return 0, false
+}
+
+// matchNumero detects the "No", "N°" and "Nº" abbreviations (and their
+// lower case variants), followed by an optional space and a number,
+// returning the range of the indicator
+func matchNumero(text []rune, i int) (start, end int, ok bool) {
+	if r := text[i]; r != 'N' && r != 'n' {
+		return 0, 0, false
+	}
+	if i > 0 && isWordRune(text[i-1]) {
+		return 0, 0, false
+	}
+	if i+2 >= len(text) {
+		return 0, 0, false
+	}
+	switch text[i+1] {
+	case 'o', degreeSign, masculineOrdinalIndicator:
+	default:
+		return 0, 0, false
+	}
+	next := i + 2
+	if text[next] == '.' {
+		next++
+	}
+	if next < len(text) && (text[next] == ' ' || text[next] == '\u00A0') {
+		next++
+	}
+	if next >= len(text) || !unicode.IsDigit(text[next]) {
+		return 0, 0, false
+	}
+	return i + 1, i + 2, true
+}
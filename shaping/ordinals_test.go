@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/di"
+	"github.com/boxesandglue/typesetting/font"
+	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
+	td "github.com/go-text/typesetting-utils/opentype"
+)
+
+func TestOrdinalFeatures(t *testing.T) {
+	type rng struct{ start, end int }
+	for _, test := range []struct {
+		text     string
+		lang     language.Language
+		expected []rng
+	}{
+		{"the 1st and 22nd, 3rd or 4th", "en", []rng{{5, 7}, {14, 16}, {19, 21}, {26, 28}}},
+		{"the 1st", "", []rng{{5, 7}}},
+		{"1stly a21st 12", "en", nil},
+		{"le 1er et la 2e, 1re", "fr-be", []rng{{4, 6}, {14, 15}, {18, 20}}},
+		{"le 2ème étage", "fr", []rng{{4, 7}}},
+		{"el 1.º y la 2ª", "es", []rng{{5, 6}, {13, 14}}},
+		{"el 1o y la 2a", "es", []rng{{4, 5}, {12, 13}}},
+		{"N° 5 and No. 12, Nope 3", "en", []rng{{1, 2}, {10, 11}}},
+		{"1. Januar", "de", nil},
+		{"3rd", "de", nil},
+	} {
+		got := OrdinalFeatures([]rune(test.text), test.lang)
+		var ranges []rng
+		for _, f := range got {
+			tu.Assert(t, f.Tag == ordinalFeature(0, 0).Tag && f.Value == 1)
+			ranges = append(ranges, rng{f.Start, f.End})
+		}
+		if !reflect.DeepEqual(ranges, test.expected) {
+			t.Errorf("for %q (%s), expected %v, got %v", test.text, test.lang, test.expected, ranges)
+		}
+	}
+}
+
+func TestRangedFeatures(t *testing.T) {
+	r, _ := td.Files.ReadFile("common/Raleway-v4020-Regular.otf")
+	face, err := font.ParseTTF(bytes.NewReader(r))
+	tu.AssertNoErr(t, err)
+
+	text := []rune("1a 1a")
+	input := Input{
+		Text:      text,
+		RunStart:  0,
+		RunEnd:    len(text),
+		Direction: di.DirectionLTR,
+		Face:      face,
+		Size:      16 * 72,
+		Script:    language.Latin,
+		Language:  language.NewLanguage("es"),
+	}
+	shaper := HarfbuzzShaper{}
+	plain := shaper.Shape(input)
+
+	// only apply 'ordn' on the first ordinal
+	input.RangedFeatures = OrdinalFeatures(text, input.Language)[:1]
+	tu.Assert(t, len(input.RangedFeatures) == 1)
+	out := shaper.Shape(input)
+	tu.Assert(t, len(out.Glyphs) == len(plain.Glyphs))
+	for i, g := range out.Glyphs {
+		changed := g.GlyphID != plain.Glyphs[i].GlyphID
+		tu.AssertC(t, changed == (i == 1), fmt.Sprintf("unexpected glyph %d", i))
+	}
+}
@@ -3,6 +3,10 @@
 package shaping
 
 import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+
 	"github.com/boxesandglue/typesetting/di"
 	"github.com/boxesandglue/typesetting/font"
 	"golang.org/x/image/math/fixed"
@@ -151,6 +155,12 @@ type Output struct {
 	// 0 indicates the leftmost run and increasing values move to the right. This is
 	// useful for sorting the runs for drawing purposes.
 	VisualIndex int32
+
+	// Hyphen is the glyph displayed at the end of a line broken after
+	// a soft hyphen (U+00AD). It is only set (with a non zero GlyphID) when
+	// the input is shaped with [harfbuzz.SoftHyphenAtLineEnd] and contains soft hyphens.
+	// Its ClusterIndex, RuneCount and GlyphCount fields are not meaningful.
+	Hyphen Glyph
 }
 
 // ToFontUnit converts a metrics (typically found in [Glyph] fields)
@@ -181,6 +191,31 @@ func (o *Output) RecomputeAdvance() {
 	o.Advance = advance
 }
 
+// RemapGlyphs replaces the glyph IDs of the run (including [Output.Hyphen]) using [mapping], which
+// maps the glyph IDs of [Output.Face] to new glyph IDs, typically the ones of a subset of this face,
+// built once the text is shaped. The metrics, clusters and masks of the glyphs are not modified,
+// so that the run may be rendered with the new font without shaping it again.
+//
+// If a glyph is missing from [mapping], an error is returned and the run is not modified.
+func (o *Output) RemapGlyphs(mapping map[font.GID]font.GID) error {
+	hasHyphen := o.Hyphen.GlyphID != 0
+	for _, g := range o.Glyphs {
+		if _, ok := mapping[g.GlyphID]; !ok {
+			return fmt.Errorf("glyph %d is not remapped", g.GlyphID)
+		}
+	}
+	if _, ok := mapping[o.Hyphen.GlyphID]; hasHyphen && !ok {
+		return fmt.Errorf("hyphen glyph %d is not remapped", o.Hyphen.GlyphID)
+	}
+	for i, g := range o.Glyphs {
+		o.Glyphs[i].GlyphID = mapping[g.GlyphID]
+	}
+	if hasHyphen {
+		o.Hyphen.GlyphID = mapping[o.Hyphen.GlyphID]
+	}
+	return nil
+}
+
 // advanceSpaceAware adjust the value in [Advance]
 // if a white space character ends the run.
 // Any end letter spacing (on the last glyph) is also removed
@@ -259,6 +294,24 @@ func (o *Output) RecalculateAll() {
 	}
 }
 
+// withHyphen returns a copy of [o] where the (invisible) glyph of the soft hyphen
+// at [runeIndex] is replaced by [o.Hyphen].
+// The glyphs are copied so that [o] is not modified.
+func (o Output) withHyphen(runeIndex int) Output {
+	for i, g := range o.Glyphs {
+		if g.ClusterIndex != runeIndex {
+			continue
+		}
+		hyphen := o.Hyphen
+		hyphen.ClusterIndex, hyphen.RuneCount, hyphen.GlyphCount = g.ClusterIndex, g.RuneCount, g.GlyphCount
+		o.Glyphs = append([]Glyph(nil), o.Glyphs...)
+		o.Glyphs[i] = hyphen
+		o.RecalculateAll()
+		break
+	}
+	return o
+}
+
 // Assuming [Glyphs] comes from an horizontal shaping,
 // applies a 90°, clockwise rotation to the whole slice of glyphs,
 // to create 'sideways' vertical text.
@@ -269,25 +322,34 @@ func (o *Output) RecalculateAll() {
 // [RecalculateAll] should be called afterwards to update [Avance] and [GlyphBounds].
 func (out *Output) sideways() {
 	for i, g := range out.Glyphs {
-		// switch height and width
-		out.Glyphs[i].Width = -g.Height // height is negative
-		out.Glyphs[i].Height = -g.Width
-		// compute the bearings
-		out.Glyphs[i].XBearing = g.YBearing + g.Height
-		out.Glyphs[i].YBearing = g.Width
-		// switch advance direction
-		out.Glyphs[i].XAdvance = 0
-		out.Glyphs[i].YAdvance = -g.XAdvance // YAdvance is negative
-		// apply a rotation around the dot, and position the glyph
-		// below the dot
-		out.Glyphs[i].XOffset = g.YOffset
-		out.Glyphs[i].YOffset = -(g.XOffset + g.XBearing + g.Width)
+		out.Glyphs[i] = g.sideways()
+	}
+	if out.Hyphen.GlyphID != 0 {
+		out.Hyphen = out.Hyphen.sideways()
 	}
 
 	// adjust direction
 	out.Direction.SetSideways(true)
 }
 
+func (g Glyph) sideways() Glyph {
+	out := g
+	// switch height and width
+	out.Width = -g.Height // height is negative
+	out.Height = -g.Width
+	// compute the bearings
+	out.XBearing = g.YBearing + g.Height
+	out.YBearing = g.Width
+	// switch advance direction
+	out.XAdvance = 0
+	out.YAdvance = -g.XAdvance // YAdvance is negative
+	// apply a rotation around the dot, and position the glyph
+	// below the dot
+	out.XOffset = g.YOffset
+	out.YOffset = -(g.XOffset + g.XBearing + g.Width)
+	return out
+}
+
 // properly update [GlyphBounds]
 func (out *Output) moveCrossAxis(d fixed.Int26_6) {
 	if out.Direction.IsVertical() {
@@ -303,6 +365,53 @@ func (out *Output) moveCrossAxis(d fixed.Int26_6) {
 	out.GlyphBounds.Descent += d
 }
 
+// Hash returns a digest of the shaped content of the run: its direction, size,
+// rune range, advance, line bounds, and for each glyph (including [Output.Hyphen]),
+// its ID, cluster and positions.
+//
+// The digest only depends on the (integer) values of these fields, so that
+// it is stable across processes, platforms and versions of this package,
+// and may be used as a cache key, or to check that a document layout is reproducible.
+//
+// Note that [Output.Face], [Output.VisualIndex] and [Glyph.Mask] are not
+// included in the digest.
+func (o *Output) Hash() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	write := func(v int64) {
+		binary.LittleEndian.PutUint64(buf[:], uint64(v))
+		h.Write(buf[:])
+	}
+	writeGlyph := func(g *Glyph) {
+		write(int64(g.GlyphID))
+		write(int64(g.ClusterIndex))
+		write(int64(g.RuneCount))
+		write(int64(g.GlyphCount))
+		for _, v := range [...]fixed.Int26_6{
+			g.XAdvance, g.YAdvance, g.XOffset, g.YOffset,
+			g.Width, g.Height, g.XBearing, g.YBearing,
+			g.startLetterSpacing, g.endLetterSpacing,
+		} {
+			write(int64(v))
+		}
+	}
+
+	write(int64(o.Direction))
+	write(int64(o.Size))
+	write(int64(o.Runes.Offset))
+	write(int64(o.Runes.Count))
+	write(int64(o.Advance))
+	write(int64(o.LineBounds.Ascent))
+	write(int64(o.LineBounds.Descent))
+	write(int64(o.LineBounds.Gap))
+	write(int64(len(o.Glyphs)))
+	for i := range o.Glyphs {
+		writeGlyph(&o.Glyphs[i])
+	}
+	writeGlyph(&o.Hyphen)
+	return h.Sum64()
+}
+
 // AdjustBaselines aligns runs with different baselines.
 //
 // For vertical text, it centralizes 'sideways' runs, so
@@ -4,11 +4,14 @@ package shaping
 
 import (
 	"bytes"
+	"fmt"
 	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/boxesandglue/typesetting/di"
 	"github.com/boxesandglue/typesetting/font"
+	"github.com/boxesandglue/typesetting/harfbuzz"
 	"github.com/boxesandglue/typesetting/language"
 	tu "github.com/boxesandglue/typesetting/testutils"
 	hd "github.com/go-text/typesetting-utils/harfbuzz"
@@ -467,3 +470,106 @@ func TestAdvanceSpaceAware(t *testing.T) {
 		})
 	}
 }
+
+func TestOutputHash(t *testing.T) {
+	hash := func(text string, size fixed.Int26_6) uint64 {
+		runes := []rune(text)
+		var shaper HarfbuzzShaper
+		out := shaper.Shape(Input{
+			Text:      runes,
+			RunStart:  0,
+			RunEnd:    len(runes),
+			Direction: di.DirectionLTR,
+			Face:      benchEnFace,
+			Size:      size,
+			Script:    language.Latin,
+			Language:  language.NewLanguage("en"),
+		})
+		return out.Hash()
+	}
+
+	var shaper HarfbuzzShaper
+	ref := shaper.Shape(Input{
+		Text:      []rune("Hello world"),
+		RunStart:  0,
+		RunEnd:    11,
+		Direction: di.DirectionLTR,
+		Face:      benchEnFace,
+		Size:      fixed.I(12),
+		Script:    language.Latin,
+		Language:  language.NewLanguage("en"),
+	})
+	tu.Assert(t, ref.Hash() == hash("Hello world", fixed.I(12)))
+	// the face pointer is ignored
+	copied := ref
+	copied.Face = nil
+	tu.Assert(t, ref.Hash() == copied.Hash())
+
+	tu.Assert(t, ref.Hash() != hash("Hello world!", fixed.I(12)))
+	tu.Assert(t, ref.Hash() != hash("Hello wordl", fixed.I(12)))
+	tu.Assert(t, ref.Hash() != hash("Hello world", fixed.I(14)))
+
+	// positions are taken into account
+	moved := ref
+	moved.Glyphs = append([]Glyph(nil), ref.Glyphs...)
+	moved.Glyphs[2].XOffset++
+	tu.Assert(t, ref.Hash() != moved.Hash())
+
+	// the digest must not change between versions
+	golden := Output{
+		Direction:  di.DirectionRTL,
+		Size:       fixed.I(12),
+		Runes:      Range{Offset: 2, Count: 3},
+		Advance:    fixed.I(20),
+		LineBounds: Bounds{Ascent: fixed.I(10), Descent: -fixed.I(3), Gap: fixed.I(1)},
+		Glyphs: []Glyph{
+			{GlyphID: 12, ClusterIndex: 4, RuneCount: 1, GlyphCount: 1, XAdvance: fixed.I(8), Width: fixed.I(7), Height: -fixed.I(9), YBearing: fixed.I(9)},
+			{GlyphID: 7, ClusterIndex: 2, RuneCount: 2, GlyphCount: 1, XAdvance: fixed.I(12), XOffset: 5, YOffset: -3},
+		},
+	}
+	tu.AssertC(t, golden.Hash() == 0x64f47601ff6b19e6, fmt.Sprintf("%#x", golden.Hash()))
+}
+
+func TestRemapGlyphs(t *testing.T) {
+	text := []rune("pre\u00ADfix")
+	face := loadOpentypeFont(t, "../font/testdata/UbuntuMono-R.ttf")
+	run := (&HarfbuzzShaper{}).Shape(Input{
+		Text:       text,
+		Face:       face,
+		Size:       fixed.I(12),
+		RunEnd:     len(text),
+		SoftHyphen: harfbuzz.SoftHyphenAtLineEnd,
+	})
+	tu.Assert(t, run.Hyphen.GlyphID != 0)
+	ref := run
+	ref.Glyphs = append([]Glyph(nil), run.Glyphs...)
+
+	// a subset, keeping the glyphs order
+	mapping := map[font.GID]font.GID{}
+	for _, g := range append(run.Glyphs, run.Hyphen) {
+		mapping[g.GlyphID] = 0
+	}
+	var olds []font.GID
+	for gid := range mapping {
+		olds = append(olds, gid)
+	}
+	sort.Slice(olds, func(i, j int) bool { return olds[i] < olds[j] })
+	for i, gid := range olds {
+		mapping[gid] = font.GID(i + 1)
+	}
+
+	// missing glyphs are reported
+	partial := map[font.GID]font.GID{run.Glyphs[0].GlyphID: 1}
+	tu.Assert(t, run.RemapGlyphs(partial) != nil)
+	tu.Assert(t, reflect.DeepEqual(run.Glyphs, ref.Glyphs))
+
+	tu.AssertNoErr(t, run.RemapGlyphs(mapping))
+	tu.Assert(t, run.Hyphen.GlyphID == mapping[ref.Hyphen.GlyphID])
+	for i, g := range run.Glyphs {
+		exp := ref.Glyphs[i]
+		tu.Assert(t, g.GlyphID == mapping[exp.GlyphID])
+		exp.GlyphID = g.GlyphID
+		tu.Assert(t, g == exp)
+	}
+	tu.Assert(t, run.Advance == ref.Advance)
+}
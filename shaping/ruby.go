@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// RubyPair associates a range of base runes with the range of ruby runes annotating it.
+// The ranges are rune indices into the Text of the base and ruby [Input]s.
+//
+// Mono ruby is described by one pair per base character, and group ruby by one
+// pair for the whole base word.
+type RubyPair struct {
+	Base, Annotating Range
+}
+
+// RubyGroup is a group of base glyphs with the glyphs of their annotation,
+// as returned by [HarfbuzzShaper.ShapeRuby].
+type RubyGroup struct {
+	RubyPair
+
+	// BaseGlyphs and AnnotatingGlyphs are sub-slices of the glyphs
+	// of [RubyOutput.Base] and [RubyOutput.Annotating], selected
+	// by their [Glyph.ClusterIndex].
+	BaseGlyphs, AnnotatingGlyphs []Glyph
+
+	// BaseAdvance and AnnotatingAdvance are the advances of the glyph groups,
+	// with the same convention as [Output.Advance].
+	BaseAdvance, AnnotatingAdvance fixed.Int26_6
+
+	// AnnotatingOffset is the offset, along the advance direction, to apply
+	// to the annotation so that it is centered on its base.
+	// It is negative when the annotation is longer than its base, and
+	// has to overhang the adjacent text.
+	AnnotatingOffset fixed.Int26_6
+}
+
+// RubyOutput is the result of [HarfbuzzShaper.ShapeRuby].
+type RubyOutput struct {
+	// Base and Annotating are the shaped base and ruby texts.
+	Base, Annotating Output
+	// Groups are the aligned glyph groups, in the order of the pairs
+	// provided to [HarfbuzzShaper.ShapeRuby].
+	Groups []RubyGroup
+}
+
+var rubyTag = ot.NewTag('r', 'u', 'b', 'y')
+
+// ShapeRuby shapes the [base] text and its [ruby] annotation (for instance furigana),
+// enabling the 'ruby' feature for the annotation. For AAT fonts, this feature selects
+// the Ruby Kana glyphs.
+//
+// [pairs] describes how the base and ruby texts are aligned; if it is empty,
+// the whole base run is annotated by the whole ruby run.
+// The returned [RubyOutput.Groups] provides, for each pair, the glyphs and advances
+// of the base and ruby texts, so that the annotation may be positioned above
+// (or, for vertical text, on the right of) its base.
+//
+// The [Input.Size] of [ruby] is usually half the size of [base].
+func (t *HarfbuzzShaper) ShapeRuby(base, ruby Input, pairs []RubyPair) RubyOutput {
+	ruby.FontFeatures = append(append([]FontFeature(nil), ruby.FontFeatures...), FontFeature{Tag: rubyTag, Value: 1})
+
+	out := RubyOutput{
+		Base:       t.Shape(base),
+		Annotating: t.Shape(ruby),
+	}
+
+	if len(pairs) == 0 {
+		pairs = []RubyPair{{
+			Base:       Range{Offset: base.RunStart, Count: base.RunEnd - base.RunStart},
+			Annotating: Range{Offset: ruby.RunStart, Count: ruby.RunEnd - ruby.RunStart},
+		}}
+	}
+	out.Groups = make([]RubyGroup, len(pairs))
+	for i, pair := range pairs {
+		group := RubyGroup{RubyPair: pair}
+		group.BaseGlyphs, group.BaseAdvance = out.Base.clusterGlyphs(pair.Base)
+		group.AnnotatingGlyphs, group.AnnotatingAdvance = out.Annotating.clusterGlyphs(pair.Annotating)
+		group.AnnotatingOffset = (group.BaseAdvance - group.AnnotatingAdvance) / 2
+		out.Groups[i] = group
+	}
+	return out
+}
+
+// clusterGlyphs returns the glyphs whose cluster is in [runes], with their advance.
+// Since the glyphs of a cluster range are adjacent, a sub-slice of [Output.Glyphs] is returned.
+func (o *Output) clusterGlyphs(runes Range) ([]Glyph, fixed.Int26_6) {
+	start, end := -1, -1
+	for i, g := range o.Glyphs {
+		if runes.Offset <= g.ClusterIndex && g.ClusterIndex < runes.Offset+runes.Count {
+			if start == -1 {
+				start = i
+			}
+			end = i + 1
+		}
+	}
+	if start == -1 {
+		return nil, 0
+	}
+	glyphs := o.Glyphs[start:end]
+	var advance fixed.Int26_6
+	for _, g := range glyphs {
+		if o.Direction.IsVertical() {
+			advance += g.YAdvance
+		} else {
+			advance += g.XAdvance
+		}
+	}
+	return glyphs, advance
+}
+
+// Group returns the group whose base text contains the rune at index [cluster],
+// typically the [Glyph.ClusterIndex] of a base glyph, or false if it is not annotated.
+func (ro *RubyOutput) Group(cluster int) (RubyGroup, bool) {
+	for _, group := range ro.Groups {
+		if group.Base.Offset <= cluster && cluster < group.Base.Offset+group.Base.Count {
+			return group, true
+		}
+	}
+	return RubyGroup{}, false
+}
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/di"
+	"github.com/boxesandglue/typesetting/font"
+	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
+	td "github.com/go-text/typesetting-utils/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestShapeRuby(t *testing.T) {
+	b, err := td.Files.ReadFile("common/NotoSansCJKjp-VF.otf")
+	tu.AssertNoErr(t, err)
+	face, err := font.ParseTTF(bytes.NewReader(b))
+	tu.AssertNoErr(t, err)
+
+	baseText, rubyText := []rune("漢字です"), []rune("かんじ")
+	for _, dir := range []di.Direction{di.DirectionLTR, di.DirectionTTB} {
+		base := Input{
+			Text: baseText, RunEnd: len(baseText), Direction: dir, Face: face,
+			Size: fixed.I(20), Script: language.Han, Language: "ja",
+		}
+		ruby := base
+		ruby.Text, ruby.RunEnd, ruby.Size = rubyText, len(rubyText), fixed.I(10)
+
+		var shaper HarfbuzzShaper
+		// mono ruby
+		out := shaper.ShapeRuby(base, ruby, []RubyPair{
+			{Base: Range{0, 1}, Annotating: Range{0, 2}},
+			{Base: Range{1, 1}, Annotating: Range{2, 1}},
+		})
+		tu.Assert(t, len(out.Base.Glyphs) == 4 && len(out.Annotating.Glyphs) == 3)
+		tu.Assert(t, len(out.Groups) == 2)
+
+		g1, g2 := out.Groups[0], out.Groups[1]
+		tu.Assert(t, len(g1.BaseGlyphs) == 1 && len(g1.AnnotatingGlyphs) == 2)
+		tu.Assert(t, len(g2.BaseGlyphs) == 1 && len(g2.AnnotatingGlyphs) == 1)
+		tu.Assert(t, &g2.AnnotatingGlyphs[0] == &out.Annotating.Glyphs[2])
+		// full width glyphs: the ruby of 漢 has the same length as its base,
+		// and the ruby of 字 is centered
+		tu.Assert(t, g1.BaseAdvance == g1.AnnotatingAdvance && g1.AnnotatingOffset == 0)
+		tu.Assert(t, g2.AnnotatingOffset == g2.BaseAdvance/4)
+		tu.Assert(t, g1.BaseAdvance+g2.BaseAdvance == out.Base.Advance/2)
+
+		// groups are keyed by cluster
+		group, ok := out.Group(1)
+		tu.Assert(t, ok && group.Base == g2.Base)
+		_, ok = out.Group(2)
+		tu.Assert(t, !ok)
+
+		// group ruby
+		out = shaper.ShapeRuby(base, ruby, nil)
+		tu.Assert(t, len(out.Groups) == 1)
+		tu.Assert(t, len(out.Groups[0].BaseGlyphs) == 4 && len(out.Groups[0].AnnotatingGlyphs) == 3)
+		tu.Assert(t, out.Groups[0].BaseAdvance == out.Base.Advance)
+
+		// the 'ruby' feature is applied : for this font,
+		// it only concerns the Bopomofo tone marks (in horizontal text,
+		// since their vertical forms are already the ruby forms)
+		if dir.IsVertical() {
+			continue
+		}
+		tone := ruby
+		tone.Text, tone.RunEnd = []rune("\u02CA"), 1
+		plain := shaper.Shape(tone)
+		out = shaper.ShapeRuby(base, tone, nil)
+		tu.Assert(t, plain.Glyphs[0].GlyphID != out.Annotating.Glyphs[0].GlyphID)
+		tu.Assert(t, len(tone.FontFeatures) == 0)
+	}
+}
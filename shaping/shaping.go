@@ -3,7 +3,10 @@
 package shaping
 
 import (
+	"math"
+
 	"github.com/boxesandglue/typesetting/di"
+	"github.com/boxesandglue/typesetting/font"
 	"github.com/boxesandglue/typesetting/harfbuzz"
 	"golang.org/x/image/math/fixed"
 )
@@ -18,6 +21,28 @@ type HarfbuzzShaper struct {
 	fonts fontLRU
 
 	features []harfbuzz.Feature
+
+	positioning Positioning
+}
+
+// Positioning selects how the glyph positions are computed by [HarfbuzzShaper].
+type Positioning uint8
+
+const (
+	// PositioningFast computes the positions with integer arithmetic, at the
+	// (rounded up) font size. This is the fastest mode, and the default.
+	PositioningFast Positioning = iota
+	// PositioningPrecise shapes in font units, and scales the positions with
+	// floating point arithmetic, rounding only once the absolute pen positions.
+	// Rounding errors thus do not accumulate along a run, and fractional font sizes
+	// are respected, which gives results closer to other engines,
+	// at the price of a few floating point operations per glyph.
+	PositioningPrecise
+)
+
+// SetPositioning selects the positioning mode used by the next calls to [Shape].
+func (h *HarfbuzzShaper) SetPositioning(p Positioning) {
+	h.positioning = p
 }
 
 // SetFontCacheSize adjusts the size of the font cache within the shaper.
@@ -44,6 +69,23 @@ const (
 	scaleShift = 6
 )
 
+// runeRange returns the bounds of the run in [Input.Text], fixing
+// invalid values.
+func (input Input) runeRange() (start, end int) {
+	start, end = input.RunStart, input.RunEnd
+	if end < start {
+		// Try to guess what the caller actually wanted.
+		end, start = start, end
+	}
+	return clamp(start, 0, len(input.Text)), clamp(end, 0, len(input.Text))
+}
+
+// runes returns the runes of the run.
+func (input Input) runes() []rune {
+	start, end := input.runeRange()
+	return input.Text[start:end]
+}
+
 // clamp ensures val is in the inclusive range [low,high].
 func clamp(val, low, high int) int {
 	if val < low {
@@ -56,22 +98,11 @@ func clamp(val, low, high int) int {
 }
 
 // Shape turns an input into an output.
-func (t *HarfbuzzShaper) Shape(input Input) Output {
-	// Prepare to shape the text.
-	if t.buf == nil {
-		t.buf = harfbuzz.NewBuffer()
-	} else {
-		t.buf.Clear()
-	}
+func (t *HarfbuzzShaper) Shape(input Input) Output { return t.shape(input, 0) }
 
-	runes, start, end := input.Text, input.RunStart, input.RunEnd
-	if end < start {
-		// Try to guess what the caller actually wanted.
-		end, start = start, end
-	}
-	start = clamp(start, 0, len(runes))
-	end = clamp(end, 0, len(runes))
-	t.buf.AddRunes(runes, start, end-start)
+// shape implements [HarfbuzzShaper.Shape], using the given buffer flags
+func (t *HarfbuzzShaper) shape(input Input, flags harfbuzz.ShappingOptions) Output {
+	font, sc := t.shapeBuffer(input, harfbuzz.MonotoneGraphemes, flags)
 
 	// handle vertical sideways text
 	isSideways := false
@@ -81,9 +112,71 @@ func (t *HarfbuzzShaper) Shape(input Input) Output {
 		isSideways = true
 	}
 
-	t.buf.Props.Direction = input.Direction.Harfbuzz()
+	// Convert the shaped text into an Output.
+	glyphs := make([]Glyph, len(t.buf.Info))
+	for i := range glyphs {
+		glyphs[i] = t.outputGlyph(font, i, &sc)
+	}
+	var hyphen Glyph
+	if input.SoftHyphen == harfbuzz.SoftHyphenAtLineEnd && hasSoftHyphen(input.runes()) {
+		hyphen = t.shapeHyphen(font, len(input.FontFeatures), positionScaler{factor: sc.factor})
+	}
+	countClusters(glyphs, input.RunEnd, input.Direction.Progression())
+	if hasAnnotationControls(input.runes()) {
+		hideAnnotationControls(glyphs, input.Text, input.Face)
+	}
+	out := Output{
+		Glyphs:    glyphs,
+		Direction: input.Direction,
+		Face:      input.Face,
+		Size:      input.Size,
+		Hyphen:    hyphen,
+	}
+	out.Runes.Offset = input.RunStart
+	out.Runes.Count = input.RunEnd - input.RunStart
+
+	if isSideways {
+		// set the Direction to the correct value.
+		// this is required here so that the following call to ExtentsForDirection
+		// returns the vertical data.
+		out.sideways()
+	}
+
+	fontExtents := font.ExtentsForDirection(out.Direction.Harfbuzz())
+	out.LineBounds = Bounds{
+		Ascent:  sc.scalef(fontExtents.Ascender),
+		Descent: sc.scalef(fontExtents.Descender),
+		Gap:     sc.scalef(fontExtents.LineGap),
+	}
+	out.RecalculateAll()
+	return out
+}
+
+// shapeBuffer fills the buffer with the run of [input], using the given cluster level and flags,
+// and shapes it, returning the font used and the scaler to apply to the positions.
+func (t *HarfbuzzShaper) shapeBuffer(input Input, level harfbuzz.ClusterLevel, flags harfbuzz.ShappingOptions) (*harfbuzz.Font, positionScaler) {
+	// Prepare to shape the text.
+	if t.buf == nil {
+		t.buf = harfbuzz.NewBuffer()
+	} else {
+		t.buf.Clear()
+	}
+
+	t.buf.ClusterLevel = level
+	t.buf.Flags = flags
+	start, end := input.runeRange()
+	t.buf.AddRunes(input.Text, start, end-start)
+
+	direction := input.Direction
+	if direction.IsSideways() {
+		// shape vertical sideways text as horizontal text
+		direction = direction.SwitchAxis()
+	}
+
+	t.buf.Props.Direction = direction.Harfbuzz()
 	t.buf.Props.Language = input.Language
 	t.buf.Props.Script = input.Script
+	t.buf.SoftHyphen = input.SoftHyphen
 
 	// reuse font when possible
 	font, ok := t.fonts.Get(input.Face.Font)
@@ -92,7 +185,15 @@ func (t *HarfbuzzShaper) Shape(input Input) Output {
 		t.fonts.Put(input.Face.Font, font)
 	}
 	// adjust the user provided fields
-	font.XScale = int32(input.Size.Ceil()) << scaleShift
+	sc := positionScaler{}
+	if t.positioning == PositioningPrecise {
+		// shape in font units
+		upem := int32(input.Face.Upem())
+		font.XScale = upem
+		sc.factor = float64(input.Size) / float64(upem)
+	} else {
+		font.XScale = int32(input.Size.Ceil()) << scaleShift
+	}
 	font.YScale = font.XScale
 
 	if L := len(input.FontFeatures); cap(t.features) < L {
@@ -108,59 +209,143 @@ func (t *HarfbuzzShaper) Shape(input Input) Output {
 			End:   harfbuzz.FeatureGlobalEnd,
 		}
 	}
+	// cluster values are indices into input.Text, so that
+	// ranged features may be used directly
+	t.features = append(t.features, input.RangedFeatures...)
 
 	// Actually use harfbuzz to shape the text.
 	t.buf.Shape(font, t.features)
 
-	// Convert the shaped text into an Output.
-	glyphs := make([]Glyph, len(t.buf.Info))
-	for i := range glyphs {
-		g := t.buf.Info[i].Glyph
-		glyphs[i] = Glyph{
-			ClusterIndex: t.buf.Info[i].Cluster,
-			GlyphID:      g,
-			Mask:         t.buf.Info[i].Mask,
-		}
+	return font, sc
+}
+
+// positionScaler converts the positions computed by harfbuzz
+// to the output unit (see [Positioning]).
+type positionScaler struct {
+	// 0 for [PositioningFast], otherwise the factor
+	// from font units to fixed.Int26_6
+	factor float64
+
+	// current pen position, in font units, only used
+	// for [PositioningPrecise]
+	penX, penY harfbuzz.Position
+}
+
+func (sc *positionScaler) round(v float64) fixed.Int26_6 {
+	return fixed.Int26_6(math.Round(v * sc.factor))
+}
+
+// scale returns the distance between [origin] and [origin] + [v]:
+// with [PositioningPrecise], this is the difference of the rounded absolute positions.
+func (sc *positionScaler) scale(origin, v harfbuzz.Position) fixed.Int26_6 {
+	if sc.factor == 0 {
+		return fixed.I(int(v)) >> scaleShift
+	}
+	return sc.round(float64(origin+v)) - sc.round(float64(origin))
+}
+
+func (sc *positionScaler) scalef(v float32) fixed.Int26_6 {
+	if sc.factor == 0 {
+		return fixed.I(int(v)) >> scaleShift
+	}
+	return sc.round(float64(v))
+}
+
+// outputGlyph converts the i-th glyph of the shaped buffer,
+// advancing the pen position of [sc].
+func (t *HarfbuzzShaper) outputGlyph(font *harfbuzz.Font, i int, sc *positionScaler) Glyph {
+	g := t.buf.Info[i].Glyph
+	pos := t.buf.Pos[i]
+	penX, penY := sc.penX, sc.penY
+	sc.penX += pos.XAdvance
+	sc.penY += pos.YAdvance
+
+	glyph := Glyph{
+		ClusterIndex: t.buf.Info[i].Cluster,
+		GlyphID:      g,
+		Mask:         t.buf.Info[i].Mask,
+	}
+	if sc.factor == 0 {
 		extents, ok := font.GlyphExtents(g)
 		if !ok {
 			// Leave the glyph having zero size if it isn't in the font. There
 			// isn't really anything we can do to recover from such an error.
-			continue
+			return glyph
+		}
+		glyph.Width = fixed.I(int(extents.Width)) >> scaleShift
+		glyph.Height = fixed.I(int(extents.Height)) >> scaleShift
+		glyph.XBearing = fixed.I(int(extents.XBearing)) >> scaleShift
+		glyph.YBearing = fixed.I(int(extents.YBearing)) >> scaleShift
+	} else {
+		// use the unrounded extents, in font units
+		extents, ok := font.Face().GlyphExtents(g)
+		if !ok {
+			return glyph
 		}
-		glyphs[i].Width = fixed.I(int(extents.Width)) >> scaleShift
-		glyphs[i].Height = fixed.I(int(extents.Height)) >> scaleShift
-		glyphs[i].XBearing = fixed.I(int(extents.XBearing)) >> scaleShift
-		glyphs[i].YBearing = fixed.I(int(extents.YBearing)) >> scaleShift
-		glyphs[i].XAdvance = fixed.I(int(t.buf.Pos[i].XAdvance)) >> scaleShift
-		glyphs[i].YAdvance = fixed.I(int(t.buf.Pos[i].YAdvance)) >> scaleShift
-		glyphs[i].XOffset = fixed.I(int(t.buf.Pos[i].XOffset)) >> scaleShift
-		glyphs[i].YOffset = fixed.I(int(t.buf.Pos[i].YOffset)) >> scaleShift
+		glyph.XBearing = sc.round(float64(extents.XBearing))
+		glyph.Width = sc.round(float64(extents.XBearing+extents.Width)) - glyph.XBearing
+		glyph.YBearing = sc.round(float64(extents.YBearing))
+		glyph.Height = sc.round(float64(extents.YBearing+extents.Height)) - glyph.YBearing
 	}
-	countClusters(glyphs, input.RunEnd, input.Direction.Progression())
-	out := Output{
-		Glyphs:    glyphs,
-		Direction: input.Direction,
-		Face:      input.Face,
-		Size:      input.Size,
+	glyph.XAdvance = sc.scale(penX, pos.XAdvance)
+	glyph.YAdvance = sc.scale(penY, pos.YAdvance)
+	glyph.XOffset = sc.scale(penX, pos.XOffset)
+	glyph.YOffset = sc.scale(penY, pos.YOffset)
+	return glyph
+}
+
+const softHyphen = '\u00AD'
+
+func hasSoftHyphen(text []rune) bool {
+	for _, r := range text {
+		if r == softHyphen {
+			return true
+		}
 	}
-	out.Runes.Offset = input.RunStart
-	out.Runes.Count = input.RunEnd - input.RunStart
+	return false
+}
 
-	if isSideways {
-		// set the Direction to the correct value.
-		// this is required here so that the following call to ExtentsForDirection
-		// returns the vertical data.
-		out.sideways()
+// hideAnnotationControls replaces the glyphs of the interlinear annotation
+// characters, which fonts usually render with .notdef, by empty glyphs, like
+// Harfbuzz does for default ignorables
+func hideAnnotationControls(glyphs []Glyph, text []rune, face *font.Face) {
+	invisible, _ := face.NominalGlyph(' ')
+	for i, g := range glyphs {
+		cluster := text[g.ClusterIndex : g.ClusterIndex+g.RuneCount]
+		if len(cluster) == 0 {
+			continue
+		}
+		isControl := true
+		for _, r := range cluster {
+			isControl = isControl && isAnnotationControl(r)
+		}
+		if !isControl {
+			continue
+		}
+		glyphs[i] = Glyph{
+			ClusterIndex: g.ClusterIndex,
+			RuneCount:    g.RuneCount,
+			GlyphCount:   g.GlyphCount,
+			GlyphID:      invisible,
+			Mask:         g.Mask,
+		}
 	}
+}
 
-	fontExtents := font.ExtentsForDirection(out.Direction.Harfbuzz())
-	out.LineBounds = Bounds{
-		Ascent:  fixed.I(int(fontExtents.Ascender)) >> scaleShift,
-		Descent: fixed.I(int(fontExtents.Descender)) >> scaleShift,
-		Gap:     fixed.I(int(fontExtents.LineGap)) >> scaleShift,
+// shapeHyphen shapes a visible soft hyphen in isolation, with the current
+// buffer properties and the first [nbGlobalFeatures] features, and
+// returns its glyph, or a zero Glyph if the font has no suitable glyph.
+func (t *HarfbuzzShaper) shapeHyphen(font *harfbuzz.Font, nbGlobalFeatures int, sc positionScaler) Glyph {
+	props := t.buf.Props
+	t.buf.Clear()
+	t.buf.Props = props
+	t.buf.SoftHyphen = harfbuzz.SoftHyphenVisible
+	t.buf.AddRune(softHyphen, 0)
+	t.buf.Shape(font, t.features[:nbGlobalFeatures])
+	if len(t.buf.Info) != 1 || t.buf.Info[0].Glyph == 0 {
+		return Glyph{}
 	}
-	out.RecalculateAll()
-	return out
+	return t.outputGlyph(font, 0, &sc)
 }
 
 // countClusters tallies the number of runes and glyphs in each cluster
@@ -3,6 +3,7 @@ package shaping
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -11,6 +12,7 @@ import (
 	"github.com/boxesandglue/typesetting/di"
 	"github.com/boxesandglue/typesetting/font"
 	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"github.com/boxesandglue/typesetting/harfbuzz"
 	"github.com/boxesandglue/typesetting/language"
 	tu "github.com/boxesandglue/typesetting/testutils"
 	hd "github.com/go-text/typesetting-utils/harfbuzz"
@@ -243,6 +245,78 @@ func BenchmarkShaping(b *testing.B) {
 	}
 }
 
+func TestShapePrecisePositioning(t *testing.T) {
+	text := []rune("Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor.")
+	face := benchEnFace
+	input := Input{
+		Text:      text,
+		RunEnd:    len(text),
+		Direction: di.DirectionLTR,
+		Face:      face,
+		Size:      fixed.I(10) + 21, // fractional size
+		Script:    language.Latin,
+		Language:  language.NewLanguage("en"),
+	}
+
+	// reference positions, in font units
+	buf := harfbuzz.NewBuffer()
+	buf.AddRunes(text, 0, len(text))
+	buf.Props.Direction = harfbuzz.LeftToRight
+	buf.Props.Script = language.Latin
+	buf.Shape(harfbuzz.NewFont(face), nil)
+	factor := float64(input.Size) / float64(face.Upem())
+
+	var shaper HarfbuzzShaper
+	shaper.SetPositioning(PositioningPrecise)
+	precise := shaper.Shape(input)
+	tu.Assert(t, len(precise.Glyphs) == len(buf.Pos))
+
+	// the pen position never drifts from the exact position by more than half a unit
+	var penFU harfbuzz.Position
+	var pen fixed.Int26_6
+	for i, g := range precise.Glyphs {
+		penFU += buf.Pos[i].XAdvance
+		pen += g.XAdvance
+		tu.Assert(t, pen == fixed.Int26_6(math.Round(float64(penFU)*factor)))
+	}
+	tu.Assert(t, precise.Advance == pen)
+
+	shaper.SetPositioning(PositioningFast)
+	fast := shaper.Shape(input)
+	tu.Assert(t, len(fast.Glyphs) == len(precise.Glyphs))
+	// the fast path uses the rounded up size
+	tu.Assert(t, fast.Advance > precise.Advance)
+}
+
+func BenchmarkShapingPositioning(b *testing.B) {
+	text := benchLangs[0].text[:1000]
+	for _, positioning := range []struct {
+		name string
+		mode Positioning
+	}{
+		{"fast", PositioningFast},
+		{"precise", PositioningPrecise},
+	} {
+		b.Run(positioning.name, func(b *testing.B) {
+			input := Input{
+				Text:      text,
+				RunEnd:    len(text),
+				Direction: benchLangs[0].dir,
+				Face:      benchLangs[0].face,
+				Size:      16*64 + 32,
+				Script:    benchLangs[0].script,
+				Language:  benchLangs[0].lang,
+			}
+			var shaper HarfbuzzShaper
+			shaper.SetPositioning(positioning.mode)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = shaper.Shape(input)
+			}
+		})
+	}
+}
+
 func BenchmarkFontLoad(b *testing.B) {
 	arabicBytes, err := os.ReadFile("../font/testdata/Amiri-Regular.ttf")
 	if err != nil {
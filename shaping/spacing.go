@@ -125,3 +125,136 @@ func AddSpacing(runs []Output, text []rune, wordSpacing, letterSpacing fixed.Int
 		}
 	}
 }
+
+// InkCollision describes two adjacent clusters of a run whose ink overlap.
+type InkCollision struct {
+	// GlyphIndex is the index in [Output.Glyphs] of the first glyph
+	// of the second cluster, in visual order.
+	GlyphIndex int
+	// Overlap is the horizontal overlap of the ink boxes
+	// of the two clusters, always positive.
+	Overlap fixed.Int26_6
+}
+
+// InkCollisions returns the adjacent clusters of the run whose ink boxes overlap by more than [tolerance],
+// which typically happens with tight (negative) letter spacing or justification, and hurts legibility.
+//
+// The ink boxes are computed from the glyph extents. Marks are included in the ink of their cluster,
+// and clusters without ink (like spaces) are ignored, so that the clusters around them are compared.
+// Note that some overlaps may be intended by the font design, for instance when kerning "AV".
+//
+// Only horizontal runs are supported : nil is returned for vertical ones.
+//
+// See [Output.ClampInkCollisions] to fix the collisions.
+func (run *Output) InkCollisions(tolerance fixed.Int26_6) []InkCollision {
+	return run.inkCollisions(tolerance, false)
+}
+
+// ClampInkCollisions reduces the negative letter spacing added by [Output.AddLetterSpacing],
+// so that the ink boxes of adjacent clusters do not overlap by more than [tolerance].
+// The overlaps which can't be fixed this way (for instance the ones caused by kerning)
+// are left untouched and returned. See [Output.InkCollisions] for more details.
+func (run *Output) ClampInkCollisions(tolerance fixed.Int26_6) []InkCollision {
+	out := run.inkCollisions(tolerance, true)
+	run.RecomputeAdvance()
+	return out
+}
+
+func (run *Output) inkCollisions(tolerance fixed.Int26_6, clamp bool) (out []InkCollision) {
+	if run.Direction.IsVertical() {
+		return nil
+	}
+	var (
+		pen       fixed.Int26_6 // at the start of the current cluster
+		prevRight fixed.Int26_6 // right side of the ink of the previous cluster with ink
+		prevEnd   int           // end glyph index of the previous cluster with ink
+		hasPrev   bool
+	)
+	for start := 0; start < len(run.Glyphs); {
+		end := start + run.Glyphs[start].GlyphCount
+		if end <= start || end > len(run.Glyphs) { // be defensive with invalid inputs
+			end = start + 1
+		}
+
+		left, right, hasInk := run.clusterInk(start, end, pen)
+		if hasInk {
+			if overlap := prevRight - left; hasPrev && overlap > tolerance {
+				if clamp {
+					fromPrev, fromCurrent := run.releaseLetterSpacing(prevEnd, start, overlap-tolerance)
+					pen += fromPrev
+					overlap -= fromPrev + fromCurrent
+					left, right = left+fromPrev+fromCurrent, right+fromPrev+fromCurrent
+				}
+				if overlap > tolerance {
+					out = append(out, InkCollision{GlyphIndex: start, Overlap: overlap})
+				}
+			}
+			prevRight, prevEnd, hasPrev = right, end, true
+		}
+
+		for _, g := range run.Glyphs[start:end] {
+			pen += g.XAdvance
+		}
+		start = end
+	}
+	return out
+}
+
+// clusterInk returns the horizontal bounds of the ink of the glyphs [start:end],
+// or false if the glyphs are empty
+func (run *Output) clusterInk(start, end int, pen fixed.Int26_6) (left, right fixed.Int26_6, hasInk bool) {
+	for _, g := range run.Glyphs[start:end] {
+		if g.Width != 0 {
+			l := pen + g.XOffset + g.XBearing
+			r := l + g.Width
+			if r < l {
+				l, r = r, l
+			}
+			if !hasInk || l < left {
+				left = l
+			}
+			if !hasInk || r > right {
+				right = r
+			}
+			hasInk = true
+		}
+		pen += g.XAdvance
+	}
+	return left, right, hasInk
+}
+
+// releaseLetterSpacing removes up to [amount] of the negative letter spacing
+// between the glyphs [prevEnd-1] and [index] (excluded), returning the spacing removed
+// before the glyph at [index], and on its start side
+func (run *Output) releaseLetterSpacing(prevEnd, index int, amount fixed.Int26_6) (before, fromCurrent fixed.Int26_6) {
+	for i := prevEnd - 1; i <= index && amount > 0; i++ {
+		g := &run.Glyphs[i]
+		if i >= prevEnd && g.startLetterSpacing < 0 {
+			a := min26_6(amount, -g.startLetterSpacing)
+			g.XAdvance += a
+			g.XOffset += a
+			g.startLetterSpacing += a
+			amount -= a
+			if i == index {
+				fromCurrent += a
+			} else {
+				before += a
+			}
+		}
+		if i < index && g.endLetterSpacing < 0 && amount > 0 {
+			a := min26_6(amount, -g.endLetterSpacing)
+			g.XAdvance += a
+			g.endLetterSpacing += a
+			amount -= a
+			before += a
+		}
+	}
+	return before, fromCurrent
+}
+
+func min26_6(a, b fixed.Int26_6) fixed.Int26_6 {
+	if a < b {
+		return a
+	}
+	return b
+}
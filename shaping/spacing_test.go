@@ -1,6 +1,7 @@
 package shaping
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/boxesandglue/typesetting/di"
@@ -156,3 +157,50 @@ func TestTrailingSpaces(t *testing.T) {
 		}
 	}
 }
+
+func TestInkCollisions(t *testing.T) {
+	latinFont := loadOpentypeFont(t, "../font/testdata/Roboto-Regular.ttf")
+	text := []rune("AVATAR Wave mm")
+
+	ref := simpleShape(text, latinFont, di.DirectionLTR)
+	// kerning makes some capitals overlap
+	natural := ref.InkCollisions(0)
+	tu.Assert(t, len(natural) == 4)
+	for i, c := range natural {
+		tu.Assert(t, c.GlyphIndex == i+1 && c.Overlap > 0)
+	}
+	tu.Assert(t, len(ref.InkCollisions(fixed.I(5))) == 0)
+
+	var clamped fixed.Int26_6
+	for _, spacing := range []fixed.Int26_6{fixed.I(100), -fixed.I(100), -fixed.I(1000)} {
+		out := simpleShape(text, latinFont, di.DirectionLTR)
+		out.AddLetterSpacing(spacing, true, true)
+		collisions := out.InkCollisions(0)
+		if spacing > 0 {
+			tu.Assert(t, len(collisions) == 0)
+			continue
+		}
+		tu.Assert(t, len(collisions) == len(text)-3) // all but the spaces
+
+		// only the overlaps caused by kerning remain
+		tu.Assert(t, reflect.DeepEqual(out.ClampInkCollisions(0), natural))
+		tu.Assert(t, reflect.DeepEqual(out.InkCollisions(0), natural))
+		tu.Assert(t, out.Advance < ref.Advance)
+		if clamped != 0 {
+			tu.Assert(t, out.Advance == clamped)
+		}
+		clamped = out.Advance
+	}
+
+	// with a tolerance, some negative spacing is kept
+	out := simpleShape(text, latinFont, di.DirectionLTR)
+	out.AddLetterSpacing(-fixed.I(1000), true, true)
+	tu.Assert(t, len(out.ClampInkCollisions(fixed.I(20))) == 0)
+	tu.Assert(t, out.Advance < clamped)
+	for _, c := range out.InkCollisions(0) {
+		tu.Assert(t, c.Overlap <= fixed.I(20))
+	}
+
+	out = simpleShape(text, latinFont, di.DirectionTTB)
+	tu.Assert(t, out.InkCollisions(0) == nil)
+}
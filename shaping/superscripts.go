@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	"github.com/boxesandglue/typesetting/font"
+	ot "github.com/boxesandglue/typesetting/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	supsTag = ot.NewTag('s', 'u', 'p', 's')
+	subsTag = ot.NewTag('s', 'u', 'b', 's')
+)
+
+// ShapeSuperscript shapes [input] as superscript text.
+//
+// If the font provides the 'sups' feature, it is simply enabled. Otherwise, the superscripts
+// are synthesized from the regular glyphs, using the size and offsets given by [font.Face.SuperscriptMetrics]:
+// the text is shaped with a reduced [Input.Size] (reported in [Output.Size]), and the glyphs
+// are raised (and moved horizontally for italic fonts).
+// The synthesis is only applied to horizontal text.
+func (t *HarfbuzzShaper) ShapeSuperscript(input Input) Output {
+	return t.shapeScript(input, supsTag, input.Face.SuperscriptMetrics)
+}
+
+// ShapeSubscript is the same as [HarfbuzzShaper.ShapeSuperscript], but for subscripts,
+// using the 'subs' feature or [font.Face.SubscriptMetrics].
+func (t *HarfbuzzShaper) ShapeSubscript(input Input) Output {
+	return t.shapeScript(input, subsTag, input.Face.SubscriptMetrics)
+}
+
+func (t *HarfbuzzShaper) shapeScript(input Input, feature ot.Tag, metrics func() font.ScriptMetrics) Output {
+	if _, hasFeature := input.Face.GSUB.FindFeatureIndex(feature); hasFeature || input.Direction.IsVertical() {
+		input.FontFeatures = append(append([]FontFeature(nil), input.FontFeatures...), FontFeature{Tag: feature, Value: 1})
+		return t.Shape(input)
+	}
+
+	sm := metrics()
+	scale := float32(input.Size) / float32(input.Face.Upem())
+	input.Size = fixed.Int26_6(sm.YSize * scale)
+	out := t.Shape(input)
+	if dx := fixed.Int26_6(sm.XOffset * scale); dx != 0 {
+		for i := range out.Glyphs {
+			out.Glyphs[i].XOffset += dx
+		}
+	}
+	out.moveCrossAxis(fixed.Int26_6(sm.YOffset * scale))
+	return out
+}
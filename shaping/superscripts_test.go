@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Unlicense OR BSD-3-Clause
+
+package shaping
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/boxesandglue/typesetting/di"
+	"github.com/boxesandglue/typesetting/font"
+	"github.com/boxesandglue/typesetting/language"
+	tu "github.com/boxesandglue/typesetting/testutils"
+	td "github.com/go-text/typesetting-utils/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestShapeSuperscript(t *testing.T) {
+	b, err := td.Files.ReadFile("common/DejaVuSans.ttf") // no 'sups' nor 'subs' features
+	tu.AssertNoErr(t, err)
+	face, err := font.ParseTTF(bytes.NewReader(b))
+	tu.AssertNoErr(t, err)
+
+	text := []rune("x2")
+	input := Input{
+		Text: text, RunEnd: len(text), Direction: di.DirectionLTR, Face: face,
+		Size: fixed.I(2048), Script: language.Latin, Language: "en",
+	}
+	var shaper HarfbuzzShaper
+	regular := shaper.Shape(input)
+
+	sup := shaper.ShapeSuperscript(input)
+	tu.Assert(t, sup.Size == fixed.I(1433))
+	tu.Assert(t, len(sup.Glyphs) == 2)
+	for i, g := range sup.Glyphs {
+		tu.Assert(t, g.GlyphID == regular.Glyphs[i].GlyphID)
+		tu.Assert(t, g.YOffset == fixed.I(983))
+	}
+	tu.Assert(t, sup.Advance < regular.Advance)
+	tu.Assert(t, sup.GlyphBounds.Descent >= 0)
+
+	sub := shaper.ShapeSubscript(input)
+	tu.Assert(t, sub.Size == fixed.I(1433))
+	for _, g := range sub.Glyphs {
+		tu.Assert(t, g.YOffset == -fixed.I(286))
+	}
+	tu.Assert(t, sub.GlyphBounds.Ascent < regular.GlyphBounds.Ascent)
+
+	// the font provides the feature: use it
+	b, err = td.Files.ReadFile("common/Raleway-v4020-Regular.otf")
+	tu.AssertNoErr(t, err)
+	face, err = font.ParseTTF(bytes.NewReader(b))
+	tu.AssertNoErr(t, err)
+	input.Face = face
+	regular = shaper.Shape(input)
+	sup = shaper.ShapeSuperscript(input)
+	tu.Assert(t, sup.Size == input.Size)
+	tu.Assert(t, sup.Glyphs[0].YOffset == 0)
+	tu.Assert(t, sup.Glyphs[1].GlyphID != regular.Glyphs[1].GlyphID)
+}
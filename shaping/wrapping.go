@@ -277,10 +277,18 @@ func (option breakOption) isValid(runeToGlyph []int, out Output) bool {
 	return true
 }
 
+// isHyphenBreak returns true if breaking after [breakAtRune] ends the line
+// with a soft hyphen which should be displayed using [run.Hyphen].
+func (b *breaker) isHyphenBreak(breakAtRune int, run Output) bool {
+	return run.Hyphen.GlyphID != 0 && 0 <= breakAtRune && breakAtRune < b.totalRunes-1 &&
+		b.text[breakAtRune] == softHyphen
+}
+
 // breaker generates line breaking candidates for a text.
 type breaker struct {
 	wordSegmenter     *segmenter.LineIterator
 	graphemeSegmenter *segmenter.GraphemeIterator
+	text              []rune
 	totalRunes        int
 	// unusedWordBreak is a break requested from the breaker in a previous iteration
 	// but which was not chosen as the line ending. Subsequent invocations of
@@ -302,6 +310,7 @@ func newBreaker(seg *segmenter.Segmenter, text []rune) *breaker {
 	br := &breaker{
 		wordSegmenter:     seg.LineIterator(),
 		graphemeSegmenter: seg.GraphemeIterator(),
+		text:              text,
 		totalRunes:        len(text),
 	}
 	return br
@@ -851,6 +860,9 @@ type WrappedLine struct {
 	// of the next line. It will equal len(text) if all the text
 	// fit in one line.
 	NextLine int
+	// Hyphenated is true if the line is broken after a soft hyphen,
+	// which has been replaced by the [Output.Hyphen] glyph of its run.
+	Hyphenated bool
 }
 
 // swapVisualOrder inverts the visual index of runs in [subline], by swapping pairs of visual indices across the midpoint
@@ -887,6 +899,7 @@ func computeBidiOrdering(dir di.Direction, finalLine Line) {
 }
 
 func (l *LineWrapper) postProcessLine(finalLine Line, done bool) (WrappedLine, bool) {
+	hyphenated := false
 	if len(finalLine) > 0 {
 		computeBidiOrdering(l.config.Direction, finalLine)
 		if !l.config.DisableTrailingWhitespaceTrim {
@@ -928,6 +941,7 @@ func (l *LineWrapper) postProcessLine(finalLine Line, done bool) (WrappedLine, b
 		finalLogicalRun := finalLine[len(finalLine)-1]
 		// Update the start position of the next line.
 		l.lineStartRune = finalLogicalRun.Runes.Count + finalLogicalRun.Runes.Offset
+		hyphenated = l.breaker.isHyphenBreak(l.lineStartRune-1, finalLogicalRun)
 	}
 
 	// Check whether we've exhausted the text.
@@ -958,7 +972,7 @@ func (l *LineWrapper) postProcessLine(finalLine Line, done bool) (WrappedLine, b
 		l.more = false
 	}
 
-	return WrappedLine{finalLine, truncated, l.lineStartRune}, done
+	return WrappedLine{finalLine, truncated, l.lineStartRune, hyphenated}, done
 }
 
 // WrapNextLine wraps the shaped glyphs of a paragraph to a particular max width.
@@ -1184,6 +1198,10 @@ func (l *LineWrapper) processBreakOption(option breakOption, config lineConfig)
 	}
 	isFirstInLine := l.scratch.candidateLen() == 0
 	candidateRun := cutRun(run, l.mapper.mapping, l.lineStartRune, option.breakAtRune, isFirstInLine)
+	if l.breaker.isHyphenBreak(option.breakAtRune, candidateRun) {
+		// the hyphen is displayed if the line ends here
+		candidateRun = candidateRun.withHyphen(option.breakAtRune)
+	}
 	candidateLineWidth := (candidateRun.advanceSpaceAware(l.config.Direction) + l.scratch.candidateAdvance()).Ceil()
 	if candidateLineWidth > config.maxWidth {
 		// The run doesn't fit on the line.
@@ -11,6 +11,7 @@ import (
 
 	"github.com/boxesandglue/typesetting/di"
 	"github.com/boxesandglue/typesetting/font"
+	"github.com/boxesandglue/typesetting/harfbuzz"
 	"github.com/boxesandglue/typesetting/language"
 	"github.com/boxesandglue/typesetting/segmenter"
 	tu "github.com/boxesandglue/typesetting/testutils"
@@ -3495,3 +3496,57 @@ func TestComputeBidiOrdering(t *testing.T) {
 		})
 	}
 }
+
+func TestWrapSoftHyphen(t *testing.T) {
+	text := []rune("pre\u00ADfix\u00ADes")
+	face := loadOpentypeFont(t, "../font/testdata/UbuntuMono-R.ttf")
+	hyphen, _ := face.NominalGlyph('\u00AD')
+
+	input := Input{
+		Text:       text,
+		Face:       face,
+		Size:       72,
+		RunEnd:     len(text),
+		SoftHyphen: harfbuzz.SoftHyphenAtLineEnd,
+	}
+	run := (&HarfbuzzShaper{}).Shape(input)
+	// soft hyphens are invisible mid-line
+	tu.Assert(t, run.Advance == fixed.I(8))
+	tu.Assert(t, run.Hyphen.GlyphID == hyphen && run.Hyphen.XAdvance == fixed.I(1))
+
+	var wrapper LineWrapper
+	wrapper.Prepare(WrapConfig{}, text, NewSliceIterator([]Output{run}))
+	var lines []WrappedLine
+	for done := false; !done; {
+		var line WrappedLine
+		line, done = wrapper.WrapNextLine(4)
+		lines = append(lines, line)
+	}
+	tu.Assert(t, len(lines) == 3)
+	for i, line := range lines {
+		tu.Assert(t, len(line.Line) == 1)
+		glyphs := line.Line[0].Glyphs
+		tu.Assert(t, line.Hyphenated == (i < 2))
+		if line.Hyphenated {
+			tu.Assert(t, line.Line[0].Advance == fixed.I(4))
+			tu.Assert(t, glyphs[len(glyphs)-1].GlyphID == hyphen)
+		}
+	}
+	// the original run is not modified
+	tu.Assert(t, run.Advance == fixed.I(8))
+	tu.Assert(t, run.Glyphs[3].XAdvance == 0)
+
+	// without the policy, no hyphen is displayed
+	input.SoftHyphen = harfbuzz.SoftHyphenInvisible
+	run = (&HarfbuzzShaper{}).Shape(input)
+	tu.Assert(t, run.Hyphen.GlyphID == 0)
+	wrapper.Prepare(WrapConfig{}, text, NewSliceIterator([]Output{run}))
+	line, _ := wrapper.WrapNextLine(4)
+	tu.Assert(t, !line.Hyphenated && line.Line[0].Advance == fixed.I(3))
+
+	// always visible
+	input.SoftHyphen = harfbuzz.SoftHyphenVisible
+	run = (&HarfbuzzShaper{}).Shape(input)
+	tu.Assert(t, run.Advance == fixed.I(10))
+	tu.Assert(t, run.Glyphs[3].GlyphID == hyphen)
+}
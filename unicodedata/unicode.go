@@ -177,6 +177,46 @@ func Compose(a, b rune) (rune, bool) {
 	return u, u != 0
 }
 
+// CJK compatibility ideographs are canonically equivalent to a unified ideograph,
+// so that the distinction is lost by normalization. Since Unicode 6.3, each of them
+// has a standardized variation sequence, using the unified ideograph followed by
+// VS1 (U+FE00), VS2, ..., in the order of the compatibility ideographs.
+var compatibilityIdeographs, compatibilityIdeographVariants = buildCompatibilityIdeographs()
+
+func buildCompatibilityIdeographs() (map[rune][2]rune, map[[2]rune]rune) {
+	toVariant, fromVariant := make(map[rune][2]rune), make(map[[2]rune]rune)
+	counts := make(map[rune]rune)
+	for _, block := range [...][2]rune{{0xF900, 0xFAFF}, {0x2F800, 0x2FA1F}} {
+		for r := block[0]; r <= block[1]; r++ {
+			base, ok := decompose1[r]
+			if !ok {
+				continue
+			}
+			seq := [2]rune{base, 0xFE00 + counts[base]}
+			counts[base]++
+			toVariant[r] = seq
+			fromVariant[seq] = r
+		}
+	}
+	return toVariant, fromVariant
+}
+
+// LookupCompatibilityIdeographVariant returns the standardized variation sequence
+// (unified ideograph and variation selector) equivalent to the CJK compatibility ideograph [r],
+// or false if [r] is not a compatibility ideograph.
+func LookupCompatibilityIdeographVariant(r rune) (base, selector rune, ok bool) {
+	seq, ok := compatibilityIdeographs[r]
+	return seq[0], seq[1], ok
+}
+
+// LookupCompatibilityIdeograph is the inverse of [LookupCompatibilityIdeographVariant]:
+// it returns the CJK compatibility ideograph equivalent to the
+// standardized variation sequence [base] followed by [selector], if any.
+func LookupCompatibilityIdeograph(base, selector rune) (rune, bool) {
+	r, ok := compatibilityIdeographVariants[[2]rune{base, selector}]
+	return r, ok
+}
+
 // ArabicJoining is a property used to shape Arabic runes.
 // See the table ArabicJoinings.
 type ArabicJoining byte
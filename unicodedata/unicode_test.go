@@ -616,3 +616,26 @@ func TestLookupVerticalOrientation(t *testing.T) {
 		}
 	}
 }
+
+func TestLookupCompatibilityIdeograph(t *testing.T) {
+	for _, test := range []struct {
+		compat, base, selector rune
+	}{
+		{'\uF900', '\u8C48', '\uFE00'},
+		{'\uF907', '\u9F9C', '\uFE00'},
+		{'\uF908', '\u9F9C', '\uFE01'},
+		{'\uFACE', '\u9F9C', '\uFE02'},
+		{'\U0002F800', '\u4E3D', '\uFE00'},
+	} {
+		base, selector, ok := LookupCompatibilityIdeographVariant(test.compat)
+		tu.Assert(t, ok && base == test.base && selector == test.selector)
+		compat, ok := LookupCompatibilityIdeograph(test.base, test.selector)
+		tu.Assert(t, ok && compat == test.compat)
+	}
+
+	_, _, ok := LookupCompatibilityIdeographVariant('\uFA0E') // unified ideograph
+	tu.Assert(t, !ok)
+	_, ok = LookupCompatibilityIdeograph('\u8C48', '\uFE01')
+	tu.Assert(t, !ok)
+	tu.Assert(t, len(compatibilityIdeographs) == len(compatibilityIdeographVariants))
+}